@@ -47,6 +47,7 @@ type AddrManager struct {
 	nNew           int
 	lamtx          sync.Mutex
 	localAddresses map[string]*localAddress
+	asmap          *ASMap
 }
 
 type serializedKnownAddress struct {
@@ -65,6 +66,10 @@ type serializedAddrManager struct {
 	Addresses    []*serializedKnownAddress
 	NewBuckets   [newBucketCount][]string // string is NetAddressKey
 	TriedBuckets [triedBucketCount][]string
+
+	// ASMapChecksum is the checksum of the asmap used to bucket the
+	// addresses or empty when no asmap was used.
+	ASMapChecksum string
 }
 
 type localAddress struct {
@@ -83,7 +88,7 @@ const (
 	// BoundPrio signifies the address has been explicitly bounded to.
 	BoundPrio
 
-	// UpnpPrio signifies the address was obtained from UPnP.
+	// UpnpPrio signifies the address was obtained from UPnP, NAT-PMP or PCP.
 	UpnpPrio
 
 	// HTTPPrio signifies the address was obtained from an external HTTP service.
@@ -299,8 +304,8 @@ func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
 
 	data1 := []byte{}
 	data1 = append(data1, a.key[:]...)
-	data1 = append(data1, []byte(GroupKey(netAddr))...)
-	data1 = append(data1, []byte(GroupKey(srcAddr))...)
+	data1 = append(data1, []byte(a.GroupKey(netAddr))...)
+	data1 = append(data1, []byte(a.GroupKey(srcAddr))...)
 	hash1 := chainhash.HashB(data1)
 	hash64 := binary.LittleEndian.Uint64(hash1)
 	hash64 %= newBucketsPerGroup
@@ -308,7 +313,7 @@ func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(srcAddr)...)
+	data2 = append(data2, a.GroupKey(srcAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.HashB(data2)
@@ -329,7 +334,7 @@ func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(netAddr)...)
+	data2 = append(data2, a.GroupKey(netAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.HashB(data2)
@@ -367,6 +372,7 @@ func (a *AddrManager) savePeers() {
 	sam := new(serializedAddrManager)
 	sam.Version = serialisationVersion
 	copy(sam.Key[:], a.key[:])
+	sam.ASMapChecksum = a.asmapChecksum()
 
 	sam.Addresses = make([]*serializedKnownAddress, len(a.addrIndex))
 	i := 0
@@ -520,9 +526,65 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 		}
 	}
 
+	// The buckets depend on the address groups, so redistribute the
+	// addresses when the asmap changed since they were saved.
+	if sam.ASMapChecksum != a.asmapChecksum() {
+		log.Infof("Asmap changed since the addresses were saved -- "+
+			"rebucketing %d addresses", len(a.addrIndex))
+		a.rebucket()
+	}
+
 	return nil
 }
 
+// asmapChecksum returns the checksum of the asmap in use or an empty string
+// when no asmap is set.
+func (a *AddrManager) asmapChecksum() string {
+	if a.asmap == nil {
+		return ""
+	}
+	return a.asmap.Checksum()
+}
+
+// rebucket redistributes all known addresses over the new and tried buckets
+// according to the current address groups.  Tried addresses whose tried bucket
+// is full are moved to the new buckets and addresses whose new bucket is full
+// are forgotten.
+//
+// This function MUST be called with the address manager lock held.
+func (a *AddrManager) rebucket() {
+	for i := range a.addrNew {
+		a.addrNew[i] = make(map[string]*KnownAddress)
+	}
+	for i := range a.addrTried {
+		a.addrTried[i] = list.New()
+	}
+	a.nNew = 0
+	a.nTried = 0
+
+	for k, ka := range a.addrIndex {
+		ka.refs = 0
+		if ka.tried {
+			bucket := a.getTriedBucket(ka.na)
+			if a.addrTried[bucket].Len() < triedBucketSize {
+				a.addrTried[bucket].PushBack(ka)
+				a.nTried++
+				continue
+			}
+			ka.tried = false
+		}
+
+		bucket := a.getNewBucket(ka.na, ka.srcAddr)
+		if len(a.addrNew[bucket]) >= newBucketSize {
+			delete(a.addrIndex, k)
+			continue
+		}
+		a.addrNew[bucket][k] = ka
+		ka.refs = 1
+		a.nNew++
+	}
+}
+
 // DeserializeNetAddress converts a given address string to a *wire.NetAddress
 func (a *AddrManager) DeserializeNetAddress(addr string) (*wire.NetAddress, error) {
 	host, portStr, err := net.SplitHostPort(addr)
@@ -537,6 +599,30 @@ func (a *AddrManager) DeserializeNetAddress(addr string) (*wire.NetAddress, erro
 	return a.HostToNetAddress(host, uint16(port), wire.SFNodeNetwork)
 }
 
+// SetASMap sets the asmap used to group addresses by the autonomous system
+// which announces them rather than by their network prefix.  Grouping by
+// autonomous system makes it more difficult for an attacker which controls
+// many network prefixes to dominate the buckets and the outbound connections.
+//
+// This function MUST be called before Start.
+func (a *AddrManager) SetASMap(asmap *ASMap) {
+	a.asmap = asmap
+}
+
+// GroupKey returns a string representing the network group an address is part
+// of.  When an asmap is set, addresses with a known autonomous system number
+// are grouped by it using the string "as" followed by the number.  Otherwise,
+// the group is the same as the one returned by the package level GroupKey
+// function.
+func (a *AddrManager) GroupKey(na *wire.NetAddress) string {
+	if a.asmap != nil {
+		if asn := a.asmap.ASN(na); asn != 0 {
+			return fmt.Sprintf("as%d", asn)
+		}
+	}
+	return GroupKey(na)
+}
+
 // Start begins the core address handler which manages a pool of known
 // addresses, timeouts, and interval based writes.
 func (a *AddrManager) Start() {
@@ -0,0 +1,349 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr
+
+import (
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"math/bits"
+	"net"
+
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/wire"
+)
+
+// asmapInvalid is returned when decoding a value from an asmap fails because
+// it is truncated.
+const asmapInvalid = 0xffffffff
+
+// asmapOpcode is an instruction of the asmap trie program.
+type asmapOpcode uint32
+
+// These constants define the instructions of the asmap trie program.
+const (
+	// asmapReturn returns the ASN which follows it.
+	asmapReturn asmapOpcode = iota
+
+	// asmapJump consumes one bit of the IP address and skips the number
+	// of bits which follows it in the program when the bit is set.
+	asmapJump
+
+	// asmapMatch compares the bits which follow it against the next bits
+	// of the IP address and returns the current default ASN on mismatch.
+	asmapMatch
+
+	// asmapDefault sets the default ASN to the ASN which follows it.
+	asmapDefault
+)
+
+// These variables define the variable length encodings of the values in the
+// asmap trie program.  See decodeBits for details.
+var (
+	asmapTypeBitSizes  = []uint8{0, 0, 1}
+	asmapASNBitSizes   = []uint8{15, 16, 17, 18, 19, 20, 21, 22, 23, 24}
+	asmapMatchBitSizes = []uint8{1, 2, 3, 4, 5, 6, 7, 8}
+	asmapJumpBitSizes  = []uint8{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16,
+		17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30}
+)
+
+// ErrInvalidASMap describes an error where an asmap is malformed.
+var ErrInvalidASMap = errors.New("invalid asmap")
+
+// ASMap maps IP addresses to the autonomous system (AS) which announces them.
+// It uses the compact binary trie encoding produced by the asmap tooling of
+// Bitcoin Core so existing asmap files may be used as is.
+type ASMap struct {
+	data     []byte
+	numBits  int
+	checksum string
+}
+
+// DecodeASMap decodes and sanity checks the passed serialized asmap.
+func DecodeASMap(data []byte) (*ASMap, error) {
+	m := &ASMap{
+		data:     data,
+		numBits:  len(data) * 8,
+		checksum: hex.EncodeToString(chainhash.HashB(data)),
+	}
+	if !m.sane(128) {
+		return nil, ErrInvalidASMap
+	}
+	return m, nil
+}
+
+// LoadASMap reads, decodes and sanity checks the asmap file at the given path.
+func LoadASMap(path string) (*ASMap, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeASMap(data)
+}
+
+// Checksum returns the hex-encoded hash of the serialized asmap.  It is used
+// to detect when the asmap changes between runs.
+func (m *ASMap) Checksum() string {
+	return m.checksum
+}
+
+// bit returns the bit of the program at the passed position.  Bits are stored
+// least significant first within each byte.
+func (m *ASMap) bit(pos int) uint32 {
+	return uint32(m.data[pos>>3]>>(uint(pos)&7)) & 1
+}
+
+// decodeBits decodes a variable length value starting at the passed position
+// and advances the position past it.  Each entry of bitSizes except the last is
+// preceded by a single bit which when set adds 1<<size to the value and moves
+// on to the next entry.  Otherwise, the next size bits are added to the value
+// most significant first.  asmapInvalid is returned when the value is
+// truncated.
+func (m *ASMap) decodeBits(pos *int, minVal uint32, bitSizes []uint8) uint32 {
+	val := minVal
+	for i, size := range bitSizes {
+		var bit uint32
+		if i != len(bitSizes)-1 {
+			if *pos == m.numBits {
+				break
+			}
+			bit = m.bit(*pos)
+			*pos++
+		}
+		if bit == 1 {
+			val += 1 << size
+			continue
+		}
+		for b := uint8(0); b < size; b++ {
+			if *pos == m.numBits {
+				return asmapInvalid
+			}
+			val += m.bit(*pos) << (size - 1 - b)
+			*pos++
+		}
+		return val
+	}
+	return asmapInvalid
+}
+
+func (m *ASMap) decodeType(pos *int) asmapOpcode {
+	return asmapOpcode(m.decodeBits(pos, 0, asmapTypeBitSizes))
+}
+
+func (m *ASMap) decodeASN(pos *int) uint32 {
+	return m.decodeBits(pos, 1, asmapASNBitSizes)
+}
+
+func (m *ASMap) decodeMatch(pos *int) uint32 {
+	return m.decodeBits(pos, 2, asmapMatchBitSizes)
+}
+
+func (m *ASMap) decodeJump(pos *int) uint32 {
+	return m.decodeBits(pos, 17, asmapJumpBitSizes)
+}
+
+// sane returns whether the asmap is a well formed program for IP addresses of
+// the passed number of bits.  In particular, it ensures every possible input
+// reaches a return instruction without running past the end of the program so
+// that interpret never fails.
+func (m *ASMap) sane(numIPBits int) bool {
+	type jumpTarget struct {
+		offset int
+		ipBits int
+	}
+	var jumps []jumpTarget
+	var pos int
+	ipBits := numIPBits
+	prevOpcode := asmapJump
+	hadIncompleteMatch := false
+	for pos != m.numBits {
+		if len(jumps) > 0 && pos >= jumps[len(jumps)-1].offset {
+			// Jump into the middle of the previous instruction.
+			return false
+		}
+		opcode := m.decodeType(&pos)
+		switch opcode {
+		case asmapReturn:
+			if prevOpcode == asmapDefault {
+				// A default followed by a return is redundant.
+				return false
+			}
+			if m.decodeASN(&pos) == asmapInvalid {
+				return false
+			}
+			if len(jumps) == 0 {
+				// Nothing else to execute, so ensure there is
+				// no more than a byte of zero padding left.
+				if m.numBits-pos > 7 {
+					return false
+				}
+				for ; pos != m.numBits; pos++ {
+					if m.bit(pos) != 0 {
+						return false
+					}
+				}
+				return true
+			}
+
+			// Continue as if the last jump was taken.
+			last := jumps[len(jumps)-1]
+			if pos != last.offset {
+				// Unreachable code.
+				return false
+			}
+			ipBits = last.ipBits
+			jumps = jumps[:len(jumps)-1]
+			prevOpcode = asmapJump
+
+		case asmapJump:
+			jump := m.decodeJump(&pos)
+			if jump == asmapInvalid {
+				return false
+			}
+			if int64(jump) > int64(m.numBits-pos) || ipBits == 0 {
+				return false
+			}
+			ipBits--
+			jumpOffset := pos + int(jump)
+			if len(jumps) > 0 && jumpOffset >= jumps[len(jumps)-1].offset {
+				// Intersecting jumps.
+				return false
+			}
+			jumps = append(jumps, jumpTarget{jumpOffset, ipBits})
+			prevOpcode = asmapJump
+
+		case asmapMatch:
+			match := m.decodeMatch(&pos)
+			if match == asmapInvalid {
+				return false
+			}
+			matchLen := bits.Len32(match) - 1
+			if prevOpcode != asmapMatch {
+				hadIncompleteMatch = false
+			}
+			if matchLen < 8 && hadIncompleteMatch {
+				// Only one incomplete match is allowed within a
+				// sequence of matches.
+				return false
+			}
+			hadIncompleteMatch = matchLen < 8
+			if ipBits < matchLen {
+				return false
+			}
+			ipBits -= matchLen
+			prevOpcode = asmapMatch
+
+		case asmapDefault:
+			if prevOpcode == asmapDefault {
+				// Successive defaults are redundant.
+				return false
+			}
+			if m.decodeASN(&pos) == asmapInvalid {
+				return false
+			}
+			prevOpcode = asmapDefault
+
+		default:
+			// Instruction straddles the end of the program.
+			return false
+		}
+	}
+
+	// Reached the end of the program without a return.
+	return false
+}
+
+// interpret runs the asmap program against the passed 16-byte IPv6 (or IPv4
+// mapped) address and returns its ASN.  A return value of 0 means the ASN is
+// unknown.
+func (m *ASMap) interpret(ip net.IP) uint32 {
+	ipBit := func(i int) uint32 {
+		return uint32(ip[i>>3]>>(7-uint(i)&7)) & 1
+	}
+
+	var pos int
+	ipBits := len(ip) * 8
+	var defaultASN uint32
+	for pos != m.numBits {
+		switch m.decodeType(&pos) {
+		case asmapReturn:
+			asn := m.decodeASN(&pos)
+			if asn == asmapInvalid {
+				return 0
+			}
+			return asn
+
+		case asmapJump:
+			jump := m.decodeJump(&pos)
+			if jump == asmapInvalid || ipBits == 0 ||
+				int64(jump) >= int64(m.numBits-pos) {
+				return 0
+			}
+			if ipBit(len(ip)*8-ipBits) == 1 {
+				pos += int(jump)
+			}
+			ipBits--
+
+		case asmapMatch:
+			match := m.decodeMatch(&pos)
+			if match == asmapInvalid {
+				return 0
+			}
+			matchLen := bits.Len32(match) - 1
+			if ipBits < matchLen {
+				return 0
+			}
+			for bit := 0; bit < matchLen; bit++ {
+				want := (match >> uint(matchLen-1-bit)) & 1
+				if ipBit(len(ip)*8-ipBits) != want {
+					return defaultASN
+				}
+				ipBits--
+			}
+
+		case asmapDefault:
+			defaultASN = m.decodeASN(&pos)
+			if defaultASN == asmapInvalid {
+				return 0
+			}
+
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+// ASN returns the autonomous system number which announces the passed address
+// or 0 when it is unknown.  The IPv4 address embedded in IPv6 tunnelling and
+// translation addresses is used for lookups.  Addresses which are not IPv4 or
+// IPv6, such as tor addresses, always return 0.
+func (m *ASMap) ASN(na *wire.NetAddress) uint32 {
+	if IsLocal(na) || !IsRoutable(na) || IsOnionCatTor(na) {
+		return 0
+	}
+
+	var ip net.IP
+	switch {
+	case IsIPv4(na):
+		ip = na.IP.To16()
+	case IsRFC6145(na) || IsRFC6052(na):
+		ip = net.IP(na.IP[12:16]).To16()
+	case IsRFC3964(na):
+		ip = net.IP(na.IP[2:6]).To16()
+	case IsRFC4380(na):
+		v4 := make(net.IP, 4)
+		for i, b := range na.IP[12:16] {
+			v4[i] = b ^ 0xff
+		}
+		ip = v4.To16()
+	default:
+		ip = na.IP.To16()
+	}
+	if ip == nil {
+		return 0
+	}
+	return m.interpret(ip)
+}
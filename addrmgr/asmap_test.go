@@ -0,0 +1,218 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coolsnady/hcd/addrmgr"
+	"github.com/coolsnady/hcd/wire"
+)
+
+// asmapWriter is used to build serialized asmaps for the tests.
+type asmapWriter struct {
+	data    []byte
+	numBits int
+}
+
+// writeBit appends a single bit.  Bits are stored least significant first
+// within each byte.
+func (w *asmapWriter) writeBit(bit uint32) {
+	if w.numBits%8 == 0 {
+		w.data = append(w.data, 0)
+	}
+	w.data[w.numBits/8] |= byte(bit&1) << uint(w.numBits%8)
+	w.numBits++
+}
+
+// encode appends the variable length encoding of the passed value.
+func (w *asmapWriter) encode(val, minVal uint32, bitSizes []uint8) {
+	val -= minVal
+	for i, size := range bitSizes {
+		last := i == len(bitSizes)-1
+		if !last && val >= 1<<size {
+			w.writeBit(1)
+			val -= 1 << size
+			continue
+		}
+		if !last {
+			w.writeBit(0)
+		}
+		for b := int(size) - 1; b >= 0; b-- {
+			w.writeBit(val >> uint(b))
+		}
+		return
+	}
+}
+
+func (w *asmapWriter) ret(asn uint32) {
+	w.encode(0, 0, []uint8{0, 0, 1})
+	w.encode(asn, 1, []uint8{15, 16, 17, 18, 19, 20, 21, 22, 23, 24})
+}
+
+func (w *asmapWriter) jump(offset uint32) {
+	w.encode(1, 0, []uint8{0, 0, 1})
+	w.encode(offset, 17, []uint8{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30})
+}
+
+// matchBytes appends match instructions for all bits of the passed bytes.
+func (w *asmapWriter) matchBytes(b []byte) {
+	for _, v := range b {
+		w.encode(2, 0, []uint8{0, 0, 1})
+		w.encode(1<<8|uint32(v), 2, []uint8{1, 2, 3, 4, 5, 6, 7, 8})
+	}
+}
+
+// testASMap returns a serialized asmap which maps 1.2.0.0/16 to AS 100,
+// 1.3.0.0/16 to AS 200 and everything else to an unknown ASN.
+func testASMap() []byte {
+	prefix := net.IPv4(1, 0, 0, 0).To16()[:13]
+	var w asmapWriter
+	w.matchBytes(prefix)
+
+	// The next byte is 2 (00000010) or 3 (00000011), so match the first
+	// seven bits as an incomplete match and then jump on the last bit over
+	// the return of AS 100, which is 17 bits long.
+	w.encode(2, 0, []uint8{0, 0, 1})
+	w.encode(1<<7|1, 2, []uint8{1, 2, 3, 4, 5, 6, 7, 8})
+	w.jump(17)
+	w.ret(100)
+	w.ret(200)
+	return w.data
+}
+
+// TestASMap ensures asmaps are decoded, sanity checked and interpreted as
+// expected.
+func TestASMap(t *testing.T) {
+	asmap, err := addrmgr.DecodeASMap(testASMap())
+	if err != nil {
+		t.Fatalf("DecodeASMap: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want uint32
+	}{
+		{"1.2.3.4", 100},
+		{"1.2.255.255", 100},
+		{"1.3.0.1", 200},
+		{"1.4.0.1", 0},
+		{"8.8.8.8", 0},
+		{"2001:470::1", 0},
+		{"2002:102:304::1", 100},                      // 6to4 1.2.3.4
+		{"2001:0:0:0:0:0:fefc:fcfb", 200},             // teredo 1.3.3.4
+		{"fd87:d87e:eb43:edb1:8e4:3588:e546:35ca", 0}, // tor
+		{"10.0.0.1", 0},
+	}
+	for _, test := range tests {
+		na := wire.NewNetAddressIPPort(net.ParseIP(test.ip), 8333,
+			wire.SFNodeNetwork)
+		if got := asmap.ASN(na); got != test.want {
+			t.Errorf("ASN(%s): got %d, want %d", test.ip, got,
+				test.want)
+		}
+	}
+
+	// Malformed asmaps must be rejected.
+	var noReturn asmapWriter
+	noReturn.matchBytes([]byte{1, 2})
+	invalid := [][]byte{
+		nil,
+		testASMap()[:10],
+		append(testASMap(), 0),
+		noReturn.data,
+	}
+	for i, data := range invalid {
+		if _, err := addrmgr.DecodeASMap(data); err != addrmgr.ErrInvalidASMap {
+			t.Errorf("DecodeASMap #%d: got error %v, want %v", i,
+				err, addrmgr.ErrInvalidASMap)
+		}
+	}
+}
+
+// TestASMapGroupKey ensures the address manager groups addresses by their
+// ASN when an asmap is set and rebuckets the saved addresses when the asmap
+// changes between runs.
+func TestASMapGroupKey(t *testing.T) {
+	asmap, err := addrmgr.DecodeASMap(testASMap())
+	if err != nil {
+		t.Fatalf("DecodeASMap: unexpected error: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "asmapgroupkey")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	n := addrmgr.New(dir, lookupFunc)
+	na1 := wire.NewNetAddressIPPort(net.ParseIP("1.2.3.4"), 8333, 0)
+	na2 := wire.NewNetAddressIPPort(net.ParseIP("1.2.200.4"), 8333, 0)
+	na3 := wire.NewNetAddressIPPort(net.ParseIP("1.3.0.1"), 8333, 0)
+	if n.GroupKey(na1) != "1.2.0.0" || n.GroupKey(na3) != "1.3.0.0" {
+		t.Fatalf("unexpected group keys without asmap: %s, %s",
+			n.GroupKey(na1), n.GroupKey(na3))
+	}
+
+	// Add a number of addresses, mark some of them good and save them to
+	// disk without an asmap.
+	srcAddr := wire.NewNetAddressIPPort(net.ParseIP("1.3.2.1"), 8333, 0)
+	var addrs []*wire.NetAddress
+	for i := 0; i < 256; i++ {
+		s := fmt.Sprintf("%d.%d.147.%d:8333", i/32+60, i%32+1, i)
+		addr, err := n.DeserializeNetAddress(s)
+		if err != nil {
+			t.Fatalf("Failed to turn %s into an address: %v", s, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	n.Start()
+	n.AddAddresses(addrs, srcAddr)
+	for _, addr := range addrs[:64] {
+		n.Good(addr)
+	}
+	wantNumAddrs := n.NumAddresses()
+	if err := n.Stop(); err != nil {
+		t.Fatalf("Address Manager failed to stop: %v", err)
+	}
+
+	// Reload the addresses with an asmap which results in them being
+	// rebucketed since the buckets are based on the address groups.
+	n = addrmgr.New(dir, lookupFunc)
+	n.SetASMap(asmap)
+	n.Start()
+	if got := n.NumAddresses(); got != wantNumAddrs {
+		t.Errorf("unexpected number of addresses after rebucket: got "+
+			"%d, want %d", got, wantNumAddrs)
+	}
+	if n.GroupKey(na1) != "as100" || n.GroupKey(na1) != n.GroupKey(na2) {
+		t.Errorf("unexpected group keys with asmap: %s, %s",
+			n.GroupKey(na1), n.GroupKey(na2))
+	}
+	if n.GroupKey(na3) != "as200" {
+		t.Errorf("unexpected group key with asmap: %s", n.GroupKey(na3))
+	}
+	if err := n.Stop(); err != nil {
+		t.Fatalf("Address Manager failed to stop: %v", err)
+	}
+
+	// The checksum of the asmap must have been saved with the addresses.
+	f, err := ioutil.ReadFile(filepath.Join(dir, "peers.json"))
+	if err != nil {
+		t.Fatalf("ReadFile: unexpected error: %v", err)
+	}
+	want := fmt.Sprintf(`"ASMapChecksum":"%s"`, asmap.Checksum())
+	if !bytes.Contains(f, []byte(want)) {
+		t.Errorf("saved peers do not contain asmap checksum %s",
+			asmap.Checksum())
+	}
+}
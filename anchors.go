@@ -0,0 +1,65 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+)
+
+// anchorsFilename is the name of the file in the data directory used to
+// persist the addresses of the block-relay-only peers between runs.
+const anchorsFilename = "anchors.json"
+
+// saveAnchors writes the passed block-relay-only peer addresses to the anchors
+// file at the given path so they can be reconnected to on the next start.
+func saveAnchors(path string, addrs []string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(addrs); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadAnchors reads the block-relay-only peer addresses from the anchors file
+// at the given path and removes the file afterwards so that the same anchors
+// are not reused should they turn out to be unreachable or misbehave.  A
+// missing file is not an error.  Addresses which can not be resolved are
+// skipped.
+func loadAnchors(path string) ([]net.Addr, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var addrStrs []string
+	err = json.NewDecoder(f).Decode(&addrStrs)
+	f.Close()
+	if removeErr := os.Remove(path); removeErr != nil {
+		srvrLog.Warnf("Failed to remove anchors file %s: %v", path,
+			removeErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]net.Addr, 0, len(addrStrs))
+	for _, addrStr := range addrStrs {
+		addr, err := addrStringToNetAddr(addrStr)
+		if err != nil {
+			srvrLog.Debugf("Skipping anchor %s: %v", addrStr, err)
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
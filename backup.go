@@ -0,0 +1,136 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coolsnady/hcd/database"
+)
+
+// backupLogInterval is the minimum amount of time between progress messages
+// logged while backing up the block database.
+const backupLogInterval = 10 * time.Second
+
+// errNoBackup is returned by chainBackup.status when no backup has been
+// started.
+var errNoBackup = errors.New("no backup has been started")
+
+// backupInfo describes the state of a block database backup.
+type backupInfo struct {
+	destination string
+	inProgress  bool
+	startTime   time.Time
+	endTime     time.Time
+	progress    database.BackupProgress
+	err         error
+}
+
+// chainBackup tracks the most recent backup of the block database which was
+// started while the server is running.  Only a single backup may run at a
+// time.  The zero value is ready to use.
+type chainBackup struct {
+	mtx     sync.Mutex
+	started bool
+	info    backupInfo
+}
+
+// start starts a backup of the passed database to the destination directory
+// in the background.  The backup is stopped when the passed quit channel is
+// closed and the passed wait group is used to track the backup goroutine.
+// Backups to a directory which contains an incomplete backup resume it.
+func (b *chainBackup) start(db database.DB, dbPath, destination string, wg *sync.WaitGroup, quit <-chan struct{}) error {
+	backuper, ok := db.(database.Backuper)
+	if !ok {
+		return fmt.Errorf("the %s database type does not support backups",
+			cfg.DbType)
+	}
+
+	// Refuse to write the backup over or into the live database.
+	destination = cleanAndExpandPath(destination)
+	absDest, err := filepath.Abs(destination)
+	if err != nil {
+		return err
+	}
+	absDbPath, err := filepath.Abs(dbPath)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(absDbPath, absDest)
+	if err == nil && rel != ".." &&
+		!strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+
+		return fmt.Errorf("the backup destination may not be inside the "+
+			"block database directory %q", dbPath)
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.started && b.info.inProgress {
+		return fmt.Errorf("a backup to %q is already in progress",
+			b.info.destination)
+	}
+	b.started = true
+	b.info = backupInfo{
+		destination: absDest,
+		inProgress:  true,
+		startTime:   time.Now(),
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		bcdbLog.Infof("Backing up block database to %s", absDest)
+		var lastLog time.Time
+		progress := func(p database.BackupProgress) {
+			b.mtx.Lock()
+			b.info.progress = p
+			b.mtx.Unlock()
+
+			if time.Since(lastLog) < backupLogInterval {
+				return
+			}
+			lastLog = time.Now()
+			bcdbLog.Infof("Backed up %d of %d MiB of block data, %d "+
+				"metadata entries", p.CopiedBytes>>20,
+				p.TotalBytes>>20, p.MetadataEntries)
+		}
+		err := backuper.Backup(absDest, progress, quit)
+
+		b.mtx.Lock()
+		b.info.inProgress = false
+		b.info.endTime = time.Now()
+		b.info.err = err
+		elapsed := b.info.endTime.Sub(b.info.startTime)
+		b.mtx.Unlock()
+
+		if err != nil {
+			bcdbLog.Errorf("Failed to back up block database to %s: %v",
+				absDest, err)
+			return
+		}
+		bcdbLog.Infof("Backed up block database to %s in %v", absDest,
+			elapsed)
+	}()
+	return nil
+}
+
+// status returns the state of the most recently started backup.
+//
+// This function is safe for concurrent access.
+func (b *chainBackup) status() (backupInfo, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if !b.started {
+		return backupInfo{}, errNoBackup
+	}
+	return b.info, nil
+}
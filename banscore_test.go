@@ -0,0 +1,215 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/peer"
+	"github.com/coolsnady/hcd/wire"
+)
+
+// simPeer is the remote end of a connection to a server peer.  It is used to
+// send arbitrary, possibly malformed, messages to the server peer.
+type simPeer struct {
+	conn   net.Conn
+	params *chaincfg.Params
+}
+
+// writeMessage writes the passed message to the server peer.
+func (sim *simPeer) writeMessage(msg wire.Message) error {
+	return wire.WriteMessage(sim.conn, msg, maxProtocolVersion,
+		sim.params.Net)
+}
+
+// writeRawMessage writes a message with the passed command and payload to the
+// server peer without encoding it as a wire message first.  This allows
+// sending payloads wire.WriteMessage refuses to encode.
+func (sim *simPeer) writeRawMessage(command string, payload []byte) error {
+	var hdr [wire.MessageHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(sim.params.Net))
+	copy(hdr[4:4+wire.CommandSize], command)
+	binary.LittleEndian.PutUint32(hdr[16:20], uint32(len(payload)))
+	copy(hdr[20:24], chainhash.HashB(payload)[:4])
+	_, err := sim.conn.Write(append(hdr[:], payload...))
+	return err
+}
+
+// newSimServerPeer returns an inbound server peer for the passed server along
+// with a simulated remote peer which has completed the version handshake with
+// it.
+func newSimServerPeer(t *testing.T, s *server, isWhitelisted bool) (*serverPeer, *simPeer) {
+	sp := newServerPeer(s, false)
+	sp.isWhitelisted = isWhitelisted
+	sp.Peer = peer.NewInboundPeer(&peer.Config{
+		Listeners: peer.MessageListeners{
+			OnFilterAdd: sp.OnFilterAdd,
+			OnRead:      sp.OnRead,
+			OnWrite:     sp.OnWrite,
+		},
+		NewestBlock: func() (*chainhash.Hash, int64, error) {
+			return &chainhash.Hash{}, 0, nil
+		},
+		ChainParams:     s.chainParams,
+		Services:        s.services,
+		ProtocolVersion: maxProtocolVersion,
+	})
+
+	remoteAddr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 9108}
+	local, remote := fakeConnPair(remoteAddr)
+	sim := &simPeer{conn: remote, params: s.chainParams}
+
+	// Discard everything the server peer sends.
+	go io.Copy(ioutil.Discard, remote)
+
+	sp.AssociateConnection(local)
+	me := wire.NewNetAddressIPPort(remoteAddr.IP, 9108, wire.SFNodeNetwork)
+	you := wire.NewNetAddressIPPort(net.IPv4(127, 0, 0, 1), 9108,
+		wire.SFNodeNetwork)
+	err := sim.writeMessage(wire.NewMsgVersion(me, you, 1, 0))
+	if err != nil {
+		t.Fatalf("failed to send version message: %v", err)
+	}
+	if err := sim.writeMessage(wire.NewMsgVerAck()); err != nil {
+		t.Fatalf("failed to send verack message: %v", err)
+	}
+	return sp, sim
+}
+
+// TestBanScoreMalformedMessages ensures server peers sending malformed or
+// oversized messages as well as protocol violations are disconnected and have
+// their ban score increased accordingly.
+func TestBanScoreMalformedMessages(t *testing.T) {
+	// Create payloads which exceed the per message item limits.
+	var tooManyHeaders bytes.Buffer
+	wire.WriteVarInt(&tooManyHeaders, 0, wire.MaxBlockHeadersPerMsg+1)
+	var tooManyTxIns bytes.Buffer
+	binary.Write(&tooManyTxIns, binary.LittleEndian, uint32(1))
+	wire.WriteVarInt(&tooManyTxIns, 0, 1<<24)
+	var tooLargeAlert bytes.Buffer
+	wire.WriteVarInt(&tooLargeAlert, 0, wire.MaxMessagePayload+1)
+
+	tests := []struct {
+		name        string
+		command     string
+		payload     []byte
+		msg         wire.Message
+		whitelisted bool
+		threshold   uint32
+		wantScore   uint32
+		wantBanned  bool
+	}{{
+		name:      "headers count exceeds max",
+		command:   wire.CmdHeaders,
+		payload:   tooManyHeaders.Bytes(),
+		wantScore: 100,
+	}, {
+		name:      "tx input count exceeds max",
+		command:   wire.CmdTx,
+		payload:   tooManyTxIns.Bytes(),
+		wantScore: 100,
+	}, {
+		name:      "alert payload exceeds max",
+		command:   wire.CmdAlert,
+		payload:   tooLargeAlert.Bytes(),
+		wantScore: 100,
+	}, {
+		name:       "malformed message above ban threshold",
+		command:    wire.CmdHeaders,
+		payload:    tooManyHeaders.Bytes(),
+		threshold:  50,
+		wantScore:  100,
+		wantBanned: true,
+	}, {
+		name:        "malformed message from whitelisted peer",
+		command:     wire.CmdHeaders,
+		payload:     tooManyHeaders.Bytes(),
+		whitelisted: true,
+		wantScore:   0,
+	}, {
+		name:      "unknown command",
+		command:   "futurecmd",
+		payload:   []byte{0x01},
+		wantScore: 0,
+	}, {
+		name:      "filteradd without bloom service",
+		msg:       wire.NewMsgFilterAdd([]byte{0x01}),
+		wantScore: 100,
+	}}
+
+	// The loggers can not be used without a log rotator, so disable them.
+	setLogLevels("off")
+	defer setLogLevels(defaultLogLevel)
+	defer func(origCfg *config) {
+		cfg = origCfg
+	}(cfg)
+
+	for _, test := range tests {
+		threshold := uint32(defaultBanThreshold)
+		if test.threshold != 0 {
+			threshold = test.threshold
+		}
+		cfg = &config{BanThreshold: threshold}
+		s := &server{
+			chainParams:     &chaincfg.SimNetParams,
+			services:        wire.SFNodeNetwork,
+			banPeers:        make(chan *serverPeer, 1),
+			bytesSentPerMsg: make(map[string]uint64),
+			bytesRecvPerMsg: make(map[string]uint64),
+			uploadTarget:    newUploadTarget(0, &chaincfg.SimNetParams),
+		}
+		sp, sim := newSimServerPeer(t, s, test.whitelisted)
+
+		var err error
+		if test.msg != nil {
+			err = sim.writeMessage(test.msg)
+		} else {
+			err = sim.writeRawMessage(test.command, test.payload)
+		}
+		if err != nil {
+			t.Errorf("%s: failed to send message: %v", test.name, err)
+			sp.Disconnect()
+			continue
+		}
+
+		// The peer must be disconnected regardless of the score.
+		disconnected := make(chan struct{})
+		go func() {
+			sp.WaitForDisconnect()
+			close(disconnected)
+		}()
+		select {
+		case <-disconnected:
+		case <-time.After(time.Second * 5):
+			t.Errorf("%s: peer was not disconnected", test.name)
+			sp.Disconnect()
+			continue
+		}
+
+		if score := sp.banScore.Int(); score != test.wantScore {
+			t.Errorf("%s: unexpected ban score -- got %d, want %d",
+				test.name, score, test.wantScore)
+		}
+		var banned bool
+		select {
+		case <-s.banPeers:
+			banned = true
+		default:
+		}
+		if banned != test.wantBanned {
+			t.Errorf("%s: unexpected ban -- got %v, want %v",
+				test.name, banned, test.wantBanned)
+		}
+		sim.conn.Close()
+	}
+}
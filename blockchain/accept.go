@@ -98,29 +98,39 @@ func IsFinalizedTransaction(tx *hcutil.Tx, blockHeight int64, blockTime time.Tim
 }
 
 // checkBlockContext peforms several validation checks on the block which depend
-// on its position within the block chain.
+// on its position within the block chain.  The block header is expected to
+// have already passed checkBlockHeaderContext, which is done when it is added
+// to the block index, so only the checks which require the data of the
+// previous blocks are performed.
 //
 // The flags modify the behavior of this function as follows:
 //  - BFFastAdd: The transaction are not checked to see if they are finalized
 //    and the somewhat expensive duplication transaction check is not performed.
-//
-// The flags are also passed to checkBlockHeaderContext.  See its documentation
-// for how the flags modify its behavior.
 func (b *BlockChain) checkBlockContext(block *hcutil.Block, prevNode *blockNode, flags BehaviorFlags) error {
 	// The genesis block is valid by definition.
 	if prevNode == nil {
 		return nil
 	}
 
-	// Perform all block header related validation checks.
 	header := &block.MsgBlock().Header
-	err := b.checkBlockHeaderContext(header, prevNode, flags)
-	if err != nil {
-		return err
-	}
-
 	fastAdd := flags&BFFastAdd == BFFastAdd
 	if !fastAdd {
+		// Enforce the stake version in the header once a majority of
+		// the network has upgraded to version 3 blocks.  This is not
+		// part of the header checks since the stake version is
+		// calculated from the votes in the previous blocks.
+		if header.Version >= 3 && b.isMajorityVersion(3, prevNode,
+			b.chainParams.BlockEnforceNumRequired) {
+
+			expectedStakeVer := b.calcStakeVersion(prevNode)
+			if header.StakeVersion != expectedStakeVer {
+				str := fmt.Sprintf("block stake version of %d "+
+					"is not the expected version of %d",
+					header.StakeVersion, expectedStakeVer)
+				return ruleError(ErrBadStakeVersion, str)
+			}
+		}
+
 		// A block must not exceed the maximum allowed size as defined
 		// by the network parameters and the current status of any hard
 		// fork votes to change it when serialized.
@@ -164,14 +174,6 @@ func (b *BlockChain) checkBlockContext(block *hcutil.Block, prevNode *blockNode,
 			}
 		}
 
-		// Check that the node is at the correct height in the blockchain,
-		// as specified in the block header.
-		if blockHeight != int64(block.MsgBlock().Header.Height) {
-			errStr := fmt.Sprintf("Block header height invalid; expected %v"+
-				" but %v was found", blockHeight, header.Height)
-			return ruleError(ErrBadBlockHeight, errStr)
-		}
-
 		// Check that the coinbase contains at minimum the block
 		// height in output 1.
 		if blockHeight > 1 {
@@ -186,9 +188,10 @@ func (b *BlockChain) checkBlockContext(block *hcutil.Block, prevNode *blockNode,
 }
 
 // ticketsSpentInBlock fetches a list of tickets that were spent in the
-// block.
+// block.  The list is never nil, so it can be distinguished from the ticket
+// information of block nodes which is not loaded.
 func ticketsSpentInBlock(bl *hcutil.Block) []chainhash.Hash {
-	var tickets []chainhash.Hash
+	tickets := []chainhash.Hash{}
 	for _, stx := range bl.MsgBlock().STransactions {
 		if stake.DetermineTxType(stx) == stake.TxTypeSSGen {
 			tickets = append(tickets, stx.TxIn[1].PreviousOutPoint.Hash)
@@ -199,9 +202,10 @@ func ticketsSpentInBlock(bl *hcutil.Block) []chainhash.Hash {
 }
 
 // ticketsRevokedInBlock fetches a list of tickets that were revoked in the
-// block.
+// block.  The list is never nil, so it can be distinguished from the ticket
+// information of block nodes which is not loaded.
 func ticketsRevokedInBlock(bl *hcutil.Block) []chainhash.Hash {
-	var tickets []chainhash.Hash
+	tickets := []chainhash.Hash{}
 	for _, stx := range bl.MsgBlock().STransactions {
 		if stake.DetermineTxType(stx) == stake.TxTypeSSRtx {
 			tickets = append(tickets, stx.TxIn[0].PreviousOutPoint.Hash)
@@ -234,6 +238,9 @@ func voteBitsInBlock(bl *hcutil.Block) []VoteVersionTuple {
 // before adding it.  The block is expected to have already gone through
 // ProcessBlock before calling this function with it.
 //
+// The header of the block is accepted into the block index first when it is not
+// already known, so the block node created for it is used.
+//
 // The flags modify the behavior of this function as follows:
 //  - BFDryRun: The memory chain index will not be pruned and no accept
 //    notification will be sent since the block is not being accepted.
@@ -242,39 +249,38 @@ func voteBitsInBlock(bl *hcutil.Block) []VoteVersionTuple {
 func (b *BlockChain) maybeAcceptBlock(block *hcutil.Block, flags BehaviorFlags) (bool, error) {
 	dryRun := flags&BFDryRun == BFDryRun
 
-	// Get a block node for the block previous to this one.  Will be nil
-	// if this is the genesis block.
-	prevNode, err := b.getPrevNodeFromBlock(block)
+	// The header of the block must be valid, so accept it, or look up the
+	// existing node for it, before checking the block itself.
+	newNode, err := b.maybeAcceptBlockHeader(&block.MsgBlock().Header, flags)
 	if err != nil {
-		log.Debugf("getPrevNodeFromBlock: %v", err)
 		return false, err
 	}
 
-	blockHeight := block.Height()
-
 	// The block must pass all of the validation rules which depend on the
-	// position of the block within the block chain.
-	err = b.checkBlockContext(block, prevNode, flags)
+	// position of the block within the block chain.  Blocks which violate
+	// them are marked invalid along with their descendants.  Note that
+	// failures of the sanity checks in ProcessBlock do not mark the block
+	// invalid since the header could be valid while the transactions were
+	// malleated.
+	err = b.checkBlockContext(block, newNode.parent, flags)
 	if err != nil {
+		if _, ok := err.(RuleError); ok && !dryRun {
+			b.markValidateFailed(newNode)
+		}
 		return false, err
 	}
 
-	// Prune stake nodes which are no longer needed before creating a new
-	// node.
+	// Prune stake nodes which are no longer needed before filling in the
+	// stake information of the node.
 	if !dryRun {
 		b.pruner.pruneChainIfNeeded()
 	}
 
-	// Create a new block node for the block and add it to the in-memory
-	// block chain (could be either a side chain or the main chain).
-	blockHeader := &block.MsgBlock().Header
-	newNode := newBlockNode(blockHeader, ticketsSpentInBlock(block),
-		ticketsRevokedInBlock(block), voteBitsInBlock(block))
-	if prevNode != nil {
-		newNode.parent = prevNode
-		newNode.height = blockHeight
-		newNode.workSum.Add(prevNode.workSum, newNode.workSum)
-	}
+	// The node of a block which was accepted as a header first does not
+	// have the stake information of the block yet.
+	newNode.ticketsSpent = ticketsSpentInBlock(block)
+	newNode.ticketsRevoked = ticketsRevokedInBlock(block)
+	newNode.votes = voteBitsInBlock(block)
 
 	// Fetching a stake node could enable a new DoS vector, so restrict
 	// this only to blocks that are recent in history.
@@ -0,0 +1,59 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"math/big"
+	"time"
+)
+
+// assumeValidBurialTime is the minimum amount of time worth of proof of work,
+// at the difficulty of a block, which has to be built on top of the block
+// before script validation is skipped for it due to being an ancestor of the
+// block assumed to be valid.  This prevents skipping the scripts of a chain
+// which does not have much work behind it, such as one that was crafted to
+// contain the block assumed to be valid with invalid ancestors.
+const assumeValidBurialTime = time.Hour * 24 * 14
+
+// isAssumedValid returns whether or not the scripts of the block represented
+// by the passed node are assumed to be valid, which is the case when the node
+// is an ancestor of the block assumed to be valid, or that block itself, and
+// the node is buried under at least assumeValidBurialTime worth of proof of
+// work.  Note that this only applies to the scripts, so all of the other
+// consensus rules must still be enforced for the block.
+//
+// The block assumed to be valid must be in the block index for any block to be
+// assumed valid, so the result is always false when it is not known yet.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) isAssumedValid(node *blockNode) bool {
+	if b.assumeValid == *zeroHash {
+		return false
+	}
+
+	assumeValidNode := b.index[b.assumeValid]
+	if assumeValidNode == nil || assumeValidNode.status.KnownInvalid() {
+		return false
+	}
+	if assumeValidNode.Ancestor(node.height) != node {
+		return false
+	}
+
+	// Measure the burial of the node against the tip with the most work
+	// known which builds on the node.  The best header is used since the
+	// blocks of the header chain are typically not all processed yet.
+	tip := assumeValidNode
+	if b.bestHeader != nil && b.bestHeader.workSum.Cmp(tip.workSum) > 0 &&
+		b.bestHeader.Ancestor(node.height) == node {
+
+		tip = b.bestHeader
+	}
+	burialBlocks := int64(assumeValidBurialTime /
+		b.chainParams.TargetTimePerBlock)
+	minBurialWork := new(big.Int).Mul(CalcWork(node.header.Bits),
+		big.NewInt(burialBlocks))
+	burialWork := new(big.Int).Sub(tip.workSum, node.workSum)
+	return burialWork.Cmp(minBurialWork) >= 0
+}
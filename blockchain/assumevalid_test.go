@@ -0,0 +1,134 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+)
+
+// TestIsAssumedValid ensures only the ancestors of the block assumed to be
+// valid which are buried under enough work are assumed to be valid.
+func TestIsAssumedValid(t *testing.T) {
+	// Use a target time per block which requires a burial of ten blocks at
+	// a constant difficulty.
+	params := chaincfg.SimNetParams
+	params.TargetTimePerBlock = assumeValidBurialTime / 10
+	bc := newFakeChain(&params)
+
+	// Create a main chain of 40 blocks with a side chain forking from it at
+	// height 5.
+	nodes := []*blockNode{bc.bestNode}
+	timestamp := time.Unix(params.GenesisBlock.Header.Timestamp.Unix(), 0)
+	for i := 0; i < 40; i++ {
+		timestamp = timestamp.Add(time.Second)
+		node := newFakeNode(nodes[len(nodes)-1], 1, 1,
+			params.PowLimitBits, timestamp)
+		bc.index[node.hash] = node
+		nodes = append(nodes, node)
+	}
+	sideNode := nodes[5]
+	for i := 0; i < 10; i++ {
+		sideNode = newFakeNode(sideNode, 1, 2, params.PowLimitBits,
+			timestamp)
+		bc.index[sideNode.hash] = sideNode
+	}
+	unknownNode := newFakeNode(nodes[40], 1, 1, params.PowLimitBits,
+		timestamp.Add(time.Second))
+
+	tests := []struct {
+		name        string
+		assumeValid chainhash.Hash
+		best        *blockNode
+		invalid     bool
+		node        *blockNode
+		want        bool
+	}{{
+		name:        "disabled",
+		assumeValid: chainhash.Hash{},
+		best:        nodes[0],
+		node:        nodes[1],
+		want:        false,
+	}, {
+		name:        "unknown assumed valid block",
+		assumeValid: unknownNode.hash,
+		best:        nodes[0],
+		node:        nodes[1],
+		want:        false,
+	}, {
+		name:        "deeply buried ancestor",
+		assumeValid: nodes[30].hash,
+		best:        nodes[0],
+		node:        nodes[10],
+		want:        true,
+	}, {
+		name:        "exactly buried ancestor",
+		assumeValid: nodes[30].hash,
+		best:        nodes[0],
+		node:        nodes[20],
+		want:        true,
+	}, {
+		name:        "insufficiently buried ancestor",
+		assumeValid: nodes[30].hash,
+		best:        nodes[0],
+		node:        nodes[25],
+		want:        false,
+	}, {
+		name:        "ancestor buried by best chain",
+		assumeValid: nodes[30].hash,
+		best:        nodes[40],
+		node:        nodes[25],
+		want:        true,
+	}, {
+		name:        "assumed valid block buried by best chain",
+		assumeValid: nodes[30].hash,
+		best:        nodes[40],
+		node:        nodes[30],
+		want:        true,
+	}, {
+		name:        "descendant of assumed valid block",
+		assumeValid: nodes[30].hash,
+		best:        nodes[40],
+		node:        nodes[31],
+		want:        false,
+	}, {
+		name:        "buried by side chain",
+		assumeValid: nodes[30].hash,
+		best:        sideNode,
+		node:        nodes[25],
+		want:        false,
+	}, {
+		name:        "side chain block",
+		assumeValid: nodes[30].hash,
+		best:        nodes[40],
+		node:        sideNode.Ancestor(10),
+		want:        false,
+	}, {
+		name:        "known invalid assumed valid block",
+		assumeValid: nodes[30].hash,
+		best:        nodes[40],
+		invalid:     true,
+		node:        nodes[10],
+		want:        false,
+	}}
+
+	for _, test := range tests {
+		bc.assumeValid = test.assumeValid
+		bc.bestHeader = test.best
+		nodes[30].status = statusNone
+		if test.invalid {
+			nodes[30].status = statusInvalid
+		}
+
+		got := bc.isAssumedValid(test.node)
+		if got != test.want {
+			t.Errorf("%s: unexpected result -- got %v, want %v",
+				test.name, got, test.want)
+		}
+	}
+}
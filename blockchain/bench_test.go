@@ -6,5 +6,38 @@
 
 package blockchain_test
 
+import (
+	"testing"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/txscript"
+	"github.com/coolsnady/hcutil"
+)
+
 // TODO Make benchmarking tests for various functions, such as sidechain
 // evaluation.
+
+// benchmarkCheckBlockScripts benchmarks validating the scripts of a block
+// spending 1000 outputs paying to secp256k1 Schnorr public keys with and
+// without verifying the signatures in batches.
+func benchmarkCheckBlockScripts(b *testing.B, batch bool) {
+	msgBlock, view, _, _ := schnorrSpendBlock(b, 1000)
+	flags := txscript.ScriptBip16 | txscript.ScriptVerifyCleanStack
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := blockchain.TstCheckBlockScripts(hcutil.NewBlock(msgBlock),
+			view, true, flags, nil, batch)
+		if err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkCheckBlockScripts(b *testing.B) {
+	benchmarkCheckBlockScripts(b, false)
+}
+
+func BenchmarkCheckBlockScriptsBatched(b *testing.B) {
+	benchmarkCheckBlockScripts(b, true)
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/database"
+)
+
+// blockStatus is a bit field representing the validation state of a block.
+type blockStatus byte
+
+const (
+	// statusDataStored indicates that the block's data is stored in the
+	// database.
+	statusDataStored blockStatus = 1 << iota
+
+	// statusValidated indicates that the block has been fully validated
+	// and connected to the main chain at some point.
+	statusValidated
+
+	// statusInvalid indicates that the block failed validation or builds
+	// on a block which did.
+	statusInvalid
+
+	// statusNone indicates that the block has no validation state flags
+	// set.
+	statusNone blockStatus = 0
+)
+
+// HaveData returns whether or not the full block data is stored in the
+// database.  This will return false for blocks which are only kept in the
+// side chain block cache.
+func (status blockStatus) HaveData() bool {
+	return status&statusDataStored != 0
+}
+
+// KnownValid returns whether or not the block is known to be valid.  This will
+// return false for blocks which have not been fully validated yet.
+func (status blockStatus) KnownValid() bool {
+	return status&statusValidated != 0
+}
+
+// KnownInvalid returns whether or not the block is known to be invalid, either
+// because it failed validation or because it builds on a block which did.
+func (status blockStatus) KnownInvalid() bool {
+	return status&statusInvalid != 0
+}
+
+// invertLowestOne turns the lowest 1 bit in the binary representation of the
+// passed number into a 0.
+func invertLowestOne(n int64) int64 {
+	return n & (n - 1)
+}
+
+// calcSkipListHeight calculates the height of the ancestor the skip pointer of
+// the block node at the passed height refers to.  The heights are chosen such
+// that any ancestor can be reached by following a logarithmic number of skip
+// and parent pointers.
+func calcSkipListHeight(height int64) int64 {
+	if height < 2 {
+		return 0
+	}
+
+	// Determine which height to jump back to.  Any number strictly lower
+	// than height is acceptable, but the following expression performs
+	// well in simulations (max 110 steps to go back up to 2**18 blocks).
+	if height&1 == 0 {
+		return invertLowestOne(height)
+	}
+	return invertLowestOne(invertLowestOne(height-1)) + 1
+}
+
+// buildSkipList sets the skip pointer of the node to the appropriate ancestor.
+// The node must be linked to its parent before calling this function.
+func (node *blockNode) buildSkipList() {
+	if node.parent != nil {
+		node.skipToAncestor = node.parent.Ancestor(
+			calcSkipListHeight(node.height))
+	}
+}
+
+// Ancestor returns the ancestor block node at the provided height by following
+// the skip and parent pointers backwards from the node.  The returned block
+// will be nil when a height is requested that is after the height of the node
+// or is less than zero.
+//
+// Nodes without a skip pointer, such as the ones created for tests, are
+// handled by following the parent pointer instead.
+func (node *blockNode) Ancestor(height int64) *blockNode {
+	if height < 0 || height > node.height {
+		return nil
+	}
+
+	n := node
+	for n != nil && n.height != height {
+		// Follow the skip pointer unless it jumps past the requested
+		// height or the skip pointer of the parent leads closer to it.
+		skipHeight := calcSkipListHeight(n.height)
+		prevSkipHeight := calcSkipListHeight(n.height - 1)
+		if n.skipToAncestor != nil && (skipHeight == height ||
+			(skipHeight > height && !(prevSkipHeight < skipHeight-2 &&
+				prevSkipHeight >= height))) {
+
+			n = n.skipToAncestor
+		} else {
+			n = n.parent
+		}
+	}
+	return n
+}
+
+// RelativeAncestor returns the ancestor block node the provided distance
+// backwards from the node.  The returned block will be nil when the distance
+// is negative or exceeds the height of the node.
+func (node *blockNode) RelativeAncestor(distance int64) *blockNode {
+	return node.Ancestor(node.height - distance)
+}
+
+// haveBlockData returns whether or not the data of the block represented by the
+// passed node is available, meaning it is either stored in the database or in
+// the side chain block cache.  Nodes which were only created from a block
+// header do not have their data available until the block is received.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) haveBlockData(node *blockNode) bool {
+	if node.status.HaveData() {
+		return true
+	}
+
+	b.blockCacheLock.RLock()
+	_, ok := b.blockCache[node.hash]
+	b.blockCacheLock.RUnlock()
+	return ok
+}
+
+// childrenWithData returns the hashes of the children of the passed node the
+// block data of which is available.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) childrenWithData(node *blockNode) []chainhash.Hash {
+	hashes := make([]chainhash.Hash, 0, len(node.children))
+	for _, child := range node.children {
+		if b.haveBlockData(child) {
+			hashes = append(hashes, child.hash)
+		}
+	}
+	return hashes
+}
+
+// setStatusFlags sets the provided status flags of the passed node and updates
+// the block index entry of the node in the database accordingly.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) setStatusFlags(node *blockNode, flags blockStatus) error {
+	if node.status&flags == flags {
+		return nil
+	}
+
+	node.status |= flags
+	return b.db.Update(func(dbTx database.Tx) error {
+		return dbPutBlockNode(dbTx, node)
+	})
+}
+
+// markValidateFailed marks the passed node, which failed validation, along
+// with all of its descendants in the memory block index as invalid so none of
+// them is considered for the main chain again.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) markValidateFailed(node *blockNode) {
+	for _, n := range b.knownDescendants(node) {
+		if err := b.setStatusFlags(n, statusInvalid); err != nil {
+			log.Warnf("Unable to mark block %v invalid: %v", n.hash,
+				err)
+		}
+	}
+	b.bestHeader = b.findBestHeader()
+}
+
+// findBestHeader returns the node with the most cumulative work in the block
+// index which is not marked invalid.  The end of the main chain is returned
+// when no node has more work.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) findBestHeader() *blockNode {
+	best := b.bestNode
+	for _, n := range b.index {
+		if n.workSum.Cmp(best.workSum) > 0 && !b.isInvalidated(n) {
+			best = n
+		}
+	}
+	return best
+}
+
+// maybeFetchTicketInfo loads the tickets spent and revoked by the block of the
+// passed node from the block when they are not already loaded, which is
+// indicated by both being nil.  They are not part of the block index and are
+// pruned from nodes which are unlikely to be needed again, so they are only
+// loaded when a stake node has to be connected.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) maybeFetchTicketInfo(node *blockNode) error {
+	if node.ticketsSpent != nil || node.ticketsRevoked != nil {
+		return nil
+	}
+
+	block, err := b.fetchBlockByHash(&node.hash)
+	if err != nil {
+		return err
+	}
+
+	node.ticketsSpent = ticketsSpentInBlock(block)
+	node.ticketsRevoked = ticketsRevokedInBlock(block)
+	return nil
+}
@@ -0,0 +1,284 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcutil"
+)
+
+// TestCalcSkipListHeight ensures the skip list heights are calculated as
+// expected and always refer to a lower height.
+func TestCalcSkipListHeight(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		height int64
+		want   int64
+	}{
+		{height: 0, want: 0},
+		{height: 1, want: 0},
+		{height: 2, want: 0},
+		{height: 3, want: 1},
+		{height: 4, want: 0},
+		{height: 6, want: 4},
+		{height: 7, want: 1},
+		{height: 12, want: 8},
+		{height: 100, want: 96},
+		{height: 101, want: 65},
+	}
+
+	for _, test := range tests {
+		got := calcSkipListHeight(test.height)
+		if got != test.want {
+			t.Errorf("calcSkipListHeight(%d): got %d, want %d",
+				test.height, got, test.want)
+		}
+	}
+
+	for height := int64(1); height < 100000; height++ {
+		if got := calcSkipListHeight(height); got >= height {
+			t.Fatalf("calcSkipListHeight(%d): got %d which is not "+
+				"lower than the height", height, got)
+		}
+	}
+}
+
+// TestAncestor ensures the ancestors found by following the skip list match
+// the ones found by following the parents.
+func TestAncestor(t *testing.T) {
+	t.Parallel()
+
+	// Create a main chain along with a side chain which forks from it.
+	params := &chaincfg.SimNetParams
+	bc := newFakeChain(params)
+	nodes := []*blockNode{bc.bestNode}
+	for i := 1; i <= 5000; i++ {
+		node := newFakeNode(nodes[i-1], 1, 1, 0, time.Now())
+		nodes = append(nodes, node)
+	}
+	sideTip := nodes[3000]
+	for i := 0; i < 1000; i++ {
+		sideTip = newFakeNode(sideTip, 2, 1, 0, time.Now())
+	}
+
+	// linearAncestor returns the ancestor of the node at the passed height
+	// by following the parents.
+	linearAncestor := func(node *blockNode, height int64) *blockNode {
+		for node != nil && node.height > height {
+			node = node.parent
+		}
+		return node
+	}
+
+	tips := []*blockNode{nodes[len(nodes)-1], sideTip, nodes[1234]}
+	for _, tip := range tips {
+		for height := int64(0); height <= tip.height; height += 7 {
+			got := tip.Ancestor(height)
+			want := linearAncestor(tip, height)
+			if got != want {
+				t.Fatalf("Ancestor(%d) of node at height %d: got "+
+					"node at height %d, want node at height %d",
+					height, tip.height, got.height, want.height)
+			}
+		}
+	}
+
+	// Ensure the relative ancestors are found.
+	tip := nodes[len(nodes)-1]
+	if got := tip.RelativeAncestor(4000); got != nodes[1000] {
+		t.Fatalf("RelativeAncestor(4000): got node at height %d, want "+
+			"height 1000", got.height)
+	}
+
+	// Ensure heights outside of the valid range return nil.
+	if got := tip.Ancestor(tip.height + 1); got != nil {
+		t.Fatalf("Ancestor(%d): got node at height %d, want nil",
+			tip.height+1, got.height)
+	}
+	if got := tip.Ancestor(-1); got != nil {
+		t.Fatalf("Ancestor(-1): got node at height %d, want nil",
+			got.height)
+	}
+	if got := tip.RelativeAncestor(tip.height + 1); got != nil {
+		t.Fatalf("RelativeAncestor(%d): got node at height %d, want nil",
+			tip.height+1, got.height)
+	}
+}
+
+// TestBlockIndexEntrySerialization ensures serializing and deserializing block
+// index entries works as expected.
+func TestBlockIndexEntrySerialization(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.SimNetParams
+	genesis := newBlockNode(&params.GenesisBlock.Header, nil, nil, nil)
+	withVotes := newFakeNode(genesis, 3, 2, 0x207fffff, time.Unix(1500000000, 0))
+	appendFakeVotes(withVotes, 3, 2, 0x01)
+	appendFakeVotes(withVotes, 2, 3, 0x03)
+
+	tests := []struct {
+		name   string
+		node   *blockNode
+		status blockStatus
+	}{
+		{
+			name:   "genesis without votes",
+			node:   genesis,
+			status: statusDataStored | statusValidated,
+		},
+		{
+			name:   "block with votes",
+			node:   withVotes,
+			status: statusDataStored | statusInvalid,
+		},
+	}
+
+	for _, test := range tests {
+		test.node.status = test.status
+		serialized, err := serializeBlockIndexEntry(test.node)
+		if err != nil {
+			t.Errorf("serializeBlockIndexEntry (%s): unexpected "+
+				"error: %v", test.name, err)
+			continue
+		}
+		if len(serialized) != blockIndexEntrySerializeSize(test.node) {
+			t.Errorf("serializeBlockIndexEntry (%s): got %d bytes, "+
+				"want %d", test.name, len(serialized),
+				blockIndexEntrySerializeSize(test.node))
+			continue
+		}
+
+		node, err := deserializeBlockIndexEntry(&test.node.hash,
+			serialized)
+		if err != nil {
+			t.Errorf("deserializeBlockIndexEntry (%s): unexpected "+
+				"error: %v", test.name, err)
+			continue
+		}
+		if node.hash != test.node.hash || node.height != test.node.height ||
+			node.status != test.status {
+			t.Errorf("deserializeBlockIndexEntry (%s): got hash %v "+
+				"height %d status %d, want hash %v height %d "+
+				"status %d", test.name, node.hash, node.height,
+				node.status, test.node.hash, test.node.height,
+				test.status)
+			continue
+		}
+		if !reflect.DeepEqual(node.header, test.node.header) {
+			t.Errorf("deserializeBlockIndexEntry (%s): mismatched "+
+				"header - got %v, want %v", test.name,
+				node.header, test.node.header)
+			continue
+		}
+		if !reflect.DeepEqual(node.votes, test.node.votes) {
+			t.Errorf("deserializeBlockIndexEntry (%s): mismatched "+
+				"votes - got %v, want %v", test.name, node.votes,
+				test.node.votes)
+			continue
+		}
+		if node.header.BlockHash() != test.node.hash {
+			t.Errorf("deserializeBlockIndexEntry (%s): header does "+
+				"not hash to the block hash", test.name)
+			continue
+		}
+
+		// Ensure truncated entries are rejected.
+		_, err = deserializeBlockIndexEntry(&test.node.hash,
+			serialized[:len(serialized)-1])
+		if !isDeserializeErr(err) {
+			t.Errorf("deserializeBlockIndexEntry (%s): truncated "+
+				"entry returned unexpected error %v", test.name, err)
+			continue
+		}
+	}
+}
+
+// TestNextNeededBlocks ensures the best header is determined as expected and
+// the blocks of its chain which are not available are returned in order.
+func TestNextNeededBlocks(t *testing.T) {
+	t.Parallel()
+
+	// Create a main chain of 10 blocks along with a header chain which
+	// forks from it at height 6 and has more work.  The block of the first
+	// header after the fork is available in the side chain block cache.
+	params := &chaincfg.SimNetParams
+	bc := newFakeChain(params)
+	bc.blockCache = make(map[chainhash.Hash]*hcutil.Block)
+	nodes := []*blockNode{bc.bestNode}
+	for i := 1; i <= 10; i++ {
+		node := newFakeNode(nodes[i-1], 1, 1, params.PowLimitBits,
+			time.Now())
+		node.inMainChain = true
+		node.status = statusDataStored | statusValidated
+		bc.index[node.hash] = node
+		nodes = append(nodes, node)
+	}
+	bc.bestNode = nodes[10]
+	headers := []*blockNode{nodes[6]}
+	for i := 1; i <= 14; i++ {
+		node := newFakeNode(headers[i-1], 2, 1, params.PowLimitBits,
+			time.Now())
+		bc.index[node.hash] = node
+		headers = append(headers, node)
+	}
+	bc.blockCache[headers[1].hash] = nil
+
+	// hashesOf returns the hashes of the passed nodes.
+	hashesOf := func(nodes []*blockNode) []*chainhash.Hash {
+		hashes := make([]*chainhash.Hash, 0, len(nodes))
+		for _, node := range nodes {
+			hashes = append(hashes, &node.hash)
+		}
+		return hashes
+	}
+
+	bc.bestHeader = bc.findBestHeader()
+	if bc.bestHeader != headers[14] {
+		t.Fatalf("findBestHeader: got node at height %d, want height %d",
+			bc.bestHeader.height, headers[14].height)
+	}
+	got := bc.NextNeededBlocks(5)
+	if want := hashesOf(headers[2:7]); !reflect.DeepEqual(got, want) {
+		t.Fatalf("NextNeededBlocks(5): got %v, want %v", got, want)
+	}
+	got = bc.NextNeededBlocks(100)
+	if want := hashesOf(headers[2:]); !reflect.DeepEqual(got, want) {
+		t.Fatalf("NextNeededBlocks(100): got %v, want %v", got, want)
+	}
+
+	// Mark a header and its descendants invalid and ensure the best header
+	// is the last valid header of the chain.
+	for _, node := range headers[9:] {
+		node.status |= statusInvalid
+	}
+	bc.bestHeader = bc.findBestHeader()
+	if bc.bestHeader != headers[8] {
+		t.Fatalf("findBestHeader: got node at height %d, want height %d",
+			bc.bestHeader.height, headers[8].height)
+	}
+	got = bc.NextNeededBlocks(100)
+	if want := hashesOf(headers[2:9]); !reflect.DeepEqual(got, want) {
+		t.Fatalf("NextNeededBlocks(100): got %v, want %v", got, want)
+	}
+
+	// Ensure no blocks are needed when the main chain has the most work.
+	for _, node := range headers[5:] {
+		node.status |= statusInvalid
+	}
+	bc.bestHeader = bc.findBestHeader()
+	if bc.bestHeader != bc.bestNode {
+		t.Fatalf("findBestHeader: got node at height %d, want height %d",
+			bc.bestHeader.height, bc.bestNode.height)
+	}
+	if got := bc.NextNeededBlocks(100); len(got) != 0 {
+		t.Fatalf("NextNeededBlocks(100): got %v, want none", got)
+	}
+}
@@ -114,16 +114,12 @@ func (b *BlockChain) blockLocatorFromHash(hash *chainhash.Hash) BlockLocator {
 			// backwards along the side chain nodes to each block
 			// height.
 			if forkHeight != -1 && blockHeight > forkHeight {
-				// Intentionally use parent field instead of the
-				// getPrevNodeFromNode function since we don't
-				// want to dynamically load nodes when building
-				// block locators.  Side chain blocks should
-				// always be in memory already, and if they
-				// aren't for some reason it's ok to skip them.
-				for iterNode != nil && blockHeight > iterNode.height {
-					iterNode = iterNode.parent
-				}
-				if iterNode != nil && iterNode.height == blockHeight {
+				// Side chain blocks, including those of header
+				// chains which are ahead of the main chain, are
+				// always in memory, so follow the skip list of
+				// the node.
+				iterNode = iterNode.Ancestor(blockHeight)
+				if iterNode != nil {
 					locator = append(locator, &iterNode.hash)
 				}
 				continue
@@ -180,3 +176,16 @@ func (b *BlockChain) LatestBlockLocator() (BlockLocator, error) {
 	b.chainLock.RUnlock()
 	return locator, nil
 }
+
+// LatestHeaderLocator returns a block locator for the best known header, which
+// is the end of the header chain with the most cumulative work that is not
+// known to be invalid.  It is used to request the headers which build on it
+// from other peers.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) LatestHeaderLocator() (BlockLocator, error) {
+	b.chainLock.RLock()
+	locator := b.blockLocatorFromHash(&b.bestHeader.hash)
+	b.chainLock.RUnlock()
+	return locator, nil
+}
@@ -24,15 +24,11 @@ import (
 )
 
 const (
-	// maxOrphanBlocks is the maximum number of orphan blocks that can be
-	// queued.
-	maxOrphanBlocks = 500
-
 	// minMemoryNodes is the minimum number of consecutive nodes needed
-	// in memory in order to perform all necessary validation.  It is used
-	// to determine when it's safe to prune nodes from memory without
-	// causing constant dynamic reloading.  This value should be larger than
-	// that for minMemoryStakeNodes.
+	// in memory with their stake data in order to perform all necessary
+	// validation.  It is used to determine when it's safe to prune the
+	// stake data of nodes without causing constant dynamic reloading.  This
+	// value should be larger than that for minMemoryStakeNodes.
 	minMemoryNodes = 2880
 
 	// minMemoryStakeNodes is the maximum height to keep stake nodes
@@ -45,10 +41,9 @@ const (
 	// keep in memory, by height from the tip of the mainchain.
 	mainchainBlockCacheSize = 12
 
-	// maxSearchDepth is the distance in block nodes to search down the
-	// blockchain to find some parent, loading block nodes from the
-	// database if necessary.  Reorganizations longer than this disance may
-	// fail.
+	// maxSearchDepth is the maximum distance in block nodes from the end
+	// of the main chain for blocks which are looked up for purposes such
+	// as generating lottery data.
 	maxSearchDepth = 2880
 )
 
@@ -71,6 +66,11 @@ type blockNode struct {
 	// is when the best chain selection algorithm is used.
 	children []*blockNode
 
+	// skipToAncestor is used to accelerate ancestor lookups.  It refers to
+	// an ancestor of the node at the height calculated by
+	// calcSkipListHeight.
+	skipToAncestor *blockNode
+
 	// hash is the double sha 256 of the block.
 	hash chainhash.Hash
 
@@ -89,6 +89,9 @@ type blockNode struct {
 	// header is the full block header.
 	header wire.BlockHeader
 
+	// status is a bitfield representing the validation state of the block.
+	status blockStatus
+
 	// stakeNode contains all the consensus information required for the
 	// staking system.  The node also caches information required to add or
 	// remove stake nodes, so that the stake node itself may be pruneable
@@ -125,14 +128,6 @@ func newBlockNode(blockHeader *wire.BlockHeader, ticketsSpent []chainhash.Hash,
 	return &node
 }
 
-// orphanBlock represents a block that we don't yet have the parent for.  It
-// is a normal block plus an expiration time to prevent caching the orphan
-// forever.
-type orphanBlock struct {
-	block      *hcutil.Block
-	expiration time.Time
-}
-
 // removeChildNode deletes node from the provided slice of child block
 // nodes.  It ensures the final pointer reference is set to nil to prevent
 // potential memory leaks.  The original slice is returned unmodified if node
@@ -193,7 +188,7 @@ func newBestState(node *blockNode, blockSize, numTxns, totalTxns uint64, medianT
 
 // BlockChain provides functions for working with the Hcd block chain.
 // It includes functionality such as rejecting duplicate blocks, ensuring blocks
-// follow all rules, orphan handling, checkpoint handling, and best chain
+// follow all rules, header chain handling, checkpoint handling, and best chain
 // selection with reorganization.
 type BlockChain struct {
 	// The following fields are set when the instance is created and can't
@@ -207,6 +202,8 @@ type BlockChain struct {
 	notifications       NotificationCallback
 	sigCache            *txscript.SigCache
 	indexManager        IndexManager
+	assumeValid         chainhash.Hash
+	batchSigVerify      bool
 
 	// subsidyCache is the cache that provides quick lookup of subsidy
 	// values.
@@ -221,18 +218,34 @@ type BlockChain struct {
 	noVerify      bool
 	noCheckpoints bool
 
-	// These fields are related to the memory block index.  They are
-	// protected by the chain lock.
+	// These fields are related to the memory block index.  The index
+	// contains a node for every block with its data stored in the database
+	// as well as for the side chain blocks in the block cache, which are
+	// linked to their parents all the way back to the genesis block.  They
+	// are protected by the chain lock.
 	bestNode *blockNode
 	index    map[chainhash.Hash]*blockNode
-	depNodes map[chainhash.Hash][]*blockNode
-
-	// These fields are related to handling of orphan blocks.  They are
-	// protected by a combination of the chain lock and the orphan lock.
-	orphanLock     sync.RWMutex
-	orphans        map[chainhash.Hash]*orphanBlock
-	prevOrphans    map[chainhash.Hash][]*orphanBlock
-	oldestOrphan   *orphanBlock
+
+	// invalidated houses the blocks which were manually marked invalid,
+	// either directly or because one of their ancestors was, mapped to the
+	// hash of their parent.  It mirrors the invalidated blocks stored in
+	// the database and is protected by the chain lock.
+	invalidated map[chainhash.Hash]chainhash.Hash
+
+	// forcedDeployments houses the deployments whose states were forced on
+	// the regression test network mapped to the state reported for them.
+	// It is protected by the chain lock.
+	forcedDeployments map[string]ThresholdStateTuple
+
+	// bestHeader is the node with the most cumulative work in the block
+	// index which is not known to be invalid.  The block data for the nodes
+	// of its chain which are not part of the main chain is downloaded in
+	// order to eventually make it the end of the main chain.  It is
+	// protected by the chain lock.
+	bestHeader *blockNode
+
+	// blockCache houses the side chain blocks which are not stored in the
+	// database.  It is protected by the block cache lock.
 	blockCacheLock sync.RWMutex
 	blockCache     map[chainhash.Hash]*hcutil.Block
 
@@ -427,150 +440,15 @@ func (b *BlockChain) FetchSubsidyCache() *SubsidyCache {
 
 // HaveBlock returns whether or not the chain instance has the block represented
 // by the passed hash.  This includes checking the various places a block can
-// be like part of the main chain, on a side chain, or in the orphan pool.
+// be like part of the main chain or on a side chain.  Blocks for which only the
+// header is known are not considered to be available.
 //
 // This function is safe for concurrent access.
 func (b *BlockChain) HaveBlock(hash *chainhash.Hash) (bool, error) {
 	b.chainLock.RLock()
 	exists, err := b.blockExists(hash)
 	b.chainLock.RUnlock()
-
-	if err != nil {
-		return false, err
-	}
-	return exists || b.IsKnownOrphan(hash), nil
-}
-
-// IsKnownOrphan returns whether the passed hash is currently a known orphan.
-// Keep in mind that only a limited number of orphans are held onto for a
-// limited amount of time, so this function must not be used as an absolute
-// way to test if a block is an orphan block.  A full block (as opposed to just
-// its hash) must be passed to ProcessBlock for that purpose.  However, calling
-// ProcessBlock with an orphan that already exists results in an error, so this
-// function provides a mechanism for a caller to intelligently detect *recent*
-// duplicate orphans and react accordingly.
-//
-// This function is safe for concurrent access.
-func (b *BlockChain) IsKnownOrphan(hash *chainhash.Hash) bool {
-	// Protect concurrent access.  Using a read lock only so multiple
-	// readers can query without blocking each other.
-	b.orphanLock.RLock()
-	_, exists := b.orphans[*hash]
-	b.orphanLock.RUnlock()
-
-	return exists
-}
-
-// GetOrphanRoot returns the head of the chain for the provided hash from the
-// map of orphan blocks.
-//
-// This function is safe for concurrent access.
-func (b *BlockChain) GetOrphanRoot(hash *chainhash.Hash) *chainhash.Hash {
-	// Protect concurrent access.  Using a read lock only so multiple
-	// readers can query without blocking each other.
-	b.orphanLock.RLock()
-	defer b.orphanLock.RUnlock()
-
-	// Keep looping while the parent of each orphaned block is
-	// known and is an orphan itself.
-	orphanRoot := hash
-	prevHash := hash
-	for {
-		orphan, exists := b.orphans[*prevHash]
-		if !exists {
-			break
-		}
-		orphanRoot = prevHash
-		prevHash = &orphan.block.MsgBlock().Header.PrevBlock
-	}
-
-	return orphanRoot
-}
-
-// removeOrphanBlock removes the passed orphan block from the orphan pool and
-// previous orphan index.
-func (b *BlockChain) removeOrphanBlock(orphan *orphanBlock) {
-	// Protect concurrent access.
-	b.orphanLock.Lock()
-	defer b.orphanLock.Unlock()
-
-	// Remove the orphan block from the orphan pool.
-	orphanHash := orphan.block.Hash()
-	delete(b.orphans, *orphanHash)
-
-	// Remove the reference from the previous orphan index too.  An indexing
-	// for loop is intentionally used over a range here as range does not
-	// reevaluate the slice on each iteration nor does it adjust the index
-	// for the modified slice.
-	prevHash := &orphan.block.MsgBlock().Header.PrevBlock
-	orphans := b.prevOrphans[*prevHash]
-	for i := 0; i < len(orphans); i++ {
-		hash := orphans[i].block.Hash()
-		if hash.IsEqual(orphanHash) {
-			copy(orphans[i:], orphans[i+1:])
-			orphans[len(orphans)-1] = nil
-			orphans = orphans[:len(orphans)-1]
-			i--
-		}
-	}
-	b.prevOrphans[*prevHash] = orphans
-
-	// Remove the map entry altogether if there are no longer any orphans
-	// which depend on the parent hash.
-	if len(b.prevOrphans[*prevHash]) == 0 {
-		delete(b.prevOrphans, *prevHash)
-	}
-}
-
-// addOrphanBlock adds the passed block (which is already determined to be
-// an orphan prior calling this function) to the orphan pool.  It lazily cleans
-// up any expired blocks so a separate cleanup poller doesn't need to be run.
-// It also imposes a maximum limit on the number of outstanding orphan
-// blocks and will remove the oldest received orphan block if the limit is
-// exceeded.
-func (b *BlockChain) addOrphanBlock(block *hcutil.Block) {
-	// Remove expired orphan blocks.
-	for _, oBlock := range b.orphans {
-		if time.Now().After(oBlock.expiration) {
-			b.removeOrphanBlock(oBlock)
-			continue
-		}
-
-		// Update the oldest orphan block pointer so it can be discarded
-		// in case the orphan pool fills up.
-		if b.oldestOrphan == nil ||
-			oBlock.expiration.Before(b.oldestOrphan.expiration) {
-			b.oldestOrphan = oBlock
-		}
-	}
-
-	// Limit orphan blocks to prevent memory exhaustion.
-	if len(b.orphans)+1 > maxOrphanBlocks {
-		// Remove the oldest orphan to make room for the new one.
-		b.removeOrphanBlock(b.oldestOrphan)
-		b.oldestOrphan = nil
-	}
-
-	// Protect concurrent access.  This is intentionally done here instead
-	// of near the top since removeOrphanBlock does its own locking and
-	// the range iterator is not invalidated by removing map entries.
-	b.orphanLock.Lock()
-	defer b.orphanLock.Unlock()
-
-	// Insert the block into the orphan map with an expiration time
-	// 1 hour from now.
-	expiration := time.Now().Add(time.Hour)
-	oBlock := &orphanBlock{
-		block:      block,
-		expiration: expiration,
-	}
-	b.orphans[*block.Hash()] = oBlock
-
-	// Add to previous hash lookup index for faster dependency lookups.
-	prevHash := &block.MsgBlock().Header.PrevBlock
-	b.prevOrphans[*prevHash] = append(b.prevOrphans[*prevHash], oBlock)
-
-	return
+	return exists, err
 }
 
 // tipGeneration returns the entire generation of blocks stemming from the
@@ -587,14 +465,9 @@ func (b *BlockChain) tipGeneration() ([]chainhash.Hash, error) {
 		return nil, fmt.Errorf("no need to get children of genesis block")
 	}
 
-	// Store all the hashes in a new slice and return them.
-	lenChildren := len(p.children)
-	allChildren := make([]chainhash.Hash, lenChildren)
-	for i := 0; i < lenChildren; i++ {
-		allChildren[i] = p.children[i].hash
-	}
-
-	return allChildren, nil
+	// Store the hashes of all children the block data of which is available
+	// in a new slice and return them.
+	return b.childrenWithData(p), nil
 }
 
 // TipGeneration returns the entire generation of blocks stemming from the
@@ -633,14 +506,9 @@ func (b *BlockChain) getGeneration(h chainhash.Hash) ([]chainhash.Hash, error) {
 		return nil, fmt.Errorf("no need to get children of genesis block")
 	}
 
-	// Store all the hashes in a new slice and return them.
-	lenChildren := len(p.children)
-	allChildren := make([]chainhash.Hash, lenChildren, lenChildren)
-	for i := 0; i < lenChildren; i++ {
-		allChildren[i] = p.children[i].hash
-	}
-
-	return allChildren, nil
+	// Store the hashes of all children the block data of which is available
+	// in a new slice and return them.
+	return b.childrenWithData(p), nil
 }
 
 // GetGeneration is the exported version of getGeneration.
@@ -648,131 +516,24 @@ func (b *BlockChain) GetGeneration(hash chainhash.Hash) ([]chainhash.Hash, error
 	return b.getGeneration(hash)
 }
 
-// loadBlockNode loads the block identified by hash from the block database,
-// creates a block node from it, and updates the memory block chain accordingly.
-// It is used mainly to dynamically load previous blocks from the database as
-// they are needed to avoid needing to put the entire block chain in memory.
+// findNode returns the node of the main chain block with the passed hash or
+// an error when the block is not part of the main chain.  If searchDepth is
+// not zero, an error is also returned when the block is more than searchDepth
+// blocks behind the end of the main chain.
 //
-// This function MUST be called with the chain state lock held (for writes).
-// The database transaction may be read-only.
-func (b *BlockChain) loadBlockNode(dbTx database.Tx, hash *chainhash.Hash) (*blockNode, error) {
-	block, err := dbFetchBlockByHash(dbTx, hash)
-	if err != nil {
-		return nil, err
-	}
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) findNode(nodeHash *chainhash.Hash, searchDepth int) (*blockNode, error) {
+	node, ok := b.index[*nodeHash]
+	if !ok || !node.inMainChain || (searchDepth != 0 &&
+		b.bestNode.height-node.height > int64(searchDepth)) {
 
-	blockHeader := block.MsgBlock().Header
-	node := newBlockNode(&blockHeader, ticketsSpentInBlock(block),
-		ticketsRevokedInBlock(block), voteBitsInBlock(block))
-	node.inMainChain = true
-	prevHash := &blockHeader.PrevBlock
-
-	// Add the node to the chain.
-	// There are a few possibilities here:
-	//  1) This node is a child of an existing block node
-	//  2) This node is the parent of one or more nodes
-	//  3) Neither 1 or 2 is true which implies it's an orphan block and
-	//     therefore is an error to insert into the chain
-	if parentNode, ok := b.index[*prevHash]; ok {
-		// Case 1 -- This node is a child of an existing block node.
-		// Update the node's work sum with the sum of the parent node's
-		// work sum and this node's work, append the node as a child of
-		// the parent node and set this node's parent to the parent
-		// node.
-		node.workSum = node.workSum.Add(parentNode.workSum, node.workSum)
-		parentNode.children = append(parentNode.children, node)
-		node.parent = parentNode
-	} else if childNodes, ok := b.depNodes[*hash]; ok {
-		// Case 2 -- This node is the parent of one or more nodes.
-		// Update the node's work sum by subtracting this node's work
-		// from the sum of its first child, and connect the node to all
-		// of its children.
-		node.workSum.Sub(childNodes[0].workSum, node.workSum)
-		for _, childNode := range childNodes {
-			childNode.parent = node
-			node.children = append(node.children, childNode)
-		}
-	} else {
-		// Case 3 -- The node doesn't have a parent in the node cache
-		// and is not the parent of another node.  This means an arbitrary
-		// orphan block is trying to be loaded which is not allowed.
-		// Before we return, check and make sure there isn't a parent
-		// further down the line in the blockchain to which the block
-		// could be attached, for example if the node had been pruned from
-		// the index.
-		foundParent, err := b.findNode(&node.header.PrevBlock, maxSearchDepth)
-		if err == nil {
-			node.workSum = node.workSum.Add(foundParent.workSum, node.workSum)
-			foundParent.children = append(foundParent.children, node)
-			node.parent = foundParent
-		} else {
-			str := "loadBlockNode: attempt to insert orphan block %v"
-			return nil, AssertError(fmt.Sprintf(str, hash))
-		}
+		return nil, fmt.Errorf("couldn't find node %v in best chain",
+			nodeHash)
 	}
 
-	// Add the new node to the indices for faster lookups.
-	b.index[*hash] = node
-	b.depNodes[*prevHash] = append(b.depNodes[*prevHash], node)
-
 	return node, nil
 }
 
-// findNode finds the node scaling backwards from best chain or return an
-// error.  If searchDepth equal zero there is no searchDepth.
-//
-// This function MUST be called with the chain state lock held (for writes).
-func (b *BlockChain) findNode(nodeHash *chainhash.Hash, searchDepth int) (*blockNode, error) {
-	var node *blockNode
-	err := b.db.View(func(dbTx database.Tx) error {
-		// Most common case; we're checking a block that wants to be connected
-		// on top of the current main chain.
-		distance := 0
-		if *nodeHash == b.bestNode.hash {
-			node = b.bestNode
-		} else {
-			// Look backwards in our blockchain and try to find it in the
-			// parents of blocks.
-			foundPrev := b.bestNode
-			notFound := true
-			for !foundPrev.hash.IsEqual(b.chainParams.GenesisHash) {
-				if searchDepth != 0 && distance >= searchDepth {
-					break
-				}
-
-				if foundPrev.hash.IsEqual(nodeHash) {
-					notFound = false
-					break
-				}
-
-				last := foundPrev.header.PrevBlock
-				foundPrev = foundPrev.parent
-				if foundPrev == nil {
-					parent, err := b.loadBlockNode(dbTx, &last)
-					if err != nil {
-						return err
-					}
-
-					foundPrev = parent
-				}
-
-				distance++
-			}
-
-			if notFound {
-				return fmt.Errorf("couldn't find node %v in best chain",
-					nodeHash)
-			}
-
-			node = foundPrev
-		}
-
-		return nil
-	})
-
-	return node, err
-}
-
 // fetchMainChainBlockByHash returns the block from the main chain with the
 // given hash.  It first attempts to use cache and then falls back to loading it
 // from the database.
@@ -811,14 +572,6 @@ func (b *BlockChain) fetchBlockByHash(hash *chainhash.Hash) (*hcutil.Block, erro
 		return block, nil
 	}
 
-	// Check orphan cache.
-	b.orphanLock.RLock()
-	orphan, existsOrphans := b.orphans[*hash]
-	b.orphanLock.RUnlock()
-	if existsOrphans {
-		return orphan.block, nil
-	}
-
 	// Check main chain cache.
 	b.mainchainBlockCacheLock.RLock()
 	block, ok := b.mainchainBlockCache[*hash]
@@ -857,98 +610,24 @@ func (b *BlockChain) FetchBlockByHash(hash *chainhash.Hash) (*hcutil.Block, erro
 	return b.fetchBlockByHash(hash)
 }
 
-// getPrevNodeFromBlock returns a block node for the block previous to the
-// passed block (the passed block's parent).  When it is already in the memory
-// block chain, it simply returns it.  Otherwise, it loads the previous block
-// header from the block database, creates a new block node from it, and returns
-// it.  The returned node will be nil if the genesis block is passed.
+// getPrevNodeFromNode returns the block node for the block previous to the
+// passed block node (the passed block node's parent).  Since the memory block
+// index contains all ancestors of every node, this is simply the parent of the
+// node.  The returned node will be nil if the genesis block is passed.
 //
-// This function MUST be called with the chain state lock held (for writes).
-func (b *BlockChain) getPrevNodeFromBlock(block *hcutil.Block) (*blockNode,
-	error) {
-	// Genesis block.
-	prevHash := &block.MsgBlock().Header.PrevBlock
-	if prevHash.IsEqual(zeroHash) {
-		return nil, nil
-	}
-
-	// Return the existing previous block node if it's already there.
-	if bn, ok := b.index[*prevHash]; ok {
-		return bn, nil
-	}
-
-	// Dynamically load the previous block from the block database, create
-	// a new block node for it, and update the memory chain accordingly.
-	var prevBlockNode *blockNode
-	err := b.db.View(func(dbTx database.Tx) error {
-		var err error
-		prevBlockNode, err = b.loadBlockNode(dbTx, prevHash)
-		return err
-	})
-	return prevBlockNode, err
-}
-
-// getPrevNodeFromNode returns a block node for the block previous to the
-// passed block node (the passed block node's parent).  When the node is already
-// connected to a parent, it simply returns it.  Otherwise, it loads the
-// associated block from the database to obtain the previous hash and uses that
-// to dynamically create a new block node and return it.  The memory block
-// chain is updated accordingly.  The returned node will be nil if the genesis
-// block is passed.
-//
-// This function MUST be called with the chain state lock held (for writes).
+// This function MUST be called with the chain state lock held (for reads).
 func (b *BlockChain) getPrevNodeFromNode(node *blockNode) (*blockNode, error) {
-	// Return the existing previous block node if it's already there.
-	if node.parent != nil {
-		return node.parent, nil
-	}
-
-	// Genesis block.
-	if node.hash.IsEqual(b.chainParams.GenesisHash) {
-		return nil, nil
-	}
-
-	// Dynamically load the previous block from the block database, create
-	// a new block node for it, and update the memory chain accordingly.
-	var prevBlockNode *blockNode
-	err := b.db.View(func(dbTx database.Tx) error {
-		var err error
-		prevBlockNode, err = b.loadBlockNode(dbTx, &node.header.PrevBlock)
-		return err
-	})
-	return prevBlockNode, err
+	return node.parent, nil
 }
 
 // ancestorNode returns the ancestor block node at the provided height by
-// following the chain backwards from the given node while dynamically loading
-// any pruned nodes from the database and updating the memory block chain as
-// needed.  The returned block will be nil when a height is requested that is
-// after the height of the passed node or is less than zero.
+// following the skip list of the given node.  The returned block will be nil
+// when a height is requested that is after the height of the passed node or is
+// less than zero.
 //
-// This function MUST be called with the chain state lock held (for writes).
+// This function MUST be called with the chain state lock held (for reads).
 func (b *BlockChain) ancestorNode(node *blockNode, height int64) (*blockNode, error) {
-	// Nothing to do if the requested height is outside of the valid range.
-	if height > node.height || height < 0 {
-		return nil, nil
-	}
-
-	// Iterate backwards until the requested height is reached.
-	iterNode := node
-	for iterNode != nil && iterNode.height > height {
-		// Get the previous block node.  This function is used over
-		// simply accessing iterNode.parent directly as it will
-		// dynamically create previous block nodes as needed.  This
-		// helps allow only the pieces of the chain that are needed
-		// to remain in memory.
-		var err error
-		iterNode, err = b.getPrevNodeFromNode(iterNode)
-		if err != nil {
-			log.Errorf("getPrevNodeFromNode: %v", err)
-			return nil, err
-		}
-	}
-
-	return iterNode, nil
+	return node.Ancestor(height), nil
 }
 
 // GetTopBlock returns the current block at HEAD on the blockchain.  Needed
@@ -982,24 +661,23 @@ func (b *BlockChain) pruneStakeNodes() {
 	// just before each new node is created.  However, that might be tuned
 	// later to only prune at intervals, so the code needs to account for
 	// the possibility of multiple nodes.
+	// The nodes further back than minMemoryNodes are skipped since their
+	// stake data was already dropped when they were within range.
 	deleteNodes := list.New()
-	for node := pruneToNode.parent; node != nil; node = node.parent {
+	minHeight := b.bestNode.height - minMemoryNodes
+	for node := pruneToNode.parent; node != nil && node.height > minHeight; node = node.parent {
 		deleteNodes.PushFront(node)
 	}
 
-	// Loop through each node to prune, unlink its children, remove it from
-	// the dependency index, and remove it from the node index.
+	// Loop through each node to prune and drop its stake data.  The nodes
+	// themselves remain in the memory block index.
 	for e := deleteNodes.Front(); e != nil; e = e.Next() {
 		node := e.Value.(*blockNode)
-		// Do not attempt to prune if the node should already have been pruned,
-		// for example if you're adding an old side chain block.
-		if node.height > b.bestNode.height-minMemoryNodes {
-			node.stakeNode = nil
-			node.stakeUndoData = nil
-			node.newTickets = nil
-			node.ticketsSpent = nil
-			node.ticketsRevoked = nil
-		}
+		node.stakeNode = nil
+		node.stakeUndoData = nil
+		node.newTickets = nil
+		node.ticketsSpent = nil
+		node.ticketsRevoked = nil
 	}
 }
 
@@ -1126,12 +804,6 @@ func (b *BlockChain) getReorganizeNodes(node *blockNode) (*list.List, *list.List
 		attachNodes.PushFront(ancestor)
 	}
 
-	// TODO(davec): Use prevNodeFromNode function in case the requested
-	// node is further back than the what is in memory.  This shouldn't
-	// happen in the normal course of operation, but the ability to fetch
-	// input transactions of arbitrary blocks will likely to be exposed at
-	// some point and that could lead to an issue here.
-
 	// Start from the end of the main chain and work backwards until the
 	// common ancestor adding each block to the list of nodes to detach from
 	// the main chain.
@@ -1140,14 +812,6 @@ func (b *BlockChain) getReorganizeNodes(node *blockNode) (*list.List, *list.List
 			break
 		}
 		detachNodes.PushBack(n)
-
-		if n.parent == nil {
-			var err error
-			n.parent, err = b.findNode(&n.header.PrevBlock, maxSearchDepth)
-			if err != nil {
-				return nil, nil, err
-			}
-		}
 	}
 
 	return detachNodes, attachNodes, nil
@@ -1280,6 +944,19 @@ func (b *BlockChain) connectBlock(node *blockNode, block *hcutil.Block, view *Ut
 			return err
 		}
 
+		// Update the block index entry of the block now that its data
+		// is stored and it is known to be valid.
+		err = dbPutBlockNode(dbTx, &blockNode{
+			hash:   node.hash,
+			height: node.height,
+			header: node.header,
+			votes:  node.votes,
+			status: node.status | statusDataStored | statusValidated,
+		})
+		if err != nil {
+			return err
+		}
+
 		// Insert the block into the stake database.
 		err = stake.WriteConnectedBestNode(dbTx, stakeNode, node.hash)
 		if err != nil {
@@ -1309,8 +986,8 @@ func (b *BlockChain) connectBlock(node *blockNode, block *hcutil.Block, view *Ut
 	// Add the new node to the memory main chain indices for faster
 	// lookups.
 	node.inMainChain = true
+	node.status |= statusDataStored | statusValidated
 	b.index[node.hash] = node
-	b.depNodes[prevHash] = append(b.depNodes[prevHash], node)
 
 	// This node is now the end of the best chain.
 	b.bestNode = node
@@ -1595,17 +1272,26 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List,
 	formerBestHash := b.bestNode.hash
 	formerBestHeight := b.bestNode.height
 
-	// Ensure all of the needed side chain blocks are in the cache.
+	// Ensure all of the needed side chain blocks are available.  They are
+	// either in the side chain block cache or, for blocks which were
+	// disconnected from the main chain before, stored in the database.
+	attachBlocks := make([]*hcutil.Block, 0, attachNodes.Len())
 	for e := attachNodes.Front(); e != nil; e = e.Next() {
 		n := e.Value.(*blockNode)
 
 		b.blockCacheLock.RLock()
-		_, exists := b.blockCache[n.hash]
+		block, exists := b.blockCache[n.hash]
 		b.blockCacheLock.RUnlock()
+		if !exists && n.status.HaveData() {
+			var err error
+			block, err = b.fetchBlockByHash(&n.hash)
+			exists = err == nil
+		}
 		if !exists {
 			return AssertError(fmt.Sprintf("block %v is missing "+
 				"from the side chain block cache", n.hash))
 		}
+		attachBlocks = append(attachBlocks, block)
 	}
 
 	// All of the blocks to detach and related spend journal entries needed
@@ -1681,19 +1367,29 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List,
 	// at least a couple of ways accomplish that rollback, but both involve
 	// tweaking the chain and/or database.  This approach catches these
 	// issues before ever modifying the chain.
+	//
+	// The fork point becomes the new end of the main chain when there are
+	// no blocks to attach, such as when the best block is invalidated and
+	// there is no side chain to switch to.
 	var topBlock *blockNode
-	for e := attachNodes.Front(); e != nil; e = e.Next() {
+	if detachNodes.Len() > 0 {
+		topBlock = detachNodes.Back().Value.(*blockNode).parent
+	}
+	for i, e := 0, attachNodes.Front(); e != nil; i, e = i+1, e.Next() {
 		n := e.Value.(*blockNode)
-		b.blockCacheLock.RLock()
-		block := b.blockCache[n.hash]
-		b.blockCacheLock.RUnlock()
 
 		// Notice the spent txout details are not requested here and
 		// thus will not be generated.  This is done because the state
 		// is not being immediately written to the database, so it is
 		// not needed.
-		err := b.checkConnectBlock(n, block, view, nil)
+		//
+		// Blocks which violate the rules are marked invalid along with
+		// their descendants so they are not considered again.
+		err := b.checkConnectBlock(n, attachBlocks[i], view, nil)
 		if err != nil {
+			if _, ok := err.(RuleError); ok && flags&BFDryRun == 0 {
+				b.markValidateFailed(n)
+			}
 			return err
 		}
 		topBlock = n
@@ -1761,11 +1457,9 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List,
 	}
 
 	// Connect the new best chain blocks.
-	for e := attachNodes.Front(); e != nil; e = e.Next() {
+	for i, e := 0, attachNodes.Front(); e != nil; i, e = i+1, e.Next() {
 		n := e.Value.(*blockNode)
-		b.blockCacheLock.RLock()
-		block := b.blockCache[n.hash]
-		b.blockCacheLock.RUnlock()
+		block := attachBlocks[i]
 
 		parent, err := b.fetchBlockByHash(&n.header.PrevBlock)
 		if err != nil {
@@ -1791,22 +1485,28 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List,
 	}
 
 	// Log the point where the chain forked.
-	firstAttachNode := attachNodes.Front().Value.(*blockNode)
-	forkNode, err := b.getPrevNodeFromNode(firstAttachNode)
-	if err == nil {
+	forkNode := topBlock
+	if attachNodes.Len() > 0 {
+		firstAttachNode := attachNodes.Front().Value.(*blockNode)
+		var err error
+		forkNode, err = b.getPrevNodeFromNode(firstAttachNode)
+		if err != nil {
+			forkNode = nil
+		}
+	}
+	if forkNode != nil {
 		log.Infof("REORGANIZE: Chain forks at %v, height %v",
 			forkNode.hash,
 			forkNode.height)
 	}
 
 	// Log the old and new best chain heads.
-	lastAttachNode := attachNodes.Back().Value.(*blockNode)
 	log.Infof("REORGANIZE: Old best chain head was %v, height %v",
 		formerBestHash,
 		formerBestHeight)
 	log.Infof("REORGANIZE: New best chain head is %v, height %v",
-		lastAttachNode.hash,
-		lastAttachNode.height)
+		newHash,
+		newHeight)
 
 	return nil
 }
@@ -1949,6 +1649,13 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *hcutil.Block, flag
 		if !fastAdd {
 			err := b.checkConnectBlock(node, block, view, &stxos)
 			if err != nil {
+				// Blocks which violate the consensus rules are
+				// marked invalid along with their descendants so
+				// neither they nor their headers are processed
+				// again.
+				if _, ok := err.(RuleError); ok && !dryRun {
+					b.markValidateFailed(node)
+				}
 				return false, err
 			}
 		}
@@ -1979,11 +1686,6 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *hcutil.Block, flag
 			return false, err
 		}
 
-		// Connect the parent node to this node.
-		if node.parent != nil {
-			node.parent.children = append(node.parent.children, node)
-		}
-
 		validateStr := "validating"
 		txTreeRegularValid := hcutil.IsFlagSet16(node.header.VoteBits,
 			hcutil.BlockValid)
@@ -2012,27 +1714,42 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *hcutil.Block, flag
 	b.blockCacheLock.Lock()
 	b.blockCache[node.hash] = block
 	b.blockCacheLock.Unlock()
-	b.index[node.hash] = node
-
-	// Connect the parent node to this node.
 	node.inMainChain = false
-	node.parent.children = append(node.parent.children, node)
 
-	// Remove the block from the side chain cache and disconnect it from the
-	// parent node when the function returns when running in dry run mode.
+	// The node is already in the block index and connected to its parent
+	// node since its header was accepted, except when running in dry run
+	// mode.  In that case, temporarily add the node when the header is not
+	// known yet.  Remove the block from the side chain cache, along with
+	// the node when it was added, when the function returns.
 	if dryRun {
+		_, indexed := b.index[node.hash]
+		if !indexed {
+			b.index[node.hash] = node
+			node.parent.children = append(node.parent.children, node)
+		}
 		defer func() {
-			children := node.parent.children
-			children = removeChildNode(children, node)
-			node.parent.children = children
-
-			delete(b.index, node.hash)
+			if !indexed {
+				children := node.parent.children
+				children = removeChildNode(children, node)
+				node.parent.children = children
+				delete(b.index, node.hash)
+			}
 			b.blockCacheLock.Lock()
 			delete(b.blockCache, node.hash)
 			b.blockCacheLock.Unlock()
 		}()
 	}
 
+	// Blocks which are marked invalid, or build on a block which is, never
+	// become part of the main chain regardless of their work.
+	if b.isInvalidated(node) {
+		if !dryRun {
+			log.Infof("Block %v (height %v) extends a chain which is "+
+				"marked invalid", node.hash, node.height)
+		}
+		return false, nil
+	}
+
 	// We're extending (or creating) a side chain, but the cumulative
 	// work for this new side chain is not enough to make it the new chain.
 	if node.workSum.Cmp(b.bestNode.workSum) <= 0 {
@@ -2150,14 +1867,67 @@ func (b *BlockChain) BestSnapshot() *BestState {
 	return snapshot
 }
 
+// BestHeader returns the hash and height of the best known header, which is the
+// end of the header chain with the most cumulative work that is not known to be
+// invalid.  It is the same as the end of the main chain when the blocks of all
+// known headers with more work have been processed.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BestHeader() (chainhash.Hash, int64) {
+	b.chainLock.RLock()
+	header := b.bestHeader
+	b.chainLock.RUnlock()
+	return header.hash, header.height
+}
+
+// NextNeededBlocks returns the hashes of up to the passed maximum number of
+// blocks of the best header chain, starting from the point it forks from the
+// main chain, whose data is not available yet.  They are returned in order of
+// their height, which is the order in which they have to be processed.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) NextNeededBlocks(maxBlocks int) []*chainhash.Hash {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	// Find the height at which the best header chain forks from the main
+	// chain.  Since all ancestors of a main chain node are part of the main
+	// chain as well, the height can be found with a binary search.
+	header := b.bestHeader
+	low, high := int64(0), header.height
+	if b.bestNode.height < high {
+		high = b.bestNode.height
+	}
+	for low < high {
+		mid := low + (high-low+1)/2
+		if header.Ancestor(mid).inMainChain {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+
+	var hashes []*chainhash.Hash
+	for height := low + 1; height <= header.height; height++ {
+		if len(hashes) >= maxBlocks {
+			break
+		}
+		node := header.Ancestor(height)
+		if !b.haveBlockData(node) {
+			hashes = append(hashes, &node.hash)
+		}
+	}
+	return hashes
+}
+
 // MaximumBlockSize returns the maximum permitted block size for the block
 // AFTER the given node.
 //
 // This function MUST be called with the chain state lock held (for reads).
 func (b *BlockChain) maxBlockSize(prevNode *blockNode) (int64, error) {
-	// Hard fork voting on block size is only enabled on testnet v1 and
-	// simnet.
-	if b.chainParams.Net != wire.SimNet {
+	// Hard fork voting on block size is only enabled on networks that
+	// define a larger block size to vote for, such as simnet.
+	if len(b.chainParams.MaximumBlockSizes) < 2 {
 		return int64(b.chainParams.MaximumBlockSizes[0]), nil
 	}
 
@@ -2254,6 +2024,27 @@ type Config struct {
 	// This field can be nil if the caller does not wish to make use of an
 	// index manager.
 	IndexManager IndexManager
+
+	// AssumeValid is the hash of a block which is assumed to be valid along
+	// with all of its ancestors.  Script validation is skipped for those
+	// ancestors which are buried under enough proof of work.
+	//
+	// The zero hash disables the optimization.
+	AssumeValid chainhash.Hash
+
+	// BatchSigVerify defers the verification of the secp256k1 Schnorr and
+	// Bliss signatures checked by the scripts of a block until all of the
+	// scripts have been executed.  The Schnorr signatures are then batch
+	// verified, and the inputs with invalid signatures are validated again
+	// individually to report the error.
+	BatchSigVerify bool
+
+	// ForcedDeployments maps the vote ids of deployments to the state,
+	// either ThresholdActive or ThresholdFailed, they are forced to
+	// regardless of the votes.  See ForceDeploymentState for details.
+	//
+	// This field may only be set for the regression test network.
+	ForcedDeployments map[string]ThresholdState
 }
 
 // New returns a BlockChain instance using the provided configuration details.
@@ -2285,11 +2076,10 @@ func New(config *Config) (*BlockChain, error) {
 		notifications:                 config.Notifications,
 		sigCache:                      config.SigCache,
 		indexManager:                  config.IndexManager,
+		assumeValid:                   config.AssumeValid,
+		batchSigVerify:                config.BatchSigVerify,
 		bestNode:                      nil,
 		index:                         make(map[chainhash.Hash]*blockNode),
-		depNodes:                      make(map[chainhash.Hash][]*blockNode),
-		orphans:                       make(map[chainhash.Hash]*orphanBlock),
-		prevOrphans:                   make(map[chainhash.Hash][]*orphanBlock),
 		blockCache:                    make(map[chainhash.Hash]*hcutil.Block),
 		mainchainBlockCache:           make(map[chainhash.Hash]*hcutil.Block),
 		mainchainBlockCacheSize:       mainchainBlockCacheSize,
@@ -2308,6 +2098,21 @@ func New(config *Config) (*BlockChain, error) {
 		return nil, err
 	}
 
+	// Apply the forced deployment states.
+	for id, state := range config.ForcedDeployments {
+		if err := b.forceDeploymentState(id, state); err != nil {
+			return nil, err
+		}
+	}
+
+	// Load the blocks which were manually marked invalid.
+	if err := b.initInvalidatedBlocks(); err != nil {
+		return nil, err
+	}
+	if len(b.invalidated) > 0 {
+		log.Infof("%d blocks are marked invalid", len(b.invalidated))
+	}
+
 	// Initialize and catch up all of the currently active optional indexes
 	// as needed.
 	if config.IndexManager != nil {
@@ -2321,6 +2126,14 @@ func New(config *Config) (*BlockChain, error) {
 		return nil, err
 	}
 
+	// Determine the best known header, which might be ahead of the main
+	// chain when the blocks of a header chain were not all downloaded yet.
+	b.bestHeader = b.findBestHeader()
+	if b.bestHeader != b.bestNode {
+		log.Infof("Best header: height %d, hash %v", b.bestHeader.height,
+			b.bestHeader.hash)
+	}
+
 	b.subsidyCache = NewSubsidyCache(b.bestNode.height, b.chainParams)
 	b.pruner = newChainPruner(&b)
 
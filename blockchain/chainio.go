@@ -54,7 +54,7 @@ const (
 
 	// currentDatabaseVersion indicates what the current database
 	// version is.
-	currentDatabaseVersion = 2
+	currentDatabaseVersion = 3
 )
 
 // errNotInMainChain signifies that a block hash or height that is not in the
@@ -1080,6 +1080,257 @@ func dbFetchHashByHeight(dbTx database.Tx, height int64) (*chainhash.Hash, error
 	return &hash, nil
 }
 
+// -----------------------------------------------------------------------------
+// The invalidated blocks consist of an entry for every block which was
+// manually marked invalid, either directly or because one of its ancestors
+// was, so that the marks survive restarts.  The parent hash is stored so the
+// descendants of a block can be determined without loading any blocks.
+//
+// The serialized key format is:
+//   <hash>
+//
+//   Field      Type             Size
+//   hash       chainhash.Hash   chainhash.HashSize
+//
+// The serialized value format is:
+//   <parent hash>
+//
+//   Field        Type             Size
+//   parent hash  chainhash.Hash   chainhash.HashSize
+// -----------------------------------------------------------------------------
+
+// dbPutInvalidatedBlock uses an existing database transaction to mark the
+// block with the provided hash and parent hash as invalid.
+func dbPutInvalidatedBlock(dbTx database.Tx, hash, parentHash *chainhash.Hash) error {
+	bucket := dbTx.Metadata().Bucket(dbnamespace.InvalidatedBlocksBucketName)
+	return bucket.Put(hash[:], parentHash[:])
+}
+
+// dbRemoveInvalidatedBlock uses an existing database transaction to remove the
+// invalid mark of the block with the provided hash.
+func dbRemoveInvalidatedBlock(dbTx database.Tx, hash *chainhash.Hash) error {
+	bucket := dbTx.Metadata().Bucket(dbnamespace.InvalidatedBlocksBucketName)
+	return bucket.Delete(hash[:])
+}
+
+// dbFetchInvalidatedBlocks uses an existing database transaction to load all
+// blocks which are marked invalid.  The returned map is keyed by the block
+// hash and contains the hash of the parent of each block.
+func dbFetchInvalidatedBlocks(dbTx database.Tx) (map[chainhash.Hash]chainhash.Hash, error) {
+	invalidated := make(map[chainhash.Hash]chainhash.Hash)
+	bucket := dbTx.Metadata().Bucket(dbnamespace.InvalidatedBlocksBucketName)
+	err := bucket.ForEach(func(k, v []byte) error {
+		if len(k) != chainhash.HashSize || len(v) != chainhash.HashSize {
+			return database.Error{
+				ErrorCode:   database.ErrCorruption,
+				Description: "corrupt invalidated block entry",
+			}
+		}
+		var hash, parentHash chainhash.Hash
+		copy(hash[:], k)
+		copy(parentHash[:], v)
+		invalidated[hash] = parentHash
+		return nil
+	})
+	return invalidated, err
+}
+
+// initInvalidatedBlocks creates the bucket which houses the invalidated blocks
+// when it does not exist yet, such as for databases created by previous
+// versions, and loads them.
+func (b *BlockChain) initInvalidatedBlocks() error {
+	return b.db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		_, err := meta.CreateBucketIfNotExists(
+			dbnamespace.InvalidatedBlocksBucketName)
+		if err != nil {
+			return err
+		}
+
+		b.invalidated, err = dbFetchInvalidatedBlocks(dbTx)
+		return err
+	})
+}
+
+// -----------------------------------------------------------------------------
+// The block index consists of an entry for every block which has its data
+// stored in the database, which includes all blocks of the main chain as well
+// as blocks which were disconnected from it.  It is loaded into memory in its
+// entirety on startup.  The key starts with the height of the block in big
+// endian, so iterating the bucket yields the entries in order of their height,
+// which ensures the parent of every block is loaded before the block itself.
+//
+// The serialized key format is:
+//   <height><hash>
+//
+//   Field      Type             Size
+//   height     uint32           4
+//   hash       chainhash.Hash   chainhash.HashSize
+//
+// The serialized value format is:
+//   <block header><status><num votes><vote version><vote bits>...
+//
+//   Field          Type              Size
+//   block header   wire.BlockHeader  wire.MaxBlockHeaderPayload
+//   status         blockStatus       1
+//   num votes      VLQ               variable
+//   vote version   uint32            4
+//   vote bits      uint16            2
+//
+// The vote version and vote bits are repeated for each vote.
+// -----------------------------------------------------------------------------
+
+// blockIndexEntrySerializeSize returns the number of bytes it would take to
+// serialize the block index entry of the passed block node.
+func blockIndexEntrySerializeSize(node *blockNode) int {
+	return wire.MaxBlockHeaderPayload + 1 +
+		serializeSizeVLQ(uint64(len(node.votes))) + len(node.votes)*6
+}
+
+// blockIndexKey returns the key of the block index entry of the block with
+// the passed hash and height.
+func blockIndexKey(hash *chainhash.Hash, height uint32) []byte {
+	key := make([]byte, 4+chainhash.HashSize)
+	binary.BigEndian.PutUint32(key[0:4], height)
+	copy(key[4:], hash[:])
+	return key
+}
+
+// serializeBlockIndexEntry returns the serialized block index entry of the
+// passed block node.
+func serializeBlockIndexEntry(node *blockNode) ([]byte, error) {
+	serialized := make([]byte, blockIndexEntrySerializeSize(node))
+	headerBytes, err := node.header.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	offset := copy(serialized, headerBytes)
+	serialized[offset] = byte(node.status)
+	offset++
+	offset += putVLQ(serialized[offset:], uint64(len(node.votes)))
+	for _, vote := range node.votes {
+		byteOrder.PutUint32(serialized[offset:], vote.Version)
+		offset += 4
+		byteOrder.PutUint16(serialized[offset:], vote.Bits)
+		offset += 2
+	}
+	return serialized, nil
+}
+
+// deserializeBlockIndexEntry creates a block node from the passed serialized
+// block index entry.  The node is not linked to its parent and its work sum
+// is just the work of the block.
+func deserializeBlockIndexEntry(hash *chainhash.Hash, serialized []byte) (*blockNode, error) {
+	if len(serialized) < wire.MaxBlockHeaderPayload+2 {
+		return nil, errDeserialize("unexpected end of data while " +
+			"reading block index entry")
+	}
+
+	var header wire.BlockHeader
+	err := header.FromBytes(serialized[:wire.MaxBlockHeaderPayload])
+	if err != nil {
+		return nil, err
+	}
+	offset := wire.MaxBlockHeaderPayload
+	status := blockStatus(serialized[offset])
+	offset++
+
+	numVotes, bytesRead := deserializeVLQ(serialized[offset:])
+	offset += bytesRead
+	if uint64(len(serialized)-offset) != numVotes*6 {
+		return nil, errDeserialize("unexpected length of the votes in " +
+			"block index entry")
+	}
+	var votes []VoteVersionTuple
+	if numVotes > 0 {
+		votes = make([]VoteVersionTuple, numVotes)
+		for i := range votes {
+			votes[i].Version = byteOrder.Uint32(serialized[offset:])
+			offset += 4
+			votes[i].Bits = byteOrder.Uint16(serialized[offset:])
+			offset += 2
+		}
+	}
+
+	// The hash is provided by the key, which avoids hashing the header of
+	// every block when loading the block index.
+	node := &blockNode{
+		hash:    *hash,
+		workSum: CalcWork(header.Bits),
+		height:  int64(header.Height),
+		header:  header,
+		votes:   votes,
+		status:  status,
+	}
+	return node, nil
+}
+
+// dbPutBlockNode uses an existing database transaction to store the block
+// index entry of the passed block node.
+func dbPutBlockNode(dbTx database.Tx, node *blockNode) error {
+	serialized, err := serializeBlockIndexEntry(node)
+	if err != nil {
+		return err
+	}
+
+	bucket := dbTx.Metadata().Bucket(dbnamespace.BlockIndexBucketName)
+	key := blockIndexKey(&node.hash, uint32(node.height))
+	return bucket.Put(key, serialized)
+}
+
+// loadBlockIndex uses an existing database transaction to load all entries of
+// the block index into the memory block index and links every node to its
+// parent.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) loadBlockIndex(dbTx database.Tx) error {
+	bucket := dbTx.Metadata().Bucket(dbnamespace.BlockIndexBucketName)
+	cursor := bucket.Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if len(key) != 4+chainhash.HashSize {
+			return database.Error{
+				ErrorCode:   database.ErrCorruption,
+				Description: "corrupt block index key",
+			}
+		}
+		var hash chainhash.Hash
+		copy(hash[:], key[4:])
+		node, err := deserializeBlockIndexEntry(&hash, cursor.Value())
+		if err != nil {
+			return database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt block index "+
+					"entry for block %v: %v", hash, err),
+			}
+		}
+
+		// The genesis block is the only block without a parent.  Every
+		// other parent has a lower height and thus was already loaded.
+		if node.height == 0 {
+			if hash != *b.chainParams.GenesisHash {
+				return AssertError(fmt.Sprintf("block index "+
+					"entry for block %v at height 0 is not the "+
+					"genesis block", hash))
+			}
+		} else {
+			parent, ok := b.index[node.header.PrevBlock]
+			if !ok {
+				return AssertError(fmt.Sprintf("parent %v of "+
+					"block %v is not in the block index",
+					node.header.PrevBlock, hash))
+			}
+			node.parent = parent
+			node.workSum.Add(parent.workSum, node.workSum)
+			parent.children = append(parent.children, node)
+			node.buildSkipList()
+		}
+		b.index[hash] = node
+	}
+
+	return nil
+}
+
 // -----------------------------------------------------------------------------
 // The database information contains information about the version and date
 // of the blockchain database.
@@ -1304,6 +1555,7 @@ func (b *BlockChain) createChainState() error {
 	header := &genesisBlock.MsgBlock().Header
 	node := newBlockNode(header, nil, nil, nil)
 	node.inMainChain = true
+	node.status = statusDataStored | statusValidated
 	b.bestNode = node
 
 	// Add the new node to the index which is used for faster lookups.
@@ -1367,6 +1619,17 @@ func (b *BlockChain) createChainState() error {
 			return err
 		}
 
+		// Create the bucket that houses the block index and add the
+		// genesis block to it.
+		_, err = meta.CreateBucket(dbnamespace.BlockIndexBucketName)
+		if err != nil {
+			return err
+		}
+		err = dbPutBlockNode(dbTx, b.bestNode)
+		if err != nil {
+			return err
+		}
+
 		// Add the genesis block hash to height and height to hash
 		// mappings to the index.
 		err = dbPutBlockIndex(dbTx, &b.bestNode.hash, b.bestNode.height)
@@ -1398,7 +1661,8 @@ func (b *BlockChain) createChainState() error {
 // chain state are initialized to the genesis block.
 func (b *BlockChain) initChainState() error {
 	// Attempt to load the chain state from the database.
-	var isStateInitialized bool
+	var state bestChainState
+	var isStateInitialized, haveBlockIndex bool
 	err := b.db.View(func(dbTx database.Tx) error {
 		// Fetch the database versioning information.
 		dbInfo, err := dbFetchDatabaseInfo(dbTx)
@@ -1446,11 +1710,53 @@ func (b *BlockChain) initChainState() error {
 			return nil
 		}
 		log.Tracef("Serialized chain state: %x", serializedData)
-		state, err := deserializeBestChainState(serializedData)
+		state, err = deserializeBestChainState(serializedData)
 		if err != nil {
 			return err
 		}
 
+		meta := dbTx.Metadata()
+		haveBlockIndex = meta.Bucket(dbnamespace.BlockIndexBucketName) != nil
+		isStateInitialized = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// At this point the database has not already been initialized, so
+	// initialize both it and the chain state to the genesis block.
+	if !isStateInitialized {
+		return b.createChainState()
+	}
+
+	// Databases created by previous versions do not have a block index yet,
+	// so create it from the main chain before loading it.
+	if !haveBlockIndex {
+		err := b.createBlockIndex(int64(state.height))
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.db.View(func(dbTx database.Tx) error {
+		// Load the entire block index into memory.
+		log.Infof("Loading block index...")
+		if err := b.loadBlockIndex(dbTx); err != nil {
+			return err
+		}
+
+		// Set the best node and mark it along with its ancestors as
+		// part of the main chain.
+		node, ok := b.index[state.hash]
+		if !ok {
+			return AssertError(fmt.Sprintf("best block %v is not in "+
+				"the block index", state.hash))
+		}
+		for n := node; n != nil; n = n.parent {
+			n.inMainChain = true
+		}
+
 		// Load the raw block bytes for the best block.
 		blockBytes, err := dbTx.FetchBlock(&state.hash)
 		if err != nil {
@@ -1461,20 +1767,14 @@ func (b *BlockChain) initChainState() error {
 		if err != nil {
 			return err
 		}
-
-		// Create a new node and set it as the best node.  The preceding
-		// nodes will be loaded on demand as needed.
 		blk := hcutil.NewBlock(&block)
-		header := &block.Header
-		node := newBlockNode(header, ticketsSpentInBlock(blk),
-			ticketsRevokedInBlock(blk), voteBitsInBlock(blk))
-		node.inMainChain = true
-		node.workSum = state.workSum
+		node.ticketsSpent = ticketsSpentInBlock(blk)
+		node.ticketsRevoked = ticketsRevokedInBlock(blk)
 
 		// Exception for version 1 blockchains: skip loading the stake
 		// node, as the upgrade path handles ensuring this is correctly
 		// set.
-		if dbInfo.version >= 2 {
+		if b.dbInfo.version >= 2 {
 			node.stakeNode, err = stake.LoadBestNode(dbTx, uint32(node.height),
 				node.hash, node.header, b.chainParams)
 			if err != nil {
@@ -1486,11 +1786,6 @@ func (b *BlockChain) initChainState() error {
 
 		b.bestNode = node
 
-		// Add the new node to the indices for faster lookups.
-		prevHash := node.header.PrevBlock
-		b.index[node.hash] = node
-		b.depNodes[prevHash] = append(b.depNodes[prevHash], node)
-
 		// Calculate the median time for the block.
 		medianTime, err := b.calcPastMedianTime(node)
 		if err != nil {
@@ -1503,21 +1798,8 @@ func (b *BlockChain) initChainState() error {
 		b.stateSnapshot = newBestState(b.bestNode, blockSize, numTxns,
 			state.totalTxns, medianTime, state.totalSubsidy)
 
-		isStateInitialized = true
 		return nil
 	})
-	if err != nil {
-		return err
-	}
-
-	// There is nothing more to do if the chain state was initialized.
-	if isStateInitialized {
-		return nil
-	}
-
-	// At this point the database has not already been initialized, so
-	// initialize both it and the chain state to the genesis block.
-	return b.createChainState()
 }
 
 // dbFetchHeaderByHash uses an existing database transaction to retrieve the
@@ -1638,6 +1920,20 @@ func DBMainChainHasBlock(dbTx database.Tx, hash *chainhash.Hash) bool {
 	return dbMainChainHasBlock(dbTx, hash)
 }
 
+// DBFetchBestHeight uses an existing database transaction to retrieve the
+// height of the tip of the main chain.  Unlike the best state snapshot, the
+// height is always consistent with the rest of the main chain data observed by
+// the transaction.
+func DBFetchBestHeight(dbTx database.Tx) (int64, error) {
+	serializedData := dbTx.Metadata().Get(dbnamespace.ChainStateKeyName)
+	state, err := deserializeBestChainState(serializedData)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(state.height), nil
+}
+
 // MainChainHasBlock returns whether or not the block with the given hash is in
 // the main chain.
 //
@@ -0,0 +1,102 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/wire"
+)
+
+// DifficultyCalculator calculates the proof of work and stake difficulties
+// required of the headers extending an in-memory chain of headers with the same
+// retarget code used to validate blocks.  It does not require a database and
+// performs no other validation, which makes it suitable for replaying
+// historical headers or simulated scenarios with custom network parameters.
+//
+// Only the headers the retarget calculations look back at are kept in memory,
+// so arbitrarily long chains may be processed.
+//
+// This type is NOT safe for concurrent access.
+type DifficultyCalculator struct {
+	chain *BlockChain
+
+	// window holds the most recent nodes of the chain starting with the
+	// oldest one which is still needed by the retarget calculations.
+	window    []*blockNode
+	maxWindow int
+}
+
+// NewDifficultyCalculator returns a difficulty calculator for a chain which
+// consists of the genesis block of the passed network parameters.
+func NewDifficultyCalculator(params *chaincfg.Params) *DifficultyCalculator {
+	// The proof of work retarget looks back over all of its windows while
+	// the stake retarget looks back to the block before the previous
+	// interval and sums the ticket purchases of the ticket maturity before
+	// it.
+	workLookback := params.WorkDiffWindowSize * params.WorkDiffWindows
+	stakeLookback := params.StakeDiffWindowSize +
+		int64(params.TicketMaturity) + 1
+	maxWindow := workLookback
+	if stakeLookback > maxWindow {
+		maxWindow = stakeLookback
+	}
+
+	genesis := newBlockNode(&params.GenesisBlock.Header, nil, nil, nil)
+	return &DifficultyCalculator{
+		chain:     &BlockChain{chainParams: params},
+		window:    []*blockNode{genesis},
+		maxWindow: int(maxWindow) + 1,
+	}
+}
+
+// tip returns the node at the end of the chain.
+func (c *DifficultyCalculator) tip() *blockNode {
+	return c.window[len(c.window)-1]
+}
+
+// Tip returns the header at the end of the chain.
+func (c *DifficultyCalculator) Tip() wire.BlockHeader {
+	return c.tip().header
+}
+
+// AddHeader extends the chain with the passed header, which must be at the
+// height after the current tip.  The difficulties of the header are not
+// checked so that headers with difficulties calculated from different
+// parameters, such as historical headers, may be added as well.
+func (c *DifficultyCalculator) AddHeader(header *wire.BlockHeader) error {
+	tip := c.tip()
+	if int64(header.Height) != tip.height+1 {
+		return fmt.Errorf("header at height %d does not extend the "+
+			"tip at height %d", header.Height, tip.height)
+	}
+
+	node := newBlockNode(header, nil, nil, nil)
+	node.parent = tip
+	c.window = append(c.window, node)
+
+	// Drop the reference to the oldest node once it is no longer needed
+	// so it can be garbage collected.
+	if len(c.window) > c.maxWindow {
+		c.window[0] = nil
+		c.window = c.window[1:]
+		c.window[0].parent = nil
+	}
+	return nil
+}
+
+// NextRequiredDifficulty returns the proof of work difficulty bits required of
+// a header which extends the tip with the passed timestamp.
+func (c *DifficultyCalculator) NextRequiredDifficulty(timestamp time.Time) (uint32, error) {
+	return c.chain.calcNextRequiredDifficulty(c.tip(), timestamp)
+}
+
+// NextRequiredStakeDifficulty returns the stake difficulty, which is the
+// ticket price in atoms, required of a header which extends the tip.
+func (c *DifficultyCalculator) NextRequiredStakeDifficulty() (int64, error) {
+	return c.chain.calcNextRequiredStakeDifficulty(c.tip())
+}
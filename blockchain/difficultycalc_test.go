@@ -0,0 +1,127 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/wire"
+)
+
+// TestDifficultyCalculator ensures the difficulties calculated for a chain of
+// headers which only keeps the most recent headers in memory match the ones
+// calculated by the chain for the full chain of headers.
+func TestDifficultyCalculator(t *testing.T) {
+	t.Parallel()
+
+	minDiffParams := chaincfg.SimNetParams
+	minDiffParams.ReduceMinDifficulty = true
+	minDiffParams.MinDiffReductionTime = minDiffParams.TargetTimePerBlock * 3
+
+	tests := []struct {
+		name   string
+		params *chaincfg.Params
+	}{
+		{"simnet", &chaincfg.SimNetParams},
+		{"simnet with min difficulty reduction", &minDiffParams},
+	}
+
+	for _, test := range tests {
+		params := test.params
+		bc := newFakeChain(params)
+		calc := NewDifficultyCalculator(params)
+		rng := rand.New(rand.NewSource(1))
+
+		const numBlocks = 600
+		timestamp := params.GenesisBlock.Header.Timestamp
+		var poolSize uint32
+		for i := 0; i < numBlocks; i++ {
+			// Mostly find blocks faster than the target so the
+			// difficulty rises above the limit, with occasional slow
+			// blocks for the minimum difficulty reduction to apply.
+			target := int64(params.TargetTimePerBlock)
+			blockTime := time.Duration(target/10 + rng.Int63n(target))
+			if rng.Intn(20) == 0 {
+				blockTime = time.Duration(target * 5)
+			}
+			timestamp = timestamp.Add(blockTime)
+
+			wantBits, err := bc.calcNextRequiredDifficulty(bc.bestNode,
+				timestamp)
+			if err != nil {
+				t.Fatalf("%s: calcNextRequiredDifficulty: %v",
+					test.name, err)
+			}
+			gotBits, err := calc.NextRequiredDifficulty(timestamp)
+			if err != nil {
+				t.Fatalf("%s: NextRequiredDifficulty: %v",
+					test.name, err)
+			}
+			if gotBits != wantBits {
+				t.Fatalf("%s: mismatched difficulty at height %d "+
+					"-- got %08x, want %08x", test.name, i+1,
+					gotBits, wantBits)
+			}
+
+			wantSBits, err := bc.calcNextRequiredStakeDifficulty(
+				bc.bestNode)
+			if err != nil {
+				t.Fatalf("%s: calcNextRequiredStakeDifficulty: %v",
+					test.name, err)
+			}
+			gotSBits, err := calc.NextRequiredStakeDifficulty()
+			if err != nil {
+				t.Fatalf("%s: NextRequiredStakeDifficulty: %v",
+					test.name, err)
+			}
+			if gotSBits != wantSBits {
+				t.Fatalf("%s: mismatched stake difficulty at height "+
+					"%d -- got %d, want %d", test.name, i+1,
+					gotSBits, wantSBits)
+			}
+
+			// The pool size does not need to be consistent with the
+			// ticket purchases for the comparison.
+			freshStake := uint8(rng.Intn(int(params.MaxFreshStakePerBlock) + 1))
+			poolSize += uint32(rng.Intn(int(params.MaxFreshStakePerBlock) + 1))
+			header := &wire.BlockHeader{
+				Version:    4,
+				Bits:       gotBits,
+				SBits:      gotSBits,
+				Height:     uint32(i + 1),
+				Timestamp:  timestamp,
+				FreshStake: freshStake,
+				PoolSize:   poolSize,
+			}
+			if err := calc.AddHeader(header); err != nil {
+				t.Fatalf("%s: AddHeader: %v", test.name, err)
+			}
+			node := newBlockNode(header, nil, nil, nil)
+			node.parent = bc.bestNode
+			bc.bestNode = node
+		}
+
+		// Ensure only the most recent headers are kept in memory.
+		if len(calc.window) != calc.maxWindow {
+			t.Fatalf("%s: unexpected number of headers in memory -- "+
+				"got %d, want %d", test.name, len(calc.window),
+				calc.maxWindow)
+		}
+		if tip := calc.Tip(); tip.Height != numBlocks {
+			t.Fatalf("%s: unexpected tip height -- got %d, want %d",
+				test.name, tip.Height, numBlocks)
+		}
+
+		// Ensure headers which don't extend the tip are rejected.
+		header := calc.Tip()
+		if err := calc.AddHeader(&header); err == nil {
+			t.Fatalf("%s: AddHeader accepted a header at the tip "+
+				"height", test.name)
+		}
+	}
+}
@@ -59,7 +59,8 @@ const (
 	// exists.
 	ErrDuplicateBlock ErrorCode = iota
 
-	// ErrMissingParent indicates that the block was an orphan.
+	// ErrMissingParent indicates that the block or block header builds on
+	// a block which is not known, or whose data is not available yet.
 	ErrMissingParent
 
 	// ErrBlockTooBig indicates the serialized block size exceeds the
@@ -453,6 +454,29 @@ const (
 	// ErrInvalidEarlyVoteBits indicates that a block before stake validation
 	// height had an unallowed vote bits value.
 	ErrInvalidEarlyVoteBits
+
+	// ErrInvalidateGenesis indicates that an attempt was made to mark the
+	// genesis block invalid.
+	ErrInvalidateGenesis
+
+	// ErrUnknownBlock indicates that a block to invalidate or reconsider is
+	// neither in the memory block index nor part of the main chain.
+	ErrUnknownBlock
+
+	// ErrKnownInvalidBlock indicates that a block or block header is
+	// already known to be invalid or builds on a block which is.
+	ErrKnownInvalidBlock
+
+	// ErrForcedDeployment indicates that the state of a deployment can't
+	// be forced, either because the network is not the regression test
+	// network or because the requested state is not active or failed.
+	ErrForcedDeployment
+
+	// ErrLowWorkFork indicates block headers of a side chain which forks
+	// from the main chain too far below the best block were rejected since
+	// none of them has more work than the main chain.  This is a policy to
+	// limit the size of the block index rather than a consensus rule.
+	ErrLowWorkFork
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -553,6 +577,11 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrFraudBlockIndex:        "ErrFraudBlockIndex",
 	ErrZeroValueOutputSpend:   "ErrZeroValueOutputSpend",
 	ErrInvalidEarlyVoteBits:   "ErrInvalidEarlyVoteBits",
+	ErrInvalidateGenesis:      "ErrInvalidateGenesis",
+	ErrUnknownBlock:           "ErrUnknownBlock",
+	ErrKnownInvalidBlock:      "ErrKnownInvalidBlock",
+	ErrForcedDeployment:       "ErrForcedDeployment",
+	ErrLowWorkFork:            "ErrLowWorkFork",
 }
 
 // String returns the ErrorCode as a human-readable name.
@@ -55,6 +55,11 @@ func TestErrorCodeStringer(t *testing.T) {
 		{blockchain.ErrBadCoinbaseValue, "ErrBadCoinbaseValue"},
 		{blockchain.ErrScriptMalformed, "ErrScriptMalformed"},
 		{blockchain.ErrScriptValidation, "ErrScriptValidation"},
+		{blockchain.ErrInvalidateGenesis, "ErrInvalidateGenesis"},
+		{blockchain.ErrUnknownBlock, "ErrUnknownBlock"},
+		{blockchain.ErrKnownInvalidBlock, "ErrKnownInvalidBlock"},
+		{blockchain.ErrForcedDeployment, "ErrForcedDeployment"},
+		{blockchain.ErrLowWorkFork, "ErrLowWorkFork"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
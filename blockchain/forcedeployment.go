@@ -0,0 +1,126 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/wire"
+)
+
+// findDeployment returns the deployment with the given vote id from the chain
+// parameters along with the stake version it is defined for.
+func (b *BlockChain) findDeployment(deploymentID string) (*chaincfg.ConsensusDeployment, uint32, bool) {
+	for version, deployments := range b.chainParams.Deployments {
+		for k := range deployments {
+			if deployments[k].Vote.Id == deploymentID {
+				return &deployments[k], version, true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+// forcedStateTuple returns the threshold state tuple which is reported for a
+// deployment that is forced to the given state.  Active deployments report the
+// first choice that is neither abstain nor no, and failed deployments report
+// the no choice, which mirrors the choices a successful vote would have
+// selected.
+func forcedStateTuple(deployment *chaincfg.ConsensusDeployment, state ThresholdState) (ThresholdStateTuple, bool) {
+	for k, choice := range deployment.Vote.Choices {
+		switch state {
+		case ThresholdActive:
+			if !choice.IsAbstain && !choice.IsNo {
+				return newThresholdState(state, uint32(k)), true
+			}
+		case ThresholdFailed:
+			if choice.IsNo {
+				return newThresholdState(state, uint32(k)), true
+			}
+		}
+	}
+	return ThresholdStateTuple{}, false
+}
+
+// forceDeploymentState overrides the state of the given deployment.  See
+// ForceDeploymentState for details.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) forceDeploymentState(deploymentID string, state ThresholdState) error {
+	if b.chainParams.Net != wire.RegTest {
+		str := fmt.Sprintf("deployment states can't be forced on %s",
+			b.chainParams.Name)
+		return ruleError(ErrForcedDeployment, str)
+	}
+	if state != ThresholdActive && state != ThresholdFailed {
+		str := fmt.Sprintf("deployment %s can't be forced to state %v",
+			deploymentID, state)
+		return ruleError(ErrForcedDeployment, str)
+	}
+
+	deployment, _, ok := b.findDeployment(deploymentID)
+	if !ok {
+		return DeploymentError(deploymentID)
+	}
+	tuple, ok := forcedStateTuple(deployment, state)
+	if !ok {
+		str := fmt.Sprintf("deployment %s has no choice for state %v",
+			deploymentID, state)
+		return ruleError(ErrForcedDeployment, str)
+	}
+
+	if b.forcedDeployments == nil {
+		b.forcedDeployments = make(map[string]ThresholdStateTuple)
+	}
+	b.forcedDeployments[deploymentID] = tuple
+	return nil
+}
+
+// ForceDeploymentState overrides the state of the deployment with the given
+// vote id so that it is either active or failed for every block regardless of
+// the votes which were actually cast.  It is only allowed on the regression
+// test network, where it makes it possible to test consensus changes without
+// waiting for several rule change intervals to pass.
+//
+// The override only lives in memory and applies until it is removed with
+// ClearDeploymentState or the chain instance is discarded.  Blocks which were
+// already accepted are not checked again against the new state.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ForceDeploymentState(deploymentID string, state ThresholdState) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+	return b.forceDeploymentState(deploymentID, state)
+}
+
+// ClearDeploymentState removes the override of the deployment with the given
+// vote id, if any, so its state is once again determined by the votes.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ClearDeploymentState(deploymentID string) error {
+	if _, _, ok := b.findDeployment(deploymentID); !ok {
+		return DeploymentError(deploymentID)
+	}
+
+	b.chainLock.Lock()
+	delete(b.forcedDeployments, deploymentID)
+	b.chainLock.Unlock()
+	return nil
+}
+
+// ForcedDeploymentStates returns the deployments whose states are currently
+// overridden mapped to the state they were forced to.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ForcedDeploymentStates() map[string]ThresholdState {
+	b.chainLock.RLock()
+	states := make(map[string]ThresholdState, len(b.forcedDeployments))
+	for id, tuple := range b.forcedDeployments {
+		states[id] = tuple.State
+	}
+	b.chainLock.RUnlock()
+	return states
+}
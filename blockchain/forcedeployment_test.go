@@ -0,0 +1,95 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/coolsnady/hcd/chaincfg"
+)
+
+// TestForceDeploymentState ensures deployment states can only be forced on the
+// regression test network and that forced states override and are removed from
+// the voting state machine as expected.
+func TestForceDeploymentState(t *testing.T) {
+	const id = chaincfg.VoteIDMaxBlockSize
+	params := &chaincfg.RegNetParams
+	bc := newFakeChain(params)
+
+	// Ensure the deployment is not active without any votes.
+	state, err := bc.deploymentState(bc.bestNode, 4, id)
+	if err != nil {
+		t.Fatalf("deploymentState: unexpected error: %v", err)
+	}
+	if state.State == ThresholdActive || state.State == ThresholdFailed {
+		t.Fatalf("unexpected initial state %v", state)
+	}
+	initialState := state
+
+	// Ensure forcing the deployment active reports the yes choice.
+	if err := bc.ForceDeploymentState(id, ThresholdActive); err != nil {
+		t.Fatalf("ForceDeploymentState: unexpected error: %v", err)
+	}
+	state, err = bc.deploymentState(bc.bestNode, 4, id)
+	if err != nil {
+		t.Fatalf("deploymentState: unexpected error: %v", err)
+	}
+	want := newThresholdState(ThresholdActive, 2)
+	if state != want {
+		t.Fatalf("unexpected forced active state %+v, want %+v", state,
+			want)
+	}
+	if states := bc.ForcedDeploymentStates(); states[id] != ThresholdActive {
+		t.Fatalf("unexpected forced deployment states %v", states)
+	}
+
+	// Ensure forcing the deployment failed reports the no choice.
+	if err := bc.ForceDeploymentState(id, ThresholdFailed); err != nil {
+		t.Fatalf("ForceDeploymentState: unexpected error: %v", err)
+	}
+	state, err = bc.deploymentState(bc.bestNode, 4, id)
+	if err != nil {
+		t.Fatalf("deploymentState: unexpected error: %v", err)
+	}
+	want = newThresholdState(ThresholdFailed, 1)
+	if state != want {
+		t.Fatalf("unexpected forced failed state %+v, want %+v", state,
+			want)
+	}
+
+	// Ensure clearing the override restores the voted state.
+	if err := bc.ClearDeploymentState(id); err != nil {
+		t.Fatalf("ClearDeploymentState: unexpected error: %v", err)
+	}
+	state, err = bc.deploymentState(bc.bestNode, 4, id)
+	if err != nil {
+		t.Fatalf("deploymentState: unexpected error: %v", err)
+	}
+	if state != initialState {
+		t.Fatalf("unexpected state after clear %+v, want %+v", state,
+			initialState)
+	}
+	if states := bc.ForcedDeploymentStates(); len(states) != 0 {
+		t.Fatalf("unexpected forced deployment states %v", states)
+	}
+
+	// Ensure invalid requests are rejected.
+	err = bc.ForceDeploymentState(id, ThresholdLockedIn)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrForcedDeployment {
+		t.Fatalf("unexpected error forcing locked in state: %v", err)
+	}
+	err = bc.ForceDeploymentState("unknown", ThresholdActive)
+	if _, ok := err.(DeploymentError); !ok {
+		t.Fatalf("unexpected error forcing unknown deployment: %v", err)
+	}
+	if _, ok := bc.ClearDeploymentState("unknown").(DeploymentError); !ok {
+		t.Fatal("expected error clearing unknown deployment")
+	}
+	simChain := newFakeChain(&chaincfg.SimNetParams)
+	err = simChain.ForceDeploymentState(id, ThresholdActive)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrForcedDeployment {
+		t.Fatalf("unexpected error forcing simnet deployment: %v", err)
+	}
+}
@@ -48,26 +48,20 @@ func TestStakeVersion(t *testing.T) {
 		t.Logf("Testing block %s (hash %s, height %d)",
 			g.TipName(), block.Hash(), blockHeight)
 
-		isMainChain, isOrphan, err := chain.ProcessBlock(block,
-			blockchain.BFNone)
+		isMainChain, err := chain.ProcessBlock(block, blockchain.BFNone)
 		if err != nil {
 			t.Fatalf("block %q (hash %s, height %d) should "+
 				"have been accepted: %v", g.TipName(),
 				block.Hash(), blockHeight, err)
 		}
 
-		// Ensure the main chain and orphan flags match the values
-		// specified in the test.
+		// Ensure the main chain flag matches the value specified in the
+		// test.
 		if !isMainChain {
 			t.Fatalf("block %q (hash %s, height %d) unexpected main "+
 				"chain flag -- got %v, want true", g.TipName(),
 				block.Hash(), blockHeight, isMainChain)
 		}
-		if isOrphan {
-			t.Fatalf("block %q (hash %s, height %d) unexpected "+
-				"orphan flag -- got %v, want false", g.TipName(),
-				block.Hash(), blockHeight, isOrphan)
-		}
 	}
 	rejected := func(code blockchain.ErrorCode) {
 		msgBlock := g.Tip()
@@ -76,7 +70,7 @@ func TestStakeVersion(t *testing.T) {
 		t.Logf("Testing block %s (hash %s, height %d)", g.TipName(),
 			block.Hash(), blockHeight)
 
-		_, _, err := chain.ProcessBlock(block, blockchain.BFNone)
+		_, err := chain.ProcessBlock(block, blockchain.BFNone)
 		if err == nil {
 			t.Fatalf("block %q (hash %s, height %d) should not "+
 				"have been accepted", g.TipName(), block.Hash(),
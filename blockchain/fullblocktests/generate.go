@@ -57,13 +57,11 @@ type TestInstance interface {
 }
 
 // AcceptedBlock defines a test instance that expects a block to be accepted to
-// the blockchain either by extending the main chain, on a side chain, or as an
-// orphan.
+// the blockchain either by extending the main chain or on a side chain.
 type AcceptedBlock struct {
 	Name        string
 	Block       *wire.MsgBlock
 	IsMainChain bool
-	IsOrphan    bool
 }
 
 // Ensure AcceptedBlock implements the TestInstance interface.
@@ -92,12 +90,12 @@ var _ TestInstance = RejectedBlock{}
 // This implements the TestInstance interface.
 func (b RejectedBlock) FullBlockTestInstance() {}
 
-// OrphanOrRejectedBlock defines a test instance that expects a block to either
-// be accepted as an orphan or rejected.  This is useful since some
-// implementations might optimize the immediate rejection of orphan blocks when
-// their parent was previously rejected, while others might accept it as an
-// orphan that eventually gets flushed (since the parent can never be accepted
-// to ultimately link it).
+// OrphanOrRejectedBlock defines a test instance that expects a block to be
+// rejected, either because its parent is not available or due to a rule
+// violation.  This is useful since some implementations might reject blocks
+// which build on a previously rejected block as invalid, while others might
+// not have retained the rejected parent and reject them as building on an
+// unknown block instead.
 type OrphanOrRejectedBlock struct {
 	Name  string
 	Block *wire.MsgBlock
@@ -380,14 +378,14 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 	// provided block using a non-canonical encoded as described by the
 	// encodeNonCanonicalBlock function and expected it to be rejected.
 	//
-	// orphanOrRejectBlock creates a test instance that expected the
-	// provided block to either by accepted as an orphan or rejected by the
-	// consensus rules.
+	// orphanOrRejectBlock creates a test instance that expects the
+	// provided block to be rejected either due to a missing parent or by
+	// the consensus rules.
 	//
 	// expectTipBlock creates a test instance that expects the provided
 	// block to be the current tip of the block chain.
-	acceptBlock := func(blockName string, block *wire.MsgBlock, isMainChain, isOrphan bool) TestInstance {
-		return AcceptedBlock{blockName, block, isMainChain, isOrphan}
+	acceptBlock := func(blockName string, block *wire.MsgBlock, isMainChain bool) TestInstance {
+		return AcceptedBlock{blockName, block, isMainChain}
 	}
 	rejectBlock := func(blockName string, block *wire.MsgBlock, code blockchain.ErrorCode) TestInstance {
 		return RejectedBlock{blockName, block, code}
@@ -423,19 +421,16 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 	// rejectedNonCanonical creates and appends a single
 	// rejectNonCanonicalBlock test instance for the current tip.
 	//
-	// orphaned creates and appends a single acceptBlock test instance for
-	// the current tip which expects the block to be accepted as an orphan.
-	//
 	// orphanedOrRejected creates and appends a single orphanOrRejectBlock
 	// test instance for the current tip.
 	accepted := func() {
 		tests = append(tests, []TestInstance{
-			acceptBlock(g.TipName(), g.Tip(), true, false),
+			acceptBlock(g.TipName(), g.Tip(), true),
 		})
 	}
 	acceptedToSideChainWithExpectedTip := func(tipName string) {
 		tests = append(tests, []TestInstance{
-			acceptBlock(g.TipName(), g.Tip(), false, false),
+			acceptBlock(g.TipName(), g.Tip(), false),
 			expectTipBlock(tipName, g.BlockByName(tipName)),
 		})
 	}
@@ -449,11 +444,6 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 			rejectNonCanonicalBlock(g.TipName(), g.Tip()),
 		})
 	}
-	orphaned := func() {
-		tests = append(tests, []TestInstance{
-			acceptBlock(g.TipName(), g.Tip(), false, true),
-		})
-	}
 	orphanedOrRejected := func() {
 		tests = append(tests, []TestInstance{
 			orphanOrRejectBlock(g.TipName(), g.Tip()),
@@ -505,7 +495,7 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 		g.NextBlock(blockName, nil, nil)
 		g.SaveTipCoinbaseOuts()
 		testInstances = append(testInstances, acceptBlock(g.TipName(),
-			g.Tip(), true, false))
+			g.Tip(), true))
 	}
 	tests = append(tests, testInstances)
 	g.AssertTipHeight(uint32(coinbaseMaturity) + 1)
@@ -528,7 +518,7 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 		g.NextBlock(blockName, nil, ticketOuts)
 		g.SaveTipCoinbaseOuts()
 		testInstances = append(testInstances, acceptBlock(g.TipName(),
-			g.Tip(), true, false))
+			g.Tip(), true))
 	}
 	tests = append(tests, testInstances)
 	g.AssertTipHeight(uint32(stakeEnabledHeight))
@@ -593,7 +583,7 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 		g.NextBlock(blockName, nil, ticketOuts)
 		g.SaveTipCoinbaseOuts()
 		testInstances = append(testInstances, acceptBlock(g.TipName(),
-			g.Tip(), true, false))
+			g.Tip(), true))
 	}
 	tests = append(tests, testInstances)
 	g.AssertTipHeight(uint32(stakeValidationHeight))
@@ -613,7 +603,7 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 		g.NextBlock(blockName, nil, outs[1:])
 		g.SaveTipCoinbaseOuts()
 		testInstances = append(testInstances, acceptBlock(g.TipName(),
-			g.Tip(), true, false))
+			g.Tip(), true))
 	}
 	tests = append(tests, testInstances)
 
@@ -735,16 +725,20 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 	//
 	//   ... -> b1(0) -> b2(1) -> b5(2) -> b6(3)
 	//              |                  \-> b12(3) -> b13(4) -> b14(5)
-	//              |                      (b12 added last)
+	//              |                      (b13 offered first)
 	//               \-> b3(1) -> b4(2)
+	//
+	// Blocks which build on a block that is not available are not retained,
+	// so b13 is rejected until b12 was accepted.
 	g.SetTip("b5")
 	b12 := g.NextBlock("b12", outs[3], ticketOuts[3])
 	b13 := g.NextBlock("b13", outs[4], ticketOuts[4])
 	b14 := g.NextBlock("b14", outs[5], ticketOuts[5], additionalCoinbasePoW(1))
 	tests = append(tests, []TestInstance{
-		acceptBlock("b13", b13, false, true),
-		acceptBlock("b14", b14, false, true),
-		rejectBlock("b12", b12, blockchain.ErrBadCoinbaseValue),
+		rejectBlock("b13", b13, blockchain.ErrMissingParent),
+		acceptBlock("b12", b12, false),
+		acceptBlock("b13", b13, true),
+		rejectBlock("b14", b14, blockchain.ErrBadCoinbaseValue),
 		expectTipBlock("b13", b13),
 	})
 
@@ -823,7 +817,7 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 	//
 	//   ... -> b5(2) -> b12(3) -> b13(4)
 	//   \                     \-> b18(4) -> b19(5) -> b20(6)
-	//   |                         (b18 added last)
+	//   |                         (b19 offered first)
 	//    \-> b3(1) -> b4(2)
 	//
 	g.SetTip("b12")
@@ -831,9 +825,10 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 	b19 := g.NextBlock("b19", outs[5], ticketOuts[5])
 	b20 := g.NextBlock("b20", outs[6], ticketOuts[6], additionalCoinbaseDev(1))
 	tests = append(tests, []TestInstance{
-		acceptBlock("b19", b19, false, true),
-		acceptBlock("b20", b20, false, true),
-		rejectBlock("b18", b18, blockchain.ErrNoTax),
+		rejectBlock("b19", b19, blockchain.ErrMissingParent),
+		acceptBlock("b18", b18, false),
+		acceptBlock("b19", b19, true),
+		rejectBlock("b20", b20, blockchain.ErrNoTax),
 		expectTipBlock("b19", b19),
 	})
 
@@ -952,7 +947,9 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 	// Orphan tests.
 	// ---------------------------------------------------------------------
 
-	// Create valid orphan block with zero prev hash.
+	// Create otherwise valid orphan block with zero prev hash.  Orphan
+	// blocks are rejected since they build on a block that is not
+	// available.
 	//
 	//   No previous block
 	//                    \-> borphan0(7)
@@ -960,19 +957,21 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 	g.NextBlock("borphan0", outs[7], ticketOuts[7], func(b *wire.MsgBlock) {
 		b.Header.PrevBlock = chainhash.Hash{}
 	})
-	orphaned()
+	rejected(blockchain.ErrMissingParent)
 
-	// Create valid orphan block.
+	// Create otherwise valid orphan block.
 	//
 	//   ... -> b21(6) -> b29(7)
 	//                \-> borphanbase(7) -> borphan1(8)
 	g.SetTip("b21")
 	g.NextBlock("borphanbase", outs[7], ticketOuts[7])
 	g.NextBlock("borphan1", outs[8], ticketOuts[8])
-	orphaned()
+	rejected(blockchain.ErrMissingParent)
 
-	// Ensure duplicate orphan blocks are rejected.
-	rejected(blockchain.ErrDuplicateBlock)
+	// Ensure orphan blocks are not retained, so offering the same orphan
+	// block again is rejected for the same reason instead of as a
+	// duplicate.
+	rejected(blockchain.ErrMissingParent)
 
 	// ---------------------------------------------------------------------
 	// Coinbase script length limits tests.
@@ -2138,7 +2137,7 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 		g.AssertTipBlockSize(maxBlockSize)
 		g.SaveTipCoinbaseOuts()
 		testInstances = append(testInstances, acceptBlock(g.TipName(),
-			g.Tip(), true, false))
+			g.Tip(), true))
 
 		// Use the next available spendable output.  First use up any
 		// remaining spendable outputs that were already popped into the
@@ -2175,7 +2174,7 @@ func Generate(includeLargeReorg bool) (tests [][]TestInstance, err error) {
 		g.NextBlock(chain2TipName, &reorgSpend, reorgTicketSpends)
 		g.SaveTipCoinbaseOuts()
 		testInstances = append(testInstances, acceptBlock(g.TipName(),
-			g.Tip(), false, false))
+			g.Tip(), false))
 
 		// Use the next available spendable output.  First use up any
 		// remaining spendable outputs that were already popped into the
@@ -0,0 +1,892 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/blockchain/stake"
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/txscript"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
+)
+
+const (
+	// addrUtxoIndexName is the human-readable name for the index.
+	addrUtxoIndexName = "address utxo index"
+
+	// addrUtxoPrefix is the prefix of the keys which identify unspent
+	// outputs in the address utxo index bucket.
+	addrUtxoPrefix = 'u'
+
+	// addrDeltaPrefix is the prefix of the keys which identify balance
+	// changes in the address utxo index bucket.
+	addrDeltaPrefix = 'd'
+
+	// addrUtxoKeySize is the size of the unspent output keys.  It consists
+	// of the prefix, the address key and the hash, index and tree of the
+	// outpoint.
+	addrUtxoKeySize = 1 + addrKeySize + chainhash.HashSize + 4 + 1
+
+	// addrUtxoValueMinSize is the minimum size of the serialized unspent
+	// output values.  It consists of the amount, block height, transaction
+	// type and script version followed by the variable length public key
+	// script.
+	addrUtxoValueMinSize = 8 + 4 + 1 + 2
+
+	// addrDeltaKeySize is the size of the balance change keys.  It consists
+	// of the prefix, the address key, the block height, the tree and index
+	// of the transaction within the block, whether or not the change is a
+	// spend and the index of the input or output.
+	addrDeltaKeySize = 1 + addrKeySize + 4 + 1 + 4 + 1 + 4
+
+	// addrDeltaValueSize is the size of the serialized balance change
+	// values.  It consists of the transaction hash, the signed amount and
+	// the block height and transaction type of the spent output.
+	addrDeltaValueSize = chainhash.HashSize + 8 + 4 + 1
+)
+
+var (
+	// addrUtxoIndexKey is the key of the address utxo index and the db
+	// bucket used to house it.
+	addrUtxoIndexKey = []byte("addrutxoidx")
+)
+
+// -----------------------------------------------------------------------------
+// The address utxo index maps addresses referenced in the blockchain to the
+// outputs paying to them which are currently unspent along with every change
+// to their balance.  This includes stake outputs such as tickets, ticket change
+// and the outputs of votes and revocations.  Similar to the address index, it
+// requires the transaction index since it is needed in order to catch up old
+// blocks due to the fact the spent outputs will already be pruned from the utxo
+// set.
+//
+// Both kinds of entries are stored in the same bucket and are distinguished by
+// a one byte prefix.  Since all numeric fields of the keys are big endian, the
+// entries for an address are stored contiguously and the balance changes are
+// ordered by their order of appearance in the blockchain.
+//
+// The serialized format for the unspent output entries is:
+//
+//   <'u'><addr key><outpoint hash><outpoint index><outpoint tree> =
+//     <amount><block height><tx type><script version><pkscript>
+//
+//   Field           Type              Size
+//   prefix          byte              1 byte
+//   addr key        [21]byte          21 bytes
+//   outpoint hash   chainhash.Hash    32 bytes
+//   outpoint index  uint32            4 bytes
+//   outpoint tree   int8              1 byte
+//   amount          int64             8 bytes
+//   block height    uint32            4 bytes
+//   tx type         uint8             1 byte
+//   script version  uint16            2 bytes
+//   pkscript        []byte            variable
+//
+// The serialized format for the balance change entries is:
+//
+//   <'d'><addr key><block height><tx tree><tx index><spend flag><io index> =
+//     <txhash><amount><spent block height><spent tx type>
+//
+//   Field              Type              Size
+//   prefix             byte              1 byte
+//   addr key           [21]byte          21 bytes
+//   block height       uint32            4 bytes
+//   tx tree            int8              1 byte
+//   tx index           uint32            4 bytes
+//   spend flag         bool              1 byte
+//   io index           uint32            4 bytes
+//   txhash             chainhash.Hash    32 bytes
+//   amount             int64             8 bytes
+//   spent block height uint32            4 bytes
+//   spent tx type      uint8             1 byte
+//
+// The amount of balance changes which spend an output is negative.  The block
+// height and transaction type of the spent output are only set for them and
+// are used to restore the unspent output entry when the block is disconnected.
+// -----------------------------------------------------------------------------
+
+// AddrUtxo describes an unspent output paying to an address.
+type AddrUtxo struct {
+	OutPoint      wire.OutPoint
+	Amount        int64
+	Height        int64
+	TxType        stake.TxType
+	ScriptVersion uint16
+	PkScript      []byte
+}
+
+// AddrDelta describes a change to the balance of an address caused by a
+// transaction which either creates an output paying to the address or spends
+// one.  The index is the output index for the former and the input index for
+// the latter, and the amount is negative when an output is spent.
+type AddrDelta struct {
+	TxHash chainhash.Hash
+	Tree   int8
+	Index  uint32
+	Spend  bool
+	Amount int64
+	Height int64
+}
+
+// AddrBalance describes the balance of an address and the total amount it has
+// ever received.
+type AddrBalance struct {
+	Balance  int64
+	Received int64
+}
+
+// addrUtxoKey returns the unspent output key for the passed address key and
+// outpoint.
+func addrUtxoKey(addrKey [addrKeySize]byte, outpoint *wire.OutPoint) [addrUtxoKeySize]byte {
+	var key [addrUtxoKeySize]byte
+	key[0] = addrUtxoPrefix
+	offset := 1 + copy(key[1:], addrKey[:])
+	offset += copy(key[offset:], outpoint.Hash[:])
+	binary.BigEndian.PutUint32(key[offset:], outpoint.Index)
+	key[addrUtxoKeySize-1] = byte(outpoint.Tree)
+	return key
+}
+
+// serializeAddrUtxo returns the serialized unspent output value for the passed
+// unspent output.  The outpoint is not serialized since it is part of the key.
+func serializeAddrUtxo(utxo *AddrUtxo) []byte {
+	serialized := make([]byte, addrUtxoValueMinSize+len(utxo.PkScript))
+	byteOrder.PutUint64(serialized, uint64(utxo.Amount))
+	byteOrder.PutUint32(serialized[8:], uint32(utxo.Height))
+	serialized[12] = byte(utxo.TxType)
+	byteOrder.PutUint16(serialized[13:], utxo.ScriptVersion)
+	copy(serialized[addrUtxoValueMinSize:], utxo.PkScript)
+	return serialized
+}
+
+// deserializeAddrUtxo decodes the passed unspent output key and value.
+func deserializeAddrUtxo(key, serialized []byte) (*AddrUtxo, error) {
+	if len(key) != addrUtxoKeySize {
+		return nil, errDeserialize(fmt.Sprintf("unexpected address "+
+			"utxo key length %d", len(key)))
+	}
+	if len(serialized) < addrUtxoValueMinSize {
+		return nil, errDeserialize(fmt.Sprintf("unexpected address "+
+			"utxo entry length %d", len(serialized)))
+	}
+
+	var utxo AddrUtxo
+	offset := 1 + addrKeySize
+	offset += copy(utxo.OutPoint.Hash[:], key[offset:])
+	utxo.OutPoint.Index = binary.BigEndian.Uint32(key[offset:])
+	utxo.OutPoint.Tree = int8(key[addrUtxoKeySize-1])
+	utxo.Amount = int64(byteOrder.Uint64(serialized))
+	utxo.Height = int64(byteOrder.Uint32(serialized[8:]))
+	utxo.TxType = stake.TxType(serialized[12])
+	utxo.ScriptVersion = byteOrder.Uint16(serialized[13:])
+	utxo.PkScript = make([]byte, len(serialized)-addrUtxoValueMinSize)
+	copy(utxo.PkScript, serialized[addrUtxoValueMinSize:])
+	return &utxo, nil
+}
+
+// addrDeltaKey returns the balance change key for the passed address key and
+// location of the input or output within the blockchain.
+func addrDeltaKey(addrKey [addrKeySize]byte, height int64, tree int8, txIdx int, spend bool, ioIdx uint32) [addrDeltaKeySize]byte {
+	var key [addrDeltaKeySize]byte
+	key[0] = addrDeltaPrefix
+	offset := 1 + copy(key[1:], addrKey[:])
+	binary.BigEndian.PutUint32(key[offset:], uint32(height))
+	offset += 4
+	key[offset] = byte(tree)
+	offset++
+	binary.BigEndian.PutUint32(key[offset:], uint32(txIdx))
+	offset += 4
+	if spend {
+		key[offset] = 1
+	}
+	offset++
+	binary.BigEndian.PutUint32(key[offset:], ioIdx)
+	return key
+}
+
+// serializeAddrDelta returns the serialized balance change value for the passed
+// details.  The spent height and transaction type are only relevant when the
+// change spends an output.
+func serializeAddrDelta(txHash *chainhash.Hash, amount int64, spentHeight int64, spentTxType stake.TxType) []byte {
+	serialized := make([]byte, addrDeltaValueSize)
+	offset := copy(serialized, txHash[:])
+	byteOrder.PutUint64(serialized[offset:], uint64(amount))
+	offset += 8
+	byteOrder.PutUint32(serialized[offset:], uint32(spentHeight))
+	offset += 4
+	serialized[offset] = byte(spentTxType)
+	return serialized
+}
+
+// deserializeAddrDelta decodes the passed balance change key and value.  It
+// also returns the block height and transaction type of the spent output.
+func deserializeAddrDelta(key, serialized []byte) (*AddrDelta, int64, stake.TxType, error) {
+	if len(key) != addrDeltaKeySize {
+		return nil, 0, 0, errDeserialize(fmt.Sprintf("unexpected "+
+			"address delta key length %d", len(key)))
+	}
+	if len(serialized) != addrDeltaValueSize {
+		return nil, 0, 0, errDeserialize(fmt.Sprintf("unexpected "+
+			"address delta entry length %d", len(serialized)))
+	}
+
+	var delta AddrDelta
+	offset := 1 + addrKeySize
+	delta.Height = int64(binary.BigEndian.Uint32(key[offset:]))
+	offset += 4
+	delta.Tree = int8(key[offset])
+	offset += 1 + 4
+	delta.Spend = key[offset] != 0
+	offset++
+	delta.Index = binary.BigEndian.Uint32(key[offset:])
+
+	offset = copy(delta.TxHash[:], serialized)
+	delta.Amount = int64(byteOrder.Uint64(serialized[offset:]))
+	offset += 8
+	spentHeight := int64(byteOrder.Uint32(serialized[offset:]))
+	offset += 4
+	spentTxType := stake.TxType(serialized[offset])
+	return &delta, spentHeight, spentTxType, nil
+}
+
+// addrKeysForScript returns the address keys for all of the supported addresses
+// the passed public key script pays to.
+func addrKeysForScript(scriptVersion uint16, pkScript []byte, params *chaincfg.Params) [][addrKeySize]byte {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(scriptVersion, pkScript,
+		params)
+	if err != nil {
+		return nil
+	}
+
+	addrKeys := make([][addrKeySize]byte, 0, len(addrs))
+	for _, addr := range addrs {
+		addrKey, err := addrToKey(addr, params)
+		if err != nil {
+			// Ignore unsupported address types.
+			continue
+		}
+		addrKeys = append(addrKeys, addrKey)
+	}
+	return addrKeys
+}
+
+// dbPutAddrUtxoIndexEntries updates the passed bucket for the transactions
+// applied when connecting the passed block.  The unspent output entries for
+// every output spent by the transactions are removed, the entries for every
+// output they create are added, and a balance change entry is added for each
+// of them.
+func dbPutAddrUtxoIndexEntries(bucket internalBucket, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint, params *chaincfg.Params) error {
+	for _, at := range appliedTxns(block, parent) {
+		msgTx := at.tx.MsgTx()
+		txHash := at.tx.Hash()
+		height := at.block.Height()
+		for txInIdx, txIn := range msgTx.TxIn {
+			// Coinbase and stakebase inputs do not spend anything.
+			prevOut := &txIn.PreviousOutPoint
+			if prevOut.Hash == zeroHash {
+				continue
+			}
+
+			// The view should always have the input since the index
+			// contract requires it, however, be safe and simply
+			// ignore any missing entries.
+			entry := view.LookupEntry(&prevOut.Hash)
+			if entry == nil {
+				log.Warnf("Missing input %v for tx %v while "+
+					"indexing block %v (height %v)", prevOut.Hash,
+					txHash, block.Hash(), block.Height())
+				continue
+			}
+
+			version := entry.ScriptVersionByIndex(prevOut.Index)
+			pkScript := entry.PkScriptByIndex(prevOut.Index)
+			for _, addrKey := range addrKeysForScript(version, pkScript,
+				params) {
+
+				// Prefer the details of the existing unspent
+				// output entry since the view might not have the
+				// original block height during catch up.
+				amount := entry.AmountByIndex(prevOut.Index)
+				spentHeight := entry.BlockHeight()
+				spentTxType := entry.TransactionType()
+				utxoKey := addrUtxoKey(addrKey, prevOut)
+				serialized := bucket.Get(utxoKey[:])
+				if serialized != nil {
+					utxo, err := deserializeAddrUtxo(utxoKey[:],
+						serialized)
+					if err != nil {
+						return err
+					}
+					amount = utxo.Amount
+					spentHeight = utxo.Height
+					spentTxType = utxo.TxType
+				}
+				if err := bucket.Delete(utxoKey[:]); err != nil {
+					return err
+				}
+
+				deltaKey := addrDeltaKey(addrKey, height, at.tree,
+					at.index, true, uint32(txInIdx))
+				delta := serializeAddrDelta(txHash, -amount,
+					spentHeight, spentTxType)
+				if err := bucket.Put(deltaKey[:], delta); err != nil {
+					return err
+				}
+			}
+		}
+
+		txType := stake.DetermineTxType(msgTx)
+		for txOutIdx, txOut := range msgTx.TxOut {
+			outpoint := wire.OutPoint{Hash: *txHash,
+				Index: uint32(txOutIdx), Tree: at.tree}
+			utxo := serializeAddrUtxo(&AddrUtxo{
+				Amount:        txOut.Value,
+				Height:        height,
+				TxType:        txType,
+				ScriptVersion: txOut.Version,
+				PkScript:      txOut.PkScript,
+			})
+			for _, addrKey := range addrKeysForScript(txOut.Version,
+				txOut.PkScript, params) {
+
+				utxoKey := addrUtxoKey(addrKey, &outpoint)
+				if err := bucket.Put(utxoKey[:], utxo); err != nil {
+					return err
+				}
+
+				deltaKey := addrDeltaKey(addrKey, height, at.tree,
+					at.index, false, uint32(txOutIdx))
+				delta := serializeAddrDelta(txHash, txOut.Value, 0, 0)
+				if err := bucket.Put(deltaKey[:], delta); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dbRemoveAddrUtxoIndexEntries undoes the changes made to the passed bucket by
+// dbPutAddrUtxoIndexEntries for the passed block.  The transactions are undone
+// in reverse order so outputs created and spent within the same block are
+// handled properly.
+func dbRemoveAddrUtxoIndexEntries(bucket internalBucket, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint, params *chaincfg.Params) error {
+	txns := appliedTxns(block, parent)
+	for i := len(txns) - 1; i >= 0; i-- {
+		at := txns[i]
+		msgTx := at.tx.MsgTx()
+		txHash := at.tx.Hash()
+		height := at.block.Height()
+		for txOutIdx, txOut := range msgTx.TxOut {
+			outpoint := wire.OutPoint{Hash: *txHash,
+				Index: uint32(txOutIdx), Tree: at.tree}
+			for _, addrKey := range addrKeysForScript(txOut.Version,
+				txOut.PkScript, params) {
+
+				utxoKey := addrUtxoKey(addrKey, &outpoint)
+				if err := bucket.Delete(utxoKey[:]); err != nil {
+					return err
+				}
+				deltaKey := addrDeltaKey(addrKey, height, at.tree,
+					at.index, false, uint32(txOutIdx))
+				if err := bucket.Delete(deltaKey[:]); err != nil {
+					return err
+				}
+			}
+		}
+
+		for txInIdx, txIn := range msgTx.TxIn {
+			prevOut := &txIn.PreviousOutPoint
+			if prevOut.Hash == zeroHash {
+				continue
+			}
+
+			entry := view.LookupEntry(&prevOut.Hash)
+			if entry == nil {
+				log.Warnf("Missing input %v for tx %v while "+
+					"unindexing block %v (height %v)",
+					prevOut.Hash, txHash, block.Hash(),
+					block.Height())
+				continue
+			}
+
+			version := entry.ScriptVersionByIndex(prevOut.Index)
+			pkScript := entry.PkScriptByIndex(prevOut.Index)
+			for _, addrKey := range addrKeysForScript(version, pkScript,
+				params) {
+
+				// Restore the unspent output entry from the
+				// details stored in the balance change entry
+				// when available.
+				utxo := AddrUtxo{
+					Amount:        entry.AmountByIndex(prevOut.Index),
+					Height:        entry.BlockHeight(),
+					TxType:        entry.TransactionType(),
+					ScriptVersion: version,
+					PkScript:      pkScript,
+				}
+				deltaKey := addrDeltaKey(addrKey, height, at.tree,
+					at.index, true, uint32(txInIdx))
+				serialized := bucket.Get(deltaKey[:])
+				if serialized != nil {
+					delta, spentHeight, spentTxType, err :=
+						deserializeAddrDelta(deltaKey[:],
+							serialized)
+					if err != nil {
+						return err
+					}
+					utxo.Amount = -delta.Amount
+					utxo.Height = spentHeight
+					utxo.TxType = spentTxType
+				}
+				if err := bucket.Delete(deltaKey[:]); err != nil {
+					return err
+				}
+
+				utxoKey := addrUtxoKey(addrKey, prevOut)
+				err := bucket.Put(utxoKey[:], serializeAddrUtxo(&utxo))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dbFetchAddrUtxos returns the unspent outputs paying to the passed address key
+// according to the specified number to skip and number requested using the
+// passed cursor over the address utxo index bucket.  The outputs in the passed
+// set of outpoints to exclude are neither returned nor counted as skipped.  It
+// also returns the number actually skipped since it could be less in the case
+// where there are not enough entries.
+func dbFetchAddrUtxos(cursor database.Cursor, addrKey [addrKeySize]byte, numToSkip, numRequested uint32, exclude map[wire.OutPoint]struct{}) ([]AddrUtxo, uint32, error) {
+	prefix := append([]byte{addrUtxoPrefix}, addrKey[:]...)
+	var utxos []AddrUtxo
+	var skipped uint32
+	for ok := cursor.Seek(prefix); ok && bytes.HasPrefix(cursor.Key(),
+		prefix); ok = cursor.Next() {
+
+		if uint32(len(utxos)) >= numRequested {
+			break
+		}
+
+		utxo, err := deserializeAddrUtxo(cursor.Key(), cursor.Value())
+		if err != nil {
+			return nil, 0, err
+		}
+		if _, ok := exclude[utxo.OutPoint]; ok {
+			continue
+		}
+		if skipped < numToSkip {
+			skipped++
+			continue
+		}
+		utxos = append(utxos, *utxo)
+	}
+	return utxos, skipped, nil
+}
+
+// dbFetchAddrDeltas returns the balance changes of the passed address key in
+// the order they appear in the blockchain according to the specified number to
+// skip and number requested using the passed cursor over the address utxo index
+// bucket.  It also returns the number actually skipped since it could be less
+// in the case where there are not enough entries.
+func dbFetchAddrDeltas(cursor database.Cursor, addrKey [addrKeySize]byte, numToSkip, numRequested uint32) ([]AddrDelta, uint32, error) {
+	prefix := append([]byte{addrDeltaPrefix}, addrKey[:]...)
+	var deltas []AddrDelta
+	var skipped uint32
+	for ok := cursor.Seek(prefix); ok && bytes.HasPrefix(cursor.Key(),
+		prefix); ok = cursor.Next() {
+
+		if uint32(len(deltas)) >= numRequested {
+			break
+		}
+		if skipped < numToSkip {
+			skipped++
+			continue
+		}
+
+		delta, _, _, err := deserializeAddrDelta(cursor.Key(),
+			cursor.Value())
+		if err != nil {
+			return nil, 0, err
+		}
+		deltas = append(deltas, *delta)
+	}
+	return deltas, skipped, nil
+}
+
+// dbFetchAddrBalance returns the balance of the passed address key and the
+// total amount it has ever received using the passed cursor over the address
+// utxo index bucket.
+func dbFetchAddrBalance(cursor database.Cursor, addrKey [addrKeySize]byte) (*AddrBalance, error) {
+	var balance AddrBalance
+	prefix := append([]byte{addrUtxoPrefix}, addrKey[:]...)
+	for ok := cursor.Seek(prefix); ok && bytes.HasPrefix(cursor.Key(),
+		prefix); ok = cursor.Next() {
+
+		if len(cursor.Value()) < addrUtxoValueMinSize {
+			return nil, errDeserialize(fmt.Sprintf("unexpected "+
+				"address utxo entry length %d",
+				len(cursor.Value())))
+		}
+		balance.Balance += int64(byteOrder.Uint64(cursor.Value()))
+	}
+
+	prefix[0] = addrDeltaPrefix
+	for ok := cursor.Seek(prefix); ok && bytes.HasPrefix(cursor.Key(),
+		prefix); ok = cursor.Next() {
+
+		delta, _, _, err := deserializeAddrDelta(cursor.Key(),
+			cursor.Value())
+		if err != nil {
+			return nil, err
+		}
+		if !delta.Spend {
+			balance.Received += delta.Amount
+		}
+	}
+	return &balance, nil
+}
+
+// AddrUtxoIndex implements an address balance and unspent output index.  That
+// is to say, it supports querying the unspent outputs paying to a given address
+// as well as its balance and every change to it without having to fetch and
+// decode all of the transactions involving the address.
+//
+// In addition, support is provided for a memory-only index of the balance
+// changes caused by unconfirmed transactions such as those which are kept in
+// the memory pool before inclusion in a block.
+type AddrUtxoIndex struct {
+	// The following fields are set when the instance is created and can't
+	// be changed afterwards, so there is no need to protect them with a
+	// separate mutex.
+	db          database.DB
+	chainParams *chaincfg.Params
+
+	// The following fields are used to quickly look up the balance
+	// changes of addresses caused by transactions that have not been
+	// included into a block yet.  They are protected by the
+	// unconfirmedLock field.
+	//
+	// The deltasByAddr field keeps the balance changes keyed by the
+	// address and the transaction which causes them.
+	//
+	// The addrsByTx field is essentially the reverse and is used to
+	// efficiently remove the changes once the transactions are removed.
+	unconfirmedLock sync.RWMutex
+	deltasByAddr    map[[addrKeySize]byte]map[chainhash.Hash][]AddrDelta
+	addrsByTx       map[chainhash.Hash]map[[addrKeySize]byte]struct{}
+}
+
+// Ensure the AddrUtxoIndex type implements the Indexer interface.
+var _ Indexer = (*AddrUtxoIndex)(nil)
+
+// Ensure the AddrUtxoIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*AddrUtxoIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *AddrUtxoIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *AddrUtxoIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *AddrUtxoIndex) Key() []byte {
+	return addrUtxoIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *AddrUtxoIndex) Name() string {
+	return addrUtxoIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the address
+// utxo index.
+//
+// This is part of the Indexer interface.
+func (idx *AddrUtxoIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(addrUtxoIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer removes the entries for the
+// outputs spent by the transactions the block applies and adds entries for the
+// outputs they create along with the resulting balance changes.
+//
+// This is part of the Indexer interface.
+func (idx *AddrUtxoIndex) ConnectBlock(dbTx database.Tx, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(addrUtxoIndexKey)
+	return dbPutAddrUtxoIndexEntries(bucket, block, parent, view,
+		idx.chainParams)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer restores the entries for the
+// outputs spent by the transactions the block applied and removes the entries
+// for the outputs they created along with the resulting balance changes.
+//
+// This is part of the Indexer interface.
+func (idx *AddrUtxoIndex) DisconnectBlock(dbTx database.Tx, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(addrUtxoIndexKey)
+	return dbRemoveAddrUtxoIndexEntries(bucket, block, parent, view,
+		idx.chainParams)
+}
+
+// UtxosForAddress returns the unspent outputs in the main chain which pay to
+// the passed address according to the specified number to skip and number
+// requested.  The outputs in the passed set of outpoints to exclude, such as
+// the ones spent by unconfirmed transactions, are neither returned nor counted
+// as skipped.  It also returns the number actually skipped since it could be
+// less in the case where there are not enough entries.
+//
+// NOTE: These results do not take unconfirmed transactions into account.  See
+// the UnconfirmedDeltasForAddress method for obtaining the balance changes
+// caused by them.
+//
+// This function is safe for concurrent access.
+func (idx *AddrUtxoIndex) UtxosForAddress(addr hcutil.Address, numToSkip, numRequested uint32, exclude map[wire.OutPoint]struct{}) ([]AddrUtxo, uint32, error) {
+	addrKey, err := addrToKey(addr, idx.chainParams)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var utxos []AddrUtxo
+	var skipped uint32
+	err = idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(addrUtxoIndexKey).Cursor()
+		var err error
+		utxos, skipped, err = dbFetchAddrUtxos(cursor, addrKey,
+			numToSkip, numRequested, exclude)
+		return err
+	})
+	return utxos, skipped, err
+}
+
+// DeltasForAddress returns the changes to the balance of the passed address in
+// the main chain in the order they appear in the blockchain according to the
+// specified number to skip and number requested.  It also returns the number
+// actually skipped since it could be less in the case where there are not
+// enough entries.
+//
+// NOTE: These results only include changes confirmed in blocks.  See the
+// UnconfirmedDeltasForAddress method for obtaining unconfirmed changes.
+//
+// This function is safe for concurrent access.
+func (idx *AddrUtxoIndex) DeltasForAddress(addr hcutil.Address, numToSkip, numRequested uint32) ([]AddrDelta, uint32, error) {
+	addrKey, err := addrToKey(addr, idx.chainParams)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var deltas []AddrDelta
+	var skipped uint32
+	err = idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(addrUtxoIndexKey).Cursor()
+		var err error
+		deltas, skipped, err = dbFetchAddrDeltas(cursor, addrKey,
+			numToSkip, numRequested)
+		return err
+	})
+	return deltas, skipped, err
+}
+
+// BalanceForAddress returns the balance of the passed address in the main chain
+// along with the total amount it has ever received.
+//
+// This function is safe for concurrent access.
+func (idx *AddrUtxoIndex) BalanceForAddress(addr hcutil.Address) (*AddrBalance, error) {
+	addrKey, err := addrToKey(addr, idx.chainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	var balance *AddrBalance
+	err = idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(addrUtxoIndexKey).Cursor()
+		var err error
+		balance, err = dbFetchAddrBalance(cursor, addrKey)
+		return err
+	})
+	return balance, err
+}
+
+// addUnconfirmedDelta adds the passed balance change to the unconfirmed
+// (memory-only) index for every supported address the passed public key script
+// pays to.
+//
+// This function MUST be called with the unconfirmed lock held (for writes).
+func (idx *AddrUtxoIndex) addUnconfirmedDelta(scriptVersion uint16, pkScript []byte, delta AddrDelta) {
+	for _, addrKey := range addrKeysForScript(scriptVersion, pkScript,
+		idx.chainParams) {
+
+		deltas := idx.deltasByAddr[addrKey]
+		if deltas == nil {
+			deltas = make(map[chainhash.Hash][]AddrDelta)
+			idx.deltasByAddr[addrKey] = deltas
+		}
+		deltas[delta.TxHash] = append(deltas[delta.TxHash], delta)
+
+		addrs := idx.addrsByTx[delta.TxHash]
+		if addrs == nil {
+			addrs = make(map[[addrKeySize]byte]struct{})
+			idx.addrsByTx[delta.TxHash] = addrs
+		}
+		addrs[addrKey] = struct{}{}
+	}
+}
+
+// AddUnconfirmedTx adds the balance changes caused by the passed transaction to
+// the unconfirmed (memory-only) index.
+//
+// NOTE: This transaction MUST have already been validated by the memory pool
+// before calling this function with it and have all of the inputs available in
+// the provided utxo view.  Failure to do so could result in some or all
+// changes not being indexed.
+//
+// This function is safe for concurrent access.
+func (idx *AddrUtxoIndex) AddUnconfirmedTx(tx *hcutil.Tx, utxoView *blockchain.UtxoViewpoint) {
+	msgTx := tx.MsgTx()
+	tree := wire.TxTreeRegular
+	if stake.DetermineTxType(msgTx) != stake.TxTypeRegular {
+		tree = wire.TxTreeStake
+	}
+
+	idx.unconfirmedLock.Lock()
+	defer idx.unconfirmedLock.Unlock()
+
+	for i, txIn := range msgTx.TxIn {
+		// Skip stakebases.
+		prevOut := &txIn.PreviousOutPoint
+		if prevOut.Hash == zeroHash {
+			continue
+		}
+
+		// Ignore missing entries.  This should never happen in practice
+		// since the function comments specifically call out all inputs
+		// must be available.
+		entry := utxoView.LookupEntry(&prevOut.Hash)
+		if entry == nil {
+			continue
+		}
+		idx.addUnconfirmedDelta(entry.ScriptVersionByIndex(prevOut.Index),
+			entry.PkScriptByIndex(prevOut.Index), AddrDelta{
+				TxHash: *tx.Hash(),
+				Tree:   tree,
+				Index:  uint32(i),
+				Spend:  true,
+				Amount: -entry.AmountByIndex(prevOut.Index),
+			})
+	}
+
+	for i, txOut := range msgTx.TxOut {
+		idx.addUnconfirmedDelta(txOut.Version, txOut.PkScript, AddrDelta{
+			TxHash: *tx.Hash(),
+			Tree:   tree,
+			Index:  uint32(i),
+			Amount: txOut.Value,
+		})
+	}
+}
+
+// RemoveUnconfirmedTx removes the balance changes caused by the passed
+// transaction from the unconfirmed (memory-only) index.
+//
+// This function is safe for concurrent access.
+func (idx *AddrUtxoIndex) RemoveUnconfirmedTx(hash *chainhash.Hash) {
+	idx.unconfirmedLock.Lock()
+	defer idx.unconfirmedLock.Unlock()
+
+	for addrKey := range idx.addrsByTx[*hash] {
+		delete(idx.deltasByAddr[addrKey], *hash)
+		if len(idx.deltasByAddr[addrKey]) == 0 {
+			delete(idx.deltasByAddr, addrKey)
+		}
+	}
+	delete(idx.addrsByTx, *hash)
+}
+
+// UnconfirmedDeltasForAddress returns the changes to the balance of the passed
+// address caused by the transactions currently in the unconfirmed (memory-only)
+// index.  They are ordered by transaction hash followed by the outputs and then
+// the inputs of each transaction so the order is stable.  Unsupported address
+// types are ignored and will result in no results.
+//
+// This function is safe for concurrent access.
+func (idx *AddrUtxoIndex) UnconfirmedDeltasForAddress(addr hcutil.Address) []AddrDelta {
+	// Ignore unsupported address types.
+	addrKey, err := addrToKey(addr, idx.chainParams)
+	if err != nil {
+		return nil
+	}
+
+	idx.unconfirmedLock.RLock()
+	var deltas []AddrDelta
+	for _, txDeltas := range idx.deltasByAddr[addrKey] {
+		deltas = append(deltas, txDeltas...)
+	}
+	idx.unconfirmedLock.RUnlock()
+
+	sort.Slice(deltas, func(i, j int) bool {
+		a, b := &deltas[i], &deltas[j]
+		if cmp := bytes.Compare(a.TxHash[:], b.TxHash[:]); cmp != 0 {
+			return cmp < 0
+		}
+		if a.Spend != b.Spend {
+			return !a.Spend
+		}
+		return a.Index < b.Index
+	})
+	return deltas
+}
+
+// NewAddrUtxoIndex returns a new instance of an indexer that is used to create
+// a mapping of all addresses in the blockchain to the unspent outputs paying to
+// them and the changes to their balance.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewAddrUtxoIndex(db database.DB, chainParams *chaincfg.Params) *AddrUtxoIndex {
+	return &AddrUtxoIndex{
+		db:           db,
+		chainParams:  chainParams,
+		deltasByAddr: make(map[[addrKeySize]byte]map[chainhash.Hash][]AddrDelta),
+		addrsByTx:    make(map[chainhash.Hash]map[[addrKeySize]byte]struct{}),
+	}
+}
+
+// DropAddrUtxoIndex drops the address utxo index from the provided database if
+// it exists.
+func DropAddrUtxoIndex(db database.DB) error {
+	return dropIndex(db, addrUtxoIndexKey, addrUtxoIndexName)
+}
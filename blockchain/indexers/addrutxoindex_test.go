@@ -0,0 +1,467 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/blockchain/stake"
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/txscript"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
+)
+
+// addrUtxoIndexBucket provides a mock address utxo index database bucket by
+// implementing the internalBucket interface along with a cursor over its
+// entries.
+type addrUtxoIndexBucket struct {
+	entries map[string][]byte
+}
+
+// Clone returns a deep copy of the mock address utxo index bucket.
+func (b *addrUtxoIndexBucket) Clone() *addrUtxoIndexBucket {
+	entries := make(map[string][]byte)
+	for k, v := range b.entries {
+		vCopy := make([]byte, len(v))
+		copy(vCopy, v)
+		entries[k] = vCopy
+	}
+	return &addrUtxoIndexBucket{entries: entries}
+}
+
+// Get returns the value associated with the key from the mock address utxo
+// index bucket.
+//
+// This is part of the internalBucket interface.
+func (b *addrUtxoIndexBucket) Get(key []byte) []byte {
+	return b.entries[string(key)]
+}
+
+// Put stores the provided key/value pair to the mock address utxo index bucket.
+//
+// This is part of the internalBucket interface.
+func (b *addrUtxoIndexBucket) Put(key []byte, value []byte) error {
+	b.entries[string(key)] = value
+	return nil
+}
+
+// Delete removes the provided key from the mock address utxo index bucket.
+//
+// This is part of the internalBucket interface.
+func (b *addrUtxoIndexBucket) Delete(key []byte) error {
+	delete(b.entries, string(key))
+	return nil
+}
+
+// Cursor returns a cursor over a snapshot of the entries of the mock address
+// utxo index bucket.
+func (b *addrUtxoIndexBucket) Cursor() database.Cursor {
+	keys := make([]string, 0, len(b.entries))
+	for k := range b.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return &sortedCursor{bucket: b, keys: keys}
+}
+
+// sortedCursor provides a mock cursor over the entries of a mock address utxo
+// index bucket by implementing the database.Cursor interface.  Only reading
+// is supported.
+type sortedCursor struct {
+	bucket *addrUtxoIndexBucket
+	keys   []string
+	pos    int
+}
+
+// Bucket is not supported by the mock cursor.
+//
+// This is part of the database.Cursor interface.
+func (c *sortedCursor) Bucket() database.Bucket {
+	return nil
+}
+
+// Delete is not supported by the mock cursor.
+//
+// This is part of the database.Cursor interface.
+func (c *sortedCursor) Delete() error {
+	return nil
+}
+
+// valid returns whether or not the cursor points to an entry.
+func (c *sortedCursor) valid() bool {
+	return c.pos >= 0 && c.pos < len(c.keys)
+}
+
+// First positions the cursor at the first entry.
+//
+// This is part of the database.Cursor interface.
+func (c *sortedCursor) First() bool {
+	c.pos = 0
+	return c.valid()
+}
+
+// Last positions the cursor at the last entry.
+//
+// This is part of the database.Cursor interface.
+func (c *sortedCursor) Last() bool {
+	c.pos = len(c.keys) - 1
+	return c.valid()
+}
+
+// Next moves the cursor one entry forward.
+//
+// This is part of the database.Cursor interface.
+func (c *sortedCursor) Next() bool {
+	c.pos++
+	return c.valid()
+}
+
+// Prev moves the cursor one entry backward.
+//
+// This is part of the database.Cursor interface.
+func (c *sortedCursor) Prev() bool {
+	c.pos--
+	return c.valid()
+}
+
+// Seek positions the cursor at the first entry with a key greater than or
+// equal to the passed key.
+//
+// This is part of the database.Cursor interface.
+func (c *sortedCursor) Seek(seek []byte) bool {
+	c.pos = sort.SearchStrings(c.keys, string(seek))
+	return c.valid()
+}
+
+// Key returns the key of the entry the cursor points to.
+//
+// This is part of the database.Cursor interface.
+func (c *sortedCursor) Key() []byte {
+	return []byte(c.keys[c.pos])
+}
+
+// Value returns the value of the entry the cursor points to.
+//
+// This is part of the database.Cursor interface.
+func (c *sortedCursor) Value() []byte {
+	return c.bucket.entries[c.keys[c.pos]]
+}
+
+// p2pkhScript returns a pay-to-pubkey-hash script for the passed hash160 along
+// with the address key for it.
+func p2pkhScript(hash160 byte) ([]byte, [addrKeySize]byte) {
+	var addrKey [addrKeySize]byte
+	addrKey[0] = addrKeyTypePubKeyHash
+	for i := 1; i < addrKeySize; i++ {
+		addrKey[i] = hash160
+	}
+	script := append([]byte{0x76, 0xa9, 0x14}, addrKey[1:]...)
+	return append(script, 0x88, 0xac), addrKey
+}
+
+// TestAddrUtxoEntrySerialization ensures serializing and deserializing address
+// utxo index entries works as expected.
+func TestAddrUtxoEntrySerialization(t *testing.T) {
+	t.Parallel()
+
+	script, addrKey := p2pkhScript(0x01)
+	wantUtxo := AddrUtxo{
+		OutPoint: wire.OutPoint{Hash: chainhash.Hash{0x02}, Index: 3,
+			Tree: wire.TxTreeStake},
+		Amount:        500000000,
+		Height:        1234,
+		TxType:        stake.TxTypeSStx,
+		ScriptVersion: 0,
+		PkScript:      script,
+	}
+	utxoKey := addrUtxoKey(addrKey, &wantUtxo.OutPoint)
+	utxo, err := deserializeAddrUtxo(utxoKey[:],
+		serializeAddrUtxo(&wantUtxo))
+	if err != nil {
+		t.Fatalf("unexpected utxo deserialize error: %v", err)
+	}
+	if !reflect.DeepEqual(*utxo, wantUtxo) {
+		t.Fatalf("mismatched utxo -- got %+v, want %+v", *utxo, wantUtxo)
+	}
+
+	wantDelta := AddrDelta{
+		TxHash: chainhash.Hash{0x04},
+		Tree:   wire.TxTreeRegular,
+		Index:  5,
+		Spend:  true,
+		Amount: -500000000,
+		Height: 1300,
+	}
+	deltaKey := addrDeltaKey(addrKey, wantDelta.Height, wantDelta.Tree, 6,
+		wantDelta.Spend, wantDelta.Index)
+	serialized := serializeAddrDelta(&wantDelta.TxHash, wantDelta.Amount,
+		1234, stake.TxTypeSStx)
+	delta, spentHeight, spentTxType, err := deserializeAddrDelta(deltaKey[:],
+		serialized)
+	if err != nil {
+		t.Fatalf("unexpected delta deserialize error: %v", err)
+	}
+	if !reflect.DeepEqual(*delta, wantDelta) {
+		t.Fatalf("mismatched delta -- got %+v, want %+v", *delta,
+			wantDelta)
+	}
+	if spentHeight != 1234 || spentTxType != stake.TxTypeSStx {
+		t.Fatalf("mismatched spent details -- got %d/%d, want 1234/%d",
+			spentHeight, spentTxType, stake.TxTypeSStx)
+	}
+
+	// Ensure entries with the wrong size are rejected.
+	_, err = deserializeAddrUtxo(utxoKey[:], make([]byte,
+		addrUtxoValueMinSize-1))
+	if !isDeserializeErr(err) {
+		t.Fatalf("unexpected error for short utxo -- got %v", err)
+	}
+	_, _, _, err = deserializeAddrDelta(deltaKey[:], serialized[1:])
+	if !isDeserializeErr(err) {
+		t.Fatalf("unexpected error for short delta -- got %v", err)
+	}
+}
+
+// TestAddrUtxoIndexEntries ensures connecting and disconnecting blocks updates
+// the address utxo index entries as expected, including outputs which are
+// created and spent by the transactions applied by the same block.
+func TestAddrUtxoIndexEntries(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.SimNetParams
+	scriptA, addrA := p2pkhScript(0xaa)
+	scriptB, addrB := p2pkhScript(0xbb)
+
+	// newTx returns a transaction which spends the passed outpoints and
+	// pays the passed amounts to the passed scripts.
+	newTx := func(prevOuts []wire.OutPoint, scripts [][]byte, amounts []int64) *wire.MsgTx {
+		tx := wire.NewMsgTx()
+		for i := range prevOuts {
+			tx.AddTxIn(wire.NewTxIn(&prevOuts[i], nil))
+		}
+		for i := range scripts {
+			tx.AddTxOut(wire.NewTxOut(amounts[i], scripts[i]))
+		}
+		return tx
+	}
+
+	// The funding transaction was confirmed before and pays 10 coins to
+	// address A.
+	fundingTx := newTx([]wire.OutPoint{{Hash: chainhash.Hash{0x01}}},
+		[][]byte{scriptA}, []int64{1000000000})
+	fundingOut := wire.OutPoint{Hash: fundingTx.TxHash()}
+
+	// The parent contains a coinbase paying to address B and a transaction
+	// spending the funding output which pays 6 coins to address B and 3
+	// coins back to address A.
+	coinbase := newTx([]wire.OutPoint{{Index: wire.MaxPrevOutIndex}},
+		[][]byte{scriptB}, []int64{200000000})
+	parentTx := newTx([]wire.OutPoint{fundingOut}, [][]byte{scriptB,
+		scriptA}, []int64{600000000, 300000000})
+	parent := hcutil.NewBlock(&wire.MsgBlock{
+		Header:       wire.BlockHeader{Height: 10},
+		Transactions: []*wire.MsgTx{coinbase, parentTx},
+	})
+
+	// The block approves the parent and contains a stake transaction which
+	// spends the change of the parent transaction and pays 2 coins to
+	// address B.
+	changeOut := wire.OutPoint{Hash: parentTx.TxHash(), Index: 1}
+	stakeTx := newTx([]wire.OutPoint{changeOut}, [][]byte{scriptB},
+		[]int64{200000000})
+	block := hcutil.NewBlock(&wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Height:   11,
+			VoteBits: hcutil.BlockValid,
+		},
+		STransactions: []*wire.MsgTx{stakeTx},
+	})
+
+	view := blockchain.NewUtxoViewpoint()
+	view.AddTxOuts(hcutil.NewTx(fundingTx), 5, 0)
+	view.AddTxOuts(hcutil.NewTx(parentTx), 10, 1)
+
+	bucket := &addrUtxoIndexBucket{entries: make(map[string][]byte)}
+	fundingKey := addrUtxoKey(addrA, &fundingOut)
+	bucket.Put(fundingKey[:], serializeAddrUtxo(&AddrUtxo{
+		Amount:   1000000000,
+		Height:   5,
+		PkScript: scriptA,
+	}))
+	origBucket := bucket.Clone()
+
+	err := dbPutAddrUtxoIndexEntries(bucket, block, parent, view, params)
+	if err != nil {
+		t.Fatalf("unexpected put error: %v", err)
+	}
+
+	// Ensure the unspent outputs are the expected ones.
+	utxosA, _, err := dbFetchAddrUtxos(bucket.Cursor(), addrA, 0, 100, nil)
+	if err != nil {
+		t.Fatalf("unexpected fetch error: %v", err)
+	}
+	if len(utxosA) != 0 {
+		t.Fatalf("unexpected utxos for address A: %+v", utxosA)
+	}
+	utxosB, _, err := dbFetchAddrUtxos(bucket.Cursor(), addrB, 0, 100, nil)
+	if err != nil {
+		t.Fatalf("unexpected fetch error: %v", err)
+	}
+	wantOutPoints := map[wire.OutPoint]int64{
+		{Hash: coinbase.TxHash()}:                        200000000,
+		{Hash: parentTx.TxHash()}:                        600000000,
+		{Hash: stakeTx.TxHash(), Tree: wire.TxTreeStake}: 200000000,
+	}
+	if len(utxosB) != len(wantOutPoints) {
+		t.Fatalf("unexpected number of utxos for address B -- got %d, "+
+			"want %d", len(utxosB), len(wantOutPoints))
+	}
+	for _, utxo := range utxosB {
+		if amount, ok := wantOutPoints[utxo.OutPoint]; !ok ||
+			amount != utxo.Amount {
+			t.Fatalf("unexpected utxo for address B: %+v", utxo)
+		}
+	}
+
+	// Ensure paging through the unspent outputs works as expected.
+	paged, skipped, err := dbFetchAddrUtxos(bucket.Cursor(), addrB, 1, 1, nil)
+	if err != nil {
+		t.Fatalf("unexpected fetch error: %v", err)
+	}
+	if skipped != 1 || len(paged) != 1 ||
+		!reflect.DeepEqual(paged[0], utxosB[1]) {
+		t.Fatalf("unexpected page -- got %+v (skipped %d), want %+v",
+			paged, skipped, utxosB[1])
+	}
+
+	// Ensure excluded outputs are neither returned nor counted as skipped.
+	exclude := map[wire.OutPoint]struct{}{utxosB[0].OutPoint: {}}
+	paged, skipped, err = dbFetchAddrUtxos(bucket.Cursor(), addrB, 1, 1,
+		exclude)
+	if err != nil {
+		t.Fatalf("unexpected fetch error: %v", err)
+	}
+	if skipped != 1 || len(paged) != 1 ||
+		!reflect.DeepEqual(paged[0], utxosB[2]) {
+		t.Fatalf("unexpected page with exclusions -- got %+v (skipped "+
+			"%d), want %+v", paged, skipped, utxosB[2])
+	}
+
+	// Ensure the balance changes of address A are in the order they
+	// appear in the blockchain.
+	deltasA, _, err := dbFetchAddrDeltas(bucket.Cursor(), addrA, 0, 100)
+	if err != nil {
+		t.Fatalf("unexpected fetch error: %v", err)
+	}
+	wantDeltas := []AddrDelta{{
+		TxHash: parentTx.TxHash(),
+		Index:  1,
+		Amount: 300000000,
+		Height: 10,
+	}, {
+		TxHash: parentTx.TxHash(),
+		Spend:  true,
+		Amount: -1000000000,
+		Height: 10,
+	}, {
+		TxHash: stakeTx.TxHash(),
+		Tree:   wire.TxTreeStake,
+		Spend:  true,
+		Amount: -300000000,
+		Height: 11,
+	}}
+	if !reflect.DeepEqual(deltasA, wantDeltas) {
+		t.Fatalf("mismatched deltas -- got %+v, want %+v", deltasA,
+			wantDeltas)
+	}
+
+	// Ensure the balances are the expected ones.
+	balanceA, err := dbFetchAddrBalance(bucket.Cursor(), addrA)
+	if err != nil {
+		t.Fatalf("unexpected balance error: %v", err)
+	}
+	if *balanceA != (AddrBalance{Balance: 0, Received: 300000000}) {
+		t.Fatalf("unexpected balance for address A: %+v", *balanceA)
+	}
+	balanceB, err := dbFetchAddrBalance(bucket.Cursor(), addrB)
+	if err != nil {
+		t.Fatalf("unexpected balance error: %v", err)
+	}
+	if *balanceB != (AddrBalance{Balance: 1000000000,
+		Received: 1000000000}) {
+		t.Fatalf("unexpected balance for address B: %+v", *balanceB)
+	}
+
+	// Ensure disconnecting the block restores the original state.
+	err = dbRemoveAddrUtxoIndexEntries(bucket, block, parent, view, params)
+	if err != nil {
+		t.Fatalf("unexpected remove error: %v", err)
+	}
+	if !reflect.DeepEqual(bucket.entries, origBucket.entries) {
+		for k, v := range bucket.entries {
+			if !bytes.Equal(origBucket.entries[k], v) {
+				t.Errorf("mismatched entry %x -- got %x, want %x",
+					k, v, origBucket.entries[k])
+			}
+		}
+		t.Fatalf("bucket has %d entries after disconnect, want %d",
+			len(bucket.entries), len(origBucket.entries))
+	}
+}
+
+// TestAddrUtxoIndexUnconfirmed ensures the unconfirmed balance changes are
+// added and removed as expected.
+func TestAddrUtxoIndexUnconfirmed(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.SimNetParams
+	scriptA, _ := p2pkhScript(0xaa)
+	scriptB, _ := p2pkhScript(0xbb)
+	_, addrsA, _, _ := txscript.ExtractPkScriptAddrs(0, scriptA, params)
+	_, addrsB, _, _ := txscript.ExtractPkScriptAddrs(0, scriptB, params)
+
+	fundingTx := wire.NewMsgTx()
+	fundingTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{1}},
+		nil))
+	fundingTx.AddTxOut(wire.NewTxOut(1000000000, scriptA))
+	view := blockchain.NewUtxoViewpoint()
+	view.AddTxOuts(hcutil.NewTx(fundingTx), 5, 0)
+
+	spendTx := wire.NewMsgTx()
+	spendTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: fundingTx.TxHash()},
+		nil))
+	spendTx.AddTxOut(wire.NewTxOut(900000000, scriptB))
+	tx := hcutil.NewTx(spendTx)
+
+	idx := NewAddrUtxoIndex(nil, params)
+	idx.AddUnconfirmedTx(tx, view)
+	deltasA := idx.UnconfirmedDeltasForAddress(addrsA[0])
+	wantA := []AddrDelta{{TxHash: *tx.Hash(), Spend: true,
+		Amount: -1000000000}}
+	if !reflect.DeepEqual(deltasA, wantA) {
+		t.Fatalf("mismatched deltas for address A -- got %+v, want %+v",
+			deltasA, wantA)
+	}
+	deltasB := idx.UnconfirmedDeltasForAddress(addrsB[0])
+	wantB := []AddrDelta{{TxHash: *tx.Hash(), Amount: 900000000}}
+	if !reflect.DeepEqual(deltasB, wantB) {
+		t.Fatalf("mismatched deltas for address B -- got %+v, want %+v",
+			deltasB, wantB)
+	}
+
+	idx.RemoveUnconfirmedTx(tx.Hash())
+	if len(idx.UnconfirmedDeltasForAddress(addrsA[0])) != 0 ||
+		len(idx.UnconfirmedDeltasForAddress(addrsB[0])) != 0 ||
+		len(idx.deltasByAddr) != 0 || len(idx.addrsByTx) != 0 {
+		t.Fatal("unconfirmed deltas remain after removal")
+	}
+}
@@ -0,0 +1,444 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/blockchain/stake"
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
+)
+
+const (
+	// blockStatsIndexName is the human-readable name for the index.
+	blockStatsIndexName = "block stats index"
+
+	// blockStatsEntrySize is the size of the serialized block stats index
+	// entries.  It consists of 10 4-byte and 15 8-byte fields along with
+	// the 2-byte number of votes and the 1-byte numbers of tickets and
+	// revocations.
+	blockStatsEntrySize = 10*4 + 15*8 + 2 + 1 + 1
+)
+
+var (
+	// blockStatsIndexKey is the key of the block stats index and the db
+	// bucket used to house it.
+	blockStatsIndexKey = []byte("blockstatsidx")
+
+	// FeeRatePercentiles are the percentiles of the fee rates paid by the
+	// transactions in a block which are included in the block statistics.
+	FeeRatePercentiles = [5]int{10, 25, 50, 75, 90}
+)
+
+// -----------------------------------------------------------------------------
+// The block stats index consists of an entry for every block in the main chain
+// which holds the statistics of the block as calculated by CalcBlockStats.
+// The statistics only depend on the block itself, so they are simply removed
+// when the block is disconnected.
+//
+// The serialized format for the keys and values in the block stats index
+// bucket is:
+//
+//   <block hash> = <height><time><size><txs><stxs><ins><outs><total out>
+//     <total fee><min fee rate><max fee rate><avg fee rate><fee rate
+//     percentiles><min tx size><max tx size><avg tx size><votes><tickets>
+//     <revocations><ticket price><pool size><pow subsidy><pos subsidy>
+//     <dev subsidy>
+//
+//   Field             Type              Size
+//   block hash        chainhash.Hash    32 bytes
+//   height            uint32            4 bytes
+//   time              int64             8 bytes
+//   size              uint32            4 bytes
+//   txs               uint32            4 bytes
+//   stxs              uint32            4 bytes
+//   ins               uint32            4 bytes
+//   outs              uint32            4 bytes
+//   total out         int64             8 bytes
+//   total fee         int64             8 bytes
+//   min fee rate      int64             8 bytes
+//   max fee rate      int64             8 bytes
+//   avg fee rate      int64             8 bytes
+//   fee percentiles   [5]int64          40 bytes
+//   min tx size       uint32            4 bytes
+//   max tx size       uint32            4 bytes
+//   avg tx size       uint32            4 bytes
+//   votes             uint16            2 bytes
+//   tickets           uint8             1 byte
+//   revocations       uint8             1 byte
+//   ticket price      int64             8 bytes
+//   pool size         uint32            4 bytes
+//   pow subsidy       int64             8 bytes
+//   pos subsidy       int64             8 bytes
+//   dev subsidy       int64             8 bytes
+//   -----
+//   Total: 196 bytes
+// -----------------------------------------------------------------------------
+
+// BlockStats houses statistics about the transactions and the subsidy of a
+// block.
+//
+// The fee rates are in atoms per kilobyte.  The fee statistics only cover the
+// transactions which pay fees, so they exclude the coinbase and the votes.  The
+// input, output and transaction size statistics exclude the coinbase.  The
+// amounts of the inputs are taken from the fraud proofs of the inputs, which
+// the consensus rules require to match the spent outputs.
+type BlockStats struct {
+	Height             int64
+	Time               int64
+	Size               uint32
+	Txs                uint32
+	STxs               uint32
+	Ins                uint32
+	Outs               uint32
+	TotalOut           int64
+	TotalFee           int64
+	MinFeeRate         int64
+	MaxFeeRate         int64
+	AvgFeeRate         int64
+	FeeRatePercentiles [5]int64
+	MinTxSize          uint32
+	MaxTxSize          uint32
+	AvgTxSize          uint32
+	Votes              uint16
+	Tickets            uint8
+	Revocations        uint8
+	TicketPrice        int64
+	PoolSize           uint32
+	PoWSubsidy         int64
+	PoSSubsidy         int64
+	DevSubsidy         int64
+}
+
+// CalcBlockStats returns the statistics of the passed block.
+//
+// This function is safe for concurrent access.
+func CalcBlockStats(block *hcutil.Block, subsidyCache *blockchain.SubsidyCache, params *chaincfg.Params) *BlockStats {
+	header := &block.MsgBlock().Header
+	height := block.Height()
+	stats := &BlockStats{
+		Height:      height,
+		Time:        header.Timestamp.Unix(),
+		Size:        header.Size,
+		Txs:         uint32(len(block.MsgBlock().Transactions)),
+		STxs:        uint32(len(block.MsgBlock().STransactions)),
+		Votes:       header.Voters,
+		Tickets:     header.FreshStake,
+		Revocations: header.Revocations,
+		TicketPrice: header.SBits,
+		PoolSize:    header.PoolSize,
+	}
+
+	// The genesis block does not have a subsidy.
+	if height > 0 {
+		stats.PoWSubsidy = blockchain.CalcBlockWorkSubsidy(subsidyCache,
+			height, header.Voters, params)
+		stats.DevSubsidy = blockchain.CalcBlockTaxSubsidy(subsidyCache,
+			height, header.Voters, params)
+		if height >= params.StakeValidationHeight {
+			stats.PoSSubsidy = blockchain.CalcStakeVoteSubsidy(
+				subsidyCache, height, params) * int64(header.Voters)
+		}
+	}
+
+	var feeRates []int64
+	var totalSize, numTxns int64
+	for _, msgTx := range block.MsgBlock().Transactions[:1] {
+		stats.Outs += uint32(len(msgTx.TxOut))
+	}
+	txns := make([]*wire.MsgTx, 0, stats.Txs-1+stats.STxs)
+	txns = append(txns, block.MsgBlock().Transactions[1:]...)
+	txns = append(txns, block.MsgBlock().STransactions...)
+	for i, msgTx := range txns {
+		var in, out int64
+		for _, txIn := range msgTx.TxIn {
+			in += txIn.ValueIn
+			if txIn.PreviousOutPoint.Hash != zeroHash {
+				stats.Ins++
+			}
+		}
+		for _, txOut := range msgTx.TxOut {
+			out += txOut.Value
+		}
+		stats.Outs += uint32(len(msgTx.TxOut))
+		stats.TotalOut += out
+
+		size := uint32(msgTx.SerializeSize())
+		if numTxns == 0 || size < stats.MinTxSize {
+			stats.MinTxSize = size
+		}
+		if size > stats.MaxTxSize {
+			stats.MaxTxSize = size
+		}
+		totalSize += int64(size)
+		numTxns++
+
+		// Votes do not pay fees.
+		isStake := i >= int(stats.Txs)-1
+		if isStake && stake.DetermineTxType(msgTx) == stake.TxTypeSSGen {
+			continue
+		}
+		fee := in - out
+		stats.TotalFee += fee
+		feeRates = append(feeRates, fee*1000/int64(size))
+	}
+	if numTxns > 0 {
+		stats.AvgTxSize = uint32(totalSize / numTxns)
+	}
+
+	if len(feeRates) > 0 {
+		sort.Sort(int64Sorter(feeRates))
+		stats.MinFeeRate = feeRates[0]
+		stats.MaxFeeRate = feeRates[len(feeRates)-1]
+		var totalFeeRate int64
+		for _, feeRate := range feeRates {
+			totalFeeRate += feeRate
+		}
+		stats.AvgFeeRate = totalFeeRate / int64(len(feeRates))
+		for i, percentile := range FeeRatePercentiles {
+			idx := (len(feeRates) - 1) * percentile / 100
+			stats.FeeRatePercentiles[i] = feeRates[idx]
+		}
+	}
+
+	return stats
+}
+
+// int64Sorter implements sort.Interface to allow a slice of 64-bit integers to
+// be sorted.
+type int64Sorter []int64
+
+// Len returns the number of 64-bit integers in the slice.  It is part of the
+// sort.Interface implementation.
+func (s int64Sorter) Len() int {
+	return len(s)
+}
+
+// Swap swaps the 64-bit integers at the passed indices.  It is part of the
+// sort.Interface implementation.
+func (s int64Sorter) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less returns whether the 64-bit integer with index i should sort before the
+// 64-bit integer with index j.  It is part of the sort.Interface
+// implementation.
+func (s int64Sorter) Less(i, j int) bool {
+	return s[i] < s[j]
+}
+
+// serializeBlockStats returns the serialized block stats index entry for the
+// passed statistics.
+func serializeBlockStats(stats *BlockStats) []byte {
+	serialized := make([]byte, blockStatsEntrySize)
+	offset := 0
+	putUint32 := func(v uint32) {
+		byteOrder.PutUint32(serialized[offset:], v)
+		offset += 4
+	}
+	putInt64 := func(v int64) {
+		byteOrder.PutUint64(serialized[offset:], uint64(v))
+		offset += 8
+	}
+	putUint32(uint32(stats.Height))
+	putInt64(stats.Time)
+	putUint32(stats.Size)
+	putUint32(stats.Txs)
+	putUint32(stats.STxs)
+	putUint32(stats.Ins)
+	putUint32(stats.Outs)
+	putInt64(stats.TotalOut)
+	putInt64(stats.TotalFee)
+	putInt64(stats.MinFeeRate)
+	putInt64(stats.MaxFeeRate)
+	putInt64(stats.AvgFeeRate)
+	for _, feeRate := range stats.FeeRatePercentiles {
+		putInt64(feeRate)
+	}
+	putUint32(stats.MinTxSize)
+	putUint32(stats.MaxTxSize)
+	putUint32(stats.AvgTxSize)
+	byteOrder.PutUint16(serialized[offset:], stats.Votes)
+	offset += 2
+	serialized[offset] = stats.Tickets
+	serialized[offset+1] = stats.Revocations
+	offset += 2
+	putInt64(stats.TicketPrice)
+	putUint32(stats.PoolSize)
+	putInt64(stats.PoWSubsidy)
+	putInt64(stats.PoSSubsidy)
+	putInt64(stats.DevSubsidy)
+	return serialized
+}
+
+// deserializeBlockStats decodes the passed serialized block stats index entry.
+func deserializeBlockStats(serialized []byte) (*BlockStats, error) {
+	if len(serialized) != blockStatsEntrySize {
+		return nil, errDeserialize(fmt.Sprintf("unexpected block stats "+
+			"index entry length %d", len(serialized)))
+	}
+
+	var stats BlockStats
+	offset := 0
+	getUint32 := func() uint32 {
+		v := byteOrder.Uint32(serialized[offset:])
+		offset += 4
+		return v
+	}
+	getInt64 := func() int64 {
+		v := int64(byteOrder.Uint64(serialized[offset:]))
+		offset += 8
+		return v
+	}
+	stats.Height = int64(getUint32())
+	stats.Time = getInt64()
+	stats.Size = getUint32()
+	stats.Txs = getUint32()
+	stats.STxs = getUint32()
+	stats.Ins = getUint32()
+	stats.Outs = getUint32()
+	stats.TotalOut = getInt64()
+	stats.TotalFee = getInt64()
+	stats.MinFeeRate = getInt64()
+	stats.MaxFeeRate = getInt64()
+	stats.AvgFeeRate = getInt64()
+	for i := range stats.FeeRatePercentiles {
+		stats.FeeRatePercentiles[i] = getInt64()
+	}
+	stats.MinTxSize = getUint32()
+	stats.MaxTxSize = getUint32()
+	stats.AvgTxSize = getUint32()
+	stats.Votes = byteOrder.Uint16(serialized[offset:])
+	offset += 2
+	stats.Tickets = serialized[offset]
+	stats.Revocations = serialized[offset+1]
+	offset += 2
+	stats.TicketPrice = getInt64()
+	stats.PoolSize = getUint32()
+	stats.PoWSubsidy = getInt64()
+	stats.PoSSubsidy = getInt64()
+	stats.DevSubsidy = getInt64()
+	return &stats, nil
+}
+
+// BlockStatsIndex implements a block statistics index.  That is to say, it
+// caches the statistics of every block in the main chain so they do not have to
+// be calculated from the block every time they are queried.
+type BlockStatsIndex struct {
+	db           database.DB
+	params       *chaincfg.Params
+	subsidyCache *blockchain.SubsidyCache
+}
+
+// Ensure the BlockStatsIndex type implements the Indexer interface.
+var _ Indexer = (*BlockStatsIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *BlockStatsIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *BlockStatsIndex) Key() []byte {
+	return blockStatsIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *BlockStatsIndex) Name() string {
+	return blockStatsIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the block stats
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *BlockStatsIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(blockStatsIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry with the statistics
+// of the block.
+//
+// This is part of the Indexer interface.
+func (idx *BlockStatsIndex) ConnectBlock(dbTx database.Tx, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
+	stats := CalcBlockStats(block, idx.subsidyCache, idx.params)
+	bucket := dbTx.Metadata().Bucket(blockStatsIndexKey)
+	return bucket.Put(block.Hash()[:], serializeBlockStats(stats))
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entry with the
+// statistics of the block.
+//
+// This is part of the Indexer interface.
+func (idx *BlockStatsIndex) DisconnectBlock(dbTx database.Tx, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(blockStatsIndexKey)
+	return bucket.Delete(block.Hash()[:])
+}
+
+// BlockStats returns the cached statistics of the block in the main chain with
+// the passed hash.  When the block is not in the main chain, nil will be
+// returned for both the statistics and the error.
+//
+// This function is safe for concurrent access.
+func (idx *BlockStatsIndex) BlockStats(hash *chainhash.Hash) (*BlockStats, error) {
+	var stats *BlockStats
+	err := idx.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(blockStatsIndexKey)
+		serialized := bucket.Get(hash[:])
+		if serialized == nil {
+			return nil
+		}
+
+		var err error
+		stats, err = deserializeBlockStats(serialized)
+		if err != nil {
+			return database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt block stats "+
+					"index entry for %v: %v", hash, err),
+			}
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// NewBlockStatsIndex returns a new instance of an indexer that is used to cache
+// the statistics of every block in the main chain.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewBlockStatsIndex(db database.DB, params *chaincfg.Params) *BlockStatsIndex {
+	return &BlockStatsIndex{
+		db:           db,
+		params:       params,
+		subsidyCache: blockchain.NewSubsidyCache(0, params),
+	}
+}
+
+// DropBlockStatsIndex drops the block stats index from the provided database if
+// it exists.
+func DropBlockStatsIndex(db database.DB) error {
+	return dropIndex(db, blockStatsIndexKey, blockStatsIndexName)
+}
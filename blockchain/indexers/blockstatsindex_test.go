@@ -0,0 +1,157 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
+)
+
+// TestBlockStatsSerialization ensures serializing and deserializing block
+// stats index entries works as expected.
+func TestBlockStatsSerialization(t *testing.T) {
+	t.Parallel()
+
+	want := BlockStats{
+		Height:             4096,
+		Time:               1520000000,
+		Size:               12000,
+		Txs:                10,
+		STxs:               8,
+		Ins:                25,
+		Outs:               40,
+		TotalOut:           1000000000000,
+		TotalFee:           2500000,
+		MinFeeRate:         10000,
+		MaxFeeRate:         300000,
+		AvgFeeRate:         50000,
+		FeeRatePercentiles: [5]int64{10000, 10000, 20000, 100000, 250000},
+		MinTxSize:          200,
+		MaxTxSize:          2000,
+		AvgTxSize:          500,
+		Votes:              5,
+		Tickets:            2,
+		Revocations:        1,
+		TicketPrice:        9825000000,
+		PoolSize:           40960,
+		PoWSubsidy:         1900000000,
+		PoSSubsidy:         1200000000,
+		DevSubsidy:         300000000,
+	}
+	serialized := serializeBlockStats(&want)
+	if len(serialized) != blockStatsEntrySize {
+		t.Fatalf("unexpected serialized size -- got %d, want %d",
+			len(serialized), blockStatsEntrySize)
+	}
+	got, err := deserializeBlockStats(serialized)
+	if err != nil {
+		t.Fatalf("unexpected deserialize error: %v", err)
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Fatalf("mismatched stats -- got %+v, want %+v", *got, want)
+	}
+
+	// Ensure entries with the wrong size are rejected.
+	_, err = deserializeBlockStats(serialized[1:])
+	if !isDeserializeErr(err) {
+		t.Fatalf("unexpected error for short entry -- got %v, want %T",
+			err, errDeserialize(""))
+	}
+}
+
+// TestCalcBlockStats ensures the statistics calculated for a block are
+// accurate.
+func TestCalcBlockStats(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.MainNetParams
+	script, _ := p2pkhScript(0x01)
+
+	coinbase := wire.NewMsgTx()
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		nil))
+	coinbase.AddTxOut(wire.NewTxOut(3000000000, script))
+	coinbase.AddTxOut(wire.NewTxOut(500000000, script))
+
+	// The first transaction pays a fee of 100000 atoms and the second one a
+	// fee of 10000 atoms.
+	tx1 := wire.NewMsgTx()
+	txIn := wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil)
+	txIn.ValueIn = 1000000
+	tx1.AddTxIn(txIn)
+	tx1.AddTxOut(wire.NewTxOut(900000, script))
+	tx2 := wire.NewMsgTx()
+	for i := uint32(0); i < 2; i++ {
+		txIn := wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{0x02},
+			Index: i}, nil)
+		txIn.ValueIn = 500000
+		tx2.AddTxIn(txIn)
+	}
+	tx2.AddTxOut(wire.NewTxOut(600000, script))
+	tx2.AddTxOut(wire.NewTxOut(390000, script))
+
+	header := wire.BlockHeader{
+		Height:      100,
+		Timestamp:   time.Unix(1520000000, 0),
+		Size:        1000,
+		Voters:      0,
+		FreshStake:  3,
+		Revocations: 1,
+		PoolSize:    4096,
+		SBits:       200000000,
+	}
+	block := hcutil.NewBlock(&wire.MsgBlock{
+		Header:       header,
+		Transactions: []*wire.MsgTx{coinbase, tx1, tx2},
+	})
+
+	size1 := int64(tx1.SerializeSize())
+	size2 := int64(tx2.SerializeSize())
+	feeRate1 := 100000 * 1000 / size1
+	feeRate2 := 10000 * 1000 / size2
+	subsidyCache := blockchain.NewSubsidyCache(0, params)
+	want := BlockStats{
+		Height:      100,
+		Time:        1520000000,
+		Size:        1000,
+		Txs:         3,
+		Ins:         3,
+		Outs:        5,
+		TotalOut:    1890000,
+		TotalFee:    110000,
+		MinFeeRate:  feeRate2,
+		MaxFeeRate:  feeRate1,
+		AvgFeeRate:  (feeRate1 + feeRate2) / 2,
+		MinTxSize:   uint32(size1),
+		MaxTxSize:   uint32(size2),
+		AvgTxSize:   uint32((size1 + size2) / 2),
+		Tickets:     3,
+		Revocations: 1,
+		TicketPrice: 200000000,
+		PoolSize:    4096,
+		PoWSubsidy: blockchain.CalcBlockWorkSubsidy(subsidyCache, 100, 0,
+			params),
+		DevSubsidy: blockchain.CalcBlockTaxSubsidy(subsidyCache, 100, 0,
+			params),
+	}
+
+	// With only two fee rates, all of the percentiles below the maximum are
+	// the lower fee rate.
+	for i := range want.FeeRatePercentiles {
+		want.FeeRatePercentiles[i] = feeRate2
+	}
+
+	got := CalcBlockStats(block, subsidyCache, params)
+	if !reflect.DeepEqual(*got, want) {
+		t.Fatalf("mismatched stats -- got %+v, want %+v", *got, want)
+	}
+}
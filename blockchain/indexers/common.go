@@ -14,6 +14,7 @@ import (
 
 	"github.com/coolsnady/hcd/blockchain"
 	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/wire"
 	"github.com/coolsnady/hcutil"
 )
 
@@ -91,6 +92,34 @@ type internalBucket interface {
 	Delete(key []byte) error
 }
 
+// appliedTx describes a transaction which is applied to the main chain when a
+// block is connected along with the block that contains it and its location
+// within that block.
+type appliedTx struct {
+	tx    *hcutil.Tx
+	block *hcutil.Block
+	tree  int8
+	index int
+}
+
+// appliedTxns returns the transactions which are applied to the main chain when
+// the passed block is connected in the order they are applied.  Those are the
+// regular transactions of the parent when the passed block approves it followed
+// by the stake transactions of the passed block.
+func appliedTxns(block, parent *hcutil.Block) []appliedTx {
+	var txns []appliedTx
+	if approvesParent(block) && block.Height() > 1 {
+		for i, tx := range parent.Transactions() {
+			txns = append(txns, appliedTx{tx, parent,
+				wire.TxTreeRegular, i})
+		}
+	}
+	for i, stx := range block.STransactions() {
+		txns = append(txns, appliedTx{stx, block, wire.TxTreeStake, i})
+	}
+	return txns
+}
+
 // approvesParent returns whether or not the vote bits in the header of the
 // passed block indicate the regular transaction tree of the parent block should
 // be considered valid.
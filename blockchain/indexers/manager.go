@@ -9,6 +9,7 @@ package indexers
 import (
 	"bytes"
 	"fmt"
+	"sync"
 
 	"github.com/coolsnady/hcd/blockchain"
 	"github.com/coolsnady/hcd/blockchain/internal/progresslog"
@@ -134,6 +135,29 @@ type Manager struct {
 	params         *chaincfg.Params
 	db             database.DB
 	enabledIndexes []Indexer
+
+	// The following fields track the progress of each enabled index.  The
+	// indexes which are behind the main chain when the manager is
+	// initialized are caught up in the background, so they are not synced
+	// until they reach its tip.  The error which stopped the catch-up of
+	// an index, if any, is kept in errs.  They are protected by the mtx
+	// field.
+	mtx        sync.Mutex
+	tipHeights []int64
+	synced     []bool
+	errs       []error
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// IndexInfo describes the progress of an index managed by the index manager.
+// Err is set when the index failed to catch up to the main chain.
+type IndexInfo struct {
+	Name   string
+	Height int64
+	Synced bool
+	Err    error
 }
 
 // Ensure the Manager type implements the blockchain.IndexManager interface.
@@ -225,11 +249,11 @@ func (m *Manager) maybeCreateIndexes(dbTx database.Tx) error {
 }
 
 // Init initializes the enabled indexes.  This is called during chain
-// initialization and primarily consists of catching up all indexes to the
-// current best chain tip.  This is necessary since each index can be disabled
-// and re-enabled at any time and attempting to catch-up indexes at the same
-// time new blocks are being downloaded would lead to an overall longer time to
-// catch up due to the I/O contention.
+// initialization and primarily consists of starting to catch up all indexes to
+// the current best chain tip.  This is necessary since each index can be
+// disabled and re-enabled at any time.  Catching up can take hours for some of
+// the indexes, so it is done in the background while new blocks are processed
+// and the indexes are reported as not synced until they reach the tip.
 //
 // This is part of the blockchain.IndexManager interface.
 func (m *Manager) Init(chain *blockchain.BlockChain) error {
@@ -357,13 +381,10 @@ func (m *Manager) Init(chain *blockchain.BlockChain) error {
 		}
 	}
 
-	// Fetch the current tip heights for each index along with tracking the
-	// lowest one so the catchup code only needs to start at the earliest
-	// block and is able to skip connecting the block for the indexes that
-	// don't need it.
-	bestHeight := int32(chain.BestSnapshot().Height)
+	// Fetch the current tip heights for each index in order to determine
+	// which of them need to be caught up to the current best chain tip.
+	bestHeight := chain.BestSnapshot().Height
 	lowestHeight := bestHeight
-	indexerHeights := make([]int32, len(m.enabledIndexes))
 	err = m.db.View(func(dbTx database.Tx) error {
 		for i, indexer := range m.enabledIndexes {
 			idxKey := indexer.Key()
@@ -374,9 +395,10 @@ func (m *Manager) Init(chain *blockchain.BlockChain) error {
 
 			log.Debugf("Current %s tip (height %d, hash %v)",
 				indexer.Name(), height, hash)
-			indexerHeights[i] = height
-			if height < lowestHeight {
-				lowestHeight = height
+			m.tipHeights[i] = int64(height)
+			m.synced[i] = int64(height) >= bestHeight
+			if int64(height) < lowestHeight {
+				lowestHeight = int64(height)
 			}
 		}
 		return nil
@@ -390,44 +412,97 @@ func (m *Manager) Init(chain *blockchain.BlockChain) error {
 		return nil
 	}
 
+	// At this point, one or more indexes are behind the current best chain
+	// tip and need to be caught up.  This can take a very long time, so it
+	// is done in the background in order to avoid delaying startup.  The
+	// indexes are not used until they are synced.
+	log.Infof("Catching up indexes from height %d to %d in the "+
+		"background", lowestHeight, bestHeight)
+	m.wg.Add(1)
+	go m.catchUp()
+	return nil
+}
+
+// catchUp connects the blocks of the main chain to the indexes which are not
+// synced until all of them reach the tip of the main chain.  Since the main
+// chain keeps growing meanwhile, the tip is determined anew for every block.
+//
+// This must be run as a goroutine.
+func (m *Manager) catchUp() {
+	defer m.wg.Done()
+
 	// Create a progress logger for the indexing process below.
 	progressLogger := progresslog.NewBlockProgressLogger("Indexed", log)
 
-	// At this point, one or more indexes are behind the current best chain
-	// tip and need to be caught up, so log the details and loop through
-	// each block that needs to be indexed.
-	log.Infof("Catching up indexes from height %d to %d", lowestHeight,
-		bestHeight)
-
 	var cachedParent *hcutil.Block
-	for height := lowestHeight + 1; height <= bestHeight; height++ {
+	for {
+		select {
+		case <-m.quit:
+			log.Infof("Index catch up interrupted")
+			return
+		default:
+		}
+
 		var block, parent *hcutil.Block
-		err = m.db.Update(func(dbTx database.Tx) error {
-			// Get the parent of the block, unless it's already cached.
-			if cachedParent == nil && height > 0 {
-				parent, err = blockchain.DBFetchBlockByHeight(
-					dbTx, int64(height-1))
+		var bestHeight int64
+		indexerHeights := make([]int64, len(m.enabledIndexes))
+		err := m.db.Update(func(dbTx database.Tx) error {
+			// Determine the lowest tip of the indexes which are not
+			// synced.  The tips are fetched from the database since
+			// blocks connected to the main chain meanwhile are
+			// connected to the indexes whose tip is their parent.
+			var err error
+			bestHeight, err = blockchain.DBFetchBestHeight(dbTx)
+			if err != nil {
+				return err
+			}
+			lowestHeight := bestHeight
+			for i, indexer := range m.enabledIndexes {
+				indexerHeights[i] = bestHeight
+				if m.IndexSynced(indexer) {
+					continue
+				}
+
+				_, height, err := dbFetchIndexerTip(dbTx,
+					indexer.Key())
 				if err != nil {
 					return err
 				}
-			} else {
-				parent = cachedParent
+				indexerHeights[i] = int64(height)
+				if int64(height) < lowestHeight {
+					lowestHeight = int64(height)
+				}
+			}
+
+			// Nothing to index if all of the indexes are caught up.
+			if lowestHeight == bestHeight {
+				return nil
 			}
 
-			// Load the block for the height since it is required to index
-			// it.
-			block, err = blockchain.DBFetchBlockByHeight(dbTx,
-				int64(height))
+			// Load the block for the next height since it is required
+			// to index it along with its parent, unless it's already
+			// cached.
+			height := lowestHeight + 1
+			block, err = blockchain.DBFetchBlockByHeight(dbTx, height)
 			if err != nil {
 				return err
 			}
+			prevHash := &block.MsgBlock().Header.PrevBlock
+			parent = cachedParent
+			if parent == nil || *parent.Hash() != *prevHash {
+				parent, err = blockchain.DBFetchBlockByHeight(dbTx,
+					height-1)
+				if err != nil {
+					return err
+				}
+			}
 			cachedParent = block
 
 			// Connect the block for all indexes that need it.
 			var view *blockchain.UtxoViewpoint
 			for i, indexer := range m.enabledIndexes {
-				// Skip indexes that don't need to be updated with this
-				// block.
+				// Skip indexes that don't need to be updated with
+				// this block.
 				if indexerHeights[i] >= height {
 					continue
 				}
@@ -437,10 +512,9 @@ func (m *Manager) Init(chain *blockchain.BlockChain) error {
 				// need to be retrieved from the transaction
 				// index.
 				if view == nil && indexNeedsInputs(indexer) {
-					var errMakeView error
-					view, errMakeView = makeUtxoView(dbTx, block, parent)
-					if errMakeView != nil {
-						return errMakeView
+					view, err = makeUtxoView(dbTx, block, parent)
+					if err != nil {
+						return err
 					}
 				}
 				err = dbIndexConnectBlock(dbTx, indexer, block,
@@ -455,15 +529,113 @@ func (m *Manager) Init(chain *blockchain.BlockChain) error {
 			return nil
 		})
 		if err != nil {
-			return err
+			// The indexes which are not synced can't make any more
+			// progress, so record the error for them in order to
+			// report it to callers instead of claiming they are
+			// still syncing.
+			log.Errorf("Unable to catch up indexes: %v", err)
+			m.mtx.Lock()
+			for i := range m.enabledIndexes {
+				if !m.synced[i] {
+					m.errs[i] = err
+				}
+			}
+			m.mtx.Unlock()
+			return
+		}
+
+		// Update the progress of the indexes now that the changes are
+		// committed.  The indexes which reached the tip of the main
+		// chain are synced from now on.
+		m.mtx.Lock()
+		allSynced := true
+		for i := range m.enabledIndexes {
+			if m.synced[i] {
+				continue
+			}
+			m.tipHeights[i] = indexerHeights[i]
+			m.synced[i] = indexerHeights[i] >= bestHeight
+			allSynced = allSynced && m.synced[i]
+		}
+		m.mtx.Unlock()
+		if block != nil {
+			progressLogger.LogBlockHeight(block.MsgBlock(),
+				parent.MsgBlock())
+		}
+		if allSynced {
+			log.Infof("Indexes caught up to height %d", bestHeight)
+			return
+		}
+	}
+}
+
+// IndexSynced returns whether or not the passed index is synced with the main
+// chain.  The indexes which are still being caught up in the background should
+// not be queried since their results would be incomplete.
+//
+// This function is safe for concurrent access.
+func (m *Manager) IndexSynced(indexer Indexer) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for i, enabled := range m.enabledIndexes {
+		if enabled == indexer {
+			return m.synced[i]
 		}
-		progressLogger.LogBlockHeight(block.MsgBlock(), parent.MsgBlock())
 	}
+	return false
+}
 
-	log.Infof("Indexes caught up to height %d", bestHeight)
+// IndexError returns the error which stopped the passed index from catching up
+// to the main chain, or nil when it is synced or still catching up.
+//
+// This function is safe for concurrent access.
+func (m *Manager) IndexError(indexer Indexer) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for i, enabled := range m.enabledIndexes {
+		if enabled == indexer {
+			return m.errs[i]
+		}
+	}
 	return nil
 }
 
+// IndexInfo returns the progress of each of the enabled indexes.
+//
+// This function is safe for concurrent access.
+func (m *Manager) IndexInfo() []IndexInfo {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	info := make([]IndexInfo, 0, len(m.enabledIndexes))
+	for i, indexer := range m.enabledIndexes {
+		info = append(info, IndexInfo{
+			Name:   indexer.Name(),
+			Height: m.tipHeights[i],
+			Synced: m.synced[i],
+			Err:    m.errs[i],
+		})
+	}
+	return info
+}
+
+// setIndexTip updates the tracked tip height of the index at the passed
+// position to the passed height and marks it as synced.
+//
+// This function is safe for concurrent access.
+func (m *Manager) setIndexTip(i int, height int64) {
+	m.mtx.Lock()
+	m.tipHeights[i] = height
+	m.synced[i] = true
+	m.mtx.Unlock()
+}
+
+// Stop interrupts the background catch-up of the indexes, if any, and waits for
+// it to finish.
+func (m *Manager) Stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
 // indexNeedsInputs returns whether or not the index needs access to the txouts
 // referenced by the transaction inputs being indexed.
 func indexNeedsInputs(index Indexer) bool {
@@ -579,12 +751,25 @@ func makeUtxoView(dbTx database.Tx, block, parent *hcutil.Block) (*blockchain.Ut
 // This is part of the blockchain.IndexManager interface.
 func (m *Manager) ConnectBlock(dbTx database.Tx, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
 	// Call each of the currently active optional indexes with the block
-	// being connected so they can update accordingly.
-	for _, index := range m.enabledIndexes {
+	// being connected so they can update accordingly.  The indexes which
+	// are still being caught up in the background are skipped unless they
+	// just reached the parent of the block.
+	for i, index := range m.enabledIndexes {
+		if !m.IndexSynced(index) {
+			tipHash, _, err := dbFetchIndexerTip(dbTx, index.Key())
+			if err != nil {
+				return err
+			}
+			if *tipHash != block.MsgBlock().Header.PrevBlock {
+				continue
+			}
+		}
+
 		err := dbIndexConnectBlock(dbTx, index, block, parent, view)
 		if err != nil {
 			return err
 		}
+		m.setIndexTip(i, block.Height())
 	}
 	return nil
 }
@@ -597,12 +782,25 @@ func (m *Manager) ConnectBlock(dbTx database.Tx, block, parent *hcutil.Block, vi
 // This is part of the blockchain.IndexManager interface.
 func (m *Manager) DisconnectBlock(dbTx database.Tx, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
 	// Call each of the currently active optional indexes with the block
-	// being disconnected so they can update accordingly.
-	for _, index := range m.enabledIndexes {
+	// being disconnected so they can update accordingly.  The indexes which
+	// are still being caught up in the background are skipped unless they
+	// already reached the block.
+	for i, index := range m.enabledIndexes {
+		if !m.IndexSynced(index) {
+			tipHash, _, err := dbFetchIndexerTip(dbTx, index.Key())
+			if err != nil {
+				return err
+			}
+			if *tipHash != *block.Hash() {
+				continue
+			}
+		}
+
 		err := dbIndexDisconnectBlock(dbTx, index, block, parent, view)
 		if err != nil {
 			return err
 		}
+		m.setIndexTip(i, block.Height()-1)
 	}
 	return nil
 }
@@ -616,6 +814,10 @@ func NewManager(db database.DB, enabledIndexes []Indexer, params *chaincfg.Param
 		db:             db,
 		enabledIndexes: enabledIndexes,
 		params:         params,
+		tipHeights:     make([]int64, len(enabledIndexes)),
+		synced:         make([]bool, len(enabledIndexes)),
+		errs:           make([]error, len(enabledIndexes)),
+		quit:           make(chan struct{}),
 	}
 }
 
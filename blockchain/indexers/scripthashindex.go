@@ -0,0 +1,460 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/txscript"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
+)
+
+const (
+	// scriptHashIndexName is the human-readable name for the index.
+	scriptHashIndexName = "script hash index"
+
+	// scriptHashKeySize is the size of the keys used in the script hash
+	// index.  It consists of the script hash followed by the height of the
+	// block, the tree and the index of the transaction within it.
+	scriptHashKeySize = chainhash.HashSize + 4 + 1 + 4
+)
+
+var (
+	// scriptHashIndexKey is the key of the script hash index and the db
+	// bucket used to house it.
+	scriptHashIndexKey = []byte("scripthashidx")
+)
+
+// -----------------------------------------------------------------------------
+// The script hash index consists of an entry for every transaction in the main
+// chain which either pays to or spends an output with a given public key
+// script.  The scripts are identified by their script hash, which is the
+// sha256 hash of the script as used by the Electrum protocol.  The outputs in
+// the stake tree are tagged with a stake opcode, so they are additionally
+// indexed under the script hash of the script without the tag.  That way the
+// votes, tickets and revocations involving a script are part of its history.
+//
+// The keys are ordered by the height of the block and the position of the
+// transaction within it so the history of a script is in the order it appears
+// in the blockchain.  The height is serialized big endian for that reason.
+//
+// The serialized format for the keys and values in the script hash index
+// bucket is:
+//
+//   <script hash><block height><tree><tx index> = <tx hash>
+//
+//   Field           Type              Size
+//   script hash     chainhash.Hash    32 bytes
+//   block height    uint32            4 bytes
+//   tree            int8              1 byte
+//   tx index        uint32            4 bytes
+//   tx hash         chainhash.Hash    32 bytes
+//   -----
+//   Total: 73 bytes
+// -----------------------------------------------------------------------------
+
+// ScriptHashTx describes a transaction in the main chain which involves a
+// script.
+type ScriptHashTx struct {
+	Hash   chainhash.Hash
+	Height int64
+	Tree   int8
+	Index  uint32
+}
+
+// ScriptHashesForScript returns the script hashes the passed public key script
+// is indexed under.  Those are the sha256 hash of the script along with the
+// hash of the script without the stake opcode for scripts in the stake tree.
+func ScriptHashesForScript(pkScript []byte) []chainhash.Hash {
+	hashes := []chainhash.Hash{sha256.Sum256(pkScript)}
+	if len(pkScript) > 1 {
+		switch pkScript[0] {
+		case txscript.OP_SSTX, txscript.OP_SSGEN, txscript.OP_SSRTX,
+			txscript.OP_SSTXCHANGE:
+
+			hashes = append(hashes, sha256.Sum256(pkScript[1:]))
+		}
+	}
+	return hashes
+}
+
+// scriptHashKey returns the script hash index key for the passed script hash
+// and transaction position.
+func scriptHashKey(scriptHash *chainhash.Hash, height int64, tree int8, txIdx int) [scriptHashKeySize]byte {
+	var key [scriptHashKeySize]byte
+	offset := copy(key[:], scriptHash[:])
+	binary.BigEndian.PutUint32(key[offset:], uint32(height))
+	offset += 4
+	key[offset] = byte(tree)
+	binary.BigEndian.PutUint32(key[offset+1:], uint32(txIdx))
+	return key
+}
+
+// deserializeScriptHashTx decodes the passed script hash index entry.
+func deserializeScriptHashTx(key, serialized []byte) (*ScriptHashTx, error) {
+	if len(key) != scriptHashKeySize || len(serialized) != chainhash.HashSize {
+		return nil, errDeserialize(fmt.Sprintf("unexpected script hash "+
+			"index entry length %d/%d", len(key), len(serialized)))
+	}
+
+	var tx ScriptHashTx
+	copy(tx.Hash[:], serialized)
+	offset := chainhash.HashSize
+	tx.Height = int64(binary.BigEndian.Uint32(key[offset:]))
+	offset += 4
+	tx.Tree = int8(key[offset])
+	tx.Index = binary.BigEndian.Uint32(key[offset+1:])
+	return &tx, nil
+}
+
+// ScriptHashesForTx returns the script hashes of all of the scripts the passed
+// transaction pays to or spends from.  The scripts of the spent outputs are
+// looked up with the passed function, which returns nil for unknown outputs.
+// Those outputs are skipped.
+func ScriptHashesForTx(msgTx *wire.MsgTx, prevOutScript func(*wire.OutPoint) []byte) map[chainhash.Hash]struct{} {
+	hashes := make(map[chainhash.Hash]struct{})
+	for _, txIn := range msgTx.TxIn {
+		// Coinbase and stakebase inputs do not spend anything.
+		prevOut := &txIn.PreviousOutPoint
+		if prevOut.Hash == zeroHash {
+			continue
+		}
+
+		pkScript := prevOutScript(prevOut)
+		if pkScript == nil {
+			continue
+		}
+		for _, hash := range ScriptHashesForScript(pkScript) {
+			hashes[hash] = struct{}{}
+		}
+	}
+	for _, txOut := range msgTx.TxOut {
+		for _, hash := range ScriptHashesForScript(txOut.PkScript) {
+			hashes[hash] = struct{}{}
+		}
+	}
+	return hashes
+}
+
+// scriptHashesForTx returns the script hashes of all of the scripts the passed
+// transaction applied by a block pays to or spends from.  The scripts of the
+// spent outputs are looked up in the passed view.
+func scriptHashesForTx(at *appliedTx, view *blockchain.UtxoViewpoint) map[chainhash.Hash]struct{} {
+	return ScriptHashesForTx(at.tx.MsgTx(), func(prevOut *wire.OutPoint) []byte {
+		// The view should always have the input since the index
+		// contract requires it, however, be safe and simply ignore any
+		// missing entries.
+		entry := view.LookupEntry(&prevOut.Hash)
+		if entry == nil {
+			log.Warnf("Missing input %v for tx %v while indexing "+
+				"block %v (height %v)", prevOut.Hash, at.tx.Hash(),
+				at.block.Hash(), at.block.Height())
+			return nil
+		}
+		return entry.PkScriptByIndex(prevOut.Index)
+	})
+}
+
+// dbPutScriptHashIndexEntries adds a script hash index entry to the passed
+// bucket for every script each transaction applied when connecting the passed
+// block pays to or spends from.
+func dbPutScriptHashIndexEntries(bucket internalBucket, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
+	for _, at := range appliedTxns(block, parent) {
+		for scriptHash := range scriptHashesForTx(&at, view) {
+			key := scriptHashKey(&scriptHash, at.block.Height(),
+				at.tree, at.index)
+			if err := bucket.Put(key[:], at.tx.Hash()[:]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dbRemoveScriptHashIndexEntries removes the script hash index entries added by
+// dbPutScriptHashIndexEntries for the passed block from the passed bucket.
+func dbRemoveScriptHashIndexEntries(bucket internalBucket, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
+	for _, at := range appliedTxns(block, parent) {
+		for scriptHash := range scriptHashesForTx(&at, view) {
+			key := scriptHashKey(&scriptHash, at.block.Height(),
+				at.tree, at.index)
+			if err := bucket.Delete(key[:]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dbFetchScriptHashHistory returns the transactions in the main chain which
+// involve the script with the passed script hash using the passed cursor over
+// the script hash index bucket.
+func dbFetchScriptHashHistory(cursor database.Cursor, scriptHash *chainhash.Hash) ([]ScriptHashTx, error) {
+	prefix := scriptHash[:]
+	var history []ScriptHashTx
+	for ok := cursor.Seek(prefix); ok && bytes.HasPrefix(cursor.Key(),
+		prefix); ok = cursor.Next() {
+
+		tx, err := deserializeScriptHashTx(cursor.Key(), cursor.Value())
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, *tx)
+	}
+	return history, nil
+}
+
+// ScriptHashIndex implements a script hash history index.  That is to say, it
+// supports querying all of the transactions in the main chain which pay to or
+// spend from a given public key script as identified by its script hash.
+type ScriptHashIndex struct {
+	// The following fields are set when the instance is created and can't
+	// be changed afterwards, so there is no need to protect them with a
+	// separate mutex.
+	db database.DB
+
+	// The following fields are used to quickly look up the transactions
+	// involving scripts that have not been included into a block yet.
+	// They are protected by the unconfirmedLock field.
+	//
+	// The txnsByScriptHash field keeps the transactions keyed by the
+	// script hashes of the scripts they pay to or spend from.
+	//
+	// The scriptHashesByTx field is essentially the reverse and is used to
+	// efficiently remove the transactions once they are removed.
+	//
+	// The unconfirmedHandler field is invoked with the script hashes
+	// involved whenever a transaction is added or removed.
+	unconfirmedLock    sync.RWMutex
+	txnsByScriptHash   map[chainhash.Hash]map[chainhash.Hash]struct{}
+	scriptHashesByTx   map[chainhash.Hash]map[chainhash.Hash]struct{}
+	unconfirmedHandler func(scriptHashes map[chainhash.Hash]struct{})
+}
+
+// Ensure the ScriptHashIndex type implements the Indexer interface.
+var _ Indexer = (*ScriptHashIndex)(nil)
+
+// Ensure the ScriptHashIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*ScriptHashIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *ScriptHashIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptHashIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptHashIndex) Key() []byte {
+	return scriptHashIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptHashIndex) Name() string {
+	return scriptHashIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the script hash
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptHashIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(scriptHashIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry for every script
+// the transactions the block applies pay to or spend from.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptHashIndex) ConnectBlock(dbTx database.Tx, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(scriptHashIndexKey)
+	return dbPutScriptHashIndexEntries(bucket, block, parent, view)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries for every
+// script the transactions the block applied pay to or spend from.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptHashIndex) DisconnectBlock(dbTx database.Tx, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(scriptHashIndexKey)
+	return dbRemoveScriptHashIndexEntries(bucket, block, parent, view)
+}
+
+// History returns the transactions in the main chain which pay to or spend from
+// the script with the passed script hash in the order they appear in the
+// blockchain.
+//
+// NOTE: These results do not take unconfirmed transactions into account.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptHashIndex) History(scriptHash *chainhash.Hash) ([]ScriptHashTx, error) {
+	var history []ScriptHashTx
+	err := idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(scriptHashIndexKey).Cursor()
+		var err error
+		history, err = dbFetchScriptHashHistory(cursor, scriptHash)
+		return err
+	})
+	return history, err
+}
+
+// AddUnconfirmedTx adds the passed transaction to the unconfirmed (memory-only)
+// index under the script hashes of all of the scripts it pays to or spends
+// from.
+//
+// NOTE: This transaction MUST have already been validated by the memory pool
+// before calling this function with it and have all of the inputs available in
+// the provided utxo view.  Failure to do so could result in some or all scripts
+// not being indexed.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptHashIndex) AddUnconfirmedTx(tx *hcutil.Tx, utxoView *blockchain.UtxoViewpoint) {
+	hashes := ScriptHashesForTx(tx.MsgTx(), func(prevOut *wire.OutPoint) []byte {
+		// Ignore missing entries.  This should never happen in practice
+		// since the function comments specifically call out all inputs
+		// must be available.
+		entry := utxoView.LookupEntry(&prevOut.Hash)
+		if entry == nil {
+			return nil
+		}
+		return entry.PkScriptByIndex(prevOut.Index)
+	})
+
+	idx.unconfirmedLock.Lock()
+	txHash := *tx.Hash()
+	for scriptHash := range hashes {
+		txns := idx.txnsByScriptHash[scriptHash]
+		if txns == nil {
+			txns = make(map[chainhash.Hash]struct{})
+			idx.txnsByScriptHash[scriptHash] = txns
+		}
+		txns[txHash] = struct{}{}
+	}
+	idx.scriptHashesByTx[txHash] = hashes
+	handler := idx.unconfirmedHandler
+	idx.unconfirmedLock.Unlock()
+
+	if handler != nil && len(hashes) > 0 {
+		handler(hashes)
+	}
+}
+
+// RemoveUnconfirmedTx removes the passed transaction from the unconfirmed
+// (memory-only) index.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptHashIndex) RemoveUnconfirmedTx(hash *chainhash.Hash) {
+	idx.unconfirmedLock.Lock()
+	hashes := idx.scriptHashesByTx[*hash]
+	for scriptHash := range hashes {
+		delete(idx.txnsByScriptHash[scriptHash], *hash)
+		if len(idx.txnsByScriptHash[scriptHash]) == 0 {
+			delete(idx.txnsByScriptHash, scriptHash)
+		}
+	}
+	delete(idx.scriptHashesByTx, *hash)
+	handler := idx.unconfirmedHandler
+	idx.unconfirmedLock.Unlock()
+
+	if handler != nil && len(hashes) > 0 {
+		handler(hashes)
+	}
+}
+
+// UnconfirmedTxnsForScriptHash returns the hashes of the transactions in the
+// unconfirmed (memory-only) index which pay to or spend from the script with
+// the passed script hash.  They are ordered by hash so the order is stable.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptHashIndex) UnconfirmedTxnsForScriptHash(scriptHash *chainhash.Hash) []chainhash.Hash {
+	idx.unconfirmedLock.RLock()
+	txns := idx.txnsByScriptHash[*scriptHash]
+	hashes := make([]chainhash.Hash, 0, len(txns))
+	for txHash := range txns {
+		hashes = append(hashes, txHash)
+	}
+	idx.unconfirmedLock.RUnlock()
+
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+	return hashes
+}
+
+// UnconfirmedScriptHashesForTx returns the script hashes of the scripts the
+// transaction with the passed hash pays to or spends from when it is in the
+// unconfirmed (memory-only) index.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptHashIndex) UnconfirmedScriptHashesForTx(txHash *chainhash.Hash) []chainhash.Hash {
+	idx.unconfirmedLock.RLock()
+	hashes := make([]chainhash.Hash, 0, len(idx.scriptHashesByTx[*txHash]))
+	for scriptHash := range idx.scriptHashesByTx[*txHash] {
+		hashes = append(hashes, scriptHash)
+	}
+	idx.unconfirmedLock.RUnlock()
+	return hashes
+}
+
+// SetUnconfirmedHandler sets the function invoked with the script hashes of
+// the scripts a transaction pays to or spends from whenever it is added to or
+// removed from the unconfirmed (memory-only) index.  The function is invoked
+// while the memory pool is being modified, so it must not block or access the
+// memory pool.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptHashIndex) SetUnconfirmedHandler(handler func(scriptHashes map[chainhash.Hash]struct{})) {
+	idx.unconfirmedLock.Lock()
+	idx.unconfirmedHandler = handler
+	idx.unconfirmedLock.Unlock()
+}
+
+// NewScriptHashIndex returns a new instance of an indexer that is used to create
+// a mapping of the script hashes of all scripts in the blockchain to the
+// transactions which pay to or spend from them.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewScriptHashIndex(db database.DB) *ScriptHashIndex {
+	return &ScriptHashIndex{
+		db:               db,
+		txnsByScriptHash: make(map[chainhash.Hash]map[chainhash.Hash]struct{}),
+		scriptHashesByTx: make(map[chainhash.Hash]map[chainhash.Hash]struct{}),
+	}
+}
+
+// DropScriptHashIndex drops the script hash index from the provided database if
+// it exists.
+func DropScriptHashIndex(db database.DB) error {
+	return dropIndex(db, scriptHashIndexKey, scriptHashIndexName)
+}
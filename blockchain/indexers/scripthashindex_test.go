@@ -0,0 +1,196 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"crypto/sha256"
+	"reflect"
+	"testing"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/txscript"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
+)
+
+// TestScriptHashesForScript ensures stake tagged scripts are indexed under the
+// script hash of the untagged script as well.
+func TestScriptHashesForScript(t *testing.T) {
+	t.Parallel()
+
+	script, _ := p2pkhScript(0x01)
+	hashes := ScriptHashesForScript(script)
+	want := []chainhash.Hash{sha256.Sum256(script)}
+	if !reflect.DeepEqual(hashes, want) {
+		t.Fatalf("mismatched hashes -- got %v, want %v", hashes, want)
+	}
+
+	tagged := append([]byte{txscript.OP_SSGEN}, script...)
+	hashes = ScriptHashesForScript(tagged)
+	want = []chainhash.Hash{sha256.Sum256(tagged), sha256.Sum256(script)}
+	if !reflect.DeepEqual(hashes, want) {
+		t.Fatalf("mismatched tagged hashes -- got %v, want %v", hashes,
+			want)
+	}
+}
+
+// TestScriptHashIndexEntries ensures connecting and disconnecting blocks
+// updates the script hash index entries as expected.
+func TestScriptHashIndexEntries(t *testing.T) {
+	t.Parallel()
+
+	scriptA, _ := p2pkhScript(0xaa)
+	scriptB, _ := p2pkhScript(0xbb)
+	hashA := chainhash.Hash(sha256.Sum256(scriptA))
+	hashB := chainhash.Hash(sha256.Sum256(scriptB))
+
+	// The funding transaction was confirmed before and pays to script A.
+	fundingTx := wire.NewMsgTx()
+	fundingTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{0x01}},
+		nil))
+	fundingTx.AddTxOut(wire.NewTxOut(1000000000, scriptA))
+
+	// The parent contains a coinbase paying to script B and a transaction
+	// spending the funding output which pays to script B.
+	coinbase := wire.NewMsgTx()
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		nil))
+	coinbase.AddTxOut(wire.NewTxOut(200000000, scriptB))
+	parentTx := wire.NewMsgTx()
+	parentTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: fundingTx.TxHash()},
+		nil))
+	parentTx.AddTxOut(wire.NewTxOut(900000000, scriptB))
+	parent := hcutil.NewBlock(&wire.MsgBlock{
+		Header:       wire.BlockHeader{Height: 10},
+		Transactions: []*wire.MsgTx{coinbase, parentTx},
+	})
+
+	// The block approves the parent so its transactions are applied.
+	block := hcutil.NewBlock(&wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Height:   11,
+			VoteBits: hcutil.BlockValid,
+		},
+	})
+
+	view := blockchain.NewUtxoViewpoint()
+	view.AddTxOuts(hcutil.NewTx(fundingTx), 5, 0)
+
+	bucket := &addrUtxoIndexBucket{entries: make(map[string][]byte)}
+	err := dbPutScriptHashIndexEntries(bucket, block, parent, view)
+	if err != nil {
+		t.Fatalf("unexpected put error: %v", err)
+	}
+
+	// Ensure the history of both scripts is the expected one.
+	historyA, err := dbFetchScriptHashHistory(bucket.Cursor(), &hashA)
+	if err != nil {
+		t.Fatalf("unexpected fetch error: %v", err)
+	}
+	wantA := []ScriptHashTx{
+		{Hash: parentTx.TxHash(), Height: 10, Index: 1},
+	}
+	if !reflect.DeepEqual(historyA, wantA) {
+		t.Fatalf("mismatched history for script A -- got %+v, want %+v",
+			historyA, wantA)
+	}
+	historyB, err := dbFetchScriptHashHistory(bucket.Cursor(), &hashB)
+	if err != nil {
+		t.Fatalf("unexpected fetch error: %v", err)
+	}
+	wantB := []ScriptHashTx{
+		{Hash: coinbase.TxHash(), Height: 10, Index: 0},
+		{Hash: parentTx.TxHash(), Height: 10, Index: 1},
+	}
+	if !reflect.DeepEqual(historyB, wantB) {
+		t.Fatalf("mismatched history for script B -- got %+v, want %+v",
+			historyB, wantB)
+	}
+
+	// Ensure disconnecting the block removes all of the entries.
+	err = dbRemoveScriptHashIndexEntries(bucket, block, parent, view)
+	if err != nil {
+		t.Fatalf("unexpected remove error: %v", err)
+	}
+	if len(bucket.entries) != 0 {
+		t.Fatalf("unexpected entries after disconnect: %d",
+			len(bucket.entries))
+	}
+
+	// Ensure entries with the wrong size are rejected.
+	key := scriptHashKey(&hashA, 10, 0, 1)
+	_, err = deserializeScriptHashTx(key[:], make([]byte,
+		chainhash.HashSize-1))
+	if !isDeserializeErr(err) {
+		t.Fatalf("unexpected error for short entry -- got %v", err)
+	}
+}
+
+// TestScriptHashIndexUnconfirmed ensures unconfirmed transactions are added
+// and removed under the script hashes of the scripts they involve and that the
+// handler is notified of them.
+func TestScriptHashIndexUnconfirmed(t *testing.T) {
+	t.Parallel()
+
+	scriptA, _ := p2pkhScript(0xaa)
+	scriptB, _ := p2pkhScript(0xbb)
+	hashA := chainhash.Hash(sha256.Sum256(scriptA))
+	hashB := chainhash.Hash(sha256.Sum256(scriptB))
+
+	fundingTx := wire.NewMsgTx()
+	fundingTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{1}},
+		nil))
+	fundingTx.AddTxOut(wire.NewTxOut(1000000000, scriptA))
+	view := blockchain.NewUtxoViewpoint()
+	view.AddTxOuts(hcutil.NewTx(fundingTx), 5, 0)
+
+	spendTx := wire.NewMsgTx()
+	spendTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: fundingTx.TxHash()},
+		nil))
+	spendTx.AddTxOut(wire.NewTxOut(900000000, scriptB))
+	tx := hcutil.NewTx(spendTx)
+
+	idx := NewScriptHashIndex(nil)
+	var notified []map[chainhash.Hash]struct{}
+	idx.SetUnconfirmedHandler(func(scriptHashes map[chainhash.Hash]struct{}) {
+		notified = append(notified, scriptHashes)
+	})
+	wantNotified := map[chainhash.Hash]struct{}{hashA: {}, hashB: {}}
+
+	idx.AddUnconfirmedTx(tx, view)
+	want := []chainhash.Hash{*tx.Hash()}
+	for _, scriptHash := range []chainhash.Hash{hashA, hashB} {
+		got := idx.UnconfirmedTxnsForScriptHash(&scriptHash)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("mismatched transactions for %v -- got %v, "+
+				"want %v", scriptHash, got, want)
+		}
+	}
+	if got := idx.UnconfirmedScriptHashesForTx(tx.Hash()); len(got) != 2 {
+		t.Fatalf("unexpected script hashes for tx -- got %v", got)
+	}
+	if len(notified) != 1 || !reflect.DeepEqual(notified[0], wantNotified) {
+		t.Fatalf("unexpected notification after add -- got %v, want %v",
+			notified, wantNotified)
+	}
+
+	idx.RemoveUnconfirmedTx(tx.Hash())
+	if len(idx.UnconfirmedTxnsForScriptHash(&hashA)) != 0 ||
+		len(idx.UnconfirmedTxnsForScriptHash(&hashB)) != 0 ||
+		len(idx.txnsByScriptHash) != 0 || len(idx.scriptHashesByTx) != 0 {
+		t.Fatal("unconfirmed transactions remain after removal")
+	}
+	if len(notified) != 2 || !reflect.DeepEqual(notified[1], wantNotified) {
+		t.Fatalf("unexpected notification after removal -- got %v, "+
+			"want %v", notified, wantNotified)
+	}
+
+	// Removing a transaction which is not in the index must not notify.
+	idx.RemoveUnconfirmedTx(tx.Hash())
+	if len(notified) != 2 {
+		t.Fatalf("unexpected notification for unknown transaction")
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"fmt"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
+)
+
+const (
+	// spendIndexName is the human-readable name for the index.
+	spendIndexName = "spend index"
+
+	// spendKeySize is the size of the outpoint keys used in the spend
+	// index.  It consists of the hash, output index and tree of the
+	// outpoint.
+	spendKeySize = chainhash.HashSize + 4 + 1
+
+	// spendEntrySize is the size of the serialized spend index entries.
+	spendEntrySize = chainhash.HashSize + 4 + chainhash.HashSize + 4
+)
+
+var (
+	// spendIndexKey is the key of the spend index and the db bucket used
+	// to house it.
+	spendIndexKey = []byte("spendidx")
+
+	// zeroHash is the zero value hash used by the previous outpoint of
+	// coinbase and stakebase inputs.
+	zeroHash chainhash.Hash
+)
+
+// -----------------------------------------------------------------------------
+// The spend index consists of an entry for every output spent by a transaction
+// in the main chain.  This includes outputs in the stake tree, such as the
+// tickets spent by votes and revocations, as well as the outputs funding the
+// ticket commitments.  The coinbase and stakebase inputs do not spend any
+// output, so there are no entries for them.
+//
+// The block which contains the spending transaction is stored by hash rather
+// than by the internal block ID used by the transaction index so the spend
+// index does not require the transaction index.
+//
+// The serialized format for the keys and values in the spend index bucket is:
+//
+//   <outpoint hash><outpoint index><outpoint tree> =
+//     <spending txhash><input index><block hash><block height>
+//
+//   Field           Type              Size
+//   outpoint hash   chainhash.Hash    32 bytes
+//   outpoint index  uint32            4 bytes
+//   outpoint tree   int8              1 byte
+//   spending txhash chainhash.Hash    32 bytes
+//   input index     uint32            4 bytes
+//   block hash      chainhash.Hash    32 bytes
+//   block height    uint32            4 bytes
+//   -----
+//   Total: 109 bytes
+// -----------------------------------------------------------------------------
+
+// SpendInfo describes the transaction in the main chain which spends an
+// output.
+type SpendInfo struct {
+	TxHash      chainhash.Hash
+	InputIndex  uint32
+	BlockHash   chainhash.Hash
+	BlockHeight int64
+}
+
+// spendKey returns the spend index key for the passed outpoint.
+func spendKey(outpoint *wire.OutPoint) [spendKeySize]byte {
+	var key [spendKeySize]byte
+	copy(key[:], outpoint.Hash[:])
+	byteOrder.PutUint32(key[chainhash.HashSize:], outpoint.Index)
+	key[spendKeySize-1] = byte(outpoint.Tree)
+	return key
+}
+
+// serializeSpendEntry returns the serialized spend index entry for the passed
+// spend details.
+func serializeSpendEntry(txHash *chainhash.Hash, inputIndex uint32, blockHash *chainhash.Hash, blockHeight uint32) []byte {
+	serialized := make([]byte, spendEntrySize)
+	offset := copy(serialized, txHash[:])
+	byteOrder.PutUint32(serialized[offset:], inputIndex)
+	offset += 4
+	offset += copy(serialized[offset:], blockHash[:])
+	byteOrder.PutUint32(serialized[offset:], blockHeight)
+	return serialized
+}
+
+// deserializeSpendEntry decodes the passed serialized spend index entry.
+func deserializeSpendEntry(serialized []byte) (*SpendInfo, error) {
+	if len(serialized) != spendEntrySize {
+		return nil, errDeserialize(fmt.Sprintf("unexpected spend index "+
+			"entry length %d", len(serialized)))
+	}
+
+	var info SpendInfo
+	offset := copy(info.TxHash[:], serialized)
+	info.InputIndex = byteOrder.Uint32(serialized[offset:])
+	offset += 4
+	offset += copy(info.BlockHash[:], serialized[offset:])
+	info.BlockHeight = int64(byteOrder.Uint32(serialized[offset:]))
+	return &info, nil
+}
+
+// dbPutSpendIndexEntries adds a spend index entry to the passed bucket for
+// every output spent by the transactions applied when connecting the passed
+// block.
+func dbPutSpendIndexEntries(bucket internalBucket, block, parent *hcutil.Block) error {
+	for _, at := range appliedTxns(block, parent) {
+		for txInIdx, txIn := range at.tx.MsgTx().TxIn {
+			// Coinbase and stakebase inputs do not spend anything.
+			prevOut := &txIn.PreviousOutPoint
+			if prevOut.Hash == zeroHash {
+				continue
+			}
+
+			key := spendKey(prevOut)
+			entry := serializeSpendEntry(at.tx.Hash(),
+				uint32(txInIdx), at.block.Hash(),
+				uint32(at.block.Height()))
+			if err := bucket.Put(key[:], entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dbRemoveSpendIndexEntries removes the spend index entries from the passed
+// bucket for every output spent by the transactions applied when connecting
+// the passed block.
+func dbRemoveSpendIndexEntries(bucket internalBucket, block, parent *hcutil.Block) error {
+	for _, at := range appliedTxns(block, parent) {
+		for _, txIn := range at.tx.MsgTx().TxIn {
+			prevOut := &txIn.PreviousOutPoint
+			if prevOut.Hash == zeroHash {
+				continue
+			}
+
+			key := spendKey(prevOut)
+			if err := bucket.Delete(key[:]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SpendIndex implements a spent outpoint index.  That is to say, it supports
+// querying the transaction which spends an output in the main chain.
+type SpendIndex struct {
+	db database.DB
+}
+
+// Ensure the SpendIndex type implements the Indexer interface.
+var _ Indexer = (*SpendIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *SpendIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *SpendIndex) Key() []byte {
+	return spendIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *SpendIndex) Name() string {
+	return spendIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the spend
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *SpendIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(spendIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry for every output
+// spent by the transactions the block applies.
+//
+// This is part of the Indexer interface.
+func (idx *SpendIndex) ConnectBlock(dbTx database.Tx, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(spendIndexKey)
+	return dbPutSpendIndexEntries(bucket, block, parent)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries for
+// every output spent by the transactions the block applied since they are
+// unspent again.
+//
+// This is part of the Indexer interface.
+func (idx *SpendIndex) DisconnectBlock(dbTx database.Tx, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(spendIndexKey)
+	return dbRemoveSpendIndexEntries(bucket, block, parent)
+}
+
+// SpendInfo returns details about the transaction in the main chain which
+// spends the passed outpoint.  When the outpoint is not spent in the main
+// chain, nil will be returned for both the details and the error.
+//
+// This function is safe for concurrent access.
+func (idx *SpendIndex) SpendInfo(outpoint *wire.OutPoint) (*SpendInfo, error) {
+	var info *SpendInfo
+	err := idx.db.View(func(dbTx database.Tx) error {
+		key := spendKey(outpoint)
+		serialized := dbTx.Metadata().Bucket(spendIndexKey).Get(key[:])
+		if serialized == nil {
+			return nil
+		}
+
+		var err error
+		info, err = deserializeSpendEntry(serialized)
+		if err != nil {
+			return database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt spend index "+
+					"entry for %v: %v", outpoint, err),
+			}
+		}
+		return nil
+	})
+	return info, err
+}
+
+// NewSpendIndex returns a new instance of an indexer that is used to create a
+// mapping of all outputs spent in the blockchain to the transaction, input and
+// block which spends them.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewSpendIndex(db database.DB) *SpendIndex {
+	return &SpendIndex{db: db}
+}
+
+// DropSpendIndex drops the spend index from the provided database if it
+// exists.
+func DropSpendIndex(db database.DB) error {
+	return dropIndex(db, spendIndexKey, spendIndexName)
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
+)
+
+// spendIndexBucket provides a mock spend index database bucket by implementing
+// the internalBucket interface.
+type spendIndexBucket struct {
+	entries map[[spendKeySize]byte][]byte
+}
+
+// Get returns the value associated with the key from the mock spend index
+// bucket.
+//
+// This is part of the internalBucket interface.
+func (b *spendIndexBucket) Get(key []byte) []byte {
+	var spendKey [spendKeySize]byte
+	copy(spendKey[:], key)
+	return b.entries[spendKey]
+}
+
+// Put stores the provided key/value pair to the mock spend index bucket.
+//
+// This is part of the internalBucket interface.
+func (b *spendIndexBucket) Put(key []byte, value []byte) error {
+	var spendKey [spendKeySize]byte
+	copy(spendKey[:], key)
+	b.entries[spendKey] = value
+	return nil
+}
+
+// Delete removes the provided key from the mock spend index bucket.
+//
+// This is part of the internalBucket interface.
+func (b *spendIndexBucket) Delete(key []byte) error {
+	var spendKey [spendKeySize]byte
+	copy(spendKey[:], key)
+	delete(b.entries, spendKey)
+	return nil
+}
+
+// TestSpendEntrySerialization ensures serializing and deserializing spend index
+// entries works as expected.
+func TestSpendEntrySerialization(t *testing.T) {
+	t.Parallel()
+
+	want := SpendInfo{
+		TxHash:      chainhash.Hash{0x01, 0x02},
+		InputIndex:  3,
+		BlockHash:   chainhash.Hash{0x04, 0x05},
+		BlockHeight: 123456,
+	}
+	serialized := serializeSpendEntry(&want.TxHash, want.InputIndex,
+		&want.BlockHash, uint32(want.BlockHeight))
+	if len(serialized) != spendEntrySize {
+		t.Fatalf("unexpected serialized size -- got %d, want %d",
+			len(serialized), spendEntrySize)
+	}
+	got, err := deserializeSpendEntry(serialized)
+	if err != nil {
+		t.Fatalf("unexpected deserialize error: %v", err)
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Fatalf("mismatched entry -- got %+v, want %+v", *got, want)
+	}
+
+	// Ensure entries with the wrong size are rejected.
+	_, err = deserializeSpendEntry(serialized[:spendEntrySize-1])
+	if !isDeserializeErr(err) {
+		t.Fatalf("unexpected error for short entry -- got %v, want %T",
+			err, errDeserialize(""))
+	}
+}
+
+// TestSpendIndexEntries ensures the spend index entries added and removed when
+// connecting and disconnecting blocks are the expected ones.
+func TestSpendIndexEntries(t *testing.T) {
+	t.Parallel()
+
+	// newTx returns a transaction which spends the passed outpoints.
+	newTx := func(prevOuts ...wire.OutPoint) *wire.MsgTx {
+		tx := wire.NewMsgTx()
+		for i := range prevOuts {
+			tx.AddTxIn(wire.NewTxIn(&prevOuts[i], nil))
+		}
+		tx.AddTxOut(wire.NewTxOut(0, []byte{0x51}))
+		return tx
+	}
+
+	// The parent contains a coinbase and a regular transaction which
+	// spends two outputs.
+	regularOut0 := wire.OutPoint{Hash: chainhash.Hash{0x10}, Index: 0}
+	regularOut1 := wire.OutPoint{Hash: chainhash.Hash{0x11}, Index: 2}
+	coinbase := newTx(wire.OutPoint{Index: wire.MaxPrevOutIndex})
+	parentTx := newTx(regularOut0, regularOut1)
+	parent := hcutil.NewBlock(&wire.MsgBlock{
+		Header:       wire.BlockHeader{Height: 10},
+		Transactions: []*wire.MsgTx{coinbase, parentTx},
+	})
+
+	// The block contains a vote which spends a ticket from the stake tree
+	// along with a stakebase input.
+	ticketOut := wire.OutPoint{Hash: chainhash.Hash{0x20}, Index: 0,
+		Tree: wire.TxTreeStake}
+	vote := newTx(wire.OutPoint{Index: wire.MaxPrevOutIndex}, ticketOut)
+	newBlock := func(voteBits uint16) *hcutil.Block {
+		return hcutil.NewBlock(&wire.MsgBlock{
+			Header: wire.BlockHeader{
+				Height:   11,
+				VoteBits: voteBits,
+			},
+			STransactions: []*wire.MsgTx{vote},
+		})
+	}
+	voteTx := hcutil.NewTx(vote)
+	parentTxn := hcutil.NewTx(parentTx)
+
+	tests := []struct {
+		name  string
+		block *hcutil.Block
+		want  map[wire.OutPoint]SpendInfo
+	}{{
+		name:  "parent approved",
+		block: newBlock(hcutil.BlockValid),
+		want: map[wire.OutPoint]SpendInfo{
+			regularOut0: {
+				TxHash:      *parentTxn.Hash(),
+				InputIndex:  0,
+				BlockHash:   *parent.Hash(),
+				BlockHeight: 10,
+			},
+			regularOut1: {
+				TxHash:      *parentTxn.Hash(),
+				InputIndex:  1,
+				BlockHash:   *parent.Hash(),
+				BlockHeight: 10,
+			},
+			ticketOut: {
+				TxHash:      *voteTx.Hash(),
+				InputIndex:  1,
+				BlockHeight: 11,
+			},
+		},
+	}, {
+		name:  "parent disapproved",
+		block: newBlock(0),
+		want: map[wire.OutPoint]SpendInfo{
+			ticketOut: {
+				TxHash:      *voteTx.Hash(),
+				InputIndex:  1,
+				BlockHeight: 11,
+			},
+		},
+	}}
+
+	for _, test := range tests {
+		bucket := &spendIndexBucket{
+			entries: make(map[[spendKeySize]byte][]byte),
+		}
+		err := dbPutSpendIndexEntries(bucket, test.block, parent)
+		if err != nil {
+			t.Errorf("%s: unexpected put error: %v", test.name, err)
+			continue
+		}
+		if len(bucket.entries) != len(test.want) {
+			t.Errorf("%s: unexpected number of entries -- got %d, "+
+				"want %d", test.name, len(bucket.entries),
+				len(test.want))
+			continue
+		}
+		for outpoint, want := range test.want {
+			if want.BlockHeight == test.block.Height() {
+				want.BlockHash = *test.block.Hash()
+			}
+			key := spendKey(&outpoint)
+			serialized := bucket.Get(key[:])
+			wantSerialized := serializeSpendEntry(&want.TxHash,
+				want.InputIndex, &want.BlockHash,
+				uint32(want.BlockHeight))
+			if !bytes.Equal(serialized, wantSerialized) {
+				t.Errorf("%s: mismatched entry for %v -- got %x, "+
+					"want %x", test.name, outpoint, serialized,
+					wantSerialized)
+			}
+		}
+
+		// Ensure all of the entries are removed when disconnecting.
+		err = dbRemoveSpendIndexEntries(bucket, test.block, parent)
+		if err != nil {
+			t.Errorf("%s: unexpected remove error: %v", test.name, err)
+			continue
+		}
+		if len(bucket.entries) != 0 {
+			t.Errorf("%s: %d entries remain after disconnect",
+				test.name, len(bucket.entries))
+		}
+	}
+}
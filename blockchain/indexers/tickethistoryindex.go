@@ -0,0 +1,643 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/blockchain/stake"
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
+)
+
+const (
+	// ticketHistoryIndexName is the human-readable name for the index.
+	ticketHistoryIndexName = "ticket history index"
+
+	// ticketPrefix is the prefix of the keys which identify the history
+	// entries of tickets in the ticket history index bucket.
+	ticketPrefix = 't'
+
+	// ticketStatusPrefix is the prefix of the keys which map the status of
+	// tickets to the tickets in the ticket history index bucket.
+	ticketStatusPrefix = 's'
+
+	// ticketAddrPrefix is the prefix of the keys which map addresses to the
+	// tickets they are involved in in the ticket history index bucket.
+	ticketAddrPrefix = 'a'
+
+	// ticketMissedPrefix is the prefix of the keys which identify the
+	// tickets which were missed or expired by a block in the ticket
+	// history index bucket.
+	ticketMissedPrefix = 'm'
+
+	// ticketKeySize is the size of the ticket history keys.  It consists of
+	// the prefix and the ticket hash.
+	ticketKeySize = 1 + chainhash.HashSize
+
+	// ticketEntrySize is the size of the serialized ticket history values.
+	ticketEntrySize = chainhash.HashSize + 4 + 8 + 1 + 4 + 1 +
+		chainhash.HashSize + 4
+)
+
+var (
+	// ticketHistoryIndexKey is the key of the ticket history index and the
+	// db bucket used to house it.
+	ticketHistoryIndexKey = []byte("tickethistoryidx")
+)
+
+// -----------------------------------------------------------------------------
+// The ticket history index records every state transition of each ticket
+// purchased in the main chain.  The purchase, vote and revocation of a ticket
+// are determined from the stake transactions of the blocks while the tickets
+// which were missed or expired by a block are obtained from the ticket undo
+// data the stake database stores for every block.
+//
+// All entries are stored in the same bucket and are distinguished by a one byte
+// prefix.
+//
+// The serialized format for the ticket history entries is:
+//
+//   <'t'><ticket hash> =
+//     <purchase block hash><purchase height><price><status><missed height>
+//     <expired><spend txhash><spend height>
+//
+//   Field               Type              Size
+//   prefix              byte              1 byte
+//   ticket hash         chainhash.Hash    32 bytes
+//   purchase block hash chainhash.Hash    32 bytes
+//   purchase height     uint32            4 bytes
+//   price               int64             8 bytes
+//   status              uint8             1 byte
+//   missed height       uint32            4 bytes
+//   expired             bool              1 byte
+//   spend txhash        chainhash.Hash    32 bytes
+//   spend height        uint32            4 bytes
+//
+// The missed height is zero unless the ticket was missed or expired and the
+// spend fields are zero unless the ticket was spent by a vote or revocation.
+//
+// In order to efficiently list tickets, there are also entries without values
+// which map the status of the tickets and the addresses involved in them to the
+// tickets:
+//
+//   <'s'><status><ticket hash>
+//   <'a'><addr key><ticket hash>
+//
+// The addresses involved in a ticket are the voting address and the addresses
+// of the commitments.
+//
+// Finally, since the ticket undo data is removed from the stake database before
+// a block is disconnected, the tickets missed or expired by each block are
+// recorded as well:
+//
+//   <'m'><block height> = <ticket hash>...
+// -----------------------------------------------------------------------------
+
+// TicketStatus describes the state of a ticket according to the ticket history
+// index.
+type TicketStatus uint8
+
+// These constants define the possible states of a ticket.
+const (
+	// TicketUnspent indicates the ticket is either immature or live.
+	TicketUnspent TicketStatus = iota
+
+	// TicketVoted indicates the ticket was spent by a vote.
+	TicketVoted
+
+	// TicketMissed indicates the ticket was selected to vote but did not.
+	TicketMissed
+
+	// TicketExpired indicates the ticket was never selected to vote before
+	// it expired.
+	TicketExpired
+
+	// TicketRevoked indicates the ticket was missed or expired and spent by
+	// a revocation afterwards.
+	TicketRevoked
+)
+
+// TicketHistory describes the history of a ticket in the main chain.
+type TicketHistory struct {
+	Hash           chainhash.Hash
+	PurchaseBlock  chainhash.Hash
+	PurchaseHeight int64
+	Price          int64
+	Status         TicketStatus
+	MissedHeight   int64
+	Expired        bool
+	SpendTxHash    chainhash.Hash
+	SpendHeight    int64
+}
+
+// ticketKey returns the ticket history index key for the passed ticket hash.
+func ticketKey(hash *chainhash.Hash) [ticketKeySize]byte {
+	var key [ticketKeySize]byte
+	key[0] = ticketPrefix
+	copy(key[1:], hash[:])
+	return key
+}
+
+// ticketStatusKey returns the key which maps the passed status to the passed
+// ticket hash.
+func ticketStatusKey(status TicketStatus, hash *chainhash.Hash) []byte {
+	key := make([]byte, 2+chainhash.HashSize)
+	key[0] = ticketStatusPrefix
+	key[1] = byte(status)
+	copy(key[2:], hash[:])
+	return key
+}
+
+// ticketAddrKey returns the key which maps the passed address key to the passed
+// ticket hash.
+func ticketAddrKey(addrKey [addrKeySize]byte, hash *chainhash.Hash) []byte {
+	key := make([]byte, 1+addrKeySize+chainhash.HashSize)
+	key[0] = ticketAddrPrefix
+	copy(key[1:], addrKey[:])
+	copy(key[1+addrKeySize:], hash[:])
+	return key
+}
+
+// ticketMissedKey returns the key of the tickets missed or expired by the block
+// at the passed height.
+func ticketMissedKey(height uint32) []byte {
+	key := make([]byte, 5)
+	key[0] = ticketMissedPrefix
+	byteOrder.PutUint32(key[1:], height)
+	return key
+}
+
+// serializeTicketHistory returns the serialized ticket history index entry for
+// the passed ticket history.
+func serializeTicketHistory(history *TicketHistory) []byte {
+	serialized := make([]byte, ticketEntrySize)
+	offset := copy(serialized, history.PurchaseBlock[:])
+	byteOrder.PutUint32(serialized[offset:], uint32(history.PurchaseHeight))
+	offset += 4
+	byteOrder.PutUint64(serialized[offset:], uint64(history.Price))
+	offset += 8
+	serialized[offset] = byte(history.Status)
+	offset++
+	byteOrder.PutUint32(serialized[offset:], uint32(history.MissedHeight))
+	offset += 4
+	if history.Expired {
+		serialized[offset] = 1
+	}
+	offset++
+	offset += copy(serialized[offset:], history.SpendTxHash[:])
+	byteOrder.PutUint32(serialized[offset:], uint32(history.SpendHeight))
+	return serialized
+}
+
+// deserializeTicketHistory decodes the passed serialized ticket history index
+// entry for the passed ticket hash.
+func deserializeTicketHistory(hash *chainhash.Hash, serialized []byte) (*TicketHistory, error) {
+	if len(serialized) != ticketEntrySize {
+		return nil, errDeserialize(fmt.Sprintf("unexpected ticket "+
+			"history entry length %d", len(serialized)))
+	}
+
+	history := TicketHistory{Hash: *hash}
+	offset := copy(history.PurchaseBlock[:], serialized)
+	history.PurchaseHeight = int64(byteOrder.Uint32(serialized[offset:]))
+	offset += 4
+	history.Price = int64(byteOrder.Uint64(serialized[offset:]))
+	offset += 8
+	history.Status = TicketStatus(serialized[offset])
+	offset++
+	history.MissedHeight = int64(byteOrder.Uint32(serialized[offset:]))
+	offset += 4
+	history.Expired = serialized[offset] != 0
+	offset++
+	offset += copy(history.SpendTxHash[:], serialized[offset:])
+	history.SpendHeight = int64(byteOrder.Uint32(serialized[offset:]))
+	return &history, nil
+}
+
+// dbFetchTicketHistory fetches the history of the passed ticket from the passed
+// bucket.  An error is returned when there is no entry for the ticket since
+// all tickets in the main chain are expected to have one.
+func dbFetchTicketHistory(bucket internalBucket, hash *chainhash.Hash) (*TicketHistory, error) {
+	key := ticketKey(hash)
+	serialized := bucket.Get(key[:])
+	if serialized == nil {
+		return nil, AssertError(fmt.Sprintf("missing ticket history "+
+			"entry for %v", hash))
+	}
+	return deserializeTicketHistory(hash, serialized)
+}
+
+// dbPutTicketHistory stores the passed ticket history in the passed bucket and
+// moves the status entry of the ticket from the passed old status to the
+// current one.
+func dbPutTicketHistory(bucket internalBucket, history *TicketHistory, oldStatus TicketStatus) error {
+	if oldStatus != history.Status {
+		err := bucket.Delete(ticketStatusKey(oldStatus, &history.Hash))
+		if err != nil {
+			return err
+		}
+	}
+	err := bucket.Put(ticketStatusKey(history.Status, &history.Hash), nil)
+	if err != nil {
+		return err
+	}
+
+	key := ticketKey(&history.Hash)
+	return bucket.Put(key[:], serializeTicketHistory(history))
+}
+
+// ticketAddrKeys returns the address keys of the voting address and the
+// commitment addresses of the passed ticket purchase.
+func ticketAddrKeys(msgTx *wire.MsgTx, params *chaincfg.Params) [][addrKeySize]byte {
+	var addrKeys [][addrKeySize]byte
+	for txOutIdx, txOut := range msgTx.TxOut {
+		switch {
+		case txOutIdx == 0:
+			addrKeys = append(addrKeys, addrKeysForScript(
+				txOut.Version, txOut.PkScript, params)...)
+
+		case stake.IsStakeSubmissionTxOut(txOutIdx):
+			addr, err := stake.AddrFromSStxPkScrCommitment(
+				txOut.PkScript, params)
+			if err != nil {
+				continue
+			}
+			addrKey, err := addrToKey(addr, params)
+			if err != nil {
+				continue
+			}
+			addrKeys = append(addrKeys, addrKey)
+		}
+	}
+	return addrKeys
+}
+
+// dbPutTicketHistoryEntries updates the passed bucket for the ticket purchases,
+// votes and revocations in the passed block along with the tickets the passed
+// ticket undo data of the block reports as missed or expired.
+func dbPutTicketHistoryEntries(bucket internalBucket, block *hcutil.Block, undoData stake.UndoTicketDataSlice, params *chaincfg.Params) error {
+	height := uint32(block.Height())
+	for _, stx := range block.STransactions() {
+		msgTx := stx.MsgTx()
+		switch stake.DetermineTxType(msgTx) {
+		case stake.TxTypeSStx:
+			history := TicketHistory{
+				Hash:           *stx.Hash(),
+				PurchaseBlock:  *block.Hash(),
+				PurchaseHeight: int64(height),
+				Price:          msgTx.TxOut[0].Value,
+				Status:         TicketUnspent,
+			}
+			err := dbPutTicketHistory(bucket, &history, TicketUnspent)
+			if err != nil {
+				return err
+			}
+			for _, addrKey := range ticketAddrKeys(msgTx, params) {
+				key := ticketAddrKey(addrKey, stx.Hash())
+				if err := bucket.Put(key, nil); err != nil {
+					return err
+				}
+			}
+
+		case stake.TxTypeSSGen, stake.TxTypeSSRtx:
+			// Votes spend the ticket with their second input since
+			// the first one is the stakebase while revocations
+			// spend it with their only input.
+			status, ticketIdx := TicketVoted, 1
+			if stake.DetermineTxType(msgTx) == stake.TxTypeSSRtx {
+				status, ticketIdx = TicketRevoked, 0
+			}
+			ticketHash := &msgTx.TxIn[ticketIdx].PreviousOutPoint.Hash
+			history, err := dbFetchTicketHistory(bucket, ticketHash)
+			if err != nil {
+				return err
+			}
+			oldStatus := history.Status
+			history.Status = status
+			history.SpendTxHash = *stx.Hash()
+			history.SpendHeight = int64(height)
+			err = dbPutTicketHistory(bucket, history, oldStatus)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Record the tickets which were missed or expired by the block.  The
+	// undo data also marks revoked tickets as missed, so they must be
+	// skipped since they were already missed by an earlier block.
+	var missed []byte
+	for _, undo := range undoData {
+		if !undo.Missed || undo.Revoked {
+			continue
+		}
+
+		history, err := dbFetchTicketHistory(bucket, &undo.TicketHash)
+		if err != nil {
+			return err
+		}
+		oldStatus := history.Status
+		history.Status = TicketMissed
+		if undo.Expired {
+			history.Status = TicketExpired
+		}
+		history.MissedHeight = int64(height)
+		history.Expired = undo.Expired
+		err = dbPutTicketHistory(bucket, history, oldStatus)
+		if err != nil {
+			return err
+		}
+		missed = append(missed, undo.TicketHash[:]...)
+	}
+	if len(missed) == 0 {
+		return nil
+	}
+	return bucket.Put(ticketMissedKey(height), missed)
+}
+
+// dbRemoveTicketHistoryEntries reverses the updates made to the passed bucket
+// when connecting the passed block.
+func dbRemoveTicketHistoryEntries(bucket internalBucket, block *hcutil.Block, params *chaincfg.Params) error {
+	// Restore the tickets which were missed or expired by the block to
+	// their previous state.
+	height := uint32(block.Height())
+	missed := bucket.Get(ticketMissedKey(height))
+	if len(missed)%chainhash.HashSize != 0 {
+		return errDeserialize(fmt.Sprintf("unexpected missed tickets "+
+			"entry length %d", len(missed)))
+	}
+	for offset := 0; offset < len(missed); offset += chainhash.HashSize {
+		var ticketHash chainhash.Hash
+		copy(ticketHash[:], missed[offset:])
+		history, err := dbFetchTicketHistory(bucket, &ticketHash)
+		if err != nil {
+			return err
+		}
+		oldStatus := history.Status
+		history.Status = TicketUnspent
+		history.MissedHeight = 0
+		history.Expired = false
+		err = dbPutTicketHistory(bucket, history, oldStatus)
+		if err != nil {
+			return err
+		}
+	}
+	if err := bucket.Delete(ticketMissedKey(height)); err != nil {
+		return err
+	}
+
+	// Undo the stake transactions in reverse order.
+	stxns := block.STransactions()
+	for i := len(stxns) - 1; i >= 0; i-- {
+		stx := stxns[i]
+		msgTx := stx.MsgTx()
+		switch stake.DetermineTxType(msgTx) {
+		case stake.TxTypeSStx:
+			key := ticketKey(stx.Hash())
+			if err := bucket.Delete(key[:]); err != nil {
+				return err
+			}
+			err := bucket.Delete(ticketStatusKey(TicketUnspent,
+				stx.Hash()))
+			if err != nil {
+				return err
+			}
+			for _, addrKey := range ticketAddrKeys(msgTx, params) {
+				key := ticketAddrKey(addrKey, stx.Hash())
+				if err := bucket.Delete(key); err != nil {
+					return err
+				}
+			}
+
+		case stake.TxTypeSSGen, stake.TxTypeSSRtx:
+			ticketIdx := 1
+			if stake.DetermineTxType(msgTx) == stake.TxTypeSSRtx {
+				ticketIdx = 0
+			}
+			ticketHash := &msgTx.TxIn[ticketIdx].PreviousOutPoint.Hash
+			history, err := dbFetchTicketHistory(bucket, ticketHash)
+			if err != nil {
+				return err
+			}
+
+			// Revoked tickets go back to being missed or expired
+			// while voted tickets become live again.
+			oldStatus := history.Status
+			switch {
+			case oldStatus == TicketVoted:
+				history.Status = TicketUnspent
+			case history.Expired:
+				history.Status = TicketExpired
+			default:
+				history.Status = TicketMissed
+			}
+			history.SpendTxHash = chainhash.Hash{}
+			history.SpendHeight = 0
+			err = dbPutTicketHistory(bucket, history, oldStatus)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dbFetchTicketHistories returns the histories of the tickets identified by the
+// keys with the passed prefix, which must end with the ticket hash, that are
+// accepted by the passed filter according to the specified number to skip and
+// number requested using the passed bucket and cursor over it.  It also returns
+// the number actually skipped since it could be less in the case where there
+// are not enough entries.
+func dbFetchTicketHistories(bucket internalBucket, cursor database.Cursor, prefix []byte, filter func(*TicketHistory) bool, numToSkip, numRequested uint32) ([]TicketHistory, uint32, error) {
+	var histories []TicketHistory
+	var skipped uint32
+	for ok := cursor.Seek(prefix); ok && bytes.HasPrefix(cursor.Key(),
+		prefix); ok = cursor.Next() {
+
+		if uint32(len(histories)) >= numRequested {
+			break
+		}
+
+		key := cursor.Key()
+		var ticketHash chainhash.Hash
+		copy(ticketHash[:], key[len(key)-chainhash.HashSize:])
+		history, err := dbFetchTicketHistory(bucket, &ticketHash)
+		if err != nil {
+			return nil, 0, err
+		}
+		if filter != nil && !filter(history) {
+			continue
+		}
+		if skipped < numToSkip {
+			skipped++
+			continue
+		}
+		histories = append(histories, *history)
+	}
+	return histories, skipped, nil
+}
+
+// TicketHistoryIndex implements a ticket history index.  That is to say, it
+// supports querying when a ticket was purchased and whether and when it was
+// voted, missed, expired or revoked, as well as listing the tickets with a
+// given status or involving a given address.
+type TicketHistoryIndex struct {
+	db          database.DB
+	chainParams *chaincfg.Params
+}
+
+// Ensure the TicketHistoryIndex type implements the Indexer interface.
+var _ Indexer = (*TicketHistoryIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *TicketHistoryIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *TicketHistoryIndex) Key() []byte {
+	return ticketHistoryIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *TicketHistoryIndex) Name() string {
+	return ticketHistoryIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the ticket
+// history index.
+//
+// This is part of the Indexer interface.
+func (idx *TicketHistoryIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(ticketHistoryIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer records the ticket purchases,
+// votes and revocations in the block along with the tickets it caused to be
+// missed or expired.
+//
+// This is part of the Indexer interface.
+func (idx *TicketHistoryIndex) ConnectBlock(dbTx database.Tx, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
+	// The stake database has already been updated for the block, so its
+	// undo data reports the tickets the block caused to be missed.
+	undoData, err := stake.FetchBlockUndoData(dbTx, uint32(block.Height()))
+	if err != nil {
+		return err
+	}
+
+	bucket := dbTx.Metadata().Bucket(ticketHistoryIndexKey)
+	return dbPutTicketHistoryEntries(bucket, block, undoData,
+		idx.chainParams)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer reverses the ticket state
+// transitions recorded for the block.
+//
+// This is part of the Indexer interface.
+func (idx *TicketHistoryIndex) DisconnectBlock(dbTx database.Tx, block, parent *hcutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(ticketHistoryIndexKey)
+	return dbRemoveTicketHistoryEntries(bucket, block, idx.chainParams)
+}
+
+// TicketHistory returns the history of the passed ticket in the main chain.
+// When the ticket was not purchased in the main chain, nil will be returned for
+// both the history and the error.
+//
+// This function is safe for concurrent access.
+func (idx *TicketHistoryIndex) TicketHistory(hash *chainhash.Hash) (*TicketHistory, error) {
+	var history *TicketHistory
+	err := idx.db.View(func(dbTx database.Tx) error {
+		key := ticketKey(hash)
+		serialized := dbTx.Metadata().Bucket(ticketHistoryIndexKey).Get(key[:])
+		if serialized == nil {
+			return nil
+		}
+
+		var err error
+		history, err = deserializeTicketHistory(hash, serialized)
+		if err != nil {
+			return database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt ticket history "+
+					"entry for %v: %v", hash, err),
+			}
+		}
+		return nil
+	})
+	return history, err
+}
+
+// TicketsByStatus returns the histories of the tickets in the main chain with
+// the passed status which are accepted by the passed filter, if any, according
+// to the specified number to skip and number requested.  When an address is
+// provided, only the tickets which involve it as the voting address or as a
+// commitment address are returned.  It also returns the number actually skipped
+// since it could be less in the case where there are not enough entries.
+//
+// This function is safe for concurrent access.
+func (idx *TicketHistoryIndex) TicketsByStatus(status TicketStatus, addr hcutil.Address, filter func(*TicketHistory) bool, numToSkip, numRequested uint32) ([]TicketHistory, uint32, error) {
+	// Scan the status entries when no address is provided and the address
+	// entries, which do not discriminate between statuses, otherwise.
+	prefix := []byte{ticketStatusPrefix, byte(status)}
+	if addr != nil {
+		addrKey, err := addrToKey(addr, idx.chainParams)
+		if err != nil {
+			return nil, 0, err
+		}
+		prefix = append([]byte{ticketAddrPrefix}, addrKey[:]...)
+		extraFilter := filter
+		filter = func(history *TicketHistory) bool {
+			if history.Status != status {
+				return false
+			}
+			return extraFilter == nil || extraFilter(history)
+		}
+	}
+
+	var histories []TicketHistory
+	var skipped uint32
+	err := idx.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(ticketHistoryIndexKey)
+		var err error
+		histories, skipped, err = dbFetchTicketHistories(bucket,
+			bucket.Cursor(), prefix, filter, numToSkip, numRequested)
+		return err
+	})
+	return histories, skipped, err
+}
+
+// NewTicketHistoryIndex returns a new instance of an indexer that is used to
+// create a mapping of all tickets purchased in the blockchain to their history.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewTicketHistoryIndex(db database.DB, chainParams *chaincfg.Params) *TicketHistoryIndex {
+	return &TicketHistoryIndex{db: db, chainParams: chainParams}
+}
+
+// DropTicketHistoryIndex drops the ticket history index from the provided
+// database if it exists.
+func DropTicketHistoryIndex(db database.DB) error {
+	return dropIndex(db, ticketHistoryIndexKey, ticketHistoryIndexName)
+}
@@ -0,0 +1,288 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/coolsnady/hcd/blockchain/stake"
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/txscript"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
+)
+
+// TestTicketHistorySerialization ensures serializing and deserializing ticket
+// history index entries works as expected.
+func TestTicketHistorySerialization(t *testing.T) {
+	t.Parallel()
+
+	want := TicketHistory{
+		Hash:           chainhash.Hash{0x01},
+		PurchaseBlock:  chainhash.Hash{0x02},
+		PurchaseHeight: 1000,
+		Price:          1500000000,
+		Status:         TicketRevoked,
+		MissedHeight:   9000,
+		Expired:        true,
+		SpendTxHash:    chainhash.Hash{0x03},
+		SpendHeight:    9010,
+	}
+	serialized := serializeTicketHistory(&want)
+	if len(serialized) != ticketEntrySize {
+		t.Fatalf("unexpected serialized size -- got %d, want %d",
+			len(serialized), ticketEntrySize)
+	}
+	got, err := deserializeTicketHistory(&want.Hash, serialized)
+	if err != nil {
+		t.Fatalf("unexpected deserialize error: %v", err)
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Fatalf("mismatched entry -- got %+v, want %+v", *got, want)
+	}
+
+	// Ensure entries with the wrong size are rejected.
+	_, err = deserializeTicketHistory(&want.Hash, serialized[1:])
+	if !isDeserializeErr(err) {
+		t.Fatalf("unexpected error for short entry -- got %v, want %T",
+			err, errDeserialize(""))
+	}
+}
+
+// TestTicketHistoryIndexEntries ensures the ticket history index tracks the
+// purchase, vote, miss and revocation of tickets as blocks are connected and
+// restores the previous state as they are disconnected.
+func TestTicketHistoryIndexEntries(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.MainNetParams
+	votingScript, votingAddrKey := p2pkhScript(0x01)
+	_, commitAddrKey := p2pkhScript(0x02)
+
+	// newTicket returns a ticket purchase which pays to the voting script
+	// and commits to the commitment address.
+	newTicket := func(prevOut wire.OutPoint) *wire.MsgTx {
+		tx := wire.NewMsgTx()
+		tx.AddTxIn(wire.NewTxIn(&prevOut, nil))
+		tx.AddTxOut(wire.NewTxOut(200000000, append([]byte{
+			txscript.OP_SSTX}, votingScript...)))
+		commitment := []byte{txscript.OP_RETURN, txscript.OP_DATA_30}
+		commitment = append(commitment, commitAddrKey[1:]...)
+		commitment = append(commitment, 0x00, 0xc2, 0xeb, 0x0b, 0, 0, 0,
+			0, 0x00, 0x58)
+		tx.AddTxOut(wire.NewTxOut(0, commitment))
+		changeScript, _ := p2pkhScript(0x03)
+		tx.AddTxOut(wire.NewTxOut(0, append([]byte{
+			txscript.OP_SSTXCHANGE}, changeScript...)))
+		return tx
+	}
+	ticket0 := newTicket(wire.OutPoint{Hash: chainhash.Hash{0x10}})
+	ticket1 := newTicket(wire.OutPoint{Hash: chainhash.Hash{0x11}})
+	ticket0Hash := ticket0.TxHash()
+	ticket1Hash := ticket1.TxHash()
+
+	// The first ticket votes while the second one is missed and revoked.
+	vote := wire.NewMsgTx()
+	vote.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		nil))
+	vote.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: ticket0Hash,
+		Tree: wire.TxTreeStake}, nil))
+	blockRef, _ := txscript.GenerateSSGenBlockRef(chainhash.Hash{0x20}, 199)
+	vote.AddTxOut(wire.NewTxOut(0, blockRef))
+	voteBits, _ := txscript.GenerateSSGenVotes(hcutil.BlockValid)
+	vote.AddTxOut(wire.NewTxOut(0, voteBits))
+	vote.AddTxOut(wire.NewTxOut(200000000, append([]byte{
+		txscript.OP_SSGEN}, votingScript...)))
+	revocation := wire.NewMsgTx()
+	revocation.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: ticket1Hash,
+		Tree: wire.TxTreeStake}, nil))
+	revocation.AddTxOut(wire.NewTxOut(200000000, append([]byte{
+		txscript.OP_SSRTX}, votingScript...)))
+	for _, tx := range []struct {
+		msgTx  *wire.MsgTx
+		txType stake.TxType
+	}{
+		{ticket0, stake.TxTypeSStx},
+		{vote, stake.TxTypeSSGen},
+		{revocation, stake.TxTypeSSRtx},
+	} {
+		if txType := stake.DetermineTxType(tx.msgTx); txType != tx.txType {
+			t.Fatalf("unexpected transaction type -- got %v, want %v",
+				txType, tx.txType)
+		}
+	}
+
+	newBlock := func(height uint32, stxns ...*wire.MsgTx) *hcutil.Block {
+		return hcutil.NewBlock(&wire.MsgBlock{
+			Header:        wire.BlockHeader{Height: height},
+			STransactions: stxns,
+		})
+	}
+	purchaseBlock := newBlock(100, ticket0, ticket1)
+	missBlock := newBlock(200, vote)
+	revokeBlock := newBlock(201, revocation)
+	missUndo := stake.UndoTicketDataSlice{
+		{TicketHash: ticket0Hash, TicketHeight: 100, Spent: true},
+		{TicketHash: ticket1Hash, TicketHeight: 100, Missed: true},
+	}
+	revokeUndo := stake.UndoTicketDataSlice{
+		{TicketHash: ticket1Hash, TicketHeight: 100, Missed: true,
+			Revoked: true},
+	}
+
+	tests := []struct {
+		name  string
+		block *hcutil.Block
+		undo  stake.UndoTicketDataSlice
+		want  map[chainhash.Hash]TicketHistory
+	}{{
+		name:  "purchase",
+		block: purchaseBlock,
+		want: map[chainhash.Hash]TicketHistory{
+			ticket0Hash: {
+				Hash:           ticket0Hash,
+				PurchaseBlock:  *purchaseBlock.Hash(),
+				PurchaseHeight: 100,
+				Price:          200000000,
+				Status:         TicketUnspent,
+			},
+			ticket1Hash: {
+				Hash:           ticket1Hash,
+				PurchaseBlock:  *purchaseBlock.Hash(),
+				PurchaseHeight: 100,
+				Price:          200000000,
+				Status:         TicketUnspent,
+			},
+		},
+	}, {
+		name:  "vote and miss",
+		block: missBlock,
+		undo:  missUndo,
+		want: map[chainhash.Hash]TicketHistory{
+			ticket0Hash: {
+				Hash:           ticket0Hash,
+				PurchaseBlock:  *purchaseBlock.Hash(),
+				PurchaseHeight: 100,
+				Price:          200000000,
+				Status:         TicketVoted,
+				SpendTxHash:    vote.TxHash(),
+				SpendHeight:    200,
+			},
+			ticket1Hash: {
+				Hash:           ticket1Hash,
+				PurchaseBlock:  *purchaseBlock.Hash(),
+				PurchaseHeight: 100,
+				Price:          200000000,
+				Status:         TicketMissed,
+				MissedHeight:   200,
+			},
+		},
+	}, {
+		name:  "revoke",
+		block: revokeBlock,
+		undo:  revokeUndo,
+		want: map[chainhash.Hash]TicketHistory{
+			ticket0Hash: {
+				Hash:           ticket0Hash,
+				PurchaseBlock:  *purchaseBlock.Hash(),
+				PurchaseHeight: 100,
+				Price:          200000000,
+				Status:         TicketVoted,
+				SpendTxHash:    vote.TxHash(),
+				SpendHeight:    200,
+			},
+			ticket1Hash: {
+				Hash:           ticket1Hash,
+				PurchaseBlock:  *purchaseBlock.Hash(),
+				PurchaseHeight: 100,
+				Price:          200000000,
+				Status:         TicketRevoked,
+				MissedHeight:   200,
+				SpendTxHash:    revocation.TxHash(),
+				SpendHeight:    201,
+			},
+		},
+	}}
+
+	bucket := &addrUtxoIndexBucket{entries: make(map[string][]byte)}
+	snapshots := make([]*addrUtxoIndexBucket, 0, len(tests))
+	for _, test := range tests {
+		snapshots = append(snapshots, bucket.Clone())
+		err := dbPutTicketHistoryEntries(bucket, test.block, test.undo,
+			params)
+		if err != nil {
+			t.Fatalf("%s: unexpected put error: %v", test.name, err)
+		}
+
+		for ticketHash, want := range test.want {
+			got, err := dbFetchTicketHistory(bucket, &ticketHash)
+			if err != nil {
+				t.Fatalf("%s: unexpected fetch error: %v", test.name,
+					err)
+			}
+			if !reflect.DeepEqual(*got, want) {
+				t.Fatalf("%s: mismatched history -- got %+v, want %+v",
+					test.name, *got, want)
+			}
+
+			// Ensure the ticket is only listed under its current
+			// status.
+			for status := TicketUnspent; status <= TicketRevoked; status++ {
+				key := ticketStatusKey(status, &ticketHash)
+				_, listed := bucket.entries[string(key)]
+				if listed != (status == want.Status) {
+					t.Fatalf("%s: unexpected status %d entry for %v",
+						test.name, status, ticketHash)
+				}
+			}
+		}
+	}
+
+	// Ensure both the voting and commitment addresses map to the tickets
+	// and the status entries can be used to list them.
+	for _, addrKey := range [][addrKeySize]byte{votingAddrKey, commitAddrKey} {
+		for _, ticketHash := range []chainhash.Hash{ticket0Hash, ticket1Hash} {
+			key := ticketAddrKey(addrKey, &ticketHash)
+			if _, ok := bucket.entries[string(key)]; !ok {
+				t.Fatalf("missing address entry for %v", ticketHash)
+			}
+		}
+	}
+	histories, _, err := dbFetchTicketHistories(bucket, bucket.Cursor(), []byte{
+		ticketStatusPrefix, byte(TicketRevoked)}, nil, 0, 10)
+	if err != nil {
+		t.Fatalf("unexpected list error: %v", err)
+	}
+	if len(histories) != 1 || histories[0].Hash != ticket1Hash {
+		t.Fatalf("unexpected revoked tickets %+v", histories)
+	}
+
+	// Ensure disconnecting the blocks in reverse order restores the index
+	// to its previous states.
+	for i := len(tests) - 1; i >= 0; i-- {
+		test := tests[i]
+		err := dbRemoveTicketHistoryEntries(bucket, test.block, params)
+		if err != nil {
+			t.Fatalf("%s: unexpected remove error: %v", test.name, err)
+		}
+		want := snapshots[i].entries
+		if len(bucket.entries) != len(want) {
+			t.Fatalf("%s: unexpected number of entries after "+
+				"disconnect -- got %d, want %d", test.name,
+				len(bucket.entries), len(want))
+		}
+		for k, v := range want {
+			got, ok := bucket.entries[k]
+			if !ok || !bytes.Equal(got, v) {
+				t.Fatalf("%s: mismatched entry %x after "+
+					"disconnect -- got %x, want %x", test.name,
+					k, got, v)
+			}
+		}
+	}
+}
@@ -25,6 +25,11 @@ var (
 	// the block height -> block hash index.
 	HeightIndexBucketName = []byte("heightidx")
 
+	// BlockIndexBucketName is the name of the db bucket used to house the
+	// block index which contains the header, status and votes of every
+	// block which has its data stored.
+	BlockIndexBucketName = []byte("blockidx")
+
 	// ChainStateKeyName is the name of the db key used to store the best
 	// chain state.
 	ChainStateKeyName = []byte("chainstate")
@@ -36,4 +41,9 @@ var (
 	// UtxoSetBucketName is the name of the db bucket used to house the
 	// unspent transaction output set.
 	UtxoSetBucketName = []byte("utxoset")
+
+	// InvalidatedBlocksBucketName is the name of the db bucket used to
+	// house the blocks which were manually marked invalid along with the
+	// hash of their parent.
+	InvalidatedBlocksBucketName = []byte("invalidatedblocks")
 )
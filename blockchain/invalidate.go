@@ -0,0 +1,314 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/database"
+)
+
+// isInvalidated returns whether or not the passed node or its parent is marked
+// invalid, either manually or because it failed validation.  Checking the
+// parent as well covers nodes which build on an invalid block but were not
+// marked themselves, such as those created for dry runs.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) isInvalidated(node *blockNode) bool {
+	if _, ok := b.invalidated[node.hash]; ok || node.status.KnownInvalid() {
+		return true
+	}
+	if node.parent != nil {
+		_, ok := b.invalidated[node.parent.hash]
+		return ok || node.parent.status.KnownInvalid()
+	}
+	return false
+}
+
+// IsInvalidated returns whether or not the block with the given hash is marked
+// invalid, either because it was invalidated with InvalidateBlock or because
+// one of its ancestors was.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) IsInvalidated(hash *chainhash.Hash) bool {
+	b.chainLock.RLock()
+	_, ok := b.invalidated[*hash]
+	b.chainLock.RUnlock()
+	return ok
+}
+
+// markInvalidated marks the passed nodes invalid in both the database and the
+// memory index of invalid blocks.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) markInvalidated(nodes []*blockNode) error {
+	err := b.db.Update(func(dbTx database.Tx) error {
+		for _, n := range nodes {
+			err := dbPutInvalidatedBlock(dbTx, &n.hash,
+				&n.header.PrevBlock)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, n := range nodes {
+		b.invalidated[n.hash] = n.header.PrevBlock
+	}
+	return nil
+}
+
+// clearInvalidated removes the invalid marks of the blocks with the passed
+// hashes from both the database and the memory index of invalid blocks.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) clearInvalidated(hashes []chainhash.Hash) error {
+	err := b.db.Update(func(dbTx database.Tx) error {
+		for i := range hashes {
+			err := dbRemoveInvalidatedBlock(dbTx, &hashes[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		delete(b.invalidated, hash)
+	}
+	return nil
+}
+
+// lookupNode returns the block node for the passed hash from the memory block
+// index or an error when the block is not known.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) lookupNode(hash *chainhash.Hash) (*blockNode, error) {
+	node, ok := b.index[*hash]
+	if !ok {
+		str := fmt.Sprintf("block %v is not known", hash)
+		return nil, ruleError(ErrUnknownBlock, str)
+	}
+	return node, nil
+}
+
+// knownDescendants returns the passed node along with all of its descendants
+// in the memory block index.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) knownDescendants(node *blockNode) []*blockNode {
+	nodes := []*blockNode{node}
+	for i := 0; i < len(nodes); i++ {
+		nodes = append(nodes, nodes[i].children...)
+	}
+	return nodes
+}
+
+// bestValidTip returns the node with the most cumulative work which is not
+// marked invalid and whose block is available to be connected, meaning it is
+// either stored in the database or in the side chain block cache.  The passed
+// node is returned when no node has more work.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) bestValidTip(start *blockNode) *blockNode {
+	best := start
+	for _, n := range b.index {
+		if n.workSum.Cmp(best.workSum) <= 0 || b.isInvalidated(n) {
+			continue
+		}
+		if !b.haveBlockData(n) {
+			continue
+		}
+		best = n
+	}
+	return best
+}
+
+// reorganizeToNode reorganizes the chain so the passed node becomes the end of
+// the main chain.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) reorganizeToNode(node *blockNode) error {
+	if node.hash == b.bestNode.hash {
+		return nil
+	}
+
+	detachNodes, attachNodes, err := b.getReorganizeNodes(node)
+	if err != nil {
+		return err
+	}
+	return b.reorganizeChain(detachNodes, attachNodes, BFNone)
+}
+
+// invalidateBlock marks the block with the passed hash and all of its
+// descendants invalid.  When the block is part of the main chain, the chain is
+// reorganized to the valid chain with the most cumulative work.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) invalidateBlock(hash *chainhash.Hash) error {
+	if hash.IsEqual(b.chainParams.GenesisHash) {
+		return ruleError(ErrInvalidateGenesis, "the genesis block can't "+
+			"be invalidated")
+	}
+
+	// Nothing to do when the block is already marked invalid.
+	if _, ok := b.invalidated[*hash]; ok {
+		return nil
+	}
+	node, err := b.lookupNode(hash)
+	if err != nil {
+		return err
+	}
+
+	parent := node.parent
+	invalidNodes := b.knownDescendants(node)
+	if err := b.markInvalidated(invalidNodes); err != nil {
+		return err
+	}
+	log.Infof("Marked block %v (height %d) and %d descendants invalid",
+		node.hash, node.height, len(invalidNodes)-1)
+
+	// Switch to the valid chain with the most work, which is the chain
+	// ending at the parent of the block unless there is a side chain with
+	// more work.  Fall back to the parent when the side chain turns out to
+	// be invalid.
+	if node.inMainChain {
+		target := b.bestValidTip(parent)
+		err := b.reorganizeToNode(target)
+		if err != nil && target != parent {
+			log.Warnf("Unable to reorganize to block %v: %v",
+				target.hash, err)
+			err = b.reorganizeToNode(parent)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	b.bestHeader = b.findBestHeader()
+
+	b.chainLock.Unlock()
+	b.sendNotification(NTBlockInvalidated, &BlockValidityNtfnsData{
+		Hash:   node.hash,
+		Height: node.height,
+	})
+	b.chainLock.Lock()
+
+	return nil
+}
+
+// InvalidateBlock marks the block with the passed hash and all of its
+// descendants invalid, so they are never part of the main chain.  When the
+// block is part of the main chain, it is disconnected along with its
+// descendants and the chain is reorganized to the remaining valid chain with
+// the most cumulative work.  Blocks which build on an invalid block that are
+// received later are marked invalid as well.
+//
+// The marks are stored in the database, so they persist across restarts until
+// they are removed with ReconsiderBlock.  Invalidating the genesis block is not
+// allowed.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) InvalidateBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+	return b.invalidateBlock(hash)
+}
+
+// reconsiderHashes returns the hashes of the blocks which are marked invalid
+// and either are the block with the passed hash, one of its ancestors, or one
+// of its descendants.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) reconsiderHashes(hash *chainhash.Hash) []chainhash.Hash {
+	// The block itself and its ancestors.
+	var hashes []chainhash.Hash
+	for h := *hash; ; {
+		parent, ok := b.invalidated[h]
+		if !ok {
+			break
+		}
+		hashes = append(hashes, h)
+		h = parent
+	}
+
+	// The descendants.  Since all descendants of an invalid block are
+	// marked invalid as well, the parents of a descendant can be followed
+	// through the invalid blocks until the block is reached.
+	for h := range b.invalidated {
+		for cur := h; cur != *hash; {
+			parent, ok := b.invalidated[cur]
+			if !ok {
+				break
+			}
+			if parent == *hash {
+				hashes = append(hashes, h)
+				break
+			}
+			cur = parent
+		}
+	}
+	return hashes
+}
+
+// reconsiderBlock removes the invalid marks from the block with the passed
+// hash, its ancestors, and its descendants and reorganizes the chain to the
+// valid chain with the most cumulative work.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) reconsiderBlock(hash *chainhash.Hash) error {
+	if _, ok := b.invalidated[*hash]; !ok {
+		if _, err := b.lookupNode(hash); err != nil {
+			return err
+		}
+	}
+
+	hashes := b.reconsiderHashes(hash)
+	if len(hashes) == 0 {
+		return nil
+	}
+	if err := b.clearInvalidated(hashes); err != nil {
+		return err
+	}
+	log.Infof("Removed the invalid mark of %d blocks", len(hashes))
+
+	// Switch to the valid chain with the most work.
+	err := b.reorganizeToNode(b.bestValidTip(b.bestNode))
+	if err != nil {
+		return err
+	}
+	b.bestHeader = b.findBestHeader()
+
+	if node, ok := b.index[*hash]; ok {
+		b.chainLock.Unlock()
+		b.sendNotification(NTBlockReconsidered, &BlockValidityNtfnsData{
+			Hash:   node.hash,
+			Height: node.height,
+		})
+		b.chainLock.Lock()
+	}
+
+	return nil
+}
+
+// ReconsiderBlock removes the invalid marks which were added by InvalidateBlock
+// from the block with the passed hash, its ancestors, and its descendants.  The
+// chain is then reorganized to the valid chain with the most cumulative work,
+// which reconnects the blocks when they have more work than the current main
+// chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ReconsiderBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+	return b.reconsiderBlock(hash)
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/txscript"
+	"github.com/coolsnady/hcutil"
+)
+
+// TestInvalidateBlock ensures invalidating a block of the main chain
+// disconnects it along with its descendants, that the marks persist across
+// chain instances, and that reconsidering the block reconnects them.
+func TestInvalidateBlock(t *testing.T) {
+	// Update simnet parameters to reflect what is expected by the legacy
+	// data.
+	params := cloneParams(&chaincfg.SimNetParams)
+	params.GenesisBlock.Header.MerkleRoot = *mustParseHash("a216ea043f0d481a072424af646787794c32bcefd3ed181a090319bbf8a37105")
+	genesisHash := params.GenesisBlock.BlockHash()
+	params.GenesisHash = &genesisHash
+
+	// Create a new database and chain instance to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "invalidateblocktest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(testDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+	newChain := func() *blockchain.BlockChain {
+		chain, err := blockchain.New(&blockchain.Config{
+			DB:          db,
+			ChainParams: params,
+			TimeSource:  blockchain.NewMedianTime(),
+			SigCache:    txscript.NewSigCache(1000),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create chain instance: %v", err)
+		}
+		return chain
+	}
+	chain := newChain()
+
+	// Load and connect the test blocks.
+	fi, err := os.Open(filepath.Join("testdata", "blocks0to168.bz2"))
+	if err != nil {
+		t.Fatalf("Unable to open test data: %v", err)
+	}
+	defer fi.Close()
+	bcBuf := new(bytes.Buffer)
+	bcBuf.ReadFrom(bzip2.NewReader(fi))
+	blockChain := make(map[int64][]byte)
+	if err := gob.NewDecoder(bcBuf).Decode(&blockChain); err != nil {
+		t.Fatalf("Error decoding test blockchain: %v", err)
+	}
+	blocks := make(map[int64]*hcutil.Block)
+	for i := int64(1); i <= 168; i++ {
+		bl, err := hcutil.NewBlockFromBytes(blockChain[i])
+		if err != nil {
+			t.Fatalf("NewBlockFromBytes error: %v", err)
+		}
+		_, err = chain.ProcessBlock(bl, blockchain.BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock error at height %d: %v", i, err)
+		}
+		blocks[i] = bl
+	}
+
+	// Ensure the genesis block can't be invalidated.
+	err = chain.InvalidateBlock(&genesisHash)
+	rerr, ok := err.(blockchain.RuleError)
+	if !ok || rerr.ErrorCode != blockchain.ErrInvalidateGenesis {
+		t.Fatalf("InvalidateBlock(genesis): unexpected error: %v", err)
+	}
+
+	// Invalidate a block of the main chain and ensure it is disconnected
+	// along with its descendants.
+	const invalidHeight = 160
+	if err := chain.InvalidateBlock(blocks[invalidHeight].Hash()); err != nil {
+		t.Fatalf("InvalidateBlock: unexpected error: %v", err)
+	}
+	checkBest := func(chain *blockchain.BlockChain, height int64) {
+		t.Helper()
+		best := chain.BestSnapshot()
+		if best.Height != height || *best.Hash != *blocks[height].Hash() {
+			t.Fatalf("unexpected best block %v (height %d), want "+
+				"height %d", best.Hash, best.Height, height)
+		}
+	}
+	checkInvalid := func(chain *blockchain.BlockChain, invalid bool) {
+		t.Helper()
+		for height := int64(invalidHeight - 1); height <= 168; height++ {
+			want := invalid && height >= invalidHeight
+			got := chain.IsInvalidated(blocks[height].Hash())
+			if got != want {
+				t.Fatalf("IsInvalidated at height %d: got %v, want %v",
+					height, got, want)
+			}
+		}
+	}
+	checkBest(chain, invalidHeight-1)
+	checkInvalid(chain, true)
+
+	// Ensure the marks persist across chain instances and that
+	// reconsidering the block reconnects it along with its descendants
+	// even though they are no longer in the side chain block cache.
+	chain = newChain()
+	checkBest(chain, invalidHeight-1)
+	checkInvalid(chain, true)
+	if err := chain.ReconsiderBlock(blocks[168].Hash()); err != nil {
+		t.Fatalf("ReconsiderBlock: unexpected error: %v", err)
+	}
+	checkBest(chain, 168)
+	checkInvalid(chain, false)
+
+	// Invalidate and reconsider the block again within the same chain
+	// instance.
+	if err := chain.InvalidateBlock(blocks[invalidHeight].Hash()); err != nil {
+		t.Fatalf("InvalidateBlock: unexpected error: %v", err)
+	}
+	checkBest(chain, invalidHeight-1)
+	if err := chain.ReconsiderBlock(blocks[invalidHeight].Hash()); err != nil {
+		t.Fatalf("ReconsiderBlock: unexpected error: %v", err)
+	}
+	checkBest(chain, 168)
+	checkInvalid(chain, false)
+}
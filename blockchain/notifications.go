@@ -46,6 +46,14 @@ const (
 	// NTSpentAndMissedTickets indicates newly maturing tickets from a newly
 	// accepted block.
 	NTNewTickets
+
+	// NTBlockInvalidated indicates the associated block was manually
+	// marked invalid along with its descendants.
+	NTBlockInvalidated
+
+	// NTBlockReconsidered indicates the invalid mark of the associated
+	// block was manually removed.
+	NTBlockReconsidered
 )
 
 // notificationTypeStrings is a map of notification types back to their constant
@@ -57,6 +65,8 @@ var notificationTypeStrings = map[NotificationType]string{
 	NTReorganization:        "NTReorganization",
 	NTSpentAndMissedTickets: "NTSpentAndMissedTickets",
 	NTNewTickets:            "NTNewTickets",
+	NTBlockInvalidated:      "NTBlockInvalidated",
+	NTBlockReconsidered:     "NTBlockReconsidered",
 }
 
 // String returns the NotificationType in human-readable form.
@@ -83,6 +93,13 @@ type ReorganizationNtfnsData struct {
 	NewHeight int64
 }
 
+// BlockValidityNtfnsData is the structure for data indicating information
+// about a block which was manually invalidated or reconsidered.
+type BlockValidityNtfnsData struct {
+	Hash   chainhash.Hash
+	Height int64
+}
+
 // TicketNotificationsData is the structure for new/spent/missed ticket
 // notifications at blockchain HEAD that are outgoing from chain.
 type TicketNotificationsData struct {
@@ -103,6 +120,8 @@ type TicketNotificationsData struct {
 //  - NTReorganization:        *ReorganizationNtfnsData
 //  - NTSpentAndMissedTickets: *TicketNotificationsData
 //  - NTNewTickets:            *TicketNotificationsData
+//  - NTBlockInvalidated:      *BlockValidityNtfnsData
+//  - NTBlockReconsidered:     *BlockValidityNtfnsData
 type Notification struct {
 	Type NotificationType
 	Data interface{}
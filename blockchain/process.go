@@ -12,6 +12,7 @@ import (
 
 	"github.com/coolsnady/hcd/chaincfg/chainhash"
 	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/wire"
 	"github.com/coolsnady/hcutil"
 )
 
@@ -23,7 +24,8 @@ const (
 	// BFFastAdd may be set to indicate that several checks can be avoided
 	// for the block since it is already known to fit into the chain due to
 	// already proving it correct links into the chain up to a known
-	// checkpoint.  This is primarily used for headers-first mode.
+	// checkpoint.  This is primarily used when importing blocks from a
+	// trusted source.
 	BFFastAdd BehaviorFlags = 1 << iota
 
 	// BFNoPoWCheck may be set to indicate the proof of work check which
@@ -40,95 +42,284 @@ const (
 	BFNone BehaviorFlags = 0
 )
 
-// blockExists determines whether a block with the given hash exists either in
-// the main chain or any side chains.
+// blockExists determines whether the data of the block with the given hash is
+// available, either because the block is part of the main chain or any side
+// chains.  Blocks which only have their header in the block index do not
+// exist in this sense.
 //
 // This function MUST be called with the chain state lock held (for reads).
 func (b *BlockChain) blockExists(hash *chainhash.Hash) (bool, error) {
-	// Check memory chain first (could be main chain or side chain blocks).
-	if _, ok := b.index[*hash]; ok {
-		return true, nil
+	node, ok := b.index[*hash]
+	return ok && b.haveBlockData(node), nil
+}
+
+// acceptBlockHeader validates the passed block header and, unless the BFDryRun
+// flag is set, adds a block node for it to the memory block index.  The header
+// must build on a header which is already in the block index and pass all of
+// the validation checks which only depend on the headers of the previous
+// blocks.  The node is returned as is when the header is already known, which
+// is indicated by the second return value being false.
+//
+// New nodes are NOT stored in the database by this function, so the caller is
+// responsible for storing them, or for removing them from the block index
+// again with removeHeaderNodes when they can not be stored.  Nodes which build
+// on a block that is marked invalid are marked invalid in the memory index as
+// well.
+//
+// The flags are also passed to checkBlockHeaderSanity and
+// checkBlockHeaderContext.  See their documentation for how the flags modify
+// their behavior.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) acceptBlockHeader(header *wire.BlockHeader, flags BehaviorFlags) (*blockNode, bool, error) {
+	// Nothing more to do when the header is already known, unless it is
+	// known to be invalid.
+	hash := header.BlockHash()
+	if node, ok := b.index[hash]; ok {
+		if node.status.KnownInvalid() {
+			str := fmt.Sprintf("block %v is known to be invalid",
+				hash)
+			return nil, false, ruleError(ErrKnownInvalidBlock, str)
+		}
+		return node, false, nil
 	}
 
-	// Check in the database.
-	var exists bool
-	err := b.db.View(func(dbTx database.Tx) error {
-		var err error
-		exists, err = dbTx.HasBlock(hash)
-		return err
-	})
-	return exists, err
+	// The header must build on a header which is known and not known to be
+	// invalid.
+	prevNode, ok := b.index[header.PrevBlock]
+	if !ok {
+		str := fmt.Sprintf("previous block %v of block %v is not known",
+			header.PrevBlock, hash)
+		return nil, false, ruleError(ErrMissingParent, str)
+	}
+	if prevNode.status.KnownInvalid() {
+		str := fmt.Sprintf("block %v builds on block %v which is known "+
+			"to be invalid", hash, prevNode.hash)
+		return nil, false, ruleError(ErrKnownInvalidBlock, str)
+	}
+
+	// Perform the context free and the contextual header checks.
+	err := checkBlockHeaderSanity(header, b.timeSource, flags,
+		b.chainParams)
+	if err != nil {
+		return nil, false, err
+	}
+	err = b.checkBlockHeaderContext(header, prevNode, flags)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Create a new block node for the header linked to its parent.
+	node := newBlockNode(header, nil, nil, nil)
+	node.parent = prevNode
+	node.workSum.Add(prevNode.workSum, node.workSum)
+	node.buildSkipList()
+	if flags&BFDryRun == BFDryRun {
+		return node, true, nil
+	}
+
+	// Add the node to the block index.  Headers which build on a block that
+	// is marked invalid are invalid as well, so mark them accordingly.
+	// This ensures they are only considered again once the invalid block is
+	// reconsidered.
+	b.index[hash] = node
+	prevNode.children = append(prevNode.children, node)
+	if _, ok := b.invalidated[prevNode.hash]; ok {
+		b.invalidated[hash] = prevNode.hash
+	}
+	return node, true, nil
 }
 
-// processOrphans determines if there are any orphans which depend on the passed
-// block hash (they are no longer orphans if true) and potentially accepts them.
-// It repeats the process for the newly accepted blocks (to detect further
-// orphans which may no longer be orphans) until there are no more.
+// removeHeaderNodes removes the passed nodes, which must have been added by
+// acceptBlockHeader in the given order, from the memory block index again.
 //
-// The flags do not modify the behavior of this function directly, however they
-// are needed to pass along to maybeAcceptBlock.
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) removeHeaderNodes(nodes []*blockNode) {
+	for i := len(nodes) - 1; i >= 0; i-- {
+		node := nodes[i]
+		delete(b.index, node.hash)
+		delete(b.invalidated, node.hash)
+		node.parent.children = removeChildNode(node.parent.children, node)
+	}
+}
+
+// isDeepFork returns whether or not the passed node is part of a side chain
+// which forks from the main chain more than minMemoryNodes blocks before the
+// best block.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) isDeepFork(node *blockNode) bool {
+	minForkHeight := b.bestNode.height - minMemoryNodes
+	if minForkHeight < 0 {
+		return false
+	}
+	forkNode := node.Ancestor(minForkHeight)
+	return forkNode == nil || !forkNode.inMainChain
+}
+
+// maybeAcceptBlockHeaders potentially accepts the passed block headers, in
+// order, into the block index and returns the block nodes for them.  Headers
+// which are already known are returned as is.  All new headers are stored in
+// the database in a single transaction.
+//
+// Processing stops at the first header which fails validation.  The headers
+// before it are still accepted and their nodes are returned along with the
+// error.
+//
+// As a matter of policy, and not a consensus rule, the new headers are
+// rejected with ErrLowWorkFork when any of them is part of a side chain which
+// forks from the main chain more than minMemoryNodes blocks before the best
+// block, unless one of them has more cumulative work than the best block.
+// Every accepted header is kept in the block index, so this prevents peers
+// from cheaply filling it with low difficulty headers forking from early
+// blocks, while deep forks which could actually become the main chain are
+// still accepted.
+//
+// Headers which are accepted update the best header, which is the one with the
+// most cumulative work that is not known to be invalid.
+//
+// The flags modify the behavior of this function as follows:
+//  - BFDryRun: The nodes are neither added to the block index nor stored, so
+//    only a single header may be passed.
+//
+// The flags are also passed to checkBlockHeaderSanity and
+// checkBlockHeaderContext.  See their documentation for how the flags modify
+// their behavior.
 //
 // This function MUST be called with the chain state lock held (for writes).
-func (b *BlockChain) processOrphans(hash *chainhash.Hash, flags BehaviorFlags) error {
-	// Start with processing at least the passed hash.  Leave a little room
-	// for additional orphan blocks that need to be processed without
-	// needing to grow the array in the common case.
-	processHashes := make([]*chainhash.Hash, 0, 10)
-	processHashes = append(processHashes, hash)
-	for len(processHashes) > 0 {
-		// Pop the first hash to process from the slice.
-		processHash := processHashes[0]
-		processHashes[0] = nil // Prevent GC leak.
-		processHashes = processHashes[1:]
-
-		// Look up all orphans that are parented by the block we just
-		// accepted.  This will typically only be one, but it could
-		// be multiple if multiple blocks are mined and broadcast
-		// around the same time.  The one with the most proof of work
-		// will eventually win out.  An indexing for loop is
-		// intentionally used over a range here as range does not
-		// reevaluate the slice on each iteration nor does it adjust the
-		// index for the modified slice.
-		for i := 0; i < len(b.prevOrphans[*processHash]); i++ {
-			orphan := b.prevOrphans[*processHash][i]
-			if orphan == nil {
-				log.Warnf("Found a nil entry at index %d in the "+
-					"orphan dependency list for block %v", i,
-					processHash)
+func (b *BlockChain) maybeAcceptBlockHeaders(headers []*wire.BlockHeader, flags BehaviorFlags) ([]*blockNode, error) {
+	nodes := make([]*blockNode, 0, len(headers))
+	var newNodes []*blockNode
+	var acceptErr error
+	for _, header := range headers {
+		node, isNew, err := b.acceptBlockHeader(header, flags)
+		if err != nil {
+			acceptErr = err
+			break
+		}
+		nodes = append(nodes, node)
+		if isNew {
+			newNodes = append(newNodes, node)
+		}
+	}
+	if len(newNodes) == 0 {
+		return nodes, acceptErr
+	}
+
+	// Reject the new headers of deep side chains which do not have more
+	// work than the main chain.
+	dryRun := flags&BFDryRun == BFDryRun
+	bestNew := newNodes[len(newNodes)-1]
+	for _, node := range newNodes {
+		if node.workSum.Cmp(bestNew.workSum) > 0 {
+			bestNew = node
+		}
+	}
+	if bestNew.workSum.Cmp(b.bestNode.workSum) <= 0 {
+		for _, node := range newNodes {
+			if !b.isDeepFork(node) {
 				continue
 			}
 
-			// Remove the orphan from the orphan pool.
-			orphanHash := orphan.block.Hash()
-			b.removeOrphanBlock(orphan)
-			i--
+			if !dryRun {
+				b.removeHeaderNodes(newNodes)
+			}
+			str := fmt.Sprintf("block %v forks from the main chain "+
+				"more than %d blocks before the best block %v "+
+				"(height %d) and does not have more work", node.hash,
+				minMemoryNodes, b.bestNode.hash, b.bestNode.height)
+			return nil, ruleError(ErrLowWorkFork, str)
+		}
+	}
+	if dryRun {
+		return nodes, acceptErr
+	}
 
-			// Potentially accept the block into the block chain.
-			_, err := b.maybeAcceptBlock(orphan.block, flags)
+	// Store the new nodes along with the invalid marks of those which build
+	// on an invalid block.
+	err := b.db.Update(func(dbTx database.Tx) error {
+		for _, node := range newNodes {
+			err := dbPutBlockNode(dbTx, node)
 			if err != nil {
 				return err
 			}
+			if _, ok := b.invalidated[node.hash]; ok {
+				err := dbPutInvalidatedBlock(dbTx, &node.hash,
+					&node.header.PrevBlock)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.removeHeaderNodes(newNodes)
+		return nil, err
+	}
+
+	// Update the best header when a new one has more work.
+	for _, node := range newNodes {
+		if node.workSum.Cmp(b.bestHeader.workSum) > 0 &&
+			!b.isInvalidated(node) {
 
-			// Add this block to the list of blocks to process so
-			// any orphan blocks that depend on this block are
-			// handled too.
-			processHashes = append(processHashes, orphanHash)
+			b.bestHeader = node
 		}
 	}
-	return nil
+
+	return nodes, acceptErr
+}
+
+// maybeAcceptBlockHeader potentially accepts the passed block header into the
+// block index and returns the block node for it.  See maybeAcceptBlockHeaders
+// for details.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) maybeAcceptBlockHeader(header *wire.BlockHeader, flags BehaviorFlags) (*blockNode, error) {
+	nodes, err := b.maybeAcceptBlockHeaders([]*wire.BlockHeader{header},
+		flags)
+	if err != nil {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// ProcessBlockHeaders validates the passed block headers, in order, and adds
+// those which are not already known to the block index.  Each header must
+// build on a header which is already known or precedes it in the passed
+// headers, otherwise an error with ErrMissingParent is returned.
+//
+// Processing stops at the first header which fails validation, so the number
+// of headers which were processed successfully is returned along with the
+// error.  Headers of side chains which fork from the main chain more than
+// minMemoryNodes blocks before the best block are rejected by policy with
+// ErrLowWorkFork unless they have more work than the main chain.
+//
+// The blocks of the best header chain can then be determined with
+// NextNeededBlocks in order to download them.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ProcessBlockHeaders(headers []*wire.BlockHeader) (int, error) {
+	b.chainLock.Lock()
+	nodes, err := b.maybeAcceptBlockHeaders(headers, BFNone)
+	b.chainLock.Unlock()
+	return len(nodes), err
 }
 
 // ProcessBlock is the main workhorse for handling insertion of new blocks into
 // the block chain.  It includes functionality such as rejecting duplicate
-// blocks, ensuring blocks follow all rules, orphan handling, and insertion into
-// the block chain along with best chain selection and reorganization.
+// blocks, ensuring blocks follow all rules, and insertion into the block chain
+// along with best chain selection and reorganization.
+//
+// The block must build on a block the data of which is available.  Otherwise,
+// an error with ErrMissingParent is returned and the caller is expected to
+// provide the missing blocks first.
 //
-// When no errors occurred during processing, the first return value indicates
-// whether or not the block is on the main chain and the second indicates
-// whether or not the block is an orphan.
+// When no errors occurred during processing, the return value indicates
+// whether or not the block is on the main chain.
 //
 // This function is safe for concurrent access.
-func (b *BlockChain) ProcessBlock(block *hcutil.Block, flags BehaviorFlags) (bool, bool, error) {
+func (b *BlockChain) ProcessBlock(block *hcutil.Block, flags BehaviorFlags) (bool, error) {
 	b.chainLock.Lock()
 	defer b.chainLock.Unlock()
 
@@ -147,23 +338,17 @@ func (b *BlockChain) ProcessBlock(block *hcutil.Block, flags BehaviorFlags) (boo
 	// The block must not already exist in the main chain or side chains.
 	exists, err := b.blockExists(blockHash)
 	if err != nil {
-		return false, false, err
+		return false, err
 	}
 	if exists {
 		str := fmt.Sprintf("already have block %v", blockHash)
-		return false, false, ruleError(ErrDuplicateBlock, str)
-	}
-
-	// The block must not already exist as an orphan.
-	if _, exists := b.orphans[*blockHash]; exists {
-		str := fmt.Sprintf("already have block (orphan) %v", blockHash)
-		return false, false, ruleError(ErrDuplicateBlock, str)
+		return false, ruleError(ErrDuplicateBlock, str)
 	}
 
 	// Perform preliminary sanity checks on the block and its transactions.
 	err = checkBlockSanity(block, b.timeSource, flags, b.chainParams)
 	if err != nil {
-		return false, false, err
+		return false, err
 	}
 
 	// Find the previous checkpoint and perform some additional checks based
@@ -175,7 +360,7 @@ func (b *BlockChain) ProcessBlock(block *hcutil.Block, flags BehaviorFlags) (boo
 	blockHeader := &block.MsgBlock().Header
 	checkpointBlock, err := b.findPreviousCheckpoint()
 	if err != nil {
-		return false, false, err
+		return false, err
 	}
 	if checkpointBlock != nil {
 		// Ensure the block timestamp is after the checkpoint timestamp.
@@ -185,7 +370,7 @@ func (b *BlockChain) ProcessBlock(block *hcutil.Block, flags BehaviorFlags) (boo
 			str := fmt.Sprintf("block %v has timestamp %v before "+
 				"last checkpoint timestamp %v", blockHash,
 				blockHeader.Timestamp, checkpointTime)
-			return false, false, ruleError(ErrCheckpointTimeTooOld, str)
+			return false, ruleError(ErrCheckpointTimeTooOld, str)
 		}
 
 		if !fastAdd {
@@ -203,46 +388,33 @@ func (b *BlockChain) ProcessBlock(block *hcutil.Block, flags BehaviorFlags) (boo
 				str := fmt.Sprintf("block target difficulty of %064x "+
 					"is too low when compared to the previous "+
 					"checkpoint", currentTarget)
-				return false, false, ruleError(ErrDifficultyTooLow, str)
+				return false, ruleError(ErrDifficultyTooLow, str)
 			}
 		}
 	}
 
-	// Handle orphan blocks.
+	// The data of the previous block must be available.
 	prevHash := &blockHeader.PrevBlock
 	prevHashExists, err := b.blockExists(prevHash)
 	if err != nil {
-		return false, false, err
+		return false, err
 	}
 	if !prevHashExists {
-		if !dryRun {
-			log.Infof("Adding orphan block %v with parent %v",
-				blockHash, prevHash)
-			b.addOrphanBlock(block)
-		}
-
-		return false, true, nil
+		str := fmt.Sprintf("previous block %v of block %v is not "+
+			"available", prevHash, blockHash)
+		return false, ruleError(ErrMissingParent, str)
 	}
 
 	// The block has passed all context independent checks and appears sane
 	// enough to potentially accept it into the block chain.
 	isMainChain, err := b.maybeAcceptBlock(block, flags)
 	if err != nil {
-		return false, false, err
+		return false, err
 	}
 
-	// Don't process any orphans or log when the dry run flag is set.
 	if !dryRun {
-		// Accept any orphan blocks that depend on this block (they are
-		// no longer orphans) and repeat for those accepted blocks until
-		// there are no more.
-		err := b.processOrphans(blockHash, flags)
-		if err != nil {
-			return false, false, err
-		}
-
 		log.Debugf("Accepted block %v", blockHash)
 	}
 
-	return isMainChain, false, nil
+	return isMainChain, nil
 }
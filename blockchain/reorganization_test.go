@@ -67,7 +67,7 @@ func reorgTestLong(t *testing.T, params *chaincfg.Params) {
 			t.Fatalf("NewBlockFromBytes error: %v", err.Error())
 		}
 
-		_, _, err = chain.ProcessBlock(bl, blockchain.BFNone)
+		_, err = chain.ProcessBlock(bl, blockchain.BFNone)
 		if err != nil {
 			t.Fatalf("ProcessBlock error at height %v: %v", i, err.Error())
 		}
@@ -105,7 +105,7 @@ func reorgTestLong(t *testing.T, params *chaincfg.Params) {
 			t.Fatalf("NewBlockFromBytes error: %v", err.Error())
 		}
 
-		_, _, err = chain.ProcessBlock(bl, blockchain.BFNone)
+		_, err = chain.ProcessBlock(bl, blockchain.BFNone)
 		if err != nil {
 			t.Fatalf("ProcessBlock error: %v", err.Error())
 		}
@@ -205,7 +205,7 @@ func reorgTestShort(t *testing.T, params *chaincfg.Params) {
 			t.Fatalf("NewBlockFromBytes error: %v", err.Error())
 		}
 
-		_, _, err = chain.ProcessBlock(bl, blockchain.BFNone)
+		_, err = chain.ProcessBlock(bl, blockchain.BFNone)
 		if err != nil {
 			t.Fatalf("ProcessBlock error at height %v: %v", i, err.Error())
 		}
@@ -221,7 +221,7 @@ func reorgTestShort(t *testing.T, params *chaincfg.Params) {
 				t.Fatalf("NewBlockFromBytes error: %v", err.Error())
 			}
 
-			_, _, err = chain.ProcessBlock(bl, blockchain.BFNone)
+			_, err = chain.ProcessBlock(bl, blockchain.BFNone)
 			if err != nil {
 				t.Fatalf("ProcessBlock error: %v", err.Error())
 			}
@@ -299,7 +299,7 @@ func reorgTestForced(t *testing.T, params *chaincfg.Params) {
 			oldBestHash = bl.Hash()
 		}
 
-		_, _, err = chain.ProcessBlock(bl, blockchain.BFNone)
+		_, err = chain.ProcessBlock(bl, blockchain.BFNone)
 		if err != nil {
 			t.Fatalf("ProcessBlock error at height %v: %v", i, err.Error())
 		}
@@ -332,7 +332,7 @@ func reorgTestForced(t *testing.T, params *chaincfg.Params) {
 		t.Fatalf("NewBlockFromBytes error: %v", err.Error())
 	}
 
-	_, _, err = chain.ProcessBlock(forkBl, blockchain.BFNone)
+	_, err = chain.ProcessBlock(forkBl, blockchain.BFNone)
 	if err != nil {
 		t.Fatalf("ProcessBlock error: %v", err.Error())
 	}
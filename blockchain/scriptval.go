@@ -21,6 +21,7 @@ type txValidateItem struct {
 	txInIndex int
 	txIn      *wire.TxIn
 	tx        *hcutil.Tx
+	batchID   int // index of the item for deferred signature checks
 }
 
 // txValidator provides a type which asynchronously validates transaction
@@ -33,6 +34,7 @@ type txValidator struct {
 	utxoView     *UtxoViewpoint
 	flags        txscript.ScriptFlags
 	sigCache     *txscript.SigCache
+	sigBatch     *txscript.SigBatch
 }
 
 // sendResult sends the result of a script pair validation on the internal
@@ -101,6 +103,12 @@ out:
 				break out
 			}
 
+			// Defer the signature checks which can be verified in
+			// batches when the validator collects them.
+			if v.sigBatch != nil {
+				vm.DeferSigChecks(v.sigBatch, txVI.batchID)
+			}
+
 			// Execute the script pair.
 			if err := vm.Execute(); err != nil {
 				str := fmt.Sprintf("failed to validate input "+
@@ -220,11 +228,38 @@ func ValidateTransactionScripts(tx *hcutil.Tx, utxoView *UtxoViewpoint, flags tx
 
 }
 
+// validateBatched validates the scripts for all of the passed transaction
+// inputs with the secp256k1 Schnorr and Bliss signature checks deferred to a
+// signature batch, which is verified once all of the scripts have been
+// executed.  When the batch contains invalid signatures, the inputs which
+// deferred them are validated again without deferring any checks in order to
+// return the same error as validation without batching.
+func validateBatched(items []*txValidateItem, utxoView *UtxoViewpoint,
+	flags txscript.ScriptFlags, sigCache *txscript.SigCache) error {
+
+	validator := newTxValidator(utxoView, flags, sigCache)
+	validator.sigBatch = txscript.NewSigBatch()
+	if err := validator.Validate(items); err != nil {
+		return err
+	}
+
+	for _, id := range validator.sigBatch.Verify() {
+		err := newTxValidator(utxoView, flags, sigCache).Validate(
+			items[id : id+1])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // checkBlockScripts executes and validates the scripts for all transactions in
-// the passed block using multiple goroutines.
+// the passed block using multiple goroutines.  The secp256k1 Schnorr and Bliss
+// signature checks are deferred to a signature batch when batchSigs is set.
 // txTree = true is TxTreeRegular, txTree = false is TxTreeStake.
 func checkBlockScripts(block *hcutil.Block, utxoView *UtxoViewpoint, txTree bool,
-	scriptFlags txscript.ScriptFlags, sigCache *txscript.SigCache) error {
+	scriptFlags txscript.ScriptFlags, sigCache *txscript.SigCache,
+	batchSigs bool) error {
 
 	// Collect all of the transaction inputs and required information for
 	// validation for all transactions in the block into a single slice.
@@ -253,11 +288,16 @@ func checkBlockScripts(block *hcutil.Block, utxoView *UtxoViewpoint, txTree bool
 				txInIndex: txInIdx,
 				txIn:      txIn,
 				tx:        tx,
+				batchID:   len(txValItems),
 			}
 			txValItems = append(txValItems, txVI)
 		}
 	}
 
 	// Validate all of the inputs.
+	if batchSigs {
+		return validateBatched(txValItems, utxoView, scriptFlags,
+			sigCache)
+	}
 	return newTxValidator(utxoView, scriptFlags, sigCache).Validate(txValItems)
 }
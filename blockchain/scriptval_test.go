@@ -9,7 +9,15 @@ package blockchain_test
 //	"fmt"
 //	"runtime"
 import (
+	"crypto/rand"
+	"reflect"
 	"testing"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/chaincfg/chainec"
+	"github.com/coolsnady/hcd/txscript"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
 )
 
 //	"github.com/coolsnady/hcd/blockchain"
@@ -56,3 +64,97 @@ func TestCheckBlockScripts(t *testing.T) {
 		}
 	*/
 }
+
+// schnorrSpendBlock returns a block whose regular transactions spend the given
+// number of outputs paying to secp256k1 Schnorr public keys along with the
+// view containing those outputs.  The private keys of the outputs are returned
+// as well so tests can replace the signatures.
+func schnorrSpendBlock(t testing.TB, numInputs int) (*wire.MsgBlock,
+	*blockchain.UtxoViewpoint, []chainec.PrivateKey, [][]byte) {
+
+	const secSchnorr = 2
+	keys := make([]chainec.PrivateKey, numInputs)
+	pkScripts := make([][]byte, numInputs)
+	prevTx := wire.NewMsgTx()
+	prevTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil))
+	for i := range keys {
+		keyDB, _, _, err := chainec.SecSchnorr.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKey: %v", err)
+		}
+		key, pk := chainec.SecSchnorr.PrivKeyFromBytes(keyDB)
+		pkScript, err := txscript.NewScriptBuilder().
+			AddData(pk.Serialize()).AddInt64(secSchnorr).
+			AddOp(txscript.OP_CHECKSIGALT).Script()
+		if err != nil {
+			t.Fatalf("Script: %v", err)
+		}
+		keys[i], pkScripts[i] = key, pkScript
+		prevTx.AddTxOut(wire.NewTxOut(1e8, pkScript))
+	}
+	view := blockchain.NewUtxoViewpoint()
+	view.AddTxOuts(hcutil.NewTx(prevTx), 1, 0)
+
+	block := &wire.MsgBlock{}
+	prevHash := prevTx.TxHash()
+	for i := range keys {
+		tx := wire.NewMsgTx()
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevHash, uint32(i),
+			wire.TxTreeRegular), nil))
+		tx.AddTxOut(wire.NewTxOut(1e8-1e4, []byte{txscript.OP_TRUE}))
+		block.AddTransaction(tx)
+		signSchnorrSpend(t, tx, pkScripts[i], keys[i])
+	}
+	return block, view, keys, pkScripts
+}
+
+// signSchnorrSpend sets the signature script of the only input of the passed
+// transaction to a signature made with the passed key.
+func signSchnorrSpend(t testing.TB, tx *wire.MsgTx, pkScript []byte,
+	key chainec.PrivateKey) {
+
+	sig, err := txscript.RawTxInSignatureAlt(tx, 0, pkScript,
+		txscript.SigHashAll, key, 2)
+	if err != nil {
+		t.Fatalf("RawTxInSignatureAlt: %v", err)
+	}
+	sigScript, err := txscript.NewScriptBuilder().AddData(sig).Script()
+	if err != nil {
+		t.Fatalf("Script: %v", err)
+	}
+	tx.TxIn[0].SignatureScript = sigScript
+}
+
+// TestCheckBlockScriptsBatched ensures validating the scripts of a block with
+// the signatures verified in batches gives the same result as validating them
+// individually, including the error for an invalid signature.
+func TestCheckBlockScriptsBatched(t *testing.T) {
+	block, view, keys, pkScripts := schnorrSpendBlock(t, 300)
+	flags := txscript.ScriptBip16 | txscript.ScriptVerifyCleanStack
+
+	for _, batch := range []bool{false, true} {
+		err := blockchain.TstCheckBlockScripts(hcutil.NewBlock(block),
+			view, true, flags, nil, batch)
+		if err != nil {
+			t.Fatalf("batch %v: unexpected error: %v", batch, err)
+		}
+	}
+
+	// Sign an input in the middle of the block with the wrong key and
+	// ensure both modes reject the block with the same error.
+	signSchnorrSpend(t, block.Transactions[211], pkScripts[211], keys[0])
+	var errs []error
+	for _, batch := range []bool{false, true} {
+		err := blockchain.TstCheckBlockScripts(hcutil.NewBlock(block),
+			view, true, flags, nil, batch)
+		rerr, ok := err.(blockchain.RuleError)
+		if !ok || rerr.ErrorCode != blockchain.ErrScriptValidation {
+			t.Fatalf("batch %v: unexpected error: %v", batch, err)
+		}
+		errs = append(errs, err)
+	}
+	if !reflect.DeepEqual(errs[0], errs[1]) {
+		t.Fatalf("mismatched errors - individual %v, batched %v",
+			errs[0], errs[1])
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/coolsnady/hcd/blockchain/stake/internal/tickettreap"
 	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/wire"
 )
 
 var (
@@ -246,6 +247,140 @@ func fetchWinners(idxs []int, t *tickettreap.Immutable) ([]*tickettreap.Key, err
 	return winners, nil
 }
 
+// LotteryResult describes how the tickets eligible to vote on the child of a
+// block are selected by the lottery seeded with the header of the block.
+type LotteryResult struct {
+	// Seed is the seed the PRNG derives from the serialized header.
+	Seed chainhash.Hash
+
+	// PoolSize is the number of live tickets the winners are selected
+	// from.
+	PoolSize int
+
+	// Indexes are the positions of the winners in the live tickets sorted
+	// by their hashes, in the order they were drawn.
+	Indexes []int
+
+	// Winners are the selected tickets in the order they were drawn.
+	Winners []chainhash.Hash
+
+	// StateHash is the hash of the state of the PRNG after the winners
+	// were drawn.
+	StateHash chainhash.Hash
+
+	// FinalState is the checksum of the winners and the PRNG state which
+	// the header of the child block commits to.
+	FinalState [6]byte
+}
+
+// runLottery draws the passed number of winners from the live tickets with
+// the PRNG seeded by the passed header.
+func runLottery(header wire.BlockHeader, liveTickets *tickettreap.Immutable,
+	ticketsPerBlock uint16) (*LotteryResult, error) {
+
+	hB, err := header.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	prng := NewHash256PRNG(hB)
+	result := &LotteryResult{
+		Seed:     prng.seed,
+		PoolSize: liveTickets.Len(),
+	}
+	idxs, err := findTicketIdxs(liveTickets.Len(), ticketsPerBlock, prng)
+	if err != nil {
+		return nil, err
+	}
+	result.Indexes = make([]int, len(idxs))
+	copy(result.Indexes, idxs)
+
+	stateBuffer := make([]byte, 0,
+		(int(ticketsPerBlock)+1)*chainhash.HashSize)
+	winnersKeys, err := fetchWinners(idxs, liveTickets)
+	if err != nil {
+		return nil, err
+	}
+	result.Winners = make([]chainhash.Hash, 0, len(winnersKeys))
+	for _, treapKey := range winnersKeys {
+		ticketHash := chainhash.Hash(*treapKey)
+		result.Winners = append(result.Winners, ticketHash)
+		stateBuffer = append(stateBuffer, ticketHash[:]...)
+	}
+	result.StateHash = prng.StateHash()
+	stateBuffer = append(stateBuffer, result.StateHash[:]...)
+	copy(result.FinalState[:], chainhash.HashB(stateBuffer)[0:6])
+
+	return result, nil
+}
+
+// RunLottery recomputes the lottery which selects the tickets eligible to vote
+// on the child of the block with the passed header from the live tickets of
+// the node, which must be the stake node of that block.  The winners and final
+// state of the result match those of the node when it was connected with the
+// same header.
+func (sn *Node) RunLottery(header wire.BlockHeader) (*LotteryResult, error) {
+	return runLottery(header, sn.liveTickets, sn.params.TicketsPerBlock)
+}
+
+// VoteProbability returns the probability that a live ticket is selected to
+// vote on a block when the passed number of tickets per block are drawn from a
+// ticket pool of the passed size.
+func VoteProbability(poolSize int, ticketsPerBlock uint16) float64 {
+	if poolSize <= int(ticketsPerBlock) {
+		return 1
+	}
+	return float64(ticketsPerBlock) / float64(poolSize)
+}
+
+// VoteWithinProbability returns the probability that a live ticket with the
+// passed per block vote probability is selected within the passed number of
+// blocks.  The pool size is assumed to remain constant.
+func VoteWithinProbability(p float64, blocks uint32) float64 {
+	return 1 - math.Pow(1-p, float64(blocks))
+}
+
+// BlocksForVoteProbability returns the minimum number of blocks within which a
+// live ticket with the passed per block vote probability is selected with at
+// least the passed target probability.  The pool size is assumed to remain
+// constant.
+func BlocksForVoteProbability(p, target float64) uint32 {
+	switch {
+	case target <= 0:
+		return 0
+	case p >= 1:
+		return 1
+	case target >= 1 || p <= 0:
+		return math.MaxUint32
+	}
+	blocks := math.Ceil(math.Log(1-target) / math.Log(1-p))
+	if blocks > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(blocks)
+}
+
+// ExpectedVoteBlocks returns the expected number of blocks until a live ticket
+// with the passed per block vote probability is selected, given that it is
+// selected before it expires after the passed number of blocks.  The pool size
+// is assumed to remain constant.
+func ExpectedVoteBlocks(p float64, expiry uint32) float64 {
+	if p >= 1 {
+		return 1
+	}
+	if p <= 0 || expiry == 0 {
+		return 0
+	}
+
+	// The number of blocks until selection is geometrically distributed,
+	// so the sum of k*p*q^(k-1) for k = 1..E divided by the probability of
+	// being selected within E blocks is
+	// (1 - (E+1)q^E + Eq^(E+1)) / (p * (1 - q^E)).
+	q := 1 - p
+	e := float64(expiry)
+	qe := math.Pow(q, e)
+	return (1 - (e+1)*qe + e*qe*q) / (p * (1 - qe))
+}
+
 // fetchExpired is a ticket database specific function which iterates over the
 // entire treap and finds tickets that are equal or less than the given height.
 // These are returned as a slice of pointers to keys, which can be recast as
@@ -8,6 +8,7 @@ package stake
 import (
 	"bytes"
 	"encoding/binary"
+	"math"
 	"math/rand"
 	"reflect"
 	"sort"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/coolsnady/hcd/blockchain/stake/internal/tickettreap"
 	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/wire"
 )
 
 func TestBasicPRNG(t *testing.T) {
@@ -245,3 +247,122 @@ func BenchmarkHashPRNG(b *testing.B) {
 		prng.Hash256Rand()
 	}
 }
+
+// TestRunLottery ensures the recomputed lottery selects the tickets at the
+// drawn indexes of the sorted live tickets and commits to them in the final
+// state.
+func TestRunLottery(t *testing.T) {
+	treap := new(tickettreap.Immutable)
+	var hashes []chainhash.Hash
+	for i := 0; i < 0xff; i++ {
+		h := chainhash.HashH([]byte{byte(i)})
+		treap = treap.Put(tickettreap.Key(h), &tickettreap.Value{
+			Height: uint32(i),
+		})
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+
+	header := wire.BlockHeader{Height: 1234, Nonce: 5678}
+	result, err := runLottery(header, treap, 5)
+	if err != nil {
+		t.Fatalf("runLottery: unexpected error: %v", err)
+	}
+	if result.PoolSize != len(hashes) || len(result.Winners) != 5 ||
+		len(result.Indexes) != 5 {
+		t.Fatalf("unexpected lottery result %+v", result)
+	}
+
+	// Ensure the draws match those of a PRNG seeded with the header.
+	hB, err := header.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	prng := NewHash256PRNG(hB)
+	idxs, err := FindTicketIdxs(len(hashes), 5, prng)
+	if err != nil {
+		t.Fatalf("FindTicketIdxs: %v", err)
+	}
+	if !reflect.DeepEqual(result.Indexes, idxs) {
+		t.Fatalf("unexpected indexes - got %v, want %v", result.Indexes,
+			idxs)
+	}
+	if result.StateHash != prng.StateHash() {
+		t.Fatal("unexpected PRNG state hash")
+	}
+
+	// Ensure the winners are the tickets at the drawn indexes of the
+	// sorted pool and the final state commits to them in order.
+	stateBuffer := make([]byte, 0, 6*chainhash.HashSize)
+	for i, idx := range idxs {
+		if result.Winners[i] != hashes[idx] {
+			t.Fatalf("winner %d is %v, want %v", i, result.Winners[i],
+				hashes[idx])
+		}
+		stateBuffer = append(stateBuffer, hashes[idx][:]...)
+	}
+	stateBuffer = append(stateBuffer, result.StateHash[:]...)
+	if !bytes.Equal(result.FinalState[:], chainhash.HashB(stateBuffer)[:6]) {
+		t.Fatalf("unexpected final state %x", result.FinalState)
+	}
+
+	// Ensure a too small pool is rejected.
+	if _, err := runLottery(header, treap, 0x100); err == nil {
+		t.Fatal("expected error for a pool smaller than the winners")
+	}
+}
+
+// TestVoteProbability ensures the vote probability calculations match the
+// geometric distribution of the number of blocks until a ticket is selected.
+func TestVoteProbability(t *testing.T) {
+	p := VoteProbability(40960, 5)
+	if p != 5.0/40960 {
+		t.Fatalf("unexpected vote probability %v", p)
+	}
+	if VoteProbability(3, 5) != 1 {
+		t.Fatal("tickets of a pool smaller than the winners always vote")
+	}
+
+	// Sum up the distribution directly.
+	const expiry = 40960
+	var cumulative, weighted float64
+	for k := uint32(1); k <= expiry; k++ {
+		pk := p * math.Pow(1-p, float64(k-1))
+		cumulative += pk
+		weighted += float64(k) * pk
+
+		if k == 8192 {
+			got := VoteWithinProbability(p, k)
+			if math.Abs(got-cumulative) > 1e-9 {
+				t.Fatalf("vote within %d blocks - got %v, want %v",
+					k, got, cumulative)
+			}
+		}
+	}
+	got := ExpectedVoteBlocks(p, expiry)
+	want := weighted / cumulative
+	if math.Abs(got-want) > 1e-6*want {
+		t.Fatalf("expected vote blocks - got %v, want %v", got, want)
+	}
+
+	// Ensure the number of blocks for a probability is the smallest one
+	// which reaches it.
+	for _, target := range []float64{0.25, 0.5, 0.9, 0.99} {
+		blocks := BlocksForVoteProbability(p, target)
+		if VoteWithinProbability(p, blocks) < target ||
+			VoteWithinProbability(p, blocks-1) >= target {
+			t.Fatalf("unexpected blocks %d for probability %v", blocks,
+				target)
+		}
+	}
+	if BlocksForVoteProbability(p, 0) != 0 ||
+		BlocksForVoteProbability(1, 0.5) != 1 ||
+		BlocksForVoteProbability(p, 1) != math.MaxUint32 {
+		t.Fatal("unexpected blocks for edge case probabilities")
+	}
+	if ExpectedVoteBlocks(1, expiry) != 1 || ExpectedVoteBlocks(p, 0) != 0 {
+		t.Fatal("unexpected expected blocks for edge cases")
+	}
+}
@@ -603,33 +603,13 @@ func connectNode(node *Node, header wire.BlockHeader, ticketsSpentInBlock, revok
 	if connectedNode.height >=
 		uint32(connectedNode.params.StakeValidationHeight-1) {
 		// Find the next set of winners.
-		hB, err := header.Bytes()
-		if err != nil {
-			return nil, err
-		}
-		prng := NewHash256PRNG(hB)
-		idxs, err := findTicketIdxs(connectedNode.liveTickets.Len(),
-			connectedNode.params.TicketsPerBlock, prng)
+		lottery, err := runLottery(header, connectedNode.liveTickets,
+			connectedNode.params.TicketsPerBlock)
 		if err != nil {
 			return nil, err
 		}
-
-		stateBuffer := make([]byte, 0,
-			(connectedNode.params.TicketsPerBlock+1)*chainhash.HashSize)
-		nextWinnersKeys, err := fetchWinners(idxs, connectedNode.liveTickets)
-		if err != nil {
-			return nil, err
-		}
-
-		for _, treapKey := range nextWinnersKeys {
-			ticketHash := chainhash.Hash(*treapKey)
-			connectedNode.nextWinners = append(connectedNode.nextWinners,
-				ticketHash)
-			stateBuffer = append(stateBuffer, ticketHash[:]...)
-		}
-		lastHash := prng.StateHash()
-		stateBuffer = append(stateBuffer, lastHash[:]...)
-		copy(connectedNode.finalState[:], chainhash.HashB(stateBuffer)[0:6])
+		connectedNode.nextWinners = lottery.Winners
+		connectedNode.finalState = lottery.FinalState
 	}
 
 	return connectedNode, nil
@@ -1036,3 +1016,16 @@ func WriteDisconnectedBestNode(dbTx database.Tx, node *Node, hash chainhash.Hash
 		NextWinners: nextWinners,
 	})
 }
+
+// FetchBlockUndoData fetches the ticket undo data for the main chain block at
+// the passed height from the database.  The undo data describes how each ticket
+// affected by the block changed state, such as the tickets which matured, were
+// spent, were missed or expired, and were revoked.
+func FetchBlockUndoData(dbTx database.Tx, height uint32) (UndoTicketDataSlice, error) {
+	utds, err := ticketdb.DbFetchBlockUndoData(dbTx, height)
+	if err != nil {
+		return nil, err
+	}
+
+	return UndoTicketDataSlice(utds), nil
+}
@@ -7,6 +7,9 @@
 package blockchain
 
 import (
+	"fmt"
+
+	"github.com/coolsnady/hcd/blockchain/stake"
 	"github.com/coolsnady/hcd/chaincfg/chainhash"
 	"github.com/coolsnady/hcd/database"
 	"github.com/coolsnady/hcd/txscript"
@@ -40,8 +43,8 @@ func (b *BlockChain) lotteryDataForNode(node *blockNode) ([]chainhash.Hash, int,
 		return []chainhash.Hash{}, 0, [6]byte{}, err
 	}
 
-	return stakeNode.Winners(), b.bestNode.stakeNode.PoolSize(),
-		b.bestNode.stakeNode.FinalState(), nil
+	return stakeNode.Winners(), stakeNode.PoolSize(),
+		stakeNode.FinalState(), nil
 }
 
 // lotteryDataForBlock takes a node block hash and returns the next tickets
@@ -85,6 +88,121 @@ func (b *BlockChain) LotteryDataForBlock(hash *chainhash.Hash) ([]chainhash.Hash
 	return b.lotteryDataForBlock(hash)
 }
 
+// LotteryVerification describes the recomputed ticket lottery which selected
+// the tickets eligible to vote on a block, along with the values the block
+// header commits to.
+type LotteryVerification struct {
+	// Height is the height of the block the winners vote on.
+	Height int64
+
+	// ParentHash is the hash of the parent block whose header seeds the
+	// lottery and whose live tickets the winners are drawn from.
+	ParentHash chainhash.Hash
+
+	// Lottery is the recomputed lottery.
+	Lottery *stake.LotteryResult
+
+	// StoredWinners and StoredFinalState are the winners and final state
+	// recorded in the stake node of the parent when it was connected.
+	StoredWinners    []chainhash.Hash
+	StoredFinalState [6]byte
+
+	// HeaderPoolSize and HeaderFinalState are the pool size and final
+	// state the header of the block commits to.
+	HeaderPoolSize   uint32
+	HeaderFinalState [6]byte
+
+	// Live reports for each of the tickets passed to VerifyLottery whether
+	// it was live, and thus eligible to be selected, in the pool of the
+	// parent.
+	Live []bool
+}
+
+// VerifyLottery recomputes the ticket lottery which selected the tickets
+// eligible to vote on the block with the passed hash from the header of its
+// parent and the live tickets of the parent.  The block may be any block of
+// the main chain or a block of a side chain which forks from the main chain
+// within minMemoryNodes blocks of the best block.  It also reports whether
+// each of the passed tickets was live in the ticket pool the winners were drawn
+// from.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) VerifyLottery(hash *chainhash.Hash, tickets []chainhash.Hash) (*LotteryVerification, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node, exists := b.index[*hash]
+	if !exists {
+		return nil, fmt.Errorf("block %v is not known", hash)
+	}
+	if node.height < b.chainParams.StakeValidationHeight {
+		return nil, fmt.Errorf("block %v at height %d is below the "+
+			"stake validation height %d and has no ticket lottery",
+			hash, node.height, b.chainParams.StakeValidationHeight)
+	}
+	parent := node.parent
+	if parent == nil {
+		return nil, AssertError(fmt.Sprintf("block %v has no parent "+
+			"node", hash))
+	}
+
+	// The stake node of a main chain parent is restored on a temporary
+	// rewind from the best node, so the lottery of any historical block
+	// can be recomputed without caching stake data on the block nodes.
+	//
+	// Restoring the stake node of a side chain parent on the other hand
+	// rewinds the ticket treaps back to the fork point and caches the
+	// result on every node along the way.  Only the stake data of nodes
+	// within minMemoryNodes of the best node is pruned again, so refuse to
+	// rewind past them.
+	var stakeNode *stake.Node
+	if parent.inMainChain {
+		var err error
+		stakeNode, err = b.fetchMainChainStakeNode(parent)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		minHeight := b.bestNode.height - minMemoryNodes
+		fork := parent
+		for !fork.inMainChain && fork.height > minHeight {
+			fork = fork.parent
+		}
+		if fork.height <= minHeight {
+			return nil, fmt.Errorf("block %v forks from the main chain "+
+				"more than %d blocks before the best block and its "+
+				"ticket lottery can not be recomputed", hash,
+				minMemoryNodes)
+		}
+
+		var err error
+		stakeNode, err = b.fetchStakeNode(parent)
+		if err != nil {
+			return nil, err
+		}
+	}
+	lottery, err := stakeNode.RunLottery(parent.header)
+	if err != nil {
+		return nil, err
+	}
+
+	live := make([]bool, len(tickets))
+	for i := range tickets {
+		live[i] = stakeNode.ExistsLiveTicket(tickets[i])
+	}
+
+	return &LotteryVerification{
+		Height:           node.height,
+		ParentHash:       parent.hash,
+		Lottery:          lottery,
+		StoredWinners:    stakeNode.Winners(),
+		StoredFinalState: stakeNode.FinalState(),
+		HeaderPoolSize:   node.header.PoolSize,
+		HeaderFinalState: node.header.FinalState,
+		Live:             live,
+	}, nil
+}
+
 // LiveTickets returns all currently live tickets from the stake database.
 //
 // This function is NOT safe for concurrent access.
@@ -14,25 +14,15 @@ import (
 	"github.com/coolsnady/hcd/database"
 )
 
-// nodeAtHeightFromTopNode goes backwards through a node until it a reaches
-// the node with a desired block height; it returns this block.  The benefit is
-// this works for both the main chain and the side chain.
+// nodeAtHeightFromTopNode returns the ancestor of the node which is the given
+// number of blocks before it.  The benefit is this works for both the main
+// chain and the side chain.
 func (b *BlockChain) nodeAtHeightFromTopNode(node *blockNode,
 	toTraverse int64) (*blockNode, error) {
-	oldNode := node
-	var err error
-
-	for i := 0; i < int(toTraverse); i++ {
-		// Get the previous block node.
-		oldNode, err = b.getPrevNodeFromNode(oldNode)
-		if err != nil {
-			return nil, err
-		}
-
-		if oldNode == nil {
-			return nil, fmt.Errorf("unable to obtain previous node; " +
-				"ancestor is genesis block")
-		}
+	oldNode := node.RelativeAncestor(toTraverse)
+	if oldNode == nil {
+		return nil, fmt.Errorf("unable to obtain previous node; " +
+			"ancestor is genesis block")
 	}
 
 	return oldNode, nil
@@ -114,6 +104,10 @@ func (b *BlockChain) fetchStakeNode(node *blockNode) (*stake.Node, error) {
 					return nil, err
 				}
 			}
+			err = b.maybeFetchTicketInfo(node)
+			if err != nil {
+				return nil, err
+			}
 
 			node.stakeNode, err = node.parent.stakeNode.ConnectNode(node.header,
 				node.ticketsSpent,
@@ -207,6 +201,10 @@ func (b *BlockChain) fetchStakeNode(node *blockNode) (*stake.Node, error) {
 					return nil, err
 				}
 			}
+			err = b.maybeFetchTicketInfo(n)
+			if err != nil {
+				return nil, err
+			}
 
 			n.stakeNode, err = current.stakeNode.ConnectNode(n.header,
 				n.ticketsSpent, n.ticketsRevoked, n.newTickets)
@@ -220,3 +218,53 @@ func (b *BlockChain) fetchStakeNode(node *blockNode) (*stake.Node, error) {
 
 	return current.stakeNode, nil
 }
+
+// fetchMainChainStakeNode returns the stake node of the passed main chain
+// node by rewinding the ticket treaps from the best node with the undo data
+// stored in the stake database.  Unlike fetchStakeNode, the intermediate stake
+// nodes are only temporary and are NOT cached on the block nodes, so this may
+// be used for main chain nodes of any depth without keeping their stake data
+// in memory.  Stake nodes which are already cached are reused though.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) fetchMainChainStakeNode(node *blockNode) (*stake.Node, error) {
+	if !node.inMainChain {
+		return nil, AssertError(fmt.Sprintf("block %v is not part of "+
+			"the main chain", node.hash))
+	}
+	if node.stakeNode != nil {
+		return node.stakeNode, nil
+	}
+
+	// Move backwards through the main chain, undoing the ticket treaps for
+	// each block.  The database is passed because the undo data and new
+	// tickets of the blocks are not necessarily in memory.
+	current := b.bestNode
+	sn := current.stakeNode
+	err := b.db.View(func(dbTx database.Tx) error {
+		for current != node {
+			parent := current.parent
+			if parent == nil {
+				return AssertError(fmt.Sprintf("block %v is not an "+
+					"ancestor of the best block", node.hash))
+			}
+			if parent.stakeNode != nil {
+				sn = parent.stakeNode
+			} else {
+				var err error
+				sn, err = sn.DisconnectNode(parent.header,
+					parent.stakeUndoData, parent.newTickets, dbTx)
+				if err != nil {
+					return err
+				}
+			}
+			current = parent
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sn, nil
+}
@@ -30,6 +30,7 @@ func newFakeChain(params *chaincfg.Params) *BlockChain {
 		chainParams:      params,
 		deploymentCaches: newThresholdCaches(params),
 		bestNode:         node,
+		bestHeader:       node,
 		index:            index,
 		isVoterMajorityVersionCache:   make(map[[stakeMajorityCacheKeySize]byte]bool),
 		isStakeMajorityVersionCache:   make(map[[stakeMajorityCacheKeySize]byte]bool),
@@ -55,6 +56,7 @@ func newFakeNode(parent *blockNode, blockVersion int32, stakeVersion uint32, bit
 	node := newBlockNode(header, nil, nil, nil)
 	node.parent = parent
 	node.workSum.Add(parent.workSum, node.workSum)
+	node.buildSkipList()
 	return node
 }
 
@@ -130,6 +130,22 @@ func (s *SubsidyCache) CalcBlockSubsidy(height int64) int64 {
 	return subsidy
 }
 
+// SubsidyEndIteration returns the subsidy reduction interval from which on the
+// block subsidy is zero.  The subsidy decreases by a factor of ten per interval
+// after the 1681st one, so this is the first interval after it with a subsidy
+// of zero.  Callers which accept arbitrary heights can clamp them to the
+// returned interval in order to avoid adding iterations the chain never
+// reaches to the cache.
+//
+// Safe for concurrent access.
+func (s *SubsidyCache) SubsidyEndIteration() int64 {
+	iteration := int64(1682)
+	for s.CalcBlockSubsidy(iteration*s.params.SubsidyReductionInterval) != 0 {
+		iteration++
+	}
+	return iteration
+}
+
 // CalcBlockWorkSubsidy calculates the proof of work subsidy for a block as a
 // proportion of the total subsidy.
 func CalcBlockWorkSubsidy(subsidyCache *SubsidyCache, height int64,
@@ -215,6 +231,89 @@ func CalcBlockTaxSubsidy(subsidyCache *SubsidyCache, height int64, voters uint16
 	return adjusted
 }
 
+// SubsidySum is the subsidy created by a range of blocks split by recipient.
+type SubsidySum struct {
+	// BlockOne is the subsidy of block one which distributes the initial
+	// tokens, if any.
+	BlockOne int64
+
+	// Work, Stake, and Tax are the proof of work, proof of stake, and
+	// developer organization subsidies respectively.
+	Work  int64
+	Stake int64
+	Tax   int64
+}
+
+// Total returns the total subsidy of all recipients.
+func (s *SubsidySum) Total() int64 {
+	return s.BlockOne + s.Work + s.Stake + s.Tax
+}
+
+// CalcSubsidySum returns the subsidy created by the blocks in the passed
+// inclusive range of heights when every block includes all of the votes it
+// may.  The subsidy of the votes in a block is counted for that block even
+// though it is calculated from the height of its parent.  The subsidy is
+// constant within a reduction interval, so the sum is calculated per interval
+// and stops once the subsidy reaches zero, which makes it cheap for heights
+// arbitrarily far in the future.
+//
+// Safe for concurrent access.
+func CalcSubsidySum(subsidyCache *SubsidyCache, startHeight, endHeight int64,
+	params *chaincfg.Params) SubsidySum {
+
+	var sum SubsidySum
+
+	// The genesis block does not have a subsidy and block one only pays
+	// out the initial tokens.
+	if startHeight < 1 {
+		startHeight = 1
+	}
+	if startHeight == 1 && endHeight >= 1 {
+		sum.BlockOne = params.BlockOneSubsidy()
+		startHeight = 2
+	}
+
+	voters := params.TicketsPerBlock
+	interval := params.SubsidyReductionInterval
+	stakeValidationHeight := params.StakeValidationHeight
+	for low := startHeight; low <= endHeight; {
+		// The subsidy of a block only changes at the start of a
+		// reduction interval and at the stake validation height.
+		high := (low/interval+1)*interval - 1
+		if low < stakeValidationHeight && high >= stakeValidationHeight {
+			high = stakeValidationHeight - 1
+		}
+		if high > endHeight {
+			high = endHeight
+		}
+		numBlocks := high - low + 1
+
+		work := CalcBlockWorkSubsidy(subsidyCache, low, voters, params)
+		tax := CalcBlockTaxSubsidy(subsidyCache, low, voters, params)
+		sum.Work += numBlocks * work
+		sum.Tax += numBlocks * tax
+		if low >= stakeValidationHeight {
+			// The votes of the first block are paid the subsidy of
+			// the height before, which is in the previous reduction
+			// interval at the start of an interval.
+			firstVotes := CalcStakeVoteSubsidy(subsidyCache, low-1,
+				params) * int64(voters)
+			votes := CalcStakeVoteSubsidy(subsidyCache, low,
+				params) * int64(voters)
+			sum.Stake += firstVotes + (numBlocks-1)*votes
+
+			// No further subsidy is created once it reaches zero.
+			if firstVotes == 0 && work == 0 && tax == 0 &&
+				subsidyCache.CalcBlockSubsidy(low) == 0 {
+				break
+			}
+		}
+		low = high + 1
+	}
+
+	return sum
+}
+
 // BlockOneCoinbasePaysTokens checks to see if the first block coinbase pays
 // out to the network initial token ledger.
 func BlockOneCoinbasePaysTokens(tx *hcutil.Tx,
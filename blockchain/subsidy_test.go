@@ -56,3 +56,128 @@ func TestBlockSubsidy(t *testing.T) {
 		t.Errorf("Bad total subsidy; want 2099999999800912, got %v", totalSubsidy)
 	}
 }
+
+// TestCalcSubsidySum ensures the subsidy summed per reduction interval matches
+// the subsidy summed block by block for ranges crossing reduction intervals,
+// the stake validation height, and the end of the subsidy.
+func TestCalcSubsidySum(t *testing.T) {
+	params := chaincfg.SimNetParams
+	params.StakeValidationHeight = params.SubsidyReductionInterval + 16
+	subsidyCache := blockchain.NewSubsidyCache(0, &params)
+
+	// blockSubsidy returns the subsidy created by the block at the passed
+	// height when it includes all votes.
+	voters := params.TicketsPerBlock
+	blockSubsidy := func(height int64) blockchain.SubsidySum {
+		var sum blockchain.SubsidySum
+		switch {
+		case height < 1:
+		case height == 1:
+			sum.BlockOne = params.BlockOneSubsidy()
+		default:
+			sum.Work = blockchain.CalcBlockWorkSubsidy(subsidyCache,
+				height, voters, &params)
+			sum.Tax = blockchain.CalcBlockTaxSubsidy(subsidyCache,
+				height, voters, &params)
+			if height >= params.StakeValidationHeight {
+				sum.Stake = blockchain.CalcStakeVoteSubsidy(
+					subsidyCache, height-1, &params) *
+					int64(voters)
+			}
+		}
+		return sum
+	}
+
+	// Sum the subsidy block by block until it reaches zero.
+	var sums []blockchain.SubsidySum
+	var total blockchain.SubsidySum
+	for height := int64(0); ; height++ {
+		sum := blockSubsidy(height)
+		if height > params.StakeValidationHeight && sum.Total() == 0 {
+			break
+		}
+		total.BlockOne += sum.BlockOne
+		total.Work += sum.Work
+		total.Stake += sum.Stake
+		total.Tax += sum.Tax
+		sums = append(sums, total)
+	}
+	lastHeight := int64(len(sums) - 1)
+
+	// rangeSum returns the expected sum of the passed inclusive range.
+	rangeSum := func(start, end int64) blockchain.SubsidySum {
+		if end > lastHeight {
+			end = lastHeight
+		}
+		sum := sums[end]
+		if start > 0 {
+			before := sums[start-1]
+			sum.BlockOne -= before.BlockOne
+			sum.Work -= before.Work
+			sum.Stake -= before.Stake
+			sum.Tax -= before.Tax
+		}
+		return sum
+	}
+
+	interval := params.SubsidyReductionInterval
+	svh := params.StakeValidationHeight
+	tests := []struct {
+		start, end int64
+	}{
+		{0, 0},
+		{0, 1},
+		{1, 1},
+		{2, 2},
+		{0, svh - 1},
+		{svh, svh},
+		{svh - 1, svh + 1},
+		{interval - 1, interval},
+		{interval, 2*interval - 1},
+		{interval + 5, 7*interval + 3},
+		{3 * interval, 3 * interval},
+		{0, 1000 * interval},
+		{0, lastHeight},
+		{lastHeight - 3*interval, lastHeight + 3*interval},
+		{0, 1 << 60},
+	}
+	for _, test := range tests {
+		got := blockchain.CalcSubsidySum(subsidyCache, test.start,
+			test.end, &params)
+		want := rangeSum(test.start, test.end)
+		if got != want {
+			t.Errorf("CalcSubsidySum(%d, %d): got %+v, want %+v",
+				test.start, test.end, got, want)
+		}
+	}
+}
+
+// TestSubsidyEndIteration ensures the interval from which on no more subsidy is
+// created is the first one with a subsidy of zero after the final reduction
+// formula applies.
+func TestSubsidyEndIteration(t *testing.T) {
+	for _, params := range []*chaincfg.Params{&chaincfg.MainNetParams,
+		&chaincfg.SimNetParams} {
+
+		subsidyCache := blockchain.NewSubsidyCache(0, params)
+		interval := params.SubsidyReductionInterval
+		end := subsidyCache.SubsidyEndIteration()
+		if end < 1682 {
+			t.Errorf("%s: end iteration %d is before the final "+
+				"reduction formula applies", params.Name, end)
+			continue
+		}
+		subsidy := subsidyCache.CalcBlockSubsidy(end * interval)
+		if subsidy != 0 {
+			t.Errorf("%s: subsidy of end iteration %d is %d", params.Name,
+				end, subsidy)
+		}
+		if end > 1682 {
+			prev := subsidyCache.CalcBlockSubsidy((end - 1) * interval)
+			if prev == 0 {
+				t.Errorf("%s: subsidy of iteration %d before end "+
+					"iteration is zero", params.Name, end-1)
+			}
+		}
+	}
+}
@@ -475,10 +475,17 @@ func (b *BlockChain) thresholdState(version uint32, prevNode *blockNode, checker
 // desired.  In other words, the returned deployment state is for the block
 // AFTER the passed node.
 //
+// Deployments whose state was forced on the regression test network report
+// the forced state for every block.
+//
 // This function MUST be called with the chain state lock held (for writes).
 func (b *BlockChain) deploymentState(prevNode *blockNode, version uint32, deploymentID string) (ThresholdStateTuple, error) {
 	for k := range b.chainParams.Deployments[version] {
 		if b.chainParams.Deployments[version][k].Vote.Id == deploymentID {
+			if state, ok := b.forcedDeployments[deploymentID]; ok {
+				return state, nil
+			}
+
 			checker := deploymentChecker{
 				deployment: &b.chainParams.Deployments[version][k],
 				chain:      b,
@@ -7,10 +7,12 @@
 package blockchain
 
 import (
+	"github.com/coolsnady/hcd/blockchain/internal/dbnamespace"
 	"github.com/coolsnady/hcd/blockchain/internal/progresslog"
 	"github.com/coolsnady/hcd/blockchain/stake"
 	"github.com/coolsnady/hcd/chaincfg/chainhash"
 	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcutil"
 )
 
 // upgradeToVersion2 upgrades a version 1 blockchain to version 2, allowing
@@ -99,6 +101,67 @@ func (b *BlockChain) upgradeToVersion2() error {
 	return nil
 }
 
+// createBlockIndex creates the block index which was introduced with database
+// version 3 and adds all blocks of the main chain up to and including the
+// passed best height to it.  Unlike the other upgrades, it is done before the
+// chain state is loaded since the chain state is loaded from the block index.
+func (b *BlockChain) createBlockIndex(bestHeight int64) error {
+	log.Infof("Creating the block index.  This may take a while...")
+	progressLogger := progresslog.NewBlockProgressLogger("Indexed", log)
+
+	// The index is created atomically, so a partially created index is
+	// never left behind.
+	err := b.db.Update(func(dbTx database.Tx) error {
+		_, err := dbTx.Metadata().CreateBucket(
+			dbnamespace.BlockIndexBucketName)
+		if err != nil {
+			return err
+		}
+
+		var parent *hcutil.Block
+		for height := int64(0); height <= bestHeight; height++ {
+			block, err := dbFetchBlockByHeight(dbTx, height)
+			if err != nil {
+				return err
+			}
+
+			err = dbPutBlockNode(dbTx, &blockNode{
+				hash:   *block.Hash(),
+				height: height,
+				header: block.MsgBlock().Header,
+				votes:  voteBitsInBlock(block),
+				status: statusDataStored | statusValidated,
+			})
+			if err != nil {
+				return err
+			}
+
+			if parent != nil {
+				progressLogger.LogBlockHeight(block.MsgBlock(),
+					parent.MsgBlock())
+			}
+			parent = block
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Block index created")
+	return nil
+}
+
+// upgradeToVersion3 upgrades a version 2 blockchain to version 3.  The block
+// index which version 3 introduces is already created by createBlockIndex
+// while loading the chain state, so only the version is updated.
+func (b *BlockChain) upgradeToVersion3() error {
+	return b.db.Update(func(dbTx database.Tx) error {
+		b.dbInfo.version = 3
+		return dbPutDatabaseInfo(dbTx, b.dbInfo)
+	})
+}
+
 // upgrade applies all possible upgrades to the blockchain database iteratively,
 // updating old clients to the newest version.
 func (b *BlockChain) upgrade() error {
@@ -109,5 +172,12 @@ func (b *BlockChain) upgrade() error {
 		}
 	}
 
+	if b.dbInfo.version == 2 {
+		err := b.upgradeToVersion3()
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
@@ -371,22 +371,11 @@ func CheckProofOfWork(block *hcutil.Block, powLimit *big.Int) error {
 //
 // The flags do not modify the behavior of this function directly, however they
 // are needed to pass along to checkProofOfWork.
-func checkBlockHeaderSanity(block *hcutil.Block, timeSource MedianTimeSource, flags BehaviorFlags, chainParams *chaincfg.Params) error {
-	powLimit := chainParams.PowLimit
-	posLimit := chainParams.MinimumStakeDiff
-	header := &block.MsgBlock().Header
-
+func checkBlockHeaderSanity(header *wire.BlockHeader, timeSource MedianTimeSource, flags BehaviorFlags, chainParams *chaincfg.Params) error {
 	// Ensure the proof of work bits in the block header is in min/max
 	// range and the block hash is less than the target value described by
 	// the bits.
-	err := checkProofOfWork(header, powLimit, flags)
-	if err != nil {
-		return err
-	}
-
-	// Check to make sure that all newly purchased tickets meet the
-	// difficulty specified in the block.
-	err = checkProofOfStake(block, posLimit)
+	err := checkProofOfWork(header, chainParams.PowLimit, flags)
 	if err != nil {
 		return err
 	}
@@ -411,6 +400,52 @@ func checkBlockHeaderSanity(block *hcutil.Block, timeSource MedianTimeSource, fl
 		return ruleError(ErrTimeTooNew, str)
 	}
 
+	// A block header must not claim more votes than the number of tickets
+	// selected per block, nor more new tickets than allowed per block.
+	if header.Voters > chainParams.TicketsPerBlock {
+		str := fmt.Sprintf("block header commits to %d votes, which "+
+			"is more than the maximum allowed of %d", header.Voters,
+			chainParams.TicketsPerBlock)
+		return ruleError(ErrTooManyVotes, str)
+	}
+	if header.FreshStake > chainParams.MaxFreshStakePerBlock {
+		str := fmt.Sprintf("block header commits to %d new tickets, "+
+			"which is more than the maximum allowed of %d",
+			header.FreshStake, chainParams.MaxFreshStakePerBlock)
+		return ruleError(ErrTooManySStxs, str)
+	}
+
+	// Blocks before stake validation height must not contain any votes or
+	// revocations, may only have 0x0001 as their vote bits, and must have
+	// an all zero final state since no tickets have been selected yet.
+	// Afterwards, a majority of the selected tickets must vote.
+	if int64(header.Height) < chainParams.StakeValidationHeight {
+		if header.Voters != 0 || header.Revocations != 0 {
+			str := fmt.Sprintf("pre stake validation height block "+
+				"header commits to %d votes and %d revocations",
+				header.Voters, header.Revocations)
+			return ruleError(ErrInvalidEarlyStakeTx, str)
+		}
+		if header.VoteBits != earlyVoteBitsValue {
+			str := fmt.Sprintf("pre stake validation height "+
+				"block %v contained an invalid votebits value"+
+				" (expected %v, got %v)", header.BlockHash(),
+				earlyVoteBitsValue, header.VoteBits)
+			return ruleError(ErrInvalidEarlyVoteBits, str)
+		}
+		if header.FinalState != [6]byte{} {
+			str := fmt.Sprintf("pre stake validation height block "+
+				"header commits to a non zero final state of %x",
+				header.FinalState)
+			return ruleError(ErrInvalidFinalState, str)
+		}
+	} else if header.Voters <= chainParams.TicketsPerBlock/2 {
+		str := fmt.Sprintf("block header commits to %d votes, but %d "+
+			"or more are required", header.Voters,
+			chainParams.TicketsPerBlock/2+1)
+		return ruleError(ErrNotEnoughVotes, str)
+	}
+
 	return nil
 }
 
@@ -424,7 +459,14 @@ func checkBlockSanity(block *hcutil.Block, timeSource MedianTimeSource, flags Be
 
 	msgBlock := block.MsgBlock()
 	header := &msgBlock.Header
-	err := checkBlockHeaderSanity(block, timeSource, flags, chainParams)
+	err := checkBlockHeaderSanity(header, timeSource, flags, chainParams)
+	if err != nil {
+		return err
+	}
+
+	// Check to make sure that all newly purchased tickets meet the
+	// difficulty specified in the block.
+	err = checkProofOfStake(block, chainParams.MinimumStakeDiff)
 	if err != nil {
 		return err
 	}
@@ -622,18 +664,6 @@ func checkBlockSanity(block *hcutil.Block, timeSource MedianTimeSource, flags Be
 		}
 	}
 
-	// Blocks before stake validation height may only have 0x0001 as their
-	// VoteBits in the header.
-	if int64(header.Height) < chainParams.StakeValidationHeight {
-		if header.VoteBits != earlyVoteBitsValue {
-			str := fmt.Sprintf("pre stake validation height "+
-				"block %v contained an invalid votebits value"+
-				" (expected %v, got %v)", block.Hash(),
-				earlyVoteBitsValue, header.VoteBits)
-			return ruleError(ErrInvalidEarlyVoteBits, str)
-		}
-	}
-
 	return nil
 }
 
@@ -652,7 +682,9 @@ func CheckWorklessBlockSanity(block *hcutil.Block, timeSource MedianTimeSource,
 }
 
 // checkBlockHeaderContext peforms several validation checks on the block
-// header which depend on its position within the block chain.
+// header which depend on its position within the block chain.  Only the
+// headers of the previous blocks are required, so the checks can be performed
+// before any of the blocks are available.
 //
 // The flags modify the behavior of this function as follows:
 //  - BFFastAdd: All checks except those involving comparing the header against
@@ -695,11 +727,50 @@ func (b *BlockChain) checkBlockHeaderContext(header *wire.BlockHeader, prevNode
 			str = fmt.Sprintf(str, header.Timestamp, medianTime)
 			return ruleError(ErrTimeTooOld, str)
 		}
+
+		// Ensure the stake difficulty specified in the block header
+		// matches the calculated stake difficulty based on the
+		// previous block and the stake difficulty retarget rules.
+		expSDiff, err := b.calcNextRequiredStakeDifficulty(prevNode)
+		if err != nil {
+			return err
+		}
+		if header.SBits != expSDiff {
+			str := fmt.Sprintf("block stake difficulty of %d is "+
+				"not the expected value of %d", header.SBits,
+				expSDiff)
+			return ruleError(ErrUnexpectedDifficulty, str)
+		}
+
+		// Ensure the ticket pool size committed to by the block header
+		// is sane.  It is the size of the pool after connecting the
+		// previous block, so it can only grow by the tickets purchased
+		// in a single block maturing, and only shrink by the tickets
+		// selected to vote along with the tickets purchased in a single
+		// block expiring.
+		prevPoolSize := int64(prevNode.header.PoolSize)
+		poolSize := int64(header.PoolSize)
+		maxNewTickets := int64(b.chainParams.MaxFreshStakePerBlock)
+		maxGrowth := maxNewTickets
+		maxShrink := int64(b.chainParams.TicketsPerBlock) + maxNewTickets
+		if poolSize > prevPoolSize+maxGrowth ||
+			poolSize < prevPoolSize-maxShrink {
+
+			str := fmt.Sprintf("block header commits to a ticket "+
+				"pool size of %d, which is not possible after "+
+				"a pool size of %d", poolSize, prevPoolSize)
+			return ruleError(ErrPoolSize, str)
+		}
 	}
 
 	// The height of this block is one more than the referenced previous
 	// block.
 	blockHeight := prevNode.height + 1
+	if int64(header.Height) != blockHeight {
+		str := fmt.Sprintf("block header height invalid; expected %v "+
+			"but %v was found", blockHeight, header.Height)
+		return ruleError(ErrBadBlockHeight, str)
+	}
 
 	// Ensure chain matches up to predetermined checkpoints.
 	blockHash := header.BlockHash()
@@ -776,20 +847,6 @@ func (b *BlockChain) checkBlockHeaderContext(header *wire.BlockHeader, prevNode
 			str = fmt.Sprintf(str, header.Version)
 			return ruleError(ErrBlockVersionTooOld, str)
 		}
-
-		// Enforce the stake version in the header once a majority of
-		// the network has upgraded to version 3 blocks.
-		if header.Version >= 3 && b.isMajorityVersion(3, prevNode,
-			b.chainParams.BlockEnforceNumRequired) {
-
-			expectedStakeVer := b.calcStakeVersion(prevNode)
-			if header.StakeVersion != expectedStakeVer {
-				str := fmt.Sprintf("block stake version of %d "+
-					"is not the expected version of %d",
-					header.StakeVersion, expectedStakeVer)
-				return ruleError(ErrBadStakeVersion, str)
-			}
-		}
 	}
 
 	return nil
@@ -2392,17 +2449,15 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *hcutil.Block, utx
 		return err
 	}
 
-	// Don't run scripts if this node is before the latest known good
-	// checkpoint since the validity is verified via the checkpoints (all
-	// transactions are included in the merkle root hash and any changes
-	// will therefore be detected by the next checkpoint).  This is a huge
-	// optimization because running the scripts is the most time consuming
-	// portion of block handling.
-	checkpoint := b.latestCheckpoint()
-	runScripts := !b.noVerify
-	if checkpoint != nil && node.height <= checkpoint.Height {
-		runScripts = false
-	}
+	// Don't run scripts if this node is an ancestor of the block assumed to
+	// be valid and is buried deep enough since the validity of its scripts
+	// is implied by the block assumed to be valid (all transactions are
+	// included in the merkle root hash, so any changes would produce a
+	// different block).  This is a huge optimization because running the
+	// scripts is the most time consuming portion of block handling.  All
+	// other rules, such as the amounts, the stake rules, and the spending
+	// of the utxos, are still enforced.
+	runScripts := !b.noVerify && !b.isAssumedValid(node)
 	var scriptFlags txscript.ScriptFlags
 	if runScripts {
 		var err error
@@ -2501,7 +2556,7 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *hcutil.Block, utx
 
 	if runScripts {
 		err = checkBlockScripts(block, utxoView, false, scriptFlags,
-			b.sigCache)
+			b.sigCache, b.batchSigVerify)
 		if err != nil {
 			log.Tracef("checkBlockScripts failed; error returned "+
 				"on txtreestake of cur block: %v", err)
@@ -2552,7 +2607,7 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *hcutil.Block, utx
 
 	if runScripts {
 		err = checkBlockScripts(block, utxoView, true,
-			scriptFlags, b.sigCache)
+			scriptFlags, b.sigCache, b.batchSigVerify)
 		if err != nil {
 			log.Tracef("checkBlockScripts failed; error returned "+
 				"on txtreeregular of cur block: %v", err)
@@ -2613,6 +2668,7 @@ func (b *BlockChain) CheckConnectBlock(block *hcutil.Block) error {
 		voteBitsInBlock(block))
 	newNode.parent = prevNode
 	newNode.workSum.Add(prevNode.workSum, newNode.workSum)
+	newNode.buildSkipList()
 	if prevNode != nil {
 		newNode.parent = prevNode
 		newNode.workSum.Add(prevNode.workSum, newNode.workSum)
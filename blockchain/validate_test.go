@@ -183,7 +183,7 @@ func TestBlockValidationRules(t *testing.T) {
 			t.Errorf("NewBlockFromBytes error: %v", err.Error())
 		}
 
-		_, _, err = chain.ProcessBlock(bl, blockchain.BFNone)
+		_, err = chain.ProcessBlock(bl, blockchain.BFNone)
 		if err != nil {
 			t.Fatalf("ProcessBlock error at height %v: %v", i, err.Error())
 		}
@@ -257,7 +257,7 @@ func TestBlockValidationRules(t *testing.T) {
 			t.Errorf("NewBlockFromBytes error: %v", err.Error())
 		}
 
-		_, _, err = chain.ProcessBlock(bl, blockchain.BFNone)
+		_, err = chain.ProcessBlock(bl, blockchain.BFNone)
 		if err != nil {
 			t.Errorf("ProcessBlock error at height %v: %v", i,
 				err.Error())
@@ -326,7 +326,7 @@ func TestBlockValidationRules(t *testing.T) {
 	badDifficulty153.Header.Bits = 0x207ffffe
 	b153test = hcutil.NewBlock(badDifficulty153)
 
-	_, _, err = chain.ProcessBlock(b153test, blockchain.BFNone)
+	_, err = chain.ProcessBlock(b153test, blockchain.BFNone)
 	if err == nil || err.(blockchain.RuleError).ErrorCode !=
 		blockchain.ErrUnexpectedDifficulty {
 		t.Errorf("Failed to get error or correct error for "+
@@ -340,7 +340,7 @@ func TestBlockValidationRules(t *testing.T) {
 	badBlockSize153.Header.Size = 0x20ffff71
 	b153test = hcutil.NewBlock(badBlockSize153)
 
-	_, _, err = chain.ProcessBlock(b153test, blockchain.BFNoPoWCheck)
+	_, err = chain.ProcessBlock(b153test, blockchain.BFNoPoWCheck)
 	if err == nil || err.(blockchain.RuleError).ErrorCode !=
 		blockchain.ErrWrongBlockSize {
 		t.Errorf("Failed to get error or correct error for "+
@@ -354,7 +354,7 @@ func TestBlockValidationRules(t *testing.T) {
 	badHash153.Header.Size = 0x20ffff70
 	b153test = hcutil.NewBlock(badHash153)
 
-	_, _, err = chain.ProcessBlock(b153test, blockchain.BFNone)
+	_, err = chain.ProcessBlock(b153test, blockchain.BFNone)
 	if err == nil || err.(blockchain.RuleError).ErrorCode !=
 		blockchain.ErrHighHash {
 		t.Errorf("Failed to get error or correct error for "+
@@ -719,7 +719,7 @@ func TestBlockValidationRules(t *testing.T) {
 	block153MsgBlock := new(wire.MsgBlock)
 	block153MsgBlock.FromBytes(block153Bytes)
 	b153test = hcutil.NewBlock(block153MsgBlock)
-	_, _, err = chain.ProcessBlock(b153test, blockchain.BFNone)
+	_, err = chain.ProcessBlock(b153test, blockchain.BFNone)
 	if err != nil {
 		t.Errorf("Got unexpected error processing block 153 %v", err)
 	}
@@ -1374,7 +1374,7 @@ func TestBlockValidationRules(t *testing.T) {
 	b154test = hcutil.NewBlock(badBlockHeight154)
 
 	// Throws ProcessBlock error through checkBlockContext.
-	_, _, err = chain.ProcessBlock(b154test, blockchain.BFNoPoWCheck)
+	_, err = chain.ProcessBlock(b154test, blockchain.BFNoPoWCheck)
 	if err == nil || err.(blockchain.RuleError).ErrorCode !=
 		blockchain.ErrBadBlockHeight {
 		t.Errorf("ProcessBlock ErrBadBlockHeight test no or unexpected "+
@@ -1721,7 +1721,7 @@ func TestBlockValidationRules(t *testing.T) {
 			}
 		}
 
-		_, _, err = chain.ProcessBlock(bl, blockchain.BFNone)
+		_, err = chain.ProcessBlock(bl, blockchain.BFNone)
 		if err != nil {
 			t.Errorf("ProcessBlock error: %v", err.Error())
 		}
@@ -1949,7 +1949,7 @@ func TestBlockchainSpendJournal(t *testing.T) {
 			t.Fatalf("NewBlockFromBytes error: %v", err.Error())
 		}
 
-		_, _, err = chain.ProcessBlock(bl, blockchain.BFNone)
+		_, err = chain.ProcessBlock(bl, blockchain.BFNone)
 		if err != nil {
 			t.Fatalf("ProcessBlock error at height %v: %v", i, err.Error())
 		}
@@ -0,0 +1,216 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/coolsnady/hcd/blockchain/internal/dbnamespace"
+	"github.com/coolsnady/hcd/blockchain/stake"
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
+)
+
+// verifyLogInterval is the minimum amount of time between progress messages
+// logged while verifying the chain state.
+const verifyLogInterval = 10 * time.Second
+
+// errInterruptRequested is returned when the verification of the chain state
+// is interrupted.
+var errInterruptRequested = errors.New("interrupt requested")
+
+// ChainStateIssue describes an inconsistency between the spend journal, the
+// utxo set, and the ticket database which was found by VerifyChainState.
+type ChainStateIssue struct {
+	// Height and Hash identify the block whose data is inconsistent.
+	Height int64
+	Hash   chainhash.Hash
+
+	// Description explains the inconsistency.
+	Description string
+}
+
+// String returns a human-readable description of the issue.
+func (i ChainStateIssue) String() string {
+	return fmt.Sprintf("block %v (height %d): %s", i.Hash, i.Height,
+		i.Description)
+}
+
+// spentOutPoints returns the outpoints spent by the passed transactions in the
+// same order as the entries of their spend journal entry.
+func spentOutPoints(txns []*wire.MsgTx) []wire.OutPoint {
+	var outPoints []wire.OutPoint
+	for _, tx := range txns {
+		isVote := stake.DetermineTxType(tx) == stake.TxTypeSSGen
+		for txInIdx, txIn := range tx.TxIn {
+			// Skip stakebase.
+			if txInIdx == 0 && isVote {
+				continue
+			}
+			outPoints = append(outPoints, txIn.PreviousOutPoint)
+		}
+	}
+	return outPoints
+}
+
+// VerifyChainState checks the chain state stored in the passed database for
+// consistency without modifying it.  For each block of the main chain,
+// starting with the best block and going back at most depth blocks (or all of
+// them when depth is zero), it ensures that:
+//
+//   - The block is indexed by its height and its spend journal entry exists
+//     and matches the transactions it spends
+//   - None of the outputs spent by the block are unspent in the utxo set
+//   - None of the tickets spent by votes and revocations of the block are
+//     still live according to the ticket database
+//
+// It also ensures that every live ticket has an unspent ticket output in the
+// utxo set.
+//
+// The returned slice contains all inconsistencies which were found.  An error
+// is only returned when the verification could not be performed, such as when
+// the chain state has not been initialized or the passed interrupt channel is
+// closed.
+func VerifyChainState(db database.DB, params *chaincfg.Params, depth int64, interrupt <-chan struct{}) ([]ChainStateIssue, error) {
+	var issues []ChainStateIssue
+	err := db.View(func(dbTx database.Tx) error {
+		serializedData := dbTx.Metadata().Get(dbnamespace.ChainStateKeyName)
+		if serializedData == nil {
+			return errors.New("the chain state has not been initialized")
+		}
+		state, err := deserializeBestChainState(serializedData)
+		if err != nil {
+			return err
+		}
+		tipHeight := int64(state.height)
+		addIssue := func(height int64, hash *chainhash.Hash, format string, args ...interface{}) {
+			issues = append(issues, ChainStateIssue{
+				Height:      height,
+				Hash:        *hash,
+				Description: fmt.Sprintf(format, args...),
+			})
+		}
+
+		block, err := dbFetchBlockByHash(dbTx, &state.hash)
+		if err != nil {
+			return fmt.Errorf("unable to load best block %v: %v",
+				state.hash, err)
+		}
+
+		// Ensure all live tickets have an unspent ticket output in the
+		// utxo set.  The stake node is used to check the tickets spent
+		// by each block below, so skip those checks when the ticket
+		// database can't be loaded.
+		stakeNode, err := stake.LoadBestNode(dbTx, state.height,
+			state.hash, block.MsgBlock().Header, params)
+		if err != nil {
+			addIssue(tipHeight, &state.hash, "unable to load ticket "+
+				"database: %v", err)
+		} else {
+			for _, ticket := range stakeNode.LiveTickets() {
+				entry, err := dbFetchUtxoEntry(dbTx, &ticket)
+				switch {
+				case err != nil:
+					addIssue(tipHeight, &state.hash, "unable to "+
+						"load utxo entry for live ticket %v: %v",
+						ticket, err)
+				case entry == nil || entry.IsOutputSpent(0):
+					addIssue(tipHeight, &state.hash, "live ticket "+
+						"%v is not in the utxo set", ticket)
+				case entry.TransactionType() != stake.TxTypeSStx:
+					addIssue(tipHeight, &state.hash, "live ticket "+
+						"%v is a %v transaction in the utxo set",
+						ticket, entry.TransactionType())
+				}
+			}
+		}
+
+		stopHeight := int64(1)
+		if depth > 0 && tipHeight-depth+1 > stopHeight {
+			stopHeight = tipHeight - depth + 1
+		}
+		var lastLog time.Time
+		for height := tipHeight; height >= stopHeight; height-- {
+			select {
+			case <-interrupt:
+				return errInterruptRequested
+			default:
+			}
+			if time.Since(lastLog) >= verifyLogInterval {
+				log.Infof("Verifying chain state at height %d", height)
+				lastLog = time.Now()
+			}
+
+			hash := block.Hash()
+			indexedHash, err := dbFetchHashByHeight(dbTx, height)
+			if err != nil || *indexedHash != *hash {
+				addIssue(height, hash, "block is not indexed by "+
+					"its height")
+			}
+
+			// Fetch the parent which is required to determine the
+			// spent outputs.  There is no way to continue when it
+			// is missing from the main chain.
+			parentHash := &block.MsgBlock().Header.PrevBlock
+			parent, err := dbFetchBlockByHash(dbTx, parentHash)
+			if err != nil {
+				addIssue(height, hash, "unable to load parent %v: %v",
+					parentHash, err)
+				break
+			}
+
+			// The block spends the outputs of the regular
+			// transactions of its parent when it approves them as
+			// well as those of its own stake transactions.
+			var txns []*wire.MsgTx
+			if hcutil.IsFlagSet16(block.MsgBlock().Header.VoteBits,
+				hcutil.BlockValid) {
+
+				txns = append(txns, parent.MsgBlock().Transactions[1:]...)
+			}
+			txns = append(txns, block.MsgBlock().STransactions...)
+			_, err = dbFetchSpendJournalEntry(dbTx, block, parent)
+			if err != nil {
+				addIssue(height, hash, "invalid spend journal "+
+					"entry: %v", err)
+			}
+			for _, outPoint := range spentOutPoints(txns) {
+				entry, err := dbFetchUtxoEntry(dbTx, &outPoint.Hash)
+				if err != nil {
+					addIssue(height, hash, "unable to load utxo "+
+						"entry for %v: %v", outPoint.Hash, err)
+					continue
+				}
+				if entry != nil && !entry.IsOutputSpent(outPoint.Index) {
+					addIssue(height, hash, "spent output %v is "+
+						"unspent in the utxo set", outPoint)
+				}
+			}
+
+			if stakeNode != nil {
+				spentTickets := append(ticketsSpentInBlock(block),
+					ticketsRevokedInBlock(block)...)
+				for _, ticket := range spentTickets {
+					if stakeNode.ExistsLiveTicket(ticket) {
+						addIssue(height, hash, "spent ticket "+
+							"%v is still live", ticket)
+					}
+				}
+			}
+
+			block = parent
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/txscript"
+	"github.com/coolsnady/hcutil"
+)
+
+// TestVerifyChainState ensures VerifyChainState does not report any issues for
+// a consistent chain state and detects a missing spend journal entry as well
+// as a live ticket which is missing from the utxo set.
+func TestVerifyChainState(t *testing.T) {
+	// Update simnet parameters to reflect what is expected by the legacy
+	// data.
+	params := cloneParams(&chaincfg.SimNetParams)
+	params.GenesisBlock.Header.MerkleRoot = *mustParseHash("a216ea043f0d481a072424af646787794c32bcefd3ed181a090319bbf8a37105")
+	genesisHash := params.GenesisBlock.BlockHash()
+	params.GenesisHash = &genesisHash
+
+	// Create a new database and chain instance to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "verifychainstatetest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(testDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: params,
+		TimeSource:  blockchain.NewMedianTime(),
+		SigCache:    txscript.NewSigCache(1000),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create chain instance: %v", err)
+	}
+
+	// Load and connect the test blocks.
+	fi, err := os.Open(filepath.Join("testdata", "blocks0to168.bz2"))
+	if err != nil {
+		t.Fatalf("Unable to open test data: %v", err)
+	}
+	defer fi.Close()
+	bcBuf := new(bytes.Buffer)
+	bcBuf.ReadFrom(bzip2.NewReader(fi))
+	blockChain := make(map[int64][]byte)
+	if err := gob.NewDecoder(bcBuf).Decode(&blockChain); err != nil {
+		t.Fatalf("Error decoding test blockchain: %v", err)
+	}
+	for i := int64(1); i <= 168; i++ {
+		bl, err := hcutil.NewBlockFromBytes(blockChain[i])
+		if err != nil {
+			t.Fatalf("NewBlockFromBytes error: %v", err)
+		}
+		_, err = chain.ProcessBlock(bl, blockchain.BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock error at height %d: %v", i, err)
+		}
+	}
+
+	// Ensure the consistent chain state has no issues.
+	issues, err := blockchain.VerifyChainState(db, params, 0, nil)
+	if err != nil {
+		t.Fatalf("VerifyChainState: unexpected error: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("VerifyChainState: unexpected issues: %v", issues)
+	}
+
+	// Remove the spend journal entry of the best block and the utxo entry
+	// of a live ticket.
+	best := chain.BestSnapshot()
+	liveTickets, err := chain.LiveTickets()
+	if err != nil || len(liveTickets) == 0 {
+		t.Fatalf("LiveTickets: unexpected result: %v", err)
+	}
+	err = db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		err := meta.Bucket([]byte("spendjournal")).Delete(best.Hash[:])
+		if err != nil {
+			return err
+		}
+		return meta.Bucket([]byte("utxoset")).Delete(liveTickets[0][:])
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	// Ensure both issues are detected when only verifying the best block.
+	issues, err = blockchain.VerifyChainState(db, params, 1, nil)
+	if err != nil {
+		t.Fatalf("VerifyChainState: unexpected error: %v", err)
+	}
+	wantIssues := []string{"is not in the utxo set", "invalid spend journal"}
+	if len(issues) != len(wantIssues) {
+		t.Fatalf("VerifyChainState: unexpected issues %v, want %d",
+			issues, len(wantIssues))
+	}
+	for i, want := range wantIssues {
+		if issues[i].Hash != *best.Hash || issues[i].Height != best.Height ||
+			!strings.Contains(issues[i].Description, want) {
+
+			t.Errorf("VerifyChainState: unexpected issue %v, want %q",
+				issues[i], want)
+		}
+	}
+}
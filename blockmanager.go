@@ -19,7 +19,6 @@ import (
 
 	"github.com/coolsnady/hcd/blockchain"
 	"github.com/coolsnady/hcd/blockchain/stake"
-	"github.com/coolsnady/hcd/chaincfg"
 	"github.com/coolsnady/hcd/chaincfg/chainhash"
 	"github.com/coolsnady/hcd/database"
 	"github.com/coolsnady/hcd/mempool"
@@ -29,8 +28,8 @@ import (
 
 const (
 	// minInFlightBlocks is the minimum number of blocks that should be
-	// in the request queue for headers-first mode before requesting
-	// more.
+	// in the request queue of the peer the blocks of the best header chain
+	// are downloaded from before requesting more.
 	minInFlightBlocks = 10
 
 	// blockDbNamePrefix is the prefix for the block database name.  The
@@ -54,6 +53,11 @@ const (
 	// hashes to store in memory.
 	maxRequestedTxns = wire.MaxInvPerMsg
 
+	// maxUnconnectingHeaders is the number of headers messages which do
+	// not connect to a known header a peer may send before its ban score
+	// is increased.  The count is reset once a headers message connects.
+	maxUnconnectingHeaders = 10
+
 	// maxLotteryDataBlockDelta is maximum number of blocks from the current
 	// best block to cut off block lottery calculation data for.  Below
 	// bestBlockHeight-maxLotteryDataBlockDelta, block lottery data will
@@ -208,6 +212,33 @@ type forceReorganizationMsg struct {
 	reply      chan forceReorganizationResponse
 }
 
+// invalidateBlockResponse is a response sent to the reply channel of an
+// invalidateBlockMsg query.
+type invalidateBlockResponse struct {
+	err error
+}
+
+// invalidateBlockMsg is a message type to be sent across the message channel
+// for requesting that a block and its descendants be marked invalid.
+type invalidateBlockMsg struct {
+	hash  chainhash.Hash
+	reply chan invalidateBlockResponse
+}
+
+// reconsiderBlockResponse is a response sent to the reply channel of a
+// reconsiderBlockMsg query.
+type reconsiderBlockResponse struct {
+	err error
+}
+
+// reconsiderBlockMsg is a message type to be sent across the message channel
+// for requesting that the invalid marks of a block and its ancestors and
+// descendants be removed.
+type reconsiderBlockMsg struct {
+	hash  chainhash.Hash
+	reply chan reconsiderBlockResponse
+}
+
 // getTopBlockResponse is a response to the request for the block at HEAD of the
 // blockchain. We need to be able to obtain this from blockChain for mining
 // purposes.
@@ -226,7 +257,6 @@ type getTopBlockMsg struct {
 // processBlockMsg.
 type processBlockResponse struct {
 	onMainChain bool
-	isOrphan    bool
 	err         error
 }
 
@@ -321,13 +351,6 @@ type setParentTemplateMsg struct {
 type setParentTemplateResponse struct {
 }
 
-// headerNode is used as a node in a list of headers that are linked together
-// between checkpoints.
-type headerNode struct {
-	height int64
-	hash   *chainhash.Hash
-}
-
 // chainState tracks the state of the best chain as blocks are inserted.  This
 // is done because blockchain is currently not safe for concurrent access and the
 // block manager is typically quite busy processing block and inventory.
@@ -427,12 +450,6 @@ type blockManager struct {
 	wg                  sync.WaitGroup
 	quit                chan struct{}
 
-	// The following fields are used for headers-first mode.
-	headersFirstMode bool
-	headerList       *list.List
-	startHeader      *list.Element
-	nextCheckpoint   *chaincfg.Checkpoint
-
 	// lotteryDataBroadcastMutex is a mutex protecting the map
 	// that checks if block lottery data has been broadcasted
 	// yet for any given block, so notifications are never
@@ -445,22 +462,6 @@ type blockManager struct {
 	AggressiveMining      bool
 }
 
-// resetHeaderState sets the headers-first mode state to values appropriate for
-// syncing from a new peer.
-func (b *blockManager) resetHeaderState(newestHash *chainhash.Hash, newestHeight int64) {
-	b.headersFirstMode = false
-	b.headerList.Init()
-	b.startHeader = nil
-
-	// When there is a next checkpoint, add an entry for the latest known
-	// block into the header pool.  This allows the next downloaded header
-	// to prove it links to the chain properly.
-	if b.nextCheckpoint != nil {
-		node := headerNode{height: newestHeight, hash: newestHash}
-		b.headerList.PushBack(&node)
-	}
-}
-
 // updateChainState updates the chain state associated with the block manager.
 // This allows fast access to chain information since blockchain is currently not
 // safe for concurrent access and the block manager is typically quite busy
@@ -484,37 +485,60 @@ func (b *blockManager) updateChainState(newestHash *chainhash.Hash,
 	b.chainState.curPrevHash = curPrevHash
 }
 
-// findNextHeaderCheckpoint returns the next checkpoint after the passed height.
-// It returns nil when there is not one either because the height is already
-// later than the final checkpoint or some other reason such as disabled
-// checkpoints.
-func (b *blockManager) findNextHeaderCheckpoint(height int64) *chaincfg.Checkpoint {
-	// There is no next checkpoint if checkpoints are disabled or there are
-	// none for this current network.
-	if cfg.DisableCheckpoints {
-		return nil
-	}
-	checkpoints := b.server.chainParams.Checkpoints
-	if len(checkpoints) == 0 {
-		return nil
-	}
+// refreshChainState updates the chain state associated with the block manager,
+// the stake difficulty of registered websocket clients, and the stake
+// transactions in the mempool after the best chain was changed by a request
+// rather than by processing a block, such as a forced reorganization.
+func (b *blockManager) refreshChainState() {
+	// Query the db for the latest best block since
+	// the block that was processed could be on a
+	// side chain or have caused a reorg.
+	best := b.chain.BestSnapshot()
 
-	// There is no next checkpoint if the height is already after the final
-	// checkpoint.
-	finalCheckpoint := &checkpoints[len(checkpoints)-1]
-	if height >= finalCheckpoint.Height {
-		return nil
-	}
+	// Fetch the required lottery data.
+	winningTickets, poolSize, finalState, err :=
+		b.chain.LotteryDataForBlock(best.Hash)
 
-	// Find the next checkpoint.
-	nextCheckpoint := finalCheckpoint
-	for i := len(checkpoints) - 2; i >= 0; i-- {
-		if height >= checkpoints[i].Height {
-			break
-		}
-		nextCheckpoint = &checkpoints[i]
+	// Update registered websocket clients on the
+	// current stake difficulty.
+	nextStakeDiff, errSDiff :=
+		b.chain.CalcNextRequiredStakeDifficulty()
+	if err != nil {
+		bmgrLog.Warnf("Failed to get next stake difficulty "+
+			"calculation: %v", err)
+	}
+	r := b.server.rpcServer
+	if r != nil && errSDiff == nil {
+		r.ntfnMgr.NotifyStakeDifficulty(
+			&StakeDifficultyNtfnData{
+				*best.Hash,
+				best.Height,
+				nextStakeDiff,
+			})
+		b.server.txMemPool.PruneStakeTx(nextStakeDiff,
+			best.Height)
+		b.server.txMemPool.PruneExpiredTx(best.Height)
+	}
+
+	missedTickets, err := b.chain.MissedTickets()
+	if err != nil {
+		bmgrLog.Warnf("Failed to get missed tickets"+
+			": %v", err)
 	}
-	return nextCheckpoint
+
+	// The blockchain should be updated, so fetch the
+	// latest snapshot.
+	best = b.chain.BestSnapshot()
+	curPrevHash := b.chain.BestPrevHash()
+
+	b.updateChainState(best.Hash,
+		best.Height,
+		finalState,
+		uint32(poolSize),
+		nextStakeDiff,
+		winningTickets,
+		missedTickets,
+		curPrevHash)
 }
 
 // startSync will choose the best peer among the available candidate peers to
@@ -560,54 +584,28 @@ func (b *blockManager) startSync(peers *list.List) {
 		// to send.
 		b.requestedBlocks = make(map[chainhash.Hash]struct{})
 
-		locator, err := b.chain.LatestBlockLocator()
+		// Request the headers which build on the best known header.
+		// The entire header chain is validated and stored before the
+		// blocks of the header chain with the most cumulative work are
+		// downloaded.  This ensures no blocks of a chain which turns out
+		// to be invalid, or to have less work, are downloaded, and
+		// allows downloading the blocks without having to deal with
+		// blocks which build on unknown blocks.
+		locator, err := b.chain.LatestHeaderLocator()
 		if err != nil {
 			bmgrLog.Errorf("Failed to get block locator for the "+
-				"latest block: %v", err)
+				"best header: %v", err)
 			return
 		}
 
 		bmgrLog.Infof("Syncing to block height %d from peer %v",
 			bestPeer.LastBlock(), bestPeer.Addr())
 
-		// When the current height is less than a known checkpoint we
-		// can use block headers to learn about which blocks comprise
-		// the chain up to the checkpoint and perform less validation
-		// for them.  This is possible since each header contains the
-		// hash of the previous header and a merkle root.  Therefore if
-		// we validate all of the received headers link together
-		// properly and the checkpoint hashes match, we can be sure the
-		// hashes for the blocks in between are accurate.  Further, once
-		// the full blocks are downloaded, the merkle root is computed
-		// and compared against the value in the header which proves the
-		// full block hasn't been tampered with.
-		//
-		// Once we have passed the final checkpoint, or checkpoints are
-		// disabled, use standard inv messages learn about the blocks
-		// and fully validate them.  Finally, regression test mode does
-		// not support the headers-first approach so do normal block
-		// downloads when in regression test mode.
-		if b.nextCheckpoint != nil &&
-			best.Height < b.nextCheckpoint.Height &&
-			!cfg.DisableCheckpoints {
-
-			err := bestPeer.PushGetHeadersMsg(locator, b.nextCheckpoint.Hash)
-			if err != nil {
-				bmgrLog.Errorf("Failed to push getheadermsg for the "+
-					"latest blocks: %v", err)
-				return
-			}
-			b.headersFirstMode = true
-			bmgrLog.Infof("Downloading headers for blocks %d to "+
-				"%d from peer %s", best.Height+1,
-				b.nextCheckpoint.Height, bestPeer.Addr())
-		} else {
-			err := bestPeer.PushGetBlocksMsg(locator, &zeroHash)
-			if err != nil {
-				bmgrLog.Errorf("Failed to push getblocksmsg for the "+
-					"latest blocks: %v", err)
-				return
-			}
+		err = bestPeer.PushGetHeadersMsg(locator, &zeroHash)
+		if err != nil {
+			bmgrLog.Errorf("Failed to push getheadermsg for the "+
+				"best header: %v", err)
+			return
 		}
 		b.syncPeer = bestPeer
 	} else {
@@ -699,14 +697,10 @@ func (b *blockManager) handleDonePeerMsg(peers *list.List, sp *serverPeer) {
 	}
 
 	// Attempt to find a new peer to sync from if the quitting peer is the
-	// sync peer.  Also, reset the headers-first state if in headers-first
-	// mode so
+	// sync peer.  The headers which were already received are kept in the
+	// block index, so syncing continues from the best known header.
 	if b.syncPeer != nil && b.syncPeer == sp {
 		b.syncPeer = nil
-		if b.headersFirstMode {
-			best := b.chain.BestSnapshot()
-			b.resetHeaderState(best.Hash, best.Height)
-		}
 		b.startSync(peers)
 	}
 }
@@ -1002,41 +996,31 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 		}
 	}
 
-	// When in headers-first mode, if the block matches the hash of the
-	// first header in the list of headers that are being fetched, it's
-	// eligible for less validation since the headers have already been
-	// verified to link together and are valid up to the next checkpoint.
-	// Also, remove the list entry for all blocks except the checkpoint
-	// since it is needed to verify the next round of headers links
-	// properly.
-	isCheckpointBlock := false
-	behaviorFlags := blockchain.BFNone
-	if b.headersFirstMode {
-		firstNodeEl := b.headerList.Front()
-		if firstNodeEl != nil {
-			firstNode := firstNodeEl.Value.(*headerNode)
-			if blockHash.IsEqual(firstNode.hash) {
-				behaviorFlags |= blockchain.BFFastAdd
-				if firstNode.hash.IsEqual(b.nextCheckpoint.Hash) {
-					isCheckpointBlock = true
-				} else {
-					b.headerList.Remove(firstNodeEl)
-				}
-			}
-		}
-	}
-
 	// Remove block from request maps. Either chain will know about it and
 	// so we shouldn't have any more instances of trying to fetch it, or we
 	// will fail the insert and thus we'll retry next time we get an inv.
 	delete(bmsg.peer.requestedBlocks, *blockHash)
 	delete(b.requestedBlocks, *blockHash)
 
-	// Process the block to include validation, best chain selection, orphan
-	// handling, etc.
-	onMainChain, isOrphan, err := b.chain.ProcessBlock(bmsg.block,
-		behaviorFlags)
+	// Process the block to include validation, best chain selection, etc.
+	// Note that the scripts of the ancestors of the block assumed to be
+	// valid are not validated.
+	onMainChain, err := b.chain.ProcessBlock(bmsg.block, blockchain.BFNone)
 	if err != nil {
+		// Blocks which build on a block that is not available are not
+		// retained.  Request the headers which link the block to the
+		// best known header from the peer, so the blocks of the chain
+		// are downloaded in order.
+		if rerr, ok := err.(blockchain.RuleError); ok &&
+			rerr.ErrorCode == blockchain.ErrMissingParent {
+
+			bmgrLog.Debugf("Block %v from %s builds on a block which "+
+				"is not available -- requesting headers", blockHash,
+				bmsg.peer)
+			b.requestHeaders(bmsg.peer)
+			return
+		}
+
 		// When the error is a rule error, it means the block was simply
 		// rejected as opposed to something actually going wrong, so log
 		// it as such.  Otherwise, something really did go wrong, so log
@@ -1066,383 +1050,337 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 	// other peers based on their last announced block hash. This allows us
 	// to dynamically update the block heights of peers, avoiding stale
 	// heights when looking for a new sync peer. Upon acceptance of a block
-	// or recognition of an orphan, we also use this information to update
-	// the block heights over other peers who's invs may have been ignored
-	// if we are actively syncing while the chain is not yet current or
-	// who may have lost the lock announcment race.
+	// we also use this information to update the block heights over other
+	// peers who's invs may have been ignored if we are actively syncing
+	// while the chain is not yet current or who may have lost the lock
+	// announcment race.
 	var heightUpdate int64
 	var blkHashUpdate *chainhash.Hash
 
-	// Request the parents for the orphan block from the peer that sent it.
-	if isOrphan {
-		// We've just received an orphan block from a peer. In order
-		// to update the height of the peer, we try to extract the
-		// block height from the scriptSig of the coinbase transaction.
-		// Extraction is only attempted if the block's version is
-		// high enough (ver 2+).
-		header := &bmsg.block.MsgBlock().Header
-		cbHeight := header.Height
-		heightUpdate = int64(cbHeight)
-		blkHashUpdate = blockHash
-
-		orphanRoot := b.chain.GetOrphanRoot(blockHash)
-		locator, err := b.chain.LatestBlockLocator()
-		if err != nil {
-			bmgrLog.Warnf("Failed to get block locator for the "+
-				"latest block: %v", err)
-		} else {
-			err = bmsg.peer.PushGetBlocksMsg(locator, orphanRoot)
-			if err != nil {
-				bmgrLog.Warnf("Failed to push getblocksmsg for the "+
-					"latest block: %v", err)
-			}
+	// Log information about the block and update the chain state.
+	b.progressLogger.logBlockHeight(bmsg.block)
+	r := b.server.rpcServer
+
+	// Determine if this block is recent enough that we need to calculate
+	// block lottery data for it.
+	_, bestHeight := b.chainState.Best()
+	blockHeight := int64(bmsg.block.MsgBlock().Header.Height)
+	tooOldForLotteryData := blockHeight <=
+		(bestHeight - maxLotteryDataBlockDelta)
+	if !tooOldForLotteryData {
+		// Query the DB for the winning SStx for the next top block if we've
+		// reached stake validation height.  Broadcast them if this is the
+		// first time determining them and we're synced to the latest
+		// checkpoint.
+		winningTickets, _, _, err :=
+			b.chain.LotteryDataForBlock(blockHash)
+		if err != nil && int64(bmsg.block.MsgBlock().Header.Height) >=
+			b.server.chainParams.StakeValidationHeight-1 {
+			bmgrLog.Errorf("Failed to get next winning tickets: %v", err)
+
+			code, reason := mempool.ErrToRejectErr(err)
+			bmsg.peer.PushRejectMsg(wire.CmdBlock, code, reason,
+				blockHash, false)
+			return
 		}
-	} else {
-		// When the block is not an orphan, log information about it and
-		// update the chain state.
-		b.progressLogger.logBlockHeight(bmsg.block)
-		r := b.server.rpcServer
 
-		// Determine if this block is recent enough that we need to calculate
-		// block lottery data for it.
-		_, bestHeight := b.chainState.Best()
-		blockHeight := int64(bmsg.block.MsgBlock().Header.Height)
-		tooOldForLotteryData := blockHeight <=
-			(bestHeight - maxLotteryDataBlockDelta)
-		if !tooOldForLotteryData {
-			// Query the DB for the winning SStx for the next top block if we've
-			// reached stake validation height.  Broadcast them if this is the
-			// first time determining them and we're synced to the latest
-			// checkpoint.
-			winningTickets, _, _, err :=
-				b.chain.LotteryDataForBlock(blockHash)
-			if err != nil && int64(bmsg.block.MsgBlock().Header.Height) >=
-				b.server.chainParams.StakeValidationHeight-1 {
-				bmgrLog.Errorf("Failed to get next winning tickets: %v", err)
-
-				code, reason := mempool.ErrToRejectErr(err)
-				bmsg.peer.PushRejectMsg(wire.CmdBlock, code, reason,
-					blockHash, false)
-				return
-			}
+		// Push winning tickets notifications if we need to.
+		winningTicketsNtfn := &WinningTicketsNtfnData{
+			BlockHash:   *blockHash,
+			BlockHeight: int64(bmsg.block.MsgBlock().Header.Height),
+			Tickets:     winningTickets}
+		b.lotteryDataBroadcastMutex.Lock()
+		_, beenNotified := b.lotteryDataBroadcast[*blockHash]
+		b.lotteryDataBroadcastMutex.Unlock()
+		if !beenNotified && r != nil &&
+			int64(bmsg.block.MsgBlock().Header.Height) >
+				b.server.chainParams.LatestCheckpointHeight() {
+			r.ntfnMgr.NotifyWinningTickets(winningTicketsNtfn)
 
-			// Push winning tickets notifications if we need to.
-			winningTicketsNtfn := &WinningTicketsNtfnData{
-				BlockHash:   *blockHash,
-				BlockHeight: int64(bmsg.block.MsgBlock().Header.Height),
-				Tickets:     winningTickets}
 			b.lotteryDataBroadcastMutex.Lock()
-			_, beenNotified := b.lotteryDataBroadcast[*blockHash]
+			b.lotteryDataBroadcast[*blockHash] = struct{}{}
 			b.lotteryDataBroadcastMutex.Unlock()
-			if !beenNotified && r != nil &&
-				int64(bmsg.block.MsgBlock().Header.Height) >
-					b.server.chainParams.LatestCheckpointHeight() {
-				r.ntfnMgr.NotifyWinningTickets(winningTicketsNtfn)
-
-				b.lotteryDataBroadcastMutex.Lock()
-				b.lotteryDataBroadcast[*blockHash] = struct{}{}
-				b.lotteryDataBroadcastMutex.Unlock()
-			}
 		}
+	}
 
-		if onMainChain {
-			// A new block is connected, however, this new block may have
-			// votes in it that were hidden from the network and which
-			// validate our parent block. We should bolt these new votes
-			// into the tx tree stake of the old block template on parent.
-			svl := b.server.chainParams.StakeValidationHeight
-			if b.AggressiveMining && bmsg.block.Height() >= svl {
-				b.checkBlockForHiddenVotes(bmsg.block)
-			}
-
-			// Query the db for the latest best block since the block
-			// that was processed could be on a side chain or have caused
-			// a reorg.
-			best := b.chain.BestSnapshot()
-
-			// Query the DB for the missed tickets for the next top block.
-			missedTickets, err := b.chain.MissedTickets()
-			if err != nil {
-				bmgrLog.Warnf("Failed to get missed tickets "+
-					"for best block %v: %v", best.Hash, err)
-			}
-
-			// Retrieve the current previous block hash.
-			curPrevHash := b.chain.BestPrevHash()
+	if onMainChain {
+		// A new block is connected, however, this new block may have
+		// votes in it that were hidden from the network and which
+		// validate our parent block. We should bolt these new votes
+		// into the tx tree stake of the old block template on parent.
+		svl := b.server.chainParams.StakeValidationHeight
+		if b.AggressiveMining && bmsg.block.Height() >= svl {
+			b.checkBlockForHiddenVotes(bmsg.block)
+		}
 
-			nextStakeDiff, errSDiff :=
-				b.chain.CalcNextRequiredStakeDifficulty()
-			if errSDiff != nil {
-				bmgrLog.Warnf("Failed to get next stake difficulty "+
-					"calculation: %v", err)
-			}
-			if r != nil && errSDiff == nil {
-				// Update registered websocket clients on the
-				// current stake difficulty.
-				r.ntfnMgr.NotifyStakeDifficulty(
-					&StakeDifficultyNtfnData{
-						*best.Hash,
-						best.Height,
-						nextStakeDiff,
-					})
-				b.server.txMemPool.PruneStakeTx(nextStakeDiff,
-					best.Height)
-				b.server.txMemPool.PruneExpiredTx(best.Height)
-			}
+		// Query the db for the latest best block since the block
+		// that was processed could be on a side chain or have caused
+		// a reorg.
+		best := b.chain.BestSnapshot()
 
-			winningTickets, poolSize, finalState, err :=
-				b.chain.LotteryDataForBlock(blockHash)
-			if err != nil {
-				bmgrLog.Warnf("Failed to get determine lottery "+
-					"data for new best block: %v", err)
-			}
+		// Query the DB for the missed tickets for the next top block.
+		missedTickets, err := b.chain.MissedTickets()
+		if err != nil {
+			bmgrLog.Warnf("Failed to get missed tickets "+
+				"for best block %v: %v", best.Hash, err)
+		}
+
+		// Retrieve the current previous block hash.
+		curPrevHash := b.chain.BestPrevHash()
+
+		nextStakeDiff, errSDiff :=
+			b.chain.CalcNextRequiredStakeDifficulty()
+		if errSDiff != nil {
+			bmgrLog.Warnf("Failed to get next stake difficulty "+
+				"calculation: %v", err)
+		}
+		if r != nil && errSDiff == nil {
+			// Update registered websocket clients on the
+			// current stake difficulty.
+			r.ntfnMgr.NotifyStakeDifficulty(
+				&StakeDifficultyNtfnData{
+					*best.Hash,
+					best.Height,
+					nextStakeDiff,
+				})
+			b.server.txMemPool.PruneStakeTx(nextStakeDiff,
+				best.Height)
+			b.server.txMemPool.PruneExpiredTx(best.Height)
+		}
+
+		winningTickets, poolSize, finalState, err :=
+			b.chain.LotteryDataForBlock(blockHash)
+		if err != nil {
+			bmgrLog.Warnf("Failed to get determine lottery "+
+				"data for new best block: %v", err)
+		}
 
-			b.updateChainState(best.Hash, best.Height, finalState,
-				uint32(poolSize), nextStakeDiff, winningTickets,
-				missedTickets, curPrevHash)
+		b.updateChainState(best.Hash, best.Height, finalState,
+			uint32(poolSize), nextStakeDiff, winningTickets,
+			missedTickets, curPrevHash)
 
-			// Update this peer's latest block height, for future
-			// potential sync node candidancy.
-			heightUpdate = best.Height
-			blkHashUpdate = best.Hash
+		// Update this peer's latest block height, for future
+		// potential sync node candidancy.
+		heightUpdate = best.Height
+		blkHashUpdate = best.Hash
 
-			// Clear the rejected transactions.
-			b.rejectedTxns = make(map[chainhash.Hash]struct{})
+		// Clear the rejected transactions.
+		b.rejectedTxns = make(map[chainhash.Hash]struct{})
 
-			// Allow any clients performing long polling via the
-			// getblocktemplate RPC to be notified when the new block causes
-			// their old block template to become stale.
-			rpcServer := b.server.rpcServer
-			if rpcServer != nil {
-				rpcServer.gbtWorkState.NotifyBlockConnected(blockHash)
-			}
+		// Allow any clients performing long polling via the
+		// getblocktemplate RPC to be notified when the new block causes
+		// their old block template to become stale.
+		rpcServer := b.server.rpcServer
+		if rpcServer != nil {
+			rpcServer.gbtWorkState.NotifyBlockConnected(blockHash)
 		}
 	}
 
 	// Update the block height for this peer. But only send a message to
-	// the server for updating peer heights if this is an orphan or our
-	// chain is "current". This avoids sending a spammy amount of messages
-	// if we're syncing the chain from scratch.
+	// the server for updating peer heights if our chain is "current". This
+	// avoids sending a spammy amount of messages if we're syncing the chain
+	// from scratch.
 	if blkHashUpdate != nil && heightUpdate != 0 {
 		bmsg.peer.UpdateLastBlockHeight(heightUpdate)
-		if isOrphan || b.current() {
+		if b.current() {
 			go b.server.UpdatePeerHeights(blkHashUpdate, heightUpdate,
 				bmsg.peer)
 		}
 	}
 
-	// Nothing more to do if we aren't in headers-first mode.
-	if !b.headersFirstMode {
-		return
-	}
+	// Request more blocks of the best header chain when the request queue
+	// of the sync peer is getting short.
+	if bmsg.peer == b.syncPeer &&
+		len(bmsg.peer.requestedBlocks) < minInFlightBlocks {
 
-	// This is headers-first mode, so if the block is not a checkpoint
-	// request more blocks using the header list when the request queue is
-	// getting short.
-	if !isCheckpointBlock {
-		if b.startHeader != nil &&
-			len(bmsg.peer.requestedBlocks) < minInFlightBlocks {
-			b.fetchHeaderBlocks()
-		}
-		return
+		b.fetchHeaderBlocks(bmsg.peer)
 	}
+}
 
-	// This is headers-first mode and the block is a checkpoint.  When
-	// there is a next checkpoint, get the next round of headers by asking
-	// for headers starting from the block after this one up to the next
-	// checkpoint.
-	prevHeight := b.nextCheckpoint.Height
-	prevHash := b.nextCheckpoint.Hash
-	b.nextCheckpoint = b.findNextHeaderCheckpoint(prevHeight)
-	if b.nextCheckpoint != nil {
-		locator := blockchain.BlockLocator([]*chainhash.Hash{prevHash})
-		err := bmsg.peer.PushGetHeadersMsg(locator, b.nextCheckpoint.Hash)
-		if err != nil {
-			bmgrLog.Warnf("Failed to send getheaders message to "+
-				"peer %s: %v", bmsg.peer.Addr(), err)
-			return
-		}
-		bmgrLog.Infof("Downloading headers for blocks %d to %d from "+
-			"peer %s", prevHeight+1, b.nextCheckpoint.Height,
-			b.syncPeer.Addr())
+// requestHeaders requests the headers which build on the best known header
+// from the passed peer.
+func (b *blockManager) requestHeaders(sp *serverPeer) {
+	locator, err := b.chain.LatestHeaderLocator()
+	if err != nil {
+		bmgrLog.Warnf("Failed to get block locator for the best "+
+			"header: %v", err)
 		return
 	}
-
-	// This is headers-first mode, the block is a checkpoint, and there are
-	// no more checkpoints, so switch to normal mode by requesting blocks
-	// from the block after this one up to the end of the chain (zero hash).
-	b.headersFirstMode = false
-	b.headerList.Init()
-	bmgrLog.Infof("Reached the final checkpoint -- switching to normal mode")
-	locator := blockchain.BlockLocator([]*chainhash.Hash{blockHash})
-	err = bmsg.peer.PushGetBlocksMsg(locator, &zeroHash)
+	err = sp.PushGetHeadersMsg(locator, &zeroHash)
 	if err != nil {
-		bmgrLog.Warnf("Failed to send getblocks message to peer %s: %v",
-			bmsg.peer.Addr(), err)
-		return
+		bmgrLog.Warnf("Failed to send getheaders message to peer %s: %v",
+			sp.Addr(), err)
 	}
 }
 
-// fetchHeaderBlocks creates and sends a request to the syncPeer for the next
-// list of blocks to be downloaded based on the current list of headers.
-func (b *blockManager) fetchHeaderBlocks() {
-	// Nothing to do if there is no start header.
-	if b.startHeader == nil {
-		bmgrLog.Warnf("fetchHeaderBlocks called with no start header")
-		return
-	}
-
-	// Build up a getdata request for the list of blocks the headers
-	// describe.  The size hint will be limited to wire.MaxInvPerMsg by
-	// the function, so no need to double check it here.
-	gdmsg := wire.NewMsgGetDataSizeHint(uint(b.headerList.Len()))
-	numRequested := 0
-	for e := b.startHeader; e != nil; e = e.Next() {
-		node, ok := e.Value.(*headerNode)
-		if !ok {
-			bmgrLog.Warn("Header list node type is not a headerNode")
+// requestBlocks creates and sends a request to the passed peer for the blocks
+// with the passed hashes which are neither available nor already requested.
+// The number of blocks in flight from the peer is limited to the maximum
+// number of inventory vectors allowed per message.
+func (b *blockManager) requestBlocks(sp *serverPeer, hashes []*chainhash.Hash) {
+	maxRequested := wire.MaxInvPerMsg - len(sp.requestedBlocks)
+	gdmsg := wire.NewMsgGetDataSizeHint(uint(len(hashes)))
+	for _, hash := range hashes {
+		if len(gdmsg.InvList) >= maxRequested {
+			break
+		}
+		if _, exists := b.requestedBlocks[*hash]; exists {
 			continue
 		}
-
-		iv := wire.NewInvVect(wire.InvTypeBlock, node.hash)
+		iv := wire.NewInvVect(wire.InvTypeBlock, hash)
 		haveInv, err := b.haveInventory(iv)
 		if err != nil {
 			bmgrLog.Warnf("Unexpected failure when checking for "+
-				"existing inventory during header block "+
-				"fetch: %v", err)
+				"existing inventory during block fetch: %v", err)
 			continue
 		}
-		if !haveInv {
-			b.requestedBlocks[*node.hash] = struct{}{}
-			b.requestedEverBlocks[*node.hash] = 0
-			b.syncPeer.requestedBlocks[*node.hash] = struct{}{}
-			err = gdmsg.AddInvVect(iv)
-			if err != nil {
-				bmgrLog.Warnf("Failed to add invvect while fetching "+
-					"block headers: %v", err)
-			}
-			numRequested++
+		if haveInv {
+			continue
 		}
-		b.startHeader = e.Next()
-		if numRequested >= wire.MaxInvPerMsg {
-			break
+
+		b.requestedBlocks[*hash] = struct{}{}
+		b.requestedEverBlocks[*hash] = 0
+		b.limitMap(b.requestedBlocks, maxRequestedBlocks)
+		sp.requestedBlocks[*hash] = struct{}{}
+		err = gdmsg.AddInvVect(iv)
+		if err != nil {
+			bmgrLog.Warnf("Failed to add invvect while fetching "+
+				"blocks: %v", err)
 		}
 	}
 	if len(gdmsg.InvList) > 0 {
-		b.syncPeer.QueueMessage(gdmsg, nil)
+		sp.QueueMessage(gdmsg, nil)
 	}
 }
 
-// handleHeadersMsg handles headers messages from all peers.
+// fetchHeaderBlocks creates and sends a request to the passed peer for the next
+// blocks of the best header chain which are not available and have not been
+// requested yet.
+func (b *blockManager) fetchHeaderBlocks(sp *serverPeer) {
+	b.requestBlocks(sp, b.chain.NextNeededBlocks(wire.MaxInvPerMsg))
+}
+
+// handleHeadersMsg handles headers messages from all peers.  The headers are
+// validated and added to the block index.  Once a peer sent all of the headers
+// it knows about, the blocks of the best header chain are requested.
 func (b *blockManager) handleHeadersMsg(hmsg *headersMsg) {
-	// The remote peer is misbehaving if we didn't request headers.
+	// Nothing to do for an empty headers message.
 	msg := hmsg.headers
 	numHeaders := len(msg.Headers)
-	if !b.headersFirstMode {
-		bmgrLog.Warnf("Got %d unrequested headers from %s -- "+
-			"disconnecting", numHeaders, hmsg.peer.Addr())
-		hmsg.peer.Disconnect()
-		return
-	}
-
-	// Nothing to do for an empty headers message.
 	if numHeaders == 0 {
 		return
 	}
 
-	// Process all of the received headers ensuring each one connects to the
-	// previous and that checkpoints match.
-	receivedCheckpoint := false
-	var finalHash *chainhash.Hash
-	for _, blockHeader := range msg.Headers {
-		blockHash := blockHeader.BlockHash()
-		finalHash = &blockHash
-
-		// Ensure there is a previous header to compare against.
-		prevNodeEl := b.headerList.Back()
-		if prevNodeEl == nil {
-			bmgrLog.Warnf("Header list does not contain a previous" +
-				"element as expected -- disconnecting peer")
-			hmsg.peer.Disconnect()
-			return
-		}
-
-		// Ensure the header properly connects to the previous one and
-		// add it to the list of headers.
-		node := headerNode{hash: &blockHash}
-		prevNode := prevNodeEl.Value.(*headerNode)
-		if prevNode.hash.IsEqual(&blockHeader.PrevBlock) {
-			node.height = prevNode.height + 1
-			e := b.headerList.PushBack(&node)
-			if b.startHeader == nil {
-				b.startHeader = e
-			}
-		} else {
-			bmgrLog.Warnf("Received block header that does not "+
-				"properly connect to the chain from peer %s "+
-				"-- disconnecting", hmsg.peer.Addr())
-			hmsg.peer.Disconnect()
+	// Process all of the received headers.  Each header is fully validated
+	// against the headers it builds on, which includes ensuring it connects
+	// to a known header.
+	sp := hmsg.peer
+	numProcessed, err := b.chain.ProcessBlockHeaders(msg.Headers)
+	if err != nil {
+		hash := msg.Headers[numProcessed].BlockHash()
+		rerr, ok := err.(blockchain.RuleError)
+		if !ok {
+			bmgrLog.Errorf("Failed to process block header %v: %v",
+				hash, err)
 			return
 		}
-
-		// Verify the header at the next checkpoint height matches.
-		if node.height == b.nextCheckpoint.Height {
-			if node.hash.IsEqual(b.nextCheckpoint.Hash) {
-				receivedCheckpoint = true
-				bmgrLog.Infof("Verified downloaded block "+
-					"header against checkpoint at height "+
-					"%d/hash %s", node.height, node.hash)
-			} else {
-				bmgrLog.Warnf("Block header at height %d/hash "+
-					"%s from peer %s does NOT match "+
-					"expected checkpoint hash of %s -- "+
-					"disconnecting", node.height,
-					node.hash, hmsg.peer.Addr(),
-					b.nextCheckpoint.Hash)
-				hmsg.peer.Disconnect()
-				return
+		switch rerr.ErrorCode {
+		// Request the missing headers when the header does not connect
+		// to a known header, which typically happens when a block was
+		// announced by a peer which is ahead.  Peers which keep sending
+		// headers that do not connect are misbehaving though, so their
+		// ban score is increased every maxUnconnectingHeaders times.
+		case blockchain.ErrMissingParent:
+			sp.numUnconnectingHeaders++
+			bmgrLog.Debugf("Received block header %v from %s which "+
+				"does not connect to a known header -- requesting "+
+				"headers", hash, sp)
+			if sp.numUnconnectingHeaders%maxUnconnectingHeaders == 0 {
+				sp.addBanScore(20, 0, "unconnecting headers")
 			}
-			break
+			b.requestHeaders(sp)
+
+		// Honest peers send headers which are too far in the future
+		// when either clock is off, headers of blocks which were
+		// invalidated locally with invalidateblock and headers of
+		// deep side chains which do not have enough work to be stored,
+		// so only stop processing the headers.
+		case blockchain.ErrTimeTooNew, blockchain.ErrKnownInvalidBlock,
+			blockchain.ErrLowWorkFork:
+
+			bmgrLog.Infof("Rejected block header %v from %s: %v",
+				hash, sp, err)
+
+		// Peers which send headers that violate the consensus rules
+		// are misbehaving.
+		default:
+			bmgrLog.Warnf("Rejected block header %v from %s: %v -- "+
+				"disconnecting", hash, sp, err)
+			sp.Disconnect()
 		}
+		return
 	}
-
-	// When this header is a checkpoint, switch to fetching the blocks for
-	// all of the headers since the last checkpoint.
-	if receivedCheckpoint {
-		// Since the first entry of the list is always the final block
-		// that is already in the database and is only used to ensure
-		// the next header links properly, it must be removed before
-		// fetching the blocks.
-		b.headerList.Remove(b.headerList.Front())
-		bmgrLog.Infof("Received %v block headers: Fetching blocks",
-			b.headerList.Len())
-		b.progressLogger.SetLastLogTime(time.Now())
-		b.fetchHeaderBlocks()
+	sp.numUnconnectingHeaders = 0
+	finalHeader := msg.Headers[numHeaders-1]
+	finalHash := finalHeader.BlockHash()
+	finalHeight := int64(finalHeader.Height)
+
+	// Update the last block the peer is known to have.
+	hmsg.peer.UpdateLastAnnouncedBlock(&finalHash)
+	if finalHeight > hmsg.peer.LastBlock() {
+		hmsg.peer.UpdateLastBlockHeight(finalHeight)
+	}
+
+	// Request the next batch of headers when the peer sent the maximum
+	// number of headers, since it likely has more.
+	if numHeaders == wire.MaxBlockHeadersPerMsg {
+		_, bestHeaderHeight := b.chain.BestHeader()
+		bmgrLog.Infof("Received %d block headers from %s (best header "+
+			"height %d)", numHeaders, hmsg.peer, bestHeaderHeight)
+		locator := blockchain.BlockLocator([]*chainhash.Hash{&finalHash})
+		err := hmsg.peer.PushGetHeadersMsg(locator, &zeroHash)
+		if err != nil {
+			bmgrLog.Warnf("Failed to send getheaders message to "+
+				"peer %s: %v", hmsg.peer.Addr(), err)
+		}
 		return
 	}
 
-	// This header is not a checkpoint, so request the next batch of
-	// headers starting from the latest known header and ending with the
-	// next checkpoint.
-	locator := blockchain.BlockLocator([]*chainhash.Hash{finalHash})
-	err := hmsg.peer.PushGetHeadersMsg(locator, b.nextCheckpoint.Hash)
-	if err != nil {
-		bmgrLog.Warnf("Failed to send getheaders message to "+
-			"peer %s: %v", hmsg.peer.Addr(), err)
+	// All headers the peer knows about were received, so download the
+	// blocks of the best header chain.  While syncing, the blocks are only
+	// downloaded from the sync peer so they arrive in order.
+	//
+	// Once the chain is current, the blocks of the received headers are
+	// requested as well, since newly announced blocks which do not extend
+	// the best header chain, such as those at the same height as the
+	// current tip, are still needed as alternative parents for block
+	// templates and to relay them to other peers.
+	if b.current() {
+		hashes := make([]*chainhash.Hash, 0, numHeaders)
+		for _, blockHeader := range msg.Headers {
+			hash := blockHeader.BlockHash()
+			hashes = append(hashes, &hash)
+		}
+		b.requestBlocks(hmsg.peer, hashes)
+		b.fetchHeaderBlocks(hmsg.peer)
 		return
 	}
+	if hmsg.peer == b.syncPeer {
+		b.progressLogger.SetLastLogTime(time.Now())
+		b.fetchHeaderBlocks(hmsg.peer)
+	}
 }
 
 // haveInventory returns whether or not the inventory represented by the passed
 // inventory vector is known.  This includes checking all of the various places
 // inventory can be when it is in different states such as blocks that are part
-// of the main chain, on a side chain, in the orphan pool, and transactions that
-// are in the memory pool (either the main pool or orphan pool).
+// of the main chain or on a side chain, and transactions that are in the memory
+// pool (either the main pool or orphan pool).
 func (b *blockManager) haveInventory(invVect *wire.InvVect) (bool, error) {
 	switch invVect.Type {
 	case wire.InvTypeBlock:
-		// Ask chain if the block is known to it in any form (main
-		// chain, side chain, or orphan).
+		// Ask chain if the data of the block is available to it in any
+		// form (main chain or side chain).
 		return b.chain.HaveBlock(&invVect.Hash)
 
 	case wire.InvTypeTx:
@@ -1490,7 +1428,8 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 	}
 
 	// Ignore invs from peers that aren't the sync if we are not current.
-	// Helps prevent fetching a mass of orphans.
+	// Helps prevent fetching a mass of headers and blocks from peers which
+	// are not synced either.
 	if imsg.peer != b.syncPeer && !b.current() {
 		return
 	}
@@ -1505,11 +1444,13 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 		}
 	}
 
-	// Request the advertised inventory if we don't already have it.  Also,
-	// request parent blocks of orphans if we receive one we already have.
-	// Finally, attempt to detect potential stalls due to long side chains
-	// we already have and request more blocks to prevent them.
-	for i, iv := range invVects {
+	// Request the advertised transactions if we don't already have them.
+	// Blocks are never requested directly from an inv.  Instead, the
+	// headers which build on the best known header are requested when any
+	// advertised block is not available, so the headers are validated
+	// before the blocks are downloaded.
+	needHeaders := false
+	for _, iv := range invVects {
 		// Ignore unsupported inventory types.
 		if iv.Type != wire.InvTypeBlock && iv.Type != wire.InvTypeTx {
 			continue
@@ -1519,11 +1460,6 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 		// for the peer.
 		imsg.peer.AddKnownInventory(iv)
 
-		// Ignore inventory when we're in headers-first mode.
-		if b.headersFirstMode {
-			continue
-		}
-
 		// Request the inventory if we don't already have it.
 		haveInv, err := b.haveInventory(iv)
 		if err != nil {
@@ -1532,67 +1468,24 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 				"processing: %v", err)
 			continue
 		}
-		if !haveInv {
-			if iv.Type == wire.InvTypeTx {
-				// Skip the transaction if it has already been
-				// rejected.
-				if _, exists := b.rejectedTxns[iv.Hash]; exists {
-					continue
-				}
-			}
-
-			// Add it to the request queue.
-			imsg.peer.requestQueue = append(imsg.peer.requestQueue, iv)
+		if haveInv {
 			continue
 		}
-
 		if iv.Type == wire.InvTypeBlock {
-			// The block is an orphan block that we already have.
-			// When the existing orphan was processed, it requested
-			// the missing parent blocks.  When this scenario
-			// happens, it means there were more blocks missing
-			// than are allowed into a single inventory message.  As
-			// a result, once this peer requested the final
-			// advertised block, the remote peer noticed and is now
-			// resending the orphan block as an available block
-			// to signal there are more missing blocks that need to
-			// be requested.
-			if b.chain.IsKnownOrphan(&iv.Hash) {
-				// Request blocks starting at the latest known
-				// up to the root of the orphan that just came
-				// in.
-				orphanRoot := b.chain.GetOrphanRoot(&iv.Hash)
-				locator, err := b.chain.LatestBlockLocator()
-				if err != nil {
-					bmgrLog.Errorf("PEER: Failed to get block "+
-						"locator for the latest block: "+
-						"%v", err)
-					continue
-				}
-				err = imsg.peer.PushGetBlocksMsg(locator, orphanRoot)
-				if err != nil {
-					bmgrLog.Errorf("PEER: Failed to push getblocksmsg "+
-						"for orphan chain: %v", err)
-				}
-				continue
-			}
+			needHeaders = true
+			continue
+		}
 
-			// We already have the final block advertised by this
-			// inventory message, so force a request for more.  This
-			// should only happen if we're on a really long side
-			// chain.
-			if i == lastBlock {
-				// Request blocks after this one up to the
-				// final one the remote peer knows about (zero
-				// stop hash).
-				locator := b.chain.BlockLocatorFromHash(&iv.Hash)
-				err = imsg.peer.PushGetBlocksMsg(locator, &zeroHash)
-				if err != nil {
-					bmgrLog.Errorf("PEER: Failed to push getblocksmsg: "+
-						"%v", err)
-				}
-			}
+		// Skip the transaction if it has already been rejected.
+		if _, exists := b.rejectedTxns[iv.Hash]; exists {
+			continue
 		}
+
+		// Add it to the request queue.
+		imsg.peer.requestQueue = append(imsg.peer.requestQueue, iv)
+	}
+	if needHeaders {
+		b.requestHeaders(imsg.peer)
 	}
 
 	// Request as much as possible at once.  Anything that won't fit into
@@ -1605,30 +1498,15 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 		requestQueue[0] = nil
 		requestQueue = requestQueue[1:]
 
-		switch iv.Type {
-		case wire.InvTypeBlock:
-			// Request the block if there is not already a pending
-			// request.
-			if _, exists := b.requestedBlocks[iv.Hash]; !exists {
-				b.requestedBlocks[iv.Hash] = struct{}{}
-				b.requestedEverBlocks[iv.Hash] = 0
-				b.limitMap(b.requestedBlocks, maxRequestedBlocks)
-				imsg.peer.requestedBlocks[iv.Hash] = struct{}{}
-				gdmsg.AddInvVect(iv)
-				numRequested++
-			}
-
-		case wire.InvTypeTx:
-			// Request the transaction if there is not already a
-			// pending request.
-			if _, exists := b.requestedTxns[iv.Hash]; !exists {
-				b.requestedTxns[iv.Hash] = struct{}{}
-				b.requestedEverTxns[iv.Hash] = 0
-				b.limitMap(b.requestedTxns, maxRequestedTxns)
-				imsg.peer.requestedTxns[iv.Hash] = struct{}{}
-				gdmsg.AddInvVect(iv)
-				numRequested++
-			}
+		// Request the transaction if there is not already a pending
+		// request.
+		if _, exists := b.requestedTxns[iv.Hash]; !exists {
+			b.requestedTxns[iv.Hash] = struct{}{}
+			b.requestedEverTxns[iv.Hash] = 0
+			b.limitMap(b.requestedTxns, maxRequestedTxns)
+			imsg.peer.requestedTxns[iv.Hash] = struct{}{}
+			gdmsg.AddInvVect(iv)
+			numRequested++
 		}
 
 		if numRequested >= wire.MaxInvPerMsg {
@@ -1724,58 +1602,28 @@ out:
 				// Reorganizing has succeeded, so we need to
 				// update the chain state.
 				if err == nil {
-					// Query the db for the latest best block since
-					// the block that was processed could be on a
-					// side chain or have caused a reorg.
-					best := b.chain.BestSnapshot()
-
-					// Fetch the required lottery data.
-					winningTickets, poolSize, finalState, err :=
-						b.chain.LotteryDataForBlock(best.Hash)
-
-					// Update registered websocket clients on the
-					// current stake difficulty.
-					nextStakeDiff, errSDiff :=
-						b.chain.CalcNextRequiredStakeDifficulty()
-					if err != nil {
-						bmgrLog.Warnf("Failed to get next stake difficulty "+
-							"calculation: %v", err)
-					}
-					r := b.server.rpcServer
-					if r != nil && errSDiff == nil {
-						r.ntfnMgr.NotifyStakeDifficulty(
-							&StakeDifficultyNtfnData{
-								*best.Hash,
-								best.Height,
-								nextStakeDiff,
-							})
-						b.server.txMemPool.PruneStakeTx(nextStakeDiff,
-							best.Height)
-						b.server.txMemPool.PruneExpiredTx(best.Height)
-					}
-
-					missedTickets, err := b.chain.MissedTickets()
-					if err != nil {
-						bmgrLog.Warnf("Failed to get missed tickets"+
-							": %v", err)
-					}
+					b.refreshChainState()
+				}
 
-					// The blockchain should be updated, so fetch the
-					// latest snapshot.
-					best = b.chain.BestSnapshot()
-					curPrevHash := b.chain.BestPrevHash()
+				msg.reply <- forceReorganizationResponse{
+					err: err,
+				}
 
-					b.updateChainState(best.Hash,
-						best.Height,
-						finalState,
-						uint32(poolSize),
-						nextStakeDiff,
-						winningTickets,
-						missedTickets,
-						curPrevHash)
+			case invalidateBlockMsg:
+				err := b.chain.InvalidateBlock(&msg.hash)
+				if err == nil {
+					b.refreshChainState()
+				}
+				msg.reply <- invalidateBlockResponse{
+					err: err,
 				}
 
-				msg.reply <- forceReorganizationResponse{
+			case reconsiderBlockMsg:
+				err := b.chain.ReconsiderBlock(&msg.hash)
+				if err == nil {
+					b.refreshChainState()
+				}
+				msg.reply <- reconsiderBlockResponse{
 					err: err,
 				}
 
@@ -1801,25 +1649,23 @@ out:
 				}
 
 			case processBlockMsg:
-				onMainChain, isOrphan, err := b.chain.ProcessBlock(
-					msg.block, msg.flags)
+				onMainChain, err := b.chain.ProcessBlock(msg.block,
+					msg.flags)
 				if err != nil {
 					msg.reply <- processBlockResponse{
 						onMainChain: onMainChain,
-						isOrphan:    isOrphan,
 						err:         err,
 					}
 					continue
 				}
 
-				// Get the winning tickets if the block is not an
-				// orphan and if it's recent. If they've yet to be
-				// broadcasted, broadcast them.
+				// Get the winning tickets if the block is recent.
+				// If they've yet to be broadcasted, broadcast them.
 				_, bestHeight := b.chainState.Best()
 				blockHeight := int64(msg.block.MsgBlock().Header.Height)
 				tooOldForLotteryData := blockHeight <=
 					(bestHeight - maxLotteryDataBlockDelta)
-				if !isOrphan && !tooOldForLotteryData {
+				if !tooOldForLotteryData {
 					b.lotteryDataBroadcastMutex.Lock()
 					_, beenNotified := b.lotteryDataBroadcast[*msg.block.Hash()]
 					b.lotteryDataBroadcastMutex.Unlock()
@@ -1830,8 +1676,7 @@ out:
 						bmgrLog.Warnf("Stake failure in lottery tickets "+
 							"calculation: %v", err)
 						msg.reply <- processBlockResponse{
-							isOrphan: false,
-							err:      err,
+							err: err,
 						}
 						continue
 					}
@@ -1841,7 +1686,7 @@ out:
 					// do this if we're above the latest checkpoint
 					// height.
 					r := b.server.rpcServer
-					if r != nil && !isOrphan && !beenNotified &&
+					if r != nil && !beenNotified &&
 						(msg.block.Height() >=
 							b.server.chainParams.StakeValidationHeight-1) &&
 						(msg.block.Height() >
@@ -1924,8 +1769,8 @@ out:
 				}
 
 				msg.reply <- processBlockResponse{
-					isOrphan: isOrphan,
-					err:      nil,
+					onMainChain: onMainChain,
+					err:         nil,
 				}
 
 			case processTransactionMsg:
@@ -2112,7 +1957,7 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 			b.server.AnnounceNewTransactions(acceptedTxs)
 		}
 
-		if r := b.server.rpcServer; r != nil {
+		if b.server.rpcServer != nil || b.server.electrumServer != nil {
 			// Now that this block is in the blockchain we can mark
 			// all the transactions (except the coinbase) as no
 			// longer needing rebroadcasting.
@@ -2126,11 +1971,18 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 				iv := wire.NewInvVect(wire.InvTypeTx, stx.Hash())
 				b.server.RemoveRebroadcastInventory(iv)
 			}
+		}
 
+		if r := b.server.rpcServer; r != nil {
 			// Notify registered websocket clients of incoming block.
 			r.ntfnMgr.NotifyBlockConnected(block)
 		}
 
+		// Notify Electrum clients of the new tip.
+		if e := b.server.electrumServer; e != nil {
+			e.NotifyTipChanged(block, parentBlock)
+		}
+
 	// Stake tickets are spent or missed from the most recently connected block.
 	case blockchain.NTSpentAndMissedTickets:
 		tnd, ok := notification.Data.(*blockchain.TicketNotificationsData)
@@ -2215,6 +2067,11 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 			r.ntfnMgr.NotifyBlockDisconnected(block)
 		}
 
+		// Notify Electrum clients of the new tip.
+		if e := b.server.electrumServer; e != nil {
+			e.NotifyTipChanged(block, parentBlock)
+		}
+
 	// The blockchain is reorganizing.
 	case blockchain.NTReorganization:
 		rd, ok := notification.Data.(*blockchain.ReorganizationNtfnsData)
@@ -2231,6 +2088,32 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 		// Drop the associated mining template from the old chain, since it
 		// will be no longer valid.
 		b.cachedCurrentTemplate = nil
+
+	// A block was manually marked invalid.
+	case blockchain.NTBlockInvalidated:
+		bd, ok := notification.Data.(*blockchain.BlockValidityNtfnsData)
+		if !ok {
+			bmgrLog.Warnf("Block invalidated notification is malformed")
+			break
+		}
+
+		// Notify registered websocket clients.
+		if r := b.server.rpcServer; r != nil {
+			r.ntfnMgr.NotifyBlockInvalidated(bd)
+		}
+
+	// The invalid mark of a block was manually removed.
+	case blockchain.NTBlockReconsidered:
+		bd, ok := notification.Data.(*blockchain.BlockValidityNtfnsData)
+		if !ok {
+			bmgrLog.Warnf("Block reconsidered notification is malformed")
+			break
+		}
+
+		// Notify registered websocket clients.
+		if r := b.server.rpcServer; r != nil {
+			r.ntfnMgr.NotifyBlockReconsidered(bd)
+		}
 	}
 }
 
@@ -2478,6 +2361,28 @@ func (b *blockManager) ForceReorganization(formerBest, newBest chainhash.Hash) e
 	return response.err
 }
 
+// InvalidateBlock marks the block with the passed hash and all of its
+// descendants invalid and reorganizes the chain to the best remaining valid
+// chain when needed.  It is funneled through the block manager since
+// blockchain is not safe for concurrent access.
+func (b *blockManager) InvalidateBlock(hash *chainhash.Hash) error {
+	reply := make(chan invalidateBlockResponse)
+	b.msgChan <- invalidateBlockMsg{hash: *hash, reply: reply}
+	response := <-reply
+	return response.err
+}
+
+// ReconsiderBlock removes the invalid marks of the block with the passed hash,
+// its ancestors, and its descendants and reorganizes the chain to the best
+// valid chain when needed.  It is funneled through the block manager since
+// blockchain is not safe for concurrent access.
+func (b *blockManager) ReconsiderBlock(hash *chainhash.Hash) error {
+	reply := make(chan reconsiderBlockResponse)
+	b.msgChan <- reconsiderBlockMsg{hash: *hash, reply: reply}
+	response := <-reply
+	return response.err
+}
+
 // GetGeneration returns the hashes of all the children of a parent for the
 // block hash that is passed to the function. It is funneled through the block
 // manager since blockchain is not safe for concurrent access.
@@ -2509,12 +2414,13 @@ func (b *blockManager) GetTopBlockFromChain() (*hcutil.Block, error) {
 
 // ProcessBlock makes use of ProcessBlock on an internal instance of a block
 // chain.  It is funneled through the block manager since blockchain is not safe
-// for concurrent access.
-func (b *blockManager) ProcessBlock(block *hcutil.Block, flags blockchain.BehaviorFlags) (bool, error) {
+// for concurrent access.  Blocks which build on a block that is not available
+// are rejected with an error.
+func (b *blockManager) ProcessBlock(block *hcutil.Block, flags blockchain.BehaviorFlags) error {
 	reply := make(chan processBlockResponse, 1)
 	b.msgChan <- processBlockMsg{block: block, flags: flags, reply: reply}
 	response := <-reply
-	return response.isOrphan, response.err
+	return response.err
 }
 
 // ProcessTransaction makes use of ProcessTransaction on an internal instance of
@@ -2597,7 +2503,6 @@ func newBlockManager(s *server, indexManager blockchain.IndexManager) (*blockMan
 		requestedEverBlocks: make(map[chainhash.Hash]uint8),
 		progressLogger:      newBlockProgressLogger("Processed", bmgrLog),
 		msgChan:             make(chan interface{}, cfg.MaxPeers*3),
-		headerList:          list.New(),
 		AggressiveMining:    !cfg.NonAggressive,
 		quit:                make(chan struct{}),
 	}
@@ -2605,25 +2510,22 @@ func newBlockManager(s *server, indexManager blockchain.IndexManager) (*blockMan
 	// Create a new block chain instance with the appropriate configuration.
 	var err error
 	bm.chain, err = blockchain.New(&blockchain.Config{
-		DB:            s.db,
-		ChainParams:   s.chainParams,
-		TimeSource:    s.timeSource,
-		Notifications: bm.handleNotifyMsg,
-		SigCache:      s.sigCache,
-		IndexManager:  indexManager,
+		DB:                s.db,
+		ChainParams:       s.chainParams,
+		TimeSource:        s.timeSource,
+		Notifications:     bm.handleNotifyMsg,
+		SigCache:          s.sigCache,
+		IndexManager:      indexManager,
+		AssumeValid:       cfg.assumeValid,
+		BatchSigVerify:    cfg.BatchSigVerify,
+		ForcedDeployments: cfg.forcedDeployments,
 	})
 	if err != nil {
 		return nil, err
 	}
 	best := bm.chain.BestSnapshot()
 	bm.chain.DisableCheckpoints(cfg.DisableCheckpoints)
-	if !cfg.DisableCheckpoints {
-		// Initialize the next checkpoint based on the current height.
-		bm.nextCheckpoint = bm.findNextHeaderCheckpoint(best.Height)
-		if bm.nextCheckpoint != nil {
-			bm.resetHeaderState(best.Hash, best.Height)
-		}
-	} else {
+	if cfg.DisableCheckpoints {
 		bmgrLog.Info("Checkpoints are disabled")
 	}
 
@@ -2736,8 +2638,15 @@ func loadBlockDB() (database.DB, error) {
 	// The database name is based on the database type.
 	dbPath := blockDbPath(cfg.DbType)
 
+	// The block compression is only passed when enabled since it is an
+	// optional argument which not all database types support.
+	dbArgs := []interface{}{dbPath, activeNetParams.Net}
+	if cfg.blockCompression != database.BlockCompressionNone {
+		dbArgs = append(dbArgs, cfg.blockCompression)
+	}
+
 	dcrdLog.Infof("Loading block database from '%s'", dbPath)
-	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	db, err := database.Open(cfg.DbType, dbArgs...)
 	if err != nil {
 		// Return the error if it's not because the database doesn't
 		// exist.
@@ -2752,13 +2661,17 @@ func loadBlockDB() (database.DB, error) {
 		if err != nil {
 			return nil, err
 		}
-		db, err = database.Create(cfg.DbType, dbPath, activeNetParams.Net)
+		db, err = database.Create(cfg.DbType, dbArgs...)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	dcrdLog.Info("Block database loaded")
+	if cfg.blockCompression != database.BlockCompressionNone {
+		dcrdLog.Infof("Compressing new blocks with %v",
+			cfg.blockCompression)
+	}
 	return db, nil
 }
 
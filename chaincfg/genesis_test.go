@@ -135,3 +135,32 @@ func TestSimNetGenesisBlock(t *testing.T) {
 			spew.Sdump(SimNetParams.GenesisHash))
 	}
 }
+
+// TestRegNetGenesisBlock tests the genesis block of the regression test network
+// for validity by checking it commits to its coinbase, is distinct from the
+// simulation test network genesis block, and matches the genesis hash.
+func TestRegNetGenesisBlock(t *testing.T) {
+	genesis := RegNetParams.GenesisBlock
+	if genesis.Header.Bits != RegNetParams.PowLimitBits {
+		t.Fatalf("TestRegNetGenesisBlock: unexpected bits %08x, want %08x",
+			genesis.Header.Bits, RegNetParams.PowLimitBits)
+	}
+	merkleRoot := genesis.Transactions[0].TxHashFull()
+	if genesis.Header.MerkleRoot != merkleRoot {
+		t.Fatalf("TestRegNetGenesisBlock: merkle root does not commit "+
+			"to the coinbase - got %v, want %v", genesis.Header.MerkleRoot,
+			merkleRoot)
+	}
+
+	// Check hash of the block against expected hash.
+	hash := genesis.BlockHash()
+	if !RegNetParams.GenesisHash.IsEqual(&hash) {
+		t.Fatalf("TestRegNetGenesisBlock: Genesis block hash does "+
+			"not appear valid - got %v, want %v", spew.Sdump(hash),
+			spew.Sdump(RegNetParams.GenesisHash))
+	}
+	if hash == *SimNetParams.GenesisHash {
+		t.Fatal("TestRegNetGenesisBlock: Genesis block hash must differ " +
+			"from the simulation test network")
+	}
+}
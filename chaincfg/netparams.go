@@ -0,0 +1,603 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/wire"
+)
+
+// maxGenesisMessageLen is the maximum number of bytes allowed for the message
+// embedded in the coinbase of a generated genesis block.  It keeps the
+// signature script within the maximum allowed coinbase script length.
+const maxGenesisMessageLen = 64
+
+// hexBytes is a byte slice that is encoded as a hex string in network
+// parameter files.
+type hexBytes []byte
+
+// MarshalJSON encodes the bytes as a hex string.
+func (h hexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(h))
+}
+
+// UnmarshalJSON decodes a hex string into the bytes.
+func (h *hexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*h = b
+	return nil
+}
+
+// duration is a time.Duration that is encoded as a duration string such as
+// "1m30s" in network parameter files.
+type duration time.Duration
+
+// MarshalJSON encodes the duration as a duration string.
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes a duration string into the duration.
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// genesisParams describes the genesis block to generate for a network loaded
+// from a parameters file.
+type genesisParams struct {
+	Timestamp int64  `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+// netParamsFile is the on-disk representation of the network parameters for
+// a custom network.  Every field other than base is optional and defaults to
+// the value of the base network.  See ParamsFromJSON for details.
+type netParamsFile struct {
+	Base        string        `json:"base"`
+	Name        string        `json:"name"`
+	Net         uint32        `json:"net"`
+	DefaultPort string        `json:"defaultport"`
+	DNSSeeds    []string      `json:"dnsseeds"`
+	Genesis     genesisParams `json:"genesis"`
+
+	// Chain parameters.
+	PowLimitBits             uint32   `json:"powlimitbits"`
+	ReduceMinDifficulty      bool     `json:"reducemindifficulty"`
+	MinDiffReductionTime     duration `json:"mindiffreductiontime"`
+	GenerateSupported        bool     `json:"generatesupported"`
+	MaximumBlockSizes        []int    `json:"maximumblocksizes"`
+	MaxTxSize                int      `json:"maxtxsize"`
+	TargetTimePerBlock       duration `json:"targettimeperblock"`
+	WorkDiffAlpha            int64    `json:"workdiffalpha"`
+	WorkDiffWindowSize       int64    `json:"workdiffwindowsize"`
+	WorkDiffWindows          int64    `json:"workdiffwindows"`
+	RetargetAdjustmentFactor int64    `json:"retargetadjustmentfactor"`
+
+	// Subsidy parameters.
+	BaseSubsidy              int64  `json:"basesubsidy"`
+	MulSubsidy               int64  `json:"mulsubsidy"`
+	DivSubsidy               int64  `json:"divsubsidy"`
+	SubsidyReductionInterval int64  `json:"subsidyreductioninterval"`
+	WorkRewardProportion     uint16 `json:"workrewardproportion"`
+	StakeRewardProportion    uint16 `json:"stakerewardproportion"`
+	BlockTaxProportion       uint16 `json:"blocktaxproportion"`
+
+	// Consensus rule change deployments.
+	RuleChangeActivationQuorum     uint32                           `json:"rulechangeactivationquorum"`
+	RuleChangeActivationMultiplier uint32                           `json:"rulechangeactivationmultiplier"`
+	RuleChangeActivationDivisor    uint32                           `json:"rulechangeactivationdivisor"`
+	RuleChangeActivationInterval   uint32                           `json:"rulechangeactivationinterval"`
+	Deployments                    map[uint32][]ConsensusDeployment `json:"deployments"`
+	BlockEnforceNumRequired        uint64                           `json:"blockenforcenumrequired"`
+	BlockRejectNumRequired         uint64                           `json:"blockrejectnumrequired"`
+	BlockUpgradeNumToCheck         uint64                           `json:"blockupgradenumtocheck"`
+
+	// Mempool parameters.
+	RelayNonStdTxs bool `json:"relaynonstdtxs"`
+
+	// Address encoding magics.
+	NetworkAddressPrefix string   `json:"networkaddressprefix"`
+	PubKeyAddrID         hexBytes `json:"pubkeyaddrid"`
+	PubKeyBlissAddrID    hexBytes `json:"pubkeyblissaddrid"`
+	PubKeyHashAddrID     hexBytes `json:"pubkeyhashaddrid"`
+	PKHEdwardsAddrID     hexBytes `json:"pkhedwardsaddrid"`
+	PKHSchnorrAddrID     hexBytes `json:"pkhschnorraddrid"`
+	PKHBlissAddrID       hexBytes `json:"pkhblissaddrid"`
+	ScriptHashAddrID     hexBytes `json:"scripthashaddrid"`
+	PrivateKeyID         hexBytes `json:"privatekeyid"`
+	HDPrivateKeyID       hexBytes `json:"hdprivatekeyid"`
+	HDPublicKeyID        hexBytes `json:"hdpublickeyid"`
+	HDCoinType           uint32   `json:"hdcointype"`
+
+	// PoS parameters.
+	MinimumStakeDiff        int64    `json:"minimumstakediff"`
+	TicketPoolSize          uint16   `json:"ticketpoolsize"`
+	TicketsPerBlock         uint16   `json:"ticketsperblock"`
+	TicketMaturity          uint16   `json:"ticketmaturity"`
+	TicketExpiry            uint32   `json:"ticketexpiry"`
+	CoinbaseMaturity        uint16   `json:"coinbasematurity"`
+	SStxChangeMaturity      uint16   `json:"sstxchangematurity"`
+	TicketPoolSizeWeight    uint16   `json:"ticketpoolsizeweight"`
+	StakeDiffAlpha          int64    `json:"stakediffalpha"`
+	StakeDiffWindowSize     int64    `json:"stakediffwindowsize"`
+	StakeDiffWindows        int64    `json:"stakediffwindows"`
+	StakeVersionInterval    int64    `json:"stakeversioninterval"`
+	MaxFreshStakePerBlock   uint8    `json:"maxfreshstakeperblock"`
+	StakeEnabledHeight      int64    `json:"stakeenabledheight"`
+	StakeValidationHeight   int64    `json:"stakevalidationheight"`
+	StakeBaseSigScript      hexBytes `json:"stakebasesigscript"`
+	StakeMajorityMultiplier int32    `json:"stakemajoritymultiplier"`
+	StakeMajorityDivisor    int32    `json:"stakemajoritydivisor"`
+
+	// Organization related parameters.
+	OrganizationPkScript        hexBytes       `json:"organizationpkscript"`
+	OrganizationPkScriptVersion uint16         `json:"organizationpkscriptversion"`
+	BlockOneLedger              []*TokenPayout `json:"blockoneledger"`
+}
+
+// standardNets maps the names accepted for the base field of a network
+// parameters file to the standard network parameters they refer to.
+var standardNets = map[string]*Params{
+	MainNetParams.Name:  &MainNetParams,
+	TestNet2Params.Name: &TestNet2Params,
+	SimNetParams.Name:   &SimNetParams,
+	RegNetParams.Name:   &RegNetParams,
+}
+
+// newNetParamsFile returns a parameters file populated with the values of the
+// passed base network.  All slices are copied so that decoding a file on top
+// of the result does not modify the base network.
+func newNetParamsFile(base *Params) *netParamsFile {
+	ledger := make([]*TokenPayout, 0, len(base.BlockOneLedger))
+	for _, payout := range base.BlockOneLedger {
+		p := *payout
+		ledger = append(ledger, &p)
+	}
+
+	return &netParamsFile{
+		DefaultPort: base.DefaultPort,
+		DNSSeeds:    append([]string(nil), base.DNSSeeds...),
+		Genesis: genesisParams{
+			Timestamp: base.GenesisBlock.Header.Timestamp.Unix(),
+		},
+
+		PowLimitBits:             base.PowLimitBits,
+		ReduceMinDifficulty:      base.ReduceMinDifficulty,
+		MinDiffReductionTime:     duration(base.MinDiffReductionTime),
+		GenerateSupported:        base.GenerateSupported,
+		MaximumBlockSizes:        append([]int(nil), base.MaximumBlockSizes...),
+		MaxTxSize:                base.MaxTxSize,
+		TargetTimePerBlock:       duration(base.TargetTimePerBlock),
+		WorkDiffAlpha:            base.WorkDiffAlpha,
+		WorkDiffWindowSize:       base.WorkDiffWindowSize,
+		WorkDiffWindows:          base.WorkDiffWindows,
+		RetargetAdjustmentFactor: base.RetargetAdjustmentFactor,
+
+		BaseSubsidy:              base.BaseSubsidy,
+		MulSubsidy:               base.MulSubsidy,
+		DivSubsidy:               base.DivSubsidy,
+		SubsidyReductionInterval: base.SubsidyReductionInterval,
+		WorkRewardProportion:     base.WorkRewardProportion,
+		StakeRewardProportion:    base.StakeRewardProportion,
+		BlockTaxProportion:       base.BlockTaxProportion,
+
+		RuleChangeActivationQuorum:     base.RuleChangeActivationQuorum,
+		RuleChangeActivationMultiplier: base.RuleChangeActivationMultiplier,
+		RuleChangeActivationDivisor:    base.RuleChangeActivationDivisor,
+		RuleChangeActivationInterval:   base.RuleChangeActivationInterval,
+		BlockEnforceNumRequired:        base.BlockEnforceNumRequired,
+		BlockRejectNumRequired:         base.BlockRejectNumRequired,
+		BlockUpgradeNumToCheck:         base.BlockUpgradeNumToCheck,
+
+		RelayNonStdTxs: base.RelayNonStdTxs,
+
+		NetworkAddressPrefix: base.NetworkAddressPrefix,
+		PubKeyAddrID:         base.PubKeyAddrID[:],
+		PubKeyBlissAddrID:    base.PubKeyBlissAddrID[:],
+		PubKeyHashAddrID:     base.PubKeyHashAddrID[:],
+		PKHEdwardsAddrID:     base.PKHEdwardsAddrID[:],
+		PKHSchnorrAddrID:     base.PKHSchnorrAddrID[:],
+		PKHBlissAddrID:       base.PKHBlissAddrID[:],
+		ScriptHashAddrID:     base.ScriptHashAddrID[:],
+		PrivateKeyID:         base.PrivateKeyID[:],
+		HDPrivateKeyID:       base.HDPrivateKeyID[:],
+		HDPublicKeyID:        base.HDPublicKeyID[:],
+		HDCoinType:           base.HDCoinType,
+
+		MinimumStakeDiff:        base.MinimumStakeDiff,
+		TicketPoolSize:          base.TicketPoolSize,
+		TicketsPerBlock:         base.TicketsPerBlock,
+		TicketMaturity:          base.TicketMaturity,
+		TicketExpiry:            base.TicketExpiry,
+		CoinbaseMaturity:        base.CoinbaseMaturity,
+		SStxChangeMaturity:      base.SStxChangeMaturity,
+		TicketPoolSizeWeight:    base.TicketPoolSizeWeight,
+		StakeDiffAlpha:          base.StakeDiffAlpha,
+		StakeDiffWindowSize:     base.StakeDiffWindowSize,
+		StakeDiffWindows:        base.StakeDiffWindows,
+		StakeVersionInterval:    base.StakeVersionInterval,
+		MaxFreshStakePerBlock:   base.MaxFreshStakePerBlock,
+		StakeEnabledHeight:      base.StakeEnabledHeight,
+		StakeValidationHeight:   base.StakeValidationHeight,
+		StakeBaseSigScript:      append(hexBytes(nil), base.StakeBaseSigScript...),
+		StakeMajorityMultiplier: base.StakeMajorityMultiplier,
+		StakeMajorityDivisor:    base.StakeMajorityDivisor,
+
+		OrganizationPkScript:        append(hexBytes(nil), base.OrganizationPkScript...),
+		OrganizationPkScriptVersion: base.OrganizationPkScriptVersion,
+		BlockOneLedger:              ledger,
+	}
+}
+
+// copyID copies the passed decoded identifier into dst and returns an error
+// naming the field when it is not exactly the length of dst.
+func copyID(dst []byte, src hexBytes, field string) error {
+	if len(src) != len(dst) {
+		return fmt.Errorf("%s must be %d bytes, got %d", field,
+			len(dst), len(src))
+	}
+	copy(dst, src)
+	return nil
+}
+
+// compactToBig converts a compact representation of a whole number N to an
+// unsigned 32-bit number.  It is a copy of blockchain.CompactToBig since this
+// package can't depend on blockchain.
+func compactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	isNegative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
+
+	var bn *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		bn = big.NewInt(int64(mantissa))
+	} else {
+		bn = big.NewInt(int64(mantissa))
+		bn.Lsh(bn, 8*(exponent-3))
+	}
+
+	if isNegative {
+		bn = bn.Neg(bn)
+	}
+
+	return bn
+}
+
+// genesisCoinbaseScript returns the signature script for the coinbase of a
+// generated genesis block.  It mirrors the standard networks by committing to
+// the difficulty bits followed by the passed message.
+func genesisCoinbaseScript(message string) []byte {
+	script := []byte{0x04, 0xff, 0xff, 0x00, 0x1d, 0x01, 0x04}
+	if len(message) > 0 {
+		script = append(script, byte(len(message)))
+		script = append(script, message...)
+	}
+	return script
+}
+
+// newGenesisBlock generates a genesis block with a single coinbase that embeds
+// the passed message and pays to the same unspendable script as the standard
+// networks.
+func newGenesisBlock(message string, timestamp time.Time, bits uint32) *wire.MsgBlock {
+	coinbaseTx := &wire.MsgTx{
+		SerType: wire.TxSerializeFull,
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{
+				Hash:  chainhash.Hash{},
+				Index: 0xffffffff,
+				Tree:  0,
+			},
+			SignatureScript: genesisCoinbaseScript(message),
+			Sequence:        0xffffffff,
+			BlockHeight:     wire.NullBlockHeight,
+			BlockIndex:      wire.NullBlockIndex,
+			ValueIn:         wire.NullValueIn,
+		}},
+		TxOut: []*wire.TxOut{{
+			Version:  0x0000,
+			Value:    0x00000000,
+			PkScript: genesisCoinbaseTx.TxOut[0].PkScript,
+		}},
+		LockTime: 0,
+		Expiry:   0,
+	}
+
+	return &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    1,
+			MerkleRoot: coinbaseTx.TxHashFull(),
+			Timestamp:  timestamp,
+			Bits:       bits,
+		},
+		Transactions:  []*wire.MsgTx{coinbaseTx},
+		STransactions: []*wire.MsgTx{},
+	}
+}
+
+// toParams converts the file into network parameters, generating the genesis
+// block and deriving the proof-of-work limit and retarget timespan.
+func (f *netParamsFile) toParams() (*Params, error) {
+	message := f.Genesis.Message
+	if message == "" {
+		message = f.Name
+	}
+	if len(message) > maxGenesisMessageLen {
+		return nil, fmt.Errorf("genesis message must not be longer "+
+			"than %d bytes", maxGenesisMessageLen)
+	}
+	genesis := newGenesisBlock(message, time.Unix(f.Genesis.Timestamp, 0),
+		f.PowLimitBits)
+	genesisHash := genesis.BlockHash()
+	targetTimespan := time.Duration(f.TargetTimePerBlock) *
+		time.Duration(f.WorkDiffWindowSize)
+
+	p := &Params{
+		Name:        f.Name,
+		Net:         wire.CurrencyNet(f.Net),
+		DefaultPort: f.DefaultPort,
+		DNSSeeds:    f.DNSSeeds,
+
+		GenesisBlock:             genesis,
+		GenesisHash:              &genesisHash,
+		PowLimit:                 compactToBig(f.PowLimitBits),
+		PowLimitBits:             f.PowLimitBits,
+		ReduceMinDifficulty:      f.ReduceMinDifficulty,
+		MinDiffReductionTime:     time.Duration(f.MinDiffReductionTime),
+		GenerateSupported:        f.GenerateSupported,
+		MaximumBlockSizes:        f.MaximumBlockSizes,
+		MaxTxSize:                f.MaxTxSize,
+		TargetTimePerBlock:       time.Duration(f.TargetTimePerBlock),
+		WorkDiffAlpha:            f.WorkDiffAlpha,
+		WorkDiffWindowSize:       f.WorkDiffWindowSize,
+		WorkDiffWindows:          f.WorkDiffWindows,
+		TargetTimespan:           targetTimespan,
+		RetargetAdjustmentFactor: f.RetargetAdjustmentFactor,
+
+		BaseSubsidy:              f.BaseSubsidy,
+		MulSubsidy:               f.MulSubsidy,
+		DivSubsidy:               f.DivSubsidy,
+		SubsidyReductionInterval: f.SubsidyReductionInterval,
+		WorkRewardProportion:     f.WorkRewardProportion,
+		StakeRewardProportion:    f.StakeRewardProportion,
+		BlockTaxProportion:       f.BlockTaxProportion,
+
+		RuleChangeActivationQuorum:     f.RuleChangeActivationQuorum,
+		RuleChangeActivationMultiplier: f.RuleChangeActivationMultiplier,
+		RuleChangeActivationDivisor:    f.RuleChangeActivationDivisor,
+		RuleChangeActivationInterval:   f.RuleChangeActivationInterval,
+		Deployments:                    f.Deployments,
+		BlockEnforceNumRequired:        f.BlockEnforceNumRequired,
+		BlockRejectNumRequired:         f.BlockRejectNumRequired,
+		BlockUpgradeNumToCheck:         f.BlockUpgradeNumToCheck,
+
+		RelayNonStdTxs: f.RelayNonStdTxs,
+
+		NetworkAddressPrefix: f.NetworkAddressPrefix,
+		HDCoinType:           f.HDCoinType,
+
+		MinimumStakeDiff:        f.MinimumStakeDiff,
+		TicketPoolSize:          f.TicketPoolSize,
+		TicketsPerBlock:         f.TicketsPerBlock,
+		TicketMaturity:          f.TicketMaturity,
+		TicketExpiry:            f.TicketExpiry,
+		CoinbaseMaturity:        f.CoinbaseMaturity,
+		SStxChangeMaturity:      f.SStxChangeMaturity,
+		TicketPoolSizeWeight:    f.TicketPoolSizeWeight,
+		StakeDiffAlpha:          f.StakeDiffAlpha,
+		StakeDiffWindowSize:     f.StakeDiffWindowSize,
+		StakeDiffWindows:        f.StakeDiffWindows,
+		StakeVersionInterval:    f.StakeVersionInterval,
+		MaxFreshStakePerBlock:   f.MaxFreshStakePerBlock,
+		StakeEnabledHeight:      f.StakeEnabledHeight,
+		StakeValidationHeight:   f.StakeValidationHeight,
+		StakeBaseSigScript:      f.StakeBaseSigScript,
+		StakeMajorityMultiplier: f.StakeMajorityMultiplier,
+		StakeMajorityDivisor:    f.StakeMajorityDivisor,
+
+		OrganizationPkScript:        f.OrganizationPkScript,
+		OrganizationPkScriptVersion: f.OrganizationPkScriptVersion,
+		BlockOneLedger:              f.BlockOneLedger,
+	}
+
+	ids := []struct {
+		dst   []byte
+		src   hexBytes
+		field string
+	}{
+		{p.PubKeyAddrID[:], f.PubKeyAddrID, "pubkeyaddrid"},
+		{p.PubKeyBlissAddrID[:], f.PubKeyBlissAddrID, "pubkeyblissaddrid"},
+		{p.PubKeyHashAddrID[:], f.PubKeyHashAddrID, "pubkeyhashaddrid"},
+		{p.PKHEdwardsAddrID[:], f.PKHEdwardsAddrID, "pkhedwardsaddrid"},
+		{p.PKHSchnorrAddrID[:], f.PKHSchnorrAddrID, "pkhschnorraddrid"},
+		{p.PKHBlissAddrID[:], f.PKHBlissAddrID, "pkhblissaddrid"},
+		{p.ScriptHashAddrID[:], f.ScriptHashAddrID, "scripthashaddrid"},
+		{p.PrivateKeyID[:], f.PrivateKeyID, "privatekeyid"},
+		{p.HDPrivateKeyID[:], f.HDPrivateKeyID, "hdprivatekeyid"},
+		{p.HDPublicKeyID[:], f.HDPublicKeyID, "hdpublickeyid"},
+	}
+	for _, id := range ids {
+		if err := copyID(id.dst, id.src, id.field); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// validateParams performs sanity checks on network parameters that were loaded
+// from a file so that obviously broken networks are rejected before any
+// subsystem attempts to use them.
+func validateParams(p *Params) error {
+	switch {
+	case p.Name == "":
+		return errors.New("name must be specified")
+	case standardNets[p.Name] != nil:
+		return fmt.Errorf("name %q is reserved for a standard network",
+			p.Name)
+	case p.Net == 0:
+		return errors.New("net must be specified")
+	case p.NetworkAddressPrefix == "":
+		return errors.New("networkaddressprefix must be specified")
+	case p.PowLimit.Sign() <= 0:
+		return errors.New("powlimitbits must encode a positive value")
+	case len(p.MaximumBlockSizes) == 0:
+		return errors.New("maximumblocksizes must not be empty")
+	case p.TargetTimePerBlock <= 0:
+		return errors.New("targettimeperblock must be positive")
+	case p.WorkDiffWindowSize <= 0 || p.WorkDiffWindows <= 0:
+		return errors.New("workdiff windows must be positive")
+	case p.StakeDiffWindowSize <= 0 || p.StakeDiffWindows <= 0:
+		return errors.New("stakediff windows must be positive")
+	case p.MulSubsidy <= 0 || p.DivSubsidy <= 0:
+		return errors.New("mulsubsidy and divsubsidy must be positive")
+	case p.SubsidyReductionInterval <= 0:
+		return errors.New("subsidyreductioninterval must be positive")
+	case p.TotalSubsidyProportions() == 0:
+		return errors.New("subsidy proportions must not all be zero")
+	case p.TicketPoolSize == 0 || p.TicketsPerBlock == 0:
+		return errors.New("ticketpoolsize and ticketsperblock must be " +
+			"positive")
+	case p.StakeEnabledHeight < int64(p.CoinbaseMaturity)+
+		int64(p.TicketMaturity):
+		return errors.New("stakeenabledheight must be at least " +
+			"coinbasematurity + ticketmaturity")
+	case p.StakeValidationHeight < p.StakeEnabledHeight:
+		return errors.New("stakevalidationheight must not be below " +
+			"stakeenabledheight")
+	case p.StakeVersionInterval <= 0:
+		return errors.New("stakeversioninterval must be positive")
+	case p.StakeMajorityDivisor <= 0 || p.RuleChangeActivationDivisor == 0:
+		return errors.New("majority divisors must be positive")
+	case p.RuleChangeActivationInterval == 0:
+		return errors.New("rulechangeactivationinterval must be positive")
+	}
+
+	if _, err := strconv.ParseUint(p.DefaultPort, 10, 16); err != nil {
+		return fmt.Errorf("invalid defaultport %q", p.DefaultPort)
+	}
+
+	for _, payout := range p.BlockOneLedger {
+		if payout == nil || payout.Amount <= 0 {
+			return errors.New("blockoneledger amounts must be positive")
+		}
+		if !strings.HasPrefix(payout.Address, p.NetworkAddressPrefix) {
+			return fmt.Errorf("blockoneledger address %s is not for "+
+				"network prefix %s", payout.Address,
+				p.NetworkAddressPrefix)
+		}
+	}
+
+	for version, deployments := range p.Deployments {
+		index, err := validateDeployments(deployments)
+		if err != nil {
+			return fmt.Errorf("invalid agenda version %v id %v: %v",
+				version, deployments[index].Vote.Id, err)
+		}
+		for _, deployment := range deployments {
+			if err := validateAgenda(deployment.Vote); err != nil {
+				return fmt.Errorf("invalid agenda version %v "+
+					"id %v: %v", version,
+					deployment.Vote.Id, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParamsFromJSON builds the parameters for a custom network from the passed
+// JSON encoded parameters file.  The returned parameters are not registered.
+//
+// The optional base field selects one of the standard networks (mainnet,
+// testnet2, simnet, or regnet; simnet by default) whose values are used for every
+// field the file does not specify.  The name and net fields are required and
+// must not clash with a standard network.  Durations are encoded as strings
+// such as "2m30s", and address and key identifiers, scripts and the stake base
+// signature script are encoded as hex.  The deployments field, when present,
+// replaces the deployments of the base network entirely.
+//
+// A new genesis block is generated from the genesis timestamp and message
+// (which defaults to the network name) along with powlimitbits, so every
+// custom network has a distinct genesis hash.  Checkpoints and the assumed
+// valid block of the base network never carry over.
+func ParamsFromJSON(data []byte) (*Params, error) {
+	var hdr struct {
+		Base string `json:"base"`
+	}
+	if err := json.Unmarshal(data, &hdr); err != nil {
+		return nil, err
+	}
+	baseName := hdr.Base
+	if baseName == "" {
+		baseName = SimNetParams.Name
+	}
+	base, ok := standardNets[baseName]
+	if !ok {
+		return nil, fmt.Errorf("unknown base network %q", hdr.Base)
+	}
+
+	f := newNetParamsFile(base)
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, err
+	}
+	if f.Deployments == nil {
+		f.Deployments = base.Deployments
+	}
+
+	p, err := f.toParams()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateParams(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadParamsFile reads and builds the custom network parameters described by
+// the JSON file at path and registers them so that addresses and extended keys
+// for the network can be decoded.  See ParamsFromJSON for the file format.
+func LoadParamsFile(path string) (*Params, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ParamsFromJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := Register(p); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return p, nil
+}
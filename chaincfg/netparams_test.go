@@ -0,0 +1,208 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// privNetJSON is a custom network parameters file based on simnet that
+// overrides a representative subset of the available fields.
+const privNetJSON = `{
+	"name": "privnet",
+	"net": 3735928559,
+	"defaultport": "15008",
+	"genesis": {"timestamp": 1514764800, "message": "private network"},
+	"targettimeperblock": "5s",
+	"maximumblocksizes": [2000000],
+	"ticketpoolsize": 32,
+	"ticketsperblock": 3,
+	"stakevalidationheight": 80,
+	"networkaddressprefix": "P",
+	"pubkeyhashaddrid": "0d2a",
+	"scripthashaddrid": "0d0c",
+	"hdprivatekeyid": "0420b904",
+	"hdpublickeyid": "0420bd3e",
+	"deployments": {
+		"5": [{
+			"vote": {
+				"id": "testagenda",
+				"description": "test agenda",
+				"mask": 6,
+				"choices": [
+					{"id": "abstain", "bits": 0, "isabstain": true},
+					{"id": "no", "bits": 2, "isno": true},
+					{"id": "yes", "bits": 4}
+				]
+			},
+			"starttime": 0,
+			"expiretime": 9223372036854775807
+		}]
+	},
+	"blockoneledger": [
+		{"address": "PsQ7Q8ZJb1nGLyJzrVTPnqY5KNw9rvTLUwi", "amount": 500000000000}
+	]
+}`
+
+// TestParamsFromJSON ensures custom network parameters are built from a file
+// with unspecified fields inherited from the base network.
+func TestParamsFromJSON(t *testing.T) {
+	simNetBlockSizes := append([]int(nil), SimNetParams.MaximumBlockSizes...)
+
+	p, err := ParamsFromJSON([]byte(privNetJSON))
+	if err != nil {
+		t.Fatalf("ParamsFromJSON: unexpected error: %v", err)
+	}
+
+	// Ensure the overridden values are used.
+	if p.Name != "privnet" || p.Net != 0xdeadbeef || p.DefaultPort != "15008" {
+		t.Fatalf("unexpected network identity %s/%v/%s", p.Name, p.Net,
+			p.DefaultPort)
+	}
+	if p.TargetTimePerBlock != 5*time.Second {
+		t.Fatalf("unexpected target time per block %v",
+			p.TargetTimePerBlock)
+	}
+	wantTimespan := 5 * time.Second * time.Duration(p.WorkDiffWindowSize)
+	if p.TargetTimespan != wantTimespan {
+		t.Fatalf("unexpected target timespan %v, want %v",
+			p.TargetTimespan, wantTimespan)
+	}
+	if p.TicketPoolSize != 32 || p.TicketsPerBlock != 3 ||
+		p.StakeValidationHeight != 80 {
+		t.Fatalf("unexpected stake parameters %d/%d/%d",
+			p.TicketPoolSize, p.TicketsPerBlock,
+			p.StakeValidationHeight)
+	}
+	if p.PubKeyHashAddrID != [2]byte{0x0d, 0x2a} ||
+		p.HDPublicKeyID != [4]byte{0x04, 0x20, 0xbd, 0x3e} {
+		t.Fatalf("unexpected address magics %x/%x", p.PubKeyHashAddrID,
+			p.HDPublicKeyID)
+	}
+	if len(p.Deployments) != 1 || len(p.Deployments[5]) != 1 ||
+		p.Deployments[5][0].Vote.Choices[1].IsNo != true {
+		t.Fatalf("unexpected deployments %+v", p.Deployments)
+	}
+	if p.BlockOneSubsidy() != 500000000000 {
+		t.Fatalf("unexpected block one subsidy %d", p.BlockOneSubsidy())
+	}
+
+	// Ensure unspecified values are inherited from simnet.
+	if p.TicketMaturity != SimNetParams.TicketMaturity ||
+		p.BaseSubsidy != SimNetParams.BaseSubsidy ||
+		p.PowLimitBits != SimNetParams.PowLimitBits ||
+		!reflect.DeepEqual(p.StakeBaseSigScript,
+			SimNetParams.StakeBaseSigScript) {
+		t.Fatal("unspecified parameters were not inherited from simnet")
+	}
+	if p.Checkpoints != nil || p.AssumeValid != SimNetParams.AssumeValid {
+		t.Fatal("checkpoints must not carry over from the base network")
+	}
+
+	// Ensure the base network was not modified.
+	if !reflect.DeepEqual(SimNetParams.MaximumBlockSizes, simNetBlockSizes) {
+		t.Fatalf("simnet block sizes modified: %v",
+			SimNetParams.MaximumBlockSizes)
+	}
+
+	// Ensure the generated genesis block commits to the parameters and
+	// is distinct from the base network.
+	genesis := p.GenesisBlock
+	if genesis.Header.Bits != p.PowLimitBits ||
+		genesis.Header.Timestamp.Unix() != 1514764800 {
+		t.Fatalf("unexpected genesis header %+v", genesis.Header)
+	}
+	if genesis.Header.MerkleRoot != genesis.Transactions[0].TxHashFull() {
+		t.Fatal("genesis merkle root does not commit to the coinbase")
+	}
+	hash := genesis.BlockHash()
+	if !p.GenesisHash.IsEqual(&hash) {
+		t.Fatalf("genesis hash mismatch - got %v, want %v",
+			p.GenesisHash, hash)
+	}
+	if !strings.Contains(string(genesis.Transactions[0].TxIn[0].SignatureScript),
+		"private network") {
+		t.Fatal("genesis coinbase does not contain the message")
+	}
+}
+
+// TestParamsFromJSONErrors ensures invalid custom network parameter files are
+// rejected.
+func TestParamsFromJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"malformed", `{"name": "x"`},
+		{"unknown base", `{"base": "devnet", "name": "x", "net": 1}`},
+		{"missing name", `{"net": 1}`},
+		{"standard name", `{"name": "simnet", "net": 1}`},
+		{"missing net", `{"name": "x"}`},
+		{"bad duration", `{"name": "x", "net": 1, "targettimeperblock": 5}`},
+		{"bad id length", `{"name": "x", "net": 1, "pubkeyhashaddrid": "0d"}`},
+		{"bad id hex", `{"name": "x", "net": 1, "privatekeyid": "zz00"}`},
+		{"bad port", `{"name": "x", "net": 1, "defaultport": "http"}`},
+		{"no tickets", `{"name": "x", "net": 1, "ticketsperblock": 0}`},
+		{"early svh", `{"name": "x", "net": 1, "stakevalidationheight": 1}`},
+		{"ledger prefix", `{"name": "x", "net": 1, "networkaddressprefix": "P"}`},
+		{"ledger amount", `{"name": "x", "net": 1, "blockoneledger": [
+			{"address": "Ss", "amount": 0}]}`},
+		{"long message", `{"name": "x", "net": 1, "genesis": {"message": "` +
+			strings.Repeat("a", maxGenesisMessageLen+1) + `"}}`},
+		{"bad agenda", `{"name": "x", "net": 1, "deployments": {"5": [{
+			"vote": {"id": "a", "mask": 6, "choices": [
+				{"id": "no", "bits": 2, "isno": true}]}}]}}`},
+	}
+
+	for _, test := range tests {
+		if _, err := ParamsFromJSON([]byte(test.json)); err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+}
+
+// TestLoadParamsFile ensures custom network parameters loaded from a file are
+// registered and can't be registered twice.
+func TestLoadParamsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netparams")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "privnet.json")
+	json := strings.Replace(privNetJSON, "3735928559", "3735928560", 1)
+	json = strings.Replace(json, `"0d2a"`, `"0d2b"`, 1)
+	if err := ioutil.WriteFile(path, []byte(json), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	p, err := LoadParamsFile(path)
+	if err != nil {
+		t.Fatalf("LoadParamsFile: unexpected error: %v", err)
+	}
+	if !IsPubKeyHashAddrID(p.PubKeyHashAddrID) {
+		t.Fatal("pubkey hash address id was not registered")
+	}
+	pub, err := HDPrivateKeyToPublicKeyID(p.HDPrivateKeyID[:])
+	if err != nil || !reflect.DeepEqual(pub, p.HDPublicKeyID[:]) {
+		t.Fatalf("unexpected hd public key id %x (%v)", pub, err)
+	}
+
+	_, err = LoadParamsFile(path)
+	if err == nil || !strings.Contains(err.Error(), ErrDuplicateNet.Error()) {
+		t.Fatalf("expected duplicate network error, got %v", err)
+	}
+
+	if _, err := LoadParamsFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatal("expected error loading missing file")
+	}
+}
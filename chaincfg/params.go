@@ -37,6 +37,10 @@ var (
 	// can have for the simulation test network.  It is the value 2^255 - 1.
 	simNetPowLimit = new(big.Int).Sub(new(big.Int).Lsh(bigOne, 255), bigOne)
 
+	// regNetPowLimit is the highest proof of work value a Hcd block
+	// can have for the regression test network.  It is the value 2^255 - 1.
+	regNetPowLimit = new(big.Int).Sub(new(big.Int).Lsh(bigOne, 255), bigOne)
+
 	VoteBitsNotFound = fmt.Errorf("vote bits not found")
 )
 
@@ -311,6 +315,13 @@ type Params struct {
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints []Checkpoint
 
+	// AssumeValid is the hash of a block which is assumed to be valid along
+	// with all of its ancestors.  Script validation is skipped for those
+	// ancestors which are buried under enough proof of work, while all of
+	// the other consensus rules are still enforced.  The zero hash disables
+	// the optimization.
+	AssumeValid chainhash.Hash
+
 	// These fields are related to voting on consensus rule changes as
 	// defined by BIP0009.
 	//
@@ -487,6 +498,12 @@ var MainNetParams = Params{
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: []Checkpoint{},
 
+	// The block assumed to be valid by default.  No block is shipped yet,
+	// so scripts are always validated unless --assumevalid specifies one.
+	// It should be set along with the first checkpoint, which provides a
+	// block that is known to be good.
+	AssumeValid: chainhash.Hash{},
+
 	// The miner confirmation window is defined as:
 	//   target proof of work timespan / target proof of work spacing
 	RuleChangeActivationQuorum:     4032, // 10 % of RuleChangeActivationInterval * TicketsPerBlock
@@ -597,6 +614,12 @@ var TestNet2Params = Params{
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: []Checkpoint{},
 
+	// The block assumed to be valid by default.  No block is shipped yet,
+	// so scripts are always validated unless --assumevalid specifies one.
+	// It should be set along with the first checkpoint, which provides a
+	// block that is known to be good.
+	AssumeValid: chainhash.Hash{},
+
 	// Consensus rule change deployments.
 	//
 	// The miner confirmation window is defined as:
@@ -708,6 +731,9 @@ var SimNetParams = Params{
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: nil,
 
+	// Scripts are always validated on the simulation test network.
+	AssumeValid: chainhash.Hash{},
+
 	// Consensus rule change deployments.
 	//
 	// The miner confirmation window is defined as:
@@ -834,6 +860,150 @@ var SimNetParams = Params{
 	BlockOneLedger:              BlockOneLedgerSimNet,
 }
 
+// RegNetParams defines the network parameters for the regression test network.
+// It is intended for testing consensus changes on a single node or a small set
+// of nodes.  Stake validation starts after a handful of blocks, and the state
+// of the deployments below may be forced active or failed instead of requiring
+// full voting windows.
+var RegNetParams = Params{
+	Name:        "regnet",
+	Net:         wire.RegTest,
+	DefaultPort: "18008",
+	DNSSeeds:    []string{}, // NOTE: There must NOT be any seeds.
+
+	// Chain parameters
+	GenesisBlock:             &regNetGenesisBlock,
+	GenesisHash:              &regNetGenesisHash,
+	PowLimit:                 regNetPowLimit,
+	PowLimitBits:             0x207fffff,
+	ReduceMinDifficulty:      false,
+	MinDiffReductionTime:     0, // Does not apply since ReduceMinDifficulty false
+	GenerateSupported:        true,
+	MaximumBlockSizes:        []int{1000000, 1310720},
+	MaxTxSize:                1000000,
+	TargetTimePerBlock:       time.Second,
+	WorkDiffAlpha:            1,
+	WorkDiffWindowSize:       8,
+	WorkDiffWindows:          4,
+	TargetTimespan:           time.Second * 8, // TimePerBlock * WindowSize
+	RetargetAdjustmentFactor: 4,
+
+	// Subsidy parameters.
+	BaseSubsidy:              50000000000,
+	MulSubsidy:               100,
+	DivSubsidy:               101,
+	SubsidyReductionInterval: 128,
+	WorkRewardProportion:     6,
+	StakeRewardProportion:    3,
+	BlockTaxProportion:       1,
+
+	// Checkpoints ordered from oldest to newest.
+	Checkpoints: nil,
+
+	// Scripts are always validated on the regression test network.
+	AssumeValid: chainhash.Hash{},
+
+	// Consensus rule change deployments.
+	//
+	// The miner confirmation window is defined as:
+	//   target proof of work timespan / target proof of work spacing
+	RuleChangeActivationQuorum:     160, // 10 % of RuleChangeActivationInterval * TicketsPerBlock
+	RuleChangeActivationMultiplier: 3,   // 75%
+	RuleChangeActivationDivisor:    4,
+	RuleChangeActivationInterval:   320, // 320 seconds
+	Deployments: map[uint32][]ConsensusDeployment{
+		4: {{
+			Vote: Vote{
+				Id:          VoteIDMaxBlockSize,
+				Description: "Change maximum allowed block size from 1MiB to 1.25MB",
+				Mask:        0x0006, // Bits 1 and 2
+				Choices: []Choice{{
+					Id:          "abstain",
+					Description: "abstain voting for change",
+					Bits:        0x0000,
+					IsAbstain:   true,
+					IsNo:        false,
+				}, {
+					Id:          "no",
+					Description: "reject changing max allowed block size",
+					Bits:        0x0002, // Bit 1
+					IsAbstain:   false,
+					IsNo:        true,
+				}, {
+					Id:          "yes",
+					Description: "accept changing max allowed block size",
+					Bits:        0x0004, // Bit 2
+					IsAbstain:   false,
+					IsNo:        false,
+				}},
+			},
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires
+		}},
+	},
+
+	// Enforce current block version once majority of the network has
+	// upgraded.
+	// 51% (51 / 100)
+	// Reject previous block versions once a majority of the network has
+	// upgraded.
+	// 75% (75 / 100)
+	BlockEnforceNumRequired: 51,
+	BlockRejectNumRequired:  75,
+	BlockUpgradeNumToCheck:  100,
+
+	// Mempool parameters
+	RelayNonStdTxs: true,
+
+	// Address encoding magics
+	NetworkAddressPrefix: "R",
+	PubKeyAddrID:         [2]byte{0x25, 0xe5}, // starts with Rk
+	PubKeyBlissAddrID:    [2]byte{0x0b, 0xe0},
+	PubKeyHashAddrID:     [2]byte{0x0e, 0x00}, // starts with Rs
+	PKHEdwardsAddrID:     [2]byte{0x0d, 0xe0}, // starts with Re
+	PKHSchnorrAddrID:     [2]byte{0x0d, 0xc2}, // starts with RS
+	PKHBlissAddrID:       [2]byte{0x0d, 0xd8}, // starts with Rb
+	ScriptHashAddrID:     [2]byte{0x0d, 0xdb}, // starts with Rc
+	PrivateKeyID:         [2]byte{0x22, 0xfe}, // starts with Pr
+
+	// BIP32 hierarchical deterministic extended key magics
+	HDPrivateKeyID: [4]byte{0xea, 0xb4, 0x04, 0x48}, // starts with rprv
+	HDPublicKeyID:  [4]byte{0xea, 0xb4, 0xf9, 0x87}, // starts with rpub
+
+	// BIP44 coin type used in the hierarchical deterministic path for
+	// address generation.
+	HDCoinType: uint32(1), // SLIP0044, Testnet (all coins)
+
+	// Hcd PoS parameters
+	MinimumStakeDiff:        20000,
+	TicketPoolSize:          64,
+	TicketsPerBlock:         5,
+	TicketMaturity:          4,
+	TicketExpiry:            384, // 6*TicketPoolSize
+	CoinbaseMaturity:        4,
+	SStxChangeMaturity:      1,
+	TicketPoolSizeWeight:    4,
+	StakeDiffAlpha:          1,
+	StakeDiffWindowSize:     8,
+	StakeDiffWindows:        8,
+	StakeVersionInterval:    8 * 2 * 7,
+	MaxFreshStakePerBlock:   20,    // 4*TicketsPerBlock
+	StakeEnabledHeight:      4 + 4, // CoinbaseMaturity + TicketMaturity
+	StakeValidationHeight:   16,    // StakeEnabledHeight + 2*TicketMaturity
+	StakeBaseSigScript:      []byte{0xDE, 0xAD, 0xBE, 0xEF},
+	StakeMajorityMultiplier: 3,
+	StakeMajorityDivisor:    4,
+
+	// Hcd organization related parameters
+	//
+	// The organization address is the same 3-of-3 P2SH script that is used
+	// on the simulation test network.  There is no block one ledger, so the
+	// first spendable funds are the proof-of-work rewards.
+	OrganizationPkScript:        hexDecode("a914cbb08d6ca783b533b2c7d24a51fbca92d937bf9987"),
+	OrganizationPkScriptVersion: 0,
+	BlockOneLedger:              nil,
+}
+
 var (
 	// ErrDuplicateNet describes an error where the parameters for a Hcd
 	// network could not be set due to the network already being a standard
@@ -1008,4 +1178,5 @@ func init() {
 	mustRegister(&MainNetParams)
 	mustRegister(&TestNet2Params)
 	mustRegister(&SimNetParams)
+	mustRegister(&RegNetParams)
 }
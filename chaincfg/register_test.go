@@ -66,6 +66,11 @@ func TestRegister(t *testing.T) {
 					params: &SimNetParams,
 					err:    ErrDuplicateNet,
 				},
+				{
+					name:   "duplicate regnet",
+					params: &RegNetParams,
+					err:    ErrDuplicateNet,
+				},
 			},
 			p2pkhMagics: []magicTest{
 				{
@@ -80,6 +85,10 @@ func TestRegister(t *testing.T) {
 					magic: SimNetParams.PubKeyHashAddrID,
 					valid: true,
 				},
+				{
+					magic: RegNetParams.PubKeyHashAddrID,
+					valid: true,
+				},
 				{
 					magic: mockNetParams.PubKeyHashAddrID,
 					valid: false,
@@ -102,6 +111,10 @@ func TestRegister(t *testing.T) {
 					magic: SimNetParams.ScriptHashAddrID,
 					valid: true,
 				},
+				{
+					magic: RegNetParams.ScriptHashAddrID,
+					valid: true,
+				},
 				{
 					magic: mockNetParams.ScriptHashAddrID,
 					valid: false,
@@ -127,6 +140,11 @@ func TestRegister(t *testing.T) {
 					want: SimNetParams.HDPublicKeyID[:],
 					err:  nil,
 				},
+				{
+					priv: RegNetParams.HDPrivateKeyID[:],
+					want: RegNetParams.HDPublicKeyID[:],
+					err:  nil,
+				},
 				{
 					priv: mockNetParams.HDPrivateKeyID[:],
 					err:  ErrUnknownHDKeyID,
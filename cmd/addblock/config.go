@@ -14,6 +14,7 @@ import (
 	"github.com/coolsnady/hcd/chaincfg"
 	"github.com/coolsnady/hcd/database"
 	_ "github.com/coolsnady/hcd/database/ffldb"
+	_ "github.com/coolsnady/hcd/database/treapdb"
 	"github.com/coolsnady/hcd/wire"
 	"github.com/coolsnady/hcutil"
 	flags "github.com/jessevdk/go-flags"
@@ -40,6 +41,8 @@ type config struct {
 	DbType            string `long:"dbtype" description:"Database backend to use for the Block Chain"`
 	TestNet           bool   `long:"testnet" description:"Use the test network"`
 	SimNet            bool   `long:"simnet" description:"Use the simulation test network"`
+	RegNet            bool   `long:"regnet" description:"Use the regression test network"`
+	NetParams         string `long:"netparams" description:"Use the custom network described by the JSON parameters file"`
 	InFile            string `short:"i" long:"infile" description:"File containing the block(s)"`
 	NoExistsAddrIndex bool   `long:"noexistsaddrindex" description:"Do not build a full index of which addresses were ever seen on the blockchain"`
 	TxIndex           bool   `long:"txindex" description:"Build a full hash-based transaction index which makes all transactions available via the getrawtransaction RPC"`
@@ -119,9 +122,22 @@ func loadConfig() (*config, []string, error) {
 		numNets++
 		activeNetParams = &chaincfg.SimNetParams
 	}
+	if cfg.RegNet {
+		numNets++
+		activeNetParams = &chaincfg.RegNetParams
+	}
+	if cfg.NetParams != "" {
+		numNets++
+		activeNetParams, err = chaincfg.LoadParamsFile(cfg.NetParams)
+		if err != nil {
+			err := fmt.Errorf("%s: %v", funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+	}
 	if numNets > 1 {
-		str := "%s: the testnet, regtest, and simnet params can't be " +
-			"used together -- choose one of the three"
+		str := "%s: the testnet, simnet, regnet, and netparams params " +
+			"can't be used together -- choose one of the four"
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		parser.WriteHelp(os.Stderr)
@@ -0,0 +1,172 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/coolsnady/hcd/chaincfg"
+	flags "github.com/jessevdk/go-flags"
+)
+
+const (
+	defaultNumBlocks = 10000
+	defaultHashRate  = "0:1"
+	defaultTickets   = "0:0"
+)
+
+var activeNetParams = &chaincfg.MainNetParams
+
+// config defines the configuration options for diffsim.
+//
+// See loadConfig for details on the configuration load process.
+type config struct {
+	// Network parameters.
+	TestNet   bool   `long:"testnet" description:"Use the test network parameters"`
+	SimNet    bool   `long:"simnet" description:"Use the simulation test network parameters"`
+	RegNet    bool   `long:"regnet" description:"Use the regression test network parameters"`
+	NetParams string `long:"netparams" description:"Use the custom network parameters described by the JSON parameters file"`
+
+	// Parameter overrides.
+	TargetTimePerBlock       time.Duration `long:"targettimeperblock" description:"Override the target time between blocks"`
+	WorkDiffAlpha            int64         `long:"workdiffalpha" description:"Override the stake weighting of the proof of work difficulty windows"`
+	WorkDiffWindowSize       int64         `long:"workdiffwindowsize" description:"Override the number of blocks of a proof of work difficulty window"`
+	WorkDiffWindows          int64         `long:"workdiffwindows" description:"Override the number of proof of work difficulty windows"`
+	RetargetAdjustmentFactor int64         `long:"retargetadjustmentfactor" description:"Override the maximum factor by which the proof of work difficulty changes per retarget"`
+	MinDiffReductionTime     time.Duration `long:"mindiffreductiontime" description:"Reduce the proof of work difficulty to the minimum once no block was found for this long"`
+	NoMinDiffReduction       bool          `long:"nomindiffreduction" description:"Disable the minimum proof of work difficulty reduction"`
+	StakeDiffWindowSize      int64         `long:"stakediffwindowsize" description:"Override the number of blocks between stake difficulty retargets"`
+	TicketPoolSize           uint16        `long:"ticketpoolsize" description:"Override the target ticket pool size in multiples of the tickets per block"`
+
+	// Replay of historical headers.
+	Headers string `long:"headers" description:"Replay the hex-encoded block headers, one per line starting at height 1, read from this file (- for stdin)"`
+
+	// Synthetic scenario.
+	Blocks   int64  `short:"n" long:"blocks" description:"Number of blocks of the synthetic scenario"`
+	HashRate string `long:"hashrate" description:"Hash rate of the synthetic scenario as comma-separated height:rate pairs, where the rate is the difficulty at which blocks are found in the target time"`
+	Tickets  string `long:"tickets" description:"Ticket purchases of the synthetic scenario as comma-separated height:tickets-per-block pairs"`
+	Seed     int64  `long:"seed" description:"Seed for random block times of the synthetic scenario; 0 uses the expected block times"`
+
+	// Output.
+	JSON bool `long:"json" description:"Output the series as a JSON array instead of CSV"`
+}
+
+// applyOverrides returns a copy of the passed network parameters with the
+// parameters overridden by the configuration.
+func (cfg *config) applyOverrides(params *chaincfg.Params) *chaincfg.Params {
+	p := *params
+	if cfg.TargetTimePerBlock != 0 {
+		p.TargetTimePerBlock = cfg.TargetTimePerBlock
+	}
+	if cfg.WorkDiffAlpha != 0 {
+		p.WorkDiffAlpha = cfg.WorkDiffAlpha
+	}
+	if cfg.WorkDiffWindowSize != 0 {
+		p.WorkDiffWindowSize = cfg.WorkDiffWindowSize
+	}
+	if cfg.WorkDiffWindows != 0 {
+		p.WorkDiffWindows = cfg.WorkDiffWindows
+	}
+	if cfg.RetargetAdjustmentFactor != 0 {
+		p.RetargetAdjustmentFactor = cfg.RetargetAdjustmentFactor
+	}
+	if cfg.MinDiffReductionTime != 0 {
+		p.ReduceMinDifficulty = true
+		p.MinDiffReductionTime = cfg.MinDiffReductionTime
+	}
+	if cfg.NoMinDiffReduction {
+		p.ReduceMinDifficulty = false
+	}
+	if cfg.StakeDiffWindowSize != 0 {
+		p.StakeDiffWindowSize = cfg.StakeDiffWindowSize
+	}
+	if cfg.TicketPoolSize != 0 {
+		p.TicketPoolSize = cfg.TicketPoolSize
+	}
+
+	// The target timespan of a proof of work difficulty window follows
+	// from the target block time and the window size.
+	p.TargetTimespan = p.TargetTimePerBlock *
+		time.Duration(p.WorkDiffWindowSize)
+	return &p
+}
+
+// loadConfig initializes and parses the config using command line options.
+func loadConfig() (*config, *chaincfg.Params, error) {
+	// Default config.
+	cfg := config{
+		Blocks:   defaultNumBlocks,
+		HashRate: defaultHashRate,
+		Tickets:  defaultTickets,
+	}
+
+	// Parse command line options.
+	parser := flags.NewParser(&cfg, flags.Default)
+	_, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return nil, nil, err
+	}
+
+	// Multiple networks can't be selected simultaneously.
+	funcName := "loadConfig"
+	numNets := 0
+	if cfg.TestNet {
+		numNets++
+		activeNetParams = &chaincfg.TestNet2Params
+	}
+	if cfg.SimNet {
+		numNets++
+		activeNetParams = &chaincfg.SimNetParams
+	}
+	if cfg.RegNet {
+		numNets++
+		activeNetParams = &chaincfg.RegNetParams
+	}
+	if cfg.NetParams != "" {
+		numNets++
+		activeNetParams, err = chaincfg.LoadParamsFile(cfg.NetParams)
+		if err != nil {
+			err := fmt.Errorf("%s: %v", funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+	}
+	if numNets > 1 {
+		str := "%s: the testnet, simnet, regnet, and netparams params " +
+			"can't be used together -- choose one of the four"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	params := cfg.applyOverrides(activeNetParams)
+	if params.TargetTimePerBlock <= 0 || params.WorkDiffWindowSize <= 0 ||
+		params.WorkDiffWindows <= 0 || params.StakeDiffWindowSize <= 0 ||
+		params.RetargetAdjustmentFactor <= 0 {
+
+		str := "%s: the target time per block, difficulty window " +
+			"sizes, window count, and retarget adjustment factor " +
+			"must be positive"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+
+	if cfg.Headers == "" && cfg.Blocks <= 0 {
+		str := "%s: the number of blocks must be positive"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	return &cfg, params, nil
+}
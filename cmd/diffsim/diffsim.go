@@ -0,0 +1,400 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
+)
+
+// record describes the simulated difficulties of a single block.  The actual
+// difficulties are only set when replaying historical headers.
+type record struct {
+	Height            uint32   `json:"height"`
+	Timestamp         int64    `json:"timestamp"`
+	BlockTime         int64    `json:"blocktime"`
+	Bits              string   `json:"bits"`
+	Difficulty        float64  `json:"difficulty"`
+	TicketPrice       float64  `json:"ticketprice"`
+	PoolSize          uint32   `json:"poolsize"`
+	FreshStake        uint8    `json:"freshstake"`
+	ActualDifficulty  *float64 `json:"actualdifficulty,omitempty"`
+	ActualTicketPrice *float64 `json:"actualticketprice,omitempty"`
+}
+
+// recordWriter writes records in the configured output format.
+type recordWriter struct {
+	w       io.Writer
+	csv     *csv.Writer
+	replay  bool
+	written int
+}
+
+// newRecordWriter returns a record writer which writes CSV, or a JSON array
+// when asJSON is set, to w.  Replayed records include the actual difficulties.
+func newRecordWriter(w io.Writer, asJSON, replay bool) *recordWriter {
+	rw := &recordWriter{w: w, replay: replay}
+	if !asJSON {
+		rw.csv = csv.NewWriter(w)
+	}
+	return rw
+}
+
+// Write writes a single record.
+func (rw *recordWriter) Write(r *record) error {
+	defer func() { rw.written++ }()
+
+	if rw.csv == nil {
+		sep := ",\n"
+		if rw.written == 0 {
+			sep = "[\n"
+		}
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(rw.w, "%s%s", sep, b)
+		return err
+	}
+
+	if rw.written == 0 {
+		header := []string{"height", "timestamp", "blocktime", "bits",
+			"difficulty", "ticketprice", "poolsize", "freshstake"}
+		if rw.replay {
+			header = append(header, "actualdifficulty",
+				"actualticketprice")
+		}
+		if err := rw.csv.Write(header); err != nil {
+			return err
+		}
+	}
+	fields := []string{
+		strconv.FormatUint(uint64(r.Height), 10),
+		strconv.FormatInt(r.Timestamp, 10),
+		strconv.FormatInt(r.BlockTime, 10),
+		r.Bits,
+		strconv.FormatFloat(r.Difficulty, 'f', -1, 64),
+		strconv.FormatFloat(r.TicketPrice, 'f', -1, 64),
+		strconv.FormatUint(uint64(r.PoolSize), 10),
+		strconv.FormatUint(uint64(r.FreshStake), 10),
+	}
+	if rw.replay {
+		fields = append(fields,
+			strconv.FormatFloat(*r.ActualDifficulty, 'f', -1, 64),
+			strconv.FormatFloat(*r.ActualTicketPrice, 'f', -1, 64))
+	}
+	return rw.csv.Write(fields)
+}
+
+// Close finishes the output.
+func (rw *recordWriter) Close() error {
+	if rw.csv == nil {
+		end := "\n]\n"
+		if rw.written == 0 {
+			end = "[]\n"
+		}
+		_, err := io.WriteString(rw.w, end)
+		return err
+	}
+	rw.csv.Flush()
+	return rw.csv.Error()
+}
+
+// difficultyRatio returns the proof of work difficulty of the passed bits as a
+// multiple of the minimum difficulty of the network.
+func difficultyRatio(params *chaincfg.Params, bits uint32) float64 {
+	max := blockchain.CompactToBig(params.PowLimitBits)
+	target := blockchain.CompactToBig(bits)
+	diff, _ := new(big.Rat).SetFrac(max, target).Float64()
+	return diff
+}
+
+// newRecord returns the record for the passed header which was added to the
+// chain after the passed previous header.
+func newRecord(params *chaincfg.Params, header, prev *wire.BlockHeader) *record {
+	return &record{
+		Height:      header.Height,
+		Timestamp:   header.Timestamp.Unix(),
+		BlockTime:   header.Timestamp.Unix() - prev.Timestamp.Unix(),
+		Bits:        fmt.Sprintf("%08x", header.Bits),
+		Difficulty:  difficultyRatio(params, header.Bits),
+		TicketPrice: hcutil.Amount(header.SBits).ToCoin(),
+		PoolSize:    header.PoolSize,
+		FreshStake:  header.FreshStake,
+	}
+}
+
+// schedulePoint is a value which applies from a height on.
+type schedulePoint struct {
+	height int64
+	value  float64
+}
+
+// schedule is a series of values which each apply from a height until the
+// height of the next value.
+type schedule []schedulePoint
+
+// parseSchedule parses a schedule from comma-separated height:value pairs.
+// The values before the first height are zero.
+func parseSchedule(s string) (schedule, error) {
+	var sched schedule
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(pair), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed schedule entry %q -- "+
+				"expected height:value", pair)
+		}
+		height, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || height < 0 {
+			return nil, fmt.Errorf("invalid height in schedule "+
+				"entry %q", pair)
+		}
+		value, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || value < 0 || math.IsInf(value, 0) ||
+			math.IsNaN(value) {
+
+			return nil, fmt.Errorf("invalid value in schedule "+
+				"entry %q", pair)
+		}
+		sched = append(sched, schedulePoint{height, value})
+	}
+	sort.SliceStable(sched, func(i, j int) bool {
+		return sched[i].height < sched[j].height
+	})
+	return sched, nil
+}
+
+// valueAt returns the value of the schedule at the passed height.
+func (s schedule) valueAt(height int64) float64 {
+	var value float64
+	for _, p := range s {
+		if p.height > height {
+			break
+		}
+		value = p.value
+	}
+	return value
+}
+
+// simulate runs a synthetic scenario through the difficulty calculator and
+// writes a record for each block.
+//
+// The hash rate is expressed as the difficulty at which blocks are found in
+// the target time per block on average, so the expected block time is the
+// target time scaled by the ratio of the required difficulty to the hash rate.
+//
+// The ticket pool is modelled by maturing the tickets purchased in a block
+// after the ticket maturity and removing the tickets which vote once votes are
+// required.  Expired and missed tickets are not modelled.
+func simulate(cfg *config, params *chaincfg.Params, w *recordWriter) error {
+	hashRate, err := parseSchedule(cfg.HashRate)
+	if err != nil {
+		return fmt.Errorf("--hashrate: %v", err)
+	}
+	tickets, err := parseSchedule(cfg.Tickets)
+	if err != nil {
+		return fmt.Errorf("--tickets: %v", err)
+	}
+	var rng *rand.Rand
+	if cfg.Seed != 0 {
+		rng = rand.New(rand.NewSource(cfg.Seed))
+	}
+
+	calc := blockchain.NewDifficultyCalculator(params)
+	ticketMaturity := int64(params.TicketMaturity)
+	freshStake := make([]uint8, 0, cfg.Blocks+1)
+	freshStake = append(freshStake, 0)
+	var poolSize uint32
+	for height := int64(1); height <= cfg.Blocks; height++ {
+		prev := calc.Tip()
+
+		// Find the block after the time it takes on average at the hash
+		// rate.  Since the minimum difficulty reduction depends on the
+		// timestamp, the expected time at the current difficulty is
+		// used to determine the required difficulty first.
+		rate := hashRate.valueAt(height)
+		if rate <= 0 {
+			return fmt.Errorf("no hash rate at height %d", height)
+		}
+		scale := 1.0
+		if rng != nil {
+			scale = rng.ExpFloat64()
+		}
+		blockTime := func(bits uint32) time.Duration {
+			diff := difficultyRatio(params, bits)
+			secs := params.TargetTimePerBlock.Seconds() * diff /
+				rate * scale
+			if secs < 1 {
+				secs = 1
+			}
+			return time.Duration(secs) * time.Second
+		}
+		timestamp := prev.Timestamp.Add(blockTime(prev.Bits))
+		bits, err := calc.NextRequiredDifficulty(timestamp)
+		if err != nil {
+			return err
+		}
+		timestamp = prev.Timestamp.Add(blockTime(bits))
+		sbits, err := calc.NextRequiredStakeDifficulty()
+		if err != nil {
+			return err
+		}
+
+		// Tickets may only be purchased once the first coinbase
+		// matured.
+		var fresh uint8
+		if height > int64(params.CoinbaseMaturity) {
+			n := tickets.valueAt(height)
+			if n > float64(params.MaxFreshStakePerBlock) {
+				n = float64(params.MaxFreshStakePerBlock)
+			}
+			fresh = uint8(n)
+		}
+		freshStake = append(freshStake, fresh)
+
+		header := &wire.BlockHeader{
+			Version:    prev.Version,
+			Bits:       bits,
+			SBits:      sbits,
+			Height:     uint32(height),
+			Timestamp:  timestamp,
+			FreshStake: fresh,
+			PoolSize:   poolSize,
+		}
+		if err := calc.AddHeader(header); err != nil {
+			return err
+		}
+		if err := w.Write(newRecord(params, header, &prev)); err != nil {
+			return err
+		}
+
+		// Update the pool size for the next block.
+		if height > ticketMaturity {
+			poolSize += uint32(freshStake[height-ticketMaturity])
+		}
+		if height >= params.StakeValidationHeight {
+			votes := uint32(params.TicketsPerBlock)
+			if votes > poolSize {
+				votes = poolSize
+			}
+			poolSize -= votes
+		}
+	}
+	return nil
+}
+
+// replay runs the hex-encoded headers read from r through the difficulty
+// calculator and writes a record for each header with both the simulated and
+// the actual difficulties.  The headers keep their timestamps, ticket
+// purchases, and pool sizes while their difficulties are replaced by the
+// simulated ones.
+func replay(r io.Reader, params *chaincfg.Params, w *recordWriter) error {
+	calc := blockchain.NewDifficultyCalculator(params)
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		b, err := hex.DecodeString(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		var header wire.BlockHeader
+		if err := header.FromBytes(b); err != nil {
+			return fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		if header.Height == 0 {
+			continue
+		}
+
+		prev := calc.Tip()
+		bits, err := calc.NextRequiredDifficulty(header.Timestamp)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		sbits, err := calc.NextRequiredStakeDifficulty()
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		actualDiff := difficultyRatio(params, header.Bits)
+		actualPrice := hcutil.Amount(header.SBits).ToCoin()
+		header.Bits = bits
+		header.SBits = sbits
+		if err := calc.AddHeader(&header); err != nil {
+			return fmt.Errorf("line %d: %v", lineNum, err)
+		}
+
+		rec := newRecord(params, &header, &prev)
+		rec.ActualDifficulty = &actualDiff
+		rec.ActualTicketPrice = &actualPrice
+		if err := w.Write(rec); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// realMain is the real main function for the utility.  It is necessary to work
+// around the fact that deferred functions do not run when os.Exit() is called.
+func realMain() error {
+	// Load configuration and parse command line.
+	cfg, params, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	w := newRecordWriter(out, cfg.JSON, cfg.Headers != "")
+
+	if cfg.Headers != "" {
+		r := io.Reader(os.Stdin)
+		if cfg.Headers != "-" {
+			f, err := os.Open(cfg.Headers)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		err = replay(r, params, w)
+	} else {
+		err = simulate(cfg, params, w)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	if err := w.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	return nil
+}
+
+func main() {
+	if err := realMain(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/coolsnady/hcd/chaincfg"
 	"github.com/coolsnady/hcd/database"
 	_ "github.com/coolsnady/hcd/database/ffldb"
+	_ "github.com/coolsnady/hcd/database/treapdb"
 	"github.com/coolsnady/hcd/wire"
 	"github.com/coolsnady/hcutil"
 	flags "github.com/jessevdk/go-flags"
@@ -41,6 +42,8 @@ type config struct {
 	DbType        string `long:"dbtype" description:"Database backend to use for the Block Chain"`
 	TestNet       bool   `long:"testnet" description:"Use the test network"`
 	SimNet        bool   `long:"simnet" description:"Use the simulation test network"`
+	RegNet        bool   `long:"regnet" description:"Use the regression test network"`
+	NetParams     string `long:"netparams" description:"Use the custom network described by the JSON parameters file"`
 	NumCandidates int    `short:"n" long:"numcandidates" description:"Max num of checkpoint candidates to show {1-20}"`
 	UseGoOutput   bool   `short:"g" long:"gooutput" description:"Display the candidates using Go syntax that is ready to insert into the dcrchain checkpoint list"`
 }
@@ -106,9 +109,22 @@ func loadConfig() (*config, []string, error) {
 		numNets++
 		activeNetParams = &chaincfg.SimNetParams
 	}
+	if cfg.RegNet {
+		numNets++
+		activeNetParams = &chaincfg.RegNetParams
+	}
+	if cfg.NetParams != "" {
+		numNets++
+		activeNetParams, err = chaincfg.LoadParamsFile(cfg.NetParams)
+		if err != nil {
+			err := fmt.Errorf("%s: %v", funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+	}
 	if numNets > 1 {
-		str := "%s: the testnet, regtest, and simnet params can't be " +
-			"used together -- choose one of the three"
+		str := "%s: the testnet, simnet, regnet, and netparams params " +
+			"can't be used together -- choose one of the four"
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		parser.WriteHelp(os.Stderr)
@@ -13,8 +13,10 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/coolsnady/hcd/chaincfg"
 	"github.com/coolsnady/hcd/dcrjson"
 	"github.com/coolsnady/hcutil"
 
@@ -111,17 +113,32 @@ type config struct {
 	ProxyPass       string `long:"proxypass" default-mask:"-" description:"Password for proxy server"`
 	TestNet         bool   `long:"testnet" description:"Connect to testnet"`
 	SimNet          bool   `long:"simnet" description:"Connect to the simulation test network"`
+	RegNet          bool   `long:"regnet" description:"Connect to the regression test network"`
+	NetParams       string `long:"netparams" description:"Connect to the custom private network described by the JSON parameters file"`
 	TLSSkipVerify   bool   `long:"skipverify" description:"Do not verify tls certificates (not recommended!)"`
 	Wallet          bool   `long:"wallet" description:"Connect to wallet"`
+	CSV             bool   `long:"csv" description:"Print the result as CSV instead of JSON (getsubsidyschedule only)"`
 }
 
 // normalizeAddress returns addr with the passed default port appended if
-// there is not already a port specified.
-func normalizeAddress(addr string, useTestNet, useSimNet, useWallet bool) string {
+// there is not already a port specified.  The ports of a custom network are
+// derived from its default peer port when customNet is not nil.
+func normalizeAddress(addr string, useTestNet, useSimNet, useWallet bool,
+	customNet *chaincfg.Params) string {
+
 	_, _, err := net.SplitHostPort(addr)
 	if err != nil {
 		var defaultPort string
 		switch {
+		case customNet != nil:
+			// The default port was validated while loading the
+			// parameters.
+			port, _ := strconv.ParseUint(customNet.DefaultPort, 10, 16)
+			if useWallet {
+				defaultPort = strconv.FormatUint(port+2, 10)
+			} else {
+				defaultPort = strconv.FormatUint(port+1, 10)
+			}
 		case useTestNet:
 			if useWallet {
 				defaultPort = "12010"
@@ -269,9 +286,25 @@ func loadConfig() (*config, []string, error) {
 	if cfg.SimNet {
 		numNets++
 	}
+	var customNet *chaincfg.Params
+	if cfg.RegNet {
+		numNets++
+		customNet = &chaincfg.RegNetParams
+	}
+	if cfg.NetParams != "" {
+		numNets++
+		customNet, err = chaincfg.LoadParamsFile(
+			cleanAndExpandPath(cfg.NetParams))
+		if err != nil {
+			str := "%s: failed to load network parameters: %v"
+			err := fmt.Errorf(str, "loadConfig", err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+	}
 	if numNets > 1 {
-		str := "%s: the testnet and simnet params can't be used " +
-			"together -- choose one of the two"
+		str := "%s: the testnet, simnet, regnet, and netparams params " +
+			"can't be used together -- choose one of the four"
 		err := fmt.Errorf(str, "loadConfig")
 		fmt.Fprintln(os.Stderr, err)
 		return nil, nil, err
@@ -294,7 +327,7 @@ func loadConfig() (*config, []string, error) {
 	// Add default port to RPC server based on --testnet and --wallet flags
 	// if needed.
 	cfg.RPCServer = normalizeAddress(cfg.RPCServer, cfg.TestNet,
-		cfg.SimNet, cfg.Wallet)
+		cfg.SimNet, cfg.Wallet, customNet)
 
 	return &cfg, remainingArgs, nil
 }
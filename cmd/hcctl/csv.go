@@ -0,0 +1,56 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/coolsnady/hcd/dcrjson"
+)
+
+// csvFormatters maps the methods whose results may be printed as CSV with the
+// --csv option to the functions which convert their results to CSV records.
+var csvFormatters = map[string]func(json.RawMessage) ([][]string, error){
+	"getsubsidyschedule": subsidyScheduleRecords,
+}
+
+// subsidyScheduleRecords converts the result of the getsubsidyschedule command
+// to a header record followed by a record for each reduction interval.
+func subsidyScheduleRecords(result json.RawMessage) ([][]string, error) {
+	var schedule dcrjson.GetSubsidyScheduleResult
+	if err := json.Unmarshal(result, &schedule); err != nil {
+		return nil, err
+	}
+
+	records := make([][]string, 0, len(schedule.Intervals)+1)
+	records = append(records, []string{"interval", "startheight",
+		"endheight", "pow", "pos", "developer", "totalpow", "totalpos",
+		"totaldeveloper", "total", "supply"})
+	for _, interval := range schedule.Intervals {
+		values := []int64{interval.Interval, interval.StartHeight,
+			interval.EndHeight, interval.PoW, interval.PoS,
+			interval.Developer, interval.TotalPoW, interval.TotalPoS,
+			interval.TotalDeveloper, interval.Total, interval.Supply}
+		record := make([]string, len(values))
+		for i, value := range values {
+			record[i] = strconv.FormatInt(value, 10)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// writeCSV converts the result of the passed method to CSV records and writes
+// them to w.
+func writeCSV(w io.Writer, method string, result json.RawMessage) error {
+	records, err := csvFormatters[method](result)
+	if err != nil {
+		return err
+	}
+	return csv.NewWriter(w).WriteAll(records)
+}
@@ -73,6 +73,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Ensure the result of the method can be printed as CSV when requested.
+	if _, ok := csvFormatters[method]; cfg.CSV && !ok {
+		fmt.Fprintf(os.Stderr, "The '%s' command does not support CSV "+
+			"output\n", method)
+		os.Exit(1)
+	}
+
 	// Convert remaining command line args to a slice of interface values
 	// to be passed along as parameters to new command creation function.
 	//
@@ -142,6 +149,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Print the result as CSV when requested.
+	if cfg.CSV {
+		if err := writeCSV(os.Stdout, method, result); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to format result: %v\n",
+				err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Choose how to display the result based on its type.
 	strResult := string(result)
 	if strings.HasPrefix(strResult, "{") || strings.HasPrefix(strResult, "[") {
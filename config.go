@@ -23,11 +23,16 @@ import (
 
 	"github.com/btcsuite/btclog"
 	"github.com/btcsuite/go-socks/socks"
+	"github.com/coolsnady/hcd/addrmgr"
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
 	"github.com/coolsnady/hcd/connmgr"
 	"github.com/coolsnady/hcd/database"
 	_ "github.com/coolsnady/hcd/database/ffldb"
+	_ "github.com/coolsnady/hcd/database/treapdb"
 	"github.com/coolsnady/hcd/mempool"
 	"github.com/coolsnady/hcd/sampleconfig"
+	"github.com/coolsnady/hcd/wire"
 	"github.com/coolsnady/hcutil"
 	flags "github.com/jessevdk/go-flags"
 )
@@ -101,6 +106,8 @@ type config struct {
 	BanDuration          time.Duration `long:"banduration" description:"How long to ban misbehaving peers.  Valid time units are {s, m, h}.  Minimum 1 second"`
 	BanThreshold         uint32        `long:"banthreshold" description:"Maximum allowed ban score before disconnecting and banning misbehaving peers."`
 	Whitelists           []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
+	ASMap                string        `long:"asmap" description:"File containing an asmap used to group peers by the autonomous system which announces them rather than by /16 (relative paths are relative to the data directory)"`
+	MaxUploadTarget      uint64        `long:"maxuploadtarget" description:"Try to keep outbound traffic under the given target in MiB per 24h -- Historical blocks are no longer served to non-whitelisted peers once reached, and targets below the network minimum needed to serve them are raised to it (0 = no limit)"`
 	RPCUser              string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
 	RPCPass              string        `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
 	RPCLimitUser         string        `long:"rpclimituser" description:"Username for limited RPC connections"`
@@ -113,6 +120,9 @@ type config struct {
 	RPCMaxConcurrentReqs int           `long:"rpcmaxconcurrentreqs" description:"Max number of concurrent RPC requests that may be processed concurrently"`
 	DisableRPC           bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
 	DisableTLS           bool          `long:"notls" description:"Disable TLS for the RPC server -- NOTE: This is only allowed if the RPC server is bound to localhost"`
+	Electrum             bool          `long:"electrum" description:"Enable the Electrum protocol server for lightweight wallets -- NOTE: This enables the script hash index"`
+	ElectrumListeners    []string      `long:"electrumlisten" description:"Add an interface/port to listen for plain TCP Electrum connections (default port: 14011, testnet: 12011)"`
+	ElectrumTLSListeners []string      `long:"electrumtlslisten" description:"Add an interface/port to listen for TLS Electrum connections using the RPC certificate and key (default port: 14012, testnet: 12012)"`
 	DisableDNSSeed       bool          `long:"nodnsseed" description:"Disable DNS seeding for peers"`
 	ExternalIPs          []string      `long:"externalip" description:"Add an ip to the list of local addresses we claim to listen on to peers"`
 	Proxy                string        `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
@@ -125,8 +135,13 @@ type config struct {
 	TorIsolation         bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
 	TestNet              bool          `long:"testnet" description:"Use the test network"`
 	SimNet               bool          `long:"simnet" description:"Use the simulation test network"`
+	RegNet               bool          `long:"regnet" description:"Use the regression test network"`
+	NetParams            string        `long:"netparams" description:"Path to a JSON file describing the parameters of a custom private network to use"`
 	DisableCheckpoints   bool          `long:"nocheckpoints" description:"Disable built-in checkpoints.  Don't do this unless you know what you're doing."`
+	AssumeValid          string        `long:"assumevalid" description:"Hash of a block assumed to be valid along with all of its ancestors, which allows skipping script validation for the deeply buried ones -- Specify 0 to always validate scripts (default: none, no network ships a known good block yet)"`
+	ForceDeployments     []string      `long:"forcedeployment" description:"Force the state of a consensus deployment regardless of the votes on the regression test network -- Specified as '<agendaid>:<active|failed>'"`
 	DbType               string        `long:"dbtype" description:"Database backend to use for the Block Chain"`
+	BlockCompression     string        `long:"blockcompression" description:"Compress blocks stored in the block database {none, snappy} -- Only affects newly stored blocks and requires the ffldb backend"`
 	Profile              string        `long:"profile" description:"Enable HTTP profiling on given [addr:]port -- NOTE port must be between 1024 and 65536"`
 	CPUProfile           string        `long:"cpuprofile" description:"Write CPU profile to the specified file"`
 	MemProfile           string        `long:"memprofile" description:"Write mem profile to the specified file"`
@@ -134,6 +149,7 @@ type config struct {
 	MiningTimeOffset     int           `long:"miningtimeoffset" description:"Offset the mining timestamp of a block by this many seconds (positive values are in the past)"`
 	DebugLevel           string        `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
 	Upnp                 bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
+	NATPMP               bool          `long:"natpmp" description:"Use NAT-PMP or PCP to map our listening port outside of NAT -- Tried before UPnP when both are enabled"`
 	MinRelayTxFee        float64       `long:"minrelaytxfee" description:"The minimum transaction fee in HC/kB to be considered a non-zero fee."`
 	FreeTxRelayLimit     float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
 	NoRelayPriority      bool          `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
@@ -146,6 +162,7 @@ type config struct {
 	GetWorkKeys          []string      `long:"getworkkey" description:"DEPRECATED -- Use the --miningaddr option instead"`
 	NoPeerBloomFilters   bool          `long:"nopeerbloomfilters" description:"Disable bloom filtering support"`
 	SigCacheMaxSize      uint          `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache"`
+	BatchSigVerify       bool          `long:"batchsigverify" description:"Verify the Schnorr and Bliss signatures checked by the scripts of a block once all of the scripts have been executed, batching the Schnorr signatures, which is faster for blocks with many of them"`
 	NonAggressive        bool          `long:"nonaggressive" description:"Disable mining off of the parent block of the blockchain if there aren't enough voters"`
 	NoMiningStateSync    bool          `long:"nominingstatesync" description:"Disable synchronizing the mining state with other nodes"`
 	AllowOldVotes        bool          `long:"allowoldvotes" description:"Enable the addition of very old votes to the mempool"`
@@ -156,8 +173,18 @@ type config struct {
 	DropTxIndex          bool          `long:"droptxindex" description:"Deletes the hash-based transaction index from the database on start up and then exits."`
 	AddrIndex            bool          `long:"addrindex" description:"Maintain a full address-based transaction index which makes the searchrawtransactions RPC available"`
 	DropAddrIndex        bool          `long:"dropaddrindex" description:"Deletes the address-based transaction index from the database on start up and then exits."`
+	AddrUtxoIndex        bool          `long:"addrutxoindex" description:"Maintain an address balance and unspent output index which makes the getaddressutxos, getaddressbalance and getaddressdeltas RPCs available"`
+	DropAddrUtxoIndex    bool          `long:"dropaddrutxoindex" description:"Deletes the address balance and unspent output index from the database on start up and then exits."`
 	NoExistsAddrIndex    bool          `long:"noexistsaddrindex" description:"Disable the exists address index, which tracks whether or not an address has even been used."`
 	DropExistsAddrIndex  bool          `long:"dropexistsaddrindex" description:"Deletes the exists address index from the database on start up and then exits."`
+	SpendIndex           bool          `long:"spendindex" description:"Maintain a spent outpoint index which makes the gettxspendingprevout and getspentinfo RPCs report spends in the main chain"`
+	DropSpendIndex       bool          `long:"dropspendindex" description:"Deletes the spent outpoint index from the database on start up and then exits."`
+	TicketHistIndex      bool          `long:"tickethistoryindex" description:"Maintain a ticket history index which makes the getticketinfo and listticketsbystatus RPCs available"`
+	DropTicketHistIndex  bool          `long:"droptickethistoryindex" description:"Deletes the ticket history index from the database on start up and then exits."`
+	BlockStatsIndex      bool          `long:"blockstatsindex" description:"Maintain a block statistics index which caches the results of the getblockstats RPC"`
+	DropBlockStatsIndex  bool          `long:"dropblockstatsindex" description:"Deletes the block statistics index from the database on start up and then exits."`
+	ScriptHashIndex      bool          `long:"scripthashindex" description:"Maintain a script hash history index which is used by the Electrum protocol server"`
+	DropScriptHashIndex  bool          `long:"dropscripthashindex" description:"Deletes the script hash history index from the database on start up and then exits."`
 	PipeRx               uint          `long:"piperx" description:"File descriptor of read end pipe to enable parent -> child process communication"`
 	PipeTx               uint          `long:"pipetx" description:"File descriptor of write end pipe to enable parent <- child process communication"`
 	LifetimeEvents       bool          `long:"lifetimeevents" description:"Send lifetime notifications over the TX pipe"`
@@ -167,7 +194,11 @@ type config struct {
 	dial                 func(string, string) (net.Conn, error)
 	miningAddrs          []hcutil.Address
 	minRelayTxFee        hcutil.Amount
+	blockCompression     database.BlockCompression
+	assumeValid          chainhash.Hash
+	forcedDeployments    map[string]blockchain.ThresholdState
 	whitelists           []*net.IPNet
+	asmap                *addrmgr.ASMap
 }
 
 // serviceOptions defines the configuration options for the daemon as a service on
@@ -306,6 +337,35 @@ func normalizeAddresses(addrs []string, defaultPort string) []string {
 	return removeDuplicateAddresses(addrs)
 }
 
+// parseForcedDeploymentState returns the threshold state a deployment is
+// forced to for the passed name, which must be either active or failed.
+func parseForcedDeploymentState(name string) (blockchain.ThresholdState, error) {
+	switch name {
+	case "active":
+		return blockchain.ThresholdActive, nil
+	case "failed":
+		return blockchain.ThresholdFailed, nil
+	}
+	return blockchain.ThresholdInvalid, fmt.Errorf("invalid forced "+
+		"deployment state '%s' -- expected active or failed", name)
+}
+
+// parseForcedDeployment parses a forced deployment of the form
+// <agendaid>:<active|failed> into the agenda id and its forced state.
+func parseForcedDeployment(forced string) (string, blockchain.ThresholdState, error) {
+	parts := strings.Split(forced, ":")
+	if len(parts) != 2 || parts[0] == "" {
+		return "", blockchain.ThresholdInvalid, fmt.Errorf("invalid "+
+			"forced deployment '%s' -- expected "+
+			"<agendaid>:<active|failed>", forced)
+	}
+	state, err := parseForcedDeploymentState(parts[1])
+	if err != nil {
+		return "", blockchain.ThresholdInvalid, err
+	}
+	return parts[0], state, nil
+}
+
 // filesExists reports whether the named file or directory exists.
 func fileExists(name string) bool {
 	if _, err := os.Stat(name); err != nil {
@@ -491,7 +551,8 @@ func loadConfig() (*config, []string, error) {
 
 	// Create a default config file when one does not exist and the user did
 	// not specify an override.
-	if !preCfg.SimNet && preCfg.ConfigFile == defaultConfigFile &&
+	if !preCfg.SimNet && !preCfg.RegNet &&
+		preCfg.ConfigFile == defaultConfigFile &&
 		!fileExists(preCfg.ConfigFile) {
 
 		err := createDefaultConfigFile(preCfg.ConfigFile)
@@ -504,7 +565,7 @@ func loadConfig() (*config, []string, error) {
 	// Load additional config from file.
 	var configFileError error
 	parser := newConfigParser(&cfg, &serviceOpts, flags.Default)
-	if !cfg.SimNet || preCfg.ConfigFile != defaultConfigFile {
+	if !(cfg.SimNet || cfg.RegNet) || preCfg.ConfigFile != defaultConfigFile {
 		err := flags.NewIniParser(parser).ParseFile(preCfg.ConfigFile)
 		if err != nil {
 			if _, ok := err.(*os.PathError); !ok {
@@ -561,9 +622,27 @@ func loadConfig() (*config, []string, error) {
 		activeNetParams = &simNetParams
 		cfg.DisableDNSSeed = true
 	}
+	if cfg.RegNet {
+		numNets++
+		// Also disable dns seeding on the regression test network.
+		activeNetParams = &regNetParams
+		cfg.DisableDNSSeed = true
+	}
+	if cfg.NetParams != "" {
+		numNets++
+		cfg.NetParams = cleanAndExpandPath(cfg.NetParams)
+		netParams, err := loadCustomNetParams(cfg.NetParams)
+		if err != nil {
+			str := "%s: failed to load network parameters: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+		activeNetParams = netParams
+	}
 	if numNets > 1 {
-		str := "%s: the testnet and simnet params can't be " +
-			"used together -- choose one of the three"
+		str := "%s: the testnet, simnet, regnet, and netparams params " +
+			"can't be used together -- choose one of the four"
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
@@ -637,6 +716,69 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Validate the block compression.  Only the ffldb backend supports
+	// compressing blocks.
+	if cfg.BlockCompression != "" {
+		compression, err := database.ParseBlockCompression(
+			cfg.BlockCompression)
+		if err != nil {
+			err := fmt.Errorf("%s: %v", funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if compression != database.BlockCompressionNone &&
+			cfg.DbType != "ffldb" {
+
+			str := "%s: block compression is not supported by the " +
+				"%s database type"
+			err := fmt.Errorf(str, funcName, cfg.DbType)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.blockCompression = compression
+	}
+
+	// Determine the block assumed to be valid.  It defaults to the one of
+	// the active network and can be disabled with a value of 0.
+	cfg.assumeValid = activeNetParams.AssumeValid
+	if cfg.AssumeValid == "0" {
+		cfg.assumeValid = chainhash.Hash{}
+	} else if cfg.AssumeValid != "" {
+		hash, err := chainhash.NewHashFromStr(cfg.AssumeValid)
+		if err != nil {
+			str := "%s: invalid assumevalid block hash '%s': %v"
+			err := fmt.Errorf(str, funcName, cfg.AssumeValid, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.assumeValid = *hash
+	}
+
+	// Parse the forced deployment states, which are only allowed on the
+	// regression test network.
+	if len(cfg.ForceDeployments) > 0 && activeNetParams.Net != wire.RegTest {
+		str := "%s: the forcedeployment option is only allowed on the " +
+			"regression test network"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	cfg.forcedDeployments = make(map[string]blockchain.ThresholdState)
+	for _, forced := range cfg.ForceDeployments {
+		id, state, err := parseForcedDeployment(forced)
+		if err != nil {
+			err := fmt.Errorf("%s: %v", funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.forcedDeployments[id] = state
+	}
+
 	// Validate format of profile, can be an address:port, or just a port.
 	if cfg.Profile != "" {
 		// if profile is just a number, then add a default host of "127.0.0.1" such that Profile is a valid tcp address
@@ -705,6 +847,22 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
+	// Load the asmap used to group peers by autonomous system.
+	if cfg.ASMap != "" {
+		path := cleanAndExpandPath(cfg.ASMap)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cfg.DataDir, path)
+		}
+		cfg.asmap, err = addrmgr.LoadASMap(path)
+		if err != nil {
+			str := "%s: failed to load asmap file '%s': %v"
+			err := fmt.Errorf(str, funcName, path, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	// --addPeer and --connect do not mix.
 	if len(cfg.AddPeers) > 0 && len(cfg.ConnectPeers) > 0 {
 		str := "%s: the --addpeer and --connect options can not be " +
@@ -774,6 +932,21 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
+	// Default the Electrum server to listen on localhost only.
+	if cfg.Electrum && len(cfg.ElectrumListeners) == 0 &&
+		len(cfg.ElectrumTLSListeners) == 0 {
+
+		addrs, err := net.LookupHost("localhost")
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.ElectrumListeners = make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			addr = net.JoinHostPort(addr, activeNetParams.electrumPort)
+			cfg.ElectrumListeners = append(cfg.ElectrumListeners, addr)
+		}
+	}
+
 	if cfg.RPCMaxConcurrentReqs < 0 {
 		str := "%s: the rpcmaxwebsocketconcurrentrequests option may " +
 			"not be less than 0 -- parsed [%d]"
@@ -818,6 +991,22 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Raise the max upload target to the minimum which still allows
+	// historical blocks to be served after reserving enough of it for
+	// relaying new blocks.  Smaller targets would never serve them.
+	if cfg.MaxUploadTarget != 0 {
+		minTarget := (minUploadTarget(activeNetParams.Params) +
+			1024*1024 - 1) / (1024 * 1024)
+		if cfg.MaxUploadTarget < minTarget {
+			dcrdLog.Warnf("The maxuploadtarget option of %d MiB is "+
+				"below the minimum of %d MiB required to serve "+
+				"historical blocks on %s -- using %d MiB",
+				cfg.MaxUploadTarget, minTarget,
+				activeNetParams.Name, minTarget)
+			cfg.MaxUploadTarget = minTarget
+		}
+	}
+
 	// Limit the block priority and minimum block sizes to max block size.
 	cfg.BlockPrioritySize = minUint32(cfg.BlockPrioritySize, cfg.BlockMaxSize)
 	cfg.BlockMinSize = minUint32(cfg.BlockMinSize, cfg.BlockMaxSize)
@@ -854,6 +1043,83 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// --addrutxoindex and --dropaddrutxoindex do not mix.
+	if cfg.AddrUtxoIndex && cfg.DropAddrUtxoIndex {
+		err := fmt.Errorf("%s: the --addrutxoindex and "+
+			"--dropaddrutxoindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --addrutxoindex and --droptxindex do not mix.
+	if cfg.AddrUtxoIndex && cfg.DropTxIndex {
+		err := fmt.Errorf("%s: the --addrutxoindex and --droptxindex "+
+			"options may not be activated at the same time "+
+			"because the address utxo index relies on the "+
+			"transaction index", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --spendindex and --dropspendindex do not mix.
+	if cfg.SpendIndex && cfg.DropSpendIndex {
+		err := fmt.Errorf("%s: the --spendindex and --dropspendindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --tickethistoryindex and --droptickethistoryindex do not mix.
+	if cfg.TicketHistIndex && cfg.DropTicketHistIndex {
+		err := fmt.Errorf("%s: the --tickethistoryindex and "+
+			"--droptickethistoryindex options may not be activated "+
+			"at the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --blockstatsindex and --dropblockstatsindex do not mix.
+	if cfg.BlockStatsIndex && cfg.DropBlockStatsIndex {
+		err := fmt.Errorf("%s: the --blockstatsindex and "+
+			"--dropblockstatsindex options may not be activated "+
+			"at the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The Electrum server relies on the script hash index.
+	if cfg.Electrum {
+		cfg.ScriptHashIndex = true
+	}
+
+	// --scripthashindex and --dropscripthashindex do not mix.
+	if cfg.ScriptHashIndex && cfg.DropScriptHashIndex {
+		err := fmt.Errorf("%s: the --scripthashindex and "+
+			"--dropscripthashindex options may not be activated "+
+			"at the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --scripthashindex and --droptxindex do not mix.
+	if cfg.ScriptHashIndex && cfg.DropTxIndex {
+		err := fmt.Errorf("%s: the --scripthashindex and --droptxindex "+
+			"options may not be activated at the same time "+
+			"because the script hash index relies on the "+
+			"transaction index", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// !--noexistsaddrindex and --dropexistsaddrindex do not mix.
 	if !cfg.NoExistsAddrIndex && cfg.DropExistsAddrIndex {
 		err := fmt.Errorf("dropexistsaddrindex cannot be activated when " +
@@ -926,6 +1192,13 @@ func loadConfig() (*config, []string, error) {
 	cfg.RPCListeners = normalizeAddresses(cfg.RPCListeners,
 		activeNetParams.rpcPort)
 
+	// Add default ports to all Electrum listener addresses if needed and
+	// remove duplicate addresses.
+	cfg.ElectrumListeners = normalizeAddresses(cfg.ElectrumListeners,
+		activeNetParams.electrumPort)
+	cfg.ElectrumTLSListeners = normalizeAddresses(cfg.ElectrumTLSListeners,
+		activeNetParams.electrumTLSPort)
+
 	// Only allow TLS to be disabled if the RPC is bound to localhost
 	// addresses.
 	if !cfg.DisableRPC && cfg.DisableTLS {
@@ -54,12 +54,22 @@ const (
 
 // ConnReq is the connection request to a network address. If permanent, the
 // connection will be retried on disconnection.
+//
+// A block-relay-only connection is an automatic outbound connection which is
+// only used to relay blocks.  It is maintained separately from the regular
+// outbound connections to make eclipse attacks more difficult.
+//
+// A feeler connection is a short-lived connection used to test whether an
+// address is reachable.  It does not count towards any target and is never
+// retried.
 type ConnReq struct {
 	// The following variables must only be used atomically.
 	id uint64
 
-	Addr      net.Addr
-	Permanent bool
+	Addr           net.Addr
+	Permanent      bool
+	BlockRelayOnly bool
+	Feeler         bool
 
 	conn       net.Conn
 	state      ConnState
@@ -124,6 +134,21 @@ type Config struct {
 	// maintain. Defaults to 8.
 	TargetOutbound uint32
 
+	// TargetBlockRelayOutbound is the number of block-relay-only outbound
+	// network connections to maintain in addition to TargetOutbound.
+	// Defaults to 0.
+	TargetBlockRelayOutbound uint32
+
+	// Anchors are the addresses of block-relay-only peers from a previous
+	// run.  They are connected to on start before any new block-relay-only
+	// connections are made.  Any anchors beyond TargetBlockRelayOutbound
+	// are ignored.
+	Anchors []net.Addr
+
+	// FeelerInterval is the interval at which feeler connections to new
+	// addresses are made.  A value of 0 disables feeler connections.
+	FeelerInterval time.Duration
+
 	// RetryDuration is the duration to wait before retrying connection
 	// requests. Defaults to 5s.
 	RetryDuration time.Duration
@@ -178,13 +203,17 @@ type ConnManager struct {
 
 // handleFailedConn handles a connection failed due to a disconnect or any
 // other failure. If permanent, it retries the connection after the configured
-// retry duration. Otherwise, if required, it makes a new connection request.
-// After maxFailedConnectionAttempts new connections will be retried after the
-// configured retry duration.
+// retry duration. Otherwise, if required, it makes a new connection request of
+// the same kind.  After maxFailedConnectionAttempts new connections will be
+// retried after the configured retry duration.  Feeler connections are never
+// retried.
 func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
 	}
+	if c.Feeler {
+		return
+	}
 	if c.Permanent {
 		c.retryCount++
 		d := time.Duration(c.retryCount) * cm.cfg.RetryDuration
@@ -202,10 +231,10 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 				"-- retrying connection in: %v", maxFailedAttempts,
 				cm.cfg.RetryDuration)
 			time.AfterFunc(cm.cfg.RetryDuration, func() {
-				cm.NewConnReq()
+				cm.newConnReq(c.BlockRelayOnly)
 			})
 		} else {
-			go cm.NewConnReq()
+			go cm.newConnReq(c.BlockRelayOnly)
 		}
 	}
 }
@@ -250,7 +279,7 @@ out:
 						go cm.cfg.OnDisconnection(connReq)
 					}
 
-					if uint32(len(conns)) < cm.cfg.TargetOutbound && msg.retry {
+					if msg.retry && cm.belowTarget(conns, connReq) {
 						cm.handleFailedConn(connReq)
 					}
 				} else {
@@ -273,9 +302,36 @@ out:
 	log.Trace("Connection handler done")
 }
 
+// belowTarget returns whether the number of established connections of the
+// same kind as the passed connection request is below the configured target.
+//
+// This function MUST be called from the connection handler goroutine.
+func (cm *ConnManager) belowTarget(conns map[uint64]*ConnReq, c *ConnReq) bool {
+	var numBlockRelay, numFeelers uint32
+	for _, connReq := range conns {
+		switch {
+		case connReq.BlockRelayOnly:
+			numBlockRelay++
+		case connReq.Feeler:
+			numFeelers++
+		}
+	}
+	if c.BlockRelayOnly {
+		return numBlockRelay < cm.cfg.TargetBlockRelayOutbound
+	}
+	numOther := uint32(len(conns)) - numBlockRelay - numFeelers
+	return numOther < cm.cfg.TargetOutbound
+}
+
 // NewConnReq creates a new connection request and connects to the
 // corresponding address.
 func (cm *ConnManager) NewConnReq() {
+	cm.newConnReq(false)
+}
+
+// newConnReq creates a new connection request, which is block-relay-only when
+// requested, and connects to the corresponding address.
+func (cm *ConnManager) newConnReq(blockRelayOnly bool) {
 	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
 	}
@@ -283,7 +339,7 @@ func (cm *ConnManager) NewConnReq() {
 		return
 	}
 
-	c := &ConnReq{}
+	c := &ConnReq{BlockRelayOnly: blockRelayOnly}
 	atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
 
 	addr, err := cm.cfg.GetNewAddress()
@@ -297,6 +353,32 @@ func (cm *ConnManager) NewConnReq() {
 	cm.Connect(c)
 }
 
+// feelerHandler periodically makes a feeler connection to a new address so
+// that addresses which have never been tried are tested and the address
+// manager learns about reachable peers.  It must be run as a goroutine.
+func (cm *ConnManager) feelerHandler() {
+	ticker := time.NewTicker(cm.cfg.FeelerInterval)
+	defer ticker.Stop()
+out:
+	for {
+		select {
+		case <-ticker.C:
+			addr, err := cm.cfg.GetNewAddress()
+			if err != nil {
+				log.Debugf("Unable to get feeler address: %v", err)
+				continue
+			}
+			go cm.Connect(&ConnReq{Addr: addr, Feeler: true})
+
+		case <-cm.quit:
+			break out
+		}
+	}
+
+	cm.wg.Done()
+	log.Trace("Feeler handler done")
+}
+
 // Connect assigns an id and dials a connection to the address of the
 // connection request.
 func (cm *ConnManager) Connect(c *ConnReq) {
@@ -377,6 +459,26 @@ func (cm *ConnManager) Start() {
 	for i := atomic.LoadUint64(&cm.connReqCount); i < uint64(cm.cfg.TargetOutbound); i++ {
 		go cm.NewConnReq()
 	}
+
+	// Reconnect to the anchors from the previous run first and then fill
+	// the remaining block-relay-only slots with new addresses.
+	var numBlockRelay uint32
+	for _, addr := range cm.cfg.Anchors {
+		if numBlockRelay >= cm.cfg.TargetBlockRelayOutbound {
+			break
+		}
+		log.Debugf("Connecting to anchor %v", addr)
+		go cm.Connect(&ConnReq{Addr: addr, BlockRelayOnly: true})
+		numBlockRelay++
+	}
+	for ; numBlockRelay < cm.cfg.TargetBlockRelayOutbound; numBlockRelay++ {
+		go cm.newConnReq(true)
+	}
+
+	if cm.cfg.FeelerInterval > 0 && cm.cfg.GetNewAddress != nil {
+		cm.wg.Add(1)
+		go cm.feelerHandler()
+	}
 }
 
 // Wait blocks until the connection manager halts gracefully.
@@ -215,6 +215,133 @@ func TestTargetOutbound(t *testing.T) {
 	cmgr.Stop()
 }
 
+// TestTargetBlockRelayOutbound tests the target number of block-relay-only
+// outbound connections along with reconnecting to anchors.
+//
+// We wait until all connections are established, then test that the anchors
+// were used first, that the expected number of each kind of connection was
+// made, and that a disconnected block-relay-only connection is replaced by
+// another block-relay-only connection.
+func TestTargetBlockRelayOutbound(t *testing.T) {
+	targetOutbound := uint32(3)
+	targetBlockRelay := uint32(2)
+	anchor := &net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: 18555}
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound:           targetOutbound,
+		TargetBlockRelayOutbound: targetBlockRelay,
+		Anchors:                  []net.Addr{anchor},
+		Dial:                     mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+	var numBlockRelay uint32
+	var anchorConnReq *ConnReq
+	for i := uint32(0); i < targetOutbound+targetBlockRelay; i++ {
+		c := <-connected
+		if !c.BlockRelayOnly {
+			continue
+		}
+		numBlockRelay++
+		if c.Addr.String() == anchor.String() {
+			anchorConnReq = c
+		}
+	}
+	if numBlockRelay != targetBlockRelay {
+		t.Fatalf("target block relay: got %d block-relay-only "+
+			"connections, want %d", numBlockRelay, targetBlockRelay)
+	}
+	if anchorConnReq == nil {
+		t.Fatal("target block relay: anchor was not connected")
+	}
+
+	select {
+	case c := <-connected:
+		t.Fatalf("target block relay: got unexpected connection - %v", c.Addr)
+	case <-time.After(time.Millisecond):
+		break
+	}
+
+	// Disconnecting the anchor must result in a new block-relay-only
+	// connection to an address from the address source.
+	cmgr.Disconnect(anchorConnReq.ID())
+	select {
+	case c := <-connected:
+		if !c.BlockRelayOnly || c.Addr.String() == anchor.String() {
+			t.Fatalf("target block relay: unexpected replacement "+
+				"connection - %v (block relay only %v)", c.Addr,
+				c.BlockRelayOnly)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("target block relay: anchor was not replaced")
+	}
+	cmgr.Stop()
+}
+
+// TestFeelerConnections tests that feeler connections are periodically made
+// and are not retried once they are disconnected.
+func TestFeelerConnections(t *testing.T) {
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound: 1,
+		FeelerInterval: 5 * time.Millisecond,
+		Dial:           mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+
+	var feeler *ConnReq
+	for feeler == nil {
+		select {
+		case c := <-connected:
+			if c.Feeler {
+				feeler = c
+			}
+		case <-time.After(time.Second):
+			t.Fatal("feeler: no feeler connection was made")
+		}
+	}
+
+	// Disconnecting the feeler must not result in a replacement outbound
+	// connection.  Additional feelers may still be made.
+	cmgr.Disconnect(feeler.ID())
+	timeout := time.After(20 * time.Millisecond)
+out:
+	for {
+		select {
+		case c := <-connected:
+			if !c.Feeler {
+				t.Fatalf("feeler: got unexpected connection - %v", c.Addr)
+			}
+		case <-timeout:
+			break out
+		}
+	}
+	cmgr.Stop()
+}
+
 // TestRetryPermanent tests that permanent connection requests are retried.
 //
 // We make a permanent connection request using Connect, disconnect it using
@@ -140,7 +140,7 @@ func (m *CPUMiner) submitBlock(block *hcutil.Block) bool {
 
 	// Process this block using the same rules as blocks coming from other
 	// nodes. This will in turn relay it to the network like normal.
-	isOrphan, err := m.server.blockManager.ProcessBlock(block, blockchain.BFNone)
+	err := m.server.blockManager.ProcessBlock(block, blockchain.BFNone)
 	if err != nil {
 		// Anything other than a rule violation is an unexpected error,
 		// so log that error as an internal error.
@@ -165,12 +165,6 @@ func (m *CPUMiner) submitBlock(block *hcutil.Block) bool {
 		return false
 
 	}
-	if isOrphan {
-		minrLog.Errorf("Block submitted via CPU miner is an orphan building "+
-			"on parent %v", block.MsgBlock().Header.PrevBlock)
-		return false
-	}
-
 	// The block was accepted.
 	coinbaseTxOuts := block.MsgBlock().Transactions[0].TxOut
 	coinbaseTxGenerated := int64(0)
@@ -607,15 +601,27 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		default:
 		}
 
+		// Add the votes and ticket purchases of the internal wallet to
+		// the memory pool on the regression test network and pay the
+		// block to it so it can purchase more tickets.  Otherwise,
+		// choose a payment address at random.
+		var payToAddr hcutil.Address
+		if w := m.server.regNetWallet; w != nil {
+			if err := w.CreateStakeTransactions(); err != nil {
+				minrLog.Warnf("Failed to create stake "+
+					"transactions: %v", err)
+			}
+			payToAddr = w.Address()
+		} else {
+			rand.Seed(time.Now().UnixNano())
+			payToAddr = cfg.miningAddrs[rand.Intn(len(cfg.miningAddrs))]
+		}
+
 		// Grab the lock used for block submission, since the current block will
 		// be changing and this would otherwise end up building a new block
 		// template on a block that is in the process of becoming stale.
 		m.submitBlockLock.Lock()
 
-		// Choose a payment address at random.
-		rand.Seed(time.Now().UnixNano())
-		payToAddr := cfg.miningAddrs[rand.Intn(len(cfg.miningAddrs))]
-
 		// Create a new block template using the available transactions
 		// in the memory pool as a source of transactions to potentially
 		// include in the block.
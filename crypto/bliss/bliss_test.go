@@ -1,7 +1,72 @@
 package bliss
 
-import "testing"
+import (
+	"crypto/rand"
+	"testing"
+
+	dcrcrypto "github.com/coolsnady/hcd/crypto"
+)
 
 func TestBliss(t *testing.T){
 
-}
\ No newline at end of file
+}
+// blissSigs returns the given number of public keys, message hashes, and
+// signatures made with distinct keys.
+func blissSigs(t testing.TB, size int) ([]dcrcrypto.PublicKey, [][]byte,
+	[]dcrcrypto.Signature) {
+
+	pubs := make([]dcrcrypto.PublicKey, size)
+	hashes := make([][]byte, size)
+	sigs := make([]dcrcrypto.Signature, size)
+	for i := 0; i < size; i++ {
+		sk, pk, err := Bliss.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKey: %v", err)
+		}
+		hash := make([]byte, 32)
+		rand.Read(hash)
+		// Sign expects the private key by value.
+		sig, err := Bliss.Sign(*sk.(*PrivateKey), hash)
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		pubs[i], hashes[i], sigs[i] = pk, hash, sig
+	}
+	return pubs, hashes, sigs
+}
+
+// TestVerify ensures Bliss signatures only pass verification against the
+// message and public key they were made for.
+func TestVerify(t *testing.T) {
+	pubs, hashes, sigs := blissSigs(t, 4)
+	for i := range sigs {
+		if !Bliss.Verify(pubs[i], hashes[i], sigs[i]) {
+			t.Fatalf("valid signature %d failed verification", i)
+		}
+	}
+
+	// Ensure a signature over a different message fails.
+	badHash := make([]byte, len(hashes[1]))
+	copy(badHash, hashes[1])
+	badHash[0] ^= 0x01
+	if Bliss.Verify(pubs[1], badHash, sigs[1]) {
+		t.Fatal("signature over a different message passed verification")
+	}
+
+	// Ensure a signature checked against the wrong key fails.
+	if Bliss.Verify(pubs[2], hashes[3], sigs[3]) {
+		t.Fatal("signature checked against the wrong key passed " +
+			"verification")
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	pubs, hashes, sigs := blissSigs(b, 64)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		i := n % len(sigs)
+		if !Bliss.Verify(pubs[i], hashes[i], sigs[i]) {
+			b.Fatal("signature failed verification")
+		}
+	}
+}
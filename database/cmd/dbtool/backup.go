@@ -0,0 +1,79 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/coolsnady/hcd/database"
+)
+
+// backupLogInterval is the minimum amount of time between progress messages.
+const backupLogInterval = 10 * time.Second
+
+// backupCmd defines the configuration options for the backup command.
+type backupCmd struct {
+	Dest string `long:"dest" description:"Directory to write the backup of the block database to"`
+}
+
+var (
+	// backupCfg defines the configuration options for the command.
+	backupCfg = backupCmd{}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *backupCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+	if cmd.Dest == "" {
+		return errors.New("a destination directory must be specified " +
+			"with --dest")
+	}
+
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	backuper, ok := db.(database.Backuper)
+	if !ok {
+		return fmt.Errorf("the %s database type does not support backups",
+			cfg.DbType)
+	}
+
+	// Stop the backup on Ctrl+C.  Running the command again with the same
+	// destination resumes it.
+	interrupt := make(chan struct{})
+	addInterruptHandler(func() {
+		log.Infof("Stopping the backup...")
+		close(interrupt)
+	})
+
+	startTime := time.Now()
+	var lastLog time.Time
+	var lastProgress database.BackupProgress
+	progress := func(p database.BackupProgress) {
+		lastProgress = p
+		if time.Since(lastLog) < backupLogInterval {
+			return
+		}
+		lastLog = time.Now()
+		log.Infof("Copied %d of %d MiB of block data, %d metadata entries",
+			p.CopiedBytes>>20, p.TotalBytes>>20, p.MetadataEntries)
+	}
+	log.Infof("Backing up block database to '%s'", cmd.Dest)
+	if err := backuper.Backup(cmd.Dest, progress, interrupt); err != nil {
+		return err
+	}
+
+	log.Infof("Backed up %d MiB of block data and %d metadata entries in %v",
+		lastProgress.TotalBytes>>20, lastProgress.MetadataEntries,
+		time.Since(startTime))
+	return nil
+}
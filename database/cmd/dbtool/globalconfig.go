@@ -14,6 +14,7 @@ import (
 	"github.com/coolsnady/hcd/chaincfg"
 	"github.com/coolsnady/hcd/database"
 	_ "github.com/coolsnady/hcd/database/ffldb"
+	_ "github.com/coolsnady/hcd/database/treapdb"
 	"github.com/coolsnady/hcutil"
 )
 
@@ -31,10 +32,12 @@ var (
 
 // config defines the global configuration options.
 type config struct {
-	DataDir string `short:"b" long:"datadir" description:"Location of the hcd data directory"`
-	DbType  string `long:"dbtype" description:"Database backend to use for the Block Chain"`
-	TestNet bool   `long:"testnet" description:"Use the test network"`
-	SimNet  bool   `long:"simnet" description:"Use the simulation test network"`
+	DataDir   string `short:"b" long:"datadir" description:"Location of the hcd data directory"`
+	DbType    string `long:"dbtype" description:"Database backend to use for the Block Chain"`
+	TestNet   bool   `long:"testnet" description:"Use the test network"`
+	SimNet    bool   `long:"simnet" description:"Use the simulation test network"`
+	RegNet    bool   `long:"regnet" description:"Use the regression test network"`
+	NetParams string `long:"netparams" description:"Use the custom network described by the JSON parameters file"`
 }
 
 // fileExists reports whether the named file or directory exists.
@@ -74,9 +77,21 @@ func setupGlobalConfig() error {
 		numNets++
 		activeNetParams = &chaincfg.SimNetParams
 	}
+	if cfg.RegNet {
+		numNets++
+		activeNetParams = &chaincfg.RegNetParams
+	}
+	if cfg.NetParams != "" {
+		numNets++
+		params, err := chaincfg.LoadParamsFile(cfg.NetParams)
+		if err != nil {
+			return err
+		}
+		activeNetParams = params
+	}
 	if numNets > 1 {
-		return errors.New("the testnet and simnet params can't be " +
-			"used together -- choose one of the two")
+		return errors.New("the testnet, simnet, regnet, and netparams " +
+			"params can't be used together -- choose one of the four")
 	}
 
 	// Validate database type.
@@ -38,9 +38,14 @@ func (cmd *headersCmd) Execute(args []string) error {
 	}
 	defer db.Close()
 
-	// NOTE: This code will only work for ffldb.  Ideally the package using
-	// the database would keep a metadata index of its own.
-	blockIdxName := []byte("ffldb-blockidx")
+	// NOTE: This code relies on the internal block index of the database
+	// driver.  Ideally the package using the database would keep a
+	// metadata index of its own.
+	internals, err := internalsForDbType(cfg.DbType)
+	if err != nil {
+		return err
+	}
+	blockIdxName := internals.blockIdxBucket
 	if !headersCfg.Bulk {
 		return db.View(func(tx database.Tx) error {
 			totalHdrs := 0
@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/btcsuite/btclog"
+	"github.com/coolsnady/hcd/blockchain"
 	"github.com/coolsnady/hcd/database"
 	flags "github.com/jessevdk/go-flags"
 )
@@ -26,12 +27,16 @@ var (
 	shutdownChannel = make(chan error)
 )
 
-// loadBlockDB opens the block database and returns a handle to it.
-func loadBlockDB() (database.DB, error) {
-	// The database name is based on the database type.
+// blockDbPath returns the path to the block database.  The database name is
+// based on the database type.
+func blockDbPath() string {
 	dbName := blockDbNamePrefix + "_" + cfg.DbType
-	dbPath := filepath.Join(cfg.DataDir, dbName)
+	return filepath.Join(cfg.DataDir, dbName)
+}
 
+// loadBlockDB opens the block database and returns a handle to it.
+func loadBlockDB() (database.DB, error) {
+	dbPath := blockDbPath()
 	log.Infof("Loading block database from '%s'", dbPath)
 	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
 	if err != nil {
@@ -68,6 +73,7 @@ func realMain() error {
 	dbLog := backendLogger.Logger("BCDB")
 	dbLog.SetLevel(btclog.LevelDebug)
 	database.UseLogger(dbLog)
+	blockchain.UseLogger(backendLogger.Logger("CHAN"))
 
 	// Setup the parser options and commands.
 	appName := filepath.Base(os.Args[0])
@@ -90,6 +96,49 @@ func realMain() error {
 	parser.AddCommand("fetchblockregion",
 		"Fetch the specified block region from the database", "",
 		&blockRegionCfg)
+	parser.AddCommand("migrate",
+		"Migrate the block database to another database backend",
+		"Copy all blocks and metadata from the block database of the "+
+			"type specified by --dbtype to a new block database "+
+			"of the type specified by --destdbtype.", &migrateCfg)
+	parser.AddCommand("backup",
+		"Back up the block database to another directory",
+		"Write a consistent copy of the block database to the "+
+			"directory specified by --dest.  An interrupted backup "+
+			"is resumed when the command is run again with the same "+
+			"destination.", &backupCfg)
+	parser.AddCommand("recompress",
+		"Rewrite all blocks with the specified compression",
+		"Rewrite all blocks of the block database with the "+
+			"compression specified by --compression.  The rewritten "+
+			"database is written next to the existing one, which "+
+			"it replaces once it is complete, so enough free space "+
+			"for a second copy is required.", &recompressCfg)
+	verifyCommand, _ := parser.AddCommand("verify",
+		"Verify the integrity of the block database",
+		"Verify the integrity of the block database using one of "+
+			"the subcommands.", &verifyCfg)
+	verifyCommand.AddCommand("blocks",
+		"Verify the hash and merkle roots of every indexed block",
+		"Fetch every block referenced by the block index and "+
+			"ensure its checksum, hash, and merkle roots match.",
+		&verifyBlocksCfg)
+	verifyCommand.AddCommand("chainstate",
+		"Verify the utxo set and ticket database",
+		"Ensure the utxo set and ticket database are consistent "+
+			"with the spend journal of the main chain blocks.",
+		&verifyChainStateCfg)
+	verifyCommand.AddCommand("files",
+		"Verify the block files against the block index",
+		"Scan the block files for data which is not referenced by "+
+			"the block index and block files which are not used.",
+		&verifyFilesCfg)
+	parser.AddCommand("repair",
+		"Rebuild the block index from the block files",
+		"Rebuild the block index of a block database which can no "+
+			"longer be opened due to corruption by scanning its "+
+			"block files.  Any invalid block data at the end of "+
+			"the block files is discarded.", &repairCfg)
 
 	// Parse command line and invoke the Execute function for the specified
 	// command.
@@ -0,0 +1,350 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcutil"
+)
+
+const (
+	// migrateBlockBatchSize is the maximum number of blocks copied per
+	// database transaction when migrating.
+	migrateBlockBatchSize = 500
+
+	// migrateMetaBatchBytes is the approximate maximum number of bytes of
+	// metadata copied per database transaction when migrating.
+	migrateMetaBatchBytes = 64 * 1024 * 1024 // 64 MiB
+)
+
+// driverInternals houses the names of the internal keys the database drivers
+// store in the top-level metadata bucket.
+type driverInternals struct {
+	blockIdxBucket []byte
+	writeLocKey    []byte
+}
+
+var (
+	// knownDriverInternals maps the supported database types to the
+	// internal keys they store in the top-level metadata bucket.
+	//
+	// NOTE: The database interface does not provide a way to enumerate the
+	// stored blocks, so the internal block index of the drivers is used
+	// for that purpose.  Ideally the package using the database would keep
+	// a metadata index of its own.
+	knownDriverInternals = map[string]driverInternals{
+		"ffldb": {
+			blockIdxBucket: []byte("ffldb-blockidx"),
+			writeLocKey:    []byte("ffldb-writeloc"),
+		},
+		"treapdb": {
+			blockIdxBucket: []byte("treapdb-blockidx"),
+			writeLocKey:    []byte("treapdb-writeloc"),
+		},
+	}
+)
+
+// internalsForDbType returns the internal keys stored in the top-level metadata
+// bucket by the provided database type.
+func internalsForDbType(dbType string) (driverInternals, error) {
+	internals, ok := knownDriverInternals[dbType]
+	if !ok {
+		return driverInternals{}, fmt.Errorf("the %q database type "+
+			"does not expose its block index", dbType)
+	}
+	return internals, nil
+}
+
+// migrateCmd defines the configuration options for the migrate command.
+type migrateCmd struct {
+	DestDbType string `long:"destdbtype" description:"Database backend to migrate the block database to"`
+}
+
+var (
+	// migrateCfg defines the configuration options for the command.
+	migrateCfg = migrateCmd{}
+)
+
+// metaBucketNode identifies a bucket in the source metadata by its parent and
+// name.  The top-level metadata bucket has a nil parent.
+type metaBucketNode struct {
+	parent *metaBucketNode
+	name   []byte
+}
+
+// metaEntry is a key/value pair of the source metadata that is pending to be
+// copied to the destination database.  A nil key only ensures the bucket
+// exists.
+type metaEntry struct {
+	bucket *metaBucketNode
+	key    []byte
+	value  []byte
+}
+
+// metadataCopier copies the metadata of a database to another one in batches.
+type metadataCopier struct {
+	db           database.DB
+	pending      []metaEntry
+	pendingBytes int
+	numCopied    uint64
+}
+
+// add adds the passed entry to the pending entries and flushes them to the
+// destination database when the batch is full.
+func (c *metadataCopier) add(entry metaEntry) error {
+	c.pending = append(c.pending, entry)
+	c.pendingBytes += len(entry.key) + len(entry.value)
+	if c.pendingBytes < migrateMetaBatchBytes {
+		return nil
+	}
+	return c.flush()
+}
+
+// flush writes all pending entries to the destination database using a single
+// transaction.
+func (c *metadataCopier) flush() error {
+	if len(c.pending) == 0 {
+		return nil
+	}
+
+	err := c.db.Update(func(tx database.Tx) error {
+		buckets := make(map[*metaBucketNode]database.Bucket)
+		var bucketFor func(node *metaBucketNode) (database.Bucket, error)
+		bucketFor = func(node *metaBucketNode) (database.Bucket, error) {
+			if node.parent == nil {
+				return tx.Metadata(), nil
+			}
+			if bucket, ok := buckets[node]; ok {
+				return bucket, nil
+			}
+			parent, err := bucketFor(node.parent)
+			if err != nil {
+				return nil, err
+			}
+			bucket, err := parent.CreateBucketIfNotExists(node.name)
+			if err != nil {
+				return nil, err
+			}
+			buckets[node] = bucket
+			return bucket, nil
+		}
+
+		for _, entry := range c.pending {
+			bucket, err := bucketFor(entry.bucket)
+			if err != nil {
+				return err
+			}
+			if entry.key == nil {
+				continue
+			}
+			if err := bucket.Put(entry.key, entry.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.numCopied += uint64(len(c.pending))
+	log.Infof("Copied %d metadata entries", c.numCopied)
+	c.pending = c.pending[:0]
+	c.pendingBytes = 0
+	return nil
+}
+
+// copyBucket adds all key/value pairs of the passed source bucket as well as
+// all of its nested buckets to the passed copier.  The passed skip function is
+// used to exclude keys and nested buckets of the bucket itself.
+func copyBucket(c *metadataCopier, bucket database.Bucket, node *metaBucketNode, skip func(k []byte) bool) error {
+	if node.parent != nil {
+		if err := c.add(metaEntry{bucket: node}); err != nil {
+			return err
+		}
+	}
+
+	err := bucket.ForEach(func(k, v []byte) error {
+		if skip(k) {
+			return nil
+		}
+		return c.add(metaEntry{bucket: node, key: k, value: v})
+	})
+	if err != nil {
+		return err
+	}
+
+	var names [][]byte
+	err = bucket.ForEachBucket(func(k []byte) error {
+		if !skip(k) {
+			names = append(names, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	noSkip := func(k []byte) bool { return false }
+	for _, name := range names {
+		child := &metaBucketNode{parent: node, name: name}
+		err := copyBucket(c, bucket.Bucket(name), child, noSkip)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyBlocks copies all blocks stored in the source database, which are tracked
+// by the block index bucket with the passed name, to the destination database.
+func copyBlocks(srcTx database.Tx, dstDB database.DB, blockIdxBucket []byte) (int, error) {
+	bucket := srcTx.Metadata().Bucket(blockIdxBucket)
+	if bucket == nil {
+		return 0, fmt.Errorf("block index bucket %q does not exist",
+			blockIdxBucket)
+	}
+	var hashes []chainhash.Hash
+	err := bucket.ForEach(func(k, v []byte) error {
+		var hash chainhash.Hash
+		copy(hash[:], k)
+		hashes = append(hashes, hash)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	log.Infof("Copying %d blocks...", len(hashes))
+	for start := 0; start < len(hashes); start += migrateBlockBatchSize {
+		end := start + migrateBlockBatchSize
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		blocksBytes, err := srcTx.FetchBlocks(hashes[start:end])
+		if err != nil {
+			return 0, err
+		}
+		err = dstDB.Update(func(tx database.Tx) error {
+			for _, blockBytes := range blocksBytes {
+				block, err := hcutil.NewBlockFromBytes(blockBytes)
+				if err != nil {
+					return err
+				}
+				if err := tx.StoreBlock(block); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		log.Infof("Copied %d of %d blocks", end, len(hashes))
+	}
+	return len(hashes), nil
+}
+
+// copyDatabase copies all blocks followed by all metadata other than the
+// internal keys of the source database to the destination database.  The
+// destination database maintains its own internal keys as the blocks are
+// stored.  It returns the number of copied blocks and metadata entries.
+func copyDatabase(srcDB, dstDB database.DB, srcInternals, dstInternals driverInternals) (int, uint64, error) {
+	var numBlocks int
+	copier := &metadataCopier{db: dstDB}
+	err := srcDB.View(func(tx database.Tx) error {
+		var err error
+		numBlocks, err = copyBlocks(tx, dstDB, srcInternals.blockIdxBucket)
+		if err != nil {
+			return err
+		}
+
+		log.Info("Copying metadata...")
+		skip := func(k []byte) bool {
+			return string(k) == string(srcInternals.blockIdxBucket) ||
+				string(k) == string(srcInternals.writeLocKey) ||
+				string(k) == string(dstInternals.blockIdxBucket) ||
+				string(k) == string(dstInternals.writeLocKey)
+		}
+		err = copyBucket(copier, tx.Metadata(), &metaBucketNode{}, skip)
+		if err != nil {
+			return err
+		}
+		return copier.flush()
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return numBlocks, copier.numCopied, nil
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *migrateCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	// Ensure the destination database type is valid and both database
+	// types expose their block index.
+	if !validDbType(cmd.DestDbType) {
+		return fmt.Errorf("the specified destination database type "+
+			"[%v] is invalid -- supported types %v", cmd.DestDbType,
+			knownDbTypes)
+	}
+	if cmd.DestDbType == cfg.DbType {
+		return fmt.Errorf("the destination database type must differ "+
+			"from the source database type [%v]", cfg.DbType)
+	}
+	srcInternals, err := internalsForDbType(cfg.DbType)
+	if err != nil {
+		return err
+	}
+	dstInternals, err := internalsForDbType(cmd.DestDbType)
+	if err != nil {
+		return err
+	}
+
+	// Open the source database, which must exist, and create the
+	// destination database, which must not exist.
+	srcPath := filepath.Join(cfg.DataDir, blockDbNamePrefix+"_"+cfg.DbType)
+	dstPath := filepath.Join(cfg.DataDir, blockDbNamePrefix+"_"+
+		cmd.DestDbType)
+	if fileExists(dstPath) {
+		return fmt.Errorf("the destination database %q already exists",
+			dstPath)
+	}
+	log.Infof("Loading block database from '%s'", srcPath)
+	srcDB, err := database.Open(cfg.DbType, srcPath, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+	defer srcDB.Close()
+	log.Infof("Creating block database in '%s'", dstPath)
+	dstDB, err := database.Create(cmd.DestDbType, dstPath,
+		activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+	defer dstDB.Close()
+
+	startTime := time.Now()
+	numBlocks, numEntries, err := copyDatabase(srcDB, dstDB, srcInternals,
+		dstInternals)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Migrated %d blocks and %d metadata entries from %s to %s "+
+		"in %v", numBlocks, numEntries, cfg.DbType, cmd.DestDbType,
+		time.Since(startTime))
+	log.Infof("Start hcd with --dbtype=%s to use the migrated database",
+		cmd.DestDbType)
+	return nil
+}
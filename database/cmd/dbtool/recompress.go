@@ -0,0 +1,137 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coolsnady/hcd/database"
+)
+
+// recompressCmd defines the configuration options for the recompress command.
+type recompressCmd struct {
+	Compression string `long:"compression" default:"snappy" description:"Compression to apply to all blocks {none, snappy}"`
+}
+
+var (
+	// recompressCfg defines the configuration options for the command.
+	recompressCfg = recompressCmd{}
+)
+
+// blockFilesSize returns the total size of the flat block files in the passed
+// database directory.
+func blockFilesSize(dbPath string) (int64, error) {
+	files, err := ioutil.ReadDir(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, fi := range files {
+		if !fi.IsDir() && strings.HasSuffix(fi.Name(), ".fdb") {
+			size += fi.Size()
+		}
+	}
+	return size, nil
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *recompressCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+	compression, err := database.ParseBlockCompression(cmd.Compression)
+	if err != nil {
+		return err
+	}
+	internals, err := internalsForDbType(cfg.DbType)
+	if err != nil {
+		return err
+	}
+
+	// The blocks are rewritten to a new database next to the existing one
+	// which replaces it once it is complete.  Refuse to continue when a
+	// previous run was stopped while replacing the database since the
+	// existing database might be incomplete.
+	dbPath := blockDbPath()
+	tmpPath := dbPath + ".recompress"
+	oldPath := dbPath + ".old"
+	if fileExists(oldPath) {
+		return fmt.Errorf("'%s' exists from a previous run which did not "+
+			"complete -- remove it once '%s' has been verified to be "+
+			"intact", oldPath, dbPath)
+	}
+	if !fileExists(dbPath) {
+		return fmt.Errorf("block database '%s' does not exist", dbPath)
+	}
+	if err := os.RemoveAll(tmpPath); err != nil {
+		return err
+	}
+	origSize, err := blockFilesSize(dbPath)
+	if err != nil {
+		return err
+	}
+
+	// The block compression is only passed when enabled since it is an
+	// optional argument which not all database types support.
+	dstArgs := []interface{}{tmpPath, activeNetParams.Net}
+	if compression != database.BlockCompressionNone {
+		dstArgs = append(dstArgs, compression)
+	}
+
+	log.Infof("Loading block database from '%s'", dbPath)
+	srcDB, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+	log.Infof("Creating block database in '%s'", tmpPath)
+	dstDB, err := database.Create(cfg.DbType, dstArgs...)
+	if err != nil {
+		srcDB.Close()
+		return err
+	}
+
+	startTime := time.Now()
+	log.Infof("Rewriting all blocks with %v compression", compression)
+	numBlocks, numEntries, err := copyDatabase(srcDB, dstDB, internals,
+		internals)
+	srcDB.Close()
+	dstDB.Close()
+	if err != nil {
+		return err
+	}
+
+	// Replace the existing database with the rewritten one.
+	if err := os.Rename(dbPath, oldPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(oldPath); err != nil {
+		return err
+	}
+
+	newSize, err := blockFilesSize(dbPath)
+	if err != nil {
+		return err
+	}
+	log.Infof("Rewrote %d blocks and %d metadata entries in %v", numBlocks,
+		numEntries, time.Since(startTime))
+	if origSize > 0 {
+		log.Infof("The block files now use %d MiB instead of %d MiB "+
+			"(%.1f%%)", newSize>>20, origSize>>20,
+			float64(newSize)*100/float64(origSize))
+	}
+	if compression != database.BlockCompressionNone {
+		log.Infof("Start hcd with --blockcompression=%v to compress new "+
+			"blocks as well", compression)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/coolsnady/hcd/database"
+)
+
+// repairCmd defines the configuration options for the repair command.
+type repairCmd struct{}
+
+var (
+	// repairCfg defines the configuration options for the command.
+	repairCfg = repairCmd{}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *repairCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	dbPath := blockDbPath()
+	if !fileExists(dbPath) {
+		return fmt.Errorf("block database '%s' does not exist", dbPath)
+	}
+	log.Infof("Rebuilding the block index of '%s' from the block files",
+		dbPath)
+	result, err := database.Repair(cfg.DbType, dbPath, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Rebuilt the block index with %d blocks (previously %d), "+
+		"discarded %d bytes of invalid block data", result.Blocks,
+		result.PrevBlocks, result.DiscardedBytes)
+	if result.Blocks != result.PrevBlocks || result.DiscardedBytes != 0 {
+		log.Warnf("The block index changed -- run 'verify chainstate' " +
+			"to ensure the chain state is still consistent")
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcutil"
+)
+
+const (
+	// verifyLogInterval is the minimum amount of time between progress
+	// messages.
+	verifyLogInterval = 10 * time.Second
+
+	// verifyBlocksPerTx is the number of blocks fetched per database
+	// transaction by the verify blocks command.  Limiting it avoids
+	// holding a single transaction open for the entire verification.
+	verifyBlocksPerTx = 1000
+)
+
+// verifyCmd defines the configuration options for the verify command.  The
+// checks themselves are performed by its subcommands.
+type verifyCmd struct{}
+
+// verifyBlocksCmd defines the configuration options for the verify blocks
+// subcommand.
+type verifyBlocksCmd struct{}
+
+// verifyChainStateCmd defines the configuration options for the verify
+// chainstate subcommand.
+type verifyChainStateCmd struct {
+	Depth int64 `long:"depth" description:"Number of blocks back from the best block to verify (0 for all)"`
+}
+
+// verifyFilesCmd defines the configuration options for the verify files
+// subcommand.
+type verifyFilesCmd struct{}
+
+var (
+	// verifyCfg defines the configuration options for the command.
+	verifyCfg = verifyCmd{}
+
+	// verifyBlocksCfg defines the configuration options for the verify
+	// blocks subcommand.
+	verifyBlocksCfg = verifyBlocksCmd{}
+
+	// verifyChainStateCfg defines the configuration options for the verify
+	// chainstate subcommand.
+	verifyChainStateCfg = verifyChainStateCmd{}
+
+	// verifyFilesCfg defines the configuration options for the verify files
+	// subcommand.
+	verifyFilesCfg = verifyFilesCmd{}
+)
+
+// verifyInterruptChannel returns a channel which is closed when an interrupt
+// signal is received.
+func verifyInterruptChannel() <-chan struct{} {
+	interrupt := make(chan struct{})
+	addInterruptHandler(func() {
+		log.Infof("Stopping the verification...")
+		close(interrupt)
+	})
+	return interrupt
+}
+
+// verifyBlock returns a description of the problem with the passed block which
+// was fetched from the database by the provided hash, or an empty string when
+// it is consistent.
+func verifyBlock(hash *chainhash.Hash, block *hcutil.Block) string {
+	header := &block.MsgBlock().Header
+	if blockHash := header.BlockHash(); blockHash != *hash {
+		return fmt.Sprintf("stored block has hash %v", blockHash)
+	}
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
+	if root := merkles[len(merkles)-1]; !header.MerkleRoot.IsEqual(root) {
+		return fmt.Sprintf("merkle root is %v, but header indicates %v",
+			root, header.MerkleRoot)
+	}
+	merkles = blockchain.BuildMerkleTreeStore(block.STransactions())
+	if root := merkles[len(merkles)-1]; !header.StakeRoot.IsEqual(root) {
+		return fmt.Sprintf("stake merkle root is %v, but header "+
+			"indicates %v", root, header.StakeRoot)
+	}
+	return ""
+}
+
+// Execute is the main entry point for the verify blocks subcommand.  It's
+// invoked by the parser.
+func (cmd *verifyBlocksCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+	internals, err := internalsForDbType(cfg.DbType)
+	if err != nil {
+		return err
+	}
+
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	interrupt := verifyInterruptChannel()
+
+	// Load the hashes of all blocks in the block index.
+	var hashes []chainhash.Hash
+	err = db.View(func(tx database.Tx) error {
+		blockIdxBucket := tx.Metadata().Bucket(internals.blockIdxBucket)
+		return blockIdxBucket.ForEach(func(k, v []byte) error {
+			var hash chainhash.Hash
+			copy(hash[:], k)
+			hashes = append(hashes, hash)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	log.Infof("Verifying %d blocks...", len(hashes))
+
+	// Fetch every block, which also verifies its checksum, and ensure the
+	// hash and merkle roots match the stored data.
+	startTime := time.Now()
+	var lastLog time.Time
+	var numFailed int
+	for start := 0; start < len(hashes); start += verifyBlocksPerTx {
+		select {
+		case <-interrupt:
+			return database.Error{ErrorCode: database.ErrInterrupted,
+				Description: "verification interrupted"}
+		default:
+		}
+		if time.Since(lastLog) >= verifyLogInterval {
+			log.Infof("Verified %d of %d blocks", start, len(hashes))
+			lastLog = time.Now()
+		}
+
+		end := start + verifyBlocksPerTx
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		err := db.View(func(tx database.Tx) error {
+			for i := start; i < end; i++ {
+				hash := &hashes[i]
+				blockBytes, err := tx.FetchBlock(hash)
+				if err != nil {
+					log.Errorf("Block %v: %v", hash, err)
+					numFailed++
+					continue
+				}
+				block, err := hcutil.NewBlockFromBytes(blockBytes)
+				if err != nil {
+					log.Errorf("Block %v: unable to deserialize: "+
+						"%v", hash, err)
+					numFailed++
+					continue
+				}
+				if problem := verifyBlock(hash, block); problem != "" {
+					log.Errorf("Block %v: %s", hash, problem)
+					numFailed++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Verified %d blocks in %v", len(hashes), time.Since(startTime))
+	if numFailed > 0 {
+		return fmt.Errorf("%d of %d blocks failed verification", numFailed,
+			len(hashes))
+	}
+	return nil
+}
+
+// Execute is the main entry point for the verify chainstate subcommand.  It's
+// invoked by the parser.
+func (cmd *verifyChainStateCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+	if cmd.Depth < 0 {
+		return fmt.Errorf("the depth may not be negative")
+	}
+
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	interrupt := verifyInterruptChannel()
+
+	startTime := time.Now()
+	log.Infof("Verifying the chain state...")
+	issues, err := blockchain.VerifyChainState(db, activeNetParams,
+		cmd.Depth, interrupt)
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		log.Errorf("%v", issue)
+	}
+	log.Infof("Verified the chain state in %v", time.Since(startTime))
+	if len(issues) > 0 {
+		return fmt.Errorf("found %d chain state inconsistencies",
+			len(issues))
+	}
+	return nil
+}
+
+// Execute is the main entry point for the verify files subcommand.  It's
+// invoked by the parser.
+func (cmd *verifyFilesCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	verifier, ok := db.(database.BlockFileVerifier)
+	if !ok {
+		return fmt.Errorf("the %s database type does not support "+
+			"verifying its block files", cfg.DbType)
+	}
+	interrupt := verifyInterruptChannel()
+
+	startTime := time.Now()
+	log.Infof("Verifying the block files...")
+	report, err := verifier.VerifyBlockFiles(interrupt)
+	if err != nil {
+		return err
+	}
+	for _, hash := range report.UnindexedBlocks {
+		log.Errorf("Block %v is stored in the block files but not "+
+			"indexed at that location", hash)
+	}
+	for _, region := range report.InvalidRegions {
+		log.Errorf("Invalid block data in %s", region)
+	}
+	for _, path := range report.OrphanedFiles {
+		log.Warnf("Block file %s is not referenced by the database", path)
+	}
+	log.Infof("Verified %d blocks stored in the block files in %v",
+		report.Blocks, time.Since(startTime))
+	numIssues := len(report.UnindexedBlocks) + len(report.InvalidRegions) +
+		len(report.OrphanedFiles)
+	if numIssues > 0 {
+		return fmt.Errorf("found %d issues with the block files", numIssues)
+	}
+	return nil
+}
@@ -28,6 +28,12 @@ type Driver struct {
 	// ErrDbDoesNotExist if the database has not already been created.
 	Open func(args ...interface{}) (DB, error)
 
+	// Repair is the function that will be invoked with all user-specified
+	// arguments to rebuild the block index of an existing database, which
+	// must not be open, from the blocks stored by the driver.  It is
+	// optional and may be nil when the driver does not support repairs.
+	Repair func(args ...interface{}) (*RepairResult, error)
+
 	// UseLogger uses a specified Logger to output package logging info.
 	UseLogger func(logger btclog.Logger)
 }
@@ -88,3 +94,25 @@ func Open(dbType string, args ...interface{}) (DB, error) {
 
 	return drv.Open(args...)
 }
+
+// Repair rebuilds the block index of an existing database for the specified
+// type from the blocks stored by the driver.  This is intended to recover from
+// corruption which prevents the database from being opened.  The arguments are
+// specific to the database type driver.  See the documentation for the database
+// driver for further details.
+//
+// ErrDbUnknownType will be returned if the the database type is not registered
+// and ErrDriverSpecific if the driver does not support repairs.
+func Repair(dbType string, args ...interface{}) (*RepairResult, error) {
+	drv, exists := drivers[dbType]
+	if !exists {
+		str := fmt.Sprintf("driver %q is not registered", dbType)
+		return nil, makeError(ErrDbUnknownType, str, nil)
+	}
+	if drv.Repair == nil {
+		str := fmt.Sprintf("driver %q does not support repairs", dbType)
+		return nil, makeError(ErrDriverSpecific, str, nil)
+	}
+
+	return drv.Repair(args...)
+}
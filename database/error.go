@@ -118,6 +118,14 @@ const (
 	// ErrBlockNotFound instead.
 	ErrBlockRegionInvalid
 
+	// ***********************************
+	// Errors related to database backups.
+	// ***********************************
+
+	// ErrInterrupted indicates a long running operation such as a backup
+	// was interrupted before it completed.
+	ErrInterrupted
+
 	// ***********************************
 	// Support for driver-specific errors.
 	// ***********************************
@@ -154,6 +162,7 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrBlockNotFound:      "ErrBlockNotFound",
 	ErrBlockExists:        "ErrBlockExists",
 	ErrBlockRegionInvalid: "ErrBlockRegionInvalid",
+	ErrInterrupted:        "ErrInterrupted",
 	ErrDriverSpecific:     "ErrDriverSpecific",
 }
 
@@ -38,6 +38,7 @@ func TestErrorCodeStringer(t *testing.T) {
 		{database.ErrBlockNotFound, "ErrBlockNotFound"},
 		{database.ErrBlockExists, "ErrBlockExists"},
 		{database.ErrBlockRegionInvalid, "ErrBlockRegionInvalid"},
+		{database.ErrInterrupted, "ErrInterrupted"},
 		{database.ErrDriverSpecific, "ErrDriverSpecific"},
 
 		{0xffff, "Unknown ErrorCode (65535)"},
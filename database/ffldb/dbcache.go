@@ -14,6 +14,7 @@ import (
 	"github.com/btcsuite/goleveldb/leveldb"
 	"github.com/btcsuite/goleveldb/leveldb/iterator"
 	"github.com/btcsuite/goleveldb/leveldb/util"
+	"github.com/coolsnady/hcd/database/internal/flatdb"
 	"github.com/coolsnady/hcd/database/internal/treap"
 )
 
@@ -271,13 +272,17 @@ func (iter *dbCacheIterator) Error() error {
 }
 
 // dbCacheSnapshot defines a snapshot of the database cache and underlying
-// database at a particular point in time.
+// database at a particular point in time.  It implements the
+// flatdb.MetadataSnapshot interface.
 type dbCacheSnapshot struct {
 	dbSnapshot    *leveldb.Snapshot
 	pendingKeys   *treap.Immutable
 	pendingRemove *treap.Immutable
 }
 
+// Enforce dbCacheSnapshot implements the flatdb.MetadataSnapshot interface.
+var _ flatdb.MetadataSnapshot = (*dbCacheSnapshot)(nil)
+
 // Has returns whether or not the passed key exists.
 func (snap *dbCacheSnapshot) Has(key []byte) bool {
 	// Check the cached entries first.
@@ -326,7 +331,7 @@ func (snap *dbCacheSnapshot) Release() {
 // The slice parameter allows the iterator to be limited to a range of keys.
 // The start key is inclusive and the limit key is exclusive.  Either or both
 // can be nil if the functionality is not desired.
-func (snap *dbCacheSnapshot) NewIterator(slice *util.Range) *dbCacheIterator {
+func (snap *dbCacheSnapshot) NewIterator(slice *util.Range) iterator.Iterator {
 	return &dbCacheIterator{
 		dbIter:        snap.dbSnapshot.NewIterator(slice, nil),
 		cacheIter:     newLdbCacheIter(snap, slice),
@@ -340,13 +345,13 @@ func (snap *dbCacheSnapshot) NewIterator(slice *util.Range) *dbCacheIterator {
 // configured value or it has been longer than the configured interval since the
 // last flush.  This effectively provides transaction batching so that callers
 // can commit transactions at will without incurring large performance hits due
-// to frequent disk syncs.
+// to frequent disk syncs.  It implements the flatdb.MetadataStore interface.
 type dbCache struct {
 	// ldb is the underlying leveldb DB for metadata.
 	ldb *leveldb.DB
 
-	// store is used to sync blocks to flat files.
-	store *blockStore
+	// syncBlocks is used to sync blocks to flat files.
+	syncBlocks func() error
 
 	// The following fields are related to flushing the cache to persistent
 	// storage.  Note that all flushing is performed in an opportunistic
@@ -379,11 +384,16 @@ type dbCache struct {
 	cachedRemove *treap.Immutable
 }
 
+// Enforce dbCache implements the flatdb.MetadataStore interface.
+var _ flatdb.MetadataStore = (*dbCache)(nil)
+
 // Snapshot returns a snapshot of the database cache and underlying database at
 // a particular point in time.
 //
 // The snapshot must be released after use by calling Release.
-func (c *dbCache) Snapshot() (*dbCacheSnapshot, error) {
+//
+// This function is part of the flatdb.MetadataStore interface implementation.
+func (c *dbCache) Snapshot() (flatdb.MetadataSnapshot, error) {
 	dbSnapshot, err := c.ldb.GetSnapshot()
 	if err != nil {
 		str := "failed to open transaction"
@@ -480,7 +490,7 @@ func (c *dbCache) flush() error {
 	// necessary before writing the metadata to prevent the case where the
 	// metadata contains information about a block which actually hasn't
 	// been written yet in unexpected shutdown scenarios.
-	if err := c.store.syncBlocks(); err != nil {
+	if err := c.syncBlocks(); err != nil {
 		return err
 	}
 
@@ -511,14 +521,20 @@ func (c *dbCache) flush() error {
 	return nil
 }
 
+// Flush flushes the database cache to persistent storage.
+//
+// This function is part of the flatdb.MetadataStore interface implementation.
+func (c *dbCache) Flush() error {
+	return c.flush()
+}
+
 // needsFlush returns whether or not the database cache needs to be flushed to
 // persistent storage based on its current size, whether or not adding all of
-// the entries in the passed database transaction would cause it to exceed the
-// configured limit, and how much time has elapsed since the last time the cache
-// was flushed.
+// the passed pending entries would cause it to exceed the configured limit, and
+// how much time has elapsed since the last time the cache was flushed.
 //
 // This function MUST be called with the database write lock held.
-func (c *dbCache) needsFlush(tx *transaction) bool {
+func (c *dbCache) needsFlush(pendingKeys, pendingRemove *treap.Mutable) bool {
 	// A flush is needed when more time has elapsed than the configured
 	// flush interval.
 	if time.Since(c.lastFlush) > c.flushInterval {
@@ -526,51 +542,43 @@ func (c *dbCache) needsFlush(tx *transaction) bool {
 	}
 
 	// A flush is needed when the size of the database cache exceeds the
-	// specified max cache size.  The total calculated size is multiplied by
-	// 1.5 here to account for additional memory consumption that will be
-	// needed during the flush as well as old nodes in the cache that are
-	// referenced by the snapshot used by the transaction.
-	snap := tx.snapshot
-	totalSize := snap.pendingKeys.Size() + snap.pendingRemove.Size()
+	// specified max cache size once the pending entries are added to it.
+	// The total calculated size is multiplied by 1.5 here to account for
+	// additional memory consumption that will be needed during the flush
+	// as well as old nodes in the cache that are referenced by snapshots.
+	c.cacheLock.RLock()
+	totalSize := c.cachedKeys.Size() + c.cachedRemove.Size()
+	c.cacheLock.RUnlock()
+	totalSize += pendingKeys.Size() + pendingRemove.Size()
 	totalSize = uint64(float64(totalSize) * 1.5)
 
 	return totalSize > c.maxSize
 }
 
-// commitTx atomically adds all of the pending keys to add and remove into the
+// Commit atomically adds all of the pending keys to add and remove into the
 // database cache.  When adding the pending keys would cause the size of the
 // cache to exceed the max cache size, or the time since the last flush exceeds
 // the configured flush interval, the cache will be flushed to the underlying
 // persistent database.
 //
 // This is an atomic operation with respect to the cache in that either all of
-// the pending keys to add and remove in the transaction will be applied or none
-// of them will.
+// the pending keys to add and remove will be applied or none of them will.
 //
 // The database cache itself might be flushed to the underlying persistent
 // database even if the transaction fails to apply, but it will only be the
-// state of the cache without the transaction applied.
+// state of the cache without the pending keys applied.
 //
-// This function MUST be called during a database write transaction which in
-// turn implies the database write lock will be held.
-func (c *dbCache) commitTx(tx *transaction) error {
-	// Flush the cache and write the current transaction directly to the
-	// database if a flush is needed.
-	if c.needsFlush(tx) {
+// This function is part of the flatdb.MetadataStore interface implementation.
+func (c *dbCache) Commit(pendingKeys, pendingRemove *treap.Mutable) error {
+	// Flush the cache and write the pending keys directly to the database
+	// if a flush is needed.
+	if c.needsFlush(pendingKeys, pendingRemove) {
 		if err := c.flush(); err != nil {
 			return err
 		}
 
 		// Perform all leveldb updates using an atomic transaction.
-		err := c.commitTreaps(tx.pendingKeys, tx.pendingRemove)
-		if err != nil {
-			return err
-		}
-
-		// Clear the transaction entries since they have been committed.
-		tx.pendingKeys = nil
-		tx.pendingRemove = nil
-		return nil
+		return c.commitTreaps(pendingKeys, pendingRemove)
 	}
 
 	// At this point a database flush is not needed, so atomically commit
@@ -584,21 +592,19 @@ func (c *dbCache) commitTx(tx *transaction) error {
 	newCachedRemove := c.cachedRemove
 	c.cacheLock.RUnlock()
 
-	// Apply every key to add in the database transaction to the cache.
-	tx.pendingKeys.ForEach(func(k, v []byte) bool {
+	// Apply every key to add to the cache.
+	pendingKeys.ForEach(func(k, v []byte) bool {
 		newCachedRemove = newCachedRemove.Delete(k)
 		newCachedKeys = newCachedKeys.Put(k, v)
 		return true
 	})
-	tx.pendingKeys = nil
 
-	// Apply every key to remove in the database transaction to the cache.
-	tx.pendingRemove.ForEach(func(k, v []byte) bool {
+	// Apply every key to remove to the cache.
+	pendingRemove.ForEach(func(k, v []byte) bool {
 		newCachedKeys = newCachedKeys.Delete(k)
 		newCachedRemove = newCachedRemove.Put(k, nil)
 		return true
 	})
-	tx.pendingRemove = nil
 
 	// Atomically replace the immutable treaps which hold the cached keys to
 	// add and delete.
@@ -612,7 +618,7 @@ func (c *dbCache) commitTx(tx *transaction) error {
 // Close cleanly shuts down the database cache by syncing all data and closing
 // the underlying leveldb database.
 //
-// This function MUST be called with the database write lock held.
+// This function is part of the flatdb.MetadataStore interface implementation.
 func (c *dbCache) Close() error {
 	// Flush any outstanding cached entries to disk.
 	if err := c.flush(); err != nil {
@@ -635,11 +641,12 @@ func (c *dbCache) Close() error {
 // newDbCache returns a new database cache instance backed by the provided
 // leveldb instance.  The cache will be flushed to leveldb when the max size
 // exceeds the provided value or it has been longer than the provided interval
-// since the last flush.
-func newDbCache(ldb *leveldb.DB, store *blockStore, maxSize uint64, flushIntervalSecs uint32) *dbCache {
+// since the last flush.  The passed function is invoked to sync the flat block
+// files before each flush.
+func newDbCache(ldb *leveldb.DB, syncBlocks func() error, maxSize uint64, flushIntervalSecs uint32) *dbCache {
 	return &dbCache{
 		ldb:           ldb,
-		store:         store,
+		syncBlocks:    syncBlocks,
 		maxSize:       maxSize,
 		flushInterval: time.Second * time.Duration(flushIntervalSecs),
 		lastFlush:     time.Now(),
@@ -26,5 +26,23 @@ database path as a string and the block network:
 	if err != nil {
 		// Handle error
 	}
+
+Block Compression
+
+An optional third parameter specifies a database.BlockCompression which is
+applied to all blocks stored after opening the database.  Blocks are only
+stored compressed when that reduces their size and blocks stored with any
+compression are transparently decompressed when they, or regions of them, are
+fetched.  Since regions of compressed blocks can only be extracted by
+decompressing the entire block, fetching them is more expensive.
+
+	db, err := database.Open("ffldb", "path/to/database", wire.MainNet,
+		database.BlockCompressionSnappy)
+	if err != nil {
+		// Handle error
+	}
+
+NOTE: Databases which contain compressed blocks can not be read by versions
+which predate block compression.
 */
 package ffldb
@@ -10,64 +10,53 @@ import (
 
 	"github.com/btcsuite/btclog"
 	"github.com/coolsnady/hcd/database"
-	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcd/database/internal/flatdb"
 )
 
-var log = btclog.Disabled
-
 const (
 	dbType = "ffldb"
 )
 
-// parseArgs parses the arguments from the database Open/Create methods.
-func parseArgs(funcName string, args ...interface{}) (string, wire.CurrencyNet, error) {
-	if len(args) != 2 {
-		return "", 0, fmt.Errorf("invalid arguments to %s.%s -- "+
-			"expected database path and block network", dbType,
-			funcName)
-	}
-
-	dbPath, ok := args[0].(string)
-	if !ok {
-		return "", 0, fmt.Errorf("first argument to %s.%s is invalid -- "+
-			"expected database path string", dbType, funcName)
-	}
-
-	network, ok := args[1].(wire.CurrencyNet)
-	if !ok {
-		return "", 0, fmt.Errorf("second argument to %s.%s is invalid -- "+
-			"expected block network", dbType, funcName)
-	}
-
-	return dbPath, network, nil
-}
-
 // openDBDriver is the callback provided during driver registration that opens
 // an existing database for use.
 func openDBDriver(args ...interface{}) (database.DB, error) {
-	dbPath, network, err := parseArgs("Open", args...)
+	dbPath, network, compression, err := flatdb.ParseArgs(dbType, "Open",
+		args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return openDB(dbPath, network, false)
+	return flatdb.Open(dbPath, network, compression, false, backend)
 }
 
 // createDBDriver is the callback provided during driver registration that
 // creates, initializes, and opens a database for use.
 func createDBDriver(args ...interface{}) (database.DB, error) {
-	dbPath, network, err := parseArgs("Create", args...)
+	dbPath, network, compression, err := flatdb.ParseArgs(dbType, "Create",
+		args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return flatdb.Open(dbPath, network, compression, true, backend)
+}
+
+// repairDBDriver is the callback provided during driver registration that
+// rebuilds the block index of an existing database.
+func repairDBDriver(args ...interface{}) (*database.RepairResult, error) {
+	dbPath, network, _, err := flatdb.ParseArgs(dbType, "Repair",
+		args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return openDB(dbPath, network, true)
+	return flatdb.Repair(dbPath, network, backend)
 }
 
 // useLogger is the callback provided during driver registration that sets the
 // current logger to the provided one.
 func useLogger(logger btclog.Logger) {
-	log = logger
+	flatdb.UseLogger(logger)
 }
 
 func init() {
@@ -76,6 +65,7 @@ func init() {
 		DbType:    dbType,
 		Create:    createDBDriver,
 		Open:      openDBDriver,
+		Repair:    repairDBDriver,
 		UseLogger: useLogger,
 	}
 	if err := database.RegisterDriver(driver); err != nil {
@@ -9,6 +9,8 @@
 package database
 
 import (
+	"fmt"
+
 	"github.com/coolsnady/hcd/chaincfg/chainhash"
 	"github.com/coolsnady/hcutil"
 )
@@ -465,3 +467,136 @@ type DB interface {
 	// back or committed).
 	Close() error
 }
+
+// BackupProgress describes the progress of a database backup as reported by
+// the Backup method of the Backuper interface.
+type BackupProgress struct {
+	// TotalBytes is the total number of bytes of block data the backup
+	// needs to copy and CopiedBytes is the number of bytes copied so far.
+	// Block data which was already copied by a previous attempt that is
+	// being resumed counts as copied.
+	TotalBytes  int64
+	CopiedBytes int64
+
+	// MetadataEntries is the number of metadata entries written so far.
+	// The metadata is written once all of the block data has been copied.
+	MetadataEntries uint64
+}
+
+// Backuper is an optional interface database drivers can implement to support
+// creating a consistent backup of a database while it is in use.
+type Backuper interface {
+	// Backup writes a consistent copy of the database as of the time the
+	// call is made to the provided destination directory.  The copy can be
+	// opened with the same database type once the call returns without an
+	// error.  Other transactions may be used while the backup is running.
+	//
+	// The passed progress function, which may be nil, is periodically
+	// invoked from the calling goroutine.  The backup stops as soon as
+	// possible once the passed interrupt channel is closed.
+	//
+	// Backups which failed or were interrupted are resumed when the call
+	// is repeated with the same destination, so data which was already
+	// copied does not need to be copied again.  Data which changed in the
+	// source database since it was copied, for example because the
+	// database was rewritten, is copied again.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrDbExists if the destination already contains a database
+	//   - ErrInterrupted if the interrupt channel was closed before the
+	//     backup completed
+	//   - ErrDbNotOpen if the database is not open
+	Backup(destPath string, progress func(BackupProgress), interrupt <-chan struct{}) error
+}
+
+// RepairResult describes the outcome of rebuilding the block index of a
+// database with Repair.
+type RepairResult struct {
+	// PrevBlocks is the number of blocks the block index referenced before
+	// it was rebuilt and Blocks is the number of blocks it references now.
+	PrevBlocks int
+	Blocks     int
+
+	// DiscardedBytes is the number of bytes of block data which were
+	// removed or are no longer referenced because they do not contain a
+	// valid block.
+	DiscardedBytes int64
+}
+
+// BlockFilesReport describes block data which is stored by a database but not
+// referenced by its block index as found by the VerifyBlockFiles method of the
+// BlockFileVerifier interface.
+type BlockFilesReport struct {
+	// Blocks is the number of valid blocks found in the stored block data.
+	Blocks int
+
+	// UnindexedBlocks contains the hashes of the valid blocks found in the
+	// stored block data which are not referenced by the block index.
+	UnindexedBlocks []chainhash.Hash
+
+	// InvalidRegions describes the regions of the stored block data which
+	// do not contain a valid block.
+	InvalidRegions []string
+
+	// OrphanedFiles contains the paths of the block files in the database
+	// directory which are not referenced by the metadata.
+	OrphanedFiles []string
+}
+
+// BlockFileVerifier is an optional interface database drivers which store
+// blocks in files can implement to find block data which is not referenced
+// by the block index.
+type BlockFileVerifier interface {
+	// VerifyBlockFiles scans all stored block data and reports the
+	// blocks and files it contains which are not referenced by the block
+	// index.  The scan stops once the passed interrupt channel is closed
+	// and ErrInterrupted is returned.
+	VerifyBlockFiles(interrupt <-chan struct{}) (*BlockFilesReport, error)
+}
+
+// BlockCompression identifies a compression algorithm database drivers which
+// support it can apply to the blocks they store.  It is passed as an optional
+// argument when opening or creating a database and only affects blocks which
+// are stored afterwards.  Blocks which were stored with any compression are
+// always readable.
+//
+// NOTE: The values are stored alongside compressed blocks, so they must not be
+// changed.
+type BlockCompression uint8
+
+// These constants define the supported block compression algorithms.
+const (
+	// BlockCompressionNone stores blocks without compression.
+	BlockCompressionNone BlockCompression = 0
+
+	// BlockCompressionSnappy compresses blocks with snappy.
+	BlockCompressionSnappy BlockCompression = 1
+)
+
+// blockCompressionStrings is a map of block compression algorithms back to
+// their constant names for pretty printing.
+var blockCompressionStrings = map[BlockCompression]string{
+	BlockCompressionNone:   "none",
+	BlockCompressionSnappy: "snappy",
+}
+
+// String returns the name of the block compression algorithm.
+func (c BlockCompression) String() string {
+	if s := blockCompressionStrings[c]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown BlockCompression (%d)", uint8(c))
+}
+
+// ParseBlockCompression returns the block compression algorithm with the
+// provided name as returned by its String method.
+func ParseBlockCompression(name string) (BlockCompression, error) {
+	for c, s := range blockCompressionStrings {
+		if s == name {
+			return c, nil
+		}
+	}
+	return BlockCompressionNone, fmt.Errorf("unknown block compression "+
+		"%q", name)
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package database_test
+
+import (
+	"testing"
+
+	"github.com/coolsnady/hcd/database"
+)
+
+// TestBlockCompressionStringer tests the stringized output for the
+// BlockCompression type and that it is parsed back by ParseBlockCompression.
+func TestBlockCompressionStringer(t *testing.T) {
+	tests := []struct {
+		in   database.BlockCompression
+		want string
+	}{
+		{database.BlockCompressionNone, "none"},
+		{database.BlockCompressionSnappy, "snappy"},
+		{0xff, "Unknown BlockCompression (255)"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		result := test.in.String()
+		if result != test.want {
+			t.Errorf("String #%d\ngot: %s\nwant: %s", i, result,
+				test.want)
+			continue
+		}
+
+		parsed, err := database.ParseBlockCompression(result)
+		if i == len(tests)-1 {
+			if err == nil {
+				t.Errorf("ParseBlockCompression #%d: unexpected "+
+					"success", i)
+			}
+			continue
+		}
+		if err != nil || parsed != test.in {
+			t.Errorf("ParseBlockCompression #%d: got %v (%v), want %v",
+				i, parsed, err, test.in)
+		}
+	}
+}
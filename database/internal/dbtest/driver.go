@@ -0,0 +1,700 @@
+// Copyright (c) 2015-2016 The btcsuite developers
+// Copyright (c) 2016 The Decred developers
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the tests of the backend drivers which are shared by all
+// of them.  Each driver should have their own driver_test.go file which invokes
+// each of the exported test functions in this file with its database type.
+
+package dbtest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/coolsnady/hcd/chaincfg"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/database/internal/flatdb"
+	"github.com/coolsnady/hcutil"
+)
+
+// TestCreateOpenFail ensures that errors related to creating and opening a
+// database are handled properly.
+func TestCreateOpenFail(t *testing.T, dbType string) {
+	t.Parallel()
+
+	// Ensure that attempting to open a database that doesn't exist returns
+	// the expected error.
+	wantErrCode := database.ErrDbDoesNotExist
+	_, err := database.Open(dbType, "noexist", BlockDataNet)
+	if !CheckDbError(t, "Open", err, wantErrCode) {
+		return
+	}
+
+	// Ensure that attempting to open a database with the wrong number of
+	// parameters returns the expected error.
+	wantErr := fmt.Errorf("invalid arguments to %s.Open -- expected "+
+		"database path, block network, and optional block "+
+		"compression", dbType)
+	_, err = database.Open(dbType, 1, 2, 3, 4)
+	if err.Error() != wantErr.Error() {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure that attempting to open a database with an invalid type for
+	// the first parameter returns the expected error.
+	wantErr = fmt.Errorf("first argument to %s.Open is invalid -- "+
+		"expected database path string", dbType)
+	_, err = database.Open(dbType, 1, BlockDataNet)
+	if err.Error() != wantErr.Error() {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure that attempting to open a database with an invalid type for
+	// the second parameter returns the expected error.
+	wantErr = fmt.Errorf("second argument to %s.Open is invalid -- "+
+		"expected block network", dbType)
+	_, err = database.Open(dbType, "noexist", "invalid")
+	if err.Error() != wantErr.Error() {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure that attempting to open a database with an invalid type or
+	// value for the third parameter returns the expected error.
+	wantErr = fmt.Errorf("third argument to %s.Open is invalid -- "+
+		"expected block compression", dbType)
+	_, err = database.Open(dbType, "noexist", BlockDataNet, "snappy")
+	if err.Error() != wantErr.Error() {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+	wantErr = fmt.Errorf("third argument to %s.Open is invalid -- "+
+		"unsupported Unknown BlockCompression (255)", dbType)
+	_, err = database.Open(dbType, "noexist", BlockDataNet,
+		database.BlockCompression(0xff))
+	if err.Error() != wantErr.Error() {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure that attempting to create a database with the wrong number of
+	// parameters returns the expected error.
+	wantErr = fmt.Errorf("invalid arguments to %s.Create -- expected "+
+		"database path, block network, and optional block "+
+		"compression", dbType)
+	_, err = database.Create(dbType, 1, 2, 3, 4)
+	if err.Error() != wantErr.Error() {
+		t.Errorf("Create: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure that attempting to create a database with an invalid type for
+	// the first parameter returns the expected error.
+	wantErr = fmt.Errorf("first argument to %s.Create is invalid -- "+
+		"expected database path string", dbType)
+	_, err = database.Create(dbType, 1, BlockDataNet)
+	if err.Error() != wantErr.Error() {
+		t.Errorf("Create: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure that attempting to create a database with an invalid type for
+	// the second parameter returns the expected error.
+	wantErr = fmt.Errorf("second argument to %s.Create is invalid -- "+
+		"expected block network", dbType)
+	_, err = database.Create(dbType, "noexist", "invalid")
+	if err.Error() != wantErr.Error() {
+		t.Errorf("Create: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure operations against a closed database return the expected
+	// error.
+	dbPath := filepath.Join(os.TempDir(), dbType+"-createfail")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, BlockDataNet)
+	if err != nil {
+		t.Errorf("Create: unexpected error: %v", err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	db.Close()
+
+	wantErrCode = database.ErrDbNotOpen
+	err = db.View(func(tx database.Tx) error {
+		return nil
+	})
+	if !CheckDbError(t, "View", err, wantErrCode) {
+		return
+	}
+
+	wantErrCode = database.ErrDbNotOpen
+	err = db.Update(func(tx database.Tx) error {
+		return nil
+	})
+	if !CheckDbError(t, "Update", err, wantErrCode) {
+		return
+	}
+
+	wantErrCode = database.ErrDbNotOpen
+	_, err = db.Begin(false)
+	if !CheckDbError(t, "Begin(false)", err, wantErrCode) {
+		return
+	}
+
+	wantErrCode = database.ErrDbNotOpen
+	_, err = db.Begin(true)
+	if !CheckDbError(t, "Begin(true)", err, wantErrCode) {
+		return
+	}
+
+	wantErrCode = database.ErrDbNotOpen
+	err = db.Close()
+	if !CheckDbError(t, "Close", err, wantErrCode) {
+		return
+	}
+}
+
+// TestPersistence ensures that values stored are still valid after closing and
+// reopening the database.
+func TestPersistence(t *testing.T, dbType string) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), dbType+"-persistencetest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, BlockDataNet)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	// Create a bucket, put some values into it, and store a block so they
+	// can be tested for existence on re-open.
+	bucket1Key := []byte("bucket1")
+	storeValues := map[string]string{
+		"b1key1": "foo1",
+		"b1key2": "foo2",
+		"b1key3": "foo3",
+	}
+	genesisBlock := hcutil.NewBlock(chaincfg.MainNetParams.GenesisBlock)
+	genesisHash := chaincfg.MainNetParams.GenesisHash
+	err = db.Update(func(tx database.Tx) error {
+		metadataBucket := tx.Metadata()
+		if metadataBucket == nil {
+			return fmt.Errorf("Metadata: unexpected nil bucket")
+		}
+
+		bucket1, err := metadataBucket.CreateBucket(bucket1Key)
+		if err != nil {
+			return fmt.Errorf("CreateBucket: unexpected error: %v",
+				err)
+		}
+
+		for k, v := range storeValues {
+			err := bucket1.Put([]byte(k), []byte(v))
+			if err != nil {
+				return fmt.Errorf("Put: unexpected error: %v",
+					err)
+			}
+		}
+
+		if err := tx.StoreBlock(genesisBlock); err != nil {
+			return fmt.Errorf("StoreBlock: unexpected error: %v",
+				err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Update: unexpected error: %v", err)
+		return
+	}
+
+	// Close and reopen the database to ensure the values persist.
+	db.Close()
+	db, err = database.Open(dbType, dbPath, BlockDataNet)
+	if err != nil {
+		t.Errorf("failed to open test database (%s) %v", dbType, err)
+		return
+	}
+	defer db.Close()
+
+	// Ensure the values previously stored in the 3rd namespace still exist
+	// and are correct.
+	err = db.View(func(tx database.Tx) error {
+		metadataBucket := tx.Metadata()
+		if metadataBucket == nil {
+			return fmt.Errorf("Metadata: unexpected nil bucket")
+		}
+
+		bucket1 := metadataBucket.Bucket(bucket1Key)
+		if bucket1 == nil {
+			return fmt.Errorf("bucket1: unexpected nil bucket")
+		}
+
+		for k, v := range storeValues {
+			gotVal := bucket1.Get([]byte(k))
+			if !reflect.DeepEqual(gotVal, []byte(v)) {
+				return fmt.Errorf("Get: key '%s' does not "+
+					"match expected value - got %s, want %s",
+					k, gotVal, v)
+			}
+		}
+
+		genesisBlockBytes, _ := genesisBlock.Bytes()
+		gotBytes, err := tx.FetchBlock(genesisHash)
+		if err != nil {
+			return fmt.Errorf("FetchBlock: unexpected error: %v",
+				err)
+		}
+		if !reflect.DeepEqual(gotBytes, genesisBlockBytes) {
+			return fmt.Errorf("FetchBlock: stored block mismatch")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Errorf("View: unexpected error: %v", err)
+		return
+	}
+}
+
+// TestInterface performs all interfaces tests for the passed database driver
+// type.
+func TestInterface(t *testing.T, dbType string) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), dbType+"-interfacetest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, BlockDataNet)
+	if err != nil {
+		t.Errorf("failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	// Ensure the driver type is the expected value.
+	gotDbType := db.Type()
+	if gotDbType != dbType {
+		t.Errorf("Type: unepxected driver type - got %v, want %v",
+			gotDbType, dbType)
+		return
+	}
+
+	// Run all of the interface tests against the database.
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	// Change the maximum file size to a small value to force multiple flat
+	// files with the test data set.
+	flatdb.RunWithMaxBlockFileSize(db, 2048, func() {
+		testInterface(t, db)
+	})
+}
+
+// TestInterfaceCompressed performs all interfaces tests for the passed database
+// driver type with block compression enabled.
+func TestInterfaceCompressed(t *testing.T, dbType string) {
+	t.Parallel()
+
+	// Create a new database with compression to run tests against.
+	dbPath := filepath.Join(os.TempDir(), dbType+"-interfacetest-compressed")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, BlockDataNet,
+		database.BlockCompressionSnappy)
+	if err != nil {
+		t.Errorf("failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	// Change the maximum file size to a small value to force multiple flat
+	// files with the test data set.
+	flatdb.RunWithMaxBlockFileSize(db, 2048, func() {
+		testInterface(t, db)
+	})
+}
+
+// TestMixedCompression ensures blocks stored with and without compression in
+// the same database can be fetched in full and by region and are found by the
+// block file verification and repair.
+func TestMixedCompression(t *testing.T, dbType string) {
+	t.Parallel()
+
+	blocks, err := LoadBlocks(t, BlockDataFile, BlockDataNet)
+	if err != nil {
+		t.Fatalf("LoadBlocks: Unexpected error: %v", err)
+	}
+	dbPath := filepath.Join(os.TempDir(), dbType+"-mixedcompressiontest")
+	_ = os.RemoveAll(dbPath)
+	defer os.RemoveAll(dbPath)
+
+	// Store the first half of the blocks without compression and the rest
+	// with compression.
+	half := len(blocks) / 2
+	storeBlocks := func(db database.DB, blocks []*hcutil.Block) error {
+		return db.Update(func(tx database.Tx) error {
+			for _, block := range blocks {
+				if err := tx.StoreBlock(block); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	db, err := database.Create(dbType, dbPath, BlockDataNet)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	err = storeBlocks(db, blocks[:half])
+	db.Close()
+	if err != nil {
+		t.Fatalf("StoreBlock: unexpected error: %v", err)
+	}
+	db, err = database.Open(dbType, dbPath, BlockDataNet,
+		database.BlockCompressionSnappy)
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	if err := storeBlocks(db, blocks[half:]); err != nil {
+		db.Close()
+		t.Fatalf("StoreBlock: unexpected error: %v", err)
+	}
+
+	// checkBlocks ensures all blocks, their headers, and a region of each
+	// of them match the original blocks.
+	checkBlocks := func(db database.DB) error {
+		return db.View(func(tx database.Tx) error {
+			for i, block := range blocks {
+				wantBytes, err := block.Bytes()
+				if err != nil {
+					return err
+				}
+				gotBytes, err := tx.FetchBlock(block.Hash())
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(gotBytes, wantBytes) {
+					return fmt.Errorf("block #%d mismatch", i)
+				}
+				_, err = tx.FetchBlockHeader(block.Hash())
+				if err != nil {
+					return err
+				}
+
+				region := database.BlockRegion{
+					Hash:   block.Hash(),
+					Offset: uint32(len(wantBytes) / 2),
+					Len:    uint32(len(wantBytes) - len(wantBytes)/2),
+				}
+				gotRegion, err := tx.FetchBlockRegion(&region)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(gotRegion, wantBytes[region.Offset:]) {
+					return fmt.Errorf("block #%d region mismatch",
+						i)
+				}
+
+				// Regions past the end of the block are invalid
+				// regardless of the compression.
+				region.Len++
+				_, err = tx.FetchBlockRegions([]database.BlockRegion{region})
+				dbErr, ok := err.(database.Error)
+				if !ok || dbErr.ErrorCode != database.ErrBlockRegionInvalid {
+					return fmt.Errorf("block #%d region past "+
+						"end: unexpected error %v", i, err)
+				}
+			}
+			return nil
+		})
+	}
+	err = checkBlocks(db)
+	if err != nil {
+		db.Close()
+		t.Fatalf("checkBlocks: %v", err)
+	}
+
+	// Ensure the block file verification finds the same locations which
+	// are stored in the block index.
+	report, err := db.(database.BlockFileVerifier).VerifyBlockFiles(nil)
+	db.Close()
+	if err != nil {
+		t.Fatalf("VerifyBlockFiles: unexpected error: %v", err)
+	}
+	if report.Blocks != len(blocks) || len(report.UnindexedBlocks) != 0 ||
+		len(report.InvalidRegions) != 0 {
+
+		t.Fatalf("VerifyBlockFiles: unexpected report %+v", report)
+	}
+
+	// Ensure the blocks are unchanged after rebuilding the block index.
+	result, err := database.Repair(dbType, dbPath, BlockDataNet)
+	if err != nil {
+		t.Fatalf("Repair: unexpected error: %v", err)
+	}
+	if result.Blocks != len(blocks) || result.DiscardedBytes != 0 {
+		t.Fatalf("Repair: unexpected result %+v", result)
+	}
+	db, err = database.Open(dbType, dbPath, BlockDataNet)
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	defer db.Close()
+	if err := checkBlocks(db); err != nil {
+		t.Fatalf("checkBlocks after repair: %v", err)
+	}
+}
+
+// TestBackup ensures a backup of a database can be interrupted, resumed, and
+// then opened with the same contents as the original database.
+func TestBackup(t *testing.T, dbType string) {
+	t.Parallel()
+
+	// Create a new database and store some blocks and values in it.
+	dbPath := filepath.Join(os.TempDir(), dbType+"-backuptest")
+	backupPath := filepath.Join(os.TempDir(), dbType+"-backuptest-dest")
+	_ = os.RemoveAll(dbPath)
+	_ = os.RemoveAll(backupPath)
+	db, err := database.Create(dbType, dbPath, BlockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer os.RemoveAll(backupPath)
+	defer db.Close()
+
+	blocks, err := LoadBlocks(t, BlockDataFile, BlockDataNet)
+	if err != nil {
+		t.Fatalf("LoadBlocks: Unexpected error: %v", err)
+	}
+	bucketKey := []byte("backupbucket")
+	err = db.Update(func(tx database.Tx) error {
+		bucket, err := tx.Metadata().CreateBucket(bucketKey)
+		if err != nil {
+			return err
+		}
+		for i, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+			key := []byte(fmt.Sprintf("key%d", i))
+			if err := bucket.Put(key, block.Hash()[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	backuper, ok := db.(database.Backuper)
+	if !ok {
+		t.Fatalf("database does not implement the backuper interface")
+	}
+
+	// Ensure an interrupted backup returns the expected error.
+	interrupt := make(chan struct{})
+	close(interrupt)
+	err = backuper.Backup(backupPath, nil, interrupt)
+	if !CheckDbError(t, "Backup", err, database.ErrInterrupted) {
+		return
+	}
+
+	// Ensure the backup can be resumed and the progress covers all of the
+	// block data.
+	var lastProgress database.BackupProgress
+	progress := func(p database.BackupProgress) {
+		lastProgress = p
+	}
+	if err := backuper.Backup(backupPath, progress, nil); err != nil {
+		t.Fatalf("Backup: unexpected error: %v", err)
+	}
+	if lastProgress.TotalBytes == 0 ||
+		lastProgress.CopiedBytes != lastProgress.TotalBytes ||
+		lastProgress.MetadataEntries == 0 {
+
+		t.Fatalf("Backup: unexpected final progress %+v", lastProgress)
+	}
+
+	// Ensure a completed backup is not overwritten.
+	err = backuper.Backup(backupPath, nil, nil)
+	if !CheckDbError(t, "Backup", err, database.ErrDbExists) {
+		return
+	}
+
+	// Ensure the backup contains all blocks and values.
+	backupDB, err := database.Open(dbType, backupPath, BlockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to open backup database (%s) %v", dbType, err)
+	}
+	defer backupDB.Close()
+	err = backupDB.View(func(tx database.Tx) error {
+		bucket := tx.Metadata().Bucket(bucketKey)
+		if bucket == nil {
+			return fmt.Errorf("Bucket: unexpected nil bucket")
+		}
+		for i, block := range blocks {
+			key := []byte(fmt.Sprintf("key%d", i))
+			if !reflect.DeepEqual(bucket.Get(key), block.Hash()[:]) {
+				return fmt.Errorf("Get: unexpected value for %s", key)
+			}
+			blockBytes, err := tx.FetchBlock(block.Hash())
+			if err != nil {
+				return err
+			}
+			wantBytes, err := block.Bytes()
+			if err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(blockBytes, wantBytes) {
+				return fmt.Errorf("FetchBlock: mismatched block %d", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+}
+
+// TestRepair ensures the block files of a database are verified as expected
+// and that the block index of a database which can't be opened due to a
+// truncated block file is rebuilt by Repair.
+func TestRepair(t *testing.T, dbType string) {
+	t.Parallel()
+
+	// Create a new database and store the test blocks in it using small
+	// block files so they are spread over several files.
+	dbPath := filepath.Join(os.TempDir(), dbType+"-repairtest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, BlockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	blocks, err := LoadBlocks(t, BlockDataFile, BlockDataNet)
+	if err != nil {
+		db.Close()
+		t.Fatalf("LoadBlocks: Unexpected error: %v", err)
+	}
+	flatdb.RunWithMaxBlockFileSize(db, 8192, func() {
+		err = db.Update(func(tx database.Tx) error {
+			for _, block := range blocks {
+				if err := tx.StoreBlock(block); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	// Ensure the block files of the consistent database only contain
+	// indexed blocks and a block file which is not referenced is
+	// reported.
+	orphanPath := filepath.Join(dbPath, "000999999.fdb")
+	if err := ioutil.WriteFile(orphanPath, []byte{0x01}, 0600); err != nil {
+		db.Close()
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	report, err := db.(database.BlockFileVerifier).VerifyBlockFiles(nil)
+	db.Close()
+	if err != nil {
+		t.Fatalf("VerifyBlockFiles: unexpected error: %v", err)
+	}
+	if report.Blocks != len(blocks) || len(report.UnindexedBlocks) != 0 ||
+		len(report.InvalidRegions) != 0 ||
+		!reflect.DeepEqual(report.OrphanedFiles, []string{orphanPath}) {
+
+		t.Fatalf("VerifyBlockFiles: unexpected report %+v", report)
+	}
+	if err := os.Remove(orphanPath); err != nil {
+		t.Fatalf("Remove: unexpected error: %v", err)
+	}
+
+	// Truncate the last block file so the database can no longer be
+	// opened since the metadata refers to data which no longer exists.
+	var lastFilePath string
+	for i := 0; ; i++ {
+		filePath := filepath.Join(dbPath, fmt.Sprintf("%09d.fdb", i))
+		if _, err := os.Stat(filePath); err != nil {
+			break
+		}
+		lastFilePath = filePath
+	}
+	fi, err := os.Stat(lastFilePath)
+	if err != nil {
+		t.Fatalf("Stat: unexpected error: %v", err)
+	}
+	if err := os.Truncate(lastFilePath, fi.Size()-1); err != nil {
+		t.Fatalf("Truncate: unexpected error: %v", err)
+	}
+	_, err = database.Open(dbType, dbPath, BlockDataNet)
+	if !CheckDbError(t, "Open", err, database.ErrCorruption) {
+		return
+	}
+
+	// Ensure the repair drops the truncated last block and the remaining
+	// blocks are available afterwards.
+	result, err := database.Repair(dbType, dbPath, BlockDataNet)
+	if err != nil {
+		t.Fatalf("Repair: unexpected error: %v", err)
+	}
+	if result.PrevBlocks != len(blocks) ||
+		result.Blocks != len(blocks)-1 || result.DiscardedBytes == 0 {
+
+		t.Fatalf("Repair: unexpected result %+v", result)
+	}
+	db, err = database.Open(dbType, dbPath, BlockDataNet)
+	if err != nil {
+		t.Fatalf("Open: unexpected error after repair: %v", err)
+	}
+	defer db.Close()
+	lastBlock := blocks[len(blocks)-1]
+	err = db.Update(func(tx database.Tx) error {
+		for _, block := range blocks[:len(blocks)-1] {
+			if _, err := tx.FetchBlock(block.Hash()); err != nil {
+				return err
+			}
+			if _, err := tx.FetchBlockHeader(block.Hash()); err != nil {
+				return err
+			}
+		}
+		if ok, _ := tx.HasBlock(lastBlock.Hash()); ok {
+			return fmt.Errorf("HasBlock: truncated block still exists")
+		}
+		return tx.StoreBlock(lastBlock)
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error after repair: %v", err)
+	}
+}
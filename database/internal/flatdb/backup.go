@@ -0,0 +1,500 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package flatdb
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/btcsuite/goleveldb/leveldb/util"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/database/internal/treap"
+)
+
+const (
+	// backupChunkSize is the number of bytes of block data copied at a
+	// time when backing up the database.  The interrupt channel is checked
+	// and progress is reported between chunks.
+	backupChunkSize = 4 * 1024 * 1024 // 4 MiB
+
+	// backupMetaBatchBytes is the approximate maximum number of bytes of
+	// metadata committed at a time when backing up the database.
+	backupMetaBatchBytes = 16 * 1024 * 1024 // 16 MiB
+
+	// backupMetaTmpSuffix is the suffix of the name the metadata store is
+	// written to by a backup.  It is renamed to the final name once it is
+	// complete so an incomplete backup is never mistaken for a usable
+	// database.
+	backupMetaTmpSuffix = ".tmp"
+
+	// backupManifestName is the name of the file in the destination
+	// directory of an incomplete backup which records how much of each
+	// block file was copied along with a checksum of the copied bytes.  It
+	// is removed once the backup is complete.
+	backupManifestName = "backup.manifest"
+
+	// backupManifestEntrySize is the size of a serialized manifest entry.
+	// Each entry is the file number, the number of copied bytes, and the
+	// checksum of the copied bytes.
+	backupManifestEntrySize = 4 + 8 + 4
+)
+
+// interruptRequested returns true when the passed channel has been closed.
+func interruptRequested(interrupt <-chan struct{}) bool {
+	select {
+	case <-interrupt:
+		return true
+	default:
+	}
+	return false
+}
+
+// errBackupInterrupted returns the error used when a backup is interrupted.
+func errBackupInterrupted() database.Error {
+	return makeDbErr(database.ErrInterrupted, "backup interrupted", nil)
+}
+
+// committedFile describes a flat block file along with the number of bytes of
+// it which are referenced by the committed metadata.
+type committedFile struct {
+	fileNum uint32
+	size    int64
+}
+
+// committedBlockFiles returns the flat block files referenced by metadata with
+// the provided write cursor location.  All files prior to the current write
+// file are complete, so they are referenced in full, while only the committed
+// portion of the current write file is referenced.
+func committedBlockFiles(dbPath string, curFileNum, curOffset uint32) ([]committedFile, error) {
+	files := make([]committedFile, 0, curFileNum+1)
+	for fileNum := uint32(0); fileNum <= curFileNum; fileNum++ {
+		if fileNum == curFileNum {
+			files = append(files, committedFile{fileNum, int64(curOffset)})
+			break
+		}
+
+		fi, err := os.Stat(blockFilePath(dbPath, fileNum))
+		if err != nil {
+			str := fmt.Sprintf("failed to stat block file %d: %v",
+				fileNum, err)
+			return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+		files = append(files, committedFile{fileNum, fi.Size()})
+	}
+	return files, nil
+}
+
+// backupFileState describes the portion of a block file which was copied to
+// the destination by a backup along with the CRC-32C checksum of it.
+type backupFileState struct {
+	copied   int64
+	checksum uint32
+}
+
+// backupManifest maps the numbers of the block files of an incomplete backup
+// to the portion of them which was copied.  It allows a resumed backup to
+// detect block files which were rewritten under the same name, such as by a
+// recompression of the database, since the copied bytes are no longer a prefix
+// of them.
+type backupManifest map[uint32]backupFileState
+
+// readBackupManifest reads the backup manifest at the passed path.  An empty
+// manifest is returned when the file does not exist or is corrupt, which makes
+// the backup copy all block files again.
+func readBackupManifest(path string) backupManifest {
+	manifest := make(backupManifest)
+	serialized, err := ioutil.ReadFile(path)
+	if err != nil || len(serialized) < 4 ||
+		(len(serialized)-4)%backupManifestEntrySize != 0 {
+
+		return manifest
+	}
+	n := len(serialized) - 4
+	if crc32.Checksum(serialized[:n], castagnoli) !=
+		byteOrder.Uint32(serialized[n:]) {
+
+		return manifest
+	}
+	for offset := 0; offset < n; offset += backupManifestEntrySize {
+		entry := serialized[offset : offset+backupManifestEntrySize]
+		manifest[byteOrder.Uint32(entry[0:4])] = backupFileState{
+			copied:   int64(byteOrder.Uint64(entry[4:12])),
+			checksum: byteOrder.Uint32(entry[12:16]),
+		}
+	}
+	return manifest
+}
+
+// write atomically replaces the backup manifest at the passed path with the
+// manifest.  The entries are ordered by file number and followed by a CRC-32C
+// checksum of them.
+func (m backupManifest) write(path string) error {
+	fileNums := make([]int, 0, len(m))
+	for fileNum := range m {
+		fileNums = append(fileNums, int(fileNum))
+	}
+	sort.Ints(fileNums)
+
+	serialized := make([]byte, len(m)*backupManifestEntrySize+4)
+	for i, fileNum := range fileNums {
+		state := m[uint32(fileNum)]
+		entry := serialized[i*backupManifestEntrySize:]
+		byteOrder.PutUint32(entry[0:4], uint32(fileNum))
+		byteOrder.PutUint64(entry[4:12], uint64(state.copied))
+		byteOrder.PutUint32(entry[12:16], state.checksum)
+	}
+	n := len(serialized) - 4
+	byteOrder.PutUint32(serialized[n:], crc32.Checksum(serialized[:n],
+		castagnoli))
+
+	tmpPath := path + backupMetaTmpSuffix
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(serialized); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// checksumFilePrefix returns the CRC-32C checksum of the first size bytes of
+// the passed file.  False is returned when the file is shorter than that.
+func checksumFilePrefix(file *os.File, size int64, interrupt <-chan struct{}) (uint32, bool, error) {
+	buf := make([]byte, backupChunkSize)
+	var checksum uint32
+	for offset := int64(0); offset < size; {
+		if interruptRequested(interrupt) {
+			return 0, false, errBackupInterrupted()
+		}
+
+		n := int64(len(buf))
+		if remaining := size - offset; remaining < n {
+			n = remaining
+		}
+		_, err := io.ReadFull(file, buf[:n])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		checksum = crc32.Update(checksum, castagnoli, buf[:n])
+		offset += n
+	}
+	return checksum, true, nil
+}
+
+// copyFilePrefix copies the first size bytes of the source file to the
+// destination file and updates the passed state to describe the copied bytes.
+//
+// The state describes the bytes copied by a previous backup attempt, if any.
+// They are only reused when the destination file still contains them and the
+// checksum of the same bytes of the source file matches, which ensures the
+// source file was not rewritten in the meantime.  Otherwise, the destination
+// file is copied from the start.  The passed function is invoked with the
+// number of reused bytes and then with the number of newly copied bytes after
+// each chunk.
+func copyFilePrefix(srcPath, dstPath string, size int64, state *backupFileState, copied func(n int64), interrupt <-chan struct{}) error {
+	dst, err := os.OpenFile(dstPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	var offset int64
+	var checksum uint32
+	if state.copied > 0 {
+		fi, err := dst.Stat()
+		if err != nil {
+			return err
+		}
+		if state.copied <= size && fi.Size() >= state.copied {
+			sum, ok, err := checksumFilePrefix(src, state.copied,
+				interrupt)
+			if err != nil {
+				return err
+			}
+			if ok && sum == state.checksum {
+				offset, checksum = state.copied, sum
+			}
+		}
+		if offset == 0 {
+			log.Infof("Block file %s changed since the backup was "+
+				"interrupted -- copying it again", srcPath)
+		}
+	}
+
+	// Discard anything in the destination file after the reused bytes,
+	// which includes bytes which are not described by the state since they
+	// were written after it was last recorded.
+	if err := dst.Truncate(offset); err != nil {
+		return err
+	}
+	*state = backupFileState{copied: offset, checksum: checksum}
+	copied(offset)
+	if offset == size {
+		return nil
+	}
+
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := dst.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	buf := make([]byte, backupChunkSize)
+	for offset < size {
+		if interruptRequested(interrupt) {
+			// Sync what was copied so far so it can be reused when
+			// the backup is resumed.
+			if err := dst.Sync(); err != nil {
+				return err
+			}
+			return errBackupInterrupted()
+		}
+
+		n := int64(len(buf))
+		if remaining := size - offset; remaining < n {
+			n = remaining
+		}
+		if _, err := io.ReadFull(src, buf[:n]); err != nil {
+			return err
+		}
+		if _, err := dst.Write(buf[:n]); err != nil {
+			return err
+		}
+		offset += n
+		state.copied = offset
+		state.checksum = crc32.Update(state.checksum, castagnoli, buf[:n])
+		copied(n)
+	}
+	return dst.Sync()
+}
+
+// backupBlockFiles copies the passed flat block files from the source database
+// path to the destination path while updating and reporting the passed
+// progress.  The copied portion of each file is recorded in the backup manifest
+// of the destination directory so an interrupted backup can be resumed.
+func backupBlockFiles(srcPath, dstPath string, files []committedFile, progress *database.BackupProgress, report func(), interrupt <-chan struct{}) error {
+	for _, file := range files {
+		progress.TotalBytes += file.size
+	}
+	report()
+
+	// Remove the block files of a previous attempt which are not part of
+	// this backup, which happens when the source database was replaced by
+	// one with fewer block files in the meantime.
+	manifestPath := filepath.Join(dstPath, backupManifestName)
+	manifest := readBackupManifest(manifestPath)
+	lastFileNum := files[len(files)-1].fileNum
+	orphaned, err := orphanedBlockFiles(dstPath, lastFileNum)
+	if err != nil {
+		str := fmt.Sprintf("failed to list block files: %v", err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	for _, path := range orphaned {
+		if err := os.Remove(path); err != nil {
+			str := fmt.Sprintf("failed to remove stale block file: %v",
+				err)
+			return makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+	}
+	for fileNum := range manifest {
+		if fileNum > lastFileNum {
+			delete(manifest, fileNum)
+		}
+	}
+
+	for _, file := range files {
+		srcFile := blockFilePath(srcPath, file.fileNum)
+		dstFile := blockFilePath(dstPath, file.fileNum)
+		copied := func(n int64) {
+			progress.CopiedBytes += n
+			report()
+		}
+		state := manifest[file.fileNum]
+		err := copyFilePrefix(srcFile, dstFile, file.size, &state, copied,
+			interrupt)
+
+		// Record the copied portion of the file once it is on disk,
+		// which is the case when it was copied completely or the copy
+		// was interrupted.
+		if err == nil || isInterruptErr(err) {
+			manifest[file.fileNum] = state
+			if err := manifest.write(manifestPath); err != nil {
+				str := fmt.Sprintf("failed to write backup "+
+					"manifest: %v", err)
+				return makeDbErr(database.ErrDriverSpecific, str,
+					err)
+			}
+		}
+		if err != nil {
+			if _, ok := err.(database.Error); ok {
+				return err
+			}
+			str := fmt.Sprintf("failed to copy block file %d: %v",
+				file.fileNum, err)
+			return makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+	}
+	return nil
+}
+
+// isInterruptErr returns whether the passed error is the error used when a
+// backup is interrupted.
+func isInterruptErr(err error) bool {
+	dbErr, ok := err.(database.Error)
+	return ok && dbErr.ErrorCode == database.ErrInterrupted
+}
+
+// backupMetadata writes all metadata visible to the passed snapshot to a new
+// metadata store of the passed backend at the provided path.
+func backupMetadata(snapshot MetadataSnapshot, backend *Backend, metaPath string, progress *database.BackupProgress, report func(), interrupt <-chan struct{}) error {
+	// The backup does not contain any block data which is not already on
+	// disk, so there is nothing to sync before persisting the metadata.
+	syncBlocks := func() error { return nil }
+	meta, err := backend.OpenMetadata(metaPath, true, syncBlocks)
+	if err != nil {
+		return err
+	}
+
+	iter := snapshot.NewIterator(&util.Range{})
+	defer iter.Release()
+	pendingKeys := treap.NewMutable()
+	var batchBytes int
+	for ok := iter.First(); ok; ok = iter.Next() {
+		pendingKeys.Put(copySlice(iter.Key()), copySlice(iter.Value()))
+		batchBytes += len(iter.Key()) + len(iter.Value())
+		progress.MetadataEntries++
+		if batchBytes < backupMetaBatchBytes {
+			continue
+		}
+
+		if interruptRequested(interrupt) {
+			_ = meta.Close()
+			return errBackupInterrupted()
+		}
+		if err := meta.Commit(pendingKeys, treap.NewMutable()); err != nil {
+			_ = meta.Close()
+			return err
+		}
+		pendingKeys = treap.NewMutable()
+		batchBytes = 0
+		report()
+	}
+
+	// Commit the final batch and close the store to ensure everything is
+	// on disk before it is renamed into place.
+	if err := meta.Commit(pendingKeys, treap.NewMutable()); err != nil {
+		_ = meta.Close()
+		return err
+	}
+	if err := meta.Close(); err != nil {
+		return err
+	}
+	report()
+	return nil
+}
+
+// Backup writes a consistent copy of the database as of the time the call is
+// made to the provided destination directory.
+//
+// This function is part of the database.Backuper interface implementation.
+func (db *db) Backup(destPath string, progressFn func(database.BackupProgress), interrupt <-chan struct{}) error {
+	// A completed backup always contains the metadata store since it is
+	// only renamed into place after everything else was copied.
+	dstMetaPath := filepath.Join(destPath, db.backend.MetadataName)
+	if fileExists(dstMetaPath) {
+		str := fmt.Sprintf("database %q already exists", dstMetaPath)
+		return makeDbErr(database.ErrDbExists, str, nil)
+	}
+	if err := os.MkdirAll(destPath, 0700); err != nil {
+		str := fmt.Sprintf("failed to create backup directory: %v", err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+
+	// Use a read-only transaction to obtain a consistent view of the
+	// metadata.  Block data referenced by the view is never modified since
+	// new blocks are only ever appended after the committed write cursor.
+	tx, err := db.begin(false)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	writeRow := tx.metaBucket.Get(db.backend.WriteLocKeyName)
+	if writeRow == nil {
+		str := "write cursor does not exist"
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+	curFileNum, curOffset, err := deserializeWriteRow(writeRow)
+	if err != nil {
+		return err
+	}
+	files, err := committedBlockFiles(db.store.basePath, curFileNum,
+		curOffset)
+	if err != nil {
+		return err
+	}
+
+	var progress database.BackupProgress
+	report := func() {
+		if progressFn != nil {
+			progressFn(progress)
+		}
+	}
+	err = backupBlockFiles(db.store.basePath, destPath, files, &progress,
+		report, interrupt)
+	if err != nil {
+		return err
+	}
+
+	// Write the metadata to a temporary location and move it into place
+	// once it is complete.  Any partially written metadata from a previous
+	// attempt is discarded since it is cheap to recreate compared to the
+	// block data.
+	tmpMetaPath := dstMetaPath + backupMetaTmpSuffix
+	if err := os.RemoveAll(tmpMetaPath); err != nil {
+		str := fmt.Sprintf("failed to remove stale metadata: %v", err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	err = backupMetadata(tx.snapshot, db.backend, tmpMetaPath, &progress,
+		report, interrupt)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpMetaPath, dstMetaPath); err != nil {
+		str := fmt.Sprintf("failed to move metadata into place: %v", err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+
+	// The manifest is only needed to resume an incomplete backup.
+	manifestPath := filepath.Join(destPath, backupManifestName)
+	if err := os.Remove(manifestPath); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Failed to remove backup manifest: %v", err)
+	}
+	return nil
+}
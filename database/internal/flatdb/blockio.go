@@ -1,12 +1,13 @@
 // Copyright (c) 2015-2016 The btcsuite developers
 // Copyright (c) 2016 The Decred developers
+// Copyright (c) 2018-2020 The Hcd developers
 // Use of this source code is governed by an ISC
 // license that can be found in the LICENSE file.
 
 // This file contains the implementation functions for reading, writing, and
 // otherwise working with the flat files that house the actual blocks.
 
-package ffldb
+package flatdb
 
 import (
 	"container/list"
@@ -21,6 +22,7 @@ import (
 	"github.com/coolsnady/hcd/chaincfg/chainhash"
 	"github.com/coolsnady/hcd/database"
 	"github.com/coolsnady/hcd/wire"
+	"github.com/golang/snappy"
 )
 
 const (
@@ -59,6 +61,14 @@ const (
 	//  [4:8]  File offset (4 bytes)
 	//  [8:12] Block length (4 bytes)
 	blockLocSize = 12
+
+	// compressedBlockFlag is set in the block length field of block records
+	// which contain a compressed block.  The payload of such records is a
+	// byte which identifies the compression algorithm followed by the
+	// compressed block and the length field holds the length of the
+	// payload.  Blocks are far smaller than 2^31 bytes, so the flag is
+	// never set for records which contain an uncompressed block.
+	compressedBlockFlag uint32 = 1 << 31
 )
 
 var (
@@ -103,6 +113,12 @@ type writeCursor struct {
 	// curOffset is the offset in the current write block file where the
 	// next new block will be written.
 	curOffset uint32
+
+	// unsynced is set when data has been written to the current write
+	// block file since it was last synced.  It is only accessed during
+	// write transactions or while the database is otherwise exclusively
+	// held, so it is not protected by the mutex.
+	unsynced bool
 }
 
 // blockStore houses information used to handle reading and writing blocks (and
@@ -112,6 +128,9 @@ type blockStore struct {
 	// block.
 	network wire.CurrencyNet
 
+	// compression is the compression algorithm applied to new blocks.
+	compression database.BlockCompression
+
 	// basePath is the base path used for the flat block files and metadata.
 	basePath string
 
@@ -180,11 +199,16 @@ type blockStore struct {
 	deleteFileFunc    func(fileNum uint32) error
 }
 
-// blockLocation identifies a particular block file and location.
+// blockLocation identifies a particular block file and location.  The block
+// length is the length of the entire block record while the raw block length
+// is the length of the serialized block, which differs from the length of the
+// record payload when the block is compressed.
 type blockLocation struct {
 	blockFileNum uint32
 	fileOffset   uint32
 	blockLen     uint32
+	compression  database.BlockCompression
+	rawBlockLen  uint32
 }
 
 // deserializeBlockLoc deserializes the passed serialized block location
@@ -199,10 +223,16 @@ func deserializeBlockLoc(serializedLoc []byte) blockLocation {
 	//  [0:4]  Block file (4 bytes)
 	//  [4:8]  File offset (4 bytes)
 	//  [8:12] Block length (4 bytes)
+	//
+	// The location of a compressed block additionally contains the
+	// compression and raw block length which are not part of the serialized
+	// location.  See deserializeBlockRowLoc.
+	blockLen := byteOrder.Uint32(serializedLoc[8:12])
 	return blockLocation{
 		blockFileNum: byteOrder.Uint32(serializedLoc[0:4]),
 		fileOffset:   byteOrder.Uint32(serializedLoc[4:8]),
-		blockLen:     byteOrder.Uint32(serializedLoc[8:12]),
+		blockLen:     blockLen,
+		rawBlockLen:  blockLen - 12,
 	}
 }
 
@@ -221,6 +251,39 @@ func serializeBlockLoc(loc blockLocation) []byte {
 	return serializedData[:]
 }
 
+// compressBlock returns the payload of a compressed block record for the passed
+// serialized block using the provided compression algorithm.
+func compressBlock(rawBlock []byte, compression database.BlockCompression) ([]byte, error) {
+	switch compression {
+	case database.BlockCompressionSnappy:
+		payload := make([]byte, 1+snappy.MaxEncodedLen(len(rawBlock)))
+		payload[0] = byte(compression)
+		encoded := snappy.Encode(payload[1:], rawBlock)
+		return payload[:1+len(encoded)], nil
+	}
+
+	str := fmt.Sprintf("unsupported block compression %v", compression)
+	return nil, makeDbErr(database.ErrDriverSpecific, str, nil)
+}
+
+// decompressBlock returns the serialized block contained in the passed payload
+// of a compressed block record along with the compression algorithm which was
+// used.
+func decompressBlock(payload []byte) ([]byte, database.BlockCompression, error) {
+	if len(payload) == 0 {
+		return nil, 0, fmt.Errorf("compressed block payload is empty")
+	}
+
+	compression := database.BlockCompression(payload[0])
+	switch compression {
+	case database.BlockCompressionSnappy:
+		rawBlock, err := snappy.Decode(nil, payload[1:])
+		return rawBlock, compression, err
+	}
+	return nil, compression, fmt.Errorf("unsupported block compression %v",
+		compression)
+}
+
 // blockFilePath return the file path for the provided block file number.
 func blockFilePath(dbPath string, fileNum uint32) string {
 	fileName := fmt.Sprintf(blockFilenameTemplate, fileNum)
@@ -383,6 +446,7 @@ func (s *blockStore) writeData(data []byte, fieldName string) error {
 	wc := s.writeCursor
 	n, err := wc.curFile.file.WriteAt(data, int64(wc.curOffset))
 	wc.curOffset += uint32(n)
+	wc.unsynced = true
 	if err != nil {
 		str := fmt.Sprintf("failed to write %s to file %d at "+
 			"offset %d: %v", fieldName, wc.curFileNum,
@@ -402,12 +466,31 @@ func (s *blockStore) writeData(data []byte, fieldName string) error {
 // The write cursor will also be advanced the number of bytes actually written
 // in the event of failure.
 //
+// The block is compressed with the compression algorithm of the store, if any,
+// unless compressing it does not reduce its size.
+//
 // Format: <network><block length><serialized block><checksum>
+//
+// Compressed format: <network><payload length | compressedBlockFlag>
+// <compression><compressed block><checksum>
 func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
+	payload := rawBlock
+	compression := database.BlockCompressionNone
+	if s.compression != database.BlockCompressionNone {
+		compressed, err := compressBlock(rawBlock, s.compression)
+		if err != nil {
+			return blockLocation{}, err
+		}
+		if len(compressed) < len(rawBlock) {
+			payload = compressed
+			compression = s.compression
+		}
+	}
+
 	// Compute how many bytes will be written.
 	// 4 bytes each for block network + 4 bytes for block length +
-	// length of raw block + 4 bytes for checksum.
-	blockLen := uint32(len(rawBlock))
+	// length of the payload + 4 bytes for checksum.
+	blockLen := uint32(len(payload))
 	fullLen := blockLen + 12
 
 	// Move to the next block file if adding the new block would exceed the
@@ -429,9 +512,23 @@ func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 		// with LRU tracking.  The close is done under the write lock
 		// for the file to prevent it from being closed out from under
 		// any readers currently reading from it.
+		//
+		// The file is synced first since syncing the block files before
+		// persisting metadata only syncs the current write file.
 		wc.Lock()
 		wc.curFile.Lock()
 		if wc.curFile.file != nil {
+			if wc.unsynced {
+				if err := wc.curFile.file.Sync(); err != nil {
+					wc.curFile.Unlock()
+					wc.Unlock()
+					str := fmt.Sprintf("failed to sync file "+
+						"%d: %v", wc.curFileNum, err)
+					return blockLocation{}, makeDbErr(
+						database.ErrDriverSpecific, str, err)
+				}
+				wc.unsynced = false
+			}
 			_ = wc.curFile.file.Close()
 			wc.curFile.file = nil
 		}
@@ -471,17 +568,21 @@ func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 	_, _ = hasher.Write(scratch[:])
 
 	// Block length.
-	byteOrder.PutUint32(scratch[:], blockLen)
+	lengthField := blockLen
+	if compression != database.BlockCompressionNone {
+		lengthField |= compressedBlockFlag
+	}
+	byteOrder.PutUint32(scratch[:], lengthField)
 	if err := s.writeData(scratch[:], "block length"); err != nil {
 		return blockLocation{}, err
 	}
 	_, _ = hasher.Write(scratch[:])
 
-	// Serialized block.
-	if err := s.writeData(rawBlock[:], "block"); err != nil {
+	// Serialized block or compressed block payload.
+	if err := s.writeData(payload, "block"); err != nil {
 		return blockLocation{}, err
 	}
-	_, _ = hasher.Write(rawBlock)
+	_, _ = hasher.Write(payload)
 
 	// Castagnoli CRC-32 as a checksum of all the previous.
 	if err := s.writeData(hasher.Sum(nil), "checksum"); err != nil {
@@ -492,6 +593,8 @@ func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 		blockFileNum: wc.curFileNum,
 		fileOffset:   origOffset,
 		blockLen:     fullLen,
+		compression:  compression,
+		rawBlockLen:  uint32(len(rawBlock)),
 	}
 	return loc, nil
 }
@@ -504,9 +607,11 @@ func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 // and closing files as necessary to stay within the maximum allowed open files
 // limit.
 //
+// Compressed blocks are transparently decompressed.
+//
 // Returns ErrDriverSpecific if the data fails to read for any reason and
 // ErrCorruption if the checksum of the read data doesn't match the checksum
-// read from the file.
+// read from the file or a compressed block can't be decompressed.
 //
 // Format: <network><block length><serialized block><checksum>
 func (s *blockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte, error) {
@@ -554,7 +659,17 @@ func (s *blockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte,
 
 	// The raw block excludes the network, length of the block, and
 	// checksum.
-	return serializedData[8 : n-4], nil
+	payload := serializedData[8 : n-4]
+	if byteOrder.Uint32(serializedData[4:8])&compressedBlockFlag == 0 {
+		return payload, nil
+	}
+	rawBlock, _, err := decompressBlock(payload)
+	if err != nil {
+		str := fmt.Sprintf("failed to decompress block %s: %v", hash,
+			err)
+		return nil, makeDbErr(database.ErrCorruption, str, err)
+	}
+	return rawBlock, nil
 }
 
 // readBlockRegion reads the specified amount of data at the provided offset for
@@ -564,8 +679,26 @@ func (s *blockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte,
 // closing files as necessary to stay within the maximum allowed open files
 // limit.
 //
+// Compressed blocks are read and decompressed in full in order to extract the
+// region, which also verifies their checksum.
+//
 // Returns ErrDriverSpecific if the data fails to read for any reason.
-func (s *blockStore) readBlockRegion(loc blockLocation, offset, numBytes uint32) ([]byte, error) {
+func (s *blockStore) readBlockRegion(hash *chainhash.Hash, loc blockLocation, offset, numBytes uint32) ([]byte, error) {
+	if loc.compression != database.BlockCompressionNone {
+		rawBlock, err := s.readBlock(hash, loc)
+		if err != nil {
+			return nil, err
+		}
+		endOffset := offset + numBytes
+		if endOffset < offset || endOffset > uint32(len(rawBlock)) {
+			str := fmt.Sprintf("region offset %d, len %d exceeds "+
+				"length %d of block %s", offset, numBytes,
+				len(rawBlock), hash)
+			return nil, makeDbErr(database.ErrCorruption, str, nil)
+		}
+		return rawBlock[offset:endOffset:endOffset], nil
+	}
+
 	// Get the referenced block file handle opening the file as needed.  The
 	// function also handles closing files as needed to avoid going over the
 	// max allowed open files.
@@ -595,19 +728,24 @@ func (s *blockStore) readBlockRegion(loc blockLocation, offset, numBytes uint32)
 // store's current write cursor.  It is safe to call even when there is not a
 // current write file in which case it will have no effect.
 //
-// This is used when flushing cached metadata updates to disk to ensure all the
-// block data is fully written before updating the metadata.  This ensures the
-// metadata and block data can be properly reconciled in failure scenarios.
+// This is used by the metadata stores before persisting metadata updates to
+// ensure all the block data is fully written before updating the metadata.
+// This ensures the metadata and block data can be properly reconciled in
+// failure scenarios.  Nothing is synced when no data has been written since
+// the last sync, so it is cheap to call for every metadata update.
+//
+// This function MUST only be called during a write transaction or while the
+// database is otherwise exclusively held.
 func (s *blockStore) syncBlocks() error {
 	wc := s.writeCursor
 	wc.RLock()
 	defer wc.RUnlock()
 
 	// Nothing to do if there is no current file associated with the write
-	// cursor.
+	// cursor or nothing was written to it since it was last synced.
 	wc.curFile.RLock()
 	defer wc.curFile.RUnlock()
-	if wc.curFile.file == nil {
+	if wc.curFile.file == nil || !wc.unsynced {
 		return nil
 	}
 
@@ -617,6 +755,7 @@ func (s *blockStore) syncBlocks() error {
 			err)
 		return makeDbErr(database.ErrDriverSpecific, str, err)
 	}
+	wc.unsynced = false
 
 	return nil
 }
@@ -742,8 +881,9 @@ func scanBlockFiles(dbPath string) (int, uint32) {
 }
 
 // newBlockStore returns a new block store with the current block file number
-// and offset set and all fields initialized.
-func newBlockStore(basePath string, network wire.CurrencyNet) *blockStore {
+// and offset set and all fields initialized.  New blocks are compressed with
+// the provided compression algorithm.
+func newBlockStore(basePath string, network wire.CurrencyNet, compression database.BlockCompression) *blockStore {
 	// Look for the end of the latest block to file to determine what the
 	// write cursor position is from the viewpoing of the block files on
 	// disk.
@@ -755,6 +895,7 @@ func newBlockStore(basePath string, network wire.CurrencyNet) *blockStore {
 
 	store := &blockStore{
 		network:          network,
+		compression:      compression,
 		basePath:         basePath,
 		maxBlockFileSize: maxBlockFileSize,
 		openBlockFiles:   make(map[uint32]*lockableFile),
@@ -0,0 +1,16 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package flatdb implements the parts of the database drivers which are shared by
+all of the drivers that store blocks in flat files.
+
+This includes the database transactions, buckets, and cursors, the flat block
+files along with the optional block compression, reconciling the block files
+with the metadata on open, verifying and repairing the block index, and online
+backups.  Each driver provides the storage of the metadata through the
+MetadataStore interface and describes its internal bucket and key names along
+with how to open its metadata store with a Backend.
+*/
+package flatdb
@@ -3,7 +3,7 @@
 // Use of this source code is governed by an ISC
 // license that can be found in the LICENSE file.
 
-package ffldb
+package flatdb
 
 import (
 	"github.com/btcsuite/goleveldb/leveldb/iterator"
@@ -0,0 +1,144 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is part of the flatdb package rather than the flatdb_test package
+// as it provides the metadata store used by the whitebox tests.
+
+package flatdb
+
+import (
+	"os"
+	"sync"
+
+	"github.com/btcsuite/goleveldb/leveldb/iterator"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/database/internal/treap"
+)
+
+// testBackend is the backend used by the whitebox tests.  It keeps all of the
+// metadata in memory.
+var testBackend = &Backend{
+	DbType:             "flatdbtest",
+	BlockIdxBucketName: []byte("flatdbtest-blockidx"),
+	WriteLocKeyName:    []byte("flatdbtest-writeloc"),
+	MetadataName:       "metadata",
+	OpenMetadata:       openMemStore,
+}
+
+// memIter wraps a treap iterator to provide the additional functionality
+// needed to satisfy the leveldb iterator.Iterator interface.
+type memIter struct {
+	*treap.Iterator
+}
+
+// Error is only provided to satisfy the iterator interface.
+func (iter *memIter) Error() error {
+	return nil
+}
+
+// SetReleaser is only provided to satisfy the iterator interface.
+func (iter *memIter) SetReleaser(releaser util.Releaser) {
+}
+
+// Release is only provided to satisfy the iterator interface.
+func (iter *memIter) Release() {
+}
+
+// memSnapshot is a snapshot of a memory metadata store.
+type memSnapshot struct {
+	keys *treap.Immutable
+}
+
+// Has returns whether or not the passed key exists.
+func (snap *memSnapshot) Has(key []byte) bool {
+	return snap.keys.Has(key)
+}
+
+// Get returns the value for the passed key.
+func (snap *memSnapshot) Get(key []byte) []byte {
+	return snap.keys.Get(key)
+}
+
+// NewIterator returns a new iterator for the snapshot.
+func (snap *memSnapshot) NewIterator(slice *util.Range) iterator.Iterator {
+	return &memIter{Iterator: snap.keys.Iterator(slice.Start, slice.Limit)}
+}
+
+// Release releases the snapshot.
+func (snap *memSnapshot) Release() {
+}
+
+// memStore is a metadata store which keeps all metadata in memory.
+type memStore struct {
+	mtx        sync.Mutex
+	keys       *treap.Immutable
+	closed     bool
+	syncBlocks func() error
+}
+
+// errMemStoreClosed returns the error for operations on a closed store.
+func errMemStoreClosed() error {
+	return makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr, nil)
+}
+
+// Snapshot returns a snapshot of the metadata.
+func (s *memStore) Snapshot() (MetadataSnapshot, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.closed {
+		return nil, errMemStoreClosed()
+	}
+	return &memSnapshot{keys: s.keys}, nil
+}
+
+// Commit atomically applies the passed keys to add and remove.
+func (s *memStore) Commit(pendingKeys, pendingRemove *treap.Mutable) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.closed {
+		return errMemStoreClosed()
+	}
+	keys := s.keys
+	pendingKeys.ForEach(func(k, v []byte) bool {
+		keys = keys.Put(k, v)
+		return true
+	})
+	pendingRemove.ForEach(func(k, v []byte) bool {
+		keys = keys.Delete(k)
+		return true
+	})
+	s.keys = keys
+	return nil
+}
+
+// Flush syncs the block files.
+func (s *memStore) Flush() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.closed {
+		return errMemStoreClosed()
+	}
+	return s.syncBlocks()
+}
+
+// Close marks the store closed.
+func (s *memStore) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.closed {
+		return errMemStoreClosed()
+	}
+	s.closed = true
+	return nil
+}
+
+// openMemStore creates the directory of a memory metadata store so the
+// database is considered to exist and returns a new empty store.
+func openMemStore(path string, create bool, syncBlocks func() error) (MetadataStore, error) {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	return &memStore{keys: treap.NewImmutable(), syncBlocks: syncBlocks}, nil
+}
@@ -3,10 +3,10 @@
 // Use of this source code is governed by an ISC
 // license that can be found in the LICENSE file.
 
-// This file is part of the ffldb package rather than the ffldb_test package as
+// This file is part of the flatdb package rather than the flatdb_test package as
 // it is part of the whitebox testing.
 
-package ffldb
+package flatdb
 
 import (
 	"errors"
@@ -0,0 +1,433 @@
+// Copyright (c) 2015-2016 The btcsuite developers
+// Copyright (c) 2016 The Decred developers
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package flatdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/goleveldb/leveldb/util"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/database/internal/treap"
+	"github.com/coolsnady/hcd/wire"
+)
+
+// The serialized write cursor location format is:
+//
+//  [0:4]  Block file (4 bytes)
+//  [4:8]  File offset (4 bytes)
+//  [8:12] Castagnoli CRC-32 checksum (4 bytes)
+
+// serializeWriteRow serialize the current block file and offset where new
+// will be written into a format suitable for storage into the metadata.
+func serializeWriteRow(curBlockFileNum, curFileOffset uint32) []byte {
+	var serializedRow [12]byte
+	byteOrder.PutUint32(serializedRow[0:4], curBlockFileNum)
+	byteOrder.PutUint32(serializedRow[4:8], curFileOffset)
+	checksum := crc32.Checksum(serializedRow[:8], castagnoli)
+	byteOrder.PutUint32(serializedRow[8:12], checksum)
+	return serializedRow[:]
+}
+
+// deserializeWriteRow deserializes the write cursor location stored in the
+// metadata.  Returns ErrCorruption if the checksum of the entry doesn't match.
+func deserializeWriteRow(writeRow []byte) (uint32, uint32, error) {
+	// Ensure the checksum matches.  The checksum is at the end.
+	gotChecksum := crc32.Checksum(writeRow[:8], castagnoli)
+	wantChecksumBytes := writeRow[8:12]
+	wantChecksum := byteOrder.Uint32(wantChecksumBytes)
+	if gotChecksum != wantChecksum {
+		str := fmt.Sprintf("metadata for write cursor does not match "+
+			"the expected checksum - got %d, want %d", gotChecksum,
+			wantChecksum)
+		return 0, 0, makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	fileNum := byteOrder.Uint32(writeRow[0:4])
+	fileOffset := byteOrder.Uint32(writeRow[4:8])
+	return fileNum, fileOffset, nil
+}
+
+// reconcileDB reconciles the metadata with the flat block files on disk.  It
+// will also initialize the underlying database if the create flag is set.
+func reconcileDB(pdb *db, create bool) (database.DB, error) {
+	// Perform initial internal bucket and value creation during database
+	// creation.
+	if create {
+		if err := initDB(pdb.meta, pdb.backend); err != nil {
+			return nil, err
+		}
+	}
+
+	// Load the current write cursor position from the metadata.
+	var curFileNum, curOffset uint32
+	err := pdb.View(func(tx database.Tx) error {
+		writeRow := tx.Metadata().Get(pdb.backend.WriteLocKeyName)
+		if writeRow == nil {
+			str := "write cursor does not exist"
+			return makeDbErr(database.ErrCorruption, str, nil)
+		}
+
+		var err error
+		curFileNum, curOffset, err = deserializeWriteRow(writeRow)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// When the write cursor position found by scanning the block files on
+	// disk is AFTER the position the metadata believes to be true, truncate
+	// the files on disk to match the metadata.  This can be a fairly common
+	// occurrence in unclean shutdown scenarios while the block files are in
+	// the middle of being written.  Since the metadata isn't updated until
+	// after the block data is written, this is effectively just a rollback
+	// to the known good point before the unclean shutdown.
+	wc := pdb.store.writeCursor
+	if wc.curFileNum > curFileNum || (wc.curFileNum == curFileNum &&
+		wc.curOffset > curOffset) {
+
+		log.Info("Detected unclean shutdown - Repairing...")
+		log.Debugf("Metadata claims file %d, offset %d. Block data is "+
+			"at file %d, offset %d", curFileNum, curOffset,
+			wc.curFileNum, wc.curOffset)
+		pdb.store.handleRollback(curFileNum, curOffset)
+		log.Infof("Database sync complete")
+	}
+
+	// When the write cursor position found by scanning the block files on
+	// disk is BEFORE the position the metadata believes to be true, return
+	// a corruption error.  Since sync is called after each block is written
+	// and before the metadata is updated, this should only happen in the
+	// case of missing, deleted, or truncated block files, which generally
+	// is not an easily recoverable scenario.  The block index can be
+	// rebuilt from the block files with repairDB, however, that needs to
+	// happen with coordination from a higher layer since it could
+	// invalidate other metadata.
+	if wc.curFileNum < curFileNum || (wc.curFileNum == curFileNum &&
+		wc.curOffset < curOffset) {
+
+		str := fmt.Sprintf("metadata claims file %d, offset %d, but "+
+			"block data is at file %d, offset %d", curFileNum,
+			curOffset, wc.curFileNum, wc.curOffset)
+		log.Warnf("***Database corruption detected***: %v", str)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	return pdb, nil
+}
+
+// scanBlockFile reads the block records stored in the first size bytes of the
+// flat block file at the provided path and invokes the passed function with the
+// hash, location, and serialized header of each valid one.  A record is valid
+// when it is for the provided network, its checksum matches, and it contains a
+// block header.  The scan stops at the first record which is not valid and the
+// offset of it is returned.  The returned offset is the passed size when all
+// records are valid.
+//
+// The scan is aborted and the error is returned when the passed function
+// returns an error.
+func scanBlockFile(filePath string, fileNum uint32, size int64, network wire.CurrencyNet, fn func(hash *chainhash.Hash, loc blockLocation, blockHdr []byte) error) (int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	// Each record is the network, the block length, the serialized block,
+	// and a checksum of all of the preceding data.  The serialized block
+	// is replaced by a compressed payload when the compressed block flag
+	// is set in the block length.
+	r := bufio.NewReaderSize(io.LimitReader(file, size), 1024*1024)
+	var offset int64
+	var scratch [8]byte
+	for offset < size {
+		if offset+12 > size {
+			return offset, nil
+		}
+		if _, err := io.ReadFull(r, scratch[:]); err != nil {
+			return offset, err
+		}
+		if byteOrder.Uint32(scratch[0:4]) != uint32(network) {
+			return offset, nil
+		}
+		lengthField := byteOrder.Uint32(scratch[4:8])
+		blockLen := int64(lengthField &^ compressedBlockFlag)
+		if offset+12+blockLen > size {
+			return offset, nil
+		}
+		serializedData := make([]byte, blockLen+4)
+		if _, err := io.ReadFull(r, serializedData); err != nil {
+			return offset, err
+		}
+		hasher := crc32.New(castagnoli)
+		_, _ = hasher.Write(scratch[:])
+		_, _ = hasher.Write(serializedData[:blockLen])
+		wantChecksum := binary.BigEndian.Uint32(serializedData[blockLen:])
+		if hasher.Sum32() != wantChecksum {
+			return offset, nil
+		}
+
+		rawBlock := serializedData[:blockLen]
+		compression := database.BlockCompressionNone
+		if lengthField&compressedBlockFlag != 0 {
+			var err error
+			rawBlock, compression, err = decompressBlock(rawBlock)
+			if err != nil {
+				return offset, nil
+			}
+		}
+		if len(rawBlock) < blockHdrSize {
+			return offset, nil
+		}
+		var header wire.BlockHeader
+		err := header.Deserialize(bytes.NewReader(rawBlock))
+		if err != nil {
+			return offset, nil
+		}
+		hash := header.BlockHash()
+		loc := blockLocation{
+			blockFileNum: fileNum,
+			fileOffset:   uint32(offset),
+			blockLen:     uint32(blockLen + 12),
+			compression:  compression,
+			rawBlockLen:  uint32(len(rawBlock)),
+		}
+		blockHdr := rawBlock[:blockHdrSize:blockHdrSize]
+		if err := fn(&hash, loc, blockHdr); err != nil {
+			return offset, err
+		}
+		offset += blockLen + 12
+	}
+	return offset, nil
+}
+
+// Repair rebuilds the block index of the database at the provided path from
+// the flat block files using the metadata store of the passed backend.  The
+// database must not be open.
+//
+// All valid blocks in the contiguous block files starting with the first one
+// are indexed.  Any trailing data in the last file which does not contain a
+// valid block is removed and the write cursor is moved to the end of the last
+// valid block.  Invalid data in any of the other files is left in place, but
+// is no longer referenced.  When a block is stored more than once, the first
+// copy is indexed.
+func Repair(dbPath string, network wire.CurrencyNet, backend *Backend) (*database.RepairResult, error) {
+	metadataPath := filepath.Join(dbPath, backend.MetadataName)
+	if !fileExists(metadataPath) {
+		str := fmt.Sprintf("database %q does not exist", metadataPath)
+		return nil, makeDbErr(database.ErrDbDoesNotExist, str, nil)
+	}
+
+	// The block store performs the same scan which is used to find the
+	// write cursor when opening the database.
+	store := newBlockStore(dbPath, network,
+		database.BlockCompressionNone)
+	meta, err := backend.OpenMetadata(metadataPath, false, store.syncBlocks)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove all existing block index entries.  The entries for the blocks
+	// found below replace them, so they are committed atomically.
+	var result database.RepairResult
+	snapshot, err := meta.Snapshot()
+	if err != nil {
+		_ = meta.Close()
+		return nil, err
+	}
+	pendingRemove := treap.NewMutable()
+	iter := snapshot.NewIterator(util.BytesPrefix(blockIdxBucketID[:]))
+	for ok := iter.First(); ok; ok = iter.Next() {
+		pendingRemove.Put(copySlice(iter.Key()), nil)
+		result.PrevBlocks++
+	}
+	iter.Release()
+	snapshot.Release()
+
+	// Index the blocks of every block file found by the scan.
+	pendingKeys := treap.NewMutable()
+	lastFileNum := store.writeCursor.curFileNum
+	var lastOffset int64
+	for fileNum := uint32(0); fileNum <= lastFileNum; fileNum++ {
+		filePath := blockFilePath(dbPath, fileNum)
+		fi, err := os.Stat(filePath)
+		if os.IsNotExist(err) && fileNum == 0 {
+			// There are no block files at all.
+			break
+		}
+		if err != nil {
+			_ = meta.Close()
+			str := fmt.Sprintf("failed to stat block file %d: %v",
+				fileNum, err)
+			return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+
+		indexBlock := func(hash *chainhash.Hash, loc blockLocation, blockHdr []byte) error {
+			key := bucketizedKey(blockIdxBucketID, hash[:])
+			if pendingKeys.Has(key) {
+				log.Warnf("Block %v is stored more than once", hash)
+				return nil
+			}
+			pendingRemove.Delete(key)
+			pendingKeys.Put(key, serializeBlockRow(loc, blockHdr))
+			result.Blocks++
+			return nil
+		}
+		validEnd, err := scanBlockFile(filePath, fileNum, fi.Size(),
+			network, indexBlock)
+		if err != nil {
+			_ = meta.Close()
+			str := fmt.Sprintf("failed to read block file %d: %v",
+				fileNum, err)
+			return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+		if validEnd < fi.Size() {
+			log.Warnf("Block file %d contains %d bytes of invalid "+
+				"data at offset %d", fileNum, fi.Size()-validEnd,
+				validEnd)
+			result.DiscardedBytes += fi.Size() - validEnd
+		}
+		lastOffset = validEnd
+	}
+
+	// Remove any invalid trailing data from the last file the same way it
+	// is done when reconciling the write cursor with the metadata and then
+	// point the write cursor to the end of the last valid block.
+	store.handleRollback(lastFileNum, uint32(lastOffset))
+	wc := store.writeCursor
+	if wc.curFile.file != nil {
+		_ = wc.curFile.file.Close()
+		wc.curFile.file = nil
+	}
+	writeRow := serializeWriteRow(lastFileNum, uint32(lastOffset))
+	pendingKeys.Put(bucketizedKey(metadataBucketID,
+		backend.WriteLocKeyName), writeRow)
+
+	// Commit all of the changes atomically and persist them.
+	if err := meta.Commit(pendingKeys, pendingRemove); err != nil {
+		_ = meta.Close()
+		return nil, err
+	}
+	if err := meta.Close(); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// orphanedBlockFiles returns the paths of the files in the provided directory
+// which are named like block files but have a number after the passed one.
+func orphanedBlockFiles(dbPath string, lastFileNum uint32) ([]string, error) {
+	fileInfos, err := ioutil.ReadDir(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	var orphaned []string
+	for _, fi := range fileInfos {
+		name := fi.Name()
+		if fi.IsDir() || !strings.HasSuffix(name, ".fdb") {
+			continue
+		}
+		fileNum, err := strconv.ParseUint(strings.TrimSuffix(name, ".fdb"),
+			10, 32)
+		if err != nil || name != fmt.Sprintf(blockFilenameTemplate, fileNum) {
+			continue
+		}
+		if uint32(fileNum) > lastFileNum {
+			orphaned = append(orphaned, filepath.Join(dbPath, name))
+		}
+	}
+	return orphaned, nil
+}
+
+// VerifyBlockFiles scans all block data referenced by the write cursor and
+// reports the blocks and files which are not referenced by the block index.
+//
+// This function is part of the database.BlockFileVerifier interface
+// implementation.
+func (db *db) VerifyBlockFiles(interrupt <-chan struct{}) (*database.BlockFilesReport, error) {
+	tx, err := db.begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	writeRow := tx.metaBucket.Get(db.backend.WriteLocKeyName)
+	if writeRow == nil {
+		str := "write cursor does not exist"
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	curFileNum, curOffset, err := deserializeWriteRow(writeRow)
+	if err != nil {
+		return nil, err
+	}
+	files, err := committedBlockFiles(db.store.basePath, curFileNum,
+		curOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load the location of every block in the block index.
+	indexed := make(map[chainhash.Hash]blockLocation)
+	iter := tx.snapshot.NewIterator(util.BytesPrefix(blockIdxBucketID[:]))
+	for ok := iter.First(); ok; ok = iter.Next() {
+		var hash chainhash.Hash
+		copy(hash[:], iter.Key()[len(blockIdxBucketID):])
+		indexed[hash] = deserializeBlockRowLoc(iter.Value())
+	}
+	iter.Release()
+
+	var report database.BlockFilesReport
+	checkBlock := func(hash *chainhash.Hash, loc blockLocation, _ []byte) error {
+		if interruptRequested(interrupt) {
+			return makeDbErr(database.ErrInterrupted,
+				"verification interrupted", nil)
+		}
+		report.Blocks++
+		if indexedLoc, ok := indexed[*hash]; !ok || indexedLoc != loc {
+			report.UnindexedBlocks = append(report.UnindexedBlocks,
+				*hash)
+		}
+		return nil
+	}
+	for _, file := range files {
+		filePath := blockFilePath(db.store.basePath, file.fileNum)
+		validEnd, err := scanBlockFile(filePath, file.fileNum, file.size,
+			db.store.network, checkBlock)
+		if err != nil {
+			if _, ok := err.(database.Error); ok {
+				return nil, err
+			}
+			str := fmt.Sprintf("failed to read block file %d: %v",
+				file.fileNum, err)
+			return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+		if validEnd < file.size {
+			region := fmt.Sprintf("file %d, offset %d to %d",
+				file.fileNum, validEnd, file.size)
+			report.InvalidRegions = append(report.InvalidRegions, region)
+		}
+	}
+
+	report.OrphanedFiles, err = orphanedBlockFiles(db.store.basePath,
+		curFileNum)
+	if err != nil {
+		str := fmt.Sprintf("failed to list block files: %v", err)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	return &report, nil
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package flatdb
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btclog"
+	"github.com/btcsuite/goleveldb/leveldb/iterator"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/database/internal/treap"
+	"github.com/coolsnady/hcd/wire"
+)
+
+var log = btclog.Disabled
+
+// UseLogger uses a specified Logger to output package logging info.  It is
+// invoked by the database drivers which are built on this package.
+func UseLogger(logger btclog.Logger) {
+	log = logger
+}
+
+// MetadataSnapshot defines a consistent view of the metadata committed to a
+// metadata store at a particular point in time.
+type MetadataSnapshot interface {
+	// Has returns whether or not the passed key exists.
+	Has(key []byte) bool
+
+	// Get returns the value for the passed key.  The function must return
+	// nil when the key does not exist.
+	Get(key []byte) []byte
+
+	// NewIterator returns a new iterator for the snapshot.  The newly
+	// returned iterator is not pointing to a valid item until a call to
+	// one of the methods to position it is made.
+	//
+	// The slice parameter limits the iterator to a range of keys.  The
+	// start key is inclusive and the limit key is exclusive.  Either or
+	// both can be nil if the functionality is not desired.
+	NewIterator(slice *util.Range) iterator.Iterator
+
+	// Release releases the snapshot.
+	Release()
+}
+
+// MetadataStore defines the interface a database driver implements to persist
+// the metadata of a database.  The transactions, buckets, and block storage are
+// provided by this package on top of it.
+type MetadataStore interface {
+	// Snapshot returns a snapshot of the metadata which has been committed
+	// to the store so far.  The snapshot must be released after use by
+	// calling Release.
+	Snapshot() (MetadataSnapshot, error)
+
+	// Commit atomically commits the passed keys to add or update and keys
+	// to remove.  The store takes ownership of the passed treaps.  When
+	// the changes are persisted is up to the store, however, either all
+	// or none of them must be persisted.
+	//
+	// This function is only called with the database write lock held.
+	Commit(pendingKeys, pendingRemove *treap.Mutable) error
+
+	// Flush persists all metadata which has been committed to the store.
+	//
+	// This function is only called with the database write lock held.
+	Flush() error
+
+	// Close persists all metadata which has been committed to the store
+	// and releases all resources used by it.
+	Close() error
+}
+
+// Backend describes the driver-specific details of a database built on this
+// package.
+type Backend struct {
+	// DbType is the database driver type.
+	DbType string
+
+	// BlockIdxBucketName is the name of the bucket used internally to
+	// track block metadata.
+	BlockIdxBucketName []byte
+
+	// WriteLocKeyName is the name of the key used to store the current
+	// write file location.
+	WriteLocKeyName []byte
+
+	// MetadataName is the name of the directory in the database path which
+	// houses the metadata store.  The database exists when it does.
+	MetadataName string
+
+	// OpenMetadata opens the metadata store at the provided path.  The
+	// store, along with its directory, is created when the create flag is
+	// set and must not exist already in that case.
+	//
+	// The passed function syncs the flat block files to disk.  Metadata
+	// might reference block data which has been written during the same
+	// transaction, so the store must invoke it before persisting any
+	// metadata to ensure the metadata and block data can be properly
+	// reconciled in failure scenarios.
+	OpenMetadata func(path string, create bool, syncBlocks func() error) (MetadataStore, error)
+}
+
+// ParseArgs parses the arguments from the database Open/Create methods of the
+// passed database driver type.  The block compression argument is optional and
+// defaults to no compression.
+func ParseArgs(dbType, funcName string, args ...interface{}) (string, wire.CurrencyNet, database.BlockCompression, error) {
+	compression := database.BlockCompressionNone
+	if len(args) != 2 && len(args) != 3 {
+		return "", 0, compression, fmt.Errorf("invalid arguments to "+
+			"%s.%s -- expected database path, block network, and "+
+			"optional block compression", dbType, funcName)
+	}
+
+	dbPath, ok := args[0].(string)
+	if !ok {
+		return "", 0, compression, fmt.Errorf("first argument to "+
+			"%s.%s is invalid -- expected database path string",
+			dbType, funcName)
+	}
+
+	network, ok := args[1].(wire.CurrencyNet)
+	if !ok {
+		return "", 0, compression, fmt.Errorf("second argument to "+
+			"%s.%s is invalid -- expected block network", dbType,
+			funcName)
+	}
+
+	if len(args) == 3 {
+		compression, ok = args[2].(database.BlockCompression)
+		if !ok {
+			return "", 0, compression, fmt.Errorf("third argument "+
+				"to %s.%s is invalid -- expected block "+
+				"compression", dbType, funcName)
+		}
+		switch compression {
+		case database.BlockCompressionNone, database.BlockCompressionSnappy:
+		default:
+			return "", 0, compression, fmt.Errorf("third argument "+
+				"to %s.%s is invalid -- unsupported %v", dbType,
+				funcName, compression)
+		}
+	}
+
+	return dbPath, network, compression, nil
+}
@@ -272,6 +272,17 @@ func (t *Mutable) Reset() {
 	t.root = nil
 }
 
+// Immutable returns an immutable treap which contains the same key/value pairs
+// as the mutable treap.  The returned treap shares the nodes of the mutable
+// treap, so this is an efficient way to build a large immutable treap.
+//
+// NOTE: The mutable treap must not be modified after calling this function
+// since the changes would otherwise also be visible in the returned immutable
+// treap.
+func (t *Mutable) Immutable() *Immutable {
+	return newImmutable(t.root, t.count, t.totalSize)
+}
+
 // NewMutable returns a new empty mutable treap ready for use.  See the
 // documentation for the Mutable structure for more details.
 func NewMutable() *Mutable {
@@ -464,3 +464,56 @@ func TestMutableForEachStopIterator(t *testing.T) {
 			numIterated, numItems/2)
 	}
 }
+
+// TestMutableImmutable ensures converting a mutable treap to an immutable treap
+// results in the same contents and that modifying the returned immutable treap
+// does not affect the mutable one.
+func TestMutableImmutable(t *testing.T) {
+	t.Parallel()
+
+	numItems := 1000
+	testTreap := NewMutable()
+	for i := 0; i < numItems; i++ {
+		key := serializeUint32(uint32(i))
+		testTreap.Put(key, key)
+	}
+
+	immutable := testTreap.Immutable()
+	if gotLen := immutable.Len(); gotLen != numItems {
+		t.Fatalf("Len: unexpected length - got %d, want %d", gotLen,
+			numItems)
+	}
+	if gotSize, wantSize := immutable.Size(), testTreap.Size(); gotSize != wantSize {
+		t.Fatalf("Size: unexpected byte size - got %d, want %d",
+			gotSize, wantSize)
+	}
+	var numIterated int
+	immutable.ForEach(func(k, v []byte) bool {
+		wantKey := serializeUint32(uint32(numIterated))
+		if !bytes.Equal(k, wantKey) || !bytes.Equal(v, wantKey) {
+			t.Fatalf("ForEach #%d: unexpected pair - got %x/%x, "+
+				"want %x", numIterated, k, v, wantKey)
+		}
+		numIterated++
+		return true
+	})
+	if numIterated != numItems {
+		t.Fatalf("ForEach: unexpected iterate count - got %d, want %d",
+			numIterated, numItems)
+	}
+
+	// Ensure deleting from the immutable treap leaves the mutable treap
+	// untouched.
+	for i := 0; i < numItems; i += 2 {
+		immutable = immutable.Delete(serializeUint32(uint32(i)))
+	}
+	if gotLen := testTreap.Len(); gotLen != numItems {
+		t.Fatalf("Len: unexpected mutable length - got %d, want %d",
+			gotLen, numItems)
+	}
+	for i := 0; i < numItems; i++ {
+		if !testTreap.Has(serializeUint32(uint32(i))) {
+			t.Fatalf("Has #%d: key unexpectedly removed", i)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package treapdb
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/database/internal/flatdb"
+)
+
+const (
+	// metadataDirName is the name of the directory which houses the
+	// metadata store.
+	metadataDirName = "metadata"
+)
+
+var (
+	// byteOrder is the preferred byte order used through the metadata
+	// files.
+	byteOrder = binary.LittleEndian
+
+	// castagnoli houses the Castagnoli polynomial used for CRC-32 checksums
+	// of the metadata files.
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+	// blockIdxBucketName is the bucket used internally to track block
+	// metadata.
+	blockIdxBucketName = []byte("treapdb-blockidx")
+
+	// writeLocKeyName is the key used to store the current write file
+	// location.
+	writeLocKeyName = []byte("treapdb-writeloc")
+
+	// backend describes how the treap database stores its metadata.
+	backend = &flatdb.Backend{
+		DbType:             dbType,
+		BlockIdxBucketName: blockIdxBucketName,
+		WriteLocKeyName:    writeLocKeyName,
+		MetadataName:       metadataDirName,
+		OpenMetadata:       openMetadata,
+	}
+)
+
+// makeDbErr creates a database.Error given a set of arguments.
+func makeDbErr(c database.ErrorCode, desc string, err error) database.Error {
+	return database.Error{ErrorCode: c, Description: desc, Err: err}
+}
+
+// syncDir syncs the provided directory to ensure the creation, removal, and
+// renaming of the files in it are persisted.  Not all platforms support syncing
+// directories, so errors are ignored.
+func syncDir(path string) {
+	if dir, err := os.Open(path); err == nil {
+		_ = dir.Sync()
+		_ = dir.Close()
+	}
+}
+
+// openMetadata opens the metadata store at the provided path using the default
+// configuration.  It is the metadata store provided to the flatdb package.
+func openMetadata(path string, create bool, syncBlocks func() error) (flatdb.MetadataStore, error) {
+	return openMetaStore(path, create, syncBlocks, defaultStoreConfig)
+}
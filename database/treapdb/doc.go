@@ -0,0 +1,51 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package treapdb implements a driver for the database package that keeps the
+metadata in a log-structured merge tree and uses flat files for block storage.
+
+This driver is written in pure Go and does not depend on leveldb.  Every
+committed write transaction appends its changes to a metadata log which is
+synced to disk before the commit returns, and applies them to an in-memory
+immutable treap, called the memtable.  Once the memtable grows large enough, it
+is written to an immutable, sorted table file in the background and the logs
+holding its changes are removed.  Table files are merged by background
+compactions which keep their number logarithmic in the size of the metadata.
+Read transactions operate on the memtables and table files as of the time they
+were started, so they are never blocked by writers, and only the memtables are
+held in memory.  On open, the logs which have not been written to a table file
+yet are replayed.
+
+Blocks are stored in flat files along with checksums in the same way as the
+ffldb driver, which this driver shares its transaction, block storage, backup,
+and repair implementation with.
+
+Usage
+
+This package is a driver to the database package and provides the database type
+of "treapdb".  The parameters the Open and Create functions take are the
+database path as a string and the block network:
+
+	db, err := database.Open("treapdb", "path/to/database", wire.MainNet)
+	if err != nil {
+		// Handle error
+	}
+
+	db, err := database.Create("treapdb", "path/to/database", wire.MainNet)
+	if err != nil {
+		// Handle error
+	}
+
+An optional third parameter specifies a database.BlockCompression which is
+applied to all blocks stored after opening the database in the same way as the
+ffldb driver:
+
+	db, err := database.Open("treapdb", "path/to/database", wire.MainNet,
+		database.BlockCompressionSnappy)
+	if err != nil {
+		// Handle error
+	}
+*/
+package treapdb
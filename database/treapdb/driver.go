@@ -0,0 +1,79 @@
+// Copyright (c) 2015-2016 The btcsuite developers
+// Copyright (c) 2016 The Decred developers
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package treapdb
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btclog"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/database/internal/flatdb"
+)
+
+var log = btclog.Disabled
+
+const (
+	dbType = "treapdb"
+)
+
+// openDBDriver is the callback provided during driver registration that opens
+// an existing database for use.
+func openDBDriver(args ...interface{}) (database.DB, error) {
+	dbPath, network, compression, err := flatdb.ParseArgs(dbType, "Open",
+		args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return flatdb.Open(dbPath, network, compression, false, backend)
+}
+
+// createDBDriver is the callback provided during driver registration that
+// creates, initializes, and opens a database for use.
+func createDBDriver(args ...interface{}) (database.DB, error) {
+	dbPath, network, compression, err := flatdb.ParseArgs(dbType, "Create",
+		args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return flatdb.Open(dbPath, network, compression, true, backend)
+}
+
+// repairDBDriver is the callback provided during driver registration that
+// rebuilds the block index of an existing database.
+func repairDBDriver(args ...interface{}) (*database.RepairResult, error) {
+	dbPath, network, _, err := flatdb.ParseArgs(dbType, "Repair",
+		args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return flatdb.Repair(dbPath, network, backend)
+}
+
+// useLogger is the callback provided during driver registration that sets the
+// current logger to the provided one.
+func useLogger(logger btclog.Logger) {
+	log = logger
+	flatdb.UseLogger(logger)
+}
+
+func init() {
+	// Register the driver.
+	driver := database.Driver{
+		DbType:    dbType,
+		Create:    createDBDriver,
+		Open:      openDBDriver,
+		Repair:    repairDBDriver,
+		UseLogger: useLogger,
+	}
+	if err := database.RegisterDriver(driver); err != nil {
+		panic(fmt.Sprintf("Failed to regiser database driver '%s': %v",
+			dbType, err))
+	}
+}
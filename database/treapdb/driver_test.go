@@ -0,0 +1,60 @@
+// Copyright (c) 2015-2016 The btcsuite developers
+// Copyright (c) 2016 The Decred developers
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package treapdb_test
+
+import (
+	"testing"
+
+	"github.com/coolsnady/hcd/database/internal/dbtest"
+	_ "github.com/coolsnady/hcd/database/treapdb"
+)
+
+// dbType is the database type name for this driver.
+const dbType = "treapdb"
+
+// TestCreateOpenFail ensures that errors related to creating and opening a
+// database are handled properly.
+func TestCreateOpenFail(t *testing.T) {
+	dbtest.TestCreateOpenFail(t, dbType)
+}
+
+// TestPersistence ensures that values stored are still valid after closing and
+// reopening the database.
+func TestPersistence(t *testing.T) {
+	dbtest.TestPersistence(t, dbType)
+}
+
+// TestInterface performs all interfaces tests for this database driver.
+func TestInterface(t *testing.T) {
+	dbtest.TestInterface(t, dbType)
+}
+
+// TestInterfaceCompressed performs all interfaces tests for this database
+// driver with block compression enabled.
+func TestInterfaceCompressed(t *testing.T) {
+	dbtest.TestInterfaceCompressed(t, dbType)
+}
+
+// TestMixedCompression ensures blocks stored with and without compression in
+// the same database can be fetched in full and by region and are found by the
+// block file verification and repair.
+func TestMixedCompression(t *testing.T) {
+	dbtest.TestMixedCompression(t, dbType)
+}
+
+// TestBackup ensures a backup of a database can be interrupted, resumed, and
+// then opened with the same contents as the original database.
+func TestBackup(t *testing.T) {
+	dbtest.TestBackup(t, dbType)
+}
+
+// TestRepair ensures the block files of a database are verified as expected
+// and that the block index of a database which can't be opened due to a
+// truncated block file is rebuilt by Repair.
+func TestRepair(t *testing.T) {
+	dbtest.TestRepair(t, dbType)
+}
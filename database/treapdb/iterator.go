@@ -0,0 +1,374 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package treapdb
+
+import (
+	"bytes"
+
+	"github.com/btcsuite/goleveldb/leveldb/iterator"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+	"github.com/coolsnady/hcd/database/internal/treap"
+)
+
+// internalIter defines the interface of the iterators over the kind prefixed
+// entries of the memtables and tables which are merged by a mergingIter.
+type internalIter interface {
+	First() bool
+	Last() bool
+	Seek(key []byte) bool
+	Next() bool
+	Prev() bool
+	Valid() bool
+	Key() []byte
+	Value() []byte
+	Error() error
+}
+
+// memIter wraps a treap iterator over a memtable to provide the additional
+// functionality needed to satisfy the internalIter interface.
+type memIter struct {
+	*treap.Iterator
+}
+
+// Enforce memIter implements the internalIter interface.
+var _ internalIter = (*memIter)(nil)
+
+// Error is only provided to satisfy the internalIter interface as there are no
+// errors for this memory-only structure.
+func (iter *memIter) Error() error {
+	return nil
+}
+
+// The following constants define the directions a mergingIter moves in.
+const (
+	dirForward = iota
+	dirBackward
+)
+
+// mergingIter merges the entries of several memtables and tables into a single
+// view of the metadata.  The iterators are ordered from the newest source to
+// the oldest source, and when more than one of them contains a key, the entry
+// of the newest one is used.  Keys whose newest entry is a tombstone are
+// skipped unless the iterator is raw, in which case the kind prefixed entries
+// including tombstones are returned.
+type mergingIter struct {
+	iters    []internalIter
+	start    []byte
+	limit    []byte
+	raw      bool
+	dir      int
+	key      []byte
+	value    []byte
+	valid    bool
+	err      error
+	released bool
+	release  func()
+	releaser util.Releaser
+}
+
+// Enforce mergingIter implements the leveldb iterator.Iterator interface.
+var _ iterator.Iterator = (*mergingIter)(nil)
+
+// newMergingIter returns a new iterator which merges the passed iterators
+// ordered from the newest to the oldest source.  The slice parameter allows the
+// iterator to be limited to a range of keys.  The passed release function, if
+// any, is invoked when the iterator is released.
+func newMergingIter(iters []internalIter, slice *util.Range, raw bool, release func()) *mergingIter {
+	iter := &mergingIter{iters: iters, raw: raw, release: release}
+	if slice != nil {
+		iter.start = slice.Start
+		iter.limit = slice.Limit
+	}
+	return iter
+}
+
+// invalidate marks the iterator exhausted.
+func (iter *mergingIter) invalidate() bool {
+	iter.key = nil
+	iter.value = nil
+	iter.valid = false
+	return false
+}
+
+// checkErrors marks the iterator exhausted when any of the merged iterators
+// encountered an error.
+func (iter *mergingIter) checkErrors() bool {
+	for _, it := range iter.iters {
+		if err := it.Error(); err != nil {
+			iter.err = err
+			return false
+		}
+	}
+	return true
+}
+
+// skipForward moves all of the merged iterators positioned at the passed key
+// to their next entry.
+func (iter *mergingIter) skipForward(key []byte) {
+	for _, it := range iter.iters {
+		if it.Valid() && bytes.Equal(it.Key(), key) {
+			it.Next()
+		}
+	}
+}
+
+// skipBackward moves all of the merged iterators positioned at the passed key
+// to their previous entry.
+func (iter *mergingIter) skipBackward(key []byte) {
+	for _, it := range iter.iters {
+		if it.Valid() && bytes.Equal(it.Key(), key) {
+			it.Prev()
+		}
+	}
+}
+
+// findNext positions the iterator at the smallest key the merged iterators are
+// positioned at, skipping tombstones as needed.
+func (iter *mergingIter) findNext() bool {
+	for {
+		if !iter.checkErrors() {
+			return iter.invalidate()
+		}
+
+		// Choose the iterator with the smallest key.  Since the
+		// iterators are ordered from newest to oldest, the newest one
+		// is chosen when several of them are positioned at the key.
+		var cur internalIter
+		for _, it := range iter.iters {
+			if !it.Valid() {
+				continue
+			}
+			if cur == nil || bytes.Compare(it.Key(), cur.Key()) < 0 {
+				cur = it
+			}
+		}
+		if cur == nil {
+			return iter.invalidate()
+		}
+		key := cur.Key()
+		if iter.limit != nil && bytes.Compare(key, iter.limit) >= 0 {
+			return iter.invalidate()
+		}
+		value := cur.Value()
+		if value[0] == kindDelete && !iter.raw {
+			iter.skipForward(key)
+			continue
+		}
+
+		iter.key = key
+		iter.value = value
+		iter.valid = true
+		return true
+	}
+}
+
+// findPrev positions the iterator at the largest key the merged iterators are
+// positioned at, skipping tombstones as needed.
+func (iter *mergingIter) findPrev() bool {
+	for {
+		if !iter.checkErrors() {
+			return iter.invalidate()
+		}
+
+		// Choose the iterator with the largest key.  Since the
+		// iterators are ordered from newest to oldest, the newest one
+		// is chosen when several of them are positioned at the key.
+		var cur internalIter
+		for _, it := range iter.iters {
+			if !it.Valid() {
+				continue
+			}
+			if cur == nil || bytes.Compare(it.Key(), cur.Key()) > 0 {
+				cur = it
+			}
+		}
+		if cur == nil {
+			return iter.invalidate()
+		}
+		key := cur.Key()
+		if iter.start != nil && bytes.Compare(key, iter.start) < 0 {
+			return iter.invalidate()
+		}
+		value := cur.Value()
+		if value[0] == kindDelete && !iter.raw {
+			iter.skipBackward(key)
+			continue
+		}
+
+		iter.key = key
+		iter.value = value
+		iter.valid = true
+		return true
+	}
+}
+
+// First positions the iterator at the first key/value pair and returns whether
+// or not the pair exists.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *mergingIter) First() bool {
+	if iter.released {
+		return false
+	}
+	iter.dir = dirForward
+	for _, it := range iter.iters {
+		if iter.start != nil {
+			it.Seek(iter.start)
+		} else {
+			it.First()
+		}
+	}
+	return iter.findNext()
+}
+
+// Last positions the iterator at the last key/value pair and returns whether or
+// not the pair exists.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *mergingIter) Last() bool {
+	if iter.released {
+		return false
+	}
+	iter.dir = dirBackward
+	for _, it := range iter.iters {
+		if iter.limit != nil {
+			if it.Seek(iter.limit) {
+				it.Prev()
+			} else {
+				it.Last()
+			}
+		} else {
+			it.Last()
+		}
+	}
+	return iter.findPrev()
+}
+
+// Seek positions the iterator at the first key/value pair that is greater than
+// or equal to the passed seek key.  Returns false if no suitable key was found.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *mergingIter) Seek(key []byte) bool {
+	if iter.released {
+		return false
+	}
+	if iter.start != nil && bytes.Compare(key, iter.start) < 0 {
+		key = iter.start
+	}
+	iter.dir = dirForward
+	for _, it := range iter.iters {
+		it.Seek(key)
+	}
+	return iter.findNext()
+}
+
+// Next moves the iterator one key/value pair forward and returns whether or not
+// the pair exists.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *mergingIter) Next() bool {
+	if !iter.valid {
+		return false
+	}
+
+	// Position all of the merged iterators after the current key when
+	// changing direction.  Otherwise, only the ones at the current key
+	// need to be moved.
+	if iter.dir == dirBackward {
+		iter.dir = dirForward
+		for _, it := range iter.iters {
+			it.Seek(iter.key)
+		}
+	}
+	iter.skipForward(iter.key)
+	return iter.findNext()
+}
+
+// Prev moves the iterator one key/value pair backward and returns whether or
+// not the pair exists.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *mergingIter) Prev() bool {
+	if !iter.valid {
+		return false
+	}
+
+	// Position all of the merged iterators before the current key when
+	// changing direction.  Otherwise, only the ones at the current key
+	// need to be moved.
+	if iter.dir == dirForward {
+		iter.dir = dirBackward
+		for _, it := range iter.iters {
+			if it.Seek(iter.key) {
+				it.Prev()
+			} else {
+				it.Last()
+			}
+		}
+		return iter.findPrev()
+	}
+	iter.skipBackward(iter.key)
+	return iter.findPrev()
+}
+
+// Valid indicates whether the iterator is positioned at a valid key/value pair.
+// It will be considered invalid when the iterator is newly created or exhausted.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *mergingIter) Valid() bool {
+	return iter.valid
+}
+
+// Key returns the current key the iterator is pointing to.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *mergingIter) Key() []byte {
+	return iter.key
+}
+
+// Value returns the current value the iterator is pointing to.  The kind
+// prefix is included for raw iterators.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *mergingIter) Value() []byte {
+	if !iter.valid || iter.raw {
+		return iter.value
+	}
+	return iter.value[1:]
+}
+
+// SetReleaser sets a releaser which is invoked when the iterator is released.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *mergingIter) SetReleaser(releaser util.Releaser) {
+	iter.releaser = releaser
+}
+
+// Release releases the iterator along with the version of the metadata it
+// iterates over.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *mergingIter) Release() {
+	if iter.released {
+		return
+	}
+	iter.released = true
+	iter.invalidate()
+	iter.iters = nil
+	if iter.release != nil {
+		iter.release()
+	}
+	if iter.releaser != nil {
+		iter.releaser.Release()
+	}
+}
+
+// Error returns any error that was encountered while reading the merged
+// tables.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *mergingIter) Error() error {
+	return iter.err
+}
@@ -0,0 +1,1016 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the implementation of the metadata store which persists
+// the metadata in a log-structured merge tree.
+//
+// Committed transactions are appended to a metadata log file and applied to an
+// in-memory treap, called the memtable.  Once the memtable grows large enough,
+// it is frozen, a new memtable along with a new log file is started, and the
+// frozen memtable is written to an immutable table file in the background.
+// Table files are merged together by background compactions to bound their
+// number.  The manifest file records the current table files and the first log
+// file which still needs to be replayed on open.
+
+package treapdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/btcsuite/goleveldb/leveldb/iterator"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/database/internal/flatdb"
+	"github.com/coolsnady/hcd/database/internal/treap"
+)
+
+const (
+	// manifestName is the name of the file which records the current table
+	// files and metadata log files.
+	manifestName = "MANIFEST"
+
+	// manifestVersion is the current version of the manifest file format.
+	manifestVersion = 1
+
+	// manifestHeaderSize is the size of the header of the manifest file.
+	//
+	// The serialized manifest format is:
+	//
+	//  [0:8]   Magic (8 bytes)
+	//  [8:12]  Version (4 bytes)
+	//  [12:20] Next file number (8 bytes)
+	//  [20:28] First log file number to replay (8 bytes)
+	//  [28:32] Number of tables (4 bytes)
+	//  Each table ordered from the oldest to the newest:
+	//    File number (8 bytes)
+	//    File size (8 bytes)
+	//  Castagnoli CRC-32 checksum of everything before it (4 bytes)
+	manifestHeaderSize = 32
+
+	// tmpFileSuffix is the suffix of files which are being written and
+	// renamed into place once they are complete.
+	tmpFileSuffix = ".tmp"
+
+	// compactionSizeRatio is the ratio the size of a table may have to the
+	// combined size of all newer tables in order for it to be merged with
+	// them by a compaction.
+	compactionSizeRatio = 2
+
+	// compactionCheckInterval is the number of entries written by a
+	// compaction in between checks for whether the store is being closed.
+	compactionCheckInterval = 1024
+)
+
+var (
+	// manifestMagic identifies a manifest file.
+	manifestMagic = [8]byte{'t', 'r', 'e', 'a', 'p', 'm', 'f', 't'}
+
+	// errStoreClosing is returned by background work which is aborted since
+	// the store is being closed.
+	errStoreClosing = errors.New("metadata store is closing")
+)
+
+// storeConfig houses the parameters which control when memtables are flushed
+// and table files are compacted.
+type storeConfig struct {
+	// memtableSize is the size the memtable has to reach before it is
+	// frozen and flushed to a table file.
+	memtableSize uint64
+
+	// maxFrozenMemtables is the maximum number of frozen memtables which
+	// are waiting to be flushed.  Commits block until a flush completes
+	// once it has been reached.
+	maxFrozenMemtables int
+
+	// compactionTables is the minimum number of tables a compaction merges.
+	compactionTables int
+
+	// tableBlockSize is the size of the data blocks of the table files.
+	tableBlockSize int
+}
+
+// defaultStoreConfig is the configuration of the metadata store used by the
+// database driver.
+var defaultStoreConfig = storeConfig{
+	memtableSize:       4 * 1024 * 1024, // 4 MiB
+	maxFrozenMemtables: 4,
+	compactionTables:   4,
+	tableBlockSize:     defaultTableBlockSize,
+}
+
+// manifest houses the contents of the manifest file.
+type manifest struct {
+	nextFileNum uint64
+	logNum      uint64
+	tables      []manifestTable
+}
+
+// manifestTable describes a table file recorded in the manifest.
+type manifestTable struct {
+	fileNum uint64
+	size    uint64
+}
+
+// writeManifest writes the passed manifest to the provided metadata path.  It is
+// written to a temporary file first and then renamed, so the previous manifest
+// stays intact until the new one is completely written.
+func writeManifest(metaPath string, m *manifest) error {
+	buf := make([]byte, manifestHeaderSize, manifestHeaderSize+
+		len(m.tables)*16+4)
+	copy(buf[0:8], manifestMagic[:])
+	byteOrder.PutUint32(buf[8:12], manifestVersion)
+	byteOrder.PutUint64(buf[12:20], m.nextFileNum)
+	byteOrder.PutUint64(buf[20:28], m.logNum)
+	byteOrder.PutUint32(buf[28:32], uint32(len(m.tables)))
+	var entry [16]byte
+	for _, t := range m.tables {
+		byteOrder.PutUint64(entry[0:8], t.fileNum)
+		byteOrder.PutUint64(entry[8:16], t.size)
+		buf = append(buf, entry[:]...)
+	}
+	var checksum [4]byte
+	byteOrder.PutUint32(checksum[:], crc32.Checksum(buf, castagnoli))
+	buf = append(buf, checksum[:]...)
+
+	path := filepath.Join(metaPath, manifestName)
+	tmpPath := path + tmpFileSuffix
+	file, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC,
+		0600)
+	if err == nil {
+		_, err = file.Write(buf)
+		if err == nil {
+			err = file.Sync()
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		str := fmt.Sprintf("failed to write metadata manifest: %v", err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	syncDir(metaPath)
+	return nil
+}
+
+// readManifest reads the manifest file from the provided metadata path.
+func readManifest(metaPath string) (*manifest, error) {
+	path := filepath.Join(metaPath, manifestName)
+	corruptErr := func(desc string) error {
+		str := fmt.Sprintf("metadata manifest %q is corrupt: %s", path,
+			desc)
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, corruptErr("file is missing")
+		}
+		str := fmt.Sprintf("failed to read metadata manifest %q: %v",
+			path, err)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	if len(buf) < manifestHeaderSize+4 {
+		return nil, corruptErr("short header")
+	}
+	if !bytes.Equal(buf[0:8], manifestMagic[:]) {
+		return nil, corruptErr("invalid magic")
+	}
+	checksumOffset := len(buf) - 4
+	wantChecksum := byteOrder.Uint32(buf[checksumOffset:])
+	if crc32.Checksum(buf[:checksumOffset], castagnoli) != wantChecksum {
+		return nil, corruptErr("checksum mismatch")
+	}
+	if version := byteOrder.Uint32(buf[8:12]); version != manifestVersion {
+		return nil, corruptErr(fmt.Sprintf("unsupported version %d",
+			version))
+	}
+	m := &manifest{
+		nextFileNum: byteOrder.Uint64(buf[12:20]),
+		logNum:      byteOrder.Uint64(buf[20:28]),
+	}
+	numTables := int(byteOrder.Uint32(buf[28:32]))
+	if checksumOffset != manifestHeaderSize+numTables*16 {
+		return nil, corruptErr("unexpected size")
+	}
+	for i := 0; i < numTables; i++ {
+		entry := buf[manifestHeaderSize+i*16:]
+		m.tables = append(m.tables, manifestTable{
+			fileNum: byteOrder.Uint64(entry[0:8]),
+			size:    byteOrder.Uint64(entry[8:16]),
+		})
+	}
+	return m, nil
+}
+
+// version houses the table files which make up the flushed metadata at a
+// particular point in time ordered from the oldest to the newest.  Versions are
+// reference counted so the tables are kept open while they are in use by
+// snapshots and iterators.
+type version struct {
+	refs   int32 // Must be accessed atomically.
+	tables []*table
+}
+
+// newVersion returns a new version made up of the passed tables.  The returned
+// version has a single reference which is owned by the caller.
+func newVersion(tables []*table) *version {
+	for _, t := range tables {
+		t.ref()
+	}
+	return &version{refs: 1, tables: tables}
+}
+
+// ref adds a reference to the version.
+func (v *version) ref() {
+	atomic.AddInt32(&v.refs, 1)
+}
+
+// unref removes a reference from the version.  The references to its tables
+// are removed once the last reference is removed.
+func (v *version) unref() {
+	if atomic.AddInt32(&v.refs, -1) != 0 {
+		return
+	}
+	for _, t := range v.tables {
+		t.unref()
+	}
+}
+
+// memtable houses the in-memory metadata which has not been flushed to a table
+// file yet.  The keys are kept in an immutable treap to support O(1) MVCC
+// snapshots, and deleted keys are recorded as tombstones.
+type memtable struct {
+	keys *treap.Immutable
+
+	// logNum is the number of the first log file which holds the entries
+	// of the memtable.  Every later log file up to the one of the next
+	// memtable does as well.
+	logNum uint64
+}
+
+// metaSnapshot houses a snapshot of the metadata store at a particular point in
+// time.  It implements the flatdb.MetadataSnapshot interface.
+type metaSnapshot struct {
+	// mems houses the memtables ordered from the newest to the oldest.
+	mems     []*treap.Immutable
+	version  *version
+	released bool
+}
+
+// Enforce metaSnapshot implements the flatdb.MetadataSnapshot interface.
+var _ flatdb.MetadataSnapshot = (*metaSnapshot)(nil)
+
+// lookup returns the kind prefixed value of the newest entry for the passed key
+// along with whether or not there is one.
+func (snap *metaSnapshot) lookup(key []byte) ([]byte, bool) {
+	for _, mem := range snap.mems {
+		if value := mem.Get(key); value != nil {
+			return value, true
+		}
+	}
+	tables := snap.version.tables
+	for i := len(tables) - 1; i >= 0; i-- {
+		value, ok, err := tables[i].get(key)
+		if err != nil {
+			log.Errorf("Failed to read metadata key %x: %v", key, err)
+			return nil, false
+		}
+		if ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// Has returns whether or not the passed key exists.
+//
+// This is part of the flatdb.MetadataSnapshot interface implementation.
+func (snap *metaSnapshot) Has(key []byte) bool {
+	value, ok := snap.lookup(key)
+	return ok && value[0] == kindPut
+}
+
+// Get returns the value for the passed key.  The function will return nil when
+// the key does not exist.
+//
+// This is part of the flatdb.MetadataSnapshot interface implementation.
+func (snap *metaSnapshot) Get(key []byte) []byte {
+	value, ok := snap.lookup(key)
+	if !ok || value[0] != kindPut {
+		return nil
+	}
+	return value[1:]
+}
+
+// NewIterator returns a new iterator for the snapshot.  The newly returned
+// iterator is not pointing to a valid item until a call to one of the methods
+// to position it is made.
+//
+// The slice parameter allows the iterator to be limited to a range of keys.
+// The start key is inclusive and the limit key is exclusive.  Either or both
+// can be nil if the functionality is not desired.
+//
+// This is part of the flatdb.MetadataSnapshot interface implementation.
+func (snap *metaSnapshot) NewIterator(slice *util.Range) iterator.Iterator {
+	iters := make([]internalIter, 0, len(snap.mems)+
+		len(snap.version.tables))
+	for _, mem := range snap.mems {
+		iters = append(iters, &memIter{Iterator: mem.Iterator(nil, nil)})
+	}
+	tables := snap.version.tables
+	for i := len(tables) - 1; i >= 0; i-- {
+		iters = append(iters, tables[i].newIterator())
+	}
+
+	// The iterator keeps its own reference to the version so the tables
+	// remain open until it is released.
+	snap.version.ref()
+	return newMergingIter(iters, slice, false, snap.version.unref)
+}
+
+// Release releases the snapshot.
+//
+// This is part of the flatdb.MetadataSnapshot interface implementation.
+func (snap *metaSnapshot) Release() {
+	if snap.released {
+		return
+	}
+	snap.released = true
+	snap.version.unref()
+	snap.mems = nil
+}
+
+// metaStore houses the state of the metadata store.  It implements the
+// flatdb.MetadataStore interface.
+type metaStore struct {
+	path       string
+	cfg        storeConfig
+	syncBlocks func() error
+
+	// log is the log file of the current memtable.  It is only accessed
+	// while the database write lock is held or once the background
+	// goroutines have been stopped, so it does not need to be protected.
+	log *logWriter
+
+	// The following fields are protected by the mutex.  The condition
+	// variable is signalled whenever a frozen memtable has been flushed or
+	// the store is closed.
+	//
+	// mem is the current memtable and frozen houses the frozen memtables
+	// which are waiting to be flushed ordered from the oldest to the
+	// newest.
+	//
+	// current is the current version of the table files.
+	//
+	// bgErr is the error encountered by a background flush, if any.  The
+	// store does not accept any more commits once it is set.
+	mtx         sync.Mutex
+	cond        *sync.Cond
+	mem         *memtable
+	frozen      []*memtable
+	current     *version
+	nextFileNum uint64
+	bgErr       error
+	closed      bool
+
+	// manifestMtx serializes changes to the version of the table files
+	// made by the background flushes and compactions along with writing
+	// the manifest which records them.
+	manifestMtx sync.Mutex
+
+	flushSignal   chan struct{}
+	compactSignal chan struct{}
+	quit          chan struct{}
+	wg            sync.WaitGroup
+}
+
+// Enforce metaStore implements the flatdb.MetadataStore interface.
+var _ flatdb.MetadataStore = (*metaStore)(nil)
+
+// errStoreClosed returns the error for operations against a closed store.
+func errStoreClosed() error {
+	return makeDbErr(database.ErrDbNotOpen, "metadata store is not open",
+		nil)
+}
+
+// allocFileNum returns the next unused file number.
+//
+// This function MUST be called with the mutex held.
+func (s *metaStore) allocFileNum() uint64 {
+	fileNum := s.nextFileNum
+	s.nextFileNum++
+	return fileNum
+}
+
+// signal sends a non-blocking signal on the passed channel.
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// Snapshot returns a snapshot of the metadata store at a particular point in
+// time.
+//
+// The snapshot must be released after use by calling Release.
+//
+// This function is part of the flatdb.MetadataStore interface implementation.
+func (s *metaStore) Snapshot() (flatdb.MetadataSnapshot, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.closed {
+		return nil, errStoreClosed()
+	}
+
+	// Since the memtables use an immutable treap, a snapshot of them is
+	// simply obtaining their roots.
+	mems := make([]*treap.Immutable, 0, len(s.frozen)+1)
+	mems = append(mems, s.mem.keys)
+	for i := len(s.frozen) - 1; i >= 0; i-- {
+		mems = append(mems, s.frozen[i].keys)
+	}
+	s.current.ref()
+	return &metaSnapshot{mems: mems, version: s.current}, nil
+}
+
+// rotateMemtable freezes the current memtable so it is flushed in the
+// background and starts a new memtable along with a new log file.  It blocks
+// while the maximum number of frozen memtables are waiting to be flushed.
+//
+// This function MUST be called with the mutex and the database write lock
+// held.
+func (s *metaStore) rotateMemtable() error {
+	for len(s.frozen) >= s.cfg.maxFrozenMemtables && s.bgErr == nil &&
+		!s.closed {
+
+		s.cond.Wait()
+	}
+	if s.bgErr != nil {
+		return s.bgErr
+	}
+
+	fileNum := s.allocFileNum()
+	logFile, err := createLogWriter(s.path, fileNum)
+	if err != nil {
+		return err
+	}
+	if err := s.log.close(); err != nil {
+		log.Warnf("%v", err)
+	}
+	s.log = logFile
+	s.frozen = append(s.frozen, s.mem)
+	s.mem = &memtable{keys: treap.NewImmutable(), logNum: fileNum}
+	signal(s.flushSignal)
+	return nil
+}
+
+// Commit atomically commits the passed keys to add or update and keys to
+// remove.  The changes are appended to the current log file, which is synced
+// before the function returns, and then applied to the current memtable.
+//
+// This function is part of the flatdb.MetadataStore interface implementation.
+func (s *metaStore) Commit(pendingKeys, pendingRemove *treap.Mutable) error {
+	s.mtx.Lock()
+	if s.closed {
+		s.mtx.Unlock()
+		return errStoreClosed()
+	}
+	if s.bgErr != nil {
+		err := s.bgErr
+		s.mtx.Unlock()
+		return err
+	}
+
+	// Freeze the current memtable before writing to it when it has grown
+	// too large.  This is done before the changes are logged, so any
+	// failure does not leave a commit which was logged, but reported as
+	// failed.
+	if s.mem.keys.Size() >= s.cfg.memtableSize {
+		if err := s.rotateMemtable(); err != nil {
+			s.mtx.Unlock()
+			return err
+		}
+	}
+	keys := s.mem.keys
+	s.mtx.Unlock()
+
+	if pendingKeys.Len() == 0 && pendingRemove.Len() == 0 {
+		return nil
+	}
+
+	// Sync the flat block files before logging the metadata which might
+	// reference blocks written during the same transaction.
+	if err := s.syncBlocks(); err != nil {
+		return err
+	}
+	if err := s.log.writeRecord(pendingKeys, pendingRemove); err != nil {
+		return err
+	}
+
+	// Apply the changes to the memtable and atomically replace it.
+	pendingKeys.ForEach(func(k, v []byte) bool {
+		keys = keys.Put(k, putValue(v))
+		return true
+	})
+	pendingRemove.ForEach(func(k, v []byte) bool {
+		keys = keys.Put(k, tombstone)
+		return true
+	})
+	s.mtx.Lock()
+	s.mem.keys = keys
+	s.mtx.Unlock()
+	return nil
+}
+
+// Flush persists all metadata which has been committed to the store.  There is
+// nothing to do since every commit is synced to the log file before it returns.
+//
+// This function is part of the flatdb.MetadataStore interface implementation.
+func (s *metaStore) Flush() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.closed {
+		return errStoreClosed()
+	}
+	return nil
+}
+
+// writeTable writes the kind prefixed entries produced by the passed iterator
+// to a new table file.  Tombstones are skipped when the drop flag is set.  It
+// returns nil without creating a file when there are no entries to write.
+func (s *metaStore) writeTable(iter internalIter, dropTombstones bool) (*table, error) {
+	var w *tableWriter
+	var fileNum uint64
+	for ok := iter.First(); ok; ok = iter.Next() {
+		value := iter.Value()
+		if dropTombstones && value[0] == kindDelete {
+			continue
+		}
+		if w == nil {
+			s.mtx.Lock()
+			fileNum = s.allocFileNum()
+			s.mtx.Unlock()
+			var err error
+			w, err = createTableWriter(s.path, fileNum,
+				s.cfg.tableBlockSize)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := w.add(iter.Key(), value); err != nil {
+			w.abort()
+			str := fmt.Sprintf("failed to write table %d: %v",
+				fileNum, err)
+			return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+		if w.numEntries()%compactionCheckInterval == 0 {
+			select {
+			case <-s.quit:
+				w.abort()
+				return nil, errStoreClosing
+			default:
+			}
+		}
+	}
+	if err := iter.Error(); err != nil {
+		if w != nil {
+			w.abort()
+		}
+		return nil, err
+	}
+	if w == nil {
+		return nil, nil
+	}
+	size, err := w.finish()
+	if err != nil {
+		return nil, err
+	}
+	syncDir(s.path)
+	return openTable(s.path, fileNum, size)
+}
+
+// installVersion writes a manifest with the passed tables and first log file
+// number to replay and makes the tables the current version.
+//
+// This function MUST be called with the manifest mutex held.
+func (s *metaStore) installVersion(tables []*table, logNum uint64) error {
+	s.mtx.Lock()
+	m := &manifest{nextFileNum: s.nextFileNum, logNum: logNum}
+	s.mtx.Unlock()
+	for _, t := range tables {
+		m.tables = append(m.tables, manifestTable{t.fileNum, t.size})
+	}
+	if err := writeManifest(s.path, m); err != nil {
+		return err
+	}
+
+	v := newVersion(tables)
+	s.mtx.Lock()
+	old := s.current
+	s.current = v
+	s.mtx.Unlock()
+	old.unref()
+	return nil
+}
+
+// flushMemtable writes the oldest frozen memtable to a new table file, records
+// it in the manifest, and removes the log files which are no longer needed.
+func (s *metaStore) flushMemtable(mem *memtable) error {
+	iter := &memIter{Iterator: mem.keys.Iterator(nil, nil)}
+	t, err := s.writeTable(iter, false)
+	if err != nil {
+		return err
+	}
+
+	s.manifestMtx.Lock()
+	defer s.manifestMtx.Unlock()
+
+	// The log files of the flushed memtable are no longer needed once the
+	// manifest notes the first log file of the next memtable.
+	s.mtx.Lock()
+	logNum := s.mem.logNum
+	if len(s.frozen) > 1 {
+		logNum = s.frozen[1].logNum
+	}
+	tables := make([]*table, 0, len(s.current.tables)+1)
+	tables = append(tables, s.current.tables...)
+	s.mtx.Unlock()
+	if t != nil {
+		tables = append(tables, t)
+	}
+	err = s.installVersion(tables, logNum)
+	if t != nil {
+		if err != nil {
+			t.markObsolete()
+		}
+		t.unref()
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	s.frozen = s.frozen[1:]
+	s.cond.Broadcast()
+	s.mtx.Unlock()
+	for fileNum := mem.logNum; fileNum < logNum; fileNum++ {
+		err := os.Remove(logFilePath(s.path, fileNum))
+		if err != nil && !os.IsNotExist(err) {
+			log.Warnf("Failed to remove metadata log %d: %v",
+				fileNum, err)
+		}
+	}
+	signal(s.compactSignal)
+	return nil
+}
+
+// flushHandler flushes frozen memtables to table files as they are frozen.
+//
+// This function MUST be run as a goroutine.
+func (s *metaStore) flushHandler() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.flushSignal:
+		case <-s.quit:
+			return
+		}
+
+		for {
+			s.mtx.Lock()
+			if len(s.frozen) == 0 || s.closed {
+				s.mtx.Unlock()
+				break
+			}
+			mem := s.frozen[0]
+			s.mtx.Unlock()
+
+			if err := s.flushMemtable(mem); err != nil {
+				if err == errStoreClosing {
+					return
+				}
+
+				// Stop accepting commits since the frozen
+				// memtables can no longer be flushed.
+				log.Errorf("Failed to flush metadata: %v", err)
+				s.mtx.Lock()
+				s.bgErr = err
+				s.cond.Broadcast()
+				s.mtx.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// pickCompaction returns the range of the passed tables, which are ordered from
+// the oldest to the newest, that should be merged by a compaction.  The newest
+// table and each older table whose size is at most a small multiple of the
+// combined size of all newer tables are merged once there are enough of them.
+// This keeps the number of tables logarithmic in the size of the metadata while
+// each entry is only rewritten a logarithmic number of times.
+func pickCompaction(tables []*table, minTables int) (int, int, bool) {
+	if len(tables) < minTables || len(tables) < 2 {
+		return 0, 0, false
+	}
+	end := len(tables)
+	start := end - 1
+	size := tables[start].size
+	for start > 0 && tables[start-1].size <= compactionSizeRatio*size {
+		start--
+		size += tables[start].size
+	}
+	if end-start < minTables {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// compact merges the passed range of tables of the passed version into a single
+// table and replaces them with it.  Tombstones are only retained when there are
+// older tables which might contain the deleted keys.
+func (s *metaStore) compact(v *version, start, end int) error {
+	run := v.tables[start:end]
+	iters := make([]internalIter, 0, len(run))
+	for i := len(run) - 1; i >= 0; i-- {
+		iters = append(iters, run[i].newIterator())
+	}
+	iter := newMergingIter(iters, nil, true, nil)
+	t, err := s.writeTable(iter, start == 0)
+	if err != nil {
+		return err
+	}
+
+	s.manifestMtx.Lock()
+	defer s.manifestMtx.Unlock()
+
+	// Replace the merged tables in the current version.  They are still
+	// contiguous since flushes only ever add newer tables and compactions
+	// are not run concurrently.
+	s.mtx.Lock()
+	cur := s.current.tables
+	i := 0
+	for i < len(cur) && cur[i] != run[0] {
+		i++
+	}
+	tables := make([]*table, 0, len(cur)-len(run)+1)
+	tables = append(tables, cur[:i]...)
+	if t != nil {
+		tables = append(tables, t)
+	}
+	tables = append(tables, cur[i+len(run):]...)
+	logNum := s.mem.logNum
+	if len(s.frozen) > 0 {
+		logNum = s.frozen[0].logNum
+	}
+	s.mtx.Unlock()
+
+	err = s.installVersion(tables, logNum)
+	if t != nil {
+		if err != nil {
+			t.markObsolete()
+		}
+		t.unref()
+	}
+	if err != nil {
+		return err
+	}
+	for _, old := range run {
+		old.markObsolete()
+	}
+	log.Debugf("Compacted %d metadata tables", len(run))
+	return nil
+}
+
+// compactionHandler merges table files as they are flushed.
+//
+// This function MUST be run as a goroutine.
+func (s *metaStore) compactionHandler() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.compactSignal:
+		case <-s.quit:
+			return
+		}
+
+		for {
+			s.mtx.Lock()
+			v := s.current
+			v.ref()
+			s.mtx.Unlock()
+
+			start, end, ok := pickCompaction(v.tables,
+				s.cfg.compactionTables)
+			var err error
+			if ok {
+				err = s.compact(v, start, end)
+			}
+			v.unref()
+			if !ok || err == errStoreClosing {
+				break
+			}
+			if err != nil {
+				// Compactions are retried once the next table
+				// is flushed since the store remains usable.
+				log.Errorf("Failed to compact metadata: %v", err)
+				break
+			}
+		}
+	}
+}
+
+// Close stops the background flushes and compactions and closes the store.
+// The memtables are not flushed since their log files are replayed on open.
+//
+// This function is part of the flatdb.MetadataStore interface implementation.
+func (s *metaStore) Close() error {
+	s.mtx.Lock()
+	if s.closed {
+		s.mtx.Unlock()
+		return errStoreClosed()
+	}
+	s.closed = true
+	s.cond.Broadcast()
+	s.mtx.Unlock()
+
+	close(s.quit)
+	s.wg.Wait()
+
+	err := s.log.close()
+	s.mtx.Lock()
+	s.current.unref()
+	s.mtx.Unlock()
+	return err
+}
+
+// parseFileNum parses the file number from the passed file name according to
+// the passed template.
+func parseFileNum(name, template string) (uint64, bool) {
+	var fileNum uint64
+	n, err := fmt.Sscanf(name, template, &fileNum)
+	if n != 1 || err != nil || name != fmt.Sprintf(template, fileNum) {
+		return 0, false
+	}
+	return fileNum, true
+}
+
+// createMetaStore creates the directory of a new metadata store at the provided
+// path and writes its initial manifest.
+func createMetaStore(path string) error {
+	if err := os.Mkdir(path, 0700); err != nil {
+		if os.IsExist(err) {
+			str := fmt.Sprintf("database %q already exists", path)
+			return makeDbErr(database.ErrDbExists, str, err)
+		}
+		str := fmt.Sprintf("failed to create metadata directory: %v", err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	if err := writeManifest(path, &manifest{nextFileNum: 1}); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// openMetaStore opens the metadata store at the provided path.  The store is
+// created first when the create flag is set.  The log files which have not been
+// flushed yet are replayed into the memtable and any files left behind by an
+// unclean shutdown are removed.
+func openMetaStore(path string, create bool, syncBlocks func() error, cfg storeConfig) (*metaStore, error) {
+	if create {
+		if err := createMetaStore(path); err != nil {
+			return nil, err
+		}
+	}
+	m, err := readManifest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the log and table files and remove any temporary files along
+	// with table files which are not in the manifest.  Those are the
+	// result of an unclean shutdown while a flush or compaction was in
+	// progress.
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		str := fmt.Sprintf("failed to read metadata directory: %v", err)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	inManifest := make(map[uint64]struct{}, len(m.tables))
+	for _, t := range m.tables {
+		inManifest[t.fileNum] = struct{}{}
+	}
+	nextFileNum := m.nextFileNum
+	var logNums []uint64
+	for _, file := range files {
+		name := file.Name()
+		if strings.HasSuffix(name, tmpFileSuffix) {
+			_ = os.Remove(filepath.Join(path, name))
+			continue
+		}
+		if fileNum, ok := parseFileNum(name, logFileTemplate); ok {
+			if fileNum >= nextFileNum {
+				nextFileNum = fileNum + 1
+			}
+			if fileNum < m.logNum {
+				_ = os.Remove(filepath.Join(path, name))
+				continue
+			}
+			logNums = append(logNums, fileNum)
+			continue
+		}
+		if fileNum, ok := parseFileNum(name, tableFileTemplate); ok {
+			if fileNum >= nextFileNum {
+				nextFileNum = fileNum + 1
+			}
+			if _, ok := inManifest[fileNum]; !ok {
+				_ = os.Remove(filepath.Join(path, name))
+			}
+		}
+	}
+	sort.Slice(logNums, func(i, j int) bool {
+		return logNums[i] < logNums[j]
+	})
+
+	// Open the tables in the manifest.
+	tables := make([]*table, 0, len(m.tables))
+	closeTables := func() {
+		for _, t := range tables {
+			t.unref()
+		}
+	}
+	for _, mt := range m.tables {
+		t, err := openTable(path, mt.fileNum, mt.size)
+		if err != nil {
+			closeTables()
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+
+	// Replay the log files into the memtable.  The memtable is loaded into
+	// a mutable treap since it is much more efficient for bulk updates and
+	// then converted once it's complete.
+	mem := treap.NewMutable()
+	for i, logNum := range logNums {
+		err := replayLogFile(mem, logFilePath(path, logNum),
+			i == len(logNums)-1)
+		if err != nil {
+			closeTables()
+			return nil, err
+		}
+	}
+
+	// Start a new log file for the changes made after opening.
+	logFile, err := createLogWriter(path, nextFileNum)
+	if err != nil {
+		closeTables()
+		return nil, err
+	}
+	memLogNum := nextFileNum
+	if len(logNums) > 0 {
+		memLogNum = logNums[0]
+	}
+
+	s := &metaStore{
+		path:          path,
+		cfg:           cfg,
+		syncBlocks:    syncBlocks,
+		log:           logFile,
+		mem:           &memtable{keys: mem.Immutable(), logNum: memLogNum},
+		current:       newVersion(tables),
+		nextFileNum:   nextFileNum + 1,
+		flushSignal:   make(chan struct{}, 1),
+		compactSignal: make(chan struct{}, 1),
+		quit:          make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mtx)
+	for _, t := range tables {
+		t.unref()
+	}
+
+	s.wg.Add(2)
+	go s.flushHandler()
+	go s.compactionHandler()
+	signal(s.compactSignal)
+	return s, nil
+}
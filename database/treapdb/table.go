@@ -0,0 +1,625 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the implementation functions for the immutable table
+// files which house the metadata once the memtables holding it are flushed.
+
+package treapdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+
+	"github.com/coolsnady/hcd/database"
+)
+
+const (
+	// tableFileTemplate is the pattern used for naming the table files.
+	// Each table file is identified by a file number which is shared with
+	// the metadata log files.
+	tableFileTemplate = "%09d.tbl"
+
+	// defaultTableBlockSize is the size the entries of a data block of a
+	// table have to reach before the block is finished.
+	defaultTableBlockSize = 16 * 1024 // 16 KiB
+
+	// tableFooterSize is the size of the footer at the end of each table
+	// file.
+	//
+	// The serialized table file format is:
+	//
+	//  <data block><checksum>...<index block><checksum><filter><checksum>
+	//  <footer>
+	//
+	// Each data block consists of entries sorted by key that are
+	// serialized as the variable length encoded key length, the key, the
+	// variable length encoded value length and the value.  The index block
+	// consists of an entry for each data block which is serialized as the
+	// variable length encoded length of the last key in the block, the key,
+	// and the variable length encoded offset and length of the block.  The
+	// filter is a bloom filter of all keys in the table.  The checksums
+	// are Castagnoli CRC-32 checksums of the preceding block.
+	//
+	// The serialized footer format is:
+	//
+	//  [0:8]   Offset of the index block (8 bytes)
+	//  [8:16]  Length of the index block (8 bytes)
+	//  [16:24] Offset of the filter (8 bytes)
+	//  [24:32] Length of the filter (8 bytes)
+	//  [32:40] Magic (8 bytes)
+	tableFooterSize = 40
+
+	// bloomBitsPerKey is the number of bits used per key in the bloom
+	// filter of a table.  It results in a false positive rate of roughly
+	// one percent.
+	bloomBitsPerKey = 10
+
+	// bloomProbes is the number of bits set in the bloom filter of a table
+	// for each key.
+	bloomProbes = 7
+)
+
+var (
+	// tableMagic identifies a table file.
+	tableMagic = [8]byte{'t', 'r', 'e', 'a', 'p', 't', 'b', 'l'}
+)
+
+// tableFilePath return the file path for the provided table file number.
+func tableFilePath(metaPath string, fileNum uint64) string {
+	return filepath.Join(metaPath, fmt.Sprintf(tableFileTemplate, fileNum))
+}
+
+// bloomHash returns the hash of the passed key used for the bloom filter.
+func bloomHash(key []byte) uint64 {
+	hasher := fnv.New64a()
+	hasher.Write(key)
+	return hasher.Sum64()
+}
+
+// newBloomFilter returns a bloom filter for the keys with the passed hashes.
+// The probes for each key are derived from the two halves of its hash using
+// double hashing.
+func newBloomFilter(hashes []uint64) []byte {
+	numBits := len(hashes) * bloomBitsPerKey
+	if numBits < 64 {
+		numBits = 64
+	}
+	filter := make([]byte, (numBits+7)/8)
+	numBits = len(filter) * 8
+	for _, h := range hashes {
+		h1, h2 := uint32(h), uint32(h>>32)
+		for i := uint32(0); i < bloomProbes; i++ {
+			bit := (h1 + i*h2) % uint32(numBits)
+			filter[bit/8] |= 1 << (bit % 8)
+		}
+	}
+	return filter
+}
+
+// bloomMayContain returns whether or not the passed bloom filter might contain
+// the passed key.  A false result means the key is definitely not contained.
+func bloomMayContain(filter []byte, key []byte) bool {
+	numBits := uint32(len(filter) * 8)
+	if numBits == 0 {
+		return true
+	}
+	h := bloomHash(key)
+	h1, h2 := uint32(h), uint32(h>>32)
+	for i := uint32(0); i < bloomProbes; i++ {
+		bit := (h1 + i*h2) % numBits
+		if filter[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// appendBytes appends the passed bytes prefixed by their variable length
+// encoded length to the passed buffer.
+func appendBytes(buf *bytes.Buffer, b []byte) {
+	var varIntBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(varIntBuf[:], uint64(len(b)))
+	buf.Write(varIntBuf[:n])
+	buf.Write(b)
+}
+
+// readBytes reads bytes prefixed by their variable length encoded length from
+// the passed serialized data and returns them along with the remaining data.
+func readBytes(data []byte) ([]byte, []byte, bool) {
+	length, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < length {
+		return nil, nil, false
+	}
+	return data[n : n+int(length)], data[n+int(length):], true
+}
+
+// tableWriter writes the sorted entries of a new table file.
+type tableWriter struct {
+	path      string
+	file      *os.File
+	bw        *bufio.Writer
+	offset    uint64
+	blockSize int
+	block     bytes.Buffer
+	lastKey   []byte
+	index     bytes.Buffer
+	hashes    []uint64
+}
+
+// createTableWriter creates a new table file with the provided file number and
+// returns a writer for it.
+func createTableWriter(metaPath string, fileNum uint64, blockSize int) (*tableWriter, error) {
+	path := tableFilePath(metaPath, fileNum)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		str := fmt.Sprintf("failed to create table %q: %v", path, err)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	return &tableWriter{
+		path:      path,
+		file:      file,
+		bw:        bufio.NewWriterSize(file, 1024*1024),
+		blockSize: blockSize,
+	}, nil
+}
+
+// writeChunk writes the passed data followed by its checksum and returns the
+// offset it was written at.
+func (w *tableWriter) writeChunk(data []byte) (uint64, error) {
+	offset := w.offset
+	var checksum [4]byte
+	byteOrder.PutUint32(checksum[:], crc32.Checksum(data, castagnoli))
+	if _, err := w.bw.Write(data); err != nil {
+		return 0, err
+	}
+	if _, err := w.bw.Write(checksum[:]); err != nil {
+		return 0, err
+	}
+	w.offset += uint64(len(data) + len(checksum))
+	return offset, nil
+}
+
+// finishBlock writes the current data block and adds it to the index.
+func (w *tableWriter) finishBlock() error {
+	if w.block.Len() == 0 {
+		return nil
+	}
+	offset, err := w.writeChunk(w.block.Bytes())
+	if err != nil {
+		return err
+	}
+	var varIntBuf [binary.MaxVarintLen64]byte
+	appendBytes(&w.index, w.lastKey)
+	n := binary.PutUvarint(varIntBuf[:], offset)
+	w.index.Write(varIntBuf[:n])
+	n = binary.PutUvarint(varIntBuf[:], uint64(w.block.Len()))
+	w.index.Write(varIntBuf[:n])
+	w.block.Reset()
+	return nil
+}
+
+// add adds the passed entry to the table.  The entries MUST be added in
+// ascending order of their keys.
+func (w *tableWriter) add(key, value []byte) error {
+	appendBytes(&w.block, key)
+	appendBytes(&w.block, value)
+	w.lastKey = append(w.lastKey[:0], key...)
+	w.hashes = append(w.hashes, bloomHash(key))
+	if w.block.Len() < w.blockSize {
+		return nil
+	}
+	return w.finishBlock()
+}
+
+// numEntries returns the number of entries added to the table so far.
+func (w *tableWriter) numEntries() int {
+	return len(w.hashes)
+}
+
+// finish writes the remaining data block, the index block, the filter, and the
+// footer, and then syncs and closes the table file.  It returns the size of the
+// table file.
+func (w *tableWriter) finish() (uint64, error) {
+	err := w.finishBlock()
+	var indexOffset, filterOffset uint64
+	var filter []byte
+	if err == nil {
+		indexOffset, err = w.writeChunk(w.index.Bytes())
+	}
+	if err == nil {
+		filter = newBloomFilter(w.hashes)
+		filterOffset, err = w.writeChunk(filter)
+	}
+	if err == nil {
+		var footer [tableFooterSize]byte
+		byteOrder.PutUint64(footer[0:8], indexOffset)
+		byteOrder.PutUint64(footer[8:16], uint64(w.index.Len()))
+		byteOrder.PutUint64(footer[16:24], filterOffset)
+		byteOrder.PutUint64(footer[24:32], uint64(len(filter)))
+		copy(footer[32:40], tableMagic[:])
+		_, err = w.bw.Write(footer[:])
+		w.offset += tableFooterSize
+	}
+	if err == nil {
+		err = w.bw.Flush()
+	}
+	if err == nil {
+		err = w.file.Sync()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(w.path)
+		str := fmt.Sprintf("failed to write table %q: %v", w.path, err)
+		return 0, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	return w.offset, nil
+}
+
+// abort closes and removes the partially written table file.
+func (w *tableWriter) abort() {
+	_ = w.file.Close()
+	_ = os.Remove(w.path)
+}
+
+// tableIndexEntry describes the location of a data block of a table along with
+// the last key it contains.
+type tableIndexEntry struct {
+	lastKey []byte
+	offset  uint64
+	length  uint64
+}
+
+// table houses an open table file along with its index and bloom filter which
+// are kept in memory.  Tables are reference counted so the file can be closed
+// and, once the table has been replaced by a compaction, removed when it is no
+// longer used by any version of the metadata.
+type table struct {
+	refs     int32 // Must be accessed atomically.
+	obsolete int32 // Must be accessed atomically.
+
+	path    string
+	fileNum uint64
+	size    uint64
+	file    *os.File
+	index   []tableIndexEntry
+	filter  []byte
+}
+
+// openTable opens the table file with the provided file number and ensures it
+// has the expected size.  The returned table has a single reference which is
+// owned by the caller.
+func openTable(metaPath string, fileNum, size uint64) (*table, error) {
+	path := tableFilePath(metaPath, fileNum)
+	corruptErr := func(desc string) error {
+		str := fmt.Sprintf("table %q is corrupt: %s", path, desc)
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, corruptErr("file is missing")
+		}
+		str := fmt.Sprintf("failed to open table %q: %v", path, err)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		str := fmt.Sprintf("failed to stat table %q: %v", path, err)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	if uint64(fi.Size()) != size || size < tableFooterSize {
+		_ = file.Close()
+		return nil, corruptErr(fmt.Sprintf("unexpected size %d, want "+
+			"%d", fi.Size(), size))
+	}
+
+	t := &table{refs: 1, path: path, fileNum: fileNum, size: size,
+		file: file}
+	var footer [tableFooterSize]byte
+	if _, err := file.ReadAt(footer[:], int64(size-tableFooterSize)); err != nil {
+		_ = file.Close()
+		str := fmt.Sprintf("failed to read table %q: %v", path, err)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	if !bytes.Equal(footer[32:40], tableMagic[:]) {
+		_ = file.Close()
+		return nil, corruptErr("invalid magic")
+	}
+	indexOffset := byteOrder.Uint64(footer[0:8])
+	indexLen := byteOrder.Uint64(footer[8:16])
+	filterOffset := byteOrder.Uint64(footer[16:24])
+	filterLen := byteOrder.Uint64(footer[24:32])
+	indexData, err := t.readChunk(indexOffset, indexLen)
+	if err == nil {
+		t.filter, err = t.readChunk(filterOffset, filterLen)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	for len(indexData) > 0 {
+		var entry tableIndexEntry
+		var ok bool
+		entry.lastKey, indexData, ok = readBytes(indexData)
+		var n1, n2 int
+		if ok {
+			entry.offset, n1 = binary.Uvarint(indexData)
+			if n1 > 0 {
+				entry.length, n2 = binary.Uvarint(indexData[n1:])
+			}
+		}
+		if !ok || n1 <= 0 || n2 <= 0 {
+			_ = file.Close()
+			return nil, corruptErr("malformed index")
+		}
+		indexData = indexData[n1+n2:]
+		t.index = append(t.index, entry)
+	}
+	return t, nil
+}
+
+// readChunk reads the chunk at the passed offset and length from the table
+// file and ensures it matches the checksum which follows it.
+func (t *table) readChunk(offset, length uint64) ([]byte, error) {
+	if offset > t.size || length > t.size ||
+		offset+length+4 > t.size-tableFooterSize {
+
+		str := fmt.Sprintf("table %q is corrupt: chunk at offset %d "+
+			"exceeds the file", t.path, offset)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	buf := make([]byte, length+4)
+	if _, err := t.file.ReadAt(buf, int64(offset)); err != nil {
+		str := fmt.Sprintf("failed to read table %q: %v", t.path, err)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	data := buf[:length]
+	if crc32.Checksum(data, castagnoli) != byteOrder.Uint32(buf[length:]) {
+		str := fmt.Sprintf("table %q is corrupt: checksum mismatch for "+
+			"chunk at offset %d", t.path, offset)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	return data, nil
+}
+
+// tableBlock houses the decoded entries of a data block.
+type tableBlock struct {
+	keys   [][]byte
+	values [][]byte
+}
+
+// readBlock reads and decodes the data block with the passed index.
+func (t *table) readBlock(i int) (*tableBlock, error) {
+	entry := &t.index[i]
+	data, err := t.readChunk(entry.offset, entry.length)
+	if err != nil {
+		return nil, err
+	}
+	block := new(tableBlock)
+	for len(data) > 0 {
+		var key, value []byte
+		var ok bool
+		key, data, ok = readBytes(data)
+		if ok {
+			value, data, ok = readBytes(data)
+		}
+		if !ok || len(value) == 0 {
+			str := fmt.Sprintf("table %q is corrupt: malformed block "+
+				"at offset %d", t.path, entry.offset)
+			return nil, makeDbErr(database.ErrCorruption, str, nil)
+		}
+		block.keys = append(block.keys, key)
+		block.values = append(block.values, value)
+	}
+	if len(block.keys) == 0 {
+		str := fmt.Sprintf("table %q is corrupt: empty block at offset "+
+			"%d", t.path, entry.offset)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	return block, nil
+}
+
+// findBlock returns the index of the first data block which might contain
+// keys greater than or equal to the passed key.
+func (t *table) findBlock(key []byte) int {
+	return sort.Search(len(t.index), func(i int) bool {
+		return bytes.Compare(t.index[i].lastKey, key) >= 0
+	})
+}
+
+// get returns the kind prefixed value for the passed key along with whether or
+// not the table contains the key.
+func (t *table) get(key []byte) ([]byte, bool, error) {
+	if !bloomMayContain(t.filter, key) {
+		return nil, false, nil
+	}
+	i := t.findBlock(key)
+	if i == len(t.index) {
+		return nil, false, nil
+	}
+	block, err := t.readBlock(i)
+	if err != nil {
+		return nil, false, err
+	}
+	j := sort.Search(len(block.keys), func(j int) bool {
+		return bytes.Compare(block.keys[j], key) >= 0
+	})
+	if j == len(block.keys) || !bytes.Equal(block.keys[j], key) {
+		return nil, false, nil
+	}
+	return block.values[j], true, nil
+}
+
+// ref adds a reference to the table.
+func (t *table) ref() {
+	atomic.AddInt32(&t.refs, 1)
+}
+
+// unref removes a reference from the table.  The table file is closed once the
+// last reference is removed and removed as well when the table is obsolete.
+func (t *table) unref() {
+	if atomic.AddInt32(&t.refs, -1) != 0 {
+		return
+	}
+	_ = t.file.Close()
+	if atomic.LoadInt32(&t.obsolete) != 0 {
+		if err := os.Remove(t.path); err != nil {
+			log.Warnf("Failed to remove obsolete table %q: %v",
+				t.path, err)
+		}
+	}
+}
+
+// markObsolete marks the table for removal once it is no longer referenced.
+func (t *table) markObsolete() {
+	atomic.StoreInt32(&t.obsolete, 1)
+}
+
+// tableIter provides an iterator over the kind prefixed entries of a table.
+type tableIter struct {
+	t        *table
+	blockIdx int
+	block    *tableBlock
+	pos      int
+	err      error
+}
+
+// Enforce tableIter implements the internalIter interface.
+var _ internalIter = (*tableIter)(nil)
+
+// loadBlock loads the data block with the passed index as the current block.
+// The iterator is exhausted when the index is out of range or the block can't
+// be read.
+func (iter *tableIter) loadBlock(i int) bool {
+	iter.block = nil
+	if i < 0 || i >= len(iter.t.index) {
+		return false
+	}
+	block, err := iter.t.readBlock(i)
+	if err != nil {
+		iter.err = err
+		return false
+	}
+	iter.blockIdx = i
+	iter.block = block
+	return true
+}
+
+// First moves the iterator to the first entry and returns whether or not it
+// exists.
+func (iter *tableIter) First() bool {
+	if !iter.loadBlock(0) {
+		return false
+	}
+	iter.pos = 0
+	return true
+}
+
+// Last moves the iterator to the last entry and returns whether or not it
+// exists.
+func (iter *tableIter) Last() bool {
+	if !iter.loadBlock(len(iter.t.index) - 1) {
+		return false
+	}
+	iter.pos = len(iter.block.keys) - 1
+	return true
+}
+
+// Seek moves the iterator to the first entry with a key greater than or equal
+// to the passed key and returns whether or not it exists.
+func (iter *tableIter) Seek(key []byte) bool {
+	if !iter.loadBlock(iter.t.findBlock(key)) {
+		return false
+	}
+	iter.pos = sort.Search(len(iter.block.keys), func(j int) bool {
+		return bytes.Compare(iter.block.keys[j], key) >= 0
+	})
+	if iter.pos == len(iter.block.keys) {
+		// The index guarantees the block contains a greater key, so
+		// this can only happen with a corrupt index.
+		if !iter.loadBlock(iter.blockIdx + 1) {
+			return false
+		}
+		iter.pos = 0
+	}
+	return true
+}
+
+// Next moves the iterator to the next entry and returns whether or not it
+// exists.
+func (iter *tableIter) Next() bool {
+	if iter.block == nil {
+		return false
+	}
+	iter.pos++
+	if iter.pos < len(iter.block.keys) {
+		return true
+	}
+	if !iter.loadBlock(iter.blockIdx + 1) {
+		return false
+	}
+	iter.pos = 0
+	return true
+}
+
+// Prev moves the iterator to the previous entry and returns whether or not it
+// exists.
+func (iter *tableIter) Prev() bool {
+	if iter.block == nil {
+		return false
+	}
+	iter.pos--
+	if iter.pos >= 0 {
+		return true
+	}
+	if !iter.loadBlock(iter.blockIdx - 1) {
+		return false
+	}
+	iter.pos = len(iter.block.keys) - 1
+	return true
+}
+
+// Valid returns whether or not the iterator is positioned at a valid entry.
+func (iter *tableIter) Valid() bool {
+	return iter.block != nil
+}
+
+// Key returns the key of the current entry.
+func (iter *tableIter) Key() []byte {
+	if iter.block == nil {
+		return nil
+	}
+	return iter.block.keys[iter.pos]
+}
+
+// Value returns the kind prefixed value of the current entry.
+func (iter *tableIter) Value() []byte {
+	if iter.block == nil {
+		return nil
+	}
+	return iter.block.values[iter.pos]
+}
+
+// Error returns any error that was encountered while reading the table.
+func (iter *tableIter) Error() error {
+	return iter.err
+}
+
+// newIterator returns a new iterator over the entries of the table.  The
+// iterator is not positioned at a valid entry until it is moved.
+func (t *table) newIterator() *tableIter {
+	return &tableIter{t: t}
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the implementation functions for the metadata log files
+// which record the changes made by each committed transaction until the
+// memtable holding them has been flushed to a table file.
+
+package treapdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/database/internal/treap"
+)
+
+const (
+	// logFileTemplate is the pattern used for naming the metadata log
+	// files.  Each log file is identified by a file number which is shared
+	// with the table files.
+	logFileTemplate = "%09d.log"
+
+	// logRecordHeaderSize is the size of the header of each record in a
+	// metadata log file.
+	//
+	// The serialized log record header format is:
+	//
+	//  [0:4] Payload length (4 bytes)
+	//  [4:8] Castagnoli CRC-32 checksum of the payload (4 bytes)
+	logRecordHeaderSize = 8
+
+	// The following constants define the operations recorded in the
+	// payload of a log record.  Each operation is serialized as the
+	// operation type followed by the variable length encoded key length,
+	// the key, and for puts, the variable length encoded value length and
+	// the value.
+	logOpPut    = 0x00
+	logOpDelete = 0x01
+)
+
+// The following constants define the kinds of entries in the memtables and
+// table files.  Every value is prefixed by its kind so deleted keys can be
+// recorded as tombstones which hide the values of the key in older tables.
+const (
+	kindDelete byte = 0x00
+	kindPut    byte = 0x01
+)
+
+// tombstone is the value stored for deleted keys.
+var tombstone = []byte{kindDelete}
+
+// putValue returns the passed value prefixed by the put kind.
+func putValue(value []byte) []byte {
+	v := make([]byte, len(value)+1)
+	v[0] = kindPut
+	copy(v[1:], value)
+	return v
+}
+
+// logFilePath return the file path for the provided metadata log file number.
+func logFilePath(metaPath string, fileNum uint64) string {
+	return filepath.Join(metaPath, fmt.Sprintf(logFileTemplate, fileNum))
+}
+
+// serializeLogRecord serializes the passed pending keys and removals into a
+// full log record including the header.
+func serializeLogRecord(pendingKeys, pendingRemove *treap.Mutable) []byte {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, logRecordHeaderSize))
+	var varIntBuf [binary.MaxVarintLen64]byte
+	writeBytes := func(b []byte) {
+		n := binary.PutUvarint(varIntBuf[:], uint64(len(b)))
+		buf.Write(varIntBuf[:n])
+		buf.Write(b)
+	}
+	pendingRemove.ForEach(func(k, v []byte) bool {
+		buf.WriteByte(logOpDelete)
+		writeBytes(k)
+		return true
+	})
+	pendingKeys.ForEach(func(k, v []byte) bool {
+		buf.WriteByte(logOpPut)
+		writeBytes(k)
+		writeBytes(v)
+		return true
+	})
+
+	record := buf.Bytes()
+	payload := record[logRecordHeaderSize:]
+	byteOrder.PutUint32(record[0:4], uint32(len(payload)))
+	byteOrder.PutUint32(record[4:8], crc32.Checksum(payload, castagnoli))
+	return record
+}
+
+// applyLogPayload applies the operations in the passed log record payload to
+// the passed memtable treap.
+func applyLogPayload(t *treap.Mutable, payload []byte) error {
+	readBytes := func() ([]byte, error) {
+		length, n := binary.Uvarint(payload)
+		if n <= 0 || uint64(len(payload)-n) < length {
+			return nil, io.ErrUnexpectedEOF
+		}
+		b := payload[n : n+int(length)]
+		payload = payload[n+int(length):]
+		return b, nil
+	}
+	for len(payload) > 0 {
+		op := payload[0]
+		payload = payload[1:]
+		key, err := readBytes()
+		if err != nil {
+			return err
+		}
+		switch op {
+		case logOpPut:
+			value, err := readBytes()
+			if err != nil {
+				return err
+			}
+			t.Put(key, putValue(value))
+
+		case logOpDelete:
+			t.Put(key, tombstone)
+
+		default:
+			return fmt.Errorf("unknown log operation %d", op)
+		}
+	}
+	return nil
+}
+
+// replayLogFile applies all of the records in the provided metadata log file
+// to the passed memtable treap.  A partially written record at the end of the
+// last log file is the result of an unclean shutdown while the record was being
+// written.  Since the transaction was never considered committed in that case,
+// the file is truncated to remove it.  Any other malformed record is treated as
+// corruption.
+func replayLogFile(t *treap.Mutable, path string, last bool) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if err != nil {
+		str := fmt.Sprintf("failed to open metadata log %q: %v", path,
+			err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		str := fmt.Sprintf("failed to stat metadata log %q: %v", path,
+			err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	fileSize := fi.Size()
+
+	var offset int64
+	var header [logRecordHeaderSize]byte
+	reader := bufio.NewReader(file)
+	for {
+		// Stop once the end of the log is cleanly reached.
+		n, err := io.ReadFull(reader, header[:])
+		if n == 0 && err == io.EOF {
+			return nil
+		}
+
+		var payload []byte
+		torn := err != nil
+		payloadLen := int64(byteOrder.Uint32(header[0:4]))
+		if !torn && offset+logRecordHeaderSize+payloadLen > fileSize {
+			torn = true
+		}
+		if !torn {
+			payload = make([]byte, payloadLen)
+			_, err = io.ReadFull(reader, payload)
+			torn = err != nil || crc32.Checksum(payload, castagnoli) !=
+				byteOrder.Uint32(header[4:8])
+		}
+		if torn {
+			if !last {
+				str := fmt.Sprintf("metadata log %q has a malformed "+
+					"record at offset %d", path, offset)
+				return makeDbErr(database.ErrCorruption, str, nil)
+			}
+
+			log.Info("Detected unclean shutdown - Repairing...")
+			log.Debugf("Truncating metadata log %q to offset %d",
+				path, offset)
+			if err := file.Truncate(offset); err != nil {
+				str := fmt.Sprintf("failed to truncate metadata "+
+					"log %q: %v", path, err)
+				return makeDbErr(database.ErrDriverSpecific, str, err)
+			}
+			if err := file.Sync(); err != nil {
+				str := fmt.Sprintf("failed to sync metadata log "+
+					"%q: %v", path, err)
+				return makeDbErr(database.ErrDriverSpecific, str, err)
+			}
+			log.Infof("Database sync complete")
+			return nil
+		}
+
+		if err := applyLogPayload(t, payload); err != nil {
+			str := fmt.Sprintf("metadata log %q has an invalid record "+
+				"at offset %d: %v", path, offset, err)
+			return makeDbErr(database.ErrCorruption, str, nil)
+		}
+		offset += int64(logRecordHeaderSize + len(payload))
+	}
+}
+
+// logWriter appends records to a metadata log file.
+type logWriter struct {
+	file    *os.File
+	fileNum uint64
+	size    int64
+}
+
+// createLogWriter creates a new metadata log file with the provided file number
+// and returns a writer for it.
+func createLogWriter(metaPath string, fileNum uint64) (*logWriter, error) {
+	path := logFilePath(metaPath, fileNum)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		str := fmt.Sprintf("failed to create metadata log %q: %v", path,
+			err)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	syncDir(metaPath)
+	return &logWriter{file: file, fileNum: fileNum}, nil
+}
+
+// writeRecord appends a record with the passed pending keys and removals to
+// the log file and syncs it to disk.  The log file is truncated back to its
+// previous size when the write fails, so a failed record is never replayed.
+//
+// This function MUST only be called while the database write lock is held.
+func (w *logWriter) writeRecord(pendingKeys, pendingRemove *treap.Mutable) error {
+	record := serializeLogRecord(pendingKeys, pendingRemove)
+	_, err := w.file.WriteAt(record, w.size)
+	if err == nil {
+		err = w.file.Sync()
+	}
+	if err != nil {
+		if tErr := w.file.Truncate(w.size); tErr != nil {
+			log.Warnf("Failed to truncate metadata log %d to offset "+
+				"%d after failed write: %v", w.fileNum, w.size,
+				tErr)
+		}
+		str := fmt.Sprintf("failed to write metadata log %d: %v",
+			w.fileNum, err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	w.size += int64(len(record))
+	return nil
+}
+
+// close closes the log file.
+func (w *logWriter) close() error {
+	if err := w.file.Close(); err != nil {
+		str := fmt.Sprintf("failed to close metadata log %d: %v",
+			w.fileNum, err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	return nil
+}
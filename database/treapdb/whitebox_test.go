@@ -0,0 +1,452 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is part of the treapdb package rather than the treapdb_test
+// package as it provides whitebox testing.
+
+package treapdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/goleveldb/leveldb/util"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/database/internal/treap"
+)
+
+// testStoreConfig is a configuration with tiny limits so the tests exercise
+// flushes and compactions with only a small amount of metadata.
+var testStoreConfig = storeConfig{
+	memtableSize:       1024,
+	maxFrozenMemtables: 2,
+	compactionTables:   2,
+	tableBlockSize:     128,
+}
+
+// noSyncBlocks is the block sync function used by the metadata store tests.
+func noSyncBlocks() error {
+	return nil
+}
+
+// openTestStore opens a metadata store in a new directory below the passed
+// temporary directory.
+func openTestStore(t *testing.T, dir string, create bool) *metaStore {
+	t.Helper()
+	store, err := openMetaStore(filepath.Join(dir, metadataDirName), create,
+		noSyncBlocks, testStoreConfig)
+	if err != nil {
+		t.Fatalf("openMetaStore: unexpected error: %v", err)
+	}
+	return store
+}
+
+// waitForFlush waits until all of the frozen memtables of the passed store have
+// been flushed to table files.
+func waitForFlush(t *testing.T, s *metaStore) {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		s.mtx.Lock()
+		numFrozen, bgErr := len(s.frozen), s.bgErr
+		s.mtx.Unlock()
+		if bgErr != nil {
+			t.Fatalf("background flush failed: %v", bgErr)
+		}
+		if numFrozen == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timeout waiting for memtables to be flushed")
+}
+
+// isErrorCode returns whether or not the passed error is a database.Error with
+// the passed error code.
+func isErrorCode(err error, code database.ErrorCode) bool {
+	dbErr, ok := err.(database.Error)
+	return ok && dbErr.ErrorCode == code
+}
+
+// testModel houses the expected contents of a metadata store.
+type testModel map[string][]byte
+
+// clone returns a copy of the model.
+func (m testModel) clone() testModel {
+	c := make(testModel, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// sortedKeys returns the keys of the model in ascending order limited to the
+// passed range.
+func (m testModel) sortedKeys(slice *util.Range) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if slice != nil && slice.Start != nil && k < string(slice.Start) {
+			continue
+		}
+		if slice != nil && slice.Limit != nil && k >= string(slice.Limit) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// testKey returns the test key for the passed number.
+func testKey(i int) []byte {
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], uint32(i))
+	return key[:]
+}
+
+// commitRandom commits a transaction of random puts and deletes of the keys
+// below the passed maximum to the store and applies them to the model.
+func commitRandom(s *metaStore, m testModel, rng *rand.Rand, maxKey int) error {
+	pendingKeys := treap.NewMutable()
+	pendingRemove := treap.NewMutable()
+	for i := 0; i < 10; i++ {
+		key := testKey(rng.Intn(maxKey))
+		if rng.Intn(3) == 0 {
+			pendingKeys.Delete(key)
+			pendingRemove.Put(key, nil)
+			delete(m, string(key))
+			continue
+		}
+		value := make([]byte, rng.Intn(32)+1)
+		rng.Read(value)
+		pendingRemove.Delete(key)
+		pendingKeys.Put(key, value)
+		m[string(key)] = value
+	}
+	return s.Commit(pendingKeys, pendingRemove)
+}
+
+// checkIterRange ensures iterating the passed range of the snapshot forwards
+// and backwards produces exactly the expected keys and values.
+func checkIterRange(snap *metaSnapshot, m testModel, slice *util.Range) error {
+	keys := m.sortedKeys(slice)
+	iter := snap.NewIterator(slice)
+	defer iter.Release()
+
+	i := 0
+	for ok := iter.First(); ok; ok = iter.Next() {
+		if i >= len(keys) {
+			return fmt.Errorf("unexpected extra key %x", iter.Key())
+		}
+		if !bytes.Equal(iter.Key(), []byte(keys[i])) {
+			return fmt.Errorf("forward key %d is %x, want %x", i,
+				iter.Key(), keys[i])
+		}
+		if !bytes.Equal(iter.Value(), m[keys[i]]) {
+			return fmt.Errorf("value for key %x is %x, want %x",
+				iter.Key(), iter.Value(), m[keys[i]])
+		}
+		i++
+	}
+	if i != len(keys) {
+		return fmt.Errorf("forward iteration returned %d keys, want %d",
+			i, len(keys))
+	}
+
+	i = len(keys) - 1
+	for ok := iter.Last(); ok; ok = iter.Prev() {
+		if i < 0 || !bytes.Equal(iter.Key(), []byte(keys[i])) {
+			return fmt.Errorf("unexpected backward key %x", iter.Key())
+		}
+		i--
+	}
+	if i != -1 {
+		return fmt.Errorf("backward iteration missed %d keys", i+1)
+	}
+	return iter.Error()
+}
+
+// checkSnapshot ensures the passed snapshot contains exactly the contents of
+// the passed model.
+func checkSnapshot(snap *metaSnapshot, m testModel, maxKey int) error {
+	for i := 0; i < maxKey; i++ {
+		key := testKey(i)
+		want, exists := m[string(key)]
+		if snap.Has(key) != exists {
+			return fmt.Errorf("Has(%x) = %v, want %v", key, !exists,
+				exists)
+		}
+		if got := snap.Get(key); !bytes.Equal(got, want) {
+			return fmt.Errorf("Get(%x) = %x, want %x", key, got, want)
+		}
+	}
+
+	ranges := []*util.Range{
+		nil,
+		{Start: testKey(maxKey / 4)},
+		{Limit: testKey(maxKey / 2)},
+		{Start: testKey(maxKey / 3), Limit: testKey(maxKey * 2 / 3)},
+	}
+	for _, slice := range ranges {
+		if err := checkIterRange(snap, m, slice); err != nil {
+			return err
+		}
+	}
+
+	// Ensure seeking followed by changing direction produces the keys
+	// around the seek key.
+	keys := m.sortedKeys(nil)
+	iter := snap.NewIterator(nil)
+	defer iter.Release()
+	seekKey := testKey(maxKey / 2)
+	idx := sort.SearchStrings(keys, string(seekKey))
+	if iter.Seek(seekKey) != (idx < len(keys)) {
+		return fmt.Errorf("unexpected result seeking to %x", seekKey)
+	}
+	if idx < len(keys) {
+		if !bytes.Equal(iter.Key(), []byte(keys[idx])) {
+			return fmt.Errorf("seek to %x positioned at %x, want %x",
+				seekKey, iter.Key(), keys[idx])
+		}
+		if iter.Prev() != (idx > 0) {
+			return fmt.Errorf("unexpected result moving back from %x",
+				keys[idx])
+		}
+		if idx > 0 && !bytes.Equal(iter.Key(), []byte(keys[idx-1])) {
+			return fmt.Errorf("prev after seek positioned at %x, "+
+				"want %x", iter.Key(), keys[idx-1])
+		}
+		if idx > 0 && (!iter.Next() ||
+			!bytes.Equal(iter.Key(), []byte(keys[idx]))) {
+
+			return fmt.Errorf("next after prev positioned at %x, "+
+				"want %x", iter.Key(), keys[idx])
+		}
+	}
+	return iter.Error()
+}
+
+// takeSnapshot returns a snapshot of the passed store.
+func takeSnapshot(t *testing.T, s *metaStore) *metaSnapshot {
+	t.Helper()
+	snap, err := s.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: unexpected error: %v", err)
+	}
+	return snap.(*metaSnapshot)
+}
+
+// TestMetaStore ensures the metadata store returns the committed metadata as
+// it is flushed to table files, compacted, and reloaded after reopening it, and
+// that snapshots are unaffected by later commits and compactions.
+func TestMetaStore(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "treapdb-metastore")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const maxKey = 500
+	rng := rand.New(rand.NewSource(1))
+	model := make(testModel)
+	s := openTestStore(t, dir, true)
+
+	// Hold a snapshot across the commits below which cause numerous
+	// flushes and compactions.
+	for i := 0; i < 50; i++ {
+		if err := commitRandom(s, model, rng, maxKey); err != nil {
+			t.Fatalf("Commit: unexpected error: %v", err)
+		}
+	}
+	oldSnap := takeSnapshot(t, s)
+	oldModel := model.clone()
+	for i := 0; i < 400; i++ {
+		if err := commitRandom(s, model, rng, maxKey); err != nil {
+			t.Fatalf("Commit: unexpected error: %v", err)
+		}
+		if i%100 == 0 {
+			snap := takeSnapshot(t, s)
+			err := checkSnapshot(snap, model, maxKey)
+			snap.Release()
+			if err != nil {
+				t.Fatalf("commit %d: %v", i, err)
+			}
+		}
+	}
+	waitForFlush(t, s)
+
+	s.mtx.Lock()
+	numTables := len(s.current.tables)
+	s.mtx.Unlock()
+	if numTables == 0 {
+		t.Fatal("no memtables were flushed to table files")
+	}
+	if err := checkSnapshot(oldSnap, oldModel, maxKey); err != nil {
+		t.Fatalf("old snapshot: %v", err)
+	}
+	oldSnap.Release()
+
+	snap := takeSnapshot(t, s)
+	if err := checkSnapshot(snap, model, maxKey); err != nil {
+		t.Fatalf("after flush: %v", err)
+	}
+	snap.Release()
+
+	// Ensure the store contains the same metadata after reopening it,
+	// including the changes which were only in the log files.
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+	s = openTestStore(t, dir, false)
+	defer s.Close()
+	snap = takeSnapshot(t, s)
+	defer snap.Release()
+	if err := checkSnapshot(snap, model, maxKey); err != nil {
+		t.Fatalf("after reopen: %v", err)
+	}
+
+	// Ensure the store is not allowed to be created again.
+	_, err = openMetaStore(filepath.Join(dir, metadataDirName), true,
+		noSyncBlocks, testStoreConfig)
+	if !isErrorCode(err, database.ErrDbExists) {
+		t.Fatalf("create existing store: unexpected error: %v", err)
+	}
+}
+
+// TestTornLogRecovery ensures a partially written record at the end of the log
+// file is removed when the store is opened while the committed metadata before
+// it is retained.
+func TestTornLogRecovery(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "treapdb-tornlog")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const maxKey = 20
+	rng := rand.New(rand.NewSource(2))
+	model := make(testModel)
+	s := openTestStore(t, dir, true)
+	for i := 0; i < 5; i++ {
+		if err := commitRandom(s, model, rng, maxKey); err != nil {
+			t.Fatalf("Commit: unexpected error: %v", err)
+		}
+	}
+	logPath := logFilePath(s.path, s.log.fileNum)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+
+	// Simulate an unclean shutdown while a record was being written by
+	// appending a record header which claims a larger payload than the
+	// file contains.
+	file, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("OpenFile: unexpected error: %v", err)
+	}
+	var header [logRecordHeaderSize + 3]byte
+	byteOrder.PutUint32(header[0:4], 100)
+	if _, err := file.Write(header[:]); err != nil {
+		t.Fatalf("Write: unexpected error: %v", err)
+	}
+	file.Close()
+
+	s = openTestStore(t, dir, false)
+	defer s.Close()
+	snap := takeSnapshot(t, s)
+	defer snap.Release()
+	if err := checkSnapshot(snap, model, maxKey); err != nil {
+		t.Fatalf("after recovery: %v", err)
+	}
+	if err := replayLogFile(treap.NewMutable(), logPath, false); err != nil {
+		t.Fatalf("log was not truncated: %v", err)
+	}
+}
+
+// TestCorruption ensures corruption of the manifest and table files is
+// detected.
+func TestCorruption(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "treapdb-corruption")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Commit enough metadata for at least one table file to be written.
+	const maxKey = 200
+	rng := rand.New(rand.NewSource(3))
+	model := make(testModel)
+	s := openTestStore(t, dir, true)
+	for i := 0; i < 40; i++ {
+		if err := commitRandom(s, model, rng, maxKey); err != nil {
+			t.Fatalf("Commit: unexpected error: %v", err)
+		}
+	}
+	waitForFlush(t, s)
+	s.mtx.Lock()
+	if len(s.current.tables) == 0 {
+		s.mtx.Unlock()
+		t.Fatal("no memtables were flushed to table files")
+	}
+	tablePath := s.current.tables[0].path
+	s.mtx.Unlock()
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+
+	// flipByte inverts the byte at the passed offset of the passed file.
+	flipByte := func(path string, offset int64) {
+		file, err := os.OpenFile(path, os.O_RDWR, 0600)
+		if err != nil {
+			t.Fatalf("OpenFile: unexpected error: %v", err)
+		}
+		defer file.Close()
+		var b [1]byte
+		if _, err := file.ReadAt(b[:], offset); err != nil {
+			t.Fatalf("ReadAt: unexpected error: %v", err)
+		}
+		b[0] = ^b[0]
+		if _, err := file.WriteAt(b[:], offset); err != nil {
+			t.Fatalf("WriteAt: unexpected error: %v", err)
+		}
+	}
+
+	// Ensure a corrupt manifest prevents the store from being opened.
+	metaPath := filepath.Join(dir, metadataDirName)
+	manifestPath := filepath.Join(metaPath, manifestName)
+	flipByte(manifestPath, 14)
+	_, err = openMetaStore(metaPath, false, noSyncBlocks, testStoreConfig)
+	if !isErrorCode(err, database.ErrCorruption) {
+		t.Fatalf("corrupt manifest: unexpected error: %v", err)
+	}
+	flipByte(manifestPath, 14)
+
+	// Ensure a corrupt data block is reported by iterators.
+	flipByte(tablePath, 2)
+	s = openTestStore(t, dir, false)
+	defer s.Close()
+	snap := takeSnapshot(t, s)
+	defer snap.Release()
+	iter := snap.NewIterator(nil)
+	defer iter.Release()
+	for iter.First(); iter.Valid(); iter.Next() {
+	}
+	if !isErrorCode(iter.Error(), database.ErrCorruption) {
+		t.Fatalf("corrupt table: unexpected iterator error: %v",
+			iter.Error())
+	}
+}
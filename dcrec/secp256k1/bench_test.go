@@ -6,7 +6,10 @@
 
 package secp256k1
 
-import "testing"
+import (
+	"math/big"
+	"testing"
+)
 
 // BenchmarkAddJacobian benchmarks the secp256k1 curve addJacobian function with
 // Z values of 1 so that the associated optimizations are used.
@@ -76,6 +79,25 @@ func BenchmarkScalarMult(b *testing.B) {
 	}
 }
 
+// BenchmarkMultiScalarMult benchmarks the secp256k1 curve MultiScalarMult
+// function with 256 points.
+func BenchmarkMultiScalarMult(b *testing.B) {
+	curve := S256()
+	k := fromHex("d74bf844b0862475103d96a611cf2d898447e288d34b360bc885cb8ce7c00575")
+	xs := make([]*big.Int, 256)
+	ys := make([]*big.Int, 256)
+	ks := make([][]byte, 256)
+	for i := range xs {
+		xs[i], ys[i] = curve.ScalarBaseMult([]byte{byte(i), 0x01})
+		ks[i] = k.Bytes()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		curve.MultiScalarMult(xs, ys, ks)
+	}
+}
+
 // BenchmarkNAF benchmarks the NAF function.
 func BenchmarkNAF(b *testing.B) {
 	k := fromHex("d74bf844b0862475103d96a611cf2d898447e288d34b360bc885cb8ce7c00575")
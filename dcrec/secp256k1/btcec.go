@@ -883,6 +883,125 @@ func (curve *KoblitzCurve) ScalarBaseMult(k []byte) (*big.Int, *big.Int) {
 	return curve.fieldJacobianToBigAffine(qx, qy, qz)
 }
 
+// msmWindowBits returns the window size in bits to use for the bucket method
+// of MultiScalarMult given the number of points.  The number of Jacobian
+// additions of the method is roughly (256 / c) * (n + 2^(c+1)) for a window
+// size of c bits and n points, which is minimized by choosing c close to
+// log2(n) - 2.
+func msmWindowBits(numPoints int) uint {
+	c := uint(0)
+	for n := numPoints; n > 1; n >>= 1 {
+		c++
+	}
+	switch {
+	case c < 4:
+		return 2
+	case c > 14:
+		return 12
+	}
+	return c - 2
+}
+
+// msmWindowDigit returns the width bits of the 32-byte big endian scalar k
+// starting at bit position bit, where bit 0 is the least significant bit.
+func msmWindowDigit(k *[32]byte, bit, width uint) int {
+	var digit int
+	for i := uint(0); i < width && bit+i < 256; i++ {
+		pos := bit + i
+		if k[31-pos/8]>>(pos%8)&0x01 == 0x01 {
+			digit |= 1 << i
+		}
+	}
+	return digit
+}
+
+// MultiScalarMult returns the sum of ks[i]*(xs[i], ys[i]) for all of the passed
+// points, where each k is a big endian integer.  The point at infinity is
+// returned as (0, 0).
+//
+// This is considerably faster than summing the results of ScalarMult for each
+// point when there are many points since it uses the bucket method described
+// by Pippenger, which shares the point doublings between all of the points
+// and replaces most of the additions with cheaper additions of affine points.
+// It is mainly useful for batch verification of signatures.
+//
+// All of the points must be on the curve and the slices must have the same
+// length.
+func (curve *KoblitzCurve) MultiScalarMult(xs, ys []*big.Int, ks [][]byte) (*big.Int, *big.Int) {
+	// Convert the points to field values and the scalars to 32-byte big
+	// endian integers reduced modulo the group order.
+	numPoints := len(xs)
+	px := make([]fieldVal, numPoints)
+	py := make([]fieldVal, numPoints)
+	scalars := make([][32]byte, numPoints)
+	for i := 0; i < numPoints; i++ {
+		px[i].SetByteSlice(xs[i].Bytes())
+		py[i].SetByteSlice(ys[i].Bytes())
+		k := curve.moduloReduce(ks[i])
+		copy(scalars[i][32-len(k):], k)
+	}
+	pz := new(fieldVal).SetInt(1)
+
+	// Point Q = ∞ (point at infinity).
+	qx, qy, qz := new(fieldVal), new(fieldVal), new(fieldVal)
+
+	// Process the scalars in windows of c bits starting with the most
+	// significant window.  Each window adds the points to the bucket
+	// selected by their digit in the window, so the bucket with index d
+	// holds the sum of all of the points with digit d.  The sum of
+	// d * bucket[d] for all of the buckets is then calculated with only
+	// two additions per bucket by keeping a running sum of the buckets from
+	// the highest digit down.
+	c := msmWindowBits(numPoints)
+	numBuckets := 1 << c
+	bx := make([]fieldVal, numBuckets)
+	by := make([]fieldVal, numBuckets)
+	bz := make([]fieldVal, numBuckets)
+	var sx, sy, sz, wx, wy, wz fieldVal
+	numWindows := (256 + c - 1) / c
+	for w := int(numWindows) - 1; w >= 0; w-- {
+		// Q = 2^c * Q
+		for i := uint(0); i < c; i++ {
+			curve.doubleJacobian(qx, qy, qz, qx, qy, qz)
+		}
+
+		// Reset the buckets to the point at infinity and add each point
+		// to the bucket of its digit in the window.
+		for d := 1; d < numBuckets; d++ {
+			bx[d].Zero()
+			by[d].Zero()
+			bz[d].Zero()
+		}
+		for i := range scalars {
+			d := msmWindowDigit(&scalars[i], uint(w)*c, c)
+			if d == 0 {
+				continue
+			}
+			curve.addJacobian(&bx[d], &by[d], &bz[d], &px[i], &py[i], pz,
+				&bx[d], &by[d], &bz[d])
+		}
+
+		// W = sum(d * bucket[d])
+		sx.Zero()
+		sy.Zero()
+		sz.Zero()
+		wx.Zero()
+		wy.Zero()
+		wz.Zero()
+		for d := numBuckets - 1; d > 0; d-- {
+			curve.addJacobian(&sx, &sy, &sz, &bx[d], &by[d], &bz[d],
+				&sx, &sy, &sz)
+			curve.addJacobian(&wx, &wy, &wz, &sx, &sy, &sz, &wx, &wy, &wz)
+		}
+
+		// Q = Q + W
+		curve.addJacobian(qx, qy, qz, &wx, &wy, &wz, qx, qy, qz)
+	}
+
+	// Convert the Jacobian coordinate field values back to affine big.Ints.
+	return curve.fieldJacobianToBigAffine(qx, qy, qz)
+}
+
 // QPlus1Div4 returns the Q+1/4 constant for the curve for use in calculating
 // square roots via exponention.
 func (curve *KoblitzCurve) QPlus1Div4() *big.Int {
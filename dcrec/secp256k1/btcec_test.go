@@ -667,6 +667,61 @@ func TestScalarMultRand(t *testing.T) {
 	}
 }
 
+// TestMultiScalarMult ensures MultiScalarMult returns the same point as
+// summing the results of ScalarMult for various numbers of points, including
+// repeated and cancelling points.
+func TestMultiScalarMult(t *testing.T) {
+	s256 := S256()
+	for _, numPoints := range []int{0, 1, 2, 7, 16, 100, 300} {
+		xs := make([]*big.Int, 0, numPoints)
+		ys := make([]*big.Int, 0, numPoints)
+		ks := make([][]byte, 0, numPoints)
+		wantX, wantY := new(big.Int), new(big.Int)
+		for i := 0; i < numPoints; i++ {
+			data := make([]byte, 32)
+			if _, err := rand.Read(data); err != nil {
+				t.Fatalf("failed to read random data: %v", err)
+			}
+			x, y := s256.ScalarBaseMult(data)
+			if i%5 == 4 {
+				// Repeat the previous point.
+				x, y = xs[i-1], ys[i-1]
+			}
+			k := data
+			if i%7 == 6 {
+				// Use a scalar larger than the group order.
+				k = append([]byte{0xff}, data...)
+			}
+			xs = append(xs, x)
+			ys = append(ys, y)
+			ks = append(ks, k)
+			kx, ky := s256.ScalarMult(x, y, k)
+			wantX, wantY = s256.Add(wantX, wantY, kx, ky)
+		}
+
+		gotX, gotY := s256.MultiScalarMult(xs, ys, ks)
+		if gotX.Cmp(wantX) != 0 || gotY.Cmp(wantY) != 0 {
+			t.Fatalf("%d points: bad output: got (%X, %X), want (%X, %X)",
+				numPoints, gotX, gotY, wantX, wantY)
+		}
+		if numPoints == 0 {
+			continue
+		}
+
+		// Ensure adding the negation of the sum results in the point at
+		// infinity.
+		negY := new(big.Int).Sub(s256.P, wantY)
+		xs = append(xs, wantX)
+		ys = append(ys, negY)
+		ks = append(ks, []byte{0x01})
+		gotX, gotY = s256.MultiScalarMult(xs, ys, ks)
+		if gotX.Sign() != 0 || gotY.Sign() != 0 {
+			t.Fatalf("%d points: expected point at infinity, got "+
+				"(%X, %X)", numPoints, gotX, gotY)
+		}
+	}
+}
+
 func TestSplitK(t *testing.T) {
 	tests := []struct {
 		k      string
@@ -0,0 +1,223 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package schnorr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/dcrec/secp256k1"
+)
+
+// batchCoefficientSize is the size of the random coefficients used to combine
+// the signatures of a batch.  128 bits of randomness make the chance of an
+// invalid batch passing verification negligible.
+const batchCoefficientSize = 16
+
+// minBatchSize is the minimum number of signatures for which batch
+// verification is faster than verifying each signature individually.  Smaller
+// batches are verified individually.
+const minBatchSize = 8
+
+// batchCoefficients returns a random coefficient for each of the passed
+// signatures.  The coefficients are derived from the hash of all of the
+// signatures, public keys, and messages, so they can't be predicted without
+// fixing the whole batch first.
+func batchCoefficients(sigs [][]byte, pubkeys []*secp256k1.PublicKey,
+	msgs [][]byte, hashFunc func([]byte) []byte) []*big.Int {
+
+	seedData := make([]byte, 0, len(sigs)*(SignatureSize+PubKeyBytesLen+
+		scalarSize))
+	for i := range sigs {
+		seedData = append(seedData, sigs[i]...)
+		seedData = append(seedData, pubkeys[i].SerializeCompressed()...)
+		seedData = append(seedData, msgs[i]...)
+	}
+	seed := hashFunc(seedData)
+
+	coefficients := make([]*big.Int, len(sigs))
+	toHash := make([]byte, len(seed)+4)
+	copy(toHash, seed)
+	for i := range coefficients {
+		binary.LittleEndian.PutUint32(toHash[len(seed):], uint32(i))
+		h := hashFunc(toHash)
+		a := new(big.Int).SetBytes(h[:batchCoefficientSize])
+		if a.Sign() == 0 {
+			a.SetInt64(1)
+		}
+		coefficients[i] = a
+	}
+	return coefficients
+}
+
+// schnorrBatchVerify is the internal function for verification of a batch of
+// secp256k1 Schnorr signatures.  A secure hash function may be passed for the
+// calculation of r.
+//
+// Each signature satisfies sG + hQ = R, where R is the point with the x
+// coordinate r and an even y coordinate, when it is valid.  Instead of
+// checking the equations individually, they are multiplied by random
+// coefficients a and summed up, so the whole batch is checked with a single
+// multi-scalar multiplication:
+//
+//	sum(a*s)G + sum(a*h*Q) - sum(a*R) = 0
+//
+// The batch passes verification if and only if all of the signatures are
+// valid, except with negligible probability.  The function does not identify
+// the invalid signatures of a failed batch.
+func schnorrBatchVerify(curve *secp256k1.KoblitzCurve, sigs [][]byte,
+	pubkeys []*secp256k1.PublicKey, msgs [][]byte,
+	hashFunc func([]byte) []byte) (bool, error) {
+
+	if len(pubkeys) != len(sigs) || len(msgs) != len(sigs) {
+		str := fmt.Sprintf("mismatched batch sizes (%v signatures, %v "+
+			"pubkeys, %v messages)", len(sigs), len(pubkeys), len(msgs))
+		return false, schnorrError(ErrBadInputSize, str)
+	}
+
+	// Verify small batches individually since it is faster.
+	if len(sigs) < minBatchSize {
+		for i := range sigs {
+			_, err := schnorrVerify(curve, sigs[i], pubkeys[i], msgs[i],
+				hashFunc)
+			if err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	// Check the inputs with the same restrictions as individual
+	// verification and decompress the R points with the even y
+	// coordinate.
+	hashes := make([]*big.Int, len(sigs))
+	sValues := make([]*big.Int, len(sigs))
+	rPoints := make([]*secp256k1.PublicKey, len(sigs))
+	for i := range sigs {
+		sig, pubkey, msg := sigs[i], pubkeys[i], msgs[i]
+		if len(msg) != scalarSize {
+			str := fmt.Sprintf("wrong size for message (got %v, want %v)",
+				len(msg), scalarSize)
+			return false, schnorrError(ErrBadInputSize, str)
+		}
+		if len(sig) != SignatureSize {
+			str := fmt.Sprintf("wrong size for signature (got %v, want %v)",
+				len(sig), SignatureSize)
+			return false, schnorrError(ErrBadInputSize, str)
+		}
+		if pubkey == nil {
+			str := fmt.Sprintf("nil pubkey")
+			return false, schnorrError(ErrInputValue, str)
+		}
+		if !curve.IsOnCurve(pubkey.GetX(), pubkey.GetY()) {
+			str := fmt.Sprintf("pubkey point is not on curve")
+			return false, schnorrError(ErrPointNotOnCurve, str)
+		}
+
+		sigR := sig[:32]
+		sigS := sig[32:]
+		sigRCopy := make([]byte, scalarSize, scalarSize)
+		copy(sigRCopy, sigR)
+		toHash := append(sigRCopy, msg...)
+		h := hashFunc(toHash)
+		hBig := new(big.Int).SetBytes(h)
+		if hBig.Cmp(curve.N) >= 0 {
+			str := fmt.Sprintf("hash of (R || m) too big")
+			return false, schnorrError(ErrSchnorrHashValue, str)
+		}
+		if hBig.Cmp(bigZero) == 0 {
+			str := fmt.Sprintf("hash of (R || m) is zero value")
+			return false, schnorrError(ErrSchnorrHashValue, str)
+		}
+		sBig := EncodedBytesToBigInt(copyBytes(sigS))
+		if sBig.Cmp(curve.N) >= 0 {
+			str := fmt.Sprintf("s value is too big")
+			return false, schnorrError(ErrInputValue, str)
+		}
+
+		// r must be the x coordinate of a point on the curve, so it
+		// can't be the curve prime or larger.
+		rBig := EncodedBytesToBigInt(copyBytes(sigR))
+		if rBig.Cmp(curve.P) >= 0 {
+			str := fmt.Sprintf("given R was greater than curve prime")
+			return false, schnorrError(ErrBadSigRNotOnCurve, str)
+		}
+		compressedPoint := make([]byte, PubKeyBytesLen, PubKeyBytesLen)
+		compressedPoint[0] = pubkeyCompressed
+		copy(compressedPoint[1:], sigR)
+		rPoint, err := secp256k1.ParsePubKey(compressedPoint, curve)
+		if err != nil {
+			str := fmt.Sprintf("bad r point")
+			return false, schnorrError(ErrRegenerateRPoint, str)
+		}
+
+		hashes[i] = hBig
+		sValues[i] = sBig
+		rPoints[i] = rPoint
+	}
+
+	// The points of the multi-scalar multiplication are the public keys
+	// and the R points of all of the signatures followed by the base
+	// point.
+	numPoints := 2*len(sigs) + 1
+	xs := make([]*big.Int, 0, numPoints)
+	ys := make([]*big.Int, 0, numPoints)
+	ks := make([][]byte, 0, numPoints)
+	coefficients := batchCoefficients(sigs, pubkeys, msgs, hashFunc)
+	sSum := new(big.Int)
+	for i, a := range coefficients {
+		// a*h*Q
+		ah := new(big.Int).Mul(a, hashes[i])
+		ah.Mod(ah, curve.N)
+		xs = append(xs, pubkeys[i].GetX())
+		ys = append(ys, pubkeys[i].GetY())
+		ks = append(ks, ah.Bytes())
+
+		// -a*R
+		negA := new(big.Int).Sub(curve.N, a)
+		xs = append(xs, rPoints[i].GetX())
+		ys = append(ys, rPoints[i].GetY())
+		ks = append(ks, negA.Bytes())
+
+		// sum(a*s)
+		sSum.Add(sSum, new(big.Int).Mul(a, sValues[i]))
+	}
+
+	// sum(a*s)G
+	sSum.Mod(sSum, curve.N)
+	xs = append(xs, curve.Gx)
+	ys = append(ys, curve.Gy)
+	ks = append(ks, sSum.Bytes())
+
+	// The sum must be the point at infinity.
+	x, y := curve.MultiScalarMult(xs, ys, ks)
+	if x.Sign() != 0 || y.Sign() != 0 {
+		str := fmt.Sprintf("batch contains an invalid signature")
+		return false, schnorrError(ErrBatchVerify, str)
+	}
+
+	return true, nil
+}
+
+// BatchVerify is the generalized and exported function for the verification
+// of a batch of secp256k1 Schnorr signatures.  It returns true only when all
+// of the signatures are valid for their public key and message, which is
+// considerably faster than verifying each of them with Verify for large
+// batches.  Nothing is learned about which signatures are invalid when it
+// returns false, so callers which need to know have to verify them
+// individually afterwards.  BLAKE256 is used as the hashing function.
+func BatchVerify(curve *secp256k1.KoblitzCurve, pubkeys []*secp256k1.PublicKey,
+	msgs [][]byte, sigs []*Signature) bool {
+
+	serializedSigs := make([][]byte, len(sigs))
+	for i, sig := range sigs {
+		serializedSigs[i] = sig.Serialize()
+	}
+	ok, _ := schnorrBatchVerify(curve, serializedSigs, pubkeys, msgs,
+		chainhash.HashB)
+	return ok
+}
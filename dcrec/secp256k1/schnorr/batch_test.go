@@ -0,0 +1,142 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package schnorr
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/coolsnady/hcd/dcrec/secp256k1"
+)
+
+// splitSigList splits a list of signature verification parameters into the
+// slices accepted by BatchVerify.
+func splitSigList(sigList []*SignatureVerParams) ([]*secp256k1.PublicKey,
+	[][]byte, []*Signature) {
+
+	pubkeys := make([]*secp256k1.PublicKey, len(sigList))
+	msgs := make([][]byte, len(sigList))
+	sigs := make([]*Signature, len(sigList))
+	for i, s := range sigList {
+		pubkeys[i] = s.pubkey
+		msgs[i] = s.msg
+		sigs[i] = s.sig
+	}
+	return pubkeys, msgs, sigs
+}
+
+// TestBatchVerify ensures batches of signatures only pass verification when
+// all of their signatures are valid.
+func TestBatchVerify(t *testing.T) {
+	curve := secp256k1.S256()
+	sigList := randSigList(curve, 64)
+
+	for _, size := range []int{0, 1, minBatchSize - 1, minBatchSize, 64} {
+		pubkeys, msgs, sigs := splitSigList(sigList[:size])
+		if !BatchVerify(curve, pubkeys, msgs, sigs) {
+			t.Fatalf("batch of %d valid signatures failed verification",
+				size)
+		}
+		if size == 0 {
+			continue
+		}
+
+		// Corrupt each of the signature, message, and public key of the
+		// last entry in turn and ensure the batch fails.
+		last := size - 1
+		badS := new(big.Int).Add(sigs[last].S, big.NewInt(1))
+		origSig := sigs[last]
+		sigs[last] = &Signature{origSig.R, badS}
+		if BatchVerify(curve, pubkeys, msgs, sigs) {
+			t.Fatalf("batch of %d with a bad s passed verification", size)
+		}
+		sigs[last] = origSig
+
+		origMsg := msgs[last]
+		badMsg := make([]byte, len(origMsg))
+		copy(badMsg, origMsg)
+		badMsg[0] ^= 0x01
+		msgs[last] = badMsg
+		if BatchVerify(curve, pubkeys, msgs, sigs) {
+			t.Fatalf("batch of %d with a bad message passed verification",
+				size)
+		}
+		msgs[last] = origMsg
+
+		// The generated list contains duplicate keys, so find one
+		// that differs.
+		origPubKey := pubkeys[last]
+		for _, s := range sigList {
+			if !s.pubkey.IsEqual(origPubKey) {
+				pubkeys[last] = s.pubkey
+				break
+			}
+		}
+		if BatchVerify(curve, pubkeys, msgs, sigs) {
+			t.Fatalf("batch of %d with a wrong pubkey passed verification",
+				size)
+		}
+		pubkeys[last] = origPubKey
+	}
+
+	// Ensure two invalid signatures which cancel each other out when summed
+	// without random coefficients are rejected.
+	pubkeys, msgs, sigs := splitSigList(sigList[:minBatchSize])
+	sigs[0] = &Signature{sigs[0].R, new(big.Int).Add(sigs[0].S, big.NewInt(1))}
+	sigs[1] = &Signature{sigs[1].R, new(big.Int).Sub(sigs[1].S, big.NewInt(1))}
+	if BatchVerify(curve, pubkeys, msgs, sigs) {
+		t.Fatal("batch with offsetting invalid signatures passed verification")
+	}
+
+	// Ensure mismatched input lengths are rejected.
+	pubkeys, msgs, sigs = splitSigList(sigList[:minBatchSize])
+	if BatchVerify(curve, pubkeys[1:], msgs, sigs) {
+		t.Fatal("batch with missing pubkey passed verification")
+	}
+	if BatchVerify(curve, pubkeys, msgs[1:], sigs) {
+		t.Fatal("batch with missing message passed verification")
+	}
+
+	// Ensure a nil pubkey is rejected rather than causing a panic.
+	pubkeys[3] = nil
+	if BatchVerify(curve, pubkeys, msgs, sigs) {
+		t.Fatal("batch with nil pubkey passed verification")
+	}
+}
+
+func benchmarkBatchVerification(b *testing.B, numSigs int) {
+	curve := secp256k1.S256()
+	pubkeys, msgs, sigs := splitSigList(randSigList(curve, numSigs))
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if !BatchVerify(curve, pubkeys, msgs, sigs) {
+			panic("made invalid sig")
+		}
+	}
+}
+
+func benchmarkIndividualVerification(b *testing.B, numSigs int) {
+	curve := secp256k1.S256()
+	sigList := randSigList(curve, numSigs)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, s := range sigList {
+			if !Verify(curve, s.pubkey, s.msg, s.sig.R, s.sig.S) {
+				panic("made invalid sig")
+			}
+		}
+	}
+}
+
+func BenchmarkBatchVerification64(b *testing.B)  { benchmarkBatchVerification(b, 64) }
+func BenchmarkBatchVerification256(b *testing.B) { benchmarkBatchVerification(b, 256) }
+func BenchmarkIndividualVerification64(b *testing.B) {
+	benchmarkIndividualVerification(b, 64)
+}
+func BenchmarkIndividualVerification256(b *testing.B) {
+	benchmarkIndividualVerification(b, 256)
+}
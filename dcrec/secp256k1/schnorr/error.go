@@ -62,6 +62,10 @@ const (
 	// ErrNonmatchingR indicates that all signatures to be combined in a
 	// threshold signature failed to have a matching R value.
 	ErrNonmatchingR
+
+	// ErrBatchVerify indicates that a batch of signatures failed
+	// verification because at least one of them is invalid.
+	ErrBatchVerify
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -78,6 +82,7 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrBadNonce:          "ErrBadNonce",
 	ErrZeroSigS:          "ErrZeroSigS",
 	ErrNonmatchingR:      "ErrNonmatchingR",
+	ErrBatchVerify:       "ErrBatchVerify",
 }
 
 // String returns the ErrorCode as a human-readable name.
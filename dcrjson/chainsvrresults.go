@@ -213,6 +213,9 @@ type GetPeerInfoResult struct {
 	CurrentHeight  int64   `json:"currentheight,omitempty"`
 	BanScore       int32   `json:"banscore"`
 	SyncNode       bool    `json:"syncnode"`
+
+	BytesSentPerMsg map[string]uint64 `json:"bytessentpermsg"`
+	BytesRecvPerMsg map[string]uint64 `json:"bytesrecvpermsg"`
 }
 
 // GetRawMempoolVerboseResult models the data returned from the getrawmempool
@@ -249,11 +252,23 @@ type GetTxOutResult struct {
 	Coinbase      bool               `json:"coinbase"`
 }
 
+// UploadTargetResult models the uploadtarget field of the getnettotals
+// command.
+type UploadTargetResult struct {
+	TimeFrame             int64  `json:"timeframe"`
+	Target                uint64 `json:"target"`
+	TargetReached         bool   `json:"targetreached"`
+	ServeHistoricalBlocks bool   `json:"servehistoricalblocks"`
+	BytesLeftInCycle      uint64 `json:"bytesleftincycle"`
+	TimeLeftInCycle       int64  `json:"timeleftincycle"`
+}
+
 // GetNetTotalsResult models the data returned from the getnettotals command.
 type GetNetTotalsResult struct {
-	TotalBytesRecv uint64 `json:"totalbytesrecv"`
-	TotalBytesSent uint64 `json:"totalbytessent"`
-	TimeMillis     int64  `json:"timemillis"`
+	TotalBytesRecv uint64             `json:"totalbytesrecv"`
+	TotalBytesSent uint64             `json:"totalbytessent"`
+	TimeMillis     int64              `json:"timemillis"`
+	UploadTarget   UploadTargetResult `json:"uploadtarget"`
 }
 
 // ScriptSig models a signature script.  It is defined separately since it only
@@ -22,6 +22,15 @@ const (
 	// block chain is in the process of a reorganization.
 	ReorganizationNtfnMethod = "reorganization"
 
+	// BlockInvalidatedNtfnMethod is the method used for notifications from
+	// the chain server that a block was manually marked invalid.
+	BlockInvalidatedNtfnMethod = "blockinvalidated"
+
+	// BlockReconsideredNtfnMethod is the method used for notifications from
+	// the chain server that the invalid mark of a block was manually
+	// removed.
+	BlockReconsideredNtfnMethod = "blockreconsidered"
+
 	// TxAcceptedNtfnMethod is the method used for notifications from the
 	// chain server that a transaction has been accepted into the mempool.
 	TxAcceptedNtfnMethod = "txaccepted"
@@ -86,6 +95,36 @@ func NewReorganizationNtfn(oldHash string, oldHeight int32, newHash string,
 	}
 }
 
+// BlockInvalidatedNtfn defines the blockinvalidated JSON-RPC notification.
+type BlockInvalidatedNtfn struct {
+	Hash   string `json:"hash"`
+	Height int64  `json:"height"`
+}
+
+// NewBlockInvalidatedNtfn returns a new instance which can be used to issue a
+// blockinvalidated JSON-RPC notification.
+func NewBlockInvalidatedNtfn(hash string, height int64) *BlockInvalidatedNtfn {
+	return &BlockInvalidatedNtfn{
+		Hash:   hash,
+		Height: height,
+	}
+}
+
+// BlockReconsideredNtfn defines the blockreconsidered JSON-RPC notification.
+type BlockReconsideredNtfn struct {
+	Hash   string `json:"hash"`
+	Height int64  `json:"height"`
+}
+
+// NewBlockReconsideredNtfn returns a new instance which can be used to issue a
+// blockreconsidered JSON-RPC notification.
+func NewBlockReconsideredNtfn(hash string, height int64) *BlockReconsideredNtfn {
+	return &BlockReconsideredNtfn{
+		Hash:   hash,
+		Height: height,
+	}
+}
+
 // TxAcceptedNtfn defines the txaccepted JSON-RPC notification.
 type TxAcceptedNtfn struct {
 	TxID   string  `json:"txid"`
@@ -134,6 +173,8 @@ func init() {
 	MustRegisterCmd(BlockConnectedNtfnMethod, (*BlockConnectedNtfn)(nil), flags)
 	MustRegisterCmd(BlockDisconnectedNtfnMethod, (*BlockDisconnectedNtfn)(nil), flags)
 	MustRegisterCmd(ReorganizationNtfnMethod, (*ReorganizationNtfn)(nil), flags)
+	MustRegisterCmd(BlockInvalidatedNtfnMethod, (*BlockInvalidatedNtfn)(nil), flags)
+	MustRegisterCmd(BlockReconsideredNtfnMethod, (*BlockReconsideredNtfn)(nil), flags)
 	MustRegisterCmd(TxAcceptedNtfnMethod, (*TxAcceptedNtfn)(nil), flags)
 	MustRegisterCmd(TxAcceptedVerboseNtfnMethod, (*TxAcceptedVerboseNtfn)(nil), flags)
 	MustRegisterCmd(RelevantTxAcceptedNtfnMethod, (*RelevantTxAcceptedNtfn)(nil), flags)
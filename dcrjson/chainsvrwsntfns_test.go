@@ -57,6 +57,34 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Header: "header",
 			},
 		},
+		{
+			name: "blockinvalidated",
+			newNtfn: func() (interface{}, error) {
+				return dcrjson.NewCmd("blockinvalidated", "123", 100)
+			},
+			staticNtfn: func() interface{} {
+				return dcrjson.NewBlockInvalidatedNtfn("123", 100)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"blockinvalidated","params":["123",100],"id":null}`,
+			unmarshalled: &dcrjson.BlockInvalidatedNtfn{
+				Hash:   "123",
+				Height: 100,
+			},
+		},
+		{
+			name: "blockreconsidered",
+			newNtfn: func() (interface{}, error) {
+				return dcrjson.NewCmd("blockreconsidered", "123", 100)
+			},
+			staticNtfn: func() interface{} {
+				return dcrjson.NewBlockReconsideredNtfn("123", 100)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"blockreconsidered","params":["123",100],"id":null}`,
+			unmarshalled: &dcrjson.BlockReconsideredNtfn{
+				Hash:   "123",
+				Height: 100,
+			},
+		},
 		{
 			name: "relevanttxaccepted",
 			newNtfn: func() (interface{}, error) {
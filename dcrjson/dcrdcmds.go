@@ -8,6 +8,19 @@
 
 package dcrjson
 
+// BackupChainCmd defines the backupchain JSON-RPC command.
+type BackupChainCmd struct {
+	Destination string
+}
+
+// NewBackupChainCmd returns a new instance which can be used to issue a
+// backupchain JSON-RPC command.
+func NewBackupChainCmd(destination string) *BackupChainCmd {
+	return &BackupChainCmd{
+		Destination: destination,
+	}
+}
+
 // EstimateStakeDiffCmd defines the eststakedifficulty JSON-RPC command.
 type EstimateStakeDiffCmd struct {
 	Tickets *uint32
@@ -111,6 +124,99 @@ func NewExistsMempoolTxsCmd(txHashBlob string) *ExistsMempoolTxsCmd {
 	}
 }
 
+// ForceDeploymentCmd defines the forcedeployment JSON-RPC command.
+type ForceDeploymentCmd struct {
+	AgendaID string
+	State    string
+}
+
+// NewForceDeploymentCmd returns a new instance which can be used to issue a
+// forcedeployment JSON-RPC command.
+func NewForceDeploymentCmd(agendaID, state string) *ForceDeploymentCmd {
+	return &ForceDeploymentCmd{
+		AgendaID: agendaID,
+		State:    state,
+	}
+}
+
+// GetAddressBalanceCmd defines the getaddressbalance JSON-RPC command.
+type GetAddressBalanceCmd struct {
+	Addresses []string
+}
+
+// NewGetAddressBalanceCmd returns a new instance which can be used to issue a
+// getaddressbalance JSON-RPC command.
+func NewGetAddressBalanceCmd(addresses []string) *GetAddressBalanceCmd {
+	return &GetAddressBalanceCmd{
+		Addresses: addresses,
+	}
+}
+
+// GetAddressDeltasCmd defines the getaddressdeltas JSON-RPC command.
+type GetAddressDeltasCmd struct {
+	Address string
+	Skip    *int `jsonrpcdefault:"0"`
+	Count   *int `jsonrpcdefault:"100"`
+}
+
+// NewGetAddressDeltasCmd returns a new instance which can be used to issue a
+// getaddressdeltas JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetAddressDeltasCmd(address string, skip, count *int) *GetAddressDeltasCmd {
+	return &GetAddressDeltasCmd{
+		Address: address,
+		Skip:    skip,
+		Count:   count,
+	}
+}
+
+// GetAddressUtxosCmd defines the getaddressutxos JSON-RPC command.
+type GetAddressUtxosCmd struct {
+	Address string
+	Skip    *int `jsonrpcdefault:"0"`
+	Count   *int `jsonrpcdefault:"100"`
+}
+
+// NewGetAddressUtxosCmd returns a new instance which can be used to issue a
+// getaddressutxos JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetAddressUtxosCmd(address string, skip, count *int) *GetAddressUtxosCmd {
+	return &GetAddressUtxosCmd{
+		Address: address,
+		Skip:    skip,
+		Count:   count,
+	}
+}
+
+// GetBackupInfoCmd defines the getbackupinfo JSON-RPC command.
+type GetBackupInfoCmd struct{}
+
+// NewGetBackupInfoCmd returns a new instance which can be used to issue a
+// getbackupinfo JSON-RPC command.
+func NewGetBackupInfoCmd() *GetBackupInfoCmd {
+	return &GetBackupInfoCmd{}
+}
+
+// GetBlockStatsCmd defines the getblockstats JSON-RPC command.
+type GetBlockStatsCmd struct {
+	HashOrHeight string
+	Stats        *[]string
+}
+
+// NewGetBlockStatsCmd returns a new instance which can be used to issue a
+// getblockstats JSON-RPC command.  The block is identified by either its hash
+// or its height in the main chain.
+func NewGetBlockStatsCmd(hashOrHeight string, stats *[]string) *GetBlockStatsCmd {
+	return &GetBlockStatsCmd{
+		HashOrHeight: hashOrHeight,
+		Stats:        stats,
+	}
+}
+
 // GetCoinSupplyCmd defines the getcoinsupply JSON-RPC command.
 type GetCoinSupplyCmd struct{}
 
@@ -120,6 +226,49 @@ func NewGetCoinSupplyCmd() *GetCoinSupplyCmd {
 	return &GetCoinSupplyCmd{}
 }
 
+// GetIndexInfoCmd defines the getindexinfo JSON-RPC command.
+type GetIndexInfoCmd struct {
+	Index *string
+}
+
+// NewGetIndexInfoCmd returns a new instance which can be used to issue a
+// getindexinfo JSON-RPC command.
+func NewGetIndexInfoCmd(index *string) *GetIndexInfoCmd {
+	return &GetIndexInfoCmd{
+		Index: index,
+	}
+}
+
+// GetNetMsgStatsCmd defines the getnetmsgstats JSON-RPC command.
+type GetNetMsgStatsCmd struct {
+	PeerID *int32
+}
+
+// NewGetNetMsgStatsCmd returns a new instance which can be used to issue a
+// getnetmsgstats JSON-RPC command.
+func NewGetNetMsgStatsCmd(peerID *int32) *GetNetMsgStatsCmd {
+	return &GetNetMsgStatsCmd{
+		PeerID: peerID,
+	}
+}
+
+// GetSpentInfoCmd defines the getspentinfo JSON-RPC command.
+type GetSpentInfoCmd struct {
+	TxID  string
+	Index uint32
+	Tree  *int8
+}
+
+// NewGetSpentInfoCmd returns a new instance which can be used to issue a
+// getspentinfo JSON-RPC command.
+func NewGetSpentInfoCmd(txID string, index uint32, tree *int8) *GetSpentInfoCmd {
+	return &GetSpentInfoCmd{
+		TxID:  txID,
+		Index: index,
+		Tree:  tree,
+	}
+}
+
 // GetStakeDifficultyCmd is a type handling custom marshaling and
 // unmarshaling of getstakedifficulty JSON RPC commands.
 type GetStakeDifficultyCmd struct{}
@@ -160,6 +309,39 @@ func NewGetStakeVersionsCmd(hash string, count int32) *GetStakeVersionsCmd {
 	}
 }
 
+// GetSubsidyScheduleCmd defines the getsubsidyschedule JSON-RPC command.
+type GetSubsidyScheduleCmd struct {
+	StartHeight *int64
+	Count       *int `jsonrpcdefault:"10"`
+	Heights     *[]int64
+}
+
+// NewGetSubsidyScheduleCmd returns a new instance which can be used to issue a
+// getsubsidyschedule JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetSubsidyScheduleCmd(startHeight *int64, count *int, heights *[]int64) *GetSubsidyScheduleCmd {
+	return &GetSubsidyScheduleCmd{
+		StartHeight: startHeight,
+		Count:       count,
+		Heights:     heights,
+	}
+}
+
+// GetTicketInfoCmd defines the getticketinfo JSON-RPC command.
+type GetTicketInfoCmd struct {
+	TxID string
+}
+
+// NewGetTicketInfoCmd returns a new instance which can be used to issue a
+// getticketinfo JSON-RPC command.
+func NewGetTicketInfoCmd(txID string) *GetTicketInfoCmd {
+	return &GetTicketInfoCmd{
+		TxID: txID,
+	}
+}
+
 // GetTicketPoolValueCmd defines the getticketpoolvalue JSON-RPC command.
 type GetTicketPoolValueCmd struct{}
 
@@ -183,6 +365,80 @@ func NewGetVoteInfoCmd(version uint32) *GetVoteInfoCmd {
 	}
 }
 
+// GetVoteProbabilityCmd defines the getvoteprobability JSON-RPC command.
+type GetVoteProbabilityCmd struct {
+	Blocks   *uint32
+	PoolSize *uint32
+}
+
+// NewGetVoteProbabilityCmd returns a new instance which can be used to issue a
+// getvoteprobability JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetVoteProbabilityCmd(blocks, poolSize *uint32) *GetVoteProbabilityCmd {
+	return &GetVoteProbabilityCmd{
+		Blocks:   blocks,
+		PoolSize: poolSize,
+	}
+}
+
+// SpendingPrevOut identifies an output for the gettxspendingprevout JSON-RPC
+// command.  Both trees are searched when the tree is not specified.
+type SpendingPrevOut struct {
+	TxID string `json:"txid"`
+	Vout uint32 `json:"vout"`
+	Tree *int8  `json:"tree,omitempty"`
+}
+
+// GetTxSpendingPrevOutCmd defines the gettxspendingprevout JSON-RPC command.
+type GetTxSpendingPrevOutCmd struct {
+	Outputs []SpendingPrevOut
+}
+
+// NewGetTxSpendingPrevOutCmd returns a new instance which can be used to issue
+// a gettxspendingprevout JSON-RPC command.
+func NewGetTxSpendingPrevOutCmd(outputs []SpendingPrevOut) *GetTxSpendingPrevOutCmd {
+	return &GetTxSpendingPrevOutCmd{
+		Outputs: outputs,
+	}
+}
+
+// InvalidateBlockCmd defines the invalidateblock JSON-RPC command.
+type InvalidateBlockCmd struct {
+	BlockHash string
+}
+
+// NewInvalidateBlockCmd returns a new instance which can be used to issue an
+// invalidateblock JSON-RPC command.
+func NewInvalidateBlockCmd(blockHash string) *InvalidateBlockCmd {
+	return &InvalidateBlockCmd{
+		BlockHash: blockHash,
+	}
+}
+
+// ListTicketsByStatusCmd defines the listticketsbystatus JSON-RPC command.
+type ListTicketsByStatusCmd struct {
+	Status  string
+	Address *string
+	Skip    *int `jsonrpcdefault:"0"`
+	Count   *int `jsonrpcdefault:"100"`
+}
+
+// NewListTicketsByStatusCmd returns a new instance which can be used to issue a
+// listticketsbystatus JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewListTicketsByStatusCmd(status string, address *string, skip, count *int) *ListTicketsByStatusCmd {
+	return &ListTicketsByStatusCmd{
+		Status:  status,
+		Address: address,
+		Skip:    skip,
+		Count:   count,
+	}
+}
+
 // LiveTicketsCmd is a type handling custom marshaling and
 // unmarshaling of livetickets JSON RPC commands.
 type LiveTicketsCmd struct{}
@@ -223,6 +479,19 @@ func NewRebroadcastWinnersCmd() *RebroadcastWinnersCmd {
 	return &RebroadcastWinnersCmd{}
 }
 
+// ReconsiderBlockCmd defines the reconsiderblock JSON-RPC command.
+type ReconsiderBlockCmd struct {
+	BlockHash string
+}
+
+// NewReconsiderBlockCmd returns a new instance which can be used to issue a
+// reconsiderblock JSON-RPC command.
+func NewReconsiderBlockCmd(blockHash string) *ReconsiderBlockCmd {
+	return &ReconsiderBlockCmd{
+		BlockHash: blockHash,
+	}
+}
+
 // TicketFeeInfoCmd defines the ticketsfeeinfo JSON-RPC command.
 type TicketFeeInfoCmd struct {
 	Blocks  *uint32
@@ -281,6 +550,24 @@ func NewTxFeeInfoCmd(blocks *uint32, start *uint32, end *uint32) *TxFeeInfoCmd {
 	}
 }
 
+// VerifyLotteryCmd defines the verifylottery JSON-RPC command.
+type VerifyLotteryCmd struct {
+	BlockHash string
+	Tickets   *[]string
+}
+
+// NewVerifyLotteryCmd returns a new instance which can be used to issue a
+// verifylottery JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewVerifyLotteryCmd(blockHash string, tickets *[]string) *VerifyLotteryCmd {
+	return &VerifyLotteryCmd{
+		BlockHash: blockHash,
+		Tickets:   tickets,
+	}
+}
+
 // VersionCmd defines the version JSON-RPC command.
 type VersionCmd struct{}
 
@@ -292,6 +579,7 @@ func init() {
 	// No special flags for commands in this file.
 	flags := UsageFlag(0)
 
+	MustRegisterCmd("backupchain", (*BackupChainCmd)(nil), flags)
 	MustRegisterCmd("estimatestakediff", (*EstimateStakeDiffCmd)(nil), flags)
 	MustRegisterCmd("existsaddress", (*ExistsAddressCmd)(nil), flags)
 	MustRegisterCmd("existsaddresses", (*ExistsAddressesCmd)(nil), flags)
@@ -300,19 +588,36 @@ func init() {
 	MustRegisterCmd("existsliveticket", (*ExistsLiveTicketCmd)(nil), flags)
 	MustRegisterCmd("existslivetickets", (*ExistsLiveTicketsCmd)(nil), flags)
 	MustRegisterCmd("existsmempooltxs", (*ExistsMempoolTxsCmd)(nil), flags)
+	MustRegisterCmd("forcedeployment", (*ForceDeploymentCmd)(nil), flags)
+	MustRegisterCmd("getaddressbalance", (*GetAddressBalanceCmd)(nil), flags)
+	MustRegisterCmd("getaddressdeltas", (*GetAddressDeltasCmd)(nil), flags)
+	MustRegisterCmd("getaddressutxos", (*GetAddressUtxosCmd)(nil), flags)
+	MustRegisterCmd("getbackupinfo", (*GetBackupInfoCmd)(nil), flags)
+	MustRegisterCmd("getblockstats", (*GetBlockStatsCmd)(nil), flags)
 	MustRegisterCmd("getcoinsupply", (*GetCoinSupplyCmd)(nil), flags)
+	MustRegisterCmd("getindexinfo", (*GetIndexInfoCmd)(nil), flags)
+	MustRegisterCmd("getnetmsgstats", (*GetNetMsgStatsCmd)(nil), flags)
+	MustRegisterCmd("getspentinfo", (*GetSpentInfoCmd)(nil), flags)
 	MustRegisterCmd("getstakedifficulty", (*GetStakeDifficultyCmd)(nil), flags)
 	MustRegisterCmd("getstakeversioninfo", (*GetStakeVersionInfoCmd)(nil), flags)
 	MustRegisterCmd("getstakeversions", (*GetStakeVersionsCmd)(nil), flags)
+	MustRegisterCmd("getsubsidyschedule", (*GetSubsidyScheduleCmd)(nil), flags)
+	MustRegisterCmd("getticketinfo", (*GetTicketInfoCmd)(nil), flags)
 	MustRegisterCmd("getticketpoolvalue", (*GetTicketPoolValueCmd)(nil), flags)
+	MustRegisterCmd("gettxspendingprevout", (*GetTxSpendingPrevOutCmd)(nil), flags)
 	MustRegisterCmd("getvoteinfo", (*GetVoteInfoCmd)(nil), flags)
+	MustRegisterCmd("getvoteprobability", (*GetVoteProbabilityCmd)(nil), flags)
+	MustRegisterCmd("invalidateblock", (*InvalidateBlockCmd)(nil), flags)
+	MustRegisterCmd("listticketsbystatus", (*ListTicketsByStatusCmd)(nil), flags)
 	MustRegisterCmd("livetickets", (*LiveTicketsCmd)(nil), flags)
 	MustRegisterCmd("missedtickets", (*MissedTicketsCmd)(nil), flags)
 	MustRegisterCmd("rebroadcastmissed", (*RebroadcastMissedCmd)(nil), flags)
 	MustRegisterCmd("rebroadcastwinners", (*RebroadcastWinnersCmd)(nil), flags)
+	MustRegisterCmd("reconsiderblock", (*ReconsiderBlockCmd)(nil), flags)
 	MustRegisterCmd("ticketfeeinfo", (*TicketFeeInfoCmd)(nil), flags)
 	MustRegisterCmd("ticketsforaddress", (*TicketsForAddressCmd)(nil), flags)
 	MustRegisterCmd("ticketvwap", (*TicketVWAPCmd)(nil), flags)
 	MustRegisterCmd("txfeeinfo", (*TxFeeInfoCmd)(nil), flags)
+	MustRegisterCmd("verifylottery", (*VerifyLotteryCmd)(nil), flags)
 	MustRegisterCmd("version", (*VersionCmd)(nil), flags)
 }
@@ -43,6 +43,251 @@ func TestDcrdCmds(t *testing.T) {
 				LevelSpec: "trace",
 			},
 		},
+		{
+			name: "getaddressbalance",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getaddressbalance", `["1Address","2Address"]`)
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetAddressBalanceCmd([]string{"1Address", "2Address"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressbalance","params":[["1Address","2Address"]],"id":1}`,
+			unmarshalled: &dcrjson.GetAddressBalanceCmd{
+				Addresses: []string{"1Address", "2Address"},
+			},
+		},
+		{
+			name: "getaddressdeltas",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getaddressdeltas", "1Address")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetAddressDeltasCmd("1Address", nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressdeltas","params":["1Address"],"id":1}`,
+			unmarshalled: &dcrjson.GetAddressDeltasCmd{
+				Address: "1Address",
+				Skip:    dcrjson.Int(0),
+				Count:   dcrjson.Int(100),
+			},
+		},
+		{
+			name: "getaddressdeltas optional",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getaddressdeltas", "1Address", 5, 10)
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetAddressDeltasCmd("1Address",
+					dcrjson.Int(5), dcrjson.Int(10))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressdeltas","params":["1Address",5,10],"id":1}`,
+			unmarshalled: &dcrjson.GetAddressDeltasCmd{
+				Address: "1Address",
+				Skip:    dcrjson.Int(5),
+				Count:   dcrjson.Int(10),
+			},
+		},
+		{
+			name: "getaddressutxos",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getaddressutxos", "1Address")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetAddressUtxosCmd("1Address", nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressutxos","params":["1Address"],"id":1}`,
+			unmarshalled: &dcrjson.GetAddressUtxosCmd{
+				Address: "1Address",
+				Skip:    dcrjson.Int(0),
+				Count:   dcrjson.Int(100),
+			},
+		},
+		{
+			name: "getaddressutxos optional",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getaddressutxos", "1Address", 5, 10)
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetAddressUtxosCmd("1Address",
+					dcrjson.Int(5), dcrjson.Int(10))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressutxos","params":["1Address",5,10],"id":1}`,
+			unmarshalled: &dcrjson.GetAddressUtxosCmd{
+				Address: "1Address",
+				Skip:    dcrjson.Int(5),
+				Count:   dcrjson.Int(10),
+			},
+		},
+		{
+			name: "backupchain",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("backupchain", "/backup")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewBackupChainCmd("/backup")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"backupchain","params":["/backup"],"id":1}`,
+			unmarshalled: &dcrjson.BackupChainCmd{
+				Destination: "/backup",
+			},
+		},
+		{
+			name: "forcedeployment",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("forcedeployment", "maxblocksize", "active")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewForceDeploymentCmd("maxblocksize", "active")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"forcedeployment","params":["maxblocksize","active"],"id":1}`,
+			unmarshalled: &dcrjson.ForceDeploymentCmd{
+				AgendaID: "maxblocksize",
+				State:    "active",
+			},
+		},
+		{
+			name: "getbackupinfo",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getbackupinfo")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetBackupInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getbackupinfo","params":[],"id":1}`,
+			unmarshalled: &dcrjson.GetBackupInfoCmd{},
+		},
+		{
+			name: "invalidateblock",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("invalidateblock", "123")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewInvalidateBlockCmd("123")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"invalidateblock","params":["123"],"id":1}`,
+			unmarshalled: &dcrjson.InvalidateBlockCmd{
+				BlockHash: "123",
+			},
+		},
+		{
+			name: "reconsiderblock",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("reconsiderblock", "123")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewReconsiderBlockCmd("123")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"reconsiderblock","params":["123"],"id":1}`,
+			unmarshalled: &dcrjson.ReconsiderBlockCmd{
+				BlockHash: "123",
+			},
+		},
+		{
+			name: "getblockstats",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getblockstats", "1024")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetBlockStatsCmd("1024", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockstats","params":["1024"],"id":1}`,
+			unmarshalled: &dcrjson.GetBlockStatsCmd{
+				HashOrHeight: "1024",
+			},
+		},
+		{
+			name: "getblockstats optional",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getblockstats", "1024",
+					[]string{"totalfee", "ticketprice"})
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetBlockStatsCmd("1024",
+					&[]string{"totalfee", "ticketprice"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockstats","params":["1024",["totalfee","ticketprice"]],"id":1}`,
+			unmarshalled: &dcrjson.GetBlockStatsCmd{
+				HashOrHeight: "1024",
+				Stats:        &[]string{"totalfee", "ticketprice"},
+			},
+		},
+		{
+			name: "getindexinfo",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getindexinfo")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetIndexInfoCmd(nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getindexinfo","params":[],"id":1}`,
+			unmarshalled: &dcrjson.GetIndexInfoCmd{},
+		},
+		{
+			name: "getindexinfo optional",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getindexinfo", "address index")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetIndexInfoCmd(dcrjson.String("address index"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getindexinfo","params":["address index"],"id":1}`,
+			unmarshalled: &dcrjson.GetIndexInfoCmd{
+				Index: dcrjson.String("address index"),
+			},
+		},
+		{
+			name: "getnetmsgstats",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getnetmsgstats")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetNetMsgStatsCmd(nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getnetmsgstats","params":[],"id":1}`,
+			unmarshalled: &dcrjson.GetNetMsgStatsCmd{},
+		},
+		{
+			name: "getnetmsgstats optional",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getnetmsgstats", 3)
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetNetMsgStatsCmd(dcrjson.Int32(3))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getnetmsgstats","params":[3],"id":1}`,
+			unmarshalled: &dcrjson.GetNetMsgStatsCmd{
+				PeerID: dcrjson.Int32(3),
+			},
+		},
+		{
+			name: "getspentinfo",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getspentinfo", "123", 1)
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetSpentInfoCmd("123", 1, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getspentinfo","params":["123",1],"id":1}`,
+			unmarshalled: &dcrjson.GetSpentInfoCmd{
+				TxID:  "123",
+				Index: 1,
+			},
+		},
+		{
+			name: "getspentinfo optional",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getspentinfo", "123", 1, 1)
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetSpentInfoCmd("123", 1, dcrjson.Int8(1))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getspentinfo","params":["123",1,1],"id":1}`,
+			unmarshalled: &dcrjson.GetSpentInfoCmd{
+				TxID:  "123",
+				Index: 1,
+				Tree:  dcrjson.Int8(1),
+			},
+		},
 		{
 			name: "getstakeversions",
 			newCmd: func() (interface{}, error) {
@@ -57,6 +302,71 @@ func TestDcrdCmds(t *testing.T) {
 				Count: 1,
 			},
 		},
+		{
+			name: "getsubsidyschedule",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getsubsidyschedule")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetSubsidyScheduleCmd(nil, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getsubsidyschedule","params":[],"id":1}`,
+			unmarshalled: &dcrjson.GetSubsidyScheduleCmd{
+				Count: dcrjson.Int(10),
+			},
+		},
+		{
+			name: "getsubsidyschedule optional",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getsubsidyschedule", 4096, 20,
+					"[100000,1000000]")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetSubsidyScheduleCmd(
+					dcrjson.Int64(4096), dcrjson.Int(20),
+					&[]int64{100000, 1000000})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getsubsidyschedule","params":[4096,20,[100000,1000000]],"id":1}`,
+			unmarshalled: &dcrjson.GetSubsidyScheduleCmd{
+				StartHeight: dcrjson.Int64(4096),
+				Count:       dcrjson.Int(20),
+				Heights:     &[]int64{100000, 1000000},
+			},
+		},
+		{
+			name: "getticketinfo",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getticketinfo", "123")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetTicketInfoCmd("123")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getticketinfo","params":["123"],"id":1}`,
+			unmarshalled: &dcrjson.GetTicketInfoCmd{
+				TxID: "123",
+			},
+		},
+		{
+			name: "gettxspendingprevout",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("gettxspendingprevout",
+					`[{"txid":"123","vout":1},{"txid":"456","vout":0,"tree":1}]`)
+			},
+			staticCmd: func() interface{} {
+				outputs := []dcrjson.SpendingPrevOut{
+					{TxID: "123", Vout: 1},
+					{TxID: "456", Vout: 0, Tree: dcrjson.Int8(1)},
+				}
+				return dcrjson.NewGetTxSpendingPrevOutCmd(outputs)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettxspendingprevout","params":[[{"txid":"123","vout":1},{"txid":"456","vout":0,"tree":1}]],"id":1}`,
+			unmarshalled: &dcrjson.GetTxSpendingPrevOutCmd{
+				Outputs: []dcrjson.SpendingPrevOut{
+					{TxID: "123", Vout: 1},
+					{TxID: "456", Vout: 0, Tree: dcrjson.Int8(1)},
+				},
+			},
+		},
 		{
 			name: "getvoteinfo",
 			newCmd: func() (interface{}, error) {
@@ -70,6 +380,96 @@ func TestDcrdCmds(t *testing.T) {
 				Version: 1,
 			},
 		},
+		{
+			name: "getvoteprobability",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getvoteprobability")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetVoteProbabilityCmd(nil, nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getvoteprobability","params":[],"id":1}`,
+			unmarshalled: &dcrjson.GetVoteProbabilityCmd{},
+		},
+		{
+			name: "getvoteprobability optional",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("getvoteprobability", 288, 40960)
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewGetVoteProbabilityCmd(
+					dcrjson.Uint32(288), dcrjson.Uint32(40960))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getvoteprobability","params":[288,40960],"id":1}`,
+			unmarshalled: &dcrjson.GetVoteProbabilityCmd{
+				Blocks:   dcrjson.Uint32(288),
+				PoolSize: dcrjson.Uint32(40960),
+			},
+		},
+		{
+			name: "listticketsbystatus",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("listticketsbystatus", "voted")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewListTicketsByStatusCmd("voted", nil,
+					nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"listticketsbystatus","params":["voted"],"id":1}`,
+			unmarshalled: &dcrjson.ListTicketsByStatusCmd{
+				Status: "voted",
+				Skip:   dcrjson.Int(0),
+				Count:  dcrjson.Int(100),
+			},
+		},
+		{
+			name: "listticketsbystatus optional",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("listticketsbystatus", "missed",
+					"1Address", 5, 10)
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewListTicketsByStatusCmd("missed",
+					dcrjson.String("1Address"), dcrjson.Int(5),
+					dcrjson.Int(10))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"listticketsbystatus","params":["missed","1Address",5,10],"id":1}`,
+			unmarshalled: &dcrjson.ListTicketsByStatusCmd{
+				Status:  "missed",
+				Address: dcrjson.String("1Address"),
+				Skip:    dcrjson.Int(5),
+				Count:   dcrjson.Int(10),
+			},
+		},
+		{
+			name: "verifylottery",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("verifylottery", "123")
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewVerifyLotteryCmd("123", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"verifylottery","params":["123"],"id":1}`,
+			unmarshalled: &dcrjson.VerifyLotteryCmd{
+				BlockHash: "123",
+			},
+		},
+		{
+			name: "verifylottery optional",
+			newCmd: func() (interface{}, error) {
+				return dcrjson.NewCmd("verifylottery", "123",
+					`["456","789"]`)
+			},
+			staticCmd: func() interface{} {
+				return dcrjson.NewVerifyLotteryCmd("123",
+					&[]string{"456", "789"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"verifylottery","params":["123",["456","789"]],"id":1}`,
+			unmarshalled: &dcrjson.VerifyLotteryCmd{
+				BlockHash: "123",
+				Tickets:   &[]string{"456", "789"},
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -6,6 +6,51 @@
 
 package dcrjson
 
+// AddressDeltaResult models the data returned for each balance change by the
+// getaddressdeltas command.
+type AddressDeltaResult struct {
+	TxID          string  `json:"txid"`
+	Tree          int8    `json:"tree"`
+	Index         uint32  `json:"index"`
+	Spend         bool    `json:"spend"`
+	Amount        float64 `json:"amount"`
+	Height        int64   `json:"height"`
+	Confirmations int64   `json:"confirmations"`
+}
+
+// AddressUtxoResult models the data returned for each unspent output by the
+// getaddressutxos command.
+type AddressUtxoResult struct {
+	TxID          string  `json:"txid"`
+	Vout          uint32  `json:"vout"`
+	Tree          int8    `json:"tree"`
+	TxType        string  `json:"txtype"`
+	Amount        float64 `json:"amount"`
+	ScriptPubKey  string  `json:"scriptpubkey"`
+	Height        int64   `json:"height"`
+	Confirmations int64   `json:"confirmations"`
+}
+
+// BackupInfoResult models the data returned from the getbackupinfo command.
+type BackupInfoResult struct {
+	Destination     string `json:"destination"`
+	InProgress      bool   `json:"inprogress"`
+	StartTime       int64  `json:"starttime"`
+	EndTime         int64  `json:"endtime,omitempty"`
+	TotalBytes      int64  `json:"totalbytes"`
+	CopiedBytes     int64  `json:"copiedbytes"`
+	MetadataEntries uint64 `json:"metadataentries"`
+	Error           string `json:"error,omitempty"`
+}
+
+// GetAddressBalanceResult models the data returned from the getaddressbalance
+// command.
+type GetAddressBalanceResult struct {
+	Balance     float64 `json:"balance"`
+	Received    float64 `json:"received"`
+	Unconfirmed float64 `json:"unconfirmed"`
+}
+
 // GetStakeDifficultyResult models the data returned from the
 // getstakedifficulty command.
 type GetStakeDifficultyResult struct {
@@ -27,6 +72,75 @@ type VersionInterval struct {
 	VoteVersions []VersionCount `json:"voteversions"`
 }
 
+// GetBlockStatsResult models the data returned from the getblockstats command.
+// Only the requested statistics are set.  The amounts are in coins and the fee
+// rates in coins per kilobyte.
+type GetBlockStatsResult struct {
+	BlockHash          *string   `json:"blockhash,omitempty"`
+	Height             *int64    `json:"height,omitempty"`
+	Time               *int64    `json:"time,omitempty"`
+	Size               *uint32   `json:"size,omitempty"`
+	Txs                *uint32   `json:"txs,omitempty"`
+	STxs               *uint32   `json:"stxs,omitempty"`
+	Ins                *uint32   `json:"ins,omitempty"`
+	Outs               *uint32   `json:"outs,omitempty"`
+	TotalOut           *float64  `json:"totalout,omitempty"`
+	TotalFee           *float64  `json:"totalfee,omitempty"`
+	MinFeeRate         *float64  `json:"minfeerate,omitempty"`
+	MaxFeeRate         *float64  `json:"maxfeerate,omitempty"`
+	AvgFeeRate         *float64  `json:"avgfeerate,omitempty"`
+	FeeRatePercentiles []float64 `json:"feeratepercentiles,omitempty"`
+	MinTxSize          *uint32   `json:"mintxsize,omitempty"`
+	MaxTxSize          *uint32   `json:"maxtxsize,omitempty"`
+	AvgTxSize          *uint32   `json:"avgtxsize,omitempty"`
+	Votes              *uint32   `json:"votes,omitempty"`
+	Tickets            *uint32   `json:"tickets,omitempty"`
+	Revocations        *uint32   `json:"revocations,omitempty"`
+	TicketPrice        *float64  `json:"ticketprice,omitempty"`
+	PoolSize           *uint32   `json:"poolsize,omitempty"`
+	PoWSubsidy         *float64  `json:"powsubsidy,omitempty"`
+	PoSSubsidy         *float64  `json:"possubsidy,omitempty"`
+	DevSubsidy         *float64  `json:"devsubsidy,omitempty"`
+}
+
+// IndexInfoResult models the progress of a single optional index as returned
+// by the getindexinfo command.
+type IndexInfoResult struct {
+	Synced          bool   `json:"synced"`
+	BestBlockHeight int64  `json:"bestblockheight"`
+	Error           string `json:"error,omitempty"`
+}
+
+// NetMsgStatsResult models the number of bytes sent and received for a single
+// wire message command as returned by the getnetmsgstats command.
+type NetMsgStatsResult struct {
+	Command   string `json:"command"`
+	BytesSent uint64 `json:"bytessent"`
+	BytesRecv uint64 `json:"bytesrecv"`
+}
+
+// GetSpentInfoResult models the data returned from the getspentinfo command.
+type GetSpentInfoResult struct {
+	TxID      string `json:"txid"`
+	Index     uint32 `json:"index"`
+	BlockHash string `json:"blockhash"`
+	Height    int64  `json:"height"`
+}
+
+// TxSpendingPrevOutResult models the data returned for each output by the
+// gettxspendingprevout command.  The spending fields are only set when the
+// output is spent and the block fields only when it is spent in the main
+// chain.
+type TxSpendingPrevOutResult struct {
+	TxID         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	Tree         int8    `json:"tree"`
+	SpendingTxID string  `json:"spendingtxid,omitempty"`
+	SpendingVin  *uint32 `json:"spendingvin,omitempty"`
+	BlockHash    string  `json:"blockhash,omitempty"`
+	BlockHeight  int64   `json:"blockheight,omitempty"`
+}
+
 // GetStakeVersionInfoResult models the resulting data for getstakeversioninfo
 // command.
 type GetStakeVersionInfoResult struct {
@@ -112,6 +226,64 @@ type MissedTicketsResult struct {
 	Tickets []string `json:"tickets"`
 }
 
+// SubsidyScheduleInterval describes the subsidy of a subsidy reduction
+// interval.  The per block subsidies are those of the first block of the
+// interval when it includes all votes, while the totals are the subsidy
+// created by all blocks of the interval.  All amounts are in atoms.
+type SubsidyScheduleInterval struct {
+	Interval       int64 `json:"interval"`
+	StartHeight    int64 `json:"startheight"`
+	EndHeight      int64 `json:"endheight"`
+	PoW            int64 `json:"pow"`
+	PoS            int64 `json:"pos"`
+	Developer      int64 `json:"developer"`
+	TotalPoW       int64 `json:"totalpow"`
+	TotalPoS       int64 `json:"totalpos"`
+	TotalDeveloper int64 `json:"totaldeveloper"`
+	Total          int64 `json:"total"`
+	Supply         int64 `json:"supply"`
+}
+
+// SubsidyScheduleSupply is the projected coin supply after the block at a
+// height in atoms.
+type SubsidyScheduleSupply struct {
+	Height int64 `json:"height"`
+	Supply int64 `json:"supply"`
+}
+
+// GetSubsidyScheduleResult models the data returned from the
+// getsubsidyschedule command.  The projected supplies assume every block
+// includes all votes, and the missed subsidy is the difference between the
+// supply the chain would have had under that assumption and the actual supply.
+type GetSubsidyScheduleResult struct {
+	Height            int64                     `json:"height"`
+	ReductionInterval int64                     `json:"reductioninterval"`
+	Intervals         []SubsidyScheduleInterval `json:"intervals"`
+	Supply            []SubsidyScheduleSupply   `json:"supply,omitempty"`
+	ActualSupply      int64                     `json:"actualsupply"`
+	TheoreticalSupply int64                     `json:"theoreticalsupply"`
+	MissedSubsidy     int64                     `json:"missedsubsidy"`
+}
+
+// TicketInfoResult models the history of a ticket as returned by the
+// getticketinfo and listticketsbystatus commands.  The missed height is only set
+// for tickets which were missed or expired and the vote and revocation fields
+// only for tickets spent by a vote or revocation respectively.
+type TicketInfoResult struct {
+	Hash             string  `json:"hash"`
+	Status           string  `json:"status"`
+	Price            float64 `json:"price"`
+	PurchaseBlock    string  `json:"purchaseblock"`
+	PurchaseHeight   int64   `json:"purchaseheight"`
+	MaturityHeight   int64   `json:"maturityheight"`
+	ExpiryHeight     int64   `json:"expiryheight"`
+	MissedHeight     int64   `json:"missedheight,omitempty"`
+	VoteTxID         string  `json:"votetxid,omitempty"`
+	VoteHeight       int64   `json:"voteheight,omitempty"`
+	RevocationTxID   string  `json:"revocationtxid,omitempty"`
+	RevocationHeight int64   `json:"revocationheight,omitempty"`
+}
+
 // Ticket is the structure representing a ticket.
 type Ticket struct {
 	Hash  string `json:"hash"`
@@ -183,6 +355,68 @@ type TxFeeInfoResult struct {
 	FeeInfoRange   FeeInfoRange   `json:"feeinforange"`
 }
 
+// VoteProbabilityPercentile is the number of blocks, and the approximate time,
+// within which a ticket votes with the given probability.
+type VoteProbabilityPercentile struct {
+	Probability float64 `json:"probability"`
+	Blocks      uint32  `json:"blocks"`
+	Seconds     int64   `json:"seconds"`
+}
+
+// VoteProbabilityResult models the data returned from the getvoteprobability
+// command.  The percentiles only include the probabilities a ticket reaches
+// before it expires.
+type VoteProbabilityResult struct {
+	PoolSize          uint32                      `json:"poolsize"`
+	TicketsPerBlock   uint16                      `json:"ticketsperblock"`
+	TicketExpiry      uint32                      `json:"ticketexpiry"`
+	BlockProbability  float64                     `json:"blockprobability"`
+	Blocks            uint32                      `json:"blocks"`
+	VoteProbability   float64                     `json:"voteprobability"`
+	ExpiryProbability float64                     `json:"expiryprobability"`
+	ExpectedBlocks    float64                     `json:"expectedblocks"`
+	ExpectedSeconds   int64                       `json:"expectedseconds"`
+	Percentiles       []VoteProbabilityPercentile `json:"percentiles"`
+}
+
+// LotteryWinner describes a ticket selected by the lottery of a block along
+// with the index it was drawn at and whether its vote was included in the
+// block.
+type LotteryWinner struct {
+	Ticket string `json:"ticket"`
+	Index  int    `json:"index"`
+	Voted  bool   `json:"voted"`
+}
+
+// LotteryTicket describes whether a ticket was eligible for the lottery of a
+// block, whether it was selected, and whether its vote was included.
+type LotteryTicket struct {
+	Ticket   string `json:"ticket"`
+	Eligible bool   `json:"eligible"`
+	Selected bool   `json:"selected"`
+	Voted    bool   `json:"voted"`
+}
+
+// VerifyLotteryResult models the data returned from the verifylottery command.
+// The lottery selecting the voters of a block is seeded with the header of its
+// parent and draws from the live tickets of the parent.  Valid is only set
+// when the recomputed pool size, winners, and final state match those
+// committed to by the header and recorded by the chain.
+type VerifyLotteryResult struct {
+	Hash             string          `json:"hash"`
+	Height           int64           `json:"height"`
+	ParentHash       string          `json:"parenthash"`
+	Seed             string          `json:"seed"`
+	PoolSize         int             `json:"poolsize"`
+	HeaderPoolSize   uint32          `json:"headerpoolsize"`
+	PRNGState        string          `json:"prngstate"`
+	FinalState       string          `json:"finalstate"`
+	HeaderFinalState string          `json:"headerfinalstate"`
+	Valid            bool            `json:"valid"`
+	Winners          []LotteryWinner `json:"winners"`
+	Tickets          []LotteryTicket `json:"tickets,omitempty"`
+}
+
 // VersionResult models objects included in the version response.  In the actual
 // result, these objects are keyed by the program or API name.
 type VersionResult struct {
@@ -30,6 +30,14 @@ func Uint(v uint) *uint {
 	return p
 }
 
+// Int8 is a helper routine that allocates a new int8 value to store v and
+// returns a pointer to it.  This is useful when assigning optional parameters.
+func Int8(v int8) *int8 {
+	p := new(int8)
+	*p = v
+	return p
+}
+
 // Int32 is a helper routine that allocates a new int32 value to store v and
 // returns a pointer to it.  This is useful when assigning optional parameters.
 func Int32(v int32) *int32 {
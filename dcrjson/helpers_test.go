@@ -53,6 +53,16 @@ func TestHelpers(t *testing.T) {
 				return &val
 			}(),
 		},
+		{
+			name: "int8",
+			f: func() interface{} {
+				return dcrjson.Int8(5)
+			},
+			expected: func() interface{} {
+				val := int8(5)
+				return &val
+			}(),
+		},
 		{
 			name: "int32",
 			f: func() interface{} {
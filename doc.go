@@ -43,6 +43,15 @@ Application Options:
                             banning misbehaving peers.
       --whitelist=          Add an IP network or IP that will not be banned.
                             (eg. 192.168.1.0/24 or ::1)
+      --asmap=              File containing an asmap used to group peers by the
+                            autonomous system which announces them rather than
+                            by /16 (relative paths are relative to the data
+                            directory)
+      --maxuploadtarget=    Try to keep outbound traffic under the given target
+                            in MiB per 24h -- Historical blocks are no longer
+                            served to non-whitelisted peers once reached, and
+                            targets below the network minimum needed to serve
+                            them are raised to it (0 = no limit)
   -u, --rpcuser=            Username for RPC connections
   -P, --rpcpass=            Password for RPC connections
       --rpclimituser=       Username for limited RPC connections
@@ -74,9 +83,23 @@ Application Options:
                             credentials for each connection.
       --testnet             Use the test network
       --simnet              Use the simulation test network
+      --regnet              Use the regression test network
+      --netparams=          Path to a JSON file describing the parameters of a
+                            custom private network to use
       --nocheckpoints       Disable built-in checkpoints.  Don't do this unless
                             you know what you're doing.
+      --assumevalid=        Hash of a block assumed to be valid along with all
+                            of its ancestors, which allows skipping script
+                            validation for the deeply buried ones -- Specify 0
+                            to always validate scripts (default: none, no
+                            network ships a known good block yet)
+      --forcedeployment=    Force the state of a consensus deployment regardless
+                            of the votes on the regression test network --
+                            Specified as '<agendaid>:<active|failed>'
       --dbtype=             Database backend to use for the Block Chain (ffldb)
+      --blockcompression=   Compress blocks stored in the block database {none,
+                            snappy} -- Only affects newly stored blocks and
+                            requires the ffldb backend
       --profile=            Enable HTTP profiling on given [addr:]port -- NOTE: port
                             must be between 1024 and 65536
       --cpuprofile=         Write CPU profile to the specified file
@@ -91,6 +114,8 @@ Application Options:
                             the log level for individual subsystems -- Use show
                             to list available subsystems (info)
       --upnp                Use UPnP to map our listening port outside of NAT
+      --natpmp              Use NAT-PMP or PCP to map our listening port outside
+                            of NAT -- Tried before UPnP when both are enabled
       --minrelaytxfee=      The minimum transaction fee in HC/kB to be
                             considered a non-zero fee.
       --limitfreerelay=     Limit relay of transactions with no transaction fee
@@ -120,6 +145,11 @@ Application Options:
       --nopeerbloomfilters  Disable bloom filtering support.
       --sigcachemaxsize=    The maximum number of entries in the signature
                             verification cache.
+      --batchsigverify      Verify the Schnorr and Bliss signatures checked by
+                            the scripts of a block once all of the scripts
+                            have been executed, batching the Schnorr
+                            signatures, which is faster for blocks with many
+                            of them
       --blocksonly          Do not accept transactions from remote peers.
       --relaynonstd         Relay non-standard transactions regardless of the
                             default settings for the active network.
@@ -0,0 +1,1118 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coolsnady/hcd/blockchain/indexers"
+	"github.com/coolsnady/hcd/blockchain/stake"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/database"
+	"github.com/coolsnady/hcd/mempool"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
+)
+
+const (
+	// electrumProtocolVersion is the version of the Electrum protocol
+	// implemented by the server.
+	electrumProtocolVersion = "1.4"
+
+	// electrumMaxRequestSize is the maximum size of a single line sent by
+	// an Electrum client.  It is large enough to broadcast any standard
+	// transaction.
+	electrumMaxRequestSize = 1024 * 1024
+
+	// electrumMaxClients is the maximum number of Electrum clients which
+	// may be connected at the same time.
+	electrumMaxClients = 256
+
+	// electrumMaxSubscriptions is the maximum number of script hashes a
+	// single Electrum client may subscribe to.
+	electrumMaxSubscriptions = 20000
+
+	// electrumWriteTimeout is the time allowed to write a message to an
+	// Electrum client before the client is disconnected.
+	electrumWriteTimeout = time.Second * 30
+
+	// electrumErrBadRequest and electrumErrDaemon are the error codes used
+	// by Electrum servers for malformed requests and for requests which
+	// failed to be processed, such as rejected transactions, respectively.
+	electrumErrBadRequest = 1
+	electrumErrDaemon     = 2
+
+	// The following are the standard JSON-RPC 2.0 error codes used for
+	// requests which can't be parsed or dispatched at all.
+	electrumErrParse          = -32700
+	electrumErrMethodNotFound = -32601
+)
+
+// electrumRequest describes a JSON-RPC 2.0 request sent by an Electrum client.
+type electrumRequest struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+// electrumError describes the error of a failed Electrum request.
+type electrumError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error satisfies the error interface and returns the message of the error.
+func (e *electrumError) Error() string {
+	return e.Message
+}
+
+// electrumResponse describes a JSON-RPC 2.0 response sent to an Electrum
+// client.  The result is kept raw so a null result is distinguishable from a
+// missing one.
+type electrumResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *electrumError  `json:"error,omitempty"`
+}
+
+// electrumNotification describes a JSON-RPC 2.0 notification sent to an
+// Electrum client.
+type electrumNotification struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// electrumHeader describes a block header as returned by the
+// blockchain.headers.subscribe method.
+type electrumHeader struct {
+	Height int64  `json:"height"`
+	Hex    string `json:"hex"`
+}
+
+// electrumHistoryItem describes a transaction in the history of a script hash
+// as returned by the blockchain.scripthash.get_history method.  The height is
+// 0 for unconfirmed transactions and -1 for unconfirmed transactions which
+// spend unconfirmed outputs.  The fee is only set for unconfirmed
+// transactions.
+type electrumHistoryItem struct {
+	TxHash string `json:"tx_hash"`
+	Height int64  `json:"height"`
+	Fee    *int64 `json:"fee,omitempty"`
+}
+
+// electrumUnspent describes an unspent output paying to a script hash as
+// returned by the blockchain.scripthash.listunspent method.
+type electrumUnspent struct {
+	TxHash string `json:"tx_hash"`
+	TxPos  uint32 `json:"tx_pos"`
+	Height int64  `json:"height"`
+	Value  int64  `json:"value"`
+}
+
+// electrumBadRequest returns an Electrum error which indicates the request is
+// malformed.
+func electrumBadRequest(format string, args ...interface{}) *electrumError {
+	return &electrumError{
+		Code:    electrumErrBadRequest,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// electrumDaemonError returns an Electrum error which indicates the request
+// could not be processed by the node.
+func electrumDaemonError(format string, args ...interface{}) *electrumError {
+	return &electrumError{
+		Code:    electrumErrDaemon,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// electrumHandler describes the function signature of the Electrum method
+// handlers.
+type electrumHandler func(*electrumServer, *electrumClient, []json.RawMessage) (interface{}, error)
+
+// electrumHandlers maps Electrum method names to the functions which handle
+// them.  It is set in init to avoid an initialization loop.
+var electrumHandlers map[string]electrumHandler
+
+func init() {
+	electrumHandlers = map[string]electrumHandler{
+		"blockchain.headers.subscribe":      handleElectrumHeadersSubscribe,
+		"blockchain.scripthash.get_history": handleElectrumGetHistory,
+		"blockchain.scripthash.listunspent": handleElectrumListUnspent,
+		"blockchain.scripthash.subscribe":   handleElectrumSubscribe,
+		"blockchain.scripthash.unsubscribe": handleElectrumUnsubscribe,
+		"blockchain.transaction.broadcast":  handleElectrumBroadcast,
+		"blockchain.transaction.get":        handleElectrumGetTransaction,
+		"server.ping":                       handleElectrumPing,
+		"server.version":                    handleElectrumVersion,
+	}
+}
+
+// electrumNtfnTipChanged is queued to the notification handler when the best
+// chain tip changed due to the passed block being connected or disconnected.
+type electrumNtfnTipChanged struct {
+	block  *hcutil.Block
+	parent *hcutil.Block
+}
+
+// electrumNtfnUnconfirmed is queued to the notification handler when a
+// transaction involving the scripts with the passed script hashes was added to
+// or removed from the memory pool.
+type electrumNtfnUnconfirmed struct {
+	scriptHashes map[chainhash.Hash]struct{}
+}
+
+// electrumClient houses the state of a single connected Electrum client.
+type electrumClient struct {
+	conn net.Conn
+	addr string
+
+	// sendMtx serializes writes to the connection.
+	sendMtx sync.Mutex
+
+	// The following fields track the subscriptions of the client along
+	// with the last status sent for every subscribed script hash.  They
+	// are protected by the mtx mutex.
+	mtx               sync.Mutex
+	headersSubscribed bool
+	scriptHashes      map[chainhash.Hash]string
+}
+
+// send writes the passed message to the client as a single line.  The
+// connection is closed when the write fails, which in turn disconnects the
+// client.
+func (c *electrumClient) send(msg interface{}) {
+	marshalled, err := json.Marshal(msg)
+	if err != nil {
+		elecLog.Errorf("Failed to marshal message for %s: %v", c.addr,
+			err)
+		return
+	}
+	marshalled = append(marshalled, '\n')
+
+	c.sendMtx.Lock()
+	defer c.sendMtx.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(electrumWriteTimeout))
+	if _, err := c.conn.Write(marshalled); err != nil {
+		elecLog.Debugf("Failed to write to %s: %v", c.addr, err)
+		c.conn.Close()
+	}
+}
+
+// notify sends a notification for the passed method and parameters to the
+// client.
+func (c *electrumClient) notify(method string, params ...interface{}) {
+	c.send(&electrumNotification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+}
+
+// electrumServer provides an Electrum protocol server for lightweight wallets.
+// The history of scripts is served from the script hash index along with the
+// memory pool.
+type electrumServer struct {
+	started  int32
+	shutdown int32
+
+	server    *server
+	listeners []net.Listener
+
+	clientsMtx sync.Mutex
+	clients    map[*electrumClient]struct{}
+
+	ntfnQueue chan interface{}
+	ntfns     chan interface{}
+	wg        sync.WaitGroup
+	quit      chan struct{}
+}
+
+// NotifyTipChanged queues a notification for the Electrum clients that the
+// best chain tip changed due to the passed block, whose parent is also passed,
+// being connected or disconnected.
+func (s *electrumServer) NotifyTipChanged(block, parent *hcutil.Block) {
+	select {
+	case s.ntfnQueue <- &electrumNtfnTipChanged{block: block, parent: parent}:
+	case <-s.quit:
+	}
+}
+
+// notifyUnconfirmed queues a notification for the Electrum clients that a
+// transaction involving the scripts with the passed script hashes was added to
+// or removed from the memory pool.  It is invoked by the script hash index
+// while the memory pool is locked, which is fine since the queue handler
+// always accepts notifications.
+func (s *electrumServer) notifyUnconfirmed(scriptHashes map[chainhash.Hash]struct{}) {
+	select {
+	case s.ntfnQueue <- &electrumNtfnUnconfirmed{scriptHashes: scriptHashes}:
+	case <-s.quit:
+	}
+}
+
+// scriptHashIndexReady returns an error when the script hash index can't be
+// used to serve requests yet.
+func (s *electrumServer) scriptHashIndexReady() error {
+	idx := s.server.scriptHashIndex
+	if s.server.indexManager == nil || s.server.indexManager.IndexSynced(idx) {
+		return nil
+	}
+	if err := s.server.indexManager.IndexError(idx); err != nil {
+		return electrumDaemonError("The %s failed to catch up: %v",
+			idx.Name(), err)
+	}
+	return electrumDaemonError("The %s is still syncing, try again later",
+		idx.Name())
+}
+
+// fetchTransaction returns the transaction with the passed hash from either
+// the memory pool or the main chain by means of the transaction index.
+func (s *electrumServer) fetchTransaction(txHash *chainhash.Hash) (*wire.MsgTx, error) {
+	tx, err := s.server.txMemPool.FetchTransaction(txHash, true)
+	if err == nil {
+		return tx.MsgTx(), nil
+	}
+
+	txIndex := s.server.txIndex
+	blockRegion, err := txIndex.TxBlockRegion(*txHash)
+	if err != nil {
+		return nil, err
+	}
+	if blockRegion == nil {
+		return nil, fmt.Errorf("no information available about "+
+			"transaction %v", txHash)
+	}
+	var txBytes []byte
+	err = s.server.db.View(func(dbTx database.Tx) error {
+		var err error
+		txBytes, err = dbTx.FetchBlockRegion(blockRegion)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var msgTx wire.MsgTx
+	if err := msgTx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, err
+	}
+	return &msgTx, nil
+}
+
+// spendsUnconfirmed returns whether or not the passed transaction spends any
+// outputs of transactions in the memory pool.
+func (s *electrumServer) spendsUnconfirmed(msgTx *wire.MsgTx) bool {
+	for _, txIn := range msgTx.TxIn {
+		prevHash := &txIn.PreviousOutPoint.Hash
+		if s.server.txMemPool.IsTransactionInPool(prevHash) {
+			return true
+		}
+	}
+	return false
+}
+
+// mempoolHistory returns the transactions in the memory pool which pay to or
+// spend from the script with the passed script hash ordered by hash.  They are
+// looked up in the unconfirmed part of the script hash index.
+func (s *electrumServer) mempoolHistory(scriptHash *chainhash.Hash) []*mempool.TxDesc {
+	txHashes := s.server.scriptHashIndex.UnconfirmedTxnsForScriptHash(scriptHash)
+	descs := make([]*mempool.TxDesc, 0, len(txHashes))
+	for i := range txHashes {
+		// Skip transactions which were removed from the memory pool
+		// in the mean time.
+		desc := s.server.txMemPool.FetchTxDesc(&txHashes[i])
+		if desc == nil {
+			continue
+		}
+		descs = append(descs, desc)
+	}
+	return descs
+}
+
+// blockScriptHashes returns the script hashes of the scripts whose status
+// might have changed due to the passed block being connected or disconnected.
+// Those are the scripts the transactions the block applies pay to or spend from
+// along with the scripts of any transactions in the memory pool which spend
+// their outputs, since whether or not those spend unconfirmed outputs changed.
+//
+// The regular transactions of the parent are included regardless of whether
+// or not the block approves them, which at worst results in the statuses of
+// some unchanged scripts being checked.
+func (s *electrumServer) blockScriptHashes(block, parent *hcutil.Block) map[chainhash.Hash]struct{} {
+	// The transactions the outputs are spent from are cached since they
+	// are usually spent from several times.
+	prevTxns := make(map[chainhash.Hash]*wire.MsgTx)
+	prevOutScript := func(prevOut *wire.OutPoint) []byte {
+		msgTx, ok := prevTxns[prevOut.Hash]
+		if !ok {
+			var err error
+			msgTx, err = s.fetchTransaction(&prevOut.Hash)
+			if err != nil {
+				elecLog.Debugf("Failed to fetch transaction "+
+					"%v: %v", prevOut.Hash, err)
+			}
+			prevTxns[prevOut.Hash] = msgTx
+		}
+		if msgTx == nil || prevOut.Index >= uint32(len(msgTx.TxOut)) {
+			return nil
+		}
+		return msgTx.TxOut[prevOut.Index].PkScript
+	}
+
+	scriptHashIndex := s.server.scriptHashIndex
+	txMemPool := s.server.txMemPool
+	hashes := make(map[chainhash.Hash]struct{})
+	addTxns := func(txns []*hcutil.Tx, tree int8) {
+		for _, tx := range txns {
+			msgTx := tx.MsgTx()
+			for hash := range indexers.ScriptHashesForTx(msgTx, prevOutScript) {
+				hashes[hash] = struct{}{}
+			}
+			for i := range msgTx.TxOut {
+				outPoint := wire.OutPoint{Hash: *tx.Hash(),
+					Index: uint32(i), Tree: tree}
+				spender := txMemPool.CheckSpend(outPoint)
+				if spender == nil {
+					continue
+				}
+				for _, hash := range scriptHashIndex.
+					UnconfirmedScriptHashesForTx(spender.Hash()) {
+					hashes[hash] = struct{}{}
+				}
+			}
+		}
+	}
+	addTxns(parent.Transactions(), wire.TxTreeRegular)
+	addTxns(block.STransactions(), wire.TxTreeStake)
+	return hashes
+}
+
+// scriptHashHistory returns the confirmed and unconfirmed history of the
+// script with the passed script hash in the format of the
+// blockchain.scripthash.get_history method.
+func (s *electrumServer) scriptHashHistory(scriptHash *chainhash.Hash) ([]electrumHistoryItem, error) {
+	confirmed, err := s.server.scriptHashIndex.History(scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	history := make([]electrumHistoryItem, 0, len(confirmed))
+	for i := range confirmed {
+		history = append(history, electrumHistoryItem{
+			TxHash: confirmed[i].Hash.String(),
+			Height: confirmed[i].Height,
+		})
+	}
+	for _, desc := range s.mempoolHistory(scriptHash) {
+		item := electrumHistoryItem{TxHash: desc.Tx.Hash().String()}
+		if s.spendsUnconfirmed(desc.Tx.MsgTx()) {
+			item.Height = -1
+		}
+		fee := desc.Fee
+		item.Fee = &fee
+		history = append(history, item)
+	}
+	return history, nil
+}
+
+// scriptHashStatus returns the status of the script with the passed script
+// hash as defined by the Electrum protocol.  That is the hex-encoded sha256
+// hash of the concatenation of "tx_hash:height:" for every transaction in its
+// history, or an empty string when there is no history.
+func (s *electrumServer) scriptHashStatus(scriptHash *chainhash.Hash) (string, error) {
+	history, err := s.scriptHashHistory(scriptHash)
+	if err != nil {
+		return "", err
+	}
+	if len(history) == 0 {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	for _, item := range history {
+		fmt.Fprintf(&buf, "%s:%d:", item.TxHash, item.Height)
+	}
+	status := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(status[:]), nil
+}
+
+// statusResult converts the passed status to the result of the
+// blockchain.scripthash.subscribe method, which is null for scripts without
+// history.
+func statusResult(status string) interface{} {
+	if status == "" {
+		return nil
+	}
+	return status
+}
+
+// bestHeader returns the header of the best chain tip in the format of the
+// blockchain.headers.subscribe method.
+func (s *electrumServer) bestHeader() (*electrumHeader, error) {
+	chain := s.server.blockManager.chain
+	best := chain.BestSnapshot()
+	header, err := chain.HeaderByHeight(best.Height)
+	if err != nil {
+		return nil, err
+	}
+	headerBytes, err := header.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &electrumHeader{
+		Height: best.Height,
+		Hex:    hex.EncodeToString(headerBytes),
+	}, nil
+}
+
+// parseScriptHashParam parses the script hash passed as the first parameter of
+// a request.  Electrum script hashes are displayed in reverse byte order just
+// like transaction hashes.
+func parseScriptHashParam(params []json.RawMessage) (*chainhash.Hash, string, error) {
+	var str string
+	if len(params) < 1 || json.Unmarshal(params[0], &str) != nil {
+		return nil, "", electrumBadRequest("missing script hash")
+	}
+	scriptHash, err := chainhash.NewHashFromStr(str)
+	if err != nil || len(str) != chainhash.MaxHashStringSize {
+		return nil, "", electrumBadRequest("invalid script hash %q",
+			str)
+	}
+	return scriptHash, str, nil
+}
+
+// handleElectrumVersion implements the server.version method.
+func handleElectrumVersion(s *electrumServer, c *electrumClient, params []json.RawMessage) (interface{}, error) {
+	return []string{"hcd " + version(), electrumProtocolVersion}, nil
+}
+
+// handleElectrumPing implements the server.ping method.
+func handleElectrumPing(s *electrumServer, c *electrumClient, params []json.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+// handleElectrumHeadersSubscribe implements the blockchain.headers.subscribe
+// method.
+func handleElectrumHeadersSubscribe(s *electrumServer, c *electrumClient, params []json.RawMessage) (interface{}, error) {
+	header, err := s.bestHeader()
+	if err != nil {
+		return nil, electrumDaemonError("failed to fetch best header: %v",
+			err)
+	}
+	c.mtx.Lock()
+	c.headersSubscribed = true
+	c.mtx.Unlock()
+	return header, nil
+}
+
+// handleElectrumGetHistory implements the blockchain.scripthash.get_history
+// method.
+func handleElectrumGetHistory(s *electrumServer, c *electrumClient, params []json.RawMessage) (interface{}, error) {
+	scriptHash, _, err := parseScriptHashParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.scriptHashIndexReady(); err != nil {
+		return nil, err
+	}
+	history, err := s.scriptHashHistory(scriptHash)
+	if err != nil {
+		return nil, electrumDaemonError("failed to fetch history: %v",
+			err)
+	}
+	return history, nil
+}
+
+// handleElectrumListUnspent implements the blockchain.scripthash.listunspent
+// method.
+func handleElectrumListUnspent(s *electrumServer, c *electrumClient, params []json.RawMessage) (interface{}, error) {
+	scriptHash, _, err := parseScriptHashParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.scriptHashIndexReady(); err != nil {
+		return nil, err
+	}
+	confirmed, err := s.server.scriptHashIndex.History(scriptHash)
+	if err != nil {
+		return nil, electrumDaemonError("failed to fetch history: %v",
+			err)
+	}
+
+	chain := s.server.blockManager.chain
+	txMemPool := s.server.txMemPool
+	unspent := make([]electrumUnspent, 0)
+
+	// addOutputs adds the outputs of the passed transaction which pay to
+	// the script hash and are unspent according to the passed function.
+	addOutputs := func(txHash *chainhash.Hash, msgTx *wire.MsgTx, tree int8, height int64, isUnspent func(uint32) bool) {
+		for i, txOut := range msgTx.TxOut {
+			var pays bool
+			for _, hash := range indexers.ScriptHashesForScript(txOut.PkScript) {
+				pays = pays || hash == *scriptHash
+			}
+			if !pays || !isUnspent(uint32(i)) {
+				continue
+			}
+			outPoint := wire.OutPoint{Hash: *txHash, Index: uint32(i),
+				Tree: tree}
+			if txMemPool.CheckSpend(outPoint) != nil {
+				continue
+			}
+			unspent = append(unspent, electrumUnspent{
+				TxHash: txHash.String(),
+				TxPos:  uint32(i),
+				Height: height,
+				Value:  txOut.Value,
+			})
+		}
+	}
+
+	for i := range confirmed {
+		shTx := &confirmed[i]
+		entry, err := chain.FetchUtxoEntry(&shTx.Hash)
+		if err != nil {
+			return nil, electrumDaemonError("failed to fetch utxos: %v",
+				err)
+		}
+		if entry == nil {
+			continue
+		}
+		msgTx, err := s.fetchTransaction(&shTx.Hash)
+		if err != nil {
+			return nil, electrumDaemonError("failed to fetch "+
+				"transaction %v: %v", shTx.Hash, err)
+		}
+		addOutputs(&shTx.Hash, msgTx, shTx.Tree, shTx.Height,
+			func(index uint32) bool {
+				return !entry.IsOutputSpent(index)
+			})
+	}
+	for _, desc := range s.mempoolHistory(scriptHash) {
+		tree := wire.TxTreeRegular
+		if desc.Type != stake.TxTypeRegular {
+			tree = wire.TxTreeStake
+		}
+		addOutputs(desc.Tx.Hash(), desc.Tx.MsgTx(), tree, 0,
+			func(uint32) bool { return true })
+	}
+	return unspent, nil
+}
+
+// handleElectrumSubscribe implements the blockchain.scripthash.subscribe
+// method.
+func handleElectrumSubscribe(s *electrumServer, c *electrumClient, params []json.RawMessage) (interface{}, error) {
+	scriptHash, _, err := parseScriptHashParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.scriptHashIndexReady(); err != nil {
+		return nil, err
+	}
+	status, err := s.scriptHashStatus(scriptHash)
+	if err != nil {
+		return nil, electrumDaemonError("failed to fetch history: %v",
+			err)
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.scriptHashes[*scriptHash]; !ok &&
+		len(c.scriptHashes) >= electrumMaxSubscriptions {
+
+		return nil, electrumBadRequest("too many subscriptions")
+	}
+	c.scriptHashes[*scriptHash] = status
+	return statusResult(status), nil
+}
+
+// handleElectrumUnsubscribe implements the blockchain.scripthash.unsubscribe
+// method.
+func handleElectrumUnsubscribe(s *electrumServer, c *electrumClient, params []json.RawMessage) (interface{}, error) {
+	scriptHash, _, err := parseScriptHashParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	_, ok := c.scriptHashes[*scriptHash]
+	delete(c.scriptHashes, *scriptHash)
+	return ok, nil
+}
+
+// handleElectrumBroadcast implements the blockchain.transaction.broadcast
+// method.
+func handleElectrumBroadcast(s *electrumServer, c *electrumClient, params []json.RawMessage) (interface{}, error) {
+	var hexStr string
+	if len(params) < 1 || json.Unmarshal(params[0], &hexStr) != nil {
+		return nil, electrumBadRequest("missing raw transaction")
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, electrumBadRequest("invalid raw transaction hex")
+	}
+	msgTx := wire.NewMsgTx()
+	if err := msgTx.Deserialize(bytes.NewReader(serializedTx)); err != nil {
+		return nil, electrumBadRequest("could not decode transaction: %v",
+			err)
+	}
+
+	tx := hcutil.NewTx(msgTx)
+	acceptedTxs, err := s.server.blockManager.ProcessTransaction(tx, false,
+		false, false)
+	if err != nil {
+		// Rule errors mean the transaction was simply rejected as
+		// opposed to something actually going wrong, so only log them
+		// at the debug level.
+		if _, ok := err.(mempool.RuleError); ok {
+			elecLog.Debugf("Rejected transaction %v: %v", tx.Hash(),
+				err)
+		} else {
+			elecLog.Errorf("Failed to process transaction %v: %v",
+				tx.Hash(), err)
+		}
+		return nil, electrumDaemonError("rejected transaction %v: %v",
+			tx.Hash(), err)
+	}
+
+	s.server.AnnounceNewTransactions(acceptedTxs)
+
+	// Keep track of the broadcast transactions so that they can be
+	// rebroadcast if they don't make their way into a block.
+	iv := wire.NewInvVect(wire.InvTypeTx, tx.Hash())
+	s.server.AddRebroadcastInventory(iv, tx)
+
+	return tx.Hash().String(), nil
+}
+
+// handleElectrumGetTransaction implements the blockchain.transaction.get
+// method.  Only the raw transaction is provided since the verbose format is
+// specific to the reference implementation.
+func handleElectrumGetTransaction(s *electrumServer, c *electrumClient, params []json.RawMessage) (interface{}, error) {
+	var str string
+	if len(params) < 1 || json.Unmarshal(params[0], &str) != nil {
+		return nil, electrumBadRequest("missing transaction hash")
+	}
+	txHash, err := chainhash.NewHashFromStr(str)
+	if err != nil {
+		return nil, electrumBadRequest("invalid transaction hash %q", str)
+	}
+	if len(params) > 1 {
+		var verbose bool
+		if json.Unmarshal(params[1], &verbose) == nil && verbose {
+			return nil, electrumBadRequest("verbose transactions " +
+				"are not supported")
+		}
+	}
+
+	msgTx, err := s.fetchTransaction(txHash)
+	if err != nil {
+		return nil, electrumDaemonError("%v", err)
+	}
+	txBytes, err := msgTx.Bytes()
+	if err != nil {
+		return nil, electrumDaemonError("failed to serialize "+
+			"transaction: %v", err)
+	}
+	return hex.EncodeToString(txBytes), nil
+}
+
+// handleRequest dispatches the passed request to its handler and returns the
+// response to send to the client.
+func (s *electrumServer) handleRequest(c *electrumClient, req *electrumRequest) *electrumResponse {
+	resp := &electrumResponse{JSONRPC: "2.0", ID: req.ID}
+	if resp.ID == nil {
+		resp.ID = json.RawMessage("null")
+	}
+
+	handler, ok := electrumHandlers[req.Method]
+	if !ok {
+		resp.Error = &electrumError{
+			Code:    electrumErrMethodNotFound,
+			Message: fmt.Sprintf("unknown method %q", req.Method),
+		}
+		return resp
+	}
+	result, err := handler(s, c, req.Params)
+	if err != nil {
+		if elecErr, ok := err.(*electrumError); ok {
+			resp.Error = elecErr
+		} else {
+			resp.Error = electrumDaemonError("%v", err)
+		}
+		return resp
+	}
+	marshalled, err := json.Marshal(result)
+	if err != nil {
+		resp.Error = electrumDaemonError("failed to marshal result: %v",
+			err)
+		return resp
+	}
+	resp.Result = marshalled
+	return resp
+}
+
+// handleLine handles a single line sent by the client, which is either a
+// request or a batch of requests.
+func (s *electrumServer) handleLine(c *electrumClient, line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+
+	if line[0] == '[' {
+		var reqs []electrumRequest
+		if err := json.Unmarshal(line, &reqs); err != nil {
+			c.send(parseErrorResponse(err))
+			return
+		}
+		resps := make([]*electrumResponse, 0, len(reqs))
+		for i := range reqs {
+			resps = append(resps, s.handleRequest(c, &reqs[i]))
+		}
+		c.send(resps)
+		return
+	}
+
+	var req electrumRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		c.send(parseErrorResponse(err))
+		return
+	}
+	c.send(s.handleRequest(c, &req))
+}
+
+// parseErrorResponse returns the response to send for a request which could
+// not be parsed.
+func parseErrorResponse(err error) *electrumResponse {
+	return &electrumResponse{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage("null"),
+		Error: &electrumError{
+			Code:    electrumErrParse,
+			Message: fmt.Sprintf("invalid JSON: %v", err),
+		},
+	}
+}
+
+// inHandler reads the requests of the passed client until it disconnects or
+// the server shuts down.  It must be run as a goroutine.
+func (s *electrumServer) inHandler(c *electrumClient) {
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 4096), electrumMaxRequestSize)
+	for scanner.Scan() {
+		s.handleLine(c, scanner.Bytes())
+	}
+	if err := scanner.Err(); err != nil &&
+		atomic.LoadInt32(&s.shutdown) == 0 {
+
+		elecLog.Debugf("Failed to read from %s: %v", c.addr, err)
+	}
+
+	c.conn.Close()
+	s.clientsMtx.Lock()
+	delete(s.clients, c)
+	s.clientsMtx.Unlock()
+	elecLog.Debugf("Disconnected Electrum client %s", c.addr)
+	s.wg.Done()
+}
+
+// listenHandler accepts Electrum clients on the passed listener until the
+// server shuts down.  It must be run as a goroutine.
+func (s *electrumServer) listenHandler(listener net.Listener) {
+	elecLog.Infof("Electrum server listening on %s", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// Only log the error if not forcibly shutting down.
+			if atomic.LoadInt32(&s.shutdown) == 0 {
+				elecLog.Errorf("Can't accept connection: %v", err)
+				continue
+			}
+			break
+		}
+
+		c := &electrumClient{
+			conn:         conn,
+			addr:         conn.RemoteAddr().String(),
+			scriptHashes: make(map[chainhash.Hash]string),
+		}
+		s.clientsMtx.Lock()
+		if len(s.clients) >= electrumMaxClients {
+			s.clientsMtx.Unlock()
+			elecLog.Infof("Max Electrum clients exceeded [%d] - "+
+				"disconnecting client %s", electrumMaxClients,
+				c.addr)
+			conn.Close()
+			continue
+		}
+		s.clients[c] = struct{}{}
+		s.clientsMtx.Unlock()
+
+		elecLog.Debugf("New Electrum client %s", c.addr)
+		s.wg.Add(1)
+		go s.inHandler(c)
+	}
+	elecLog.Tracef("Electrum listener done for %s", listener.Addr())
+	s.wg.Done()
+}
+
+// connectedClients returns a snapshot of the connected clients.
+func (s *electrumServer) connectedClients() []*electrumClient {
+	s.clientsMtx.Lock()
+	clients := make([]*electrumClient, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.clientsMtx.Unlock()
+	return clients
+}
+
+// notifyStatuses sends a notification for every script hash out of the passed
+// affected ones subscribed to by the passed clients whose status changed.
+func (s *electrumServer) notifyStatuses(clients []*electrumClient, affected map[chainhash.Hash]struct{}) {
+	// Statuses are cached since many clients might be subscribed to the
+	// same script hashes.
+	statuses := make(map[chainhash.Hash]string)
+	for _, c := range clients {
+		c.mtx.Lock()
+
+		// Only look up the smaller of the affected and the subscribed
+		// script hashes in the other.
+		var subscribed []chainhash.Hash
+		if len(affected) < len(c.scriptHashes) {
+			for scriptHash := range affected {
+				if _, ok := c.scriptHashes[scriptHash]; ok {
+					subscribed = append(subscribed, scriptHash)
+				}
+			}
+		} else {
+			for scriptHash := range c.scriptHashes {
+				if _, ok := affected[scriptHash]; ok {
+					subscribed = append(subscribed, scriptHash)
+				}
+			}
+		}
+
+		var changed []chainhash.Hash
+		for _, scriptHash := range subscribed {
+			oldStatus := c.scriptHashes[scriptHash]
+			status, ok := statuses[scriptHash]
+			if !ok {
+				var err error
+				status, err = s.scriptHashStatus(&scriptHash)
+				if err != nil {
+					elecLog.Errorf("Failed to fetch status "+
+						"of script hash %v: %v",
+						scriptHash, err)
+					continue
+				}
+				statuses[scriptHash] = status
+			}
+			if status != oldStatus {
+				c.scriptHashes[scriptHash] = status
+				changed = append(changed, scriptHash)
+			}
+		}
+		c.mtx.Unlock()
+
+		for i := range changed {
+			c.notify("blockchain.scripthash.subscribe",
+				changed[i].String(),
+				statusResult(statuses[changed[i]]))
+		}
+	}
+}
+
+// notificationHandler sends the queued notifications to the subscribed
+// clients.  It must be run as a goroutine.
+func (s *electrumServer) notificationHandler() {
+out:
+	for {
+		select {
+		case n, ok := <-s.ntfns:
+			if !ok {
+				// The queue handler quit.
+				break out
+			}
+			clients := s.connectedClients()
+			if len(clients) == 0 {
+				continue
+			}
+			if s.scriptHashIndexReady() != nil {
+				continue
+			}
+
+			switch n := n.(type) {
+			case *electrumNtfnTipChanged:
+				header, err := s.bestHeader()
+				if err != nil {
+					elecLog.Errorf("Failed to fetch best "+
+						"header: %v", err)
+					continue
+				}
+				for _, c := range clients {
+					c.mtx.Lock()
+					subscribed := c.headersSubscribed
+					c.mtx.Unlock()
+					if subscribed {
+						c.notify("blockchain.headers."+
+							"subscribe", header)
+					}
+				}
+
+				// The status of a script only changes when its
+				// history does, which is when a transaction
+				// involving it is confirmed or unconfirmed.
+				affected := s.blockScriptHashes(n.block, n.parent)
+				s.notifyStatuses(clients, affected)
+
+			case *electrumNtfnUnconfirmed:
+				s.notifyStatuses(clients, n.scriptHashes)
+			}
+
+		case <-s.quit:
+			break out
+		}
+	}
+	s.wg.Done()
+}
+
+// Start begins accepting Electrum clients and sending them notifications.
+func (s *electrumServer) Start() {
+	if atomic.AddInt32(&s.started, 1) != 1 {
+		return
+	}
+
+	elecLog.Trace("Starting Electrum server")
+	for _, listener := range s.listeners {
+		s.wg.Add(1)
+		go s.listenHandler(listener)
+	}
+
+	s.wg.Add(2)
+	go func() {
+		queueHandler(s.ntfnQueue, s.ntfns, s.quit)
+		s.wg.Done()
+	}()
+	go s.notificationHandler()
+
+	// Watch the transactions added to and removed from the memory pool
+	// now that the queue handler accepts notifications.
+	s.server.scriptHashIndex.SetUnconfirmedHandler(s.notifyUnconfirmed)
+}
+
+// Stop disconnects all Electrum clients and shuts down the server.
+func (s *electrumServer) Stop() {
+	if atomic.AddInt32(&s.shutdown, 1) != 1 {
+		elecLog.Infof("Electrum server is already in the process of " +
+			"shutting down")
+		return
+	}
+	elecLog.Warnf("Electrum server shutting down")
+	s.server.scriptHashIndex.SetUnconfirmedHandler(nil)
+	for _, listener := range s.listeners {
+		if err := listener.Close(); err != nil {
+			elecLog.Errorf("Problem shutting down Electrum "+
+				"listener: %v", err)
+		}
+	}
+	close(s.quit)
+	for _, c := range s.connectedClients() {
+		c.conn.Close()
+	}
+	s.wg.Wait()
+	elecLog.Infof("Electrum server shutdown complete")
+}
+
+// electrumListen creates listeners for the passed addresses using the passed
+// function.
+func electrumListen(addrs []string, listenFunc func(string, string) (net.Listener, error)) ([]net.Listener, error) {
+	ipv4ListenAddrs, ipv6ListenAddrs, _, err := parseListeners(addrs)
+	if err != nil {
+		return nil, err
+	}
+	listeners := make([]net.Listener, 0,
+		len(ipv6ListenAddrs)+len(ipv4ListenAddrs))
+	for _, addr := range ipv4ListenAddrs {
+		listener, err := listenFunc("tcp4", addr)
+		if err != nil {
+			elecLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+	for _, addr := range ipv6ListenAddrs {
+		listener, err := listenFunc("tcp6", addr)
+		if err != nil {
+			elecLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// newElectrumServer returns a new Electrum server listening on the plain TCP
+// and TLS addresses specified by the configuration.  The TLS listeners use the
+// certificate and key of the RPC server.
+func newElectrumServer(s *server) (*electrumServer, error) {
+	listeners, err := electrumListen(cfg.ElectrumListeners, net.Listen)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.ElectrumTLSListeners) > 0 {
+		// Generate the TLS cert and key file if both don't already
+		// exist.
+		if !fileExists(cfg.RPCKey) && !fileExists(cfg.RPCCert) {
+			err := genCertPair(cfg.RPCCert, cfg.RPCKey)
+			if err != nil {
+				return nil, err
+			}
+		}
+		keypair, err := tls.LoadX509KeyPair(cfg.RPCCert, cfg.RPCKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig := tls.Config{
+			Certificates: []tls.Certificate{keypair},
+			MinVersion:   tls.VersionTLS12,
+		}
+		tlsListeners, err := electrumListen(cfg.ElectrumTLSListeners,
+			func(net string, laddr string) (net.Listener, error) {
+				return tls.Listen(net, laddr, &tlsConfig)
+			})
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, tlsListeners...)
+	}
+	if len(listeners) == 0 {
+		return nil, errors.New("ELEC: No valid listen address")
+	}
+
+	return &electrumServer{
+		server:    s,
+		listeners: listeners,
+		clients:   make(map[*electrumClient]struct{}),
+		ntfnQueue: make(chan interface{}),
+		ntfns:     make(chan interface{}),
+		quit:      make(chan struct{}),
+	}, nil
+}
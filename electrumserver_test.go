@@ -0,0 +1,80 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+)
+
+// TestElectrumRequests ensures Electrum requests, including batches and
+// malformed ones, are dispatched and answered as expected.
+func TestElectrumRequests(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		request string
+		want    string
+	}{{
+		name:    "server.version",
+		request: `{"jsonrpc":"2.0","id":1,"method":"server.version","params":["wallet","1.4"]}`,
+		want:    `{"jsonrpc":"2.0","id":1,"result":["hcd ` + version() + `","1.4"]}`,
+	}, {
+		name:    "server.ping",
+		request: `{"jsonrpc":"2.0","id":"a","method":"server.ping","params":[]}`,
+		want:    `{"jsonrpc":"2.0","id":"a","result":null}`,
+	}, {
+		name:    "batch",
+		request: `[{"jsonrpc":"2.0","id":1,"method":"server.ping"},{"jsonrpc":"2.0","id":2,"method":"server.ping"}]`,
+		want:    `[{"jsonrpc":"2.0","id":1,"result":null},{"jsonrpc":"2.0","id":2,"result":null}]`,
+	}, {
+		name:    "unknown method",
+		request: `{"jsonrpc":"2.0","id":3,"method":"blockchain.unknown","params":[]}`,
+		want:    `{"jsonrpc":"2.0","id":3,"error":{"code":-32601,"message":"unknown method \"blockchain.unknown\""}}`,
+	}, {
+		name:    "invalid script hash",
+		request: `{"jsonrpc":"2.0","id":4,"method":"blockchain.scripthash.get_history","params":["zz"]}`,
+		want:    `{"jsonrpc":"2.0","id":4,"error":{"code":1,"message":"invalid script hash \"zz\""}}`,
+	}}
+
+	s := &electrumServer{}
+	for _, test := range tests {
+		clientConn, serverConn := net.Pipe()
+		c := &electrumClient{
+			conn:         serverConn,
+			addr:         "pipe",
+			scriptHashes: make(map[chainhash.Hash]string),
+		}
+		go s.handleLine(c, []byte(test.request))
+
+		line, err := bufio.NewReader(clientConn).ReadBytes('\n')
+		clientConn.Close()
+		serverConn.Close()
+		if err != nil {
+			t.Errorf("%s: unexpected read error: %v", test.name, err)
+			continue
+		}
+
+		var got, want interface{}
+		if err := json.Unmarshal(line, &got); err != nil {
+			t.Errorf("%s: unexpected unmarshal error: %v", test.name,
+				err)
+			continue
+		}
+		if err := json.Unmarshal([]byte(test.want), &want); err != nil {
+			t.Fatalf("%s: bad test data: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: mismatched response -- got %s, want %s",
+				test.name, line, test.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+)
+
+// fakeConn wraps one end of an in-memory pipe and reports the configured
+// addresses instead of the pipe addresses since peers require TCP addresses.
+// It is used to test peer handling without having to actually make any real
+// connections.
+type fakeConn struct {
+	net.Conn
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+// LocalAddr returns the localAddr field of the fake connection and satisfies
+// the net.Conn interface.
+func (c *fakeConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+// RemoteAddr returns the remoteAddr field of the fake connection and satisfies
+// the net.Conn interface.
+func (c *fakeConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+// fakeConnPair returns the local and remote ends of a fake connection from
+// the passed remote address.
+func fakeConnPair(remoteAddr *net.TCPAddr) (*fakeConn, *fakeConn) {
+	localAddr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9108}
+	local, remote := net.Pipe()
+	return &fakeConn{Conn: local, localAddr: localAddr, remoteAddr: remoteAddr},
+		&fakeConn{Conn: remote, localAddr: remoteAddr, remoteAddr: localAddr}
+}
@@ -139,7 +139,7 @@ func dcrdMain(serverChan chan<- *server) error {
 	// Drop indexes and exit if requested.
 	//
 	// NOTE: The order is important here because dropping the tx index also
-	// drops the address index since it relies on it.
+	// drops the address indexes since they rely on it.
 	if cfg.DropAddrIndex {
 		if err := indexers.DropAddrIndex(db); err != nil {
 			dcrdLog.Errorf("%v", err)
@@ -148,6 +148,14 @@ func dcrdMain(serverChan chan<- *server) error {
 
 		return nil
 	}
+	if cfg.DropAddrUtxoIndex {
+		if err := indexers.DropAddrUtxoIndex(db); err != nil {
+			dcrdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
 	if cfg.DropTxIndex {
 		if err := indexers.DropTxIndex(db); err != nil {
 			dcrdLog.Errorf("%v", err)
@@ -156,6 +164,38 @@ func dcrdMain(serverChan chan<- *server) error {
 
 		return nil
 	}
+	if cfg.DropSpendIndex {
+		if err := indexers.DropSpendIndex(db); err != nil {
+			dcrdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropTicketHistIndex {
+		if err := indexers.DropTicketHistoryIndex(db); err != nil {
+			dcrdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropBlockStatsIndex {
+		if err := indexers.DropBlockStatsIndex(db); err != nil {
+			dcrdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropScriptHashIndex {
+		if err := indexers.DropScriptHashIndex(db); err != nil {
+			dcrdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
 	if cfg.DropExistsAddrIndex {
 		if err := indexers.DropExistsAddrIndex(db); err != nil {
 			dcrdLog.Errorf("%v", err)
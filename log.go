@@ -66,6 +66,7 @@ var (
 	dcrdLog = backendLog.Logger("HC")
 	chanLog = backendLog.Logger("CHAN")
 	discLog = backendLog.Logger("DISC")
+	elecLog = backendLog.Logger("ELEC")
 	indxLog = backendLog.Logger("INDX")
 	minrLog = backendLog.Logger("MINR")
 	peerLog = backendLog.Logger("PEER")
@@ -99,6 +100,7 @@ var subsystemLoggers = map[string]btclog.Logger{
 	"HC":  dcrdLog,
 	"CHAN": chanLog,
 	"DISC": discLog,
+	"ELEC": elecLog,
 	"INDX": indxLog,
 	"MINR": minrLog,
 	"PEER": peerLog,
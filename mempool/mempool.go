@@ -130,10 +130,20 @@ type Config struct {
 	// This can be nil if the address index is not enabled.
 	AddrIndex *indexers.AddrIndex
 
+	// AddrUtxoIndex defines the optional address utxo index instance to
+	// use for indexing the unconfirmed transactions in the memory pool.
+	// This can be nil if the address utxo index is not enabled.
+	AddrUtxoIndex *indexers.AddrUtxoIndex
+
 	// ExistsAddrIndex defines the optional exists address index instance
 	// to use for indexing the unconfirmed transactions in the memory pool.
 	// This can be nil if the address index is not enabled.
 	ExistsAddrIndex *indexers.ExistsAddrIndex
+
+	// ScriptHashIndex defines the optional script hash index instance to
+	// use for indexing the unconfirmed transactions in the memory pool.
+	// This can be nil if the script hash index is not enabled.
+	ScriptHashIndex *indexers.ScriptHashIndex
 }
 
 // Policy houses the policy (configuration parameters) which is used to
@@ -510,6 +520,18 @@ func (mp *TxPool) HaveTransaction(hash *chainhash.Hash) bool {
 	return haveTx
 }
 
+// CheckSpend returns the transaction in the main pool which spends the passed
+// outpoint or nil if there is none.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) CheckSpend(op wire.OutPoint) *hcutil.Tx {
+	mp.mtx.RLock()
+	txR := mp.outpoints[op]
+	mp.mtx.RUnlock()
+
+	return txR
+}
+
 // haveTransactions returns whether or not the passed transactions already exist
 // in the main pool or in the orphan pool.
 //
@@ -566,6 +588,12 @@ func (mp *TxPool) removeTransaction(tx *hcutil.Tx, removeRedeemers bool) {
 		if mp.cfg.AddrIndex != nil {
 			mp.cfg.AddrIndex.RemoveUnconfirmedTx(txHash)
 		}
+		if mp.cfg.AddrUtxoIndex != nil {
+			mp.cfg.AddrUtxoIndex.RemoveUnconfirmedTx(txHash)
+		}
+		if mp.cfg.ScriptHashIndex != nil {
+			mp.cfg.ScriptHashIndex.RemoveUnconfirmedTx(txHash)
+		}
 
 		// Mark the referenced outpoints as unspent by the pool.
 
@@ -641,9 +669,15 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint,
 	if mp.cfg.AddrIndex != nil {
 		mp.cfg.AddrIndex.AddUnconfirmedTx(tx, utxoView)
 	}
+	if mp.cfg.AddrUtxoIndex != nil {
+		mp.cfg.AddrUtxoIndex.AddUnconfirmedTx(tx, utxoView)
+	}
 	if mp.cfg.ExistsAddrIndex != nil {
 		mp.cfg.ExistsAddrIndex.AddUnconfirmedTx(msgTx)
 	}
+	if mp.cfg.ScriptHashIndex != nil {
+		mp.cfg.ScriptHashIndex.AddUnconfirmedTx(tx, utxoView)
+	}
 }
 
 // checkPoolDoubleSpend checks whether or not the passed transaction is
@@ -1492,6 +1526,19 @@ func (mp *TxPool) TxDescs() []*TxDesc {
 	return descs
 }
 
+// FetchTxDesc returns the descriptor of the transaction with the passed hash
+// in the pool or nil when it is not in the pool.  The descriptor is to be
+// treated as read only.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) FetchTxDesc(txHash *chainhash.Hash) *TxDesc {
+	mp.mtx.RLock()
+	desc := mp.pool[*txHash]
+	mp.mtx.RUnlock()
+
+	return desc
+}
+
 // MiningDescs returns a slice of mining descriptors for all the transactions
 // in the pool.
 //
@@ -510,6 +510,16 @@ func TestSimpleOrphanChain(t *testing.T) {
 			t.Fatalf("IsTransactionInPool: false for accepted tx %v",
 				tx.Hash())
 		}
+
+		// Ensure the outputs spent by the transactions are reported as
+		// spent by them.
+		for _, txIn := range tx.MsgTx().TxIn {
+			spender := harness.txPool.CheckSpend(txIn.PreviousOutPoint)
+			if spender == nil || *spender.Hash() != *tx.Hash() {
+				t.Fatalf("CheckSpend: %v is not spent by accepted "+
+					"tx %v", txIn.PreviousOutPoint, tx.Hash())
+			}
+		}
 	}
 }
 
@@ -0,0 +1,440 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+// Just enough NAT-PMP (RFC 6886) and PCP (RFC 6887) to be able to forward
+// ports.
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coolsnady/hcd/addrmgr"
+	"github.com/coolsnady/hcd/wire"
+)
+
+const (
+	// natpmpPort is the port NAT-PMP and PCP servers listen on.
+	natpmpPort = 5351
+
+	// natpmpVersion and pcpVersion are the protocol versions of NAT-PMP and
+	// PCP, respectively.  PCP servers which also support NAT-PMP answer
+	// requests of either version.
+	natpmpVersion = 0
+	pcpVersion    = 2
+
+	// These constants define the NAT-PMP opcodes used.  Responses have the
+	// natpmpResponseBit set in the opcode.
+	natpmpOpExternalAddress = 0
+	natpmpOpMapUDP          = 1
+	natpmpOpMapTCP          = 2
+	natpmpResponseBit       = 0x80
+
+	// These constants define the PCP opcodes used.
+	pcpOpAnnounce = 0
+	pcpOpMap      = 1
+
+	// natpmpResultSuccess and natpmpResultUnsupportedVersion are the result
+	// codes shared by NAT-PMP and PCP which are handled specially.
+	natpmpResultSuccess            = 0
+	natpmpResultUnsupportedVersion = 1
+
+	// natpmpInitialTimeout is the initial time to wait for a response.  It
+	// doubles with every retransmission as recommended by the RFCs.
+	natpmpInitialTimeout = 250 * time.Millisecond
+
+	// natpmpMaxTries is the maximum number of times a request is sent
+	// before giving up.
+	natpmpMaxTries = 4
+
+	// pcpHeaderLen and pcpMapLen are the lengths of the PCP common header
+	// and MAP opcode payload, respectively.
+	pcpHeaderLen = 24
+	pcpMapLen    = 36
+)
+
+var (
+	// errNATPMPNoResponse is returned when the gateway does not answer.
+	errNATPMPNoResponse = errors.New("no response from NAT-PMP/PCP gateway")
+
+	// errNATPMPUnsupportedVersion is returned when the gateway does not
+	// support the protocol version of the request.
+	errNATPMPUnsupportedVersion = errors.New("unsupported NAT-PMP/PCP " +
+		"version")
+)
+
+// natpmpNAT implements the NAT interface using NAT-PMP or, when the gateway
+// supports it, PCP.
+type natpmpNAT struct {
+	gateway *net.UDPAddr
+	pcp     bool
+	nonce   [12]byte
+
+	mtx        sync.Mutex
+	externalIP net.IP
+	lifetime   time.Duration
+}
+
+// Ensure natpmpNAT implements the NAT interface.
+var _ NAT = (*natpmpNAT)(nil)
+
+// natpmpResultError returns an error describing the passed result code.
+func natpmpResultError(pcp bool, result uint16) error {
+	proto := "NAT-PMP"
+	if pcp {
+		proto = "PCP"
+	}
+	if result == natpmpResultUnsupportedVersion {
+		return errNATPMPUnsupportedVersion
+	}
+	return fmt.Errorf("%s request failed with result code %d", proto,
+		result)
+}
+
+// DiscoverNATPMP determines whether the passed gateway supports PCP or
+// NAT-PMP, in that order of preference, returning a NAT for it if so.
+func DiscoverNATPMP(gateway *net.UDPAddr) (NAT, error) {
+	n := &natpmpNAT{gateway: gateway, pcp: true}
+	if _, err := rand.Read(n.nonce[:]); err != nil {
+		return nil, err
+	}
+
+	// Try PCP first using an announce request.  Gateways which only
+	// support NAT-PMP reply with an unsupported version result.
+	err := n.pcpAnnounce()
+	if err == nil {
+		return n, nil
+	}
+	if err != errNATPMPUnsupportedVersion {
+		return nil, err
+	}
+
+	n.pcp = false
+	if _, err := n.GetExternalAddress(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// request sends the passed request to the gateway and returns the first
+// response which is accepted by the passed function.  The request is
+// retransmitted with an exponentially increasing timeout until
+// natpmpMaxTries is reached.
+func (n *natpmpNAT) request(req []byte, accept func(resp []byte) bool) ([]byte, error) {
+	conn, err := net.DialUDP("udp", nil, n.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	resp := make([]byte, 1100)
+	timeout := natpmpInitialTimeout
+	for i := 0; i < natpmpMaxTries; i++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(timeout)
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		for {
+			nr, err := conn.Read(resp)
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			if accept(resp[:nr]) {
+				return resp[:nr], nil
+			}
+		}
+		timeout *= 2
+	}
+	return nil, errNATPMPNoResponse
+}
+
+// localIP returns the local IP address used to reach the gateway.  It is
+// included in PCP requests.
+func (n *natpmpNAT) localIP() (net.IP, error) {
+	conn, err := net.DialUDP("udp", nil, n.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.To16(), nil
+}
+
+// pcpHeader returns a PCP request with the common header filled in for the
+// passed opcode and lifetime and room for a payload of the passed length.
+func (n *natpmpNAT) pcpHeader(opcode byte, lifetime uint32, payloadLen int) ([]byte, error) {
+	ip, err := n.localIP()
+	if err != nil {
+		return nil, err
+	}
+	req := make([]byte, pcpHeaderLen+payloadLen)
+	req[0] = pcpVersion
+	req[1] = opcode
+	binary.BigEndian.PutUint32(req[4:8], lifetime)
+	copy(req[8:24], ip)
+	return req, nil
+}
+
+// parseResponse returns the result code of the passed response to a request
+// with the passed version and opcode or false when it is not a response to
+// such a request.  A NAT-PMP unsupported version response is accepted for PCP
+// requests since that is how NAT-PMP only gateways answer them.
+func parseResponse(resp []byte, version, opcode byte) (uint16, bool) {
+	if len(resp) < 4 {
+		return 0, false
+	}
+	if version == pcpVersion && resp[0] == natpmpVersion {
+		result := binary.BigEndian.Uint16(resp[2:4])
+		return result, result == natpmpResultUnsupportedVersion
+	}
+	if resp[0] != version || resp[1] != opcode|natpmpResponseBit {
+		return 0, false
+	}
+	if version == pcpVersion {
+		if len(resp) < pcpHeaderLen {
+			return 0, false
+		}
+		return uint16(resp[3]), true
+	}
+	return binary.BigEndian.Uint16(resp[2:4]), true
+}
+
+// pcpAnnounce sends a PCP announce request which is used to determine whether
+// the gateway supports PCP.
+func (n *natpmpNAT) pcpAnnounce() error {
+	req, err := n.pcpHeader(pcpOpAnnounce, 0, 0)
+	if err != nil {
+		return err
+	}
+	var result uint16
+	_, err = n.request(req, func(resp []byte) bool {
+		var ok bool
+		result, ok = parseResponse(resp, pcpVersion, pcpOpAnnounce)
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+	if result != natpmpResultSuccess {
+		return natpmpResultError(true, result)
+	}
+	return nil
+}
+
+// GetExternalAddress implements the NAT interface by fetching the external IP
+// from the gateway.  PCP has no dedicated request for the external address, so
+// the address assigned by the most recent port mapping is returned instead.
+func (n *natpmpNAT) GetExternalAddress() (net.IP, error) {
+	if n.pcp {
+		n.mtx.Lock()
+		ip := n.externalIP
+		n.mtx.Unlock()
+		if ip == nil {
+			return nil, errors.New("no PCP port mapping established")
+		}
+		return ip, nil
+	}
+
+	req := []byte{natpmpVersion, natpmpOpExternalAddress}
+	var result uint16
+	resp, err := n.request(req, func(resp []byte) bool {
+		var ok bool
+		result, ok = parseResponse(resp, natpmpVersion,
+			natpmpOpExternalAddress)
+		return ok && (result != natpmpResultSuccess || len(resp) >= 12)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result != natpmpResultSuccess {
+		return nil, natpmpResultError(false, result)
+	}
+	ip := net.IPv4(resp[8], resp[9], resp[10], resp[11])
+	n.mtx.Lock()
+	n.externalIP = ip
+	n.mtx.Unlock()
+	return ip, nil
+}
+
+// mapPort requests a mapping from the passed external port to the passed
+// internal port for the passed lifetime in seconds.  A lifetime of 0 deletes
+// the mapping.  It returns the mapped external port.
+func (n *natpmpNAT) mapPort(protocol string, externalPort, internalPort int, lifetime uint32) (int, error) {
+	var natpmpOp, pcpProto byte
+	switch strings.ToLower(protocol) {
+	case "tcp":
+		natpmpOp, pcpProto = natpmpOpMapTCP, 6
+	case "udp":
+		natpmpOp, pcpProto = natpmpOpMapUDP, 17
+	default:
+		return 0, fmt.Errorf("unsupported protocol %q", protocol)
+	}
+
+	if !n.pcp {
+		req := make([]byte, 12)
+		req[0] = natpmpVersion
+		req[1] = natpmpOp
+		binary.BigEndian.PutUint16(req[4:6], uint16(internalPort))
+		binary.BigEndian.PutUint16(req[6:8], uint16(externalPort))
+		binary.BigEndian.PutUint32(req[8:12], lifetime)
+		var result uint16
+		resp, err := n.request(req, func(resp []byte) bool {
+			var ok bool
+			result, ok = parseResponse(resp, natpmpVersion, natpmpOp)
+			if !ok || result != natpmpResultSuccess {
+				return ok
+			}
+			return len(resp) >= 16 && binary.BigEndian.Uint16(
+				resp[8:10]) == uint16(internalPort)
+		})
+		if err != nil {
+			return 0, err
+		}
+		if result != natpmpResultSuccess {
+			return 0, natpmpResultError(false, result)
+		}
+		n.mtx.Lock()
+		n.lifetime = time.Duration(binary.BigEndian.Uint32(resp[12:16])) *
+			time.Second
+		n.mtx.Unlock()
+		return int(binary.BigEndian.Uint16(resp[10:12])), nil
+	}
+
+	req, err := n.pcpHeader(pcpOpMap, lifetime, pcpMapLen)
+	if err != nil {
+		return 0, err
+	}
+	payload := req[pcpHeaderLen:]
+	copy(payload[0:12], n.nonce[:])
+	payload[12] = pcpProto
+	binary.BigEndian.PutUint16(payload[16:18], uint16(internalPort))
+	binary.BigEndian.PutUint16(payload[18:20], uint16(externalPort))
+	copy(payload[20:36], net.IPv4zero.To16())
+	var result uint16
+	resp, err := n.request(req, func(resp []byte) bool {
+		var ok bool
+		result, ok = parseResponse(resp, pcpVersion, pcpOpMap)
+		if !ok || result != natpmpResultSuccess {
+			return ok
+		}
+		if len(resp) < pcpHeaderLen+pcpMapLen {
+			return false
+		}
+		respPayload := resp[pcpHeaderLen:]
+		return string(respPayload[0:12]) == string(n.nonce[:])
+	})
+	if err != nil {
+		return 0, err
+	}
+	if result != natpmpResultSuccess {
+		return 0, natpmpResultError(true, result)
+	}
+	respPayload := resp[pcpHeaderLen:]
+	n.mtx.Lock()
+	n.lifetime = time.Duration(binary.BigEndian.Uint32(resp[4:8])) *
+		time.Second
+	if lifetime != 0 {
+		n.externalIP = net.IP(append([]byte(nil), respPayload[20:36]...))
+	}
+	n.mtx.Unlock()
+	return int(binary.BigEndian.Uint16(respPayload[18:20])), nil
+}
+
+// AddPortMapping implements the NAT interface by requesting a port mapping
+// from the gateway.  The description is not supported by NAT-PMP and PCP and
+// is therefore ignored.
+func (n *natpmpNAT) AddPortMapping(protocol string, externalPort, internalPort int, description string, timeout int) (int, error) {
+	return n.mapPort(protocol, externalPort, internalPort, uint32(timeout))
+}
+
+// DeletePortMapping implements the NAT interface by requesting the removal of
+// the port mapping from the gateway.
+func (n *natpmpNAT) DeletePortMapping(protocol string, externalPort, internalPort int) error {
+	_, err := n.mapPort(protocol, externalPort, internalPort, 0)
+	return err
+}
+
+// leaseLifetime returns the lifetime the gateway granted for the most recent
+// port mapping.  The gateway may grant a shorter lifetime than requested, so
+// the mapping must be renewed accordingly.
+func (n *natpmpNAT) leaseLifetime() time.Duration {
+	n.mtx.Lock()
+	lifetime := n.lifetime
+	n.mtx.Unlock()
+	return lifetime
+}
+
+// String returns the name of the protocol in use.
+func (n *natpmpNAT) String() string {
+	if n.pcp {
+		return "PCP"
+	}
+	return "NAT-PMP"
+}
+
+// defaultGateway returns a best guess at the IPv4 address of the default
+// gateway.  The routing table is used when it is available, otherwise the
+// first address of the network of the first private IPv4 interface address is
+// assumed since that is by far the most common home router configuration.
+func defaultGateway() (net.IP, error) {
+	if f, err := os.Open("/proc/net/route"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 3 || fields[1] != "00000000" {
+				continue
+			}
+			b, err := hex.DecodeString(fields[2])
+			if err != nil || len(b) != 4 {
+				continue
+			}
+			// The gateway is in host byte order, which is little
+			// endian on all platforms providing this file.
+			return net.IPv4(b[3], b[2], b[1], b[0]), nil
+		}
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipNet.IP.To4()
+		if ip == nil {
+			continue
+		}
+		na := wire.NewNetAddressIPPort(ip, 0, 0)
+		if !addrmgr.IsRFC1918(na) {
+			continue
+		}
+		gw := ip.Mask(ipNet.Mask)
+		if gw == nil {
+			continue
+		}
+		gw = gw.To4()
+		gw[3] |= 1
+		return gw, nil
+	}
+	return nil, errors.New("unable to determine default gateway")
+}
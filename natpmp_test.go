@@ -0,0 +1,162 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeGateway is a minimal NAT-PMP and optionally PCP server used to test the
+// client.  It grants every mapping with the external port incremented by one
+// and the lifetime halved.
+type fakeGateway struct {
+	conn       *net.UDPConn
+	pcp        bool
+	externalIP net.IP
+}
+
+// newFakeGateway starts a fake gateway listening on a random localhost port.
+func newFakeGateway(t *testing.T, pcp bool) *fakeGateway {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP: unexpected error: %v", err)
+	}
+	g := &fakeGateway{
+		conn:       conn,
+		pcp:        pcp,
+		externalIP: net.IPv4(203, 0, 113, 7).To4(),
+	}
+	go g.serve()
+	return g
+}
+
+func (g *fakeGateway) addr() *net.UDPAddr {
+	return g.conn.LocalAddr().(*net.UDPAddr)
+}
+
+func (g *fakeGateway) serve() {
+	buf := make([]byte, 1100)
+	for {
+		n, from, err := g.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if resp := g.handle(buf[:n]); resp != nil {
+			g.conn.WriteToUDP(resp, from)
+		}
+	}
+}
+
+func (g *fakeGateway) handle(req []byte) []byte {
+	if len(req) < 2 {
+		return nil
+	}
+	if req[0] == pcpVersion {
+		if !g.pcp {
+			// NAT-PMP only gateways answer with an unsupported
+			// version result in the NAT-PMP format.
+			resp := make([]byte, 8)
+			resp[1] = req[1] | natpmpResponseBit
+			binary.BigEndian.PutUint16(resp[2:4],
+				natpmpResultUnsupportedVersion)
+			return resp
+		}
+		resp := make([]byte, len(req))
+		copy(resp, req)
+		resp[1] |= natpmpResponseBit
+		if req[1] == pcpOpMap {
+			lifetime := binary.BigEndian.Uint32(req[4:8]) / 2
+			binary.BigEndian.PutUint32(resp[4:8], lifetime)
+			payload := resp[pcpHeaderLen:]
+			port := binary.BigEndian.Uint16(payload[18:20])
+			binary.BigEndian.PutUint16(payload[18:20], port+1)
+			copy(payload[20:36], g.externalIP.To16())
+		}
+		copy(resp[8:24], make([]byte, 16))
+		return resp
+	}
+
+	switch req[1] {
+	case natpmpOpExternalAddress:
+		resp := make([]byte, 12)
+		resp[1] = req[1] | natpmpResponseBit
+		copy(resp[8:12], g.externalIP)
+		return resp
+	case natpmpOpMapTCP, natpmpOpMapUDP:
+		resp := make([]byte, 16)
+		resp[1] = req[1] | natpmpResponseBit
+		copy(resp[8:10], req[4:6])
+		port := binary.BigEndian.Uint16(req[6:8])
+		binary.BigEndian.PutUint16(resp[10:12], port+1)
+		lifetime := binary.BigEndian.Uint32(req[8:12]) / 2
+		binary.BigEndian.PutUint32(resp[12:16], lifetime)
+		return resp
+	}
+	return nil
+}
+
+// TestNATPMP ensures gateways supporting PCP and NAT-PMP are discovered with
+// the expected protocol and that port mappings and the external address are
+// handled as expected for both.
+func TestNATPMP(t *testing.T) {
+	tests := []struct {
+		name  string
+		pcp   bool
+		proto string
+	}{
+		{name: "pcp", pcp: true, proto: "PCP"},
+		{name: "natpmp", pcp: false, proto: "NAT-PMP"},
+	}
+
+	for _, test := range tests {
+		g := newFakeGateway(t, test.pcp)
+		nat, err := DiscoverNATPMP(g.addr())
+		if err != nil {
+			g.conn.Close()
+			t.Errorf("%s: DiscoverNATPMP: unexpected error: %v",
+				test.name, err)
+			continue
+		}
+		if got, ok := nat.(*natpmpNAT); !ok || got.String() != test.proto {
+			t.Errorf("%s: unexpected protocol: got %v, want %s",
+				test.name, nat, test.proto)
+		}
+
+		port, err := nat.AddPortMapping("tcp", 9108, 9108, "test", 1200)
+		if err != nil {
+			t.Errorf("%s: AddPortMapping: unexpected error: %v",
+				test.name, err)
+		} else if port != 9109 {
+			t.Errorf("%s: unexpected mapped port: got %d, want %d",
+				test.name, port, 9109)
+		}
+		if got := natRenewInterval(nat); got != 5*time.Minute {
+			t.Errorf("%s: unexpected renew interval: got %v, want %v",
+				test.name, got, 5*time.Minute)
+		}
+
+		ip, err := nat.GetExternalAddress()
+		if err != nil {
+			t.Errorf("%s: GetExternalAddress: unexpected error: %v",
+				test.name, err)
+		} else if !ip.Equal(g.externalIP) {
+			t.Errorf("%s: unexpected external address: got %v, "+
+				"want %v", test.name, ip, g.externalIP)
+		}
+
+		if err := nat.DeletePortMapping("tcp", 9108, 9108); err != nil {
+			t.Errorf("%s: DeletePortMapping: unexpected error: %v",
+				test.name, err)
+		}
+		if _, err := nat.AddPortMapping("sctp", 1, 1, "", 1); err == nil {
+			t.Errorf("%s: AddPortMapping: did not reject unsupported "+
+				"protocol", test.name)
+		}
+		g.conn.Close()
+	}
+}
@@ -7,6 +7,8 @@
 package main
 
 import (
+	"strconv"
+
 	"github.com/coolsnady/hcd/chaincfg"
 	"github.com/coolsnady/hcd/wire"
 )
@@ -19,7 +21,9 @@ var activeNetParams = &mainNetParams
 // network and test networks.
 type params struct {
 	*chaincfg.Params
-	rpcPort string
+	rpcPort         string
+	electrumPort    string
+	electrumTLSPort string
 }
 
 // mainNetParams contains parameters specific to the main network
@@ -29,22 +33,60 @@ type params struct {
 // it does not handle on to hcd.  This approach allows the wallet process
 // to emulate the full reference implementation RPC API.
 var mainNetParams = params{
-	Params:  &chaincfg.MainNetParams,
-	rpcPort: "14009",
+	Params:          &chaincfg.MainNetParams,
+	rpcPort:         "14009",
+	electrumPort:    "14011",
+	electrumTLSPort: "14012",
 }
 
 // testNet2Params contains parameters specific to the test network (version 2)
 // (wire.TestNet2).
 var testNet2Params = params{
-	Params:  &chaincfg.TestNet2Params,
-	rpcPort: "12009",
+	Params:          &chaincfg.TestNet2Params,
+	rpcPort:         "12009",
+	electrumPort:    "12011",
+	electrumTLSPort: "12012",
 }
 
 // simNetParams contains parameters specific to the simulation test network
 // (wire.SimNet).
 var simNetParams = params{
-	Params:  &chaincfg.SimNetParams,
-	rpcPort: "13009",
+	Params:          &chaincfg.SimNetParams,
+	rpcPort:         "13009",
+	electrumPort:    "13011",
+	electrumTLSPort: "13012",
+}
+
+// regNetParams contains parameters specific to the regression test network
+// (wire.RegTest).
+var regNetParams = params{
+	Params:          &chaincfg.RegNetParams,
+	rpcPort:         "18009",
+	electrumPort:    "18011",
+	electrumTLSPort: "18012",
+}
+
+// loadCustomNetParams loads, registers, and returns the parameters for a
+// custom network described by the file at path.  The RPC and Electrum ports
+// for the network are derived from its default peer port using the same
+// offsets as the standard networks.
+func loadCustomNetParams(path string) (*params, error) {
+	chainParams, err := chaincfg.LoadParamsFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The default port was validated while loading the parameters.
+	port, _ := strconv.ParseUint(chainParams.DefaultPort, 10, 16)
+	offsetPort := func(offset uint64) string {
+		return strconv.FormatUint(port+offset, 10)
+	}
+	return &params{
+		Params:          chainParams,
+		rpcPort:         offsetPort(1),
+		electrumPort:    offsetPort(3),
+		electrumTLSPort: offsetPort(4),
+	}, nil
 }
 
 // netName returns the name used when referring to a decred network.  At the
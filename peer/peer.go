@@ -31,6 +31,10 @@ const (
 	// MaxProtocolVersion is the max protocol version the peer supports.
 	MaxProtocolVersion = wire.FeeFilterVersion
 
+	// UnknownMsgCommand is the command under which the bytes of messages
+	// that could not be decoded are accounted in the per-message stats.
+	UnknownMsgCommand = "*other*"
+
 	// outputBufferSize is the number of elements the output channels use.
 	outputBufferSize = 5000
 
@@ -346,6 +350,13 @@ type StatsSnap struct {
 	LastPingNonce  uint64
 	LastPingTime   time.Time
 	LastPingMicros int64
+
+	// BytesSentPerMsg and BytesRecvPerMsg hold the number of bytes sent to
+	// and received from the peer keyed by wire message command.  Bytes
+	// belonging to messages that could not be decoded are accounted under
+	// UnknownMsgCommand.
+	BytesSentPerMsg map[string]uint64
+	BytesRecvPerMsg map[string]uint64
 }
 
 // HashFunc is a function which returns a block hash, height and error
@@ -435,6 +446,12 @@ type Peer struct {
 	lastPingTime       time.Time // Time we sent last ping.
 	lastPingMicros     int64     // Time for last ping to return.
 
+	// These fields track the number of bytes sent and received per wire
+	// message command and are protected by the msgStatsMtx mutex.
+	msgStatsMtx     sync.Mutex
+	bytesSentPerMsg map[string]uint64
+	bytesRecvPerMsg map[string]uint64
+
 	stallControl  chan stallControlMsg
 	outputQueue   chan outMsg
 	sendQueue     chan outMsg
@@ -521,6 +538,9 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 	}
 
 	p.statsMtx.RUnlock()
+
+	statsSnap.BytesSentPerMsg = p.BytesSentPerMsg()
+	statsSnap.BytesRecvPerMsg = p.BytesReceivedPerMsg()
 	return statsSnap
 }
 
@@ -702,6 +722,46 @@ func (p *Peer) BytesReceived() uint64 {
 	return atomic.LoadUint64(&p.bytesReceived)
 }
 
+// BytesSentPerMsg returns a copy of the number of bytes sent to the peer keyed
+// by wire message command.
+//
+// This function is safe for concurrent access.
+func (p *Peer) BytesSentPerMsg() map[string]uint64 {
+	p.msgStatsMtx.Lock()
+	stats := make(map[string]uint64, len(p.bytesSentPerMsg))
+	for cmd, n := range p.bytesSentPerMsg {
+		stats[cmd] = n
+	}
+	p.msgStatsMtx.Unlock()
+
+	return stats
+}
+
+// BytesReceivedPerMsg returns a copy of the number of bytes received from the
+// peer keyed by wire message command.
+//
+// This function is safe for concurrent access.
+func (p *Peer) BytesReceivedPerMsg() map[string]uint64 {
+	p.msgStatsMtx.Lock()
+	stats := make(map[string]uint64, len(p.bytesRecvPerMsg))
+	for cmd, n := range p.bytesRecvPerMsg {
+		stats[cmd] = n
+	}
+	p.msgStatsMtx.Unlock()
+
+	return stats
+}
+
+// MsgCommand returns the command used to account the bytes of the passed
+// message in the per-message statistics.  UnknownMsgCommand is returned when
+// the message is nil, which is the case when it could not be decoded.
+func MsgCommand(msg wire.Message) string {
+	if msg == nil {
+		return UnknownMsgCommand
+	}
+	return msg.Command()
+}
+
 // TimeConnected returns the time at which the peer connected.
 //
 // This function is safe for concurrent access.
@@ -1079,6 +1139,11 @@ func (p *Peer) readMessage() (wire.Message, []byte, error) {
 	n, msg, buf, err := wire.ReadMessageN(p.conn, p.ProtocolVersion(),
 		p.cfg.ChainParams.Net)
 	atomic.AddUint64(&p.bytesReceived, uint64(n))
+	if n > 0 {
+		p.msgStatsMtx.Lock()
+		p.bytesRecvPerMsg[MsgCommand(msg)] += uint64(n)
+		p.msgStatsMtx.Unlock()
+	}
 	if p.cfg.Listeners.OnRead != nil {
 		p.cfg.Listeners.OnRead(p, n, msg, err)
 	}
@@ -1142,6 +1207,11 @@ func (p *Peer) writeMessage(msg wire.Message) error {
 	n, err := wire.WriteMessageN(p.conn, msg, p.ProtocolVersion(),
 		p.cfg.ChainParams.Net)
 	atomic.AddUint64(&p.bytesSent, uint64(n))
+	if n > 0 {
+		p.msgStatsMtx.Lock()
+		p.bytesSentPerMsg[MsgCommand(msg)] += uint64(n)
+		p.msgStatsMtx.Unlock()
+	}
 	if p.cfg.Listeners.OnWrite != nil {
 		p.cfg.Listeners.OnWrite(p, n, msg, err)
 	}
@@ -2037,6 +2107,8 @@ func newPeerBase(cfg *Config, inbound bool) *Peer {
 		cfg:             *cfg, // Copy so caller can't mutate.
 		services:        cfg.Services,
 		protocolVersion: protocolVersion,
+		bytesSentPerMsg: make(map[string]uint64),
+		bytesRecvPerMsg: make(map[string]uint64),
 	}
 	return &p
 }
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"io"
 	"net"
+	"reflect"
 	"strconv"
 	"sync"
 	"testing"
@@ -107,6 +108,7 @@ type peerStats struct {
 	wantTimeOffset      int64
 	wantBytesSent       uint64
 	wantBytesReceived   uint64
+	wantBytesPerMsg     map[string]uint64
 }
 
 // testPeer tests the given peer's flags and stats
@@ -205,6 +207,18 @@ func testPeer(t *testing.T, p *peer.Peer, s peerStats) {
 		t.Errorf("testPeer: wrong LastRecv - got %v, want %v", p.LastRecv(), stats.LastRecv)
 		return
 	}
+
+	if !reflect.DeepEqual(stats.BytesSentPerMsg, s.wantBytesPerMsg) {
+		t.Errorf("testPeer: wrong BytesSentPerMsg - got %v, want %v",
+			stats.BytesSentPerMsg, s.wantBytesPerMsg)
+		return
+	}
+
+	if !reflect.DeepEqual(stats.BytesRecvPerMsg, s.wantBytesPerMsg) {
+		t.Errorf("testPeer: wrong BytesRecvPerMsg - got %v, want %v",
+			stats.BytesRecvPerMsg, s.wantBytesPerMsg)
+		return
+	}
 }
 
 // TestPeerConnection tests connection between inbound and outbound peers.
@@ -243,6 +257,10 @@ func TestPeerConnection(t *testing.T) {
 		wantTimeOffset:      int64(0),
 		wantBytesSent:       158, // 134 version + 24 verack
 		wantBytesReceived:   158,
+		wantBytesPerMsg: map[string]uint64{
+			wire.CmdVersion: 134,
+			wire.CmdVerAck:  24,
+		},
 	}
 	tests := []struct {
 		name  string
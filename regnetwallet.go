@@ -0,0 +1,499 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/blockchain/stake"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/txscript"
+	"github.com/coolsnady/hcd/wire"
+	"github.com/coolsnady/hcutil"
+)
+
+const (
+	// regNetVoteBits are the vote bits of the votes created by the
+	// regression test network wallet.  They approve the regular transaction
+	// tree of the block being voted on and abstain on all agendas.
+	regNetVoteBits = 0x0001
+
+	// regNetTicketFeeLimits are the fee limits committed to by the tickets
+	// purchased by the regression test network wallet.  Votes may not pay
+	// a fee, while revocations may pay up to 2^24 atoms.
+	regNetTicketFeeLimits = 0x5800
+)
+
+var (
+	// regNetOpTrueScript is the redeem script of the pay-to-script-hash
+	// address all funds of the regression test network wallet are paid to.
+	regNetOpTrueScript = []byte{txscript.OP_TRUE}
+
+	// regNetOpTrueSigScript is the signature script which spends outputs
+	// paying to the regression test network wallet by pushing the redeem
+	// script.
+	regNetOpTrueSigScript = []byte{txscript.OP_DATA_1, txscript.OP_TRUE}
+)
+
+// regNetOutput describes an unspent output of the main chain which pays to the
+// regression test network wallet.
+type regNetOutput struct {
+	amount      int64
+	blockHeight int64
+	blockIndex  uint32
+
+	// maturity is the height of the first block which may spend the
+	// output.
+	maturity int64
+}
+
+// regNetTicket describes a ticket of the main chain which was purchased by the
+// regression test network wallet and has not been spent yet.
+type regNetTicket struct {
+	price       int64
+	blockHeight int64
+	blockIndex  uint32
+	commitments []int64
+}
+
+// regNetWallet is a minimal wallet which allows the blocks created by the
+// generate RPC on the regression test network to include votes and ticket
+// purchases, so the stake consensus rules can be exercised end to end without
+// running a separate wallet.
+//
+// The coinbases of the generated blocks as well as the tickets, change and
+// vote rewards of the wallet are all paid to a pay-to-script-hash address of a
+// single OP_TRUE, so no keys are needed.  This means anyone can spend them,
+// which is only acceptable on a private test network.  The wallet does not
+// keep any state on disk.  Instead, it discovers its outputs and tickets by
+// scanning the main chain whenever blocks are generated.  Missed and expired
+// tickets are never revoked.
+type regNetWallet struct {
+	server *server
+
+	// These fields are set when the wallet is created and never change.
+	addr             hcutil.Address
+	pkScript         []byte
+	ticketScript     []byte
+	changeScript     []byte
+	voteRewardScript []byte
+	voteVersion      uint32
+
+	// The following fields track the outputs and tickets of the wallet in
+	// the main chain up to and including the last scanned block.  They are
+	// protected by the mutex.
+	mtx           sync.Mutex
+	scannedHash   chainhash.Hash
+	scannedHeight int64
+	outputs       map[wire.OutPoint]*regNetOutput
+	tickets       map[chainhash.Hash]*regNetTicket
+}
+
+// newRegNetWallet returns a regression test network wallet for the provided
+// server.
+func newRegNetWallet(s *server) (*regNetWallet, error) {
+	addr, err := hcutil.NewAddressScriptHash(regNetOpTrueScript,
+		s.chainParams)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	ticketScript, err := txscript.PayToSStx(addr)
+	if err != nil {
+		return nil, err
+	}
+	changeScript, err := txscript.PayToSStxChange(addr)
+	if err != nil {
+		return nil, err
+	}
+	voteRewardScript, err := txscript.PayToSSGen(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Vote with the most recent version that has deployments so the votes
+	// count towards them.
+	var voteVersion uint32
+	for version := range s.chainParams.Deployments {
+		if version > voteVersion {
+			voteVersion = version
+		}
+	}
+
+	w := &regNetWallet{
+		server:           s,
+		addr:             addr,
+		pkScript:         pkScript,
+		ticketScript:     ticketScript,
+		changeScript:     changeScript,
+		voteRewardScript: voteRewardScript,
+		voteVersion:      voteVersion,
+	}
+	w.reset()
+	return w, nil
+}
+
+// Address returns the address that receives the coinbases of the blocks
+// generated with the wallet.
+func (w *regNetWallet) Address() hcutil.Address {
+	return w.addr
+}
+
+// reset forgets all outputs and tickets so the main chain is scanned again
+// from the genesis block.
+//
+// This function MUST be called with the wallet lock held (for writes).
+func (w *regNetWallet) reset() {
+	w.scannedHash = *w.server.chainParams.GenesisHash
+	w.scannedHeight = 0
+	w.outputs = make(map[wire.OutPoint]*regNetOutput)
+	w.tickets = make(map[chainhash.Hash]*regNetTicket)
+}
+
+// paysToWallet returns whether all commitments of the passed ticket pay to the
+// wallet along with the committed amounts.
+func (w *regNetWallet) paysToWallet(ticket *wire.MsgTx) ([]int64, bool) {
+	isP2SH, hashes, amounts, _, _, _, _ := stake.TxSStxStakeOutputInfo(ticket)
+	walletHash := w.addr.ScriptAddress()
+	for i := range hashes {
+		if !isP2SH[i] || !bytes.Equal(hashes[i], walletHash) {
+			return nil, false
+		}
+	}
+	return amounts, true
+}
+
+// connectBlock updates the outputs and tickets of the wallet with the passed
+// main chain block.
+//
+// This function MUST be called with the wallet lock held (for writes).
+func (w *regNetWallet) connectBlock(block *hcutil.Block) {
+	params := w.server.chainParams
+	height := block.Height()
+	addOutputs := func(tx *hcutil.Tx, tree int8, script []byte, maturity int64) {
+		msgTx := tx.MsgTx()
+		for i, txOut := range msgTx.TxOut {
+			if txOut.Value == 0 || !bytes.Equal(txOut.PkScript, script) {
+				continue
+			}
+			op := wire.OutPoint{Hash: *tx.Hash(), Index: uint32(i), Tree: tree}
+			w.outputs[op] = &regNetOutput{
+				amount:      txOut.Value,
+				blockHeight: height,
+				blockIndex:  uint32(tx.Index()),
+				maturity:    maturity,
+			}
+		}
+	}
+
+	for _, tx := range block.Transactions() {
+		for _, txIn := range tx.MsgTx().TxIn {
+			delete(w.outputs, txIn.PreviousOutPoint)
+		}
+		maturity := height + 1
+		if tx.Index() == 0 {
+			maturity = height + int64(params.CoinbaseMaturity)
+		}
+		addOutputs(tx, wire.TxTreeRegular, w.pkScript, maturity)
+	}
+
+	for _, tx := range block.STransactions() {
+		msgTx := tx.MsgTx()
+		for _, txIn := range msgTx.TxIn {
+			delete(w.outputs, txIn.PreviousOutPoint)
+		}
+
+		switch stake.DetermineTxType(msgTx) {
+		case stake.TxTypeSStx:
+			if !bytes.Equal(msgTx.TxOut[0].PkScript, w.ticketScript) {
+				break
+			}
+			commitments, ok := w.paysToWallet(msgTx)
+			if ok {
+				w.tickets[*tx.Hash()] = &regNetTicket{
+					price:       msgTx.TxOut[0].Value,
+					blockHeight: height,
+					blockIndex:  uint32(tx.Index()),
+					commitments: commitments,
+				}
+			}
+			addOutputs(tx, wire.TxTreeStake, w.changeScript,
+				height+int64(params.SStxChangeMaturity))
+
+		case stake.TxTypeSSGen:
+			// The first input of a vote is the stakebase and the
+			// second one spends the ticket.
+			delete(w.tickets, msgTx.TxIn[1].PreviousOutPoint.Hash)
+			addOutputs(tx, wire.TxTreeStake, w.voteRewardScript,
+				height+int64(params.CoinbaseMaturity))
+
+		case stake.TxTypeSSRtx:
+			delete(w.tickets, msgTx.TxIn[0].PreviousOutPoint.Hash)
+		}
+	}
+}
+
+// sync scans the main chain blocks after the last scanned block.  It starts
+// over from the genesis block when the last scanned block is no longer part of
+// the main chain.
+//
+// This function MUST be called with the wallet lock held (for writes).
+func (w *regNetWallet) sync() error {
+	chain := w.server.blockManager.chain
+	hash, err := chain.BlockHashByHeight(w.scannedHeight)
+	if err != nil || *hash != w.scannedHash {
+		w.reset()
+	}
+
+	best := chain.BestSnapshot()
+	for height := w.scannedHeight + 1; height <= best.Height; height++ {
+		block, err := chain.BlockByHeight(height)
+		if err != nil {
+			return err
+		}
+		w.connectBlock(block)
+		w.scannedHash = *block.Hash()
+		w.scannedHeight = height
+	}
+	return nil
+}
+
+// nullDataScript returns a provably-pruneable OP_RETURN script which pushes the
+// passed data.
+func nullDataScript(data []byte) ([]byte, error) {
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(data).Script()
+}
+
+// createVote returns a vote on the passed block with the given ticket.
+//
+// The vote consists of the following outputs:
+// - First output is an OP_RETURN followed by the block hash and height
+// - Second output is an OP_RETURN followed by the vote bits and version
+// - Third and subsequent outputs pay the ticket commitments along with their
+//   share of the vote subsidy to the wallet
+func (w *regNetWallet) createVote(blockHash *chainhash.Hash, blockHeight int64, ticketHash *chainhash.Hash, ticket *regNetTicket) (*wire.MsgTx, error) {
+	params := w.server.chainParams
+	subsidy := blockchain.CalcStakeVoteSubsidy(
+		w.server.blockManager.chain.FetchSubsidyCache(), blockHeight,
+		params)
+
+	var blockRef [36]byte
+	copy(blockRef[:], blockHash[:])
+	binary.LittleEndian.PutUint32(blockRef[32:], uint32(blockHeight))
+	blockScript, err := nullDataScript(blockRef[:])
+	if err != nil {
+		return nil, err
+	}
+
+	voteData := make([]byte, 6)
+	binary.LittleEndian.PutUint16(voteData, regNetVoteBits)
+	binary.LittleEndian.PutUint32(voteData[2:], w.voteVersion)
+	if w.voteVersion == 0 {
+		voteData = voteData[:2]
+	}
+	voteScript, err := nullDataScript(voteData)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := wire.NewMsgTx()
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex, wire.TxTreeRegular),
+		Sequence:        wire.MaxTxInSequenceNum,
+		ValueIn:         subsidy,
+		BlockHeight:     wire.NullBlockHeight,
+		BlockIndex:      wire.NullBlockIndex,
+		SignatureScript: params.StakeBaseSigScript,
+	})
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(ticketHash, 0,
+			wire.TxTreeStake),
+		Sequence:        wire.MaxTxInSequenceNum,
+		ValueIn:         ticket.price,
+		BlockHeight:     uint32(ticket.blockHeight),
+		BlockIndex:      ticket.blockIndex,
+		SignatureScript: regNetOpTrueSigScript,
+	})
+	tx.AddTxOut(wire.NewTxOut(0, blockScript))
+	tx.AddTxOut(wire.NewTxOut(0, voteScript))
+	rewards := stake.CalculateRewards(ticket.commitments, ticket.price,
+		subsidy)
+	for _, reward := range rewards {
+		tx.AddTxOut(wire.NewTxOut(reward, w.voteRewardScript))
+	}
+	return tx, nil
+}
+
+// createTicket returns a ticket purchase at the given price which spends the
+// passed output and returns the change to the wallet.  The ticket is nil when
+// the output is not large enough to pay for it.
+//
+// The ticket consists of the following outputs:
+// - First output is an OP_SSTX tagged output paying to the wallet
+// - Second output is an OP_RETURN followed by the commitment to the wallet
+// - Third output is an OP_SSTXCHANGE tagged output paying to the wallet
+func (w *regNetWallet) createTicket(op wire.OutPoint, output *regNetOutput, price int64) (*wire.MsgTx, error) {
+	// The size of the commitment does not depend on the amount, so create
+	// a placeholder to determine the size of the ticket and thus its fee.
+	commitScript, err := txscript.GenerateSStxAddrPush(w.addr, 0,
+		regNetTicketFeeLimits)
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx()
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: op,
+		Sequence:         wire.MaxTxInSequenceNum,
+		ValueIn:          output.amount,
+		BlockHeight:      uint32(output.blockHeight),
+		BlockIndex:       output.blockIndex,
+		SignatureScript:  regNetOpTrueSigScript,
+	})
+	tx.AddTxOut(wire.NewTxOut(price, w.ticketScript))
+	tx.AddTxOut(wire.NewTxOut(0, commitScript))
+	tx.AddTxOut(wire.NewTxOut(0, w.changeScript))
+
+	minRelayTxFee := int64(cfg.minRelayTxFee)
+	fee := int64(tx.SerializeSize()) * minRelayTxFee / 1000
+	if fee < minRelayTxFee {
+		fee = minRelayTxFee
+	}
+	change := output.amount - price - fee
+	if change < 0 {
+		return nil, nil
+	}
+
+	commitScript, err = txscript.GenerateSStxAddrPush(w.addr,
+		hcutil.Amount(price+fee), regNetTicketFeeLimits)
+	if err != nil {
+		return nil, err
+	}
+	tx.TxOut[1].PkScript = commitScript
+	tx.TxOut[2].Value = change
+	return tx, nil
+}
+
+// submit adds the passed transaction to the memory pool and announces it to
+// the connected peers.
+func (w *regNetWallet) submit(msgTx *wire.MsgTx) error {
+	tx := hcutil.NewTx(msgTx)
+	acceptedTxs, err := w.server.blockManager.ProcessTransaction(tx, false,
+		false, true)
+	if err != nil {
+		return fmt.Errorf("unable to submit %v: %v", tx.Hash(), err)
+	}
+	w.server.AnnounceNewTransactions(acceptedTxs)
+	return nil
+}
+
+// CreateStakeTransactions adds votes for the winning tickets of the wallet and
+// new ticket purchases to the memory pool so they are included in the block
+// after the current best block.
+//
+// The votes are only created from the stake validation height on and tickets
+// are only purchased from the stake enabled height on.  The number of tickets
+// purchased is the maximum allowed per block until the ticket pool reaches its
+// target size and the number of votes per block afterwards.  It is limited by
+// the number of mature outputs of the wallet which are not already spent by a
+// transaction in the memory pool.
+//
+// This function is safe for concurrent access.
+func (w *regNetWallet) CreateStakeTransactions() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if err := w.sync(); err != nil {
+		return err
+	}
+
+	bm := w.server.blockManager
+	mp := w.server.txMemPool
+	params := w.server.chainParams
+	best := bm.chain.BestSnapshot()
+	nextHeight := best.Height + 1
+
+	// Vote with the winning tickets of the wallet.
+	if nextHeight >= params.StakeValidationHeight {
+		winners, _, _, err := bm.chain.LotteryDataForBlock(best.Hash)
+		if err != nil {
+			return err
+		}
+		for i := range winners {
+			ticketHash := &winners[i]
+			ticket, ok := w.tickets[*ticketHash]
+			if !ok {
+				continue
+			}
+			op := wire.OutPoint{Hash: *ticketHash, Tree: wire.TxTreeStake}
+			if spender := mp.CheckSpend(op); spender != nil {
+				// Votes on blocks which were disconnected by a
+				// reorganization are added back to the memory
+				// pool, so replace any vote on a block other
+				// than the current best block.
+				spenderTx := spender.MsgTx()
+				if stake.DetermineTxType(spenderTx) != stake.TxTypeSSGen {
+					continue
+				}
+				votedOn, _, err := stake.SSGenBlockVotedOn(spenderTx)
+				if err != nil || votedOn == *best.Hash {
+					continue
+				}
+				mp.RemoveTransaction(spender, true)
+			}
+			vote, err := w.createVote(best.Hash, best.Height,
+				ticketHash, ticket)
+			if err != nil {
+				return err
+			}
+			if err := w.submit(vote); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Purchase new tickets.
+	if nextHeight < params.StakeEnabledHeight {
+		return nil
+	}
+	price, err := bm.chain.CalcNextRequiredStakeDifficulty()
+	if err != nil {
+		return err
+	}
+	numTickets := int(params.TicketsPerBlock)
+	targetPoolSize := uint32(params.TicketPoolSize) *
+		uint32(params.TicketsPerBlock)
+	if bm.chainState.NextPoolSize() < targetPoolSize {
+		numTickets = int(params.MaxFreshStakePerBlock)
+	}
+	for op, output := range w.outputs {
+		if numTickets == 0 {
+			break
+		}
+		if output.maturity > nextHeight || mp.CheckSpend(op) != nil {
+			continue
+		}
+		ticket, err := w.createTicket(op, output, price)
+		if err != nil {
+			return err
+		}
+		if ticket == nil {
+			continue
+		}
+		if err := w.submit(ticket); err != nil {
+			return err
+		}
+		numTickets--
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coolsnady/hcd/blockchain/stake"
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+	"github.com/coolsnady/hcd/database"
+	_ "github.com/coolsnady/hcd/database/ffldb"
+	"github.com/coolsnady/hcd/mempool"
+)
+
+// newRegNetTestServer starts a regression test network server backed by a
+// database in a temporary directory.  It does not listen for peers or RPC
+// clients.  The returned function stops the server and removes the directory.
+func newRegNetTestServer(t *testing.T) (*server, func()) {
+	t.Helper()
+
+	// The loggers can not be used since the log rotator is not initialized.
+	setLogLevels("off")
+
+	dataDir, err := ioutil.TempDir("", "regnetwallet")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	oldCfg, oldParams := cfg, activeNetParams
+	cfg = &config{
+		DataDir:           dataDir,
+		DbType:            "ffldb",
+		RegNet:            true,
+		DisableListen:     true,
+		DisableRPC:        true,
+		DisableDNSSeed:    true,
+		RelayNonStd:       regNetParams.RelayNonStdTxs,
+		MaxPeers:          defaultMaxPeers,
+		BanDuration:       defaultBanDuration,
+		BanThreshold:      defaultBanThreshold,
+		FreeTxRelayLimit:  defaultFreeTxRelayLimit,
+		BlockMinSize:      defaultBlockMinSize,
+		BlockMaxSize:      defaultBlockMaxSize,
+		BlockPrioritySize: mempool.DefaultBlockPrioritySize,
+		MaxOrphanTxs:      defaultMaxOrphanTransactions,
+		SigCacheMaxSize:   defaultSigCacheMaxSize,
+		minRelayTxFee:     mempool.DefaultMinRelayTxFee,
+	}
+	activeNetParams = &regNetParams
+	restore := func() {
+		cfg, activeNetParams = oldCfg, oldParams
+		os.RemoveAll(dataDir)
+	}
+
+	db, err := database.Create(cfg.DbType, filepath.Join(dataDir, "blocks"),
+		activeNetParams.Net)
+	if err != nil {
+		restore()
+		t.Fatalf("database.Create: unexpected error: %v", err)
+	}
+	s, err := newServer(nil, db, activeNetParams.Params)
+	if err != nil {
+		db.Close()
+		restore()
+		t.Fatalf("newServer: unexpected error: %v", err)
+	}
+	s.Start()
+	return s, func() {
+		s.Stop()
+		s.WaitForShutdown()
+		db.Close()
+		restore()
+	}
+}
+
+// generateBlocks generates the passed number of blocks with the CPU miner of
+// the passed server.  The test fails instead of waiting forever when the blocks
+// can't be generated, which is the case when the wallet does not provide
+// enough votes from the stake validation height on.
+func generateBlocks(t *testing.T, s *server, n uint32) {
+	t.Helper()
+
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := s.cpuMiner.GenerateNBlocks(n)
+		errChan <- err
+	}()
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Fatalf("GenerateNBlocks: unexpected error: %v", err)
+		}
+	case <-time.After(time.Minute):
+		t.Fatalf("timeout generating %d blocks", n)
+	}
+}
+
+// walletStakeTxCounts returns the number of ticket purchases and votes of the
+// regression test network wallet in each block of the main chain.
+func walletStakeTxCounts(t *testing.T, s *server) (map[int64]int, map[int64]int) {
+	t.Helper()
+
+	w := s.regNetWallet
+	chain := s.blockManager.chain
+	tickets := make(map[int64]int)
+	votes := make(map[int64]int)
+	best := chain.BestSnapshot()
+	for height := int64(1); height <= best.Height; height++ {
+		block, err := chain.BlockByHeight(height)
+		if err != nil {
+			t.Fatalf("BlockByHeight(%d): unexpected error: %v",
+				height, err)
+		}
+		for _, stx := range block.MsgBlock().STransactions {
+			switch stake.DetermineTxType(stx) {
+			case stake.TxTypeSStx:
+				if _, ok := w.paysToWallet(stx); ok {
+					tickets[height]++
+				}
+			case stake.TxTypeSSGen:
+				votes[height]++
+			}
+		}
+	}
+	return tickets, votes
+}
+
+// TestRegNetWalletStake ensures the blocks generated on the regression test
+// network include the ticket purchases of the internal wallet from the stake
+// enabled height on and enough of its votes to be valid from the stake
+// validation height on, and that the wallet adds them to the memory pool.
+func TestRegNetWalletStake(t *testing.T) {
+	s, teardown := newRegNetTestServer(t)
+	defer teardown()
+
+	params := s.chainParams
+	numBlocks := params.StakeValidationHeight + 2*int64(params.TicketMaturity)
+	generateBlocks(t, s, uint32(numBlocks))
+
+	chain := s.blockManager.chain
+	best := chain.BestSnapshot()
+	if best.Height != numBlocks {
+		t.Fatalf("unexpected best height %d, want %d", best.Height,
+			numBlocks)
+	}
+	tickets, votes := walletStakeTxCounts(t, s)
+	for height := int64(1); height <= best.Height; height++ {
+		if height < params.StakeEnabledHeight && tickets[height] != 0 {
+			t.Fatalf("block %d purchases %d tickets before the stake "+
+				"enabled height", height, tickets[height])
+		}
+		if height >= params.StakeEnabledHeight && tickets[height] == 0 {
+			t.Fatalf("block %d does not purchase any tickets",
+				height)
+		}
+		minVotes := int(params.TicketsPerBlock/2 + 1)
+		if height >= params.StakeValidationHeight &&
+			votes[height] < minVotes {
+
+			t.Fatalf("block %d only has %d votes, want at least %d",
+				height, votes[height], minVotes)
+		}
+	}
+
+	// Ensure the votes on the current best block and new ticket purchases
+	// are accepted to the memory pool.
+	if err := s.regNetWallet.CreateStakeTransactions(); err != nil {
+		t.Fatalf("CreateStakeTransactions: unexpected error: %v", err)
+	}
+	var numVotes, numTickets int
+	for _, desc := range s.txMemPool.TxDescs() {
+		switch desc.Type {
+		case stake.TxTypeSSGen:
+			numVotes++
+		case stake.TxTypeSStx:
+			numTickets++
+		}
+	}
+	if numVotes < int(params.TicketsPerBlock/2+1) {
+		t.Fatalf("memory pool has %d votes, want at least %d", numVotes,
+			params.TicketsPerBlock/2+1)
+	}
+	if numTickets == 0 {
+		t.Fatal("memory pool does not have any ticket purchases")
+	}
+}
+
+// TestRegNetWalletReorg ensures the regression test network wallet forgets the
+// outputs and tickets of blocks which are no longer part of the main chain
+// after a reorganization, and that it keeps providing the stake transactions
+// for the new main chain.
+func TestRegNetWalletReorg(t *testing.T) {
+	s, teardown := newRegNetTestServer(t)
+	defer teardown()
+
+	params := s.chainParams
+	w := s.regNetWallet
+	chain := s.blockManager.chain
+	generateBlocks(t, s, uint32(params.StakeValidationHeight+4))
+
+	// checkSynced ensures the wallet is synced to the current best block and
+	// tracks exactly the same outputs and tickets as a new wallet which
+	// scans the main chain from scratch.
+	checkSynced := func(desc string) {
+		t.Helper()
+		w.mtx.Lock()
+		defer w.mtx.Unlock()
+		if err := w.sync(); err != nil {
+			t.Fatalf("%s: sync: unexpected error: %v", desc, err)
+		}
+		best := chain.BestSnapshot()
+		if w.scannedHeight != best.Height || w.scannedHash != *best.Hash {
+			t.Fatalf("%s: wallet synced to %v (height %d), want %v "+
+				"(height %d)", desc, w.scannedHash,
+				w.scannedHeight, best.Hash, best.Height)
+		}
+		fresh, err := newRegNetWallet(s)
+		if err != nil {
+			t.Fatalf("%s: newRegNetWallet: unexpected error: %v", desc,
+				err)
+		}
+		if err := fresh.sync(); err != nil {
+			t.Fatalf("%s: sync: unexpected error: %v", desc, err)
+		}
+		if !reflect.DeepEqual(w.outputs, fresh.outputs) {
+			t.Fatalf("%s: wallet tracks %d outputs, want %d", desc,
+				len(w.outputs), len(fresh.outputs))
+		}
+		if !reflect.DeepEqual(w.tickets, fresh.tickets) {
+			t.Fatalf("%s: wallet tracks %d tickets, want %d", desc,
+				len(w.tickets), len(fresh.tickets))
+		}
+	}
+	checkSynced("initial chain")
+
+	// Remember the tickets which were purchased in the blocks that are
+	// about to be disconnected.
+	forkHeight := params.StakeValidationHeight - 2
+	oldTip := chain.BestSnapshot()
+	forkHash, err := chain.BlockHashByHeight(forkHeight)
+	if err != nil {
+		t.Fatalf("BlockHashByHeight: unexpected error: %v", err)
+	}
+	disconnectedTickets := make(map[chainhash.Hash]struct{})
+	for height := forkHeight; height <= oldTip.Height; height++ {
+		block, err := chain.BlockByHeight(height)
+		if err != nil {
+			t.Fatalf("BlockByHeight: unexpected error: %v", err)
+		}
+		for _, stx := range block.STransactions() {
+			if stake.DetermineTxType(stx.MsgTx()) == stake.TxTypeSStx {
+				disconnectedTickets[*stx.Hash()] = struct{}{}
+			}
+		}
+	}
+	if len(disconnectedTickets) == 0 {
+		t.Fatal("no tickets are purchased in the disconnected blocks")
+	}
+
+	// Reorganize to a shorter chain by invalidating a block before the
+	// stake validation height and ensure the wallet starts over.
+	if err := s.blockManager.InvalidateBlock(forkHash); err != nil {
+		t.Fatalf("InvalidateBlock: unexpected error: %v", err)
+	}
+	if height := chain.BestSnapshot().Height; height != forkHeight-1 {
+		t.Fatalf("unexpected best height %d after invalidating block "+
+			"%d", height, forkHeight)
+	}
+	checkSynced("after invalidating a block")
+	for hash := range w.tickets {
+		if _, ok := disconnectedTickets[hash]; ok {
+			t.Fatalf("wallet still tracks ticket %v of a "+
+				"disconnected block", hash)
+		}
+	}
+
+	// Extend the new chain past the height of the old one, which requires
+	// the wallet to vote with the tickets of the new chain, and ensure the
+	// wallet follows it.
+	generateBlocks(t, s, uint32(oldTip.Height-forkHeight+3))
+	newTip := chain.BestSnapshot()
+	if newTip.Height <= oldTip.Height {
+		t.Fatalf("unexpected best height %d, want more than %d",
+			newTip.Height, oldTip.Height)
+	}
+	mainHash, err := chain.BlockHashByHeight(oldTip.Height)
+	if err != nil {
+		t.Fatalf("BlockHashByHeight: unexpected error: %v", err)
+	}
+	if *mainHash == *oldTip.Hash {
+		t.Fatal("old best block is still part of the main chain")
+	}
+	checkSynced("after extending the new chain")
+	_, votes := walletStakeTxCounts(t, s)
+	for height := params.StakeValidationHeight; height <= newTip.Height; height++ {
+		if votes[height] < int(params.TicketsPerBlock/2+1) {
+			t.Fatalf("block %d only has %d votes", height,
+				votes[height])
+		}
+	}
+}
@@ -37,6 +37,7 @@ import (
 
 	"github.com/coolsnady/bitset"
 	"github.com/coolsnady/hcd/blockchain"
+	"github.com/coolsnady/hcd/blockchain/indexers"
 	"github.com/coolsnady/hcd/blockchain/stake"
 	"github.com/coolsnady/hcd/chaincfg"
 	"github.com/coolsnady/hcd/chaincfg/chainec"
@@ -116,6 +117,11 @@ const (
 	// be relayed or mined and thus should only apply in the mempool and/or
 	// possibly the mining code.
 	maxSigOpsPerTx = blockchain.MaxSigOpsPerBlock / 5
+
+	// maxSubsidyScheduleIntervals is the maximum number of subsidy
+	// reduction intervals the getsubsidyschedule RPC returns.  It exceeds
+	// the number of intervals of the subsidy schedule of the main network.
+	maxSubsidyScheduleIntervals = 2000
 )
 
 var (
@@ -178,6 +184,7 @@ type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{},
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
 	"addnode":               handleAddNode,
+	"backupchain":           handleBackupChain,
 	"createrawsstx":         handleCreateRawSStx,
 	"createrawssgentx":      handleCreateRawSSGenTx,
 	"createrawssrtx":        handleCreateRawSSRtx,
@@ -196,12 +203,17 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"existsmempooltxs":      handleExistsMempoolTxs,
 	"generate":              handleGenerate,
 	"getaddednodeinfo":      handleGetAddedNodeInfo,
+	"getaddressbalance":     handleGetAddressBalance,
+	"getaddressdeltas":      handleGetAddressDeltas,
+	"getaddressutxos":       handleGetAddressUtxos,
+	"getbackupinfo":         handleGetBackupInfo,
 	"getbestblock":          handleGetBestBlock,
 	"getbestblockhash":      handleGetBestBlockHash,
 	"getblock":              handleGetBlock,
 	"getblockcount":         handleGetBlockCount,
 	"getblockhash":          handleGetBlockHash,
 	"getblockheader":        handleGetBlockHeader,
+	"getblockstats":         handleGetBlockStats,
 	"getblocksubsidy":       handleGetBlockSubsidy,
 	"getcoinsupply":         handleGetCoinSupply,
 	"getconnectioncount":    handleGetConnectionCount,
@@ -210,22 +222,32 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getgenerate":           handleGetGenerate,
 	"gethashespersec":       handleGetHashesPerSec,
 	"getheaders":            handleGetHeaders,
+	"getindexinfo":          handleGetIndexInfo,
 	"getinfo":               handleGetInfo,
 	"getmempoolinfo":        handleGetMempoolInfo,
 	"getmininginfo":         handleGetMiningInfo,
+	"getnetmsgstats":        handleGetNetMsgStats,
 	"getnettotals":          handleGetNetTotals,
 	"getnetworkhashps":      handleGetNetworkHashPS,
 	"getpeerinfo":           handleGetPeerInfo,
 	"getrawmempool":         handleGetRawMempool,
 	"getrawtransaction":     handleGetRawTransaction,
+	"getspentinfo":          handleGetSpentInfo,
 	"getstakedifficulty":    handleGetStakeDifficulty,
 	"getstakeversioninfo":   handleGetStakeVersionInfo,
 	"getstakeversions":      handleGetStakeVersions,
+	"getsubsidyschedule":    handleGetSubsidySchedule,
+	"getticketinfo":         handleGetTicketInfo,
 	"getticketpoolvalue":    handleGetTicketPoolValue,
 	"getvoteinfo":           handleGetVoteInfo,
+	"getvoteprobability":    handleGetVoteProbability,
 	"gettxout":              handleGetTxOut,
+	"gettxspendingprevout":  handleGetTxSpendingPrevOut,
 	"getwork":               handleGetWork,
+	"forcedeployment":       handleForceDeployment,
 	"help":                  handleHelp,
+	"invalidateblock":       handleInvalidateBlock,
+	"listticketsbystatus":   handleListTicketsByStatus,
 	"livetickets":           handleLiveTickets,
 	"missedtickets":         handleMissedTickets,
 	"node":                  handleNode,
@@ -233,6 +255,7 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"searchrawtransactions": handleSearchRawTransactions,
 	"rebroadcastmissed":     handleRebroadcastMissed,
 	"rebroadcastwinners":    handleRebroadcastWinners,
+	"reconsiderblock":       handleReconsiderBlock,
 	"sendrawtransaction":    handleSendRawTransaction,
 	"setgenerate":           handleSetGenerate,
 	"stop":                  handleStop,
@@ -243,6 +266,7 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"txfeeinfo":             handleTxFeeInfo,
 	"validateaddress":       handleValidateAddress,
 	"verifychain":           handleVerifyChain,
+	"verifylottery":         handleVerifyLottery,
 	"verifymessage":         handleVerifyMessage,
 	"verifyblissmessage":    handleVerifyBlissMessage,
 	"version":               handleVersion,
@@ -325,19 +349,30 @@ var rpcLimited = map[string]struct{}{
 	"createrawtransaction":  {},
 	"decoderawtransaction":  {},
 	"decodescript":          {},
+	"getaddressbalance":     {},
+	"getaddressdeltas":      {},
+	"getaddressutxos":       {},
 	"getbestblock":          {},
 	"getbestblockhash":      {},
 	"getblock":              {},
 	"getblockcount":         {},
 	"getblockhash":          {},
+	"getblockstats":         {},
 	"getcurrentnet":         {},
 	"getdifficulty":         {},
+	"getindexinfo":          {},
 	"getinfo":               {},
 	"getnettotals":          {},
 	"getnetworkhashps":      {},
 	"getrawmempool":         {},
 	"getrawtransaction":     {},
+	"getspentinfo":          {},
+	"getsubsidyschedule":    {},
+	"getticketinfo":         {},
 	"gettxout":              {},
+	"gettxspendingprevout":  {},
+	"getvoteprobability":    {},
+	"listticketsbystatus":   {},
 	"searchrawtransactions": {},
 	"sendrawtransaction":    {},
 	"submitblock":           {},
@@ -421,6 +456,27 @@ func rpcNoTxInfoError(txHash *chainhash.Hash) *dcrjson.RPCError {
 			txHash))
 }
 
+// rpcIndexSyncingError returns a nicely formatted RPC error which indicates the
+// provided optional index is not synced with the main chain.  The error states
+// why the index failed to catch up when it did, and that it is still catching
+// up in the background otherwise.
+func (s *rpcServer) rpcIndexSyncingError(indexer indexers.Indexer) *dcrjson.RPCError {
+	if err := s.server.indexManager.IndexError(indexer); err != nil {
+		return rpcInternalError(err.Error(), fmt.Sprintf("The %s "+
+			"failed to catch up", indexer.Name()))
+	}
+	return dcrjson.NewRPCError(dcrjson.ErrRPCIndexSyncing,
+		fmt.Sprintf("The %s is still syncing, try again later",
+			indexer.Name()))
+}
+
+// indexSynced returns whether or not the provided enabled optional index has
+// caught up to the main chain and can therefore be used to serve requests.
+func (s *rpcServer) indexSynced(indexer indexers.Indexer) bool {
+	return s.server.indexManager == nil ||
+		s.server.indexManager.IndexSynced(indexer)
+}
+
 // rpcMiscError is a convenience function for returning a nicely formatted RPC
 // error which indicates there is a unquantifiable error.  Use this sparingly;
 // misc return codes are a cop out.
@@ -615,6 +671,18 @@ func messageToHex(msg wire.Message) (string, error) {
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
+// handleBackupChain implements the backupchain command.
+func handleBackupChain(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*dcrjson.BackupChainCmd)
+
+	err := s.server.backup.start(s.server.db, blockDbPath(cfg.DbType),
+		c.Destination, &s.server.wg, s.server.quit)
+	if err != nil {
+		return nil, rpcMiscError(err.Error())
+	}
+	return nil, nil
+}
+
 // handleCreateRawTransaction handles createrawtransaction commands.
 func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*dcrjson.CreateRawTransactionCmd)
@@ -1543,6 +1611,9 @@ func handleExistsAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 		return nil, rpcInternalError("Exists address index disabled",
 			"Configuration")
 	}
+	if !s.indexSynced(existsAddrIndex) {
+		return nil, s.rpcIndexSyncingError(existsAddrIndex)
+	}
 
 	c := cmd.(*dcrjson.ExistsAddressCmd)
 
@@ -1568,6 +1639,9 @@ func handleExistsAddresses(s *rpcServer, cmd interface{}, closeChan <-chan struc
 		return nil, rpcInternalError("Exists address index disabled",
 			"Configuration")
 	}
+	if !s.indexSynced(existsAddrIndex) {
+		return nil, s.rpcIndexSyncingError(existsAddrIndex)
+	}
 
 	c := cmd.(*dcrjson.ExistsAddressesCmd)
 	addresses := make([]hcutil.Address, len(c.Addresses))
@@ -1732,8 +1806,9 @@ func handleExistsMempoolTxs(s *rpcServer, cmd interface{}, closeChan <-chan stru
 // handleGenerate handles generate commands.
 func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if there are no addresses to pay the
-	// created blocks to.
-	if len(cfg.miningAddrs) == 0 {
+	// created blocks to.  The blocks pay to the internal wallet on the
+	// regression test network.
+	if len(cfg.miningAddrs) == 0 && s.server.regNetWallet == nil {
 		return nil, rpcInternalError("No payment addresses specified "+
 			"via --miningaddr", "Configuration")
 	}
@@ -1764,6 +1839,39 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	return reply, nil
 }
 
+// handleForceDeployment implements the forcedeployment command.
+func handleForceDeployment(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*dcrjson.ForceDeploymentCmd)
+	if s.server.chainParams.Net != wire.RegTest {
+		return nil, rpcRuleError("Deployment states can only be forced " +
+			"on the regression test network")
+	}
+
+	var err error
+	switch c.State {
+	case "active":
+		err = s.chain.ForceDeploymentState(c.AgendaID,
+			blockchain.ThresholdActive)
+	case "failed":
+		err = s.chain.ForceDeploymentState(c.AgendaID,
+			blockchain.ThresholdFailed)
+	case "clear":
+		err = s.chain.ClearDeploymentState(c.AgendaID)
+	default:
+		return nil, rpcInvalidError("Invalid state %q -- expected "+
+			"active, failed or clear", c.State)
+	}
+	switch err.(type) {
+	case nil:
+		return nil, nil
+	case blockchain.DeploymentError:
+		return nil, rpcInvalidError("Unknown agenda %q", c.AgendaID)
+	case blockchain.RuleError:
+		return nil, rpcRuleError("%v", err)
+	}
+	return nil, rpcInternalError(err.Error(), "Failed to force deployment")
+}
+
 // handleGetAddedNodeInfo handles getaddednodeinfo commands.
 func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*dcrjson.GetAddedNodeInfoCmd)
@@ -1844,6 +1952,282 @@ func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return results, nil
 }
 
+// addrUtxoIndexPaging returns the number of entries to skip and the number of
+// entries requested by the optional paging parameters of the address utxo and
+// ticket history index commands.
+func addrUtxoIndexPaging(skip, count *int) (uint32, uint32) {
+	numRequested := 100
+	if count != nil {
+		numRequested = *count
+		if numRequested < 0 {
+			numRequested = 1
+		}
+	}
+	var numToSkip int
+	if skip != nil {
+		numToSkip = *skip
+		if numToSkip < 0 {
+			numToSkip = 0
+		}
+	}
+	return uint32(numToSkip), uint32(numRequested)
+}
+
+// stakeTxTypeString returns the name of the passed transaction type as used in
+// the results of the address utxo index commands.
+func stakeTxTypeString(txType stake.TxType) string {
+	switch txType {
+	case stake.TxTypeSStx:
+		return "ticket"
+	case stake.TxTypeSSGen:
+		return "vote"
+	case stake.TxTypeSSRtx:
+		return "revocation"
+	}
+	return "regular"
+}
+
+// handleGetAddressBalance implements the getaddressbalance command.
+func handleGetAddressBalance(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	addrUtxoIndex := s.server.addrUtxoIndex
+	if addrUtxoIndex == nil {
+		return nil, rpcInternalError("Address utxo index must be "+
+			"enabled (--addrutxoindex)", "Configuration")
+	}
+	if !s.indexSynced(addrUtxoIndex) {
+		return nil, s.rpcIndexSyncingError(addrUtxoIndex)
+	}
+
+	c := cmd.(*dcrjson.GetAddressBalanceCmd)
+	var balance, received, unconfirmed int64
+	seen := make(map[string]struct{}, len(c.Addresses))
+	for _, address := range c.Addresses {
+		// Avoid counting the same address more than once.
+		if _, ok := seen[address]; ok {
+			continue
+		}
+		seen[address] = struct{}{}
+
+		addr, err := hcutil.DecodeAddress(address)
+		if err != nil {
+			return nil, rpcAddressKeyError("Could not decode "+
+				"address: %v", err)
+		}
+
+		addrBalance, err := addrUtxoIndex.BalanceForAddress(addr)
+		if err != nil {
+			context := "Failed to load address utxo index entries"
+			return nil, rpcInternalError(err.Error(), context)
+		}
+		balance += addrBalance.Balance
+		received += addrBalance.Received
+
+		for _, delta := range addrUtxoIndex.UnconfirmedDeltasForAddress(addr) {
+			unconfirmed += delta.Amount
+		}
+	}
+
+	return &dcrjson.GetAddressBalanceResult{
+		Balance:     hcutil.Amount(balance).ToCoin(),
+		Received:    hcutil.Amount(received).ToCoin(),
+		Unconfirmed: hcutil.Amount(unconfirmed).ToCoin(),
+	}, nil
+}
+
+// handleGetAddressDeltas implements the getaddressdeltas command.
+func handleGetAddressDeltas(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	addrUtxoIndex := s.server.addrUtxoIndex
+	if addrUtxoIndex == nil {
+		return nil, rpcInternalError("Address utxo index must be "+
+			"enabled (--addrutxoindex)", "Configuration")
+	}
+	if !s.indexSynced(addrUtxoIndex) {
+		return nil, s.rpcIndexSyncingError(addrUtxoIndex)
+	}
+
+	c := cmd.(*dcrjson.GetAddressDeltasCmd)
+	addr, err := hcutil.DecodeAddress(c.Address)
+	if err != nil {
+		return nil, rpcAddressKeyError("Could not decode address: %v",
+			err)
+	}
+	numToSkip, numRequested := addrUtxoIndexPaging(c.Skip, c.Count)
+
+	// Fetch the changes from the main chain first followed by those caused
+	// by unconfirmed transactions in the mempool as needed depending on
+	// the requested counts.
+	deltas, numSkipped, err := addrUtxoIndex.DeltasForAddress(addr,
+		numToSkip, numRequested)
+	if err != nil {
+		context := "Failed to load address utxo index entries"
+		return nil, rpcInternalError(err.Error(), context)
+	}
+	if uint32(len(deltas)) < numRequested {
+		mpDeltas := addrUtxoIndex.UnconfirmedDeltasForAddress(addr)
+		mpToSkip := numToSkip - numSkipped
+		if mpToSkip > uint32(len(mpDeltas)) {
+			mpToSkip = uint32(len(mpDeltas))
+		}
+		for _, delta := range mpDeltas[mpToSkip:] {
+			if uint32(len(deltas)) >= numRequested {
+				break
+			}
+			deltas = append(deltas, delta)
+		}
+	}
+
+	best := s.chain.BestSnapshot()
+	results := make([]dcrjson.AddressDeltaResult, 0, len(deltas))
+	for _, delta := range deltas {
+		var confirmations int64
+		if delta.Height != 0 {
+			confirmations = 1 + best.Height - delta.Height
+		}
+		results = append(results, dcrjson.AddressDeltaResult{
+			TxID:          delta.TxHash.String(),
+			Tree:          delta.Tree,
+			Index:         delta.Index,
+			Spend:         delta.Spend,
+			Amount:        hcutil.Amount(delta.Amount).ToCoin(),
+			Height:        delta.Height,
+			Confirmations: confirmations,
+		})
+	}
+
+	return results, nil
+}
+
+// handleGetAddressUtxos implements the getaddressutxos command.
+func handleGetAddressUtxos(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	addrUtxoIndex := s.server.addrUtxoIndex
+	if addrUtxoIndex == nil {
+		return nil, rpcInternalError("Address utxo index must be "+
+			"enabled (--addrutxoindex)", "Configuration")
+	}
+	if !s.indexSynced(addrUtxoIndex) {
+		return nil, s.rpcIndexSyncingError(addrUtxoIndex)
+	}
+
+	c := cmd.(*dcrjson.GetAddressUtxosCmd)
+	addr, err := hcutil.DecodeAddress(c.Address)
+	if err != nil {
+		return nil, rpcAddressKeyError("Could not decode address: %v",
+			err)
+	}
+	numToSkip, numRequested := addrUtxoIndexPaging(c.Skip, c.Count)
+
+	// Outputs spent by transactions in the mempool are not reported since
+	// they are no longer available.  They are excluded before paging so
+	// they are not counted by the number to skip either.
+	deltas := addrUtxoIndex.UnconfirmedDeltasForAddress(addr)
+	spentInMempool := make(map[wire.OutPoint]struct{})
+	for _, delta := range deltas {
+		if !delta.Spend {
+			continue
+		}
+		tx, err := s.server.txMemPool.FetchTransaction(&delta.TxHash,
+			false)
+		if err != nil {
+			// The transaction was removed from the mempool in the
+			// mean time.
+			continue
+		}
+		prevOut := tx.MsgTx().TxIn[delta.Index].PreviousOutPoint
+		spentInMempool[prevOut] = struct{}{}
+	}
+
+	// Fetch the unspent outputs from the main chain first followed by the
+	// outputs created by unconfirmed transactions in the mempool as needed
+	// depending on the requested counts.
+	utxos, numSkipped, err := addrUtxoIndex.UtxosForAddress(addr,
+		numToSkip, numRequested, spentInMempool)
+	if err != nil {
+		context := "Failed to load address utxo index entries"
+		return nil, rpcInternalError(err.Error(), context)
+	}
+	if uint32(len(utxos)) < numRequested {
+		mpToSkip := numToSkip - numSkipped
+		for _, delta := range deltas {
+			if uint32(len(utxos)) >= numRequested {
+				break
+			}
+			if delta.Spend {
+				continue
+			}
+			tx, err := s.server.txMemPool.FetchTransaction(&delta.TxHash,
+				false)
+			if err != nil {
+				// The transaction was removed from the mempool
+				// in the mean time.
+				continue
+			}
+			outPoint := wire.OutPoint{Hash: delta.TxHash,
+				Index: delta.Index, Tree: delta.Tree}
+			if _, ok := spentInMempool[outPoint]; ok {
+				continue
+			}
+			if mpToSkip > 0 {
+				mpToSkip--
+				continue
+			}
+
+			txOut := tx.MsgTx().TxOut[delta.Index]
+			utxos = append(utxos, indexers.AddrUtxo{
+				OutPoint:      outPoint,
+				Amount:        txOut.Value,
+				TxType:        stake.DetermineTxType(tx.MsgTx()),
+				ScriptVersion: txOut.Version,
+				PkScript:      txOut.PkScript,
+			})
+		}
+	}
+
+	best := s.chain.BestSnapshot()
+	results := make([]dcrjson.AddressUtxoResult, 0, len(utxos))
+	for _, utxo := range utxos {
+		var confirmations int64
+		if utxo.Height != 0 {
+			confirmations = 1 + best.Height - utxo.Height
+		}
+		results = append(results, dcrjson.AddressUtxoResult{
+			TxID:          utxo.OutPoint.Hash.String(),
+			Vout:          utxo.OutPoint.Index,
+			Tree:          utxo.OutPoint.Tree,
+			TxType:        stakeTxTypeString(utxo.TxType),
+			Amount:        hcutil.Amount(utxo.Amount).ToCoin(),
+			ScriptPubKey:  hex.EncodeToString(utxo.PkScript),
+			Height:        utxo.Height,
+			Confirmations: confirmations,
+		})
+	}
+
+	return results, nil
+}
+
+// handleGetBackupInfo implements the getbackupinfo command.
+func handleGetBackupInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	info, err := s.server.backup.status()
+	if err != nil {
+		return nil, rpcMiscError(err.Error())
+	}
+
+	result := &dcrjson.BackupInfoResult{
+		Destination:     info.destination,
+		InProgress:      info.inProgress,
+		StartTime:       info.startTime.Unix(),
+		TotalBytes:      info.progress.TotalBytes,
+		CopiedBytes:     info.progress.CopiedBytes,
+		MetadataEntries: info.progress.MetadataEntries,
+	}
+	if !info.inProgress {
+		result.EndTime = info.endTime.Unix()
+	}
+	if info.err != nil {
+		result.Error = info.err.Error()
+	}
+	return result, nil
+}
+
 // handleGetBestBlock implements the getbestblock command.
 func handleGetBestBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// All other "get block" commands give either the height, the hash, or
@@ -2119,6 +2503,176 @@ func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct
 
 }
 
+// blockStatNames are the names of the statistics returned by the getblockstats
+// command.
+var blockStatNames = []string{"blockhash", "height", "time", "size", "txs",
+	"stxs", "ins", "outs", "totalout", "totalfee", "minfeerate", "maxfeerate",
+	"avgfeerate", "feeratepercentiles", "mintxsize", "maxtxsize", "avgtxsize",
+	"votes", "tickets", "revocations", "ticketprice", "poolsize",
+	"powsubsidy", "possubsidy", "devsubsidy"}
+
+// handleGetBlockStats implements the getblockstats command.
+func handleGetBlockStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*dcrjson.GetBlockStatsCmd)
+
+	// Determine the requested statistics, which default to all of them.
+	valid := make(map[string]struct{}, len(blockStatNames))
+	for _, name := range blockStatNames {
+		valid[name] = struct{}{}
+	}
+	requested := valid
+	if c.Stats != nil {
+		requested = make(map[string]struct{}, len(*c.Stats))
+		for _, name := range *c.Stats {
+			if _, ok := valid[name]; !ok {
+				return nil, rpcInvalidError("Invalid statistic %q",
+					name)
+			}
+			requested[name] = struct{}{}
+		}
+	}
+
+	// The block is identified by either its hash or its height in the main
+	// chain.
+	var hash *chainhash.Hash
+	if len(c.HashOrHeight) == chainhash.MaxHashStringSize {
+		var err error
+		hash, err = chainhash.NewHashFromStr(c.HashOrHeight)
+		if err != nil {
+			return nil, rpcDecodeHexError(c.HashOrHeight)
+		}
+	} else {
+		height, err := strconv.ParseInt(c.HashOrHeight, 10, 64)
+		if err != nil {
+			return nil, rpcInvalidError("Invalid block hash or "+
+				"height %q", c.HashOrHeight)
+		}
+		hash, err = s.chain.BlockHashByHeight(height)
+		if err != nil {
+			return nil, &dcrjson.RPCError{
+				Code: dcrjson.ErrRPCOutOfRange,
+				Message: fmt.Sprintf("Block number out of range: %v",
+					height),
+			}
+		}
+	}
+
+	// Use the statistics cached by the block stats index when it is synced
+	// and calculate them from the block otherwise.  Blocks which are not in
+	// the main chain are never cached.
+	var stats *indexers.BlockStats
+	blockStatsIndex := s.server.blockStatsIndex
+	if blockStatsIndex != nil && s.indexSynced(blockStatsIndex) {
+		var err error
+		stats, err = blockStatsIndex.BlockStats(hash)
+		if err != nil {
+			context := "Failed to query block stats index"
+			return nil, rpcInternalError(err.Error(), context)
+		}
+	}
+	if stats == nil {
+		blk, err := s.chain.FetchBlockByHash(hash)
+		if err != nil {
+			return nil, &dcrjson.RPCError{
+				Code:    dcrjson.ErrRPCBlockNotFound,
+				Message: fmt.Sprintf("Block not found: %v", hash),
+			}
+		}
+		stats = indexers.CalcBlockStats(blk, s.chain.FetchSubsidyCache(),
+			s.server.chainParams)
+	}
+
+	isRequested := func(name string) bool {
+		_, ok := requested[name]
+		return ok
+	}
+	toCoin := func(amount int64) *float64 {
+		return dcrjson.Float64(hcutil.Amount(amount).ToCoin())
+	}
+	var result dcrjson.GetBlockStatsResult
+	if isRequested("blockhash") {
+		result.BlockHash = dcrjson.String(hash.String())
+	}
+	if isRequested("height") {
+		result.Height = dcrjson.Int64(stats.Height)
+	}
+	if isRequested("time") {
+		result.Time = dcrjson.Int64(stats.Time)
+	}
+	if isRequested("size") {
+		result.Size = dcrjson.Uint32(stats.Size)
+	}
+	if isRequested("txs") {
+		result.Txs = dcrjson.Uint32(stats.Txs)
+	}
+	if isRequested("stxs") {
+		result.STxs = dcrjson.Uint32(stats.STxs)
+	}
+	if isRequested("ins") {
+		result.Ins = dcrjson.Uint32(stats.Ins)
+	}
+	if isRequested("outs") {
+		result.Outs = dcrjson.Uint32(stats.Outs)
+	}
+	if isRequested("totalout") {
+		result.TotalOut = toCoin(stats.TotalOut)
+	}
+	if isRequested("totalfee") {
+		result.TotalFee = toCoin(stats.TotalFee)
+	}
+	if isRequested("minfeerate") {
+		result.MinFeeRate = toCoin(stats.MinFeeRate)
+	}
+	if isRequested("maxfeerate") {
+		result.MaxFeeRate = toCoin(stats.MaxFeeRate)
+	}
+	if isRequested("avgfeerate") {
+		result.AvgFeeRate = toCoin(stats.AvgFeeRate)
+	}
+	if isRequested("feeratepercentiles") {
+		result.FeeRatePercentiles = make([]float64, 0,
+			len(stats.FeeRatePercentiles))
+		for _, feeRate := range stats.FeeRatePercentiles {
+			result.FeeRatePercentiles = append(result.FeeRatePercentiles,
+				hcutil.Amount(feeRate).ToCoin())
+		}
+	}
+	if isRequested("mintxsize") {
+		result.MinTxSize = dcrjson.Uint32(stats.MinTxSize)
+	}
+	if isRequested("maxtxsize") {
+		result.MaxTxSize = dcrjson.Uint32(stats.MaxTxSize)
+	}
+	if isRequested("avgtxsize") {
+		result.AvgTxSize = dcrjson.Uint32(stats.AvgTxSize)
+	}
+	if isRequested("votes") {
+		result.Votes = dcrjson.Uint32(uint32(stats.Votes))
+	}
+	if isRequested("tickets") {
+		result.Tickets = dcrjson.Uint32(uint32(stats.Tickets))
+	}
+	if isRequested("revocations") {
+		result.Revocations = dcrjson.Uint32(uint32(stats.Revocations))
+	}
+	if isRequested("ticketprice") {
+		result.TicketPrice = toCoin(stats.TicketPrice)
+	}
+	if isRequested("poolsize") {
+		result.PoolSize = dcrjson.Uint32(stats.PoolSize)
+	}
+	if isRequested("powsubsidy") {
+		result.PoWSubsidy = toCoin(stats.PoWSubsidy)
+	}
+	if isRequested("possubsidy") {
+		result.PoSSubsidy = toCoin(stats.PoSSubsidy)
+	}
+	if isRequested("devsubsidy") {
+		result.DevSubsidy = toCoin(stats.DevSubsidy)
+	}
+	return result, nil
+}
+
 // handleGetBlockSubsidy implements the getblocksubsidy command.
 func handleGetBlockSubsidy(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*dcrjson.GetBlockSubsidyCmd)
@@ -2931,7 +3485,7 @@ func handleGetBlockTemplateRequest(s *rpcServer, request *dcrjson.TemplateReques
 	// way to relay a found block or receive transactions to work on.
 	// However, allow this state when running in the regression test or
 	// simulation test mode.
-	if !cfg.SimNet && s.server.ConnectedCount() == 0 {
+	if !cfg.SimNet && !cfg.RegNet && s.server.ConnectedCount() == 0 {
 		return nil, &dcrjson.RPCError{
 			Code:    dcrjson.ErrRPCClientNotConnected,
 			Message: "Hcd is not connected",
@@ -2987,6 +3541,8 @@ func chainErrToGBTErrString(err error) string {
 	switch ruleErr.ErrorCode {
 	case blockchain.ErrDuplicateBlock:
 		return "duplicate"
+	case blockchain.ErrMissingParent:
+		return "orphan"
 	case blockchain.ErrBlockTooBig:
 		return "bad-block-size"
 	case blockchain.ErrBlockVersionTooOld:
@@ -3009,6 +3565,8 @@ func chainErrToGBTErrString(err error) string {
 		return "bad-checkpoint"
 	case blockchain.ErrForkTooOld:
 		return "fork-too-old"
+	case blockchain.ErrLowWorkFork:
+		return "low-work-fork"
 	case blockchain.ErrCheckpointTimeTooOld:
 		return "checkpoint-time-too-old"
 	case blockchain.ErrNoTransactions:
@@ -3097,7 +3655,7 @@ func handleGetBlockTemplateProposal(s *rpcServer, request *dcrjson.TemplateReque
 	}
 
 	flags := blockchain.BFDryRun | blockchain.BFNoPoWCheck
-	isOrphan, err := s.server.blockManager.ProcessBlock(block, flags)
+	err = s.server.blockManager.ProcessBlock(block, flags)
 	if err != nil {
 		if _, ok := err.(blockchain.RuleError); !ok {
 			errStr := fmt.Sprintf("Failed to process block "+
@@ -3110,9 +3668,6 @@ func handleGetBlockTemplateProposal(s *rpcServer, request *dcrjson.TemplateReque
 		rpcsLog.Infof("Rejected block proposal: %v", err)
 		return chainErrToGBTErrString(err), nil
 	}
-	if isOrphan {
-		return "orphan", nil
-	}
 
 	return nil, nil
 }
@@ -3241,6 +3796,31 @@ func handleGetHeaders(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return &dcrjson.GetHeadersResult{Headers: hexBlockHeaders}, nil
 }
 
+// handleGetIndexInfo implements the getindexinfo command.
+func handleGetIndexInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*dcrjson.GetIndexInfoCmd)
+
+	result := make(map[string]dcrjson.IndexInfoResult)
+	if s.server.indexManager == nil {
+		return result, nil
+	}
+	for _, info := range s.server.indexManager.IndexInfo() {
+		if c.Index != nil && *c.Index != info.Name {
+			continue
+		}
+		var errStr string
+		if info.Err != nil {
+			errStr = info.Err.Error()
+		}
+		result[info.Name] = dcrjson.IndexInfoResult{
+			Synced:          info.Synced,
+			BestBlockHeight: info.Height,
+			Error:           errStr,
+		}
+	}
+	return result, nil
+}
+
 // handleGetInfo implements the getinfo command. We only return the fields
 // that are not related to wallet functionality.
 func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
@@ -3319,13 +3899,68 @@ func handleGetMiningInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 	return &result, nil
 }
 
+// handleGetNetMsgStats implements the getnetmsgstats command.
+func handleGetNetMsgStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*dcrjson.GetNetMsgStatsCmd)
+
+	// Use the totals across all peers since start unless the stats of a
+	// specific connected peer were requested.
+	var sent, recv map[string]uint64
+	if c.PeerID == nil {
+		sent, recv = s.server.NetMsgTotals()
+	} else {
+		var found bool
+		for _, p := range s.server.Peers() {
+			if p.ID() == *c.PeerID {
+				sent = p.BytesSentPerMsg()
+				recv = p.BytesReceivedPerMsg()
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, rpcInvalidError("Peer %d not found", *c.PeerID)
+		}
+	}
+
+	commands := make([]string, 0, len(sent)+len(recv))
+	for command := range sent {
+		commands = append(commands, command)
+	}
+	for command := range recv {
+		if _, ok := sent[command]; !ok {
+			commands = append(commands, command)
+		}
+	}
+	sort.Strings(commands)
+
+	stats := make([]dcrjson.NetMsgStatsResult, 0, len(commands))
+	for _, command := range commands {
+		stats = append(stats, dcrjson.NetMsgStatsResult{
+			Command:   command,
+			BytesSent: sent[command],
+			BytesRecv: recv[command],
+		})
+	}
+	return stats, nil
+}
+
 // handleGetNetTotals implements the getnettotals command.
 func handleGetNetTotals(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	totalBytesRecv, totalBytesSent := s.server.NetTotals()
+	uploadTarget := s.server.uploadTarget.Status()
 	reply := &dcrjson.GetNetTotalsResult{
 		TotalBytesRecv: totalBytesRecv,
 		TotalBytesSent: totalBytesSent,
 		TimeMillis:     time.Now().UTC().UnixNano() / int64(time.Millisecond),
+		UploadTarget: dcrjson.UploadTargetResult{
+			TimeFrame:             int64(uploadTarget.Timeframe / time.Second),
+			Target:                uploadTarget.Target,
+			TargetReached:         uploadTarget.TargetReached,
+			ServeHistoricalBlocks: uploadTarget.ServeHistoricalBlocks,
+			BytesLeftInCycle:      uploadTarget.BytesLeftInCycle,
+			TimeLeftInCycle:       int64(uploadTarget.TimeLeftInCycle / time.Second),
+		},
 	}
 	return reply, nil
 }
@@ -3464,6 +4099,9 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 			CurrentHeight:  statsSnap.LastBlock,
 			BanScore:       int32(p.banScore.Int()),
 			SyncNode:       p == syncPeer,
+
+			BytesSentPerMsg: statsSnap.BytesSentPerMsg,
+			BytesRecvPerMsg: statsSnap.BytesRecvPerMsg,
 		}
 		if p.LastPingNonce() != 0 {
 			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
@@ -3551,6 +4189,9 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 				"must be enabled to query the blockchain "+
 				"(specify --txindex)", "Configuration")
 		}
+		if !s.indexSynced(txIndex) {
+			return nil, s.rpcIndexSyncingError(txIndex)
+		}
 
 		// Look up the location of the transaction.
 		blockRegion, err := txIndex.TxBlockRegion(*txHash)
@@ -3656,6 +4297,65 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 	return *rawTxn, nil
 }
 
+// spendTrees returns the transaction trees to search for an output given the
+// optional tree parameter of the spend related commands.  Both trees are
+// searched when the tree is not specified.
+func spendTrees(tree *int8) ([]int8, error) {
+	if tree == nil {
+		return []int8{wire.TxTreeRegular, wire.TxTreeStake}, nil
+	}
+	if *tree != wire.TxTreeRegular && *tree != wire.TxTreeStake {
+		return nil, rpcInvalidError("Tree must be %d (regular) or "+
+			"%d (stake)", wire.TxTreeRegular, wire.TxTreeStake)
+	}
+	return []int8{*tree}, nil
+}
+
+// handleGetSpentInfo implements the getspentinfo command.
+func handleGetSpentInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	spendIndex := s.server.spendIndex
+	if spendIndex == nil {
+		return nil, rpcInternalError("Spend index disabled",
+			"Configuration")
+	}
+	if !s.indexSynced(spendIndex) {
+		return nil, s.rpcIndexSyncingError(spendIndex)
+	}
+
+	c := cmd.(*dcrjson.GetSpentInfoCmd)
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+	trees, err := spendTrees(c.Tree)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tree := range trees {
+		outpoint := wire.NewOutPoint(txHash, c.Index, tree)
+		info, err := spendIndex.SpendInfo(outpoint)
+		if err != nil {
+			context := "Failed to query spend index"
+			return nil, rpcInternalError(err.Error(), context)
+		}
+		if info == nil {
+			continue
+		}
+
+		return &dcrjson.GetSpentInfoResult{
+			TxID:      info.TxHash.String(),
+			Index:     info.InputIndex,
+			BlockHash: info.BlockHash.String(),
+			Height:    info.BlockHeight,
+		}, nil
+	}
+
+	return nil, dcrjson.NewRPCError(dcrjson.ErrRPCNoTxInfo,
+		fmt.Sprintf("Output %v:%d is not spent in the main chain",
+			txHash, c.Index))
+}
+
 // handleGetStakeDifficulty implements the getstakedifficulty command.
 func handleGetStakeDifficulty(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	best := s.chain.BestSnapshot()
@@ -3818,6 +4518,181 @@ func handleGetStakeVersions(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return result, nil
 }
 
+// handleGetSubsidySchedule implements the getsubsidyschedule command.
+func handleGetSubsidySchedule(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*dcrjson.GetSubsidyScheduleCmd)
+	params := s.server.chainParams
+
+	cache := s.chain.FetchSubsidyCache()
+	if cache == nil {
+		return nil, rpcInternalError("empty subsidy cache", "")
+	}
+
+	best := s.chain.BestSnapshot()
+	startHeight := best.Height
+	if c.StartHeight != nil {
+		startHeight = *c.StartHeight
+	}
+	if startHeight < 0 {
+		return nil, rpcInvalidError("Start height must not be negative")
+	}
+	count := *c.Count
+	if count < 1 || count > maxSubsidyScheduleIntervals {
+		return nil, rpcInvalidError("Count must be between 1 and %d",
+			maxSubsidyScheduleIntervals)
+	}
+	if c.Heights != nil {
+		for _, height := range *c.Heights {
+			if height < 0 {
+				return nil, rpcInvalidError("Height %d must not "+
+					"be negative", height)
+			}
+		}
+	}
+
+	// Project the subsidy of the requested reduction intervals starting
+	// with the one containing the start height.  The schedule ends early
+	// once no more subsidy is created.
+	//
+	// The subsidy cache is shared with consensus, so the start height is
+	// clamped to the interval in which the subsidy reaches zero in order to
+	// avoid adding arbitrary iterations to it.
+	interval := params.SubsidyReductionInterval
+	voters := params.TicketsPerBlock
+	endIteration := cache.SubsidyEndIteration()
+	first := startHeight / interval
+	if first > endIteration {
+		first = endIteration
+	}
+	before := blockchain.CalcSubsidySum(cache, 0, first*interval-1, params)
+	supply := before.Total()
+	intervals := make([]dcrjson.SubsidyScheduleInterval, 0, count)
+	for i := first; i < first+int64(count) && i <= endIteration; i++ {
+		low, high := i*interval, (i+1)*interval-1
+		sum := blockchain.CalcSubsidySum(cache, low, high, params)
+		if sum.Total() == 0 {
+			break
+		}
+		supply += sum.Total()
+		intervals = append(intervals, dcrjson.SubsidyScheduleInterval{
+			Interval:    i,
+			StartHeight: low,
+			EndHeight:   high,
+			PoW: blockchain.CalcBlockWorkSubsidy(cache, low, voters,
+				params),
+			PoS: blockchain.CalcStakeVoteSubsidy(cache, low,
+				params) * int64(voters),
+			Developer: blockchain.CalcBlockTaxSubsidy(cache, low,
+				voters, params),
+			TotalPoW:       sum.Work,
+			TotalPoS:       sum.Stake,
+			TotalDeveloper: sum.Tax,
+			Total:          sum.Total(),
+			Supply:         supply,
+		})
+	}
+
+	var supplies []dcrjson.SubsidyScheduleSupply
+	if c.Heights != nil {
+		supplies = make([]dcrjson.SubsidyScheduleSupply, 0,
+			len(*c.Heights))
+		for _, height := range *c.Heights {
+			sum := blockchain.CalcSubsidySum(cache, 0, height, params)
+			supplies = append(supplies, dcrjson.SubsidyScheduleSupply{
+				Height: height,
+				Supply: sum.Total(),
+			})
+		}
+	}
+
+	// The total subsidy of the chain only includes the coinbase of a block
+	// once the next block approves it, so the coinbase of the best block is
+	// excluded from the theoretical supply.  The remaining difference is
+	// caused by missed votes, which reduce the stake subsidy as well as the
+	// proof of work and developer subsidies, and by disapproved blocks.
+	theoretical := blockchain.CalcSubsidySum(cache, 0, best.Height, params)
+	bestBlock := blockchain.CalcSubsidySum(cache, best.Height, best.Height,
+		params)
+	theoreticalSupply := theoretical.Total() - bestBlock.BlockOne -
+		bestBlock.Work - bestBlock.Tax
+
+	return &dcrjson.GetSubsidyScheduleResult{
+		Height:            best.Height,
+		ReductionInterval: interval,
+		Intervals:         intervals,
+		Supply:            supplies,
+		ActualSupply:      best.TotalSubsidy,
+		TheoreticalSupply: theoreticalSupply,
+		MissedSubsidy:     theoreticalSupply - best.TotalSubsidy,
+	}, nil
+}
+
+// ticketInfoResult returns the result of the ticket history index commands for
+// the passed ticket history given the height of the current best block.
+func ticketInfoResult(history *indexers.TicketHistory, bestHeight int64, params *chaincfg.Params) *dcrjson.TicketInfoResult {
+	maturityHeight := history.PurchaseHeight + int64(params.TicketMaturity)
+	result := &dcrjson.TicketInfoResult{
+		Hash:           history.Hash.String(),
+		Price:          hcutil.Amount(history.Price).ToCoin(),
+		PurchaseBlock:  history.PurchaseBlock.String(),
+		PurchaseHeight: history.PurchaseHeight,
+		MaturityHeight: maturityHeight,
+		ExpiryHeight:   maturityHeight + int64(params.TicketExpiry),
+		MissedHeight:   history.MissedHeight,
+	}
+
+	switch history.Status {
+	case indexers.TicketUnspent:
+		result.Status = "live"
+		if bestHeight < maturityHeight {
+			result.Status = "immature"
+		}
+	case indexers.TicketVoted:
+		result.Status = "voted"
+		result.VoteTxID = history.SpendTxHash.String()
+		result.VoteHeight = history.SpendHeight
+	case indexers.TicketMissed:
+		result.Status = "missed"
+	case indexers.TicketExpired:
+		result.Status = "expired"
+	case indexers.TicketRevoked:
+		result.Status = "revoked"
+		result.RevocationTxID = history.SpendTxHash.String()
+		result.RevocationHeight = history.SpendHeight
+	}
+	return result
+}
+
+// handleGetTicketInfo implements the getticketinfo command.
+func handleGetTicketInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	ticketHistoryIndex := s.server.ticketHistoryIndex
+	if ticketHistoryIndex == nil {
+		return nil, rpcInternalError("Ticket history index must be "+
+			"enabled (--tickethistoryindex)", "Configuration")
+	}
+	if !s.indexSynced(ticketHistoryIndex) {
+		return nil, s.rpcIndexSyncingError(ticketHistoryIndex)
+	}
+
+	c := cmd.(*dcrjson.GetTicketInfoCmd)
+	ticketHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+	history, err := ticketHistoryIndex.TicketHistory(ticketHash)
+	if err != nil {
+		context := "Failed to query ticket history index"
+		return nil, rpcInternalError(err.Error(), context)
+	}
+	if history == nil {
+		return nil, dcrjson.NewRPCError(dcrjson.ErrRPCNoTxInfo,
+			fmt.Sprintf("No ticket %v in the main chain", ticketHash))
+	}
+
+	best := s.chain.BestSnapshot()
+	return ticketInfoResult(history, best.Height, s.server.chainParams), nil
+}
+
 // handleGetTicketPoolValue implements the getticketpoolvalue command.
 func handleGetTicketPoolValue(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	amt, err := s.server.blockManager.TicketPoolValue()
@@ -3934,6 +4809,78 @@ func handleGetVoteInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return result, nil
 }
 
+// votePercentiles are the probabilities for which the getvoteprobability
+// command reports the number of blocks within which a ticket votes.
+var votePercentiles = []float64{0.25, 0.5, 0.75, 0.9, 0.95, 0.99}
+
+// handleGetVoteProbability implements the getvoteprobability command.
+func handleGetVoteProbability(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*dcrjson.GetVoteProbabilityCmd)
+	params := s.server.chainParams
+
+	// Default to the size of the ticket pool the winners of the next block
+	// are drawn from.
+	var poolSize uint32
+	if c.PoolSize != nil {
+		poolSize = *c.PoolSize
+	} else {
+		best := s.chain.BestSnapshot()
+		_, size, _, err := s.chain.LotteryDataForBlock(best.Hash)
+		if err != nil {
+			return nil, rpcInternalError(err.Error(),
+				"Could not obtain ticket pool size")
+		}
+		poolSize = uint32(size)
+	}
+	if poolSize == 0 {
+		return nil, rpcInvalidError("Pool size must be greater than zero")
+	}
+
+	expiry := params.TicketExpiry
+	blocks := expiry
+	if c.Blocks != nil {
+		blocks = *c.Blocks
+	}
+
+	// A ticket can't vote after it expires, so the probability of voting
+	// within more blocks than the expiry is the same as within the expiry.
+	withinBlocks := blocks
+	if withinBlocks > expiry {
+		withinBlocks = expiry
+	}
+
+	blockSeconds := params.TargetTimePerBlock.Seconds()
+	p := stake.VoteProbability(int(poolSize), params.TicketsPerBlock)
+	expected := stake.ExpectedVoteBlocks(p, expiry)
+	result := &dcrjson.VoteProbabilityResult{
+		PoolSize:          poolSize,
+		TicketsPerBlock:   params.TicketsPerBlock,
+		TicketExpiry:      expiry,
+		BlockProbability:  p,
+		Blocks:            blocks,
+		VoteProbability:   stake.VoteWithinProbability(p, withinBlocks),
+		ExpiryProbability: 1 - stake.VoteWithinProbability(p, expiry),
+		ExpectedBlocks:    expected,
+		ExpectedSeconds:   int64(expected * blockSeconds),
+		Percentiles: make([]dcrjson.VoteProbabilityPercentile, 0,
+			len(votePercentiles)),
+	}
+	for _, target := range votePercentiles {
+		n := stake.BlocksForVoteProbability(p, target)
+		if n > expiry {
+			break
+		}
+		result.Percentiles = append(result.Percentiles,
+			dcrjson.VoteProbabilityPercentile{
+				Probability: target,
+				Blocks:      n,
+				Seconds:     int64(float64(n) * blockSeconds),
+			})
+	}
+
+	return result, nil
+}
+
 // bigToLEUint256 returns the passed big integer as an unsigned 256-bit integer
 // encoded as little-endian bytes.  Numbers which are larger than the max
 // unsigned 256-bit integer are truncated.
@@ -4069,6 +5016,86 @@ func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	return txOutReply, nil
 }
 
+// handleGetTxSpendingPrevOut implements the gettxspendingprevout command.
+func handleGetTxSpendingPrevOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*dcrjson.GetTxSpendingPrevOutCmd)
+
+	// Parse all of the outputs up front so no work is done when any of them
+	// are invalid.
+	type prevOut struct {
+		hash  *chainhash.Hash
+		index uint32
+		trees []int8
+	}
+	prevOuts := make([]prevOut, 0, len(c.Outputs))
+	for _, output := range c.Outputs {
+		txHash, err := chainhash.NewHashFromStr(output.TxID)
+		if err != nil {
+			return nil, rpcDecodeHexError(output.TxID)
+		}
+		trees, err := spendTrees(output.Tree)
+		if err != nil {
+			return nil, err
+		}
+		prevOuts = append(prevOuts, prevOut{txHash, output.Vout, trees})
+	}
+
+	// Spends by transactions in the mempool take precedence since they
+	// are the most recent.  Spends in the main chain are only reported
+	// when the spend index is enabled.
+	spendIndex := s.server.spendIndex
+	if spendIndex != nil && !s.indexSynced(spendIndex) {
+		return nil, s.rpcIndexSyncingError(spendIndex)
+	}
+	results := make([]dcrjson.TxSpendingPrevOutResult, 0, len(prevOuts))
+	for _, po := range prevOuts {
+		result := dcrjson.TxSpendingPrevOutResult{
+			TxID: po.hash.String(),
+			Vout: po.index,
+			Tree: po.trees[0],
+		}
+
+	search:
+		for _, tree := range po.trees {
+			outpoint := wire.NewOutPoint(po.hash, po.index, tree)
+			if tx := s.server.txMemPool.CheckSpend(*outpoint); tx != nil {
+				for i, txIn := range tx.MsgTx().TxIn {
+					if txIn.PreviousOutPoint != *outpoint {
+						continue
+					}
+					vin := uint32(i)
+					result.Tree = tree
+					result.SpendingTxID = tx.Hash().String()
+					result.SpendingVin = &vin
+					break search
+				}
+			}
+
+			if spendIndex == nil {
+				continue
+			}
+			info, err := spendIndex.SpendInfo(outpoint)
+			if err != nil {
+				context := "Failed to query spend index"
+				return nil, rpcInternalError(err.Error(), context)
+			}
+			if info != nil {
+				vin := info.InputIndex
+				result.Tree = tree
+				result.SpendingTxID = info.TxHash.String()
+				result.SpendingVin = &vin
+				result.BlockHash = info.BlockHash.String()
+				result.BlockHeight = info.BlockHeight
+				break search
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
 // pruneOldBlockTemplates prunes all old block templates from the templatePool
 // map. Must be called with the RPC workstate locked to avoid races to the map.
 func pruneOldBlockTemplates(s *rpcServer, bestHeight int64) {
@@ -4340,9 +5367,8 @@ func handleGetWorkSubmission(s *rpcServer, hexData string) (interface{}, error)
 
 	// Process this block using the same rules as blocks coming from other
 	// nodes.  This will in turn relay it to the network like normal.
-	isOrphan, err := s.server.blockManager.ProcessBlock(block,
-		blockchain.BFNone)
-	if err != nil || isOrphan {
+	err = s.server.blockManager.ProcessBlock(block, blockchain.BFNone)
+	if err != nil {
 		// Anything other than a rule violation is an unexpected error,
 		// so return that error as an internal error.
 		if _, ok := err.(blockchain.RuleError); !ok {
@@ -4378,7 +5404,7 @@ func handleGetWork(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 	// way to relay a found block or receive transactions to work on.
 	// However, allow this state when running in the regression test or
 	// simulation test mode.
-	if !cfg.SimNet && s.server.ConnectedCount() == 0 {
+	if !cfg.SimNet && !cfg.RegNet && s.server.ConnectedCount() == 0 {
 		return nil, &dcrjson.RPCError{
 			Code:    dcrjson.ErrRPCClientNotConnected,
 			Message: "Hcd is not connected",
@@ -4448,6 +5474,106 @@ func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return help, nil
 }
 
+// blockValidityError converts an error returned while invalidating or
+// reconsidering the block with the passed hash to an RPC error.
+func blockValidityError(hash *chainhash.Hash, err error) *dcrjson.RPCError {
+	ruleErr, ok := err.(blockchain.RuleError)
+	if !ok {
+		context := "Failed to change block validity"
+		return rpcInternalError(err.Error(), context)
+	}
+	if ruleErr.ErrorCode == blockchain.ErrUnknownBlock {
+		return &dcrjson.RPCError{
+			Code:    dcrjson.ErrRPCBlockNotFound,
+			Message: fmt.Sprintf("Block not found: %v", hash),
+		}
+	}
+	return rpcRuleError("%v", err)
+}
+
+// handleInvalidateBlock implements the invalidateblock command.
+func handleInvalidateBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*dcrjson.InvalidateBlockCmd)
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+
+	err = s.server.blockManager.InvalidateBlock(hash)
+	if err != nil {
+		return nil, blockValidityError(hash, err)
+	}
+
+	return nil, nil
+}
+
+// handleListTicketsByStatus implements the listticketsbystatus command.
+func handleListTicketsByStatus(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	ticketHistoryIndex := s.server.ticketHistoryIndex
+	if ticketHistoryIndex == nil {
+		return nil, rpcInternalError("Ticket history index must be "+
+			"enabled (--tickethistoryindex)", "Configuration")
+	}
+	if !s.indexSynced(ticketHistoryIndex) {
+		return nil, s.rpcIndexSyncingError(ticketHistoryIndex)
+	}
+
+	// Immature and live tickets are both unspent according to the index, so
+	// they are told apart by the height the tickets mature at.
+	c := cmd.(*dcrjson.ListTicketsByStatusCmd)
+	best := s.chain.BestSnapshot()
+	params := s.server.chainParams
+	var status indexers.TicketStatus
+	var filter func(*indexers.TicketHistory) bool
+	switch c.Status {
+	case "immature", "live":
+		status = indexers.TicketUnspent
+		wantMature := c.Status == "live"
+		filter = func(history *indexers.TicketHistory) bool {
+			maturityHeight := history.PurchaseHeight +
+				int64(params.TicketMaturity)
+			return (best.Height >= maturityHeight) == wantMature
+		}
+	case "voted":
+		status = indexers.TicketVoted
+	case "missed":
+		status = indexers.TicketMissed
+	case "expired":
+		status = indexers.TicketExpired
+	case "revoked":
+		status = indexers.TicketRevoked
+	default:
+		return nil, rpcInvalidError("Invalid ticket status %q -- must "+
+			"be one of immature, live, voted, missed, expired or "+
+			"revoked", c.Status)
+	}
+
+	var addr hcutil.Address
+	if c.Address != nil {
+		var err error
+		addr, err = hcutil.DecodeAddress(*c.Address)
+		if err != nil {
+			return nil, rpcAddressKeyError("Could not decode "+
+				"address: %v", err)
+		}
+	}
+	numToSkip, numRequested := addrUtxoIndexPaging(c.Skip, c.Count)
+
+	histories, _, err := ticketHistoryIndex.TicketsByStatus(status, addr,
+		filter, numToSkip, numRequested)
+	if err != nil {
+		context := "Failed to load ticket history index entries"
+		return nil, rpcInternalError(err.Error(), context)
+	}
+
+	results := make([]dcrjson.TicketInfoResult, 0, len(histories))
+	for i := range histories {
+		results = append(results, *ticketInfoResult(&histories[i],
+			best.Height, params))
+	}
+	return results, nil
+}
+
 // handleLiveTickets implements the livetickets command.
 func handleLiveTickets(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	lt, err := s.server.blockManager.chain.LiveTickets()
@@ -4796,6 +5922,22 @@ func fetchMempoolTxnsForAddress(s *rpcServer, addr hcutil.Address, numToSkip, nu
 	return mpTxns[numToSkip:rangeEnd], numToSkip
 }
 
+// handleReconsiderBlock implements the reconsiderblock command.
+func handleReconsiderBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*dcrjson.ReconsiderBlockCmd)
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+
+	err = s.server.blockManager.ReconsiderBlock(hash)
+	if err != nil {
+		return nil, blockValidityError(hash, err)
+	}
+
+	return nil, nil
+}
+
 // handleSearchRawTransactions implements the searchrawtransactions command.
 func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if the address index is not enabled.
@@ -4804,6 +5946,9 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan
 		return nil, rpcInternalError("Address index must be "+
 			"enabled (--addrindex)", "Configuration")
 	}
+	if !s.indexSynced(addrIndex) {
+		return nil, s.rpcIndexSyncingError(addrIndex)
+	}
 
 	// Override the flag for including extra previous output information in
 	// each input if needed.
@@ -4821,6 +5966,9 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan
 		return nil, rpcInternalError("Transaction index must be "+
 			"enabled (--txindex)", "Configuration")
 	}
+	if vinExtra && !s.indexSynced(s.server.txIndex) {
+		return nil, s.rpcIndexSyncingError(s.server.txIndex)
+	}
 
 	// Attempt to decode the supplied address.
 	addr, err := hcutil.DecodeAddress(c.Address)
@@ -5179,7 +6327,7 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 		return nil, rpcInternalError(err.Error(), "Block decode")
 	}
 
-	_, err = s.server.blockManager.ProcessBlock(block, blockchain.BFNone)
+	err = s.server.blockManager.ProcessBlock(block, blockchain.BFNone)
 	if err != nil {
 		return fmt.Sprintf("rejected: %v", err), nil
 	}
@@ -5731,6 +6879,102 @@ func handleVerifyChain(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return err == nil, nil
 }
 
+// handleVerifyLottery implements the verifylottery command.
+func handleVerifyLottery(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*dcrjson.VerifyLotteryCmd)
+
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+	var tickets []chainhash.Hash
+	if c.Tickets != nil {
+		tickets = make([]chainhash.Hash, 0, len(*c.Tickets))
+		for _, ticket := range *c.Tickets {
+			ticketHash, err := chainhash.NewHashFromStr(ticket)
+			if err != nil {
+				return nil, rpcDecodeHexError(ticket)
+			}
+			tickets = append(tickets, *ticketHash)
+		}
+	}
+
+	block, err := s.chain.FetchBlockByHash(hash)
+	if err != nil {
+		return nil, &dcrjson.RPCError{
+			Code:    dcrjson.ErrRPCBlockNotFound,
+			Message: fmt.Sprintf("Block not found: %v", hash),
+		}
+	}
+	verification, err := s.chain.VerifyLottery(hash, tickets)
+	if err != nil {
+		return nil, rpcInternalError(err.Error(),
+			"Could not verify ticket lottery")
+	}
+
+	// Determine which tickets voted in the block.
+	voted := make(map[chainhash.Hash]struct{})
+	for _, stx := range block.MsgBlock().STransactions {
+		if isVote, _ := stake.IsSSGen(stx); isVote {
+			voted[stx.TxIn[1].PreviousOutPoint.Hash] = struct{}{}
+		}
+	}
+
+	lottery := verification.Lottery
+	selected := make(map[chainhash.Hash]struct{}, len(lottery.Winners))
+	winners := make([]dcrjson.LotteryWinner, 0, len(lottery.Winners))
+	for i, winner := range lottery.Winners {
+		_, hasVoted := voted[winner]
+		winners = append(winners, dcrjson.LotteryWinner{
+			Ticket: winner.String(),
+			Index:  lottery.Indexes[i],
+			Voted:  hasVoted,
+		})
+		selected[winner] = struct{}{}
+	}
+
+	var ticketResults []dcrjson.LotteryTicket
+	if len(tickets) > 0 {
+		ticketResults = make([]dcrjson.LotteryTicket, 0, len(tickets))
+		for i, ticket := range tickets {
+			_, isSelected := selected[ticket]
+			_, hasVoted := voted[ticket]
+			ticketResults = append(ticketResults, dcrjson.LotteryTicket{
+				Ticket:   ticket.String(),
+				Eligible: verification.Live[i],
+				Selected: isSelected,
+				Voted:    hasVoted,
+			})
+		}
+	}
+
+	// The lottery is only valid when the recomputed winners and final
+	// state match those recorded when the parent was connected and the
+	// header commits to the recomputed pool size and final state.
+	valid := uint32(lottery.PoolSize) == verification.HeaderPoolSize &&
+		lottery.FinalState == verification.HeaderFinalState &&
+		lottery.FinalState == verification.StoredFinalState &&
+		len(lottery.Winners) == len(verification.StoredWinners)
+	for i := 0; valid && i < len(lottery.Winners); i++ {
+		valid = lottery.Winners[i] == verification.StoredWinners[i]
+	}
+
+	return &dcrjson.VerifyLotteryResult{
+		Hash:             hash.String(),
+		Height:           verification.Height,
+		ParentHash:       verification.ParentHash.String(),
+		Seed:             lottery.Seed.String(),
+		PoolSize:         lottery.PoolSize,
+		HeaderPoolSize:   verification.HeaderPoolSize,
+		PRNGState:        lottery.StateHash.String(),
+		FinalState:       hex.EncodeToString(lottery.FinalState[:]),
+		HeaderFinalState: hex.EncodeToString(verification.HeaderFinalState[:]),
+		Valid:            valid,
+		Winners:          winners,
+		Tickets:          ticketResults,
+	}, nil
+}
+
 // handleVerifyMessage implements the verifymessage command.
 func handleVerifyMessage(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*dcrjson.VerifyMessageCmd)
@@ -35,6 +35,10 @@ var helpDescsEnUS = map[string]string{
 	"addnode-addr":      "IP address and port of the peer to operate on",
 	"addnode-subcmd":    "'add' to add a persistent peer, 'remove' to remove a persistent peer, or 'onetry' to try a single connection to a peer",
 
+	// BackupChainCmd help.
+	"backupchain--synopsis":   "Starts a backup of the block database to the provided directory in the background.  The backup is a consistent copy of the database at the time the command is issued and the node keeps running while it is written.  Issuing the command again with the same directory resumes a backup which was interrupted or failed.  Use getbackupinfo to monitor the progress.",
+	"backupchain-destination": "The directory to write the backup to",
+
 	// NodeCmd help.
 	"node--synopsis":     "Attempts to add or remove a peer.",
 	"node-subcmd":        "'disconnect' to remove all matching non-persistent peers, 'remove' to remove a persistent peer, or 'connect' to connect to a peer",
@@ -220,6 +224,48 @@ var helpDescsEnUS = map[string]string{
 	"getaddednodeinfo--condition1": "dns=true",
 	"getaddednodeinfo--result0":    "List of added peers",
 
+	// GetAddressBalanceCmd help.
+	"getaddressbalance--synopsis": "Returns the combined balance of the provided addresses along with the total amount they have received and the net change caused by unconfirmed transactions.  Requires the address utxo index (--addrutxoindex).",
+	"getaddressbalance-addresses": "The addresses to return the balance of",
+
+	// GetAddressBalanceResult help.
+	"getaddressbalanceresult-balance":     "The balance of the addresses in the main chain",
+	"getaddressbalanceresult-received":    "The total amount the addresses have received in the main chain",
+	"getaddressbalanceresult-unconfirmed": "The net change to the balance caused by unconfirmed transactions in the mempool",
+
+	// GetAddressDeltasCmd help.
+	"getaddressdeltas--synopsis": "Returns the changes to the balance of an address in the order they appear in the blockchain followed by those caused by unconfirmed transactions in the mempool.  Requires the address utxo index (--addrutxoindex).",
+	"getaddressdeltas-address":   "The address to return the changes for",
+	"getaddressdeltas-skip":      "The number of changes to skip",
+	"getaddressdeltas-count":     "The maximum number of changes to return",
+	"getaddressdeltas--result0":  "The changes to the balance of the address",
+
+	// AddressDeltaResult help.
+	"addressdeltaresult-txid":          "The hash of the transaction which changes the balance",
+	"addressdeltaresult-tree":          "The tree of the transaction",
+	"addressdeltaresult-index":         "The index of the output for credits or the input for spends",
+	"addressdeltaresult-spend":         "Whether or not the change spends an output",
+	"addressdeltaresult-amount":        "The change to the balance in HC, negative for spends",
+	"addressdeltaresult-height":        "The height of the block which contains the transaction (0 for unconfirmed transactions)",
+	"addressdeltaresult-confirmations": "The number of confirmations of the transaction",
+
+	// GetAddressUtxosCmd help.
+	"getaddressutxos--synopsis": "Returns the unspent outputs which pay to an address in the main chain followed by those created by unconfirmed transactions in the mempool.  Outputs spent by unconfirmed transactions are omitted.  Requires the address utxo index (--addrutxoindex).",
+	"getaddressutxos-address":   "The address to return the unspent outputs for",
+	"getaddressutxos-skip":      "The number of unspent outputs to skip, not counting the omitted ones",
+	"getaddressutxos-count":     "The maximum number of unspent outputs to return",
+	"getaddressutxos--result0":  "The unspent outputs which pay to the address",
+
+	// AddressUtxoResult help.
+	"addressutxoresult-txid":          "The hash of the transaction which contains the output",
+	"addressutxoresult-vout":          "The index of the output",
+	"addressutxoresult-tree":          "The tree of the transaction which contains the output",
+	"addressutxoresult-txtype":        "The type of the transaction which contains the output (regular/ticket/vote/revocation)",
+	"addressutxoresult-amount":        "The amount of the output in HC",
+	"addressutxoresult-scriptpubkey":  "The hex-encoded public key script of the output",
+	"addressutxoresult-height":        "The height of the block which contains the output (0 for unconfirmed transactions)",
+	"addressutxoresult-confirmations": "The number of confirmations of the output",
+
 	// GetBestBlockResult help.
 	"getbestblockresult-hash":   "Hex-encoded bytes of the best block hash",
 	"getbestblockresult-height": "Height of the best block",
@@ -339,6 +385,38 @@ var helpDescsEnUS = map[string]string{
 	"getblockheaderverboseresult-stakeroot":         "The merkle root of the stake transaction tree",
 	"getblockheaderverboseresult-stakeversion":      "The stake version of the block",
 
+	// GetBlockStatsCmd help.
+	"getblockstats--synopsis":    "Returns statistics about the transactions, the stake and the subsidy of a block.  The statistics are cached by the block stats index (--blockstatsindex) when it is enabled and calculated from the block otherwise.",
+	"getblockstats-hashorheight": "The hash of the block or its height in the main chain",
+	"getblockstats-stats":        "The statistics to return, all of them by default (blockhash, height, time, size, txs, stxs, ins, outs, totalout, totalfee, minfeerate, maxfeerate, avgfeerate, feeratepercentiles, mintxsize, maxtxsize, avgtxsize, votes, tickets, revocations, ticketprice, poolsize, powsubsidy, possubsidy, devsubsidy)",
+
+	// GetBlockStatsResult help.
+	"getblockstatsresult-blockhash":          "The hash of the block",
+	"getblockstatsresult-height":             "The height of the block",
+	"getblockstatsresult-time":               "The timestamp of the block in seconds since 1 Jan 1970 GMT",
+	"getblockstatsresult-size":               "The size of the block in bytes",
+	"getblockstatsresult-txs":                "The number of regular transactions including the coinbase",
+	"getblockstatsresult-stxs":               "The number of stake transactions",
+	"getblockstatsresult-ins":                "The number of inputs which spend an output, excluding the coinbase and stakebase inputs",
+	"getblockstatsresult-outs":               "The number of outputs",
+	"getblockstatsresult-totalout":           "The total amount of the outputs, excluding the coinbase",
+	"getblockstatsresult-totalfee":           "The total fees paid by the transactions",
+	"getblockstatsresult-minfeerate":         "The lowest fee rate in coins per kilobyte, excluding the coinbase and votes",
+	"getblockstatsresult-maxfeerate":         "The highest fee rate in coins per kilobyte, excluding the coinbase and votes",
+	"getblockstatsresult-avgfeerate":         "The average fee rate in coins per kilobyte, excluding the coinbase and votes",
+	"getblockstatsresult-feeratepercentiles": "The 10th, 25th, 50th, 75th and 90th percentiles of the fee rates in coins per kilobyte",
+	"getblockstatsresult-mintxsize":          "The size of the smallest transaction in bytes, excluding the coinbase",
+	"getblockstatsresult-maxtxsize":          "The size of the largest transaction in bytes, excluding the coinbase",
+	"getblockstatsresult-avgtxsize":          "The average size of the transactions in bytes, excluding the coinbase",
+	"getblockstatsresult-votes":              "The number of votes",
+	"getblockstatsresult-tickets":            "The number of ticket purchases",
+	"getblockstatsresult-revocations":        "The number of revocations",
+	"getblockstatsresult-ticketprice":        "The price of a ticket purchased in the block",
+	"getblockstatsresult-poolsize":           "The size of the live ticket pool as recorded in the block header",
+	"getblockstatsresult-powsubsidy":         "The Proof-of-Work subsidy",
+	"getblockstatsresult-possubsidy":         "The Proof-of-Stake subsidy of all votes",
+	"getblockstatsresult-devsubsidy":         "The developer subsidy",
+
 	// GetBlockSubsidyCmd help.
 	"getblocksubsidy--synopsis": "Returns information regarding subsidy amounts.",
 	"getblocksubsidy-height":    "The block height",
@@ -478,6 +556,27 @@ var helpDescsEnUS = map[string]string{
 	"choice-count":                    "How many votes received.",
 	"choice-progress":                 "Progress of the overall count.",
 
+	// GetVoteProbabilityCmd help.
+	"getvoteprobability--synopsis": "Returns the probability that a live ticket is selected to vote within a number of blocks and the distribution of the time until it votes.\n" +
+		"The ticket pool size is assumed to remain constant.",
+	"getvoteprobability-blocks":   "The number of blocks to return the vote probability for (default: the ticket expiry)",
+	"getvoteprobability-poolsize": "The ticket pool size to calculate the probabilities for (default: the current pool size)",
+
+	// VoteProbabilityResult help.
+	"voteprobabilityresult-poolsize":          "The number of live tickets the winners are drawn from",
+	"voteprobabilityresult-ticketsperblock":   "The number of tickets selected to vote on each block",
+	"voteprobabilityresult-ticketexpiry":      "The number of blocks after which a live ticket expires",
+	"voteprobabilityresult-blockprobability":  "The probability that a live ticket is selected to vote on a given block",
+	"voteprobabilityresult-blocks":            "The number of blocks the vote probability is calculated for",
+	"voteprobabilityresult-voteprobability":   "The probability that a live ticket is selected within the number of blocks, which never exceeds the probability of voting before expiry",
+	"voteprobabilityresult-expiryprobability": "The probability that a live ticket expires without being selected",
+	"voteprobabilityresult-expectedblocks":    "The expected number of blocks until a ticket which votes before it expires is selected",
+	"voteprobabilityresult-expectedseconds":   "The expected number of blocks converted to seconds with the target block time",
+	"voteprobabilityresult-percentiles":       "The number of blocks within which a ticket is selected with common probabilities, omitting those not reached before expiry",
+	"voteprobabilitypercentile-probability":   "The probability of being selected",
+	"voteprobabilitypercentile-blocks":        "The number of blocks within which a ticket is selected with the probability",
+	"voteprobabilitypercentile-seconds":       "The number of blocks converted to seconds with the target block time",
+
 	// GetGenerateCmd help.
 	"getgenerate--synopsis": "Returns if the server is set to generate coins (mine) or not.",
 	"getgenerate--result0":  "True if mining, false if not",
@@ -562,6 +661,50 @@ var helpDescsEnUS = map[string]string{
 	"getnettotalsresult-totalbytesrecv": "Total bytes received",
 	"getnettotalsresult-totalbytessent": "Total bytes sent",
 	"getnettotalsresult-timemillis":     "Number of milliseconds since 1 Jan 1970 GMT",
+	"getnettotalsresult-uploadtarget":   "Status of the maximum upload target",
+
+	// UploadTargetResult help.
+	"uploadtargetresult-timeframe":             "Length of the upload target cycle in seconds",
+	"uploadtargetresult-target":                "Maximum number of bytes to send per cycle (0 for no limit)",
+	"uploadtargetresult-targetreached":         "Whether or not the upload target has been reached",
+	"uploadtargetresult-servehistoricalblocks": "Whether or not historical blocks are still served to peers",
+	"uploadtargetresult-bytesleftincycle":      "Number of bytes left to send in the current cycle",
+	"uploadtargetresult-timeleftincycle":       "Number of seconds left in the current cycle",
+
+	// GetBackupInfoCmd help.
+	"getbackupinfo--synopsis": "Returns the progress of the most recent block database backup started with backupchain.",
+
+	// BackupInfoResult help.
+	"backupinforesult-destination":     "The directory the backup is written to",
+	"backupinforesult-inprogress":      "Whether or not the backup is still running",
+	"backupinforesult-starttime":       "The time the backup was started in seconds since 1 Jan 1970 GMT",
+	"backupinforesult-endtime":         "The time the backup finished in seconds since 1 Jan 1970 GMT (omitted while in progress)",
+	"backupinforesult-totalbytes":      "The number of bytes of block data to copy",
+	"backupinforesult-copiedbytes":     "The number of bytes of block data copied so far, including data copied by a previous attempt which was resumed",
+	"backupinforesult-metadataentries": "The number of metadata entries written so far",
+	"backupinforesult-error":           "The reason the backup failed (omitted unless it failed)",
+
+	// GetIndexInfoCmd help.
+	"getindexinfo--synopsis":       "Returns the height each enabled optional index has been built up to and whether or not it has caught up to the main chain.  Indexes which are still syncing are built in the background and cannot serve requests yet.  Indexes which failed to catch up report the error and cannot serve requests until the node is restarted.",
+	"getindexinfo-index":           "Only return the progress of the index with this name (for example \"address index\")",
+	"getindexinfo--result0--desc":  "Progress of the enabled indexes keyed by their name",
+	"getindexinfo--result0--key":   "Index name",
+	"getindexinfo--result0--value": "Object containing the progress of the index",
+
+	// IndexInfoResult help.
+	"indexinforesult-synced":          "Whether or not the index has caught up to the main chain",
+	"indexinforesult-bestblockheight": "The height of the last block connected to the index",
+	"indexinforesult-error":           "The reason the index failed to catch up to the main chain (omitted unless it failed)",
+
+	// GetNetMsgStatsCmd help.
+	"getnetmsgstats--synopsis": "Returns the number of bytes sent and received per message command, either for all peers since the server started or for a single connected peer.",
+	"getnetmsgstats-peerid":    "Only return the statistics for the connected peer with this ID",
+	"getnetmsgstats--result0":  "Per message command traffic statistics",
+
+	// NetMsgStatsResult help.
+	"netmsgstatsresult-command":   "The message command",
+	"netmsgstatsresult-bytessent": "Total bytes sent for the command",
+	"netmsgstatsresult-bytesrecv": "Total bytes received for the command",
 
 	// GetPeerInfoResult help.
 	"getpeerinforesult-id":             "A unique node ID",
@@ -584,6 +727,15 @@ var helpDescsEnUS = map[string]string{
 	"getpeerinforesult-banscore":       "The ban score",
 	"getpeerinforesult-syncnode":       "Whether or not the peer is the sync peer",
 
+	"getpeerinforesult-bytessentpermsg":        "Total bytes sent per message command",
+	"getpeerinforesult-bytessentpermsg--key":   "command",
+	"getpeerinforesult-bytessentpermsg--value": "bytes",
+	"getpeerinforesult-bytessentpermsg--desc":  "The number of bytes sent for the message command",
+	"getpeerinforesult-bytesrecvpermsg":        "Total bytes received per message command",
+	"getpeerinforesult-bytesrecvpermsg--key":   "command",
+	"getpeerinforesult-bytesrecvpermsg--value": "bytes",
+	"getpeerinforesult-bytesrecvpermsg--desc":  "The number of bytes received for the message command",
+
 	// GetPeerInfoCmd help.
 	"getpeerinfo--synopsis": "Returns data about each connected network peer as an array of json objects.",
 
@@ -612,6 +764,65 @@ var helpDescsEnUS = map[string]string{
 	"getrawtransaction--condition1": "verbose=true",
 	"getrawtransaction--result0":    "Hex-encoded bytes of the serialized transaction",
 
+	// GetSpentInfoCmd help.
+	"getspentinfo--synopsis": "Returns the transaction input in the main chain which spends an output.  Requires the spend index (--spendindex).",
+	"getspentinfo-txid":      "The hash of the transaction which contains the output",
+	"getspentinfo-index":     "The index of the output",
+	"getspentinfo-tree":      "The tree of the transaction which contains the output (0 for regular, 1 for stake), both trees are searched when omitted",
+
+	// GetSpentInfoResult help.
+	"getspentinforesult-txid":      "The hash of the spending transaction",
+	"getspentinforesult-index":     "The index of the spending input",
+	"getspentinforesult-blockhash": "The hash of the block which contains the spending transaction",
+	"getspentinforesult-height":    "The height of the block which contains the spending transaction",
+
+	// GetSubsidyScheduleCmd help.
+	"getsubsidyschedule--synopsis": "Returns the projected proof of work, proof of stake and developer subsidy per subsidy reduction interval and the cumulative coin supply, assuming every block includes all votes.\n" +
+		"All amounts are in atoms.  The schedule ends early once no more subsidy is created.",
+	"getsubsidyschedule-startheight": "The height whose reduction interval the schedule starts with, limited to the interval in which the subsidy reaches zero (default: the best height)",
+	"getsubsidyschedule-count":       "The maximum number of reduction intervals to return",
+	"getsubsidyschedule-heights":     "Heights to project the cumulative coin supply at",
+
+	// GetSubsidyScheduleResult help.
+	"getsubsidyscheduleresult-height":            "The height of the best block",
+	"getsubsidyscheduleresult-reductioninterval": "The number of blocks between subsidy reductions",
+	"getsubsidyscheduleresult-intervals":         "The subsidy of each reduction interval",
+	"getsubsidyscheduleresult-supply":            "The projected coin supply at each of the requested heights, omitted when none were requested",
+	"getsubsidyscheduleresult-actualsupply":      "The total subsidy of the main chain as returned by getcoinsupply",
+	"getsubsidyscheduleresult-theoreticalsupply": "The total subsidy the main chain would have if every block had included all votes and been approved",
+	"getsubsidyscheduleresult-missedsubsidy":     "The difference between the theoretical and actual supply caused by missed votes and disapproved blocks",
+	"subsidyscheduleinterval-interval":           "The index of the reduction interval",
+	"subsidyscheduleinterval-startheight":        "The height of the first block of the interval",
+	"subsidyscheduleinterval-endheight":          "The height of the last block of the interval",
+	"subsidyscheduleinterval-pow":                "The proof of work subsidy of a block",
+	"subsidyscheduleinterval-pos":                "The proof of stake subsidy of all votes of a block",
+	"subsidyscheduleinterval-developer":          "The developer subsidy of a block",
+	"subsidyscheduleinterval-totalpow":           "The proof of work subsidy of all blocks of the interval",
+	"subsidyscheduleinterval-totalpos":           "The proof of stake subsidy of all blocks of the interval",
+	"subsidyscheduleinterval-totaldeveloper":     "The developer subsidy of all blocks of the interval",
+	"subsidyscheduleinterval-total":              "The subsidy of all blocks of the interval, including the initial token distribution of block one",
+	"subsidyscheduleinterval-supply":             "The cumulative coin supply at the end of the interval",
+	"subsidyschedulesupply-height":               "The height",
+	"subsidyschedulesupply-supply":               "The cumulative coin supply after the block at the height",
+
+	// GetTicketInfoCmd help.
+	"getticketinfo--synopsis": "Returns when a ticket was purchased and whether and when it was voted, missed, expired or revoked.  Requires the ticket history index (--tickethistoryindex).",
+	"getticketinfo-txid":      "The hash of the ticket purchase transaction",
+
+	// TicketInfoResult help.
+	"ticketinforesult-hash":             "The hash of the ticket purchase transaction",
+	"ticketinforesult-status":           "The status of the ticket (immature, live, voted, missed, expired or revoked)",
+	"ticketinforesult-price":            "The price of the ticket in HC",
+	"ticketinforesult-purchaseblock":    "The hash of the block which contains the ticket purchase",
+	"ticketinforesult-purchaseheight":   "The height of the block which contains the ticket purchase",
+	"ticketinforesult-maturityheight":   "The height at which the ticket matures and may be selected to vote",
+	"ticketinforesult-expiryheight":     "The height at which the ticket expires unless it was selected to vote before",
+	"ticketinforesult-missedheight":     "The height of the block in which the ticket was missed or expired, omitted otherwise",
+	"ticketinforesult-votetxid":         "The hash of the vote which spent the ticket, omitted unless the ticket voted",
+	"ticketinforesult-voteheight":       "The height of the block which contains the vote, omitted unless the ticket voted",
+	"ticketinforesult-revocationtxid":   "The hash of the revocation which spent the ticket, omitted unless the ticket was revoked",
+	"ticketinforesult-revocationheight": "The height of the block which contains the revocation, omitted unless the ticket was revoked",
+
 	// GetTicketPoolValue help.
 	"getticketpoolvalue--synopsis": "Return the current value of all locked funds in the ticket pool",
 	"getticketpoolvalue--result0":  "Total value of ticket pool",
@@ -630,6 +841,25 @@ var helpDescsEnUS = map[string]string{
 	"gettxout-vout":           "The index of the output",
 	"gettxout-includemempool": "Include the mempool when true",
 
+	// GetTxSpendingPrevOutCmd help.
+	"gettxspendingprevout--synopsis": "Returns the transactions which spend the provided outputs.  Spends by transactions in the mempool are always reported while spends in the main chain require the spend index (--spendindex).",
+	"gettxspendingprevout-outputs":   "The outputs to look up",
+	"gettxspendingprevout--result0":  "The spending details for each output in the same order as provided",
+
+	// SpendingPrevOut help.
+	"spendingprevout-txid": "The hash of the transaction which contains the output",
+	"spendingprevout-vout": "The index of the output",
+	"spendingprevout-tree": "The tree of the transaction which contains the output (0 for regular, 1 for stake), both trees are searched when omitted",
+
+	// TxSpendingPrevOutResult help.
+	"txspendingprevoutresult-txid":         "The hash of the transaction which contains the output",
+	"txspendingprevoutresult-vout":         "The index of the output",
+	"txspendingprevoutresult-tree":         "The tree of the transaction which contains the output",
+	"txspendingprevoutresult-spendingtxid": "The hash of the spending transaction, omitted when the output is unspent",
+	"txspendingprevoutresult-spendingvin":  "The index of the spending input, omitted when the output is unspent",
+	"txspendingprevoutresult-blockhash":    "The hash of the block which contains the spending transaction, omitted when spent in the mempool",
+	"txspendingprevoutresult-blockheight":  "The height of the block which contains the spending transaction, omitted when spent in the mempool",
+
 	// GetWorkResult help.
 	"getworkresult-data":     "Hex-encoded block data",
 	"getworkresult-hash1":    "(DEPRECATED) Hex-encoded formatted hash buffer",
@@ -643,6 +873,11 @@ var helpDescsEnUS = map[string]string{
 	"getwork--condition1": "data provided",
 	"getwork--result1":    "Whether or not the solved data is valid and was added to the chain",
 
+	// ForceDeploymentCmd help.
+	"forcedeployment--synopsis": "Forces the state of a consensus deployment regardless of the votes, or removes a previously forced state.  Only available on the regression test network and not persisted across restarts.",
+	"forcedeployment-agendaid":  "The id of the agenda of the deployment",
+	"forcedeployment-state":     "The state to force the deployment to (active or failed), or clear to determine the state from the votes again",
+
 	// HelpCmd help.
 	"help--synopsis":   "Returns a list of all commands or help for a specified command.",
 	"help-command":     "The command to retrieve help for",
@@ -651,6 +886,18 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// InvalidateBlockCmd help.
+	"invalidateblock--synopsis": "Marks a block and all of its descendants invalid, disconnecting them when they are part of the main chain.  The mark persists across restarts until the block is reconsidered.",
+	"invalidateblock-blockhash": "The hash of the block to invalidate",
+
+	// ListTicketsByStatusCmd help.
+	"listticketsbystatus--synopsis": "Returns the tickets in the main chain with the provided status, optionally only those which involve an address as the voting address or as a commitment address.  Requires the ticket history index (--tickethistoryindex).",
+	"listticketsbystatus-status":    "The status of the tickets to list (immature, live, voted, missed, expired or revoked)",
+	"listticketsbystatus-address":   "Only list the tickets which involve this address",
+	"listticketsbystatus-skip":      "The number of leading tickets to skip",
+	"listticketsbystatus-count":     "The maximum number of tickets to return",
+	"listticketsbystatus--result0":  "The tickets with the provided status",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
@@ -661,6 +908,10 @@ var helpDescsEnUS = map[string]string{
 	// RebroadcastWinnerCmd help.
 	"rebroadcastwinners--synopsis": "Asks the daemon to rebroadcast the winners of the voting lottery.\n",
 
+	// ReconsiderBlockCmd help.
+	"reconsiderblock--synopsis": "Removes the invalid mark from a block, its ancestors and its descendants, reconnecting them when they form the valid chain with the most cumulative work.",
+	"reconsiderblock-blockhash": "The hash of the block to reconsider",
+
 	// SearchRawTransactionsCmd help.
 	"searchrawtransactions--synopsis": "Returns raw data for transactions involving the passed address.\n" +
 		"Returned transactions are pulled from both the database, and transactions currently in the mempool.\n" +
@@ -722,6 +973,33 @@ var helpDescsEnUS = map[string]string{
 	"verifychain-checkdepth": "The number of blocks to check",
 	"verifychain--result0":   "Whether or not the chain verified",
 
+	// VerifyLotteryCmd help.
+	"verifylottery--synopsis": "Recomputes the ticket lottery which selected the tickets eligible to vote on a block and checks it against the pool size and final state the block header commits to.\n" +
+		"The lottery is seeded with the serialized header of the parent block and draws the winners from the live tickets of the parent sorted by hash.",
+	"verifylottery-blockhash": "The hash of the block whose voters to verify",
+	"verifylottery-tickets":   "Ticket hashes to report the eligibility and selection of",
+
+	// VerifyLotteryResult help.
+	"verifylotteryresult-hash":             "The hash of the block",
+	"verifylotteryresult-height":           "The height of the block",
+	"verifylotteryresult-parenthash":       "The hash of the parent block which seeds the lottery",
+	"verifylotteryresult-seed":             "The PRNG seed derived from the serialized parent header",
+	"verifylotteryresult-poolsize":         "The number of live tickets of the parent the winners were drawn from",
+	"verifylotteryresult-headerpoolsize":   "The pool size the block header commits to",
+	"verifylotteryresult-prngstate":        "The hash of the PRNG state after the winners were drawn",
+	"verifylotteryresult-finalstate":       "The recomputed final state checksum of the winners and PRNG state",
+	"verifylotteryresult-headerfinalstate": "The final state the block header commits to",
+	"verifylotteryresult-valid":            "Whether the recomputed lottery matches the block header and the winners recorded by the chain",
+	"verifylotteryresult-winners":          "The selected tickets in the order they were drawn",
+	"verifylotteryresult-tickets":          "The eligibility of the requested tickets, omitted when none were requested",
+	"lotterywinner-ticket":                 "The hash of the selected ticket",
+	"lotterywinner-index":                  "The position of the ticket in the live tickets sorted by hash",
+	"lotterywinner-voted":                  "Whether the vote of the ticket is included in the block",
+	"lotteryticket-ticket":                 "The hash of the ticket",
+	"lotteryticket-eligible":               "Whether the ticket was live in the pool the winners were drawn from",
+	"lotteryticket-selected":               "Whether the ticket was selected to vote on the block",
+	"lotteryticket-voted":                  "Whether the vote of the ticket is included in the block",
+
 	// VerifyMessageCmd help.
 	"verifymessage--synopsis": "Verify a signed message.",
 	"verifymessage-address":   "The decred address to use for the signature",
@@ -892,6 +1170,7 @@ var helpDescsEnUS = map[string]string{
 // pointer to the type (or nil to indicate no return value).
 var rpcResultTypes = map[string][]interface{}{
 	"addnode":               nil,
+	"backupchain":           nil,
 	"createrawsstx":         {(*string)(nil)},
 	"createrawssgentx":      {(*string)(nil)},
 	"createrawssrtx":        {(*string)(nil)},
@@ -909,6 +1188,10 @@ var rpcResultTypes = map[string][]interface{}{
 	"existslivetickets":     {(*string)(nil)},
 	"existsmempooltxs":      {(*string)(nil)},
 	"getaddednodeinfo":      {(*[]string)(nil), (*[]dcrjson.GetAddedNodeInfoResult)(nil)},
+	"getaddressbalance":     {(*dcrjson.GetAddressBalanceResult)(nil)},
+	"getaddressdeltas":      {(*[]dcrjson.AddressDeltaResult)(nil)},
+	"getaddressutxos":       {(*[]dcrjson.AddressUtxoResult)(nil)},
+	"getbackupinfo":         {(*dcrjson.BackupInfoResult)(nil)},
 	"getbestblock":          {(*dcrjson.GetBestBlockResult)(nil)},
 	"generate":              {(*[]string)(nil)},
 	"getbestblockhash":      {(*string)(nil)},
@@ -916,6 +1199,7 @@ var rpcResultTypes = map[string][]interface{}{
 	"getblockcount":         {(*int64)(nil)},
 	"getblockhash":          {(*string)(nil)},
 	"getblockheader":        {(*string)(nil), (*dcrjson.GetBlockHeaderVerboseResult)(nil)},
+	"getblockstats":         {(*dcrjson.GetBlockStatsResult)(nil)},
 	"getblocksubsidy":       {(*dcrjson.GetBlockSubsidyResult)(nil)},
 	"getblocktemplate":      {(*dcrjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
 	"getconnectioncount":    {(*int32)(nil)},
@@ -927,26 +1211,37 @@ var rpcResultTypes = map[string][]interface{}{
 	"getgenerate":           {(*bool)(nil)},
 	"gethashespersec":       {(*float64)(nil)},
 	"getheaders":            {(*dcrjson.GetHeadersResult)(nil)},
+	"getindexinfo":          {(*map[string]dcrjson.IndexInfoResult)(nil)},
 	"getinfo":               {(*dcrjson.InfoChainResult)(nil)},
 	"getmempoolinfo":        {(*dcrjson.GetMempoolInfoResult)(nil)},
 	"getmininginfo":         {(*dcrjson.GetMiningInfoResult)(nil)},
+	"getnetmsgstats":        {(*[]dcrjson.NetMsgStatsResult)(nil)},
 	"getnettotals":          {(*dcrjson.GetNetTotalsResult)(nil)},
 	"getnetworkhashps":      {(*int64)(nil)},
 	"getpeerinfo":           {(*[]dcrjson.GetPeerInfoResult)(nil)},
 	"getrawmempool":         {(*[]string)(nil), (*dcrjson.GetRawMempoolVerboseResult)(nil)},
 	"getrawtransaction":     {(*string)(nil), (*dcrjson.TxRawResult)(nil)},
+	"getspentinfo":          {(*dcrjson.GetSpentInfoResult)(nil)},
+	"getsubsidyschedule":    {(*dcrjson.GetSubsidyScheduleResult)(nil)},
+	"getticketinfo":         {(*dcrjson.TicketInfoResult)(nil)},
 	"getticketpoolvalue":    {(*float64)(nil)},
 	"gettxout":              {(*dcrjson.GetTxOutResult)(nil)},
+	"gettxspendingprevout":  {(*[]dcrjson.TxSpendingPrevOutResult)(nil)},
 	"getvoteinfo":           {(*dcrjson.GetVoteInfoResult)(nil)},
+	"getvoteprobability":    {(*dcrjson.VoteProbabilityResult)(nil)},
 	"getwork":               {(*dcrjson.GetWorkResult)(nil), (*bool)(nil)},
 	"getcoinsupply":         {(*int64)(nil)},
+	"forcedeployment":       nil,
 	"help":                  {(*string)(nil), (*string)(nil)},
+	"invalidateblock":       nil,
+	"listticketsbystatus":   {(*[]dcrjson.TicketInfoResult)(nil)},
 	"livetickets":           {(*dcrjson.LiveTicketsResult)(nil)},
 	"missedtickets":         {(*dcrjson.MissedTicketsResult)(nil)},
 	"node":                  nil,
 	"ping":                  nil,
 	"rebroadcastmissed":     nil,
 	"rebroadcastwinners":    nil,
+	"reconsiderblock":       nil,
 	"searchrawtransactions": {(*string)(nil), (*[]dcrjson.SearchRawTransactionsResult)(nil)},
 	"sendrawtransaction":    {(*string)(nil)},
 	"setgenerate":           nil,
@@ -958,6 +1253,7 @@ var rpcResultTypes = map[string][]interface{}{
 	"txfeeinfo":             {(*dcrjson.TxFeeInfoResult)(nil)},
 	"validateaddress":       {(*dcrjson.ValidateAddressChainResult)(nil)},
 	"verifychain":           {(*bool)(nil)},
+	"verifylottery":         {(*dcrjson.VerifyLotteryResult)(nil)},
 	"verifymessage":         {(*bool)(nil)},
 	"verifyblissmessage":    {(*bool)(nil)},
 	"version":               {(*map[string]dcrjson.VersionResult)(nil)},
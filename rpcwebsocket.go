@@ -239,6 +239,32 @@ func (m *wsNotificationManager) NotifyReorganization(rd *blockchain.Reorganizati
 	}
 }
 
+// NotifyBlockInvalidated passes a block which was manually marked invalid to
+// the notification manager for block notification processing.
+func (m *wsNotificationManager) NotifyBlockInvalidated(bd *blockchain.BlockValidityNtfnsData) {
+	// As NotifyBlockInvalidated will be called by the block manager
+	// and the RPC server may no longer be running, use a select
+	// statement to unblock enqueuing the notification once the RPC
+	// server has begun shutting down.
+	select {
+	case m.queueNotification <- (*notificationBlockInvalidated)(bd):
+	case <-m.quit:
+	}
+}
+
+// NotifyBlockReconsidered passes a block whose invalid mark was manually
+// removed to the notification manager for block notification processing.
+func (m *wsNotificationManager) NotifyBlockReconsidered(bd *blockchain.BlockValidityNtfnsData) {
+	// As NotifyBlockReconsidered will be called by the block manager
+	// and the RPC server may no longer be running, use a select
+	// statement to unblock enqueuing the notification once the RPC
+	// server has begun shutting down.
+	select {
+	case m.queueNotification <- (*notificationBlockReconsidered)(bd):
+	case <-m.quit:
+	}
+}
+
 // NotifyWinningTickets passes newly winning tickets for an incoming block
 // to the notification manager for further processing.
 func (m *wsNotificationManager) NotifyWinningTickets(
@@ -496,6 +522,8 @@ func (f *wsClientFilter) removeUnspentOutPoint(op *wire.OutPoint) {
 type notificationBlockConnected hcutil.Block
 type notificationBlockDisconnected hcutil.Block
 type notificationReorganization blockchain.ReorganizationNtfnsData
+type notificationBlockInvalidated blockchain.BlockValidityNtfnsData
+type notificationBlockReconsidered blockchain.BlockValidityNtfnsData
 type notificationWinningTickets WinningTicketsNtfnData
 type notificationSpentAndMissedTickets blockchain.TicketNotificationsData
 type notificationNewTickets blockchain.TicketNotificationsData
@@ -569,6 +597,14 @@ out:
 				m.notifyReorganization(blockNotifications,
 					(*blockchain.ReorganizationNtfnsData)(n))
 
+			case *notificationBlockInvalidated:
+				m.notifyBlockInvalidated(blockNotifications,
+					(*blockchain.BlockValidityNtfnsData)(n))
+
+			case *notificationBlockReconsidered:
+				m.notifyBlockReconsidered(blockNotifications,
+					(*blockchain.BlockValidityNtfnsData)(n))
+
 			case *notificationWinningTickets:
 				m.notifyWinningTickets(winningTicketNotifications,
 					(*WinningTicketsNtfnData)(n))
@@ -861,6 +897,48 @@ func (m *wsNotificationManager) notifyReorganization(clients map[chan struct{}]*
 	}
 }
 
+// notifyBlockInvalidated notifies websocket clients that have registered for
+// block updates when a block was manually marked invalid.
+func (m *wsNotificationManager) notifyBlockInvalidated(clients map[chan struct{}]*wsClient, bd *blockchain.BlockValidityNtfnsData) {
+	// Skip notification creation if no clients have requested block
+	// notifications.
+	if len(clients) == 0 {
+		return
+	}
+
+	ntfn := dcrjson.NewBlockInvalidatedNtfn(bd.Hash.String(), bd.Height)
+	marshalledJSON, err := dcrjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal block invalidated "+
+			"notification: %v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
+// notifyBlockReconsidered notifies websocket clients that have registered for
+// block updates when the invalid mark of a block was manually removed.
+func (m *wsNotificationManager) notifyBlockReconsidered(clients map[chan struct{}]*wsClient, bd *blockchain.BlockValidityNtfnsData) {
+	// Skip notification creation if no clients have requested block
+	// notifications.
+	if len(clients) == 0 {
+		return
+	}
+
+	ntfn := dcrjson.NewBlockReconsideredNtfn(bd.Hash.String(), bd.Height)
+	marshalledJSON, err := dcrjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal block reconsidered "+
+			"notification: %v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
 // RegisterWinningTickets requests winning tickets update notifications
 // to the passed websocket client.
 func (m *wsNotificationManager) RegisterWinningTickets(wsc *wsClient) {
@@ -31,6 +31,21 @@ const FileContents = `[Application Options]
 ; Use simnet.
 ; simnet=1
 
+; Use regnet, a regression test network on which stake validation begins after
+; a few blocks and the generate RPC also purchases tickets and casts votes with
+; an internal wallet.  See docs/regnet.md for details.
+; regnet=1
+
+; Force the state of a consensus deployment on regnet regardless of the votes.
+; This option may be specified multiple times.
+; forcedeployment=maxblocksize:active
+
+; Use a custom private network whose parameters, such as the ticket pool size,
+; stake validation height, target block time, and premine ledger, are loaded
+; from a JSON file.  The genesis block is generated from the file.  See
+; docs/custom_networks.md for the file format.
+; netparams=~/.hcd/privnet.json
+
 ; Connect via a SOCKS5 proxy.  NOTE: Specifying a proxy will disable listening
 ; for incoming connections unless listen addresses are provided via the 'listen'
 ; option.
@@ -61,11 +76,18 @@ const FileContents = `[Application Options]
 ; will have no effect if exernal IP addresses are specified.
 ; upnp=1
 
+; Use NAT-PMP or its successor, the Port Control Protocol (PCP), to
+; automatically open the listen port and obtain the external IP address from
+; supported routers.  When both this and the 'upnp' option are enabled,
+; NAT-PMP and PCP are tried first.  NOTE: This option will have no effect if
+; external IP addresses are specified.
+; natpmp=1
+
 ; Specify the external IP addresses your node is listening on.  One address per
 ; line.  hcd will not contact 3rd-party sites to obtain external ip addresses.
 ; This means if you are behind NAT, your node will not be able to advertise a
-; reachable address unless you specify it here or enable the 'upnp' option (and
-; have a supported device).
+; reachable address unless you specify it here or enable the 'upnp' or 'natpmp'
+; option (and have a supported device).
 ; externalip=1.2.3.4
 ; externalip=2002::1234
 
@@ -131,6 +153,21 @@ const FileContents = `[Application Options]
 ; whitelist=192.168.0.0/24
 ; whitelist=fd00::/16
 
+; Group peers by the autonomous system which announces them rather than by
+; their /16 network prefix using the given asmap file.  This makes it more
+; difficult for an attacker which controls many network prefixes within a
+; single autonomous system to dominate the known and outbound peers.  Relative
+; paths are relative to the data directory.
+; asmap=ip_asn.map
+
+; Try to keep outbound traffic under the given target in MiB per 24 hour
+; window.  Once the target has been reached, blocks older than a week are no
+; longer served to non-whitelisted peers while new blocks are still relayed.
+; Targets too small to serve any historical blocks after reserving enough for
+; relaying new blocks are raised to the minimum of the network, which is 432 MiB
+; on mainnet.  The default of 0 disables the limit.
+; maxuploadtarget=5000
+
 ; Disable DNS seeding for peers.  By default, when hcd starts, it will use
 ; DNS to query for available peers to connect with.
 ; nodnsseed=1
@@ -226,6 +263,28 @@ const FileContents = `[Application Options]
 ; norpc=1
 
 
+; ------------------------------------------------------------------------------
+; Electrum server options - The following options control the built-in server
+; speaking the Electrum protocol used by lightweight wallets.
+;
+; NOTE: The Electrum server is disabled by default.  Enabling it also enables
+; the script hash index and, in turn, the transaction index.
+; ------------------------------------------------------------------------------
+
+; Enable the Electrum server.
+; electrum=1
+
+; Specify the interfaces for the Electrum server to listen on for plain TCP
+; connections.  One listen address per line.  The same address formats as the
+; 'rpclisten' option are accepted.  By default, the Electrum server will only
+; listen on localhost for IPv4 and IPv6 unless TLS listeners are specified.
+; electrumlisten=127.0.0.1
+
+; Specify the interfaces for the Electrum server to listen on for TLS
+; connections.  The RPC certificate and key are used for them.
+; electrumtlslisten=0.0.0.0
+
+
 
 ; ------------------------------------------------------------------------------
 ; Mempool Settings - The following options
@@ -261,6 +320,22 @@ const FileContents = `[Application Options]
 ; Delete the entire address index on start up, then exit.
 ; dropaddrindex=0
 
+; Delete the entire address balance and unspent output index on start up, then
+; exit.
+; dropaddrutxoindex=0
+
+; Delete the entire spent outpoint index on start up, then exit.
+; dropspendindex=0
+
+; Delete the entire ticket history index on start up, then exit.
+; droptickethistoryindex=0
+
+; Delete the entire block statistics index on start up, then exit.
+; dropblockstatsindex=0
+
+; Delete the entire script hash index on start up, then exit.
+; dropscripthashindex=0
+
 
 ; ------------------------------------------------------------------------------
 ; Optional Indexes
@@ -274,6 +349,33 @@ const FileContents = `[Application Options]
 ; searchrawtransactions RPC available.
 ; addrindex=1
 
+; Build and maintain an address balance and unspent output index which makes
+; the getaddressutxos, getaddressbalance and getaddressdeltas RPCs available.
+; It requires the transaction index which is enabled automatically.
+; addrutxoindex=1
+
+; Build and maintain a spent outpoint index which maps every output spent in
+; the main chain to the transaction, input and block spending it.  This makes
+; the gettxspendingprevout RPC report confirmed spends in addition to those in
+; the memory pool and the getspentinfo RPC available.
+; spendindex=1
+
+; Build and maintain a ticket history index which records when every ticket
+; was purchased and whether and when it was voted, missed, expired or revoked.
+; This makes the getticketinfo and listticketsbystatus RPCs available.
+; tickethistoryindex=1
+
+; Build and maintain a block statistics index which caches the statistics of
+; every block in the main chain so the getblockstats RPC does not have to
+; calculate them from the block on every request.
+; blockstatsindex=1
+
+; Build and maintain a script hash index which maps the sha256 hash of every
+; public key script, including stake scripts, to the transactions paying to or
+; spending from it.  It is used by the Electrum server and requires the
+; transaction index which is enabled automatically.
+; scripthashindex=1
+
 
 ; ------------------------------------------------------------------------------
 ; Signature Verification Cache
@@ -282,6 +384,13 @@ const FileContents = `[Application Options]
 ; Limit the signature cache to a max of 50000 entries.
 ; sigcachemaxsize=50000
 
+; Verify the secp256k1 Schnorr and Bliss signatures checked by the scripts of a
+; block concurrently once all of the scripts have been executed instead of one
+; at a time, and batch verify the Schnorr signatures.  This is faster for blocks
+; with many such signatures.  When one of them is invalid, the inputs are
+; validated again individually to find it.
+; batchsigverify=1
+
 
 ; ------------------------------------------------------------------------------
 ; Coin Generation (Mining) Settings - The following options control the
@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -50,6 +51,15 @@ const (
 	// target.
 	defaultTargetOutbound = 8
 
+	// defaultTargetBlockRelayOutbound is the default number of
+	// block-relay-only outbound peers to target in addition to the regular
+	// outbound peers.
+	defaultTargetBlockRelayOutbound = 2
+
+	// feelerInterval is the interval at which a feeler connection is made
+	// to test a new address from the address manager.
+	feelerInterval = time.Minute * 2
+
 	// connectionRetryInterval is the base amount of time to wait in between
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
@@ -67,6 +77,10 @@ var (
 	// userAgentVersion is the user agent version and is used to help
 	// identify ourselves to other peers.
 	userAgentVersion = fmt.Sprintf("%d.%d.%d", appMajor, appMinor, appPatch)
+
+	// errUploadTargetReached is returned when a historical block is not
+	// served to a peer because the max upload target has been reached.
+	errUploadTargetReached = errors.New("max upload target reached")
 )
 
 // broadcastMsg provides the ability to house a decred message to be broadcast
@@ -155,9 +169,11 @@ type server struct {
 	connManager          *connmgr.ConnManager
 	sigCache             *txscript.SigCache
 	rpcServer            *rpcServer
+	electrumServer       *electrumServer
 	blockManager         *blockManager
 	txMemPool            *mempool.TxPool
 	cpuMiner             *CPUMiner
+	regNetWallet         *regNetWallet
 	modifyRebroadcastInv chan interface{}
 	newPeers             chan *serverPeer
 	donePeers            chan *serverPeer
@@ -172,14 +188,35 @@ type server struct {
 	db                   database.DB
 	timeSource           blockchain.MedianTimeSource
 	services             wire.ServiceFlag
+	uploadTarget         *uploadTarget
+
+	// backup tracks the most recent block database backup started via the
+	// backupchain RPC.
+	backup chainBackup
+
+	// The following fields track the number of bytes sent and received per
+	// wire message command across all peers since start.  They are
+	// protected by the msgStatsMtx mutex.
+	msgStatsMtx     sync.Mutex
+	bytesSentPerMsg map[string]uint64
+	bytesRecvPerMsg map[string]uint64
 
 	// The following fields are used for optional indexes.  They will be nil
 	// if the associated index is not enabled.  These fields are set during
 	// initial creation of the server and never changed afterwards, so they
 	// do not need to be protected for concurrent access.
-	txIndex         *indexers.TxIndex
-	addrIndex       *indexers.AddrIndex
-	existsAddrIndex *indexers.ExistsAddrIndex
+	txIndex            *indexers.TxIndex
+	addrIndex          *indexers.AddrIndex
+	addrUtxoIndex      *indexers.AddrUtxoIndex
+	existsAddrIndex    *indexers.ExistsAddrIndex
+	spendIndex         *indexers.SpendIndex
+	ticketHistoryIndex *indexers.TicketHistoryIndex
+	blockStatsIndex    *indexers.BlockStatsIndex
+	scriptHashIndex    *indexers.ScriptHashIndex
+
+	// indexManager manages the optional indexes above, if any are enabled,
+	// and tracks whether or not they are synced with the main chain.
+	indexManager *indexers.Manager
 }
 
 // serverPeer extends the peer to maintain state shared by the server and
@@ -190,6 +227,8 @@ type serverPeer struct {
 	connReq         *connmgr.ConnReq
 	server          *server
 	persistent      bool
+	blockRelayOnly  bool
+	feeler          bool
 	continueHash    *chainhash.Hash
 	relayMtx        sync.Mutex
 	disableRelayTx  bool
@@ -202,6 +241,11 @@ type serverPeer struct {
 	banScore        connmgr.DynamicBanScore
 	quit            chan struct{}
 
+	// numUnconnectingHeaders is the number of headers messages received
+	// from the peer in a row which did not connect to a known header.  It
+	// is only accessed by the block manager.
+	numUnconnectingHeaders int
+
 	// The following chans are used to sync blockmanager and server.
 	txProcessed    chan struct{}
 	blockProcessed chan struct{}
@@ -325,6 +369,16 @@ func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) {
 // to negotiate the protocol version details as well as kick start the
 // communications.
 func (sp *serverPeer) OnVersion(p *peer.Peer, msg *wire.MsgVersion) {
+	// Feeler connections are only made to test whether the address is
+	// reachable, so mark it as a known good address and disconnect.
+	if sp.feeler {
+		srvrLog.Debugf("Feeler connection to %s succeeded -- "+
+			"disconnecting", p)
+		sp.server.addrManager.Good(p.NA())
+		p.Disconnect()
+		return
+	}
+
 	// Add the remote peer time as a sample for creating an offset against
 	// the local clock to keep the network time in sync.
 	sp.server.timeSource.AddTimeSample(p.Addr(), msg.Timestamp)
@@ -333,21 +387,24 @@ func (sp *serverPeer) OnVersion(p *peer.Peer, msg *wire.MsgVersion) {
 	sp.server.blockManager.NewPeer(sp)
 
 	// Choose whether or not to relay transactions before a filter command
-	// is received.
-	sp.setDisableRelayTx(msg.DisableRelayTx)
+	// is received.  Transactions are never relayed to block-relay-only
+	// peers.
+	sp.setDisableRelayTx(msg.DisableRelayTx || sp.blockRelayOnly)
 
 	// Update the address manager and request known addresses from the
 	// remote peer for outbound connections.  This is skipped when running
-	// on the simulation test network since it is only intended to connect
-	// to specified peers and actively avoids advertising and connecting to
-	// discovered peers.
-	if !cfg.SimNet {
+	// on the simulation and regression test networks since they are only
+	// intended to connect to specified peers and actively avoid advertising
+	// and connecting to discovered peers.
+	if !cfg.SimNet && !cfg.RegNet {
 		addrManager := sp.server.addrManager
 		// Outbound connections.
 		if !p.Inbound() {
 			// TODO(davec): Only do this if not doing the initial block
-			// download and the local address is routable.
-			if !cfg.DisableListen /* && isCurrent? */ {
+			// download and the local address is routable.  Addresses
+			// are neither advertised to nor requested from
+			// block-relay-only peers.
+			if !cfg.DisableListen && !sp.blockRelayOnly /* && isCurrent? */ {
 				// Get address that best matches.
 				lna := addrManager.GetBestLocalAddress(p.NA())
 				if addrmgr.IsRoutable(lna) {
@@ -359,7 +416,7 @@ func (sp *serverPeer) OnVersion(p *peer.Peer, msg *wire.MsgVersion) {
 
 			// Request known addresses if the server address manager
 			// needs more.
-			if addrManager.NeedMoreAddresses() {
+			if addrManager.NeedMoreAddresses() && !sp.blockRelayOnly {
 				p.QueueMessage(wire.NewMsgGetAddr(), nil)
 			}
 
@@ -528,6 +585,11 @@ func (sp *serverPeer) OnTx(p *peer.Peer, msg *wire.MsgTx) {
 			msg.TxHash(), p)
 		return
 	}
+	if sp.blockRelayOnly {
+		peerLog.Tracef("Ignoring tx %v from %v - block-relay-only peer",
+			msg.TxHash(), p)
+		return
+	}
 
 	// Add the transaction to the known inventory for the peer.
 	// Convert the raw MsgTx to a hcutil.Tx which provides some convenience
@@ -574,7 +636,7 @@ func (sp *serverPeer) OnBlock(p *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 // accordingly.  We pass the message down to blockmanager which will call
 // QueueMessage with any appropriate responses.
 func (sp *serverPeer) OnInv(p *peer.Peer, msg *wire.MsgInv) {
-	if !cfg.BlocksOnly {
+	if !cfg.BlocksOnly && !sp.blockRelayOnly {
 		if len(msg.InvList) > 0 {
 			sp.server.blockManager.QueueInv(msg, sp)
 		}
@@ -952,11 +1014,11 @@ func (sp *serverPeer) OnFilterLoad(p *peer.Peer, msg *wire.MsgFilterLoad) {
 // OnGetAddr is invoked when a peer receives a getaddr wire message and is used
 // to provide the peer with known addresses from the address manager.
 func (sp *serverPeer) OnGetAddr(p *peer.Peer, msg *wire.MsgGetAddr) {
-	// Don't return any addresses when running on the simulation test
-	// network.  This helps prevent the network from becoming another
-	// public test network since it will not be able to learn about other
-	// peers that have not specifically been provided.
-	if cfg.SimNet {
+	// Don't return any addresses when running on the simulation or
+	// regression test networks.  This helps prevent the network from
+	// becoming another public test network since it will not be able to
+	// learn about other peers that have not specifically been provided.
+	if cfg.SimNet || cfg.RegNet {
 		return
 	}
 
@@ -976,11 +1038,17 @@ func (sp *serverPeer) OnGetAddr(p *peer.Peer, msg *wire.MsgGetAddr) {
 // OnAddr is invoked when a peer receives an addr wire message and is used to
 // notify the server about advertised addresses.
 func (sp *serverPeer) OnAddr(p *peer.Peer, msg *wire.MsgAddr) {
-	// Ignore addresses when running on the simulation test network.  This
-	// helps prevent the network from becoming another public test network
-	// since it will not be able to learn about other peers that have not
-	// specifically been provided.
-	if cfg.SimNet {
+	// Ignore addresses when running on the simulation or regression test
+	// networks.  This helps prevent the network from becoming another
+	// public test network since it will not be able to learn about other
+	// peers that have not specifically been provided.
+	if cfg.SimNet || cfg.RegNet {
+		return
+	}
+
+	// Ignore addresses from block-relay-only peers since addresses are
+	// not relayed over those connections.
+	if sp.blockRelayOnly {
 		return
 	}
 
@@ -1019,15 +1087,30 @@ func (sp *serverPeer) OnAddr(p *peer.Peer, msg *wire.MsgAddr) {
 }
 
 // OnRead is invoked when a peer receives a message and it is used to update
-// the bytes received by the server.
+// the bytes received by the server and to ban peers sending malformed
+// messages.
 func (sp *serverPeer) OnRead(p *peer.Peer, bytesRead int, msg wire.Message, err error) {
 	sp.server.AddBytesReceived(uint64(bytesRead))
+	sp.server.addMsgBytesReceived(peer.MsgCommand(msg), uint64(bytesRead))
+
+	// Ban peers which send a message payload that fails to decode, such as
+	// one with item counts exceeding the protocol limits.  Such payloads
+	// have already passed the header length and checksum checks, so they
+	// can only be the result of a deliberate protocol violation.  Errors
+	// detected while reading the header, such as unknown commands, only
+	// lead to a disconnect since they may be caused by newer or
+	// misconfigured peers.
+	if payloadErr, ok := err.(*wire.PayloadError); ok {
+		sp.addBanScore(100, 0, fmt.Sprintf("malformed message: %v",
+			payloadErr))
+	}
 }
 
 // OnWrite is invoked when a peer sends a message and it is used to update
 // the bytes sent by the server.
 func (sp *serverPeer) OnWrite(p *peer.Peer, bytesWritten int, msg wire.Message, err error) {
 	sp.server.AddBytesSent(uint64(bytesWritten))
+	sp.server.addMsgBytesSent(peer.MsgCommand(msg), uint64(bytesWritten))
 }
 
 // randomUint16Number returns a random uint16 in a specified input range.  Note
@@ -1139,6 +1222,18 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 		return err
 	}
 
+	// Disconnect the peer rather than serving it historical blocks once the
+	// upload target has been reached.
+	if s.historicalBlockRestricted(sp, &block.MsgBlock().Header) {
+		peerLog.Infof("Disconnecting peer %v requesting historical block "+
+			"%v -- max upload target reached", sp, hash)
+		sp.Disconnect()
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return errUploadTargetReached
+	}
+
 	// Once we have fetched data wait for any previous operation to finish.
 	if waitChan != nil {
 		<-waitChan
@@ -1195,6 +1290,18 @@ func (s *server) pushMerkleBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneCh
 		return err
 	}
 
+	// Disconnect the peer rather than serving it historical blocks once the
+	// upload target has been reached.
+	if s.historicalBlockRestricted(sp, &blk.MsgBlock().Header) {
+		peerLog.Infof("Disconnecting peer %v requesting historical merkle "+
+			"block %v -- max upload target reached", sp, hash)
+		sp.Disconnect()
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return errUploadTargetReached
+	}
+
 	// Generate a merkle block by filtering the requested block according
 	// to the filter for the peer.
 	merkle, matchedTxIndices := bloom.NewMerkleBlock(blk, sp.filter)
@@ -1301,12 +1408,27 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		return false
 	}
 
+	// Disconnect automatic outbound peers which are in the same network
+	// group as an existing outbound peer to ensure the outbound peers are
+	// spread across different network segments.  This is also checked when
+	// choosing addresses, however, multiple connections to the same group
+	// may be in flight at the same time.
+	if !sp.Inbound() && !sp.persistent {
+		key := s.addrManager.GroupKey(sp.NA())
+		if state.outboundGroups[key] != 0 {
+			srvrLog.Debugf("Already connected to an outbound peer "+
+				"in group %s - disconnecting peer %s", key, sp)
+			sp.Disconnect()
+			return false
+		}
+	}
+
 	// Add the new peer and start it.
 	srvrLog.Debugf("New peer %s", sp)
 	if sp.Inbound() {
 		state.inboundPeers[sp.ID()] = sp
 	} else {
-		state.outboundGroups[addrmgr.GroupKey(sp.NA())]++
+		state.outboundGroups[s.addrManager.GroupKey(sp.NA())]++
 		if sp.persistent {
 			state.persistentPeers[sp.ID()] = sp
 		} else {
@@ -1330,7 +1452,7 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	}
 	if _, ok := list[sp.ID()]; ok {
 		if !sp.Inbound() && sp.VersionKnown() {
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		}
 		if !sp.Inbound() && sp.connReq != nil {
 			s.connManager.Disconnect(sp.connReq.ID())
@@ -1533,7 +1655,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found := disconnectPeer(state.persistentPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		})
 
 		if found {
@@ -1569,7 +1691,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		})
 		if found {
 			// If there are multiple outbound connections to the same
@@ -1577,7 +1699,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 			// peers are found.
 			for found {
 				found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
-					state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+					state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 				})
 			}
 			msg.reply <- nil
@@ -1642,7 +1764,7 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		UserAgentVersion: userAgentVersion,
 		ChainParams:      sp.server.chainParams,
 		Services:         sp.server.services,
-		DisableRelayTx:   cfg.BlocksOnly,
+		DisableRelayTx:   cfg.BlocksOnly || sp.blockRelayOnly,
 		ProtocolVersion:  maxProtocolVersion,
 	}
 }
@@ -1666,6 +1788,8 @@ func (s *server) inboundPeerConnected(conn net.Conn) {
 // manager of the attempt.
 func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	sp := newServerPeer(s, c.Permanent)
+	sp.blockRelayOnly = c.BlockRelayOnly
+	sp.feeler = c.Feeler
 	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String())
 	if err != nil {
 		srvrLog.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
@@ -1686,7 +1810,7 @@ func (s *server) peerDoneHandler(sp *serverPeer) {
 	s.donePeers <- sp
 
 	// Only tell block manager we are gone if we ever told it we existed.
-	if sp.VersionKnown() {
+	if sp.VersionKnown() && !sp.feeler {
 		s.blockManager.DonePeer(sp)
 	}
 	close(sp.quit)
@@ -1759,6 +1883,10 @@ out:
 			s.handleQuery(state, qmsg)
 
 		case <-s.quit:
+			// Persist the block-relay-only peers so they can be used
+			// as anchors on the next start.
+			s.saveAnchors(state)
+
 			// Disconnect all peers on server shutdown.
 			state.forAllPeers(func(sp *serverPeer) {
 				srvrLog.Tracef("Shutdown peer %s", sp)
@@ -1770,6 +1898,9 @@ out:
 
 	s.connManager.Stop()
 	s.blockManager.Stop()
+	if s.indexManager != nil {
+		s.indexManager.Stop()
+	}
 	s.addrManager.Stop()
 
 	// Drain channels before exiting so nothing is left waiting around
@@ -1791,6 +1922,33 @@ cleanup:
 	srvrLog.Tracef("Peer handler done")
 }
 
+// saveAnchors writes the addresses of the connected block-relay-only peers to
+// the anchors file so they are reconnected to on the next start.  It is
+// invoked from the peerHandler goroutine.
+func (s *server) saveAnchors(state *peerState) {
+	// Anchors are only used when connections are made automatically.
+	if cfg.SimNet || cfg.RegNet || len(cfg.ConnectPeers) != 0 {
+		return
+	}
+
+	var addrs []string
+	for _, sp := range state.outboundPeers {
+		if sp.blockRelayOnly && sp.Connected() {
+			addrs = append(addrs, sp.Addr())
+		}
+	}
+	if len(addrs) == 0 {
+		return
+	}
+
+	path := filepath.Join(cfg.DataDir, anchorsFilename)
+	if err := saveAnchors(path, addrs); err != nil {
+		srvrLog.Errorf("Failed to save anchors to %s: %v", path, err)
+		return
+	}
+	srvrLog.Debugf("Saved %d anchors to %s", len(addrs), path)
+}
+
 // AddPeer adds a new peer that has already been connected to the server.
 func (s *server) AddPeer(sp *serverPeer) {
 	s.newPeers <- sp
@@ -1919,6 +2077,7 @@ func (s *server) ConnectNode(addr string, permanent bool) error {
 // for the server.  It is safe for concurrent access.
 func (s *server) AddBytesSent(bytesSent uint64) {
 	atomic.AddUint64(&s.bytesSent, bytesSent)
+	s.uploadTarget.AddBytesSent(bytesSent)
 }
 
 // AddBytesReceived adds the passed number of bytes to the total bytes received
@@ -1934,6 +2093,52 @@ func (s *server) NetTotals() (uint64, uint64) {
 		atomic.LoadUint64(&s.bytesSent)
 }
 
+// addMsgBytesSent adds the passed number of bytes to the bytes sent for the
+// given wire message command.  It is safe for concurrent access.
+func (s *server) addMsgBytesSent(command string, bytesSent uint64) {
+	s.msgStatsMtx.Lock()
+	s.bytesSentPerMsg[command] += bytesSent
+	s.msgStatsMtx.Unlock()
+}
+
+// addMsgBytesReceived adds the passed number of bytes to the bytes received
+// for the given wire message command.  It is safe for concurrent access.
+func (s *server) addMsgBytesReceived(command string, bytesReceived uint64) {
+	s.msgStatsMtx.Lock()
+	s.bytesRecvPerMsg[command] += bytesReceived
+	s.msgStatsMtx.Unlock()
+}
+
+// NetMsgTotals returns copies of the number of bytes sent and received across
+// all peers since start keyed by wire message command.  It is safe for
+// concurrent access.
+func (s *server) NetMsgTotals() (map[string]uint64, map[string]uint64) {
+	s.msgStatsMtx.Lock()
+	defer s.msgStatsMtx.Unlock()
+
+	sent := make(map[string]uint64, len(s.bytesSentPerMsg))
+	for command, n := range s.bytesSentPerMsg {
+		sent[command] = n
+	}
+	recv := make(map[string]uint64, len(s.bytesRecvPerMsg))
+	for command, n := range s.bytesRecvPerMsg {
+		recv[command] = n
+	}
+	return sent, recv
+}
+
+// historicalBlockRestricted returns whether a block with the passed header may
+// no longer be served to the peer because it is historical, the peer is not
+// whitelisted, and the max upload target has been reached.
+func (s *server) historicalBlockRestricted(sp *serverPeer, header *wire.BlockHeader) bool {
+	if sp.isWhitelisted || !s.uploadTarget.Reached(true) {
+		return false
+	}
+
+	best := s.blockManager.chain.BestSnapshot()
+	return best.MedianTime.Sub(header.Timestamp) > historicalBlockAge
+}
+
 // UpdatePeerHeights updates the heights of all peers who have have announced
 // the latest connected main chain block, or a recognized orphan. These height
 // updates allow us to dynamically refresh peer heights, ensuring sync peer
@@ -2020,19 +2225,26 @@ func (s *server) Start() {
 
 	if s.nat != nil {
 		s.wg.Add(1)
-		go s.upnpUpdateThread()
+		go s.natUpdateThread()
 	}
 
-	if !cfg.DisableRPC {
+	if !cfg.DisableRPC || cfg.Electrum {
 		s.wg.Add(1)
 
 		// Start the rebroadcastHandler, which ensures user tx received by
-		// the RPC server are rebroadcast until being included in a block.
+		// the RPC and Electrum servers are rebroadcast until being
+		// included in a block.
 		go s.rebroadcastHandler()
+	}
 
+	if !cfg.DisableRPC {
 		s.rpcServer.Start()
 	}
 
+	if cfg.Electrum {
+		s.electrumServer.Start()
+	}
+
 	// Start the CPU miner if generation is enabled.
 	if cfg.Generate {
 		s.cpuMiner.Start()
@@ -2060,6 +2272,11 @@ func (s *server) Stop() error {
 		s.rpcServer.Stop()
 	}
 
+	// Shutdown the Electrum server if it's enabled.
+	if cfg.Electrum && s.electrumServer != nil {
+		s.electrumServer.Stop()
+	}
+
 	// Signal the remaining goroutines to quit.
 	close(s.quit)
 	return nil
@@ -2160,16 +2377,43 @@ func parseListeners(addrs []string) ([]string, []string, bool, error) {
 	return ipv4ListenAddrs, ipv6ListenAddrs, haveWildcard, nil
 }
 
-func (s *server) upnpUpdateThread() {
+// natRenewInterval returns how long to wait before renewing the port mapping
+// of the passed NAT.  Gateways speaking NAT-PMP or PCP may grant a shorter
+// lifetime than requested, in which case the mapping is renewed halfway
+// through the granted lifetime.
+func natRenewInterval(nat NAT) time.Duration {
+	const defaultInterval = time.Minute * 15
+	leaser, ok := nat.(interface {
+		leaseLifetime() time.Duration
+	})
+	if !ok {
+		return defaultInterval
+	}
+	lifetime := leaser.leaseLifetime()
+	if lifetime <= 0 || lifetime/2 >= defaultInterval {
+		return defaultInterval
+	}
+	if lifetime/2 < time.Minute {
+		return time.Minute
+	}
+	return lifetime / 2
+}
+
+// natUpdateThread maps the listen port via the discovered NAT, renews the
+// mapping before its lease expires and reports the external address to the
+// address manager whenever it changes.  It must be run as a goroutine.
+func (s *server) natUpdateThread() {
 	// Go off immediately to prevent code duplication, thereafter we renew
-	// lease every 15 minutes.
+	// the lease periodically.
 	timer := time.NewTimer(0 * time.Second)
 	lport, _ := strconv.ParseInt(activeNetParams.DefaultPort, 10, 16)
-	first := true
+	var lastExternalIP net.IP
 out:
 	for {
 		select {
 		case <-timer.C:
+			timer.Reset(time.Minute * 15)
+
 			// TODO(oga) pick external port  more cleverly
 			// TODO(oga) know which ports we are listening to on an external net.
 			// TODO(oga) if specific listen port doesn't work then ask for wildcard
@@ -2178,26 +2422,31 @@ out:
 			listenPort, err := s.nat.AddPortMapping("tcp", int(lport), int(lport),
 				"hcd listen port", 20*60)
 			if err != nil {
-				srvrLog.Warnf("can't add UPnP port mapping: %v", err)
+				srvrLog.Warnf("can't add %v port mapping: %v", s.nat, err)
+				continue out
 			}
-			if first && err == nil {
-				// TODO(oga): look this up periodically to see if upnp domain changed
-				// and so did ip.
-				externalip, err := s.nat.GetExternalAddress()
-				if err != nil {
-					srvrLog.Warnf("UPnP can't get external address: %v", err)
-					continue out
-				}
-				na := wire.NewNetAddressIPPort(externalip, uint16(listenPort),
-					s.services)
-				err = s.addrManager.AddLocalAddress(na, addrmgr.UpnpPrio)
-				if err != nil {
-					// XXX DeletePortMapping?
-				}
-				srvrLog.Warnf("Successfully bound via UPnP to %s", addrmgr.NetAddressKey(na))
-				first = false
+			timer.Reset(natRenewInterval(s.nat))
+
+			// Look the external address up on every renewal since
+			// it may change, for instance when the ISP assigns a new
+			// address to the gateway.
+			externalip, err := s.nat.GetExternalAddress()
+			if err != nil {
+				srvrLog.Warnf("%v can't get external address: %v", s.nat, err)
+				continue out
 			}
-			timer.Reset(time.Minute * 15)
+			if externalip.Equal(lastExternalIP) {
+				continue out
+			}
+			na := wire.NewNetAddressIPPort(externalip, uint16(listenPort),
+				s.services)
+			err = s.addrManager.AddLocalAddress(na, addrmgr.UpnpPrio)
+			if err != nil {
+				// XXX DeletePortMapping?
+			}
+			srvrLog.Warnf("Successfully bound via %v to %s", s.nat,
+				addrmgr.NetAddressKey(na))
+			lastExternalIP = externalip
 		case <-s.quit:
 			break out
 		}
@@ -2206,9 +2455,9 @@ out:
 	timer.Stop()
 
 	if err := s.nat.DeletePortMapping("tcp", int(lport), int(lport)); err != nil {
-		srvrLog.Warnf("unable to remove UPnP port mapping: %v", err)
+		srvrLog.Warnf("unable to remove %v port mapping: %v", s.nat, err)
 	} else {
-		srvrLog.Debugf("succesfully disestablished UPnP port mapping")
+		srvrLog.Debugf("succesfully disestablished %v port mapping", s.nat)
 	}
 
 	s.wg.Done()
@@ -2238,6 +2487,11 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	}
 
 	amgr := addrmgr.New(cfg.DataDir, dcrdLookup)
+	if cfg.asmap != nil {
+		srvrLog.Infof("Using asmap with checksum %s to group peers",
+			cfg.asmap.Checksum())
+		amgr.SetASMap(cfg.asmap)
+	}
 
 	var listeners []net.Listener
 	var nat NAT
@@ -2286,12 +2540,10 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 					amgrLog.Warnf("Skipping specified external IP: %v", err)
 				}
 			}
-		} else if discover && cfg.Upnp {
-			nat, err = Discover()
-			if err != nil {
-				srvrLog.Warnf("Can't discover upnp: %v", err)
-			}
-			// nil nat here is fine, just means no upnp on network.
+		} else if discover && (cfg.NATPMP || cfg.Upnp) {
+			// nil nat here is fine, just means no supported device
+			// on network.
+			nat = discoverNAT()
 		}
 
 		// TODO(oga) nonstandard port...
@@ -2380,6 +2632,13 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		timeSource:           blockchain.NewMedianTime(),
 		services:             services,
 		sigCache:             txscript.NewSigCache(cfg.SigCacheMaxSize),
+		uploadTarget:         newUploadTarget(cfg.MaxUploadTarget*1024*1024, chainParams),
+		bytesSentPerMsg:      make(map[string]uint64),
+		bytesRecvPerMsg:      make(map[string]uint64),
+	}
+	if cfg.MaxUploadTarget != 0 {
+		srvrLog.Infof("Max upload target set to %d MiB per 24h",
+			cfg.MaxUploadTarget)
 	}
 
 	// Create the transaction and address indexes if needed.
@@ -2389,12 +2648,14 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	// addrindex is run first, it may not have the transactions from the
 	// current block indexed.
 	var indexes []indexers.Indexer
-	if cfg.TxIndex || cfg.AddrIndex {
-		// Enable transaction index if address index is enabled since it
-		// requires it.
+	if cfg.TxIndex || cfg.AddrIndex || cfg.AddrUtxoIndex ||
+		cfg.ScriptHashIndex {
+
+		// Enable transaction index if either address index or the
+		// script hash index is enabled since they require it.
 		if !cfg.TxIndex {
 			indxLog.Infof("Transaction index enabled because it " +
-				"is required by the address index")
+				"is required by the address or script hash indexes")
 			cfg.TxIndex = true
 		} else {
 			indxLog.Info("Transaction index is enabled")
@@ -2408,16 +2669,44 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		s.addrIndex = indexers.NewAddrIndex(db, chainParams)
 		indexes = append(indexes, s.addrIndex)
 	}
+	if cfg.AddrUtxoIndex {
+		indxLog.Info("Address utxo index is enabled")
+		s.addrUtxoIndex = indexers.NewAddrUtxoIndex(db, chainParams)
+		indexes = append(indexes, s.addrUtxoIndex)
+	}
 	if !cfg.NoExistsAddrIndex {
 		indxLog.Info("Exists address index is enabled")
 		s.existsAddrIndex = indexers.NewExistsAddrIndex(db, chainParams)
 		indexes = append(indexes, s.existsAddrIndex)
 	}
+	if cfg.SpendIndex {
+		indxLog.Info("Spend index is enabled")
+		s.spendIndex = indexers.NewSpendIndex(db)
+		indexes = append(indexes, s.spendIndex)
+	}
+	if cfg.TicketHistIndex {
+		indxLog.Info("Ticket history index is enabled")
+		s.ticketHistoryIndex = indexers.NewTicketHistoryIndex(db,
+			chainParams)
+		indexes = append(indexes, s.ticketHistoryIndex)
+	}
+	if cfg.BlockStatsIndex {
+		indxLog.Info("Block stats index is enabled")
+		s.blockStatsIndex = indexers.NewBlockStatsIndex(db, chainParams)
+		indexes = append(indexes, s.blockStatsIndex)
+	}
+
+	if cfg.ScriptHashIndex {
+		indxLog.Info("Script hash index is enabled")
+		s.scriptHashIndex = indexers.NewScriptHashIndex(db)
+		indexes = append(indexes, s.scriptHashIndex)
+	}
 
 	// Create an index manager if any of the optional indexes are enabled.
 	var indexManager blockchain.IndexManager
 	if len(indexes) > 0 {
-		indexManager = indexers.NewManager(db, indexes, chainParams)
+		s.indexManager = indexers.NewManager(db, indexes, chainParams)
+		indexManager = s.indexManager
 	}
 	bm, err := newBlockManager(&s, indexManager)
 	if err != nil {
@@ -2456,7 +2745,9 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		SigCache:         s.sigCache,
 		PastMedianTime:   func() time.Time { return bm.chain.BestSnapshot().MedianTime },
 		AddrIndex:        s.addrIndex,
+		AddrUtxoIndex:    s.addrUtxoIndex,
 		ExistsAddrIndex:  s.existsAddrIndex,
+		ScriptHashIndex:  s.scriptHashIndex,
 	}
 	s.txMemPool = mempool.New(&txC)
 
@@ -2471,14 +2762,25 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	}
 	s.cpuMiner = newCPUMiner(&policy, &s)
 
+	// Create the wallet which provides the stake transactions for the
+	// blocks created by the generate RPC on the regression test network.
+	if s.chainParams.Net == wire.RegTest {
+		s.regNetWallet, err = newRegNetWallet(&s)
+		if err != nil {
+			return nil, err
+		}
+		srvrLog.Infof("Generated blocks pay to the regression test "+
+			"network wallet address %v", s.regNetWallet.Address())
+	}
+
 	// Only setup a function to return new addresses to connect to when
-	// not running in connect-only mode.  The simulation network is always
-	// in connect-only mode since it is only intended to connect to
-	// specified peers and actively avoid advertising and connecting to
-	// discovered peers in order to prevent it from becoming a public test
-	// network.
+	// not running in connect-only mode.  The simulation and regression
+	// test networks are always in connect-only mode since they are only
+	// intended to connect to specified peers and actively avoid
+	// advertising and connecting to discovered peers in order to prevent
+	// them from becoming public test networks.
 	var newAddressFunc func() (net.Addr, error)
-	if !cfg.SimNet && len(cfg.ConnectPeers) == 0 {
+	if !cfg.SimNet && !cfg.RegNet && len(cfg.ConnectPeers) == 0 {
 		newAddressFunc = func() (net.Addr, error) {
 			for tries := 0; tries < 100; tries++ {
 				addr := s.addrManager.GetAddress()
@@ -2492,7 +2794,7 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 				// in the same group so that we are not connecting
 				// to the same network segment at the expense of
 				// others.
-				key := addrmgr.GroupKey(addr.NetAddress())
+				key := s.addrManager.GroupKey(addr.NetAddress())
 				if s.OutboundGroupCount(key) != 0 {
 					continue
 				}
@@ -2517,19 +2819,38 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		}
 	}
 
+	// Load the block-relay-only peers from the previous run to use as
+	// anchors when connections are made automatically.
+	var anchors []net.Addr
+	if newAddressFunc != nil {
+		path := filepath.Join(cfg.DataDir, anchorsFilename)
+		anchors, err = loadAnchors(path)
+		if err != nil {
+			srvrLog.Warnf("Failed to load anchors from %s: %v", path,
+				err)
+		}
+	}
+
 	// Create a connection manager.
 	targetOutbound := defaultTargetOutbound
 	if cfg.MaxPeers < targetOutbound {
 		targetOutbound = cfg.MaxPeers
 	}
+	targetBlockRelayOutbound := defaultTargetBlockRelayOutbound
+	if cfg.MaxPeers-targetOutbound < targetBlockRelayOutbound {
+		targetBlockRelayOutbound = cfg.MaxPeers - targetOutbound
+	}
 	cmgr, err := connmgr.New(&connmgr.Config{
-		Listeners:      listeners,
-		OnAccept:       s.inboundPeerConnected,
-		RetryDuration:  connectionRetryInterval,
-		TargetOutbound: uint32(targetOutbound),
-		Dial:           dcrdDial,
-		OnConnection:   s.outboundPeerConnected,
-		GetNewAddress:  newAddressFunc,
+		Listeners:                listeners,
+		OnAccept:                 s.inboundPeerConnected,
+		RetryDuration:            connectionRetryInterval,
+		TargetOutbound:           uint32(targetOutbound),
+		TargetBlockRelayOutbound: uint32(targetBlockRelayOutbound),
+		Anchors:                  anchors,
+		FeelerInterval:           feelerInterval,
+		Dial:                     dcrdDial,
+		OnConnection:             s.outboundPeerConnected,
+		GetNewAddress:            newAddressFunc,
 	})
 	if err != nil {
 		return nil, err
@@ -2566,6 +2887,13 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		}()
 	}
 
+	if cfg.Electrum {
+		s.electrumServer, err = newElectrumServer(&s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &s, nil
 }
 
@@ -2600,6 +2928,37 @@ func addrStringToNetAddr(addr string) (net.Addr, error) {
 	}, nil
 }
 
+// discoverNAT searches the local network for a device supporting one of the
+// enabled port mapping protocols and returns a NAT for it, or nil if there is
+// none.  PCP and NAT-PMP are tried before UPnP since they are considerably
+// simpler and faster to discover.
+func discoverNAT() NAT {
+	if cfg.NATPMP {
+		gateway, err := defaultGateway()
+		if err != nil {
+			srvrLog.Warnf("Can't determine gateway for NAT-PMP: %v", err)
+		} else {
+			nat, err := DiscoverNATPMP(&net.UDPAddr{
+				IP:   gateway,
+				Port: natpmpPort,
+			})
+			if err == nil {
+				srvrLog.Infof("Discovered %v gateway %v", nat, gateway)
+				return nat
+			}
+			srvrLog.Warnf("Can't discover NAT-PMP or PCP: %v", err)
+		}
+	}
+	if cfg.Upnp {
+		nat, err := Discover()
+		if err == nil {
+			return nat
+		}
+		srvrLog.Warnf("Can't discover upnp: %v", err)
+	}
+	return nil
+}
+
 // dynamicTickDuration is a convenience function used to dynamically choose a
 // tick duration based on remaining time.  It is primarily used during
 // server shutdown to make shutdown warnings more frequent as the shutdown time
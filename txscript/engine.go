@@ -111,6 +111,34 @@ type Engine struct {
 	sigCache        *SigCache
 	bip16           bool     // treat execution as pay-to-script-hash
 	savedFirstStack [][]byte // stack from first script for bip16 scripts
+	sigBatch        *SigBatch
+	sigBatchID      int
+}
+
+// DeferSigChecks makes the engine defer the verification of secp256k1 Schnorr
+// and Bliss signatures checked by OP_CHECKSIGALT and OP_CHECKSIGALTVERIFY to
+// the passed signature batch, tagged with the passed identifier.  Only checks
+// whose result can't alter the execution of the script are deferred, which
+// are those of OP_CHECKSIGALTVERIFY and of an OP_CHECKSIGALT which is the
+// final opcode to be executed.  The deferred checks are treated as successful
+// during execution, so the scripts of an engine are only valid when both
+// Execute succeeds and the identifier is not returned by SigBatch.Verify.
+func (vm *Engine) DeferSigChecks(batch *SigBatch, id int) {
+	vm.sigBatch = batch
+	vm.sigBatchID = id
+}
+
+// isFinalOpcode returns whether the opcode being executed is the last opcode
+// of the final script, so that its result is only consumed by the check of
+// the final stack.
+func (vm *Engine) isFinalOpcode() bool {
+	// The redeem script of a pay-to-script-hash script is executed after
+	// the public key script.
+	if vm.bip16 && vm.scriptIdx < 2 {
+		return false
+	}
+	return vm.scriptIdx == len(vm.scripts)-1 &&
+		vm.scriptOff == len(vm.scripts[vm.scriptIdx])-1
 }
 
 // hasFlag returns whether the script engine instance has the passed flag set.
@@ -2960,6 +2960,26 @@ func opcodeCheckSigAlt(op *parsedOpcode, vm *Engine) error {
 		return nil
 	}
 
+	// Defer the verification of secp256k1 Schnorr and Bliss signatures to
+	// the signature batch of the engine when the result can't alter the
+	// execution of the script.  A failure of the deferred check makes the
+	// scripts invalid just as a failure here would.
+	switch sigTypes(sigType) {
+	case secSchnorr, bliss:
+		if vm.sigBatch != nil && (op.opcode.value == OP_CHECKSIGALTVERIFY ||
+			vm.isFinalOpcode()) {
+
+			vm.sigBatch.add(sigTypes(sigType), sigBatchEntry{
+				id:     vm.sigBatchID,
+				pubKey: pubKey,
+				hash:   hash,
+				sig:    signature,
+			})
+			vm.dstack.PushBool(true)
+			return nil
+		}
+	}
+
 	// Attempt to validate the signature.
 	switch sigTypes(sigType) {
 	case secp256k1:
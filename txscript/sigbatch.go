@@ -0,0 +1,180 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/coolsnady/hcd/chaincfg/chainec"
+	bs "github.com/coolsnady/hcd/crypto/bliss"
+	secp "github.com/coolsnady/hcd/dcrec/secp256k1"
+	"github.com/coolsnady/hcd/dcrec/secp256k1/schnorr"
+)
+
+// sigBatchChunkSize is the number of signatures which are verified together.
+// Larger secp256k1 Schnorr chunks are verified faster per signature, while
+// smaller chunks make it cheaper to find the invalid signatures of a failed
+// chunk.
+const sigBatchChunkSize = 128
+
+// sigBatchEntry is a signature check which was deferred to a SigBatch along
+// with the identifier of the engine which deferred it.
+type sigBatchEntry struct {
+	id     int
+	pubKey chainec.PublicKey
+	hash   []byte
+	sig    chainec.Signature
+}
+
+// SigBatch collects the secp256k1 Schnorr and Bliss signature checks of
+// OP_CHECKSIGALT and OP_CHECKSIGALTVERIFY which script engines defer so they
+// can be verified together once all of the scripts have been executed.  See
+// Engine.DeferSigChecks for details.
+type SigBatch struct {
+	sync.Mutex
+	schnorrSigs []sigBatchEntry
+	blissSigs   []sigBatchEntry
+}
+
+// NewSigBatch returns a new empty signature batch.
+func NewSigBatch() *SigBatch {
+	return &SigBatch{}
+}
+
+// Len returns the number of deferred signature checks in the batch.
+//
+// This function is safe for concurrent access.
+func (b *SigBatch) Len() int {
+	b.Lock()
+	n := len(b.schnorrSigs) + len(b.blissSigs)
+	b.Unlock()
+	return n
+}
+
+// add adds a deferred signature check of the given signature type to the
+// batch.
+//
+// This function is safe for concurrent access.
+func (b *SigBatch) add(sigType sigTypes, entry sigBatchEntry) {
+	b.Lock()
+	switch sigType {
+	case secSchnorr:
+		b.schnorrSigs = append(b.schnorrSigs, entry)
+	case bliss:
+		b.blissSigs = append(b.blissSigs, entry)
+	}
+	b.Unlock()
+}
+
+// verifySchnorrChunk returns the identifiers of the entries of a chunk of
+// deferred secp256k1 Schnorr signature checks with invalid signatures.
+func verifySchnorrChunk(entries []sigBatchEntry) []int {
+	curve := secp.S256()
+	pubKeys := make([]*secp.PublicKey, len(entries))
+	hashes := make([][]byte, len(entries))
+	sigs := make([]*schnorr.Signature, len(entries))
+	for i, e := range entries {
+		pubKeys[i] = secp.NewPublicKey(curve, e.pubKey.GetX(),
+			e.pubKey.GetY())
+		hashes[i] = e.hash
+		sigs[i] = schnorr.NewSignature(e.sig.GetR(), e.sig.GetS())
+	}
+	if schnorr.BatchVerify(curve, pubKeys, hashes, sigs) {
+		return nil
+	}
+
+	// The chunk contains at least one invalid signature, so check them
+	// individually to find out which.
+	var failed []int
+	for i, e := range entries {
+		if !schnorr.Verify(curve, pubKeys[i], e.hash, sigs[i].R,
+			sigs[i].S) {
+			failed = append(failed, e.id)
+		}
+	}
+	return failed
+}
+
+// verifyBlissChunk returns the identifiers of the entries of a chunk of
+// deferred Bliss signature checks with invalid signatures.  Bliss signatures
+// made with different keys can't be combined into a single check, so each of
+// them is verified once in turn and the chunks only provide the concurrency.
+func verifyBlissChunk(entries []sigBatchEntry) []int {
+	var failed []int
+	for _, e := range entries {
+		if !bs.Bliss.Verify(e.pubKey, e.hash, e.sig) {
+			failed = append(failed, e.id)
+		}
+	}
+	return failed
+}
+
+// Verify verifies all of the deferred signature checks of the batch and
+// returns the sorted identifiers of the engines which deferred an invalid
+// signature.  The batch is split into chunks which are verified concurrently.
+// The secp256k1 Schnorr signatures of a chunk are batch verified and only
+// verified individually when the chunk fails, so that only the engines with
+// invalid signatures are reported, while Bliss signatures are always verified
+// individually.
+//
+// This function is safe for concurrent access, however no checks may be
+// deferred to the batch while it is being verified.
+func (b *SigBatch) Verify() []int {
+	b.Lock()
+	defer b.Unlock()
+
+	type chunk struct {
+		entries []sigBatchEntry
+		verify  func([]sigBatchEntry) []int
+	}
+	var chunks []chunk
+	for i := 0; i < len(b.schnorrSigs); i += sigBatchChunkSize {
+		end := i + sigBatchChunkSize
+		if end > len(b.schnorrSigs) {
+			end = len(b.schnorrSigs)
+		}
+		chunks = append(chunks, chunk{b.schnorrSigs[i:end], verifySchnorrChunk})
+	}
+	for i := 0; i < len(b.blissSigs); i += sigBatchChunkSize {
+		end := i + sigBatchChunkSize
+		if end > len(b.blissSigs) {
+			end = len(b.blissSigs)
+		}
+		chunks = append(chunks, chunk{b.blissSigs[i:end], verifyBlissChunk})
+	}
+
+	// Verify the chunks concurrently while limiting the number of
+	// goroutines to the number of processors.
+	results := make([][]int, len(chunks))
+	sem := make(chan struct{}, runtime.NumCPU())
+	var wg sync.WaitGroup
+	wg.Add(len(chunks))
+	for i := range chunks {
+		sem <- struct{}{}
+		go func(i int) {
+			results[i] = chunks[i].verify(chunks[i].entries)
+			<-sem
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+
+	// Collect the unique identifiers of the failed engines.
+	seen := make(map[int]struct{})
+	var failed []int
+	for _, ids := range results {
+		for _, id := range ids {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			failed = append(failed, id)
+		}
+	}
+	sort.Ints(failed)
+	return failed
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"crypto/rand"
+	"reflect"
+	"testing"
+
+	"github.com/coolsnady/hcd/chaincfg/chainec"
+	"github.com/coolsnady/hcd/wire"
+)
+
+// testSigBatchFlags are the script flags used to execute the scripts of the
+// signature batch tests.
+const testSigBatchFlags = ScriptBip16 | ScriptVerifyCleanStack |
+	ScriptVerifyMinimalData
+
+// TestSigBatch ensures engines defer secp256k1 Schnorr signature checks to a
+// signature batch only when the result can't alter the execution of the
+// script, and that the batch reports the engines with invalid signatures.
+func TestSigBatch(t *testing.T) {
+	t.Parallel()
+
+	newKey := func() (chainec.PrivateKey, []byte) {
+		keyDB, _, _, err := chainec.SecSchnorr.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKey: %v", err)
+		}
+		key, pk := chainec.SecSchnorr.PrivKeyFromBytes(keyDB)
+		return key, pk.Serialize()
+	}
+
+	// Every third input uses OP_CHECKSIGALTVERIFY, every third input uses
+	// an OP_CHECKSIGALT whose result is consumed by OP_VERIFY, and the
+	// remaining inputs use a final OP_CHECKSIGALT.  Only the latter and
+	// the OP_CHECKSIGALTVERIFY checks may be deferred.
+	const numInputs = 30
+	tx := wire.NewMsgTx()
+	for i := 0; i < numInputs; i++ {
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: uint32(i)}, nil))
+	}
+	tx.AddTxOut(wire.NewTxOut(1, []byte{OP_TRUE}))
+	keys := make([]chainec.PrivateKey, numInputs)
+	pkScripts := make([][]byte, numInputs)
+	deferrable := make([]bool, numInputs)
+	for i := range pkScripts {
+		key, pkBytes := newKey()
+		builder := NewScriptBuilder().AddData(pkBytes).
+			AddInt64(int64(secSchnorr))
+		switch i % 3 {
+		case 0:
+			builder.AddOp(OP_CHECKSIGALTVERIFY).AddOp(OP_TRUE)
+			deferrable[i] = true
+		case 1:
+			builder.AddOp(OP_CHECKSIGALT).AddOp(OP_VERIFY).AddOp(OP_TRUE)
+		case 2:
+			builder.AddOp(OP_CHECKSIGALT)
+			deferrable[i] = true
+		}
+		pkScript, err := builder.Script()
+		if err != nil {
+			t.Fatalf("Script: %v", err)
+		}
+		keys[i], pkScripts[i] = key, pkScript
+	}
+
+	// signInput sets the signature script of the given input to a
+	// signature made with the passed key.
+	signInput := func(i int, key chainec.PrivateKey) {
+		sig, err := RawTxInSignatureAlt(tx, i, pkScripts[i], SigHashAll,
+			key, secSchnorr)
+		if err != nil {
+			t.Fatalf("RawTxInSignatureAlt: %v", err)
+		}
+		sigScript, err := NewScriptBuilder().AddData(sig).Script()
+		if err != nil {
+			t.Fatalf("Script: %v", err)
+		}
+		tx.TxIn[i].SignatureScript = sigScript
+	}
+	for i := range keys {
+		signInput(i, keys[i])
+	}
+
+	// execute executes the scripts of all inputs with the checks deferred
+	// to a new batch and returns the batch along with the indexes of the
+	// inputs which failed execution.
+	execute := func() (*SigBatch, []int) {
+		batch := NewSigBatch()
+		var failed []int
+		for i, pkScript := range pkScripts {
+			vm, err := NewEngine(pkScript, tx, i, testSigBatchFlags,
+				0, nil)
+			if err != nil {
+				t.Fatalf("NewEngine: %v", err)
+			}
+			vm.DeferSigChecks(batch, i)
+			if err := vm.Execute(); err != nil {
+				failed = append(failed, i)
+			}
+		}
+		return batch, failed
+	}
+
+	batch, failed := execute()
+	if failed != nil {
+		t.Fatalf("unexpected failed inputs %v", failed)
+	}
+	if batch.Len() != 2*numInputs/3 {
+		t.Fatalf("unexpected number of deferred checks - got %d, want %d",
+			batch.Len(), 2*numInputs/3)
+	}
+	if ids := batch.Verify(); ids != nil {
+		t.Fatalf("unexpected invalid signatures for inputs %v", ids)
+	}
+
+	// Sign a few inputs with the wrong keys and ensure the deferred ones
+	// are reported by the batch while the others fail execution.
+	badInputs := []int{3, 7, 10, 26}
+	var wantFailed, wantInvalid []int
+	for _, i := range badInputs {
+		wrongKey, _ := newKey()
+		signInput(i, wrongKey)
+		if deferrable[i] {
+			wantInvalid = append(wantInvalid, i)
+		} else {
+			wantFailed = append(wantFailed, i)
+		}
+	}
+	batch, failed = execute()
+	if !reflect.DeepEqual(failed, wantFailed) {
+		t.Fatalf("unexpected failed inputs - got %v, want %v", failed,
+			wantFailed)
+	}
+	if ids := batch.Verify(); !reflect.DeepEqual(ids, wantInvalid) {
+		t.Fatalf("unexpected invalid signatures - got %v, want %v", ids,
+			wantInvalid)
+	}
+
+	// Ensure all of the bad inputs fail execution when the checks are not
+	// deferred.
+	var failedImmediately []int
+	for i, pkScript := range pkScripts {
+		vm, err := NewEngine(pkScript, tx, i, testSigBatchFlags, 0, nil)
+		if err != nil {
+			t.Fatalf("NewEngine: %v", err)
+		}
+		if err := vm.Execute(); err != nil {
+			failedImmediately = append(failedImmediately, i)
+		}
+	}
+	if !reflect.DeepEqual(failedImmediately, badInputs) {
+		t.Fatalf("unexpected failed inputs without deferral - got %v, "+
+			"want %v", failedImmediately, badInputs)
+	}
+}
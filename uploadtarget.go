@@ -0,0 +1,161 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/coolsnady/hcd/chaincfg"
+)
+
+const (
+	// uploadTargetTimeframe is the length of a single upload target cycle.
+	// The number of bytes sent is reset at the start of every cycle.
+	uploadTargetTimeframe = 24 * time.Hour
+
+	// historicalBlockAge is how much older than the current best block a
+	// block must be before it is considered historical.  Historical blocks
+	// are no longer served to non-whitelisted peers once the upload target
+	// has been reached.
+	historicalBlockAge = 7 * 24 * time.Hour
+)
+
+// uploadTargetStatus is a snapshot of the state of the upload target.
+type uploadTargetStatus struct {
+	Timeframe             time.Duration
+	Target                uint64
+	TargetReached         bool
+	ServeHistoricalBlocks bool
+	BytesLeftInCycle      uint64
+	TimeLeftInCycle       time.Duration
+}
+
+// uploadTarget keeps track of the number of bytes sent to peers during the
+// current upload cycle and determines whether the maximum upload target
+// configured via --maxuploadtarget has been reached.
+type uploadTarget struct {
+	target       uint64        // Max bytes per cycle, 0 for no limit.
+	blockBuffer  uint64        // Max size of a single block.
+	blockTime    time.Duration // Target time per block.
+	timeSource   func() time.Time
+	mtx          sync.Mutex
+	cycleStart   time.Time
+	bytesInCycle uint64
+}
+
+// maxBlockSize returns the largest maximum block size of the passed network.
+func maxBlockSize(params *chaincfg.Params) uint64 {
+	var maxSize int
+	for _, size := range params.MaximumBlockSizes {
+		if size > maxSize {
+			maxSize = size
+		}
+	}
+	return uint64(maxSize)
+}
+
+// minUploadTarget returns the smallest upload target in bytes for the passed
+// network which allows historical blocks to be served.  Since the buffer
+// reserved for relaying new blocks at the start of a cycle is a maximum sized
+// block for every expected block in the cycle, any target up to that amount
+// would never serve historical blocks.  The minimum is twice the buffer so at
+// least half of the target is available for them.
+func minUploadTarget(params *chaincfg.Params) uint64 {
+	if params.TargetTimePerBlock <= 0 {
+		return 0
+	}
+	blocksPerCycle := uint64(uploadTargetTimeframe / params.TargetTimePerBlock)
+	return 2 * blocksPerCycle * maxBlockSize(params)
+}
+
+// newUploadTarget returns a new upload target which allows up to target bytes
+// to be sent per cycle.  A target of zero disables the limit.  Targets below
+// the value returned by minUploadTarget never serve historical blocks, so the
+// caller is expected to enforce it.
+func newUploadTarget(target uint64, params *chaincfg.Params) *uploadTarget {
+	return &uploadTarget{
+		target:      target,
+		blockBuffer: maxBlockSize(params),
+		blockTime:   params.TargetTimePerBlock,
+		timeSource:  time.Now,
+	}
+}
+
+// maybeStartCycle starts a new upload cycle when the current one has ended.
+//
+// This function MUST be called with the upload target lock held.
+func (u *uploadTarget) maybeStartCycle(now time.Time) {
+	if now.Sub(u.cycleStart) >= uploadTargetTimeframe {
+		u.cycleStart = now
+		u.bytesInCycle = 0
+	}
+}
+
+// AddBytesSent adds the passed number of bytes to the bytes sent during the
+// current cycle.
+//
+// This function is safe for concurrent access.
+func (u *uploadTarget) AddBytesSent(n uint64) {
+	u.mtx.Lock()
+	u.maybeStartCycle(u.timeSource())
+	u.bytesInCycle += n
+	u.mtx.Unlock()
+}
+
+// reached returns whether the upload target has been reached.  When
+// historical is set, a buffer large enough to continue relaying a maximum
+// sized block for every expected block in the remainder of the cycle is
+// reserved so that serving historical blocks does not starve block relay.
+//
+// This function MUST be called with the upload target lock held.
+func (u *uploadTarget) reached(now time.Time, historical bool) bool {
+	if u.target == 0 {
+		return false
+	}
+
+	u.maybeStartCycle(now)
+	var buffer uint64
+	if historical && u.blockTime > 0 {
+		timeLeft := uploadTargetTimeframe - now.Sub(u.cycleStart)
+		buffer = uint64(timeLeft/u.blockTime) * u.blockBuffer
+	}
+	return buffer >= u.target || u.bytesInCycle >= u.target-buffer
+}
+
+// Reached returns whether the upload target has been reached.  See reached
+// for details on the historical parameter.
+//
+// This function is safe for concurrent access.
+func (u *uploadTarget) Reached(historical bool) bool {
+	u.mtx.Lock()
+	reached := u.reached(u.timeSource(), historical)
+	u.mtx.Unlock()
+	return reached
+}
+
+// Status returns a snapshot of the current state of the upload target.
+//
+// This function is safe for concurrent access.
+func (u *uploadTarget) Status() *uploadTargetStatus {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	now := u.timeSource()
+	status := &uploadTargetStatus{
+		Timeframe:             uploadTargetTimeframe,
+		Target:                u.target,
+		TargetReached:         u.reached(now, false),
+		ServeHistoricalBlocks: !u.reached(now, true),
+	}
+	if u.target == 0 {
+		return status
+	}
+	if u.bytesInCycle < u.target {
+		status.BytesLeftInCycle = u.target - u.bytesInCycle
+	}
+	status.TimeLeftInCycle = uploadTargetTimeframe - now.Sub(u.cycleStart)
+	return status
+}
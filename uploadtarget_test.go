@@ -0,0 +1,123 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coolsnady/hcd/chaincfg"
+)
+
+// TestUploadTarget ensures the upload target correctly tracks the bytes sent
+// during a cycle, reserves a buffer for relaying new blocks when determining
+// whether historical blocks may be served, and resets at each new cycle.
+func TestUploadTarget(t *testing.T) {
+	params := &chaincfg.Params{
+		MaximumBlockSizes:  []int{1000, 2000},
+		TargetTimePerBlock: time.Hour,
+	}
+	now := time.Unix(1500000000, 0)
+	u := newUploadTarget(100000, params)
+	u.timeSource = func() time.Time { return now }
+
+	// Nothing has been sent yet, so neither target should be reached.  A
+	// full cycle requires a buffer of 24 blocks of 2000 bytes each.
+	if u.Reached(false) || u.Reached(true) {
+		t.Fatal("upload target reached before any bytes were sent")
+	}
+	status := u.Status()
+	if status.BytesLeftInCycle != 100000 {
+		t.Fatalf("unexpected bytes left in cycle -- got %d, want %d",
+			status.BytesLeftInCycle, 100000)
+	}
+	if status.TimeLeftInCycle != uploadTargetTimeframe {
+		t.Fatalf("unexpected time left in cycle -- got %v, want %v",
+			status.TimeLeftInCycle, uploadTargetTimeframe)
+	}
+
+	// Exceeding the target minus the block buffer must stop historical
+	// blocks from being served while still allowing new blocks.
+	u.AddBytesSent(100000 - 48000)
+	if u.Reached(false) {
+		t.Fatal("upload target reached before the target was hit")
+	}
+	if !u.Reached(true) {
+		t.Fatal("historical upload target not reached")
+	}
+
+	// The buffer shrinks as the cycle progresses.
+	now = now.Add(12 * time.Hour)
+	if u.Reached(true) {
+		t.Fatal("historical upload target reached after buffer shrank")
+	}
+
+	// Exceeding the target must mark it reached for all blocks.
+	u.AddBytesSent(48000)
+	status = u.Status()
+	if !status.TargetReached || status.ServeHistoricalBlocks {
+		t.Fatalf("unexpected status after hitting target: %+v", status)
+	}
+	if status.BytesLeftInCycle != 0 {
+		t.Fatalf("unexpected bytes left in cycle -- got %d, want 0",
+			status.BytesLeftInCycle)
+	}
+
+	// A new cycle resets the count.
+	now = now.Add(12 * time.Hour)
+	if u.Reached(false) || u.Reached(true) {
+		t.Fatal("upload target reached after start of new cycle")
+	}
+
+	// A zero target disables the limit entirely.
+	u = newUploadTarget(0, params)
+	u.AddBytesSent(1 << 40)
+	if u.Reached(false) || u.Reached(true) {
+		t.Fatal("disabled upload target reported as reached")
+	}
+}
+
+// TestMinUploadTarget ensures the minimum upload target leaves room to serve
+// historical blocks at the start of a cycle while targets no larger than the
+// buffer reserved for relaying new blocks never serve them.
+func TestMinUploadTarget(t *testing.T) {
+	params := &chaincfg.Params{
+		MaximumBlockSizes:  []int{1000, 2000},
+		TargetTimePerBlock: time.Hour,
+	}
+	now := time.Unix(1500000000, 0)
+
+	// A full cycle requires a buffer of 24 blocks of 2000 bytes each.
+	minTarget := minUploadTarget(params)
+	if minTarget != 96000 {
+		t.Fatalf("unexpected minimum upload target -- got %d, want %d",
+			minTarget, 96000)
+	}
+
+	u := newUploadTarget(48000, params)
+	u.timeSource = func() time.Time { return now }
+	if !u.Reached(true) {
+		t.Fatal("historical blocks served with a target no larger " +
+			"than the block buffer")
+	}
+
+	u = newUploadTarget(minTarget, params)
+	u.timeSource = func() time.Time { return now }
+	if u.Reached(true) {
+		t.Fatal("historical blocks not served with the minimum target")
+	}
+	u.AddBytesSent(minTarget / 2)
+	if !u.Reached(true) {
+		t.Fatal("historical upload target not reached after sending " +
+			"half of the minimum target")
+	}
+
+	// The minimum of the main network is 432 MiB.
+	minTarget = minUploadTarget(&chaincfg.MainNetParams)
+	if minTarget != 432*1024*1024 {
+		t.Fatalf("unexpected main network minimum upload target -- "+
+			"got %d, want %d", minTarget, 432*1024*1024)
+	}
+}
@@ -383,6 +383,11 @@ func (n *upnpNAT) AddPortMapping(protocol string, externalPort, internalPort int
 	return
 }
 
+// String returns the name of the protocol in use.
+func (n *upnpNAT) String() string {
+	return "UPnP"
+}
+
 // DeletePortMapping implements the NAT interface by removing up a port forwarding
 // from the UPnP router to the local machine with the given ports and.
 func (n *upnpNAT) DeletePortMapping(protocol string, externalPort, internalPort int) (err error) {
@@ -146,7 +146,9 @@ Errors returned by this package are either the raw errors provided by underlying
 calls to read/write from streams such as io.EOF, io.ErrUnexpectedEOF, and
 io.ErrShortWrite, or of type wire.MessageError.  This allows the caller to
 differentiate between general IO errors and malformed messages through type
-assertions.
+assertions.  ReadMessage and ReadMessageN return errors of type
+wire.PayloadError when the payload of a message with a valid header fails to
+decode, so the caller can also tell those apart from malformed headers.
 
 Bitcoin Improvement Proposals
 
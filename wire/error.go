@@ -34,3 +34,18 @@ func (e *MessageError) Error() string {
 func messageError(f string, desc string) *MessageError {
 	return &MessageError{Func: f, Description: desc}
 }
+
+// PayloadError describes a failure to decode the payload of a message whose
+// header passed all of the checks, including the payload checksum.  Since the
+// checksum rules out transmission errors, such failures are caused by the
+// sender and can be told apart from issues with the message header, which are
+// reported as MessageError, through a type assertion.
+type PayloadError struct {
+	Command string // Command of the message
+	Err     error  // Error returned while decoding the payload
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *PayloadError) Error() string {
+	return fmt.Sprintf("failed to decode %v payload: %v", e.Command, e.Err)
+}
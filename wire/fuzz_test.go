@@ -0,0 +1,213 @@
+// Copyright (c) 2018-2020 The Hcd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package wire
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/coolsnady/hcd/chaincfg/chainhash"
+)
+
+// fuzzBtcDecode runs a fuzz target which decodes arbitrary data into the
+// message returned by newMsg.  Decoding must never panic, and any message which
+// decodes successfully and can be encoded again must survive a round trip
+// through its own encoding unchanged.  The encoding of each of the passed
+// messages is used to seed the corpus.
+func fuzzBtcDecode(f *testing.F, newMsg func() Message, seeds ...Message) {
+	for _, seed := range seeds {
+		var buf bytes.Buffer
+		if err := seed.BtcEncode(&buf, ProtocolVersion); err != nil {
+			f.Fatalf("BtcEncode: unexpected error encoding seed %T: %v",
+				seed, err)
+		}
+		f.Add(buf.Bytes())
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg := newMsg()
+		if err := msg.BtcDecode(bytes.NewBuffer(data), ProtocolVersion); err != nil {
+			return
+		}
+
+		var encoded bytes.Buffer
+		if err := msg.BtcEncode(&encoded, ProtocolVersion); err != nil {
+			return
+		}
+		msg2 := newMsg()
+		err := msg2.BtcDecode(bytes.NewBuffer(encoded.Bytes()), ProtocolVersion)
+		if err != nil {
+			t.Fatalf("BtcDecode: failed to decode own encoding of %T: %v",
+				msg, err)
+		}
+		var reencoded bytes.Buffer
+		if err := msg2.BtcEncode(&reencoded, ProtocolVersion); err != nil {
+			t.Fatalf("BtcEncode: failed to encode decoded %T: %v", msg2,
+				err)
+		}
+		if !bytes.Equal(encoded.Bytes(), reencoded.Bytes()) {
+			t.Fatalf("%T did not survive round trip:\n%x\n%x", msg,
+				encoded.Bytes(), reencoded.Bytes())
+		}
+	})
+}
+
+func FuzzMsgVersion(f *testing.F) {
+	me := NewNetAddressIPPort(net.ParseIP("127.0.0.1"), 9108, SFNodeNetwork)
+	you := NewNetAddressIPPort(net.ParseIP("192.168.0.1"), 9108, SFNodeNetwork)
+	fuzzBtcDecode(f, func() Message { return &MsgVersion{} },
+		NewMsgVersion(me, you, 123123, 0))
+}
+
+func FuzzMsgVerAck(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgVerAck{} }, NewMsgVerAck())
+}
+
+func FuzzMsgGetAddr(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgGetAddr{} }, NewMsgGetAddr())
+}
+
+func FuzzMsgAddr(f *testing.F) {
+	msg := NewMsgAddr()
+	msg.AddAddress(NewNetAddressIPPort(net.ParseIP("10.0.0.1"), 9108,
+		SFNodeNetwork))
+	fuzzBtcDecode(f, func() Message { return &MsgAddr{} }, msg)
+}
+
+func FuzzMsgGetBlocks(f *testing.F) {
+	msg := NewMsgGetBlocks(&chainhash.Hash{})
+	msg.AddBlockLocatorHash(&mainNetGenesisHash)
+	fuzzBtcDecode(f, func() Message { return &MsgGetBlocks{} }, msg)
+}
+
+func FuzzMsgBlock(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgBlock{} }, &testBlock)
+}
+
+func FuzzMsgInv(f *testing.F) {
+	msg := NewMsgInv()
+	msg.AddInvVect(NewInvVect(InvTypeBlock, &mainNetGenesisHash))
+	fuzzBtcDecode(f, func() Message { return &MsgInv{} }, msg)
+}
+
+func FuzzMsgGetData(f *testing.F) {
+	msg := NewMsgGetData()
+	msg.AddInvVect(NewInvVect(InvTypeTx, &mainNetGenesisHash))
+	fuzzBtcDecode(f, func() Message { return &MsgGetData{} }, msg)
+}
+
+func FuzzMsgNotFound(f *testing.F) {
+	msg := NewMsgNotFound()
+	msg.AddInvVect(NewInvVect(InvTypeTx, &mainNetGenesisHash))
+	fuzzBtcDecode(f, func() Message { return &MsgNotFound{} }, msg)
+}
+
+func FuzzMsgTx(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgTx{} },
+		testBlock.Transactions[0], testBlock.STransactions[0])
+}
+
+func FuzzMsgPing(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgPing{} }, NewMsgPing(1))
+}
+
+func FuzzMsgPong(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgPong{} }, NewMsgPong(1))
+}
+
+func FuzzMsgGetHeaders(f *testing.F) {
+	msg := NewMsgGetHeaders()
+	msg.AddBlockLocatorHash(&mainNetGenesisHash)
+	fuzzBtcDecode(f, func() Message { return &MsgGetHeaders{} }, msg)
+}
+
+func FuzzMsgHeaders(f *testing.F) {
+	msg := NewMsgHeaders()
+	msg.AddBlockHeader(&testBlock.Header)
+	fuzzBtcDecode(f, func() Message { return &MsgHeaders{} }, msg)
+}
+
+func FuzzMsgAlert(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgAlert{} },
+		NewMsgAlert([]byte("payload"), []byte("signature")))
+}
+
+func FuzzMsgMemPool(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgMemPool{} }, NewMsgMemPool())
+}
+
+func FuzzMsgMiningState(f *testing.F) {
+	msg := NewMsgMiningState()
+	msg.AddBlockHash(&mainNetGenesisHash)
+	msg.AddVoteHash(&mainNetGenesisHash)
+	fuzzBtcDecode(f, func() Message { return &MsgMiningState{} }, msg)
+}
+
+func FuzzMsgGetMiningState(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgGetMiningState{} },
+		NewMsgGetMiningState())
+}
+
+func FuzzMsgFilterAdd(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgFilterAdd{} },
+		NewMsgFilterAdd([]byte{0x01}))
+}
+
+func FuzzMsgFilterClear(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgFilterClear{} },
+		NewMsgFilterClear())
+}
+
+func FuzzMsgFilterLoad(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgFilterLoad{} },
+		NewMsgFilterLoad([]byte{0x01}, 10, 0, BloomUpdateNone))
+}
+
+func FuzzMsgMerkleBlock(f *testing.F) {
+	msg := NewMsgMerkleBlock(&testBlock.Header)
+	msg.AddTxHash(&mainNetGenesisHash)
+	msg.Flags = []byte{0x01}
+	fuzzBtcDecode(f, func() Message { return &MsgMerkleBlock{} }, msg)
+}
+
+func FuzzMsgReject(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgReject{} },
+		NewMsgReject("block", RejectDuplicate, "duplicate block"))
+}
+
+func FuzzMsgSendHeaders(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgSendHeaders{} },
+		NewMsgSendHeaders())
+}
+
+func FuzzMsgFeeFilter(f *testing.F) {
+	fuzzBtcDecode(f, func() Message { return &MsgFeeFilter{} },
+		NewMsgFeeFilter(10000))
+}
+
+// FuzzReadMessage ensures reading arbitrary data as a framed message never
+// panics and never reads more than the data provided.
+func FuzzReadMessage(f *testing.F) {
+	for _, msg := range []Message{NewMsgPing(1), &testBlock, NewMsgVerAck()} {
+		var buf bytes.Buffer
+		if err := WriteMessage(&buf, msg, ProtocolVersion, MainNet); err != nil {
+			f.Fatalf("WriteMessage: unexpected error: %v", err)
+		}
+		f.Add(buf.Bytes())
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		n, _, _, _ := ReadMessageN(bytes.NewReader(data), ProtocolVersion,
+			MainNet)
+		if n > len(data) {
+			t.Fatalf("ReadMessageN: read %d bytes from %d bytes of "+
+				"data", n, len(data))
+		}
+	})
+}
@@ -363,7 +363,8 @@ func ReadMessageN(r io.Reader, pver uint32, dcrnet CurrencyNet) (int, Message, [
 	pr := bytes.NewBuffer(payload)
 	err = msg.BtcDecode(pr, pver)
 	if err != nil {
-		return totalBytes, nil, nil, err
+		return totalBytes, nil, nil, &PayloadError{Command: command,
+			Err: err}
 	}
 
 	return totalBytes, msg, payload, nil
@@ -213,6 +213,15 @@ func TestReadMessageWireErrors(t *testing.T) {
 			testErr.Error(), wantErr)
 	}
 
+	// Ensure payload errors are as expected.
+	payloadErr := PayloadError{Command: "foo", Err: &testErr}
+	wantPayloadErr := "failed to decode foo payload: " + wantFunc + ": " +
+		wantErr
+	if payloadErr.Error() != wantPayloadErr {
+		t.Errorf("PayloadError: wrong error - got %v, want %v",
+			payloadErr.Error(), wantPayloadErr)
+	}
+
 	// Wire encoded bytes for main and testnet networks magic identifiers.
 	testNet2Bytes := makeHeader(TestNet2, "", 0, 0)
 
@@ -348,7 +357,7 @@ func TestReadMessageWireErrors(t *testing.T) {
 			pver,
 			dcrnet,
 			len(badMessageBytes),
-			&MessageError{},
+			&PayloadError{},
 			25,
 		},
 
@@ -380,9 +389,11 @@ func TestReadMessageWireErrors(t *testing.T) {
 				"got %d, want %d", i, nr, test.bytes)
 		}
 
-		// For errors which are not of type MessageError, check them for
-		// equality.
-		if _, ok := err.(*MessageError); !ok {
+		// For errors which are not of type MessageError or
+		// PayloadError, check them for equality.
+		switch err.(type) {
+		case *MessageError, *PayloadError:
+		default:
 			if err != test.readErr {
 				t.Errorf("ReadMessage #%d wrong error got: %v <%T>, "+
 					"want: %v <%T>", i, err, err,
@@ -458,9 +469,11 @@ func TestWriteMessageWireErrors(t *testing.T) {
 				"written - got %d, want %d", i, nw, test.bytes)
 		}
 
-		// For errors which are not of type MessageError, check them for
-		// equality.
-		if _, ok := err.(*MessageError); !ok {
+		// For errors which are not of type MessageError or
+		// PayloadError, check them for equality.
+		switch err.(type) {
+		case *MessageError, *PayloadError:
+		default:
 			if err != test.err {
 				t.Errorf("ReadMessage #%d wrong error got: %v <%T>, "+
 					"want: %v <%T>", i, err, err,
@@ -115,7 +115,7 @@ func (msg *MsgMerkleBlock) BtcDecode(r io.Reader, pver uint32) error {
 		return messageError("MsgMerkleBlock.BtcDecode", str)
 	}
 
-	hashes = make([]chainhash.Hash, count)
+	hashes = make([]chainhash.Hash, scount)
 	msg.SHashes = make([]*chainhash.Hash, 0, scount)
 	for i := uint64(0); i < scount; i++ {
 		hash := &hashes[i]
@@ -417,6 +417,31 @@ func TestMerkleBlockOverflowErrors(t *testing.T) {
 	}
 }
 
+// TestMerkleBlockMoreStakeHashes ensures merkle blocks with more stake
+// transaction hashes than regular transaction hashes decode properly.
+func TestMerkleBlockMoreStakeHashes(t *testing.T) {
+	pver := ProtocolVersion
+
+	msg := NewMsgMerkleBlock(&testMerkleBlock.Header)
+	for i := byte(0); i < 3; i++ {
+		msg.AddSTxHash(&chainhash.Hash{i})
+	}
+	msg.Flags = []byte{0x01}
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("BtcEncode: unexpected error: %v", err)
+	}
+
+	var got MsgMerkleBlock
+	if err := got.BtcDecode(bytes.NewReader(buf.Bytes()), pver); err != nil {
+		t.Fatalf("BtcDecode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&got, msg) {
+		t.Fatalf("BtcDecode: mismatched message - got %v, want %v",
+			spew.Sdump(&got), spew.Sdump(msg))
+	}
+}
+
 // testMerkleBlock is a basic normative merkle block that is used throughout the
 // tests.
 var testMerkleBlock = MsgMerkleBlock{